@@ -6,6 +6,7 @@ import (
 	"log"
 	"strconv"
 
+	"github.com/canonical/notary/internal/db"
 	"github.com/canonical/notary/internal/db/migrations"
 	"github.com/pressly/goose/v3"
 	"github.com/spf13/cobra"
@@ -40,19 +41,14 @@ Use with no argument to apply all migrations. Use with a version number to apply
 		if err != nil {
 			return err
 		}
-		db, err := sql.Open("sqlite", dsn)
+		conn, err := sql.Open("sqlite", dsn)
 		if err != nil {
 			return err
 		}
-		if version == 0 {
-			err = goose.UpContext(cmd.Context(), db, ".", goose.WithNoColor(true))
-		} else {
-			err = goose.UpToContext(cmd.Context(), db, ".", version, goose.WithNoColor(true))
-		}
-		if err != nil {
+		if err := db.MigrateUp(cmd.Context(), conn, version); err != nil {
 			return err
 		}
-		if err := db.Close(); err != nil {
+		if err := conn.Close(); err != nil {
 			return err
 		}
 		return nil
@@ -45,11 +45,21 @@ https://canonical-notary.readthedocs-hosted.com/en/latest/reference/config_file/
 		if err != nil {
 			l.Fatal("couldn't initialize server", zap.Error(err))
 		}
+		if appEnv.ACMEDNSRepository != nil {
+			if err := appEnv.ACMEDNSRepository.Start(); err != nil {
+				l.Fatal("couldn't start acme-dns server", zap.Error(err))
+			}
+			l.Info("Started acme-dns server", zap.String("domain", appEnv.ACMEDNSRepository.Domain()))
+		}
 		appEnv.AuditLogger.SystemStartup(srv.Addr)
 		l.Info("Starting server at", zap.String("url", srv.Addr))
 		if err := srv.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
 			l.Fatal("HTTP server ListenAndServe", zap.Error(err))
 		}
+		appEnv.JobRunner.Stop()
+		if appEnv.ACMEDNSRepository != nil {
+			appEnv.ACMEDNSRepository.Stop()
+		}
 		appEnv.AuditLogger.SystemShutdown("server stopped")
 		l.Info("Shutting down server")
 
@@ -0,0 +1,311 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/canonical/notary/internal/db"
+	legoacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	legoconfig "github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+const mailTo = "mailto:"
+
+// errKeyChangeUnconfirmed is returned when the key change request was sent but its answer was lost,
+// so the ACME server may have changed the key of the account.
+var errKeyChangeUnconfirmed = errors.New("the ACME server didn't answer the key change")
+
+// AccountContacts returns the contact emails of an ACME account, as the ACME server last returned them.
+func AccountContacts(account *db.ACMEAccount) []string {
+	contacts := []string{}
+	var reg registration.Resource
+	if err := json.Unmarshal([]byte(account.RegistrationBody), &reg); err != nil {
+		return contacts
+	}
+	for _, contact := range reg.Body.Contact {
+		contacts = append(contacts, strings.TrimPrefix(contact, mailTo))
+	}
+	return contacts
+}
+
+// accountCore returns the ACME API client of an account whose private key is decrypted,
+// and the HTTP client it sends its requests with.
+func accountCore(account *db.ACMEAccount) (*api.Core, *acmeUser, *http.Client, error) {
+	user, err := accountUser(account)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("acme: %w", err)
+	}
+	cfg := legoconfig.NewConfig(user)
+	core, err := api.New(cfg.HTTPClient, cfg.UserAgent, account.DirectoryURL, account.RegistrationURI, user.key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("acme: failed to create ACME client: %w", err)
+	}
+	return core, user, cfg.HTTPClient, nil
+}
+
+// withRegistration returns a copy of the account with the registration returned by the ACME server.
+func withRegistration(account *db.ACMEAccount, body legoacme.Account) (*db.ACMEAccount, error) {
+	regBody, err := json.Marshal(registration.Resource{Body: body, URI: account.RegistrationURI})
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to marshal ACME registration: %w", err)
+	}
+	updated := *account
+	updated.RegistrationBody = string(regBody)
+	switch body.Status {
+	case legoacme.StatusDeactivated:
+		updated.Status = db.ACMEAccountStatusDeactivated
+	case legoacme.StatusRevoked:
+		updated.Status = db.ACMEAccountStatusRevoked
+	default:
+		updated.Status = db.ACMEAccountStatusValid
+	}
+	return &updated, nil
+}
+
+// QueryAccount fetches the registration of an account from its ACME server, and returns the account with it.
+// The private key of the account must be decrypted.
+func QueryAccount(account *db.ACMEAccount) (*db.ACMEAccount, error) {
+	core, _, _, err := accountCore(account)
+	if err != nil {
+		return nil, err
+	}
+	body, err := core.Accounts.Get(account.RegistrationURI)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to query ACME account: %w", err)
+	}
+	return withRegistration(account, body)
+}
+
+// UpdateAccountContacts replaces the contact emails of an account on its ACME server, and returns the account
+// with its updated registration. The private key of the account must be decrypted.
+func UpdateAccountContacts(account *db.ACMEAccount, emails []string) (*db.ACMEAccount, error) {
+	if len(emails) == 0 {
+		return nil, errors.New("acme: an ACME account needs at least one contact email")
+	}
+	core, _, _, err := accountCore(account)
+	if err != nil {
+		return nil, err
+	}
+	contacts := make([]string, 0, len(emails))
+	for _, email := range emails {
+		contacts = append(contacts, mailTo+email)
+	}
+	body, err := core.Accounts.Update(account.RegistrationURI, legoacme.Account{Contact: contacts})
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to update ACME account contacts: %w", err)
+	}
+	return withRegistration(account, body)
+}
+
+// DeactivateAccount deactivates an account on its ACME server, which then refuses every request signed by it,
+// and returns the account with its updated registration. The private key of the account must be decrypted.
+func DeactivateAccount(account *db.ACMEAccount) (*db.ACMEAccount, error) {
+	core, _, _, err := accountCore(account)
+	if err != nil {
+		return nil, err
+	}
+	if err := core.Accounts.Deactivate(account.RegistrationURI); err != nil {
+		return nil, fmt.Errorf("acme: failed to deactivate ACME account: %w", err)
+	}
+	var reg registration.Resource
+	if err := json.Unmarshal([]byte(account.RegistrationBody), &reg); err != nil {
+		return nil, fmt.Errorf("acme: failed to unmarshal ACME registration: %w", err)
+	}
+	reg.Body.Status = legoacme.StatusDeactivated
+	return withRegistration(account, reg.Body)
+}
+
+// RollOverAccountKey replaces the key of an account with a new one on its ACME server (RFC 8555, section 7.3.5),
+// and returns the account with the new key. The new key is stored as the pending key of the account before the
+// ACME server is asked to change the key, replaces the current key once the server accepted it, and is discarded
+// if the server refused it. The account stays locked during the rollover, so that no order loads its key meanwhile.
+func RollOverAccountKey(database *db.DatabaseRepository, accountID int64) (*db.ACMEAccount, error) {
+	account, err := database.GetDecryptedACMEAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to load ACME account: %w", err)
+	}
+	unlock := lockAccount(account.Email, account.DirectoryURL)
+	defer unlock()
+	account, err = database.GetDecryptedACMEAccount(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to load ACME account: %w", err)
+	}
+	if account.PendingPrivateKeyPEM != "" {
+		// A previous rollover stopped before the answer of the ACME server was stored.
+		account, err = settlePendingKey(database, account)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to generate ACME account key: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(newKey)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to marshal ACME account key: %w", err)
+	}
+	newKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err := database.SetACMEAccountPendingKey(account.ID, newKeyPEM); err != nil {
+		return nil, fmt.Errorf("acme: failed to store the new ACME account key: %w", err)
+	}
+	pending := *account
+	pending.PendingPrivateKeyPEM = newKeyPEM
+
+	if err := changeAccountKey(account, newKey); err != nil {
+		if !errors.Is(err, errKeyChangeUnconfirmed) {
+			// The ACME server refused the new key, the account keeps its current key.
+			if clearErr := database.ClearACMEAccountPendingKey(account.ID); clearErr != nil {
+				return nil, fmt.Errorf("acme: failed to discard the new ACME account key: %w", clearErr)
+			}
+			return nil, fmt.Errorf("acme: account key rollover failed: %w", err)
+		}
+		settled, settleErr := settlePendingKey(database, &pending)
+		if settleErr != nil {
+			return nil, fmt.Errorf("acme: account key rollover failed: %w", err)
+		}
+		if settled.PrivateKeyPEM != newKeyPEM {
+			return nil, fmt.Errorf("acme: account key rollover failed: %w", err)
+		}
+		return settled, nil
+	}
+
+	if err := database.PromoteACMEAccountPendingKey(account.ID); err != nil {
+		// The new key stays pending, and is promoted by the next rollover once the ACME server confirms it.
+		return nil, fmt.Errorf("acme: failed to store the new ACME account key: %w", err)
+	}
+	updated := *account
+	updated.PrivateKeyPEM = newKeyPEM
+	return &updated, nil
+}
+
+// settlePendingKey resolves a rollover whose outcome is unknown by querying the account with both of its keys.
+// The pending key replaces the current one if the ACME server accepts it, and is discarded if the server still
+// accepts the current key. The pending key is kept if the server accepts neither, for instance when it can't
+// be reached.
+func settlePendingKey(database *db.DatabaseRepository, account *db.ACMEAccount) (*db.ACMEAccount, error) {
+	withPendingKey := *account
+	withPendingKey.PrivateKeyPEM = account.PendingPrivateKeyPEM
+	withPendingKey.PendingPrivateKeyPEM = ""
+	if _, err := QueryAccount(&withPendingKey); err == nil {
+		if err := database.PromoteACMEAccountPendingKey(account.ID); err != nil {
+			return nil, fmt.Errorf("acme: failed to store the new ACME account key: %w", err)
+		}
+		return &withPendingKey, nil
+	}
+	if _, err := QueryAccount(account); err != nil {
+		return nil, fmt.Errorf("acme: the ACME server accepts neither key of the account: %w", err)
+	}
+	if err := database.ClearACMEAccountPendingKey(account.ID); err != nil {
+		return nil, fmt.Errorf("acme: failed to discard the new ACME account key: %w", err)
+	}
+	withCurrentKey := *account
+	withCurrentKey.PendingPrivateKeyPEM = ""
+	return &withCurrentKey, nil
+}
+
+// changeAccountKey asks the ACME server to replace the key of the account with newKey.
+// The private key of the account must be decrypted.
+func changeAccountKey(account *db.ACMEAccount, newKey *ecdsa.PrivateKey) error {
+	core, user, httpClient, err := accountCore(account)
+	if err != nil {
+		return err
+	}
+	oldKey, ok := user.key.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("acme: unsupported ACME account key type")
+	}
+	keyChangeURL := core.GetDirectory().KeyChangeURL
+	if keyChangeURL == "" {
+		return errors.New("acme: the ACME server doesn't support account key rollover")
+	}
+
+	// The inner JWS is signed by the new key and names the account and its old key.
+	// The outer JWS is signed by the old key, as every other request of the account.
+	innerPayload, err := json.Marshal(struct {
+		Account string          `json:"account"`
+		OldKey  jose.JSONWebKey `json:"oldKey"`
+	}{account.RegistrationURI, jose.JSONWebKey{Key: &oldKey.PublicKey}})
+	if err != nil {
+		return fmt.Errorf("acme: failed to marshal key change: %w", err)
+	}
+	inner, err := signJWS(jose.JSONWebKey{Key: newKey}, true, map[jose.HeaderKey]any{"url": keyChangeURL}, innerPayload)
+	if err != nil {
+		return err
+	}
+	nonce, err := fetchNonce(httpClient, core.GetDirectory().NewNonceURL)
+	if err != nil {
+		return err
+	}
+	outer, err := signJWS(jose.JSONWebKey{Key: oldKey, KeyID: account.RegistrationURI}, false,
+		map[jose.HeaderKey]any{"url": keyChangeURL, "nonce": nonce}, []byte(inner))
+	if err != nil {
+		return err
+	}
+	return postJWS(httpClient, keyChangeURL, outer)
+}
+
+// signJWS signs the payload with a P-256 key and returns the flattened JSON serialization of the JWS.
+// embedJWK puts the public key in the protected header instead of the key ID.
+func signJWS(key jose.JSONWebKey, embedJWK bool, headers map[jose.HeaderKey]any, payload []byte) (string, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, &jose.SignerOptions{
+		EmbedJWK:     embedJWK,
+		ExtraHeaders: headers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("acme: failed to create JWS signer: %w", err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("acme: failed to sign JWS: %w", err)
+	}
+	return signed.FullSerialize(), nil
+}
+
+// fetchNonce returns a new anti-replay nonce from the ACME server.
+func fetchNonce(httpClient *http.Client, newNonceURL string) (string, error) {
+	resp, err := httpClient.Head(newNonceURL)
+	if err != nil {
+		return "", fmt.Errorf("acme: failed to get a nonce: %w", err)
+	}
+	defer resp.Body.Close()
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("acme: the ACME server returned no nonce")
+	}
+	return nonce, nil
+}
+
+// postJWS posts a signed request to the ACME server and returns the problem it reports, if any.
+// It returns errKeyChangeUnconfirmed if the server sent no answer.
+func postJWS(httpClient *http.Client, url, jws string) error {
+	resp, err := httpClient.Post(url, "application/jose+json", bytes.NewBufferString(jws))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errKeyChangeUnconfirmed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	problem := &legoacme.ProblemDetails{}
+	if err := json.Unmarshal(body, problem); err != nil || problem.Type == "" {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	problem.HTTPStatus = resp.StatusCode
+	return problem
+}
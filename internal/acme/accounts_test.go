@@ -0,0 +1,143 @@
+package acme_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestAccountLifecycleWithPebble(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	database := tu.MustPrepareEmptyDB(t)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+	id, err := database.CreateACMEServer("pebble", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	signCSR := func() *acme.Certificate {
+		t.Helper()
+		server, err := database.GetDecryptedACMEServer(id)
+		if err != nil {
+			t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+		}
+		issued, err := acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+		if err != nil {
+			t.Fatalf("SignCSR() unexpected error: %s", err)
+		}
+		return issued
+	}
+	issued := signCSR()
+	account, err := database.GetDecryptedACMEAccount(issued.AccountID)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEAccount() unexpected error: %s", err)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		queried, err := acme.QueryAccount(account)
+		if err != nil {
+			t.Fatalf("QueryAccount() unexpected error: %s", err)
+		}
+		if queried.Status != db.ACMEAccountStatusValid {
+			t.Fatalf("expected a valid account, got %q", queried.Status)
+		}
+		if contacts := acme.AccountContacts(queried); len(contacts) != 1 || contacts[0] != "admin@example.com" {
+			t.Fatalf("expected the email of the server as contact, got %v", contacts)
+		}
+	})
+
+	t.Run("update contacts", func(t *testing.T) {
+		updated, err := acme.UpdateAccountContacts(account, []string{"ops@example.com", "security@example.com"})
+		if err != nil {
+			t.Fatalf("UpdateAccountContacts() unexpected error: %s", err)
+		}
+		contacts := acme.AccountContacts(updated)
+		if len(contacts) != 2 || contacts[0] != "ops@example.com" || contacts[1] != "security@example.com" {
+			t.Fatalf("expected the updated contacts, got %v", contacts)
+		}
+	})
+
+	t.Run("roll over key", func(t *testing.T) {
+		rolled, err := acme.RollOverAccountKey(database, account.ID)
+		if err != nil {
+			t.Fatalf("RollOverAccountKey() unexpected error: %s", err)
+		}
+		if rolled.PrivateKeyPEM == account.PrivateKeyPEM {
+			t.Fatal("expected a new account key")
+		}
+		if _, err := acme.QueryAccount(account); err == nil {
+			t.Fatal("expected the ACME server to reject the old account key")
+		}
+		stored, err := database.GetDecryptedACMEAccount(account.ID)
+		if err != nil {
+			t.Fatalf("GetDecryptedACMEAccount() unexpected error: %s", err)
+		}
+		if stored.PrivateKeyPEM != rolled.PrivateKeyPEM || stored.PendingPrivateKeyPEM != "" {
+			t.Fatal("expected the new key to replace the stored key")
+		}
+		if again := signCSR(); again.AccountID != account.ID {
+			t.Fatalf("expected the server to keep ordering with account %d, got %d", account.ID, again.AccountID)
+		}
+		account = rolled
+	})
+
+	t.Run("roll over key refused by the ACME server", func(t *testing.T) {
+		if err := database.UpdateACMEAccount(account.ID, account.PrivateKeyPEM, account.RegistrationURI+"-unknown", account.RegistrationBody); err != nil {
+			t.Fatalf("UpdateACMEAccount() unexpected error: %s", err)
+		}
+		if _, err := acme.RollOverAccountKey(database, account.ID); err == nil {
+			t.Fatal("expected the ACME server to refuse the key change of an unknown account")
+		}
+		stored, err := database.GetDecryptedACMEAccount(account.ID)
+		if err != nil {
+			t.Fatalf("GetDecryptedACMEAccount() unexpected error: %s", err)
+		}
+		if stored.PrivateKeyPEM != account.PrivateKeyPEM || stored.PendingPrivateKeyPEM != "" {
+			t.Fatal("expected the account to keep its key and no pending key")
+		}
+		if err := database.UpdateACMEAccount(account.ID, account.PrivateKeyPEM, account.RegistrationURI, account.RegistrationBody); err != nil {
+			t.Fatalf("UpdateACMEAccount() unexpected error: %s", err)
+		}
+	})
+
+	t.Run("roll over key after an interrupted rollover", func(t *testing.T) {
+		// A pending key that the ACME server never accepted is left by a rollover that stopped before its request.
+		if err := database.SetACMEAccountPendingKey(account.ID, tu.MustGenerateACMEAccountKey(t)); err != nil {
+			t.Fatalf("SetACMEAccountPendingKey() unexpected error: %s", err)
+		}
+		rolled, err := acme.RollOverAccountKey(database, account.ID)
+		if err != nil {
+			t.Fatalf("RollOverAccountKey() unexpected error: %s", err)
+		}
+		if _, err := acme.QueryAccount(rolled); err != nil {
+			t.Fatalf("expected the ACME server to accept the new account key, got %s", err)
+		}
+		stored, err := database.GetDecryptedACMEAccount(account.ID)
+		if err != nil {
+			t.Fatalf("GetDecryptedACMEAccount() unexpected error: %s", err)
+		}
+		if stored.PrivateKeyPEM != rolled.PrivateKeyPEM || stored.PendingPrivateKeyPEM != "" {
+			t.Fatal("expected the new key to replace the stored key")
+		}
+		account = rolled
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		deactivated, err := acme.DeactivateAccount(account)
+		if err != nil {
+			t.Fatalf("DeactivateAccount() unexpected error: %s", err)
+		}
+		if deactivated.Status != db.ACMEAccountStatusDeactivated {
+			t.Fatalf("expected a deactivated account, got %q", deactivated.Status)
+		}
+		if err := database.UpdateACMEAccountRegistration(deactivated.ID, deactivated.RegistrationBody, deactivated.Status); err != nil {
+			t.Fatalf("UpdateACMEAccountRegistration() unexpected error: %s", err)
+		}
+		if again := signCSR(); again.AccountID == account.ID {
+			t.Fatal("expected the server to register a new account in place of the deactivated one")
+		}
+	})
+}
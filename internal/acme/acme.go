@@ -1,30 +1,43 @@
 package acme
 
 import (
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"os"
-	"strings"
+	"io"
+	"net/http"
 	"sync"
 
 	"github.com/canonical/notary/internal/db"
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	legoconfig "github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/providers/dns"
 	"github.com/go-acme/lego/v4/registration"
 )
 
-var signingMu sync.Mutex
+// accountLocks serializes the lookup, registration and key rollover of an ACME account, keyed by email and
+// directory URL, so that concurrent orders don't register the same account twice and don't load a key that
+// a rollover is replacing. Orders for different accounts run in parallel.
+var accountLocks sync.Map
+
+// lockAccount locks the account identified by email and directory URL, and returns the function that unlocks it.
+func lockAccount(email, directoryURL string) func() {
+	mu, _ := accountLocks.LoadOrStore(email+"\x00"+directoryURL, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
 
 type acmeUser struct {
+	// id is the ID of the account in the database.
+	id           int64
 	email        string
 	registration *registration.Resource
 	key          crypto.PrivateKey
@@ -37,51 +50,81 @@ func (u *acmeUser) GetPrivateKey() crypto.PrivateKey        { return u.key }
 // ACMERepository holds everything needed to obtain a certificate from an ACME
 // server for a single signing operation.
 type ACMERepository struct {
-	serverID     int64
+	serverID int64
+	// accountID is the ID of the account linked to the server, 0 if none is.
+	accountID    int64
 	email        string
 	directoryURL string
 	dnsProvider  string
 	envVars      map[string]string
+	challenge    db.ACMEChallenge
+	eab          db.ACMEExternalAccountBinding
+	order        db.ACMEOrderOptions
 	db           *db.DatabaseRepository
 }
 
-func NewACMERepository(serverID int64, email, directoryURL, dnsProvider string, envVars map[string]string, database *db.DatabaseRepository) *ACMERepository {
+// NewACMERepository creates an ACMERepository for the given ACME server.
+// envVars are the decrypted variables of the server's DNS provider, and the server itself must be
+// decrypted for its External Account Binding to be used.
+func NewACMERepository(server *db.ACMEServer, envVars map[string]string, database *db.DatabaseRepository) *ACMERepository {
+	var accountID int64
+	if server.ACMEAccountID != nil {
+		accountID = *server.ACMEAccountID
+	}
 	return &ACMERepository{
-		serverID:     serverID,
-		email:        email,
-		directoryURL: directoryURL,
-		dnsProvider:  dnsProvider,
+		serverID:     server.ID,
+		accountID:    accountID,
+		email:        server.Email,
+		directoryURL: server.DirectoryURL,
+		dnsProvider:  server.DNSProvider,
 		envVars:      envVars,
+		challenge:    server.Challenge(),
+		eab:          server.ExternalAccountBinding(),
+		order:        server.OrderOptions(),
 		db:           database,
 	}
 }
 
+// ServerID returns the ID of the ACME server that the repository orders certificates from.
+func (r *ACMERepository) ServerID() int64 {
+	return r.serverID
+}
+
 // loadOrCreateAccount returns an acmeUser backed by a DB-persisted account.
-// Must be called with signingMu held.
+// The account linked to the server is used if there is one. Otherwise, the valid account registered with
+// the email of the server on its directory URL is used and linked to the server, and registered if needed.
 func (r *ACMERepository) loadOrCreateAccount() (*acmeUser, error) {
+	if r.accountID != 0 {
+		account, err := r.db.GetDecryptedACMEAccount(r.accountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ACME account %d: %w", r.accountID, err)
+		}
+		// The account is loaded again once no rollover replaces its key.
+		unlock := lockAccount(account.Email, account.DirectoryURL)
+		defer unlock()
+		account, err = r.db.GetDecryptedACMEAccount(r.accountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ACME account %d: %w", r.accountID, err)
+		}
+		if account.Status != db.ACMEAccountStatusValid {
+			return nil, fmt.Errorf("ACME account %d is %s", account.ID, account.Status)
+		}
+		return accountUser(account)
+	}
+
+	unlock := lockAccount(r.email, r.directoryURL)
+	defer unlock()
+
 	account, err := r.db.GetACMEAccountByEmailAndURL(r.email, r.directoryURL)
 	if err != nil && !errors.Is(err, db.ErrNotFound) {
 		return nil, fmt.Errorf("failed to look up ACME account: %w", err)
 	}
 
 	if err == nil {
-		block, _ := pem.Decode([]byte(account.PrivateKeyPEM))
-		if block == nil {
-			return nil, errors.New("failed to decode ACME account private key PEM")
-		}
-		privKey, err := x509.ParseECPrivateKey(block.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse ACME account private key: %w", err)
-		}
-		var reg registration.Resource
-		if err := json.Unmarshal([]byte(account.RegistrationBody), &reg); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal ACME registration: %w", err)
+		if err := r.linkAccount(account.ID); err != nil {
+			return nil, err
 		}
-		return &acmeUser{
-			email:        account.Email,
-			registration: &reg,
-			key:          privKey,
-		}, nil
+		return accountUser(account)
 	}
 
 	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
@@ -100,7 +143,7 @@ func (r *ACMERepository) loadOrCreateAccount() (*acmeUser, error) {
 		return nil, fmt.Errorf("failed to create ACME client: %w", err)
 	}
 
-	reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	reg, err := r.register(client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register ACME account: %w", err)
 	}
@@ -121,82 +164,240 @@ func (r *ACMERepository) loadOrCreateAccount() (*acmeUser, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to store ACME account: %w", err)
 	}
+	user.id = newAccount.ID
 
-	if r.serverID > 0 {
-		if err := r.db.LinkAccountToServer(r.serverID, newAccount.ID); err != nil {
-			return nil, fmt.Errorf("failed to link ACME account to server: %w", err)
-		}
+	if err := r.linkAccount(newAccount.ID); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-// SignCSR obtains a signed certificate via ACME DNS-01 challenge.
-// Env vars are injected into the process environment under signingMu.
-func (r *ACMERepository) SignCSR(csrPEM string) (string, error) {
-	signingMu.Lock()
-
-	saved := make(map[string]*string, len(r.envVars))
-	for k, v := range r.envVars {
-		if prev, ok := os.LookupEnv(k); ok {
-			saved[k] = &prev
-		} else {
-			saved[k] = nil
-		}
-		if err := os.Setenv(k, v); err != nil {
-			signingMu.Unlock()
-			return "", fmt.Errorf("acme: invalid env var key %q: %w", k, err)
-		}
+// linkAccount links the account to the ACME server, so that the server keeps using it.
+func (r *ACMERepository) linkAccount(accountID int64) error {
+	if r.serverID == 0 {
+		return nil
 	}
-	defer func() {
-		for k, prev := range saved {
-			if prev != nil {
-				os.Setenv(k, *prev) //nolint:errcheck
-			} else {
-				os.Unsetenv(k) //nolint:errcheck
-			}
-		}
-		signingMu.Unlock()
-	}()
+	if err := r.db.LinkAccountToServer(r.serverID, accountID); err != nil {
+		return fmt.Errorf("failed to link ACME account to server: %w", err)
+	}
+	return nil
+}
 
-	user, err := r.loadOrCreateAccount()
+// accountUser returns the acmeUser of an ACME account whose private key is decrypted.
+func accountUser(account *db.ACMEAccount) (*acmeUser, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKeyPEM))
+	if block == nil {
+		return nil, errors.New("failed to decode ACME account private key PEM")
+	}
+	privKey, err := x509.ParseECPrivateKey(block.Bytes)
 	if err != nil {
-		return "", fmt.Errorf("acme: failed to initialize account: %w", err)
+		return nil, fmt.Errorf("failed to parse ACME account private key: %w", err)
 	}
+	var reg registration.Resource
+	if err := json.Unmarshal([]byte(account.RegistrationBody), &reg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ACME registration: %w", err)
+	}
+	return &acmeUser{
+		id:           account.ID,
+		email:        account.Email,
+		registration: &reg,
+		key:          privKey,
+	}, nil
+}
 
-	cfg := legoconfig.NewConfig(user)
-	cfg.CADirURL = r.directoryURL
-	cfg.Certificate.KeyType = certcrypto.EC256
+// register registers a new account, bound to the external account when the ACME server requires it.
+func (r *ACMERepository) register(client *legoconfig.Client) (*registration.Resource, error) {
+	if !r.eab.IsSet() {
+		return client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	}
+	return client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+		TermsOfServiceAgreed: true,
+		Kid:                  r.eab.KeyID,
+		HmacEncoded:          r.eab.HMACKey,
+	})
+}
 
-	client, err := legoconfig.NewClient(cfg)
+// ValidateExternalAccountBinding checks that the External Account Binding credentials are complete
+// and that the HMAC key is base64url encoded. The returned errors are meant to be shown to the user.
+func ValidateExternalAccountBinding(eab db.ACMEExternalAccountBinding) error {
+	if eab.KeyID == "" && eab.HMACKey == "" {
+		return nil
+	}
+	if eab.KeyID == "" || eab.HMACKey == "" {
+		return errors.New("eab_key_id and eab_hmac_key must be set together")
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(eab.HMACKey); err == nil {
+		return nil
+	}
+	if _, err := base64.URLEncoding.DecodeString(eab.HMACKey); err != nil {
+		return errors.New("eab_hmac_key must be base64url encoded")
+	}
+	return nil
+}
+
+// Certificate is a certificate chain obtained from an ACME server.
+type Certificate struct {
+	Chain string
+	// ServerID and AccountID identify the ACME server and account that ordered the certificate.
+	// The account is needed to revoke the certificate.
+	ServerID  int64
+	AccountID int64
+	// Order holds the options the certificate was ordered with, and ChainIssuer the common name of the issuer
+	// at the top of the chain, which differs from the preferred chain when the ACME server doesn't offer it.
+	Order       db.ACMEOrderOptions
+	ChainIssuer string
+}
+
+// SignCSR obtains a signed certificate via the challenge type configured on the ACME server.
+// The DNS provider is built from the variables configured on the ACME server,
+// so orders against different servers can run concurrently. Orders that use the same
+// built-in responder port run one at a time. The options that are set in order override
+// the defaults of the ACME server. Cancelling ctx aborts the requests of the order to the ACME server.
+func (r *ACMERepository) SignCSR(ctx context.Context, csrPEM string, order db.ACMEOrderOptions) (*Certificate, error) {
+	return r.obtain(ctx, csrPEM, "", order)
+}
+
+// RenewCSR obtains a new certificate for a CSR whose current certificate chain is certPEM.
+// When the ACME server supports ACME Renewal Information (RFC 9773), the order is marked
+// as replacing the current certificate. Cancelling ctx aborts the requests of the order to the ACME server.
+func (r *ACMERepository) RenewCSR(ctx context.Context, csrPEM, certPEM string, order db.ACMEOrderOptions) (*Certificate, error) {
+	return r.obtain(ctx, csrPEM, certPEM, order)
+}
+
+// obtain places an order for the CSR. replacedPEM is the certificate chain that the order replaces, if any.
+func (r *ACMERepository) obtain(ctx context.Context, csrPEM, replacedPEM string, override db.ACMEOrderOptions) (*Certificate, error) {
+	order := r.orderOptions(override)
+	if err := ValidateOrderOptions(order); err != nil {
+		return nil, fmt.Errorf("acme: %w", err)
+	}
+
+	solver, err := r.newChallengeSolver()
 	if err != nil {
-		return "", fmt.Errorf("acme: failed to create ACME client: %w", err)
+		return nil, fmt.Errorf("acme: failed to configure %s challenge: %w", r.challenge.Type, err)
 	}
 
-	provider, err := dns.NewDNSChallengeProviderByName(strings.ToLower(r.dnsProvider))
+	client, user, err := r.newClient(ctx)
 	if err != nil {
-		return "", fmt.Errorf("acme: unknown DNS provider %q: %w", r.dnsProvider, err)
+		return nil, err
 	}
-	if err := client.Challenge.SetDNS01Provider(provider); err != nil {
-		return "", fmt.Errorf("acme: failed to set DNS-01 provider: %w", err)
+
+	if err := solver.apply(client); err != nil {
+		return nil, fmt.Errorf("acme: %w", err)
 	}
 
 	block, _ := pem.Decode([]byte(csrPEM))
 	if block == nil {
-		return "", errors.New("acme: failed to decode CSR PEM")
+		return nil, errors.New("acme: failed to decode CSR PEM")
 	}
 	x509CSR, err := x509.ParseCertificateRequest(block.Bytes)
 	if err != nil {
-		return "", fmt.Errorf("acme: failed to parse CSR: %w", err)
+		return nil, fmt.Errorf("acme: failed to parse CSR: %w", err)
 	}
 
+	var replacesCertID string
+	if replacedPEM != "" {
+		// Renewal information is a hint, the certificate is renewed without it if the server doesn't support it.
+		if leaf, err := parseLeafCertificate(replacedPEM); err == nil {
+			if _, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: leaf}); err == nil {
+				replacesCertID, _ = certificate.MakeARICertID(leaf)
+			}
+		}
+	}
+
+	unlock := solver.lock()
+	defer unlock()
 	resource, err := client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
-		CSR:    x509CSR,
-		Bundle: true,
+		CSR:            x509CSR,
+		Bundle:         true,
+		PreferredChain: order.PreferredChain,
+		Profile:        order.Profile,
+		ReplacesCertID: replacesCertID,
 	})
 	if err != nil {
-		return "", fmt.Errorf("acme: certificate issuance failed: %w", err)
+		return nil, fmt.Errorf("acme: certificate issuance failed: %w", err)
+	}
+
+	chain := string(resource.Certificate)
+	return &Certificate{
+		Chain:       chain,
+		ServerID:    r.serverID,
+		AccountID:   user.id,
+		Order:       order,
+		ChainIssuer: chainIssuer(chain),
+	}, nil
+}
+
+// newClient creates an ACME client for the account of the ACME server, registering the account if needed.
+// The requests of the client to the ACME server fail once ctx is cancelled.
+func (r *ACMERepository) newClient(ctx context.Context) (*legoconfig.Client, *acmeUser, error) {
+	user, err := r.loadOrCreateAccount()
+	if err != nil {
+		return nil, nil, fmt.Errorf("acme: failed to initialize account: %w", err)
+	}
+	client, err := newAccountClient(ctx, user, r.directoryURL)
+	if err != nil {
+		return nil, nil, err
 	}
+	return client, user, nil
+}
+
+// newAccountClient creates an ACME client for an account that is already registered with the ACME server.
+// The requests of the client to the ACME server fail once ctx is cancelled.
+func newAccountClient(ctx context.Context, user *acmeUser, directoryURL string) (*legoconfig.Client, error) {
+	cfg := legoconfig.NewConfig(user)
+	cfg.CADirURL = directoryURL
+	httpClient := *cfg.HTTPClient
+	httpClient.Transport = &contextTransport{ctx: ctx, base: httpClient.Transport}
+	cfg.HTTPClient = &httpClient
+	cfg.Certificate.KeyType = certcrypto.EC256
+
+	client, err := legoconfig.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to create ACME client: %w", err)
+	}
+	return client, nil
+}
+
+// contextTransport cancels the requests it sends once ctx is cancelled, including the request that is in flight.
+// lego doesn't take a context, so this is how an order is interrupted between, and during, its requests.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := context.Cause(t.ctx); err != nil {
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx, cancel := context.WithCancelCause(req.Context())
+	stop := context.AfterFunc(t.ctx, func() { cancel(context.Cause(t.ctx)) })
+	release := func() {
+		stop()
+		cancel(nil)
+	}
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The body is read after RoundTrip returns, so the request is released once it is closed.
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
 
-	return string(resource.Certificate), nil
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
 }
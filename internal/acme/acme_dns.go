@@ -0,0 +1,62 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// BuiltinDNSProvider is the DNS provider that solves DNS-01 challenges with the acme-dns compatible service
+// of Notary. It needs no variables: the challenges of a domain are written to the record of the acme-dns
+// registration of that domain, which _acme-challenge.<domain> must be a CNAME to.
+const BuiltinDNSProvider = "notary"
+
+// IsBuiltinDNSProvider reports whether name is the DNS provider of the acme-dns compatible service of Notary.
+func IsBuiltinDNSProvider(name string) bool {
+	return strings.EqualFold(name, BuiltinDNSProvider)
+}
+
+// newDNSProvider builds the DNS-01 provider of the ACME server.
+func (r *ACMERepository) newDNSProvider() (challenge.Provider, error) {
+	if IsBuiltinDNSProvider(r.dnsProvider) {
+		return &builtinDNSProvider{db: r.db}, nil
+	}
+	return newDNSProvider(r.dnsProvider, r.envVars)
+}
+
+// builtinDNSProvider writes the challenges to the records served by the acme-dns compatible service.
+type builtinDNSProvider struct {
+	db *db.DatabaseRepository
+}
+
+func (p *builtinDNSProvider) Present(domain, token, keyAuth string) error {
+	registration, err := p.registration(domain)
+	if err != nil {
+		return err
+	}
+	return p.db.SetACMEDNSRecord(registration.ID, dns01.GetChallengeInfo(domain, keyAuth).Value)
+}
+
+func (p *builtinDNSProvider) CleanUp(domain, token, keyAuth string) error {
+	registration, err := p.registration(domain)
+	if err != nil {
+		return err
+	}
+	return p.db.RemoveACMEDNSRecord(registration.ID, dns01.GetChallengeInfo(domain, keyAuth).Value)
+}
+
+// registration returns the acme-dns registration that the challenges of domain are delegated to.
+func (p *builtinDNSProvider) registration(domain string) (*db.ACMEDNSRegistration, error) {
+	registration, err := p.db.GetACMEDNSRegistrationByDomain(normalizeDNSName(domain))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, fmt.Errorf("no acme-dns registration for %s", domain)
+		}
+		return nil, fmt.Errorf("failed to get the acme-dns registration for %s: %w", domain, err)
+	}
+	return registration, nil
+}
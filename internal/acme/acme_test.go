@@ -0,0 +1,75 @@
+package acme_test
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestValidateExternalAccountBinding(t *testing.T) {
+	hmacKey := base64.RawURLEncoding.EncodeToString([]byte("zWNDZM6eQGHWpSRTPal5eIUYFTu7EajVIoguysqZ9wG44nMEtx3MUAsUDkMTQ12W"))
+	cases := []struct {
+		desc    string
+		eab     db.ACMEExternalAccountBinding
+		wantErr string
+	}{
+		{"not set", db.ACMEExternalAccountBinding{}, ""},
+		{"raw base64url key", db.ACMEExternalAccountBinding{KeyID: "kid-1", HMACKey: hmacKey}, ""},
+		{"padded base64url key", db.ACMEExternalAccountBinding{KeyID: "kid-1", HMACKey: base64.URLEncoding.EncodeToString([]byte("key"))}, ""},
+		{"missing HMAC key", db.ACMEExternalAccountBinding{KeyID: "kid-1"}, "must be set together"},
+		{"missing key ID", db.ACMEExternalAccountBinding{HMACKey: hmacKey}, "must be set together"},
+		{"invalid HMAC key", db.ACMEExternalAccountBinding{KeyID: "kid-1", HMACKey: "not base64!"}, "must be base64url encoded"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := acme.ValidateExternalAccountBinding(tc.eab)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateExternalAccountBinding() unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("ValidateExternalAccountBinding() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignCSRWithExternalAccountBinding(t *testing.T) {
+	hmacKey := base64.RawURLEncoding.EncodeToString([]byte("zWNDZM6eQGHWpSRTPal5eIUYFTu7EajVIoguysqZ9wG44nMEtx3MUAsUDkMTQ12W"))
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebbleWithEAB(t, httpPort, tlsPort, map[string]string{"kid-1": hmacKey})
+	database := tu.MustPrepareEmptyDB(t)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+
+	withoutEAB, err := database.CreateACMEServer("no-eab", directoryURL, "a@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(withoutEAB)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	_, err = acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+	if err == nil || !strings.Contains(err.Error(), "failed to register ACME account") {
+		t.Fatalf("expected registration without External Account Binding to fail, got %v", err)
+	}
+
+	withEAB, err := database.CreateACMEServer("eab", directoryURL, "b@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{KeyID: "kid-1", HMACKey: hmacKey}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err = database.GetDecryptedACMEServer(withEAB)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	if _, err := acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{}); err != nil {
+		t.Fatalf("SignCSR() unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,184 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+	legoconfig "github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/http/webroot"
+)
+
+const (
+	defaultHTTP01Port    = 80
+	defaultTLSALPN01Port = 443
+)
+
+// SupportedChallengeTypes returns the challenge types an ACME server can be configured with.
+func SupportedChallengeTypes() []string {
+	return []string{db.ACMEChallengeDNS01, db.ACMEChallengeHTTP01, db.ACMEChallengeTLSALPN01}
+}
+
+// ValidateWebroot checks that the webroot of an ACME server is within directory, the directory that the configuration
+// of Notary allows webroots in. Webroots are rejected when directory is empty.
+// The returned errors are meant to be shown to the user.
+func ValidateWebroot(webroot, directory string) error {
+	if webroot == "" {
+		return nil
+	}
+	if directory == "" {
+		return errors.New("webroot can't be used, it requires the acme webroot_directory to be configured")
+	}
+	rel, err := filepath.Rel(directory, filepath.Clean(webroot))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("webroot must be within %s", directory)
+	}
+	return nil
+}
+
+// NormalizeChallenge validates the challenge settings of an ACME server and fills in the defaults:
+// DNS-01 when no type is given, and the standard port when a built-in responder has no port.
+// The returned errors are meant to be shown to the user.
+func NormalizeChallenge(challenge db.ACMEChallenge, dnsProvider string) (db.ACMEChallenge, error) {
+	if challenge.Type == "" {
+		challenge.Type = db.ACMEChallengeDNS01
+	}
+	if challenge.Type != db.ACMEChallengeDNS01 && hasDNSSettings(challenge.DNS) {
+		return challenge, errors.New("the dns_* settings can only be used with the dns-01 challenge")
+	}
+	switch challenge.Type {
+	case db.ACMEChallengeDNS01:
+		if dnsProvider == "" {
+			return challenge, errors.New("dns_provider is required for the dns-01 challenge")
+		}
+		if !IsSupportedDNSProvider(dnsProvider) {
+			return challenge, errors.New("unsupported dns_provider, must be one of: " + strings.Join(SupportedDNSProviders(), ", "))
+		}
+		if challenge.Port != 0 || challenge.Webroot != "" {
+			return challenge, errors.New("challenge_port and webroot can't be used with the dns-01 challenge")
+		}
+		dns, err := normalizeDNSSettings(challenge.DNS)
+		if err != nil {
+			return challenge, err
+		}
+		challenge.DNS = dns
+		return challenge, nil
+	case db.ACMEChallengeHTTP01:
+		if challenge.Webroot != "" {
+			if !filepath.IsAbs(challenge.Webroot) {
+				return challenge, errors.New("webroot must be an absolute path")
+			}
+			if challenge.Port != 0 {
+				return challenge, errors.New("challenge_port can't be used with webroot")
+			}
+			return challenge, nil
+		}
+		if challenge.Port == 0 {
+			challenge.Port = defaultHTTP01Port
+		}
+	case db.ACMEChallengeTLSALPN01:
+		if challenge.Webroot != "" {
+			return challenge, errors.New("webroot can only be used with the http-01 challenge")
+		}
+		if challenge.Port == 0 {
+			challenge.Port = defaultTLSALPN01Port
+		}
+	default:
+		return challenge, errors.New("unsupported challenge_type, must be one of: " + strings.Join(SupportedChallengeTypes(), ", "))
+	}
+	if challenge.Port < 1 || challenge.Port > 65535 {
+		return challenge, errors.New("challenge_port must be between 1 and 65535")
+	}
+	return challenge, nil
+}
+
+func hasDNSSettings(settings db.ACMEDNSSettings) bool {
+	return len(settings.Resolvers) > 0 || settings.PropagationTimeout != 0 || settings.PollingInterval != 0 ||
+		settings.SkipAuthoritativeCheck || len(settings.ChallengeAliases) > 0
+}
+
+// responderLocks serializes the orders that solve challenges with a built-in responder, keyed by port,
+// so that concurrent orders don't compete for the same listener.
+var responderLocks sync.Map
+
+// lockResponder locks the built-in responder listening on port, and returns the function that unlocks it.
+func lockResponder(port int64) func() {
+	mu, _ := responderLocks.LoadOrStore(port, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// challengeSolver holds the provider that solves the challenges of an order.
+type challengeSolver struct {
+	challengeType string
+	provider      challenge.Provider
+	// responderPort is the port of the built-in responder, 0 when there is none.
+	responderPort int64
+	// dnsSettings are the DNS-01 settings of the ACME server, applied to provider by wrapDNSProvider.
+	dnsSettings db.ACMEDNSSettings
+}
+
+// newChallengeSolver builds the provider for the challenge type of the ACME server.
+func (r *ACMERepository) newChallengeSolver() (*challengeSolver, error) {
+	settings, err := NormalizeChallenge(r.challenge, r.dnsProvider)
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.FormatInt(settings.Port, 10)
+	switch settings.Type {
+	case db.ACMEChallengeDNS01:
+		provider, err := r.newDNSProvider()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure DNS provider %q: %w", r.dnsProvider, err)
+		}
+		return &challengeSolver{challengeType: settings.Type, provider: wrapDNSProvider(provider, settings.DNS), dnsSettings: settings.DNS}, nil
+	case db.ACMEChallengeHTTP01:
+		if settings.Webroot != "" {
+			provider, err := webroot.NewHTTPProvider(settings.Webroot)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure webroot: %w", err)
+			}
+			return &challengeSolver{challengeType: settings.Type, provider: provider}, nil
+		}
+		return &challengeSolver{challengeType: settings.Type, provider: http01.NewProviderServer("", port), responderPort: settings.Port}, nil
+	default:
+		return &challengeSolver{challengeType: settings.Type, provider: tlsalpn01.NewProviderServer("", port), responderPort: settings.Port}, nil
+	}
+}
+
+// lock takes what solving the challenges needs for itself: the built-in responder, or the recursive
+// nameservers of the DNS-01 settings. It returns the function that releases them.
+func (s *challengeSolver) lock() func() {
+	switch {
+	case s.responderPort != 0:
+		return lockResponder(s.responderPort)
+	case s.challengeType == db.ACMEChallengeDNS01:
+		return recursiveNameservers.use(s.dnsSettings.Resolvers)
+	default:
+		return func() {}
+	}
+}
+
+// apply configures the client to solve the challenge type of the solver, and only that one.
+func (s *challengeSolver) apply(client *legoconfig.Client) error {
+	var err error
+	switch s.challengeType {
+	case db.ACMEChallengeDNS01:
+		err = client.Challenge.SetDNS01Provider(s.provider, dnsChallengeOptions(s.dnsSettings)...)
+	case db.ACMEChallengeHTTP01:
+		err = client.Challenge.SetHTTP01Provider(s.provider)
+	default:
+		err = client.Challenge.SetTLSALPN01Provider(s.provider)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set %s provider: %w", s.challengeType, err)
+	}
+	return nil
+}
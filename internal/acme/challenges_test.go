@@ -0,0 +1,188 @@
+package acme_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestNormalizeChallenge(t *testing.T) {
+	cases := []struct {
+		desc        string
+		challenge   db.ACMEChallenge
+		dnsProvider string
+		want        db.ACMEChallenge
+		wantErr     string
+	}{
+		{"defaults to dns-01", db.ACMEChallenge{}, "cloudflare", db.ACMEChallenge{Type: db.ACMEChallengeDNS01}, ""},
+		{"dns-01 without provider", db.ACMEChallenge{Type: db.ACMEChallengeDNS01}, "", db.ACMEChallenge{}, "dns_provider is required"},
+		{"dns-01 with unsupported provider", db.ACMEChallenge{Type: db.ACMEChallengeDNS01}, "not-a-provider", db.ACMEChallenge{}, "unsupported dns_provider"},
+		{"dns-01 with port", db.ACMEChallenge{Type: db.ACMEChallengeDNS01, Port: 8080}, "cloudflare", db.ACMEChallenge{}, "can't be used with the dns-01 challenge"},
+		{"http-01 default port", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01}, "", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: 80}, ""},
+		{"http-01 custom port", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: 8080}, "", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: 8080}, ""},
+		{"http-01 webroot", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: "/var/www"}, "", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: "/var/www"}, ""},
+		{"http-01 relative webroot", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: "www"}, "", db.ACMEChallenge{}, "webroot must be an absolute path"},
+		{"http-01 webroot and port", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: "/var/www", Port: 80}, "", db.ACMEChallenge{}, "challenge_port can't be used with webroot"},
+		{"http-01 port out of range", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: 70000}, "", db.ACMEChallenge{}, "challenge_port must be between 1 and 65535"},
+		{"tls-alpn-01 default port", db.ACMEChallenge{Type: db.ACMEChallengeTLSALPN01}, "", db.ACMEChallenge{Type: db.ACMEChallengeTLSALPN01, Port: 443}, ""},
+		{"tls-alpn-01 webroot", db.ACMEChallenge{Type: db.ACMEChallengeTLSALPN01, Webroot: "/var/www"}, "", db.ACMEChallenge{}, "webroot can only be used with the http-01 challenge"},
+		{"unsupported type", db.ACMEChallenge{Type: "email-reply-00"}, "", db.ACMEChallenge{}, "unsupported challenge_type"},
+		{
+			"dns-01 settings",
+			db.ACMEChallenge{DNS: db.ACMEDNSSettings{
+				Resolvers:              []string{"10.0.0.53", "[2001:db8::53]:5353", "ns.internal.example.com"},
+				PropagationTimeout:     600,
+				PollingInterval:        10,
+				SkipAuthoritativeCheck: true,
+				ChallengeAliases:       map[string]string{"Example.COM.": "acme.example.net"},
+			}},
+			"cloudflare",
+			db.ACMEChallenge{Type: db.ACMEChallengeDNS01, DNS: db.ACMEDNSSettings{
+				Resolvers:              []string{"10.0.0.53:53", "[2001:db8::53]:5353", "ns.internal.example.com:53"},
+				PropagationTimeout:     600,
+				PollingInterval:        10,
+				SkipAuthoritativeCheck: true,
+				ChallengeAliases:       map[string]string{"example.com": "acme.example.net"},
+			}},
+			"",
+		},
+		{"dns-01 invalid resolver", db.ACMEChallenge{DNS: db.ACMEDNSSettings{Resolvers: []string{"10.0.0.53:99999"}}}, "cloudflare", db.ACMEChallenge{}, "invalid port in dns_resolvers"},
+		{"dns-01 resolver URL", db.ACMEChallenge{DNS: db.ACMEDNSSettings{Resolvers: []string{"https://dns.example.com/dns-query"}}}, "cloudflare", db.ACMEChallenge{}, "dns_resolvers entry"},
+		{"dns-01 negative timeout", db.ACMEChallenge{DNS: db.ACMEDNSSettings{PropagationTimeout: -1}}, "cloudflare", db.ACMEChallenge{}, "dns_propagation_timeout must be between"},
+		{"dns-01 interval longer than timeout", db.ACMEChallenge{DNS: db.ACMEDNSSettings{PropagationTimeout: 60, PollingInterval: 120}}, "cloudflare", db.ACMEChallenge{}, "dns_polling_interval can't be longer"},
+		{"dns-01 wildcard alias", db.ACMEChallenge{DNS: db.ACMEDNSSettings{ChallengeAliases: map[string]string{"*.example.com": "acme.example.net"}}}, "cloudflare", db.ACMEChallenge{}, "dns_challenge_aliases must map domain names"},
+		{"dns-01 alias to itself", db.ACMEChallenge{DNS: db.ACMEDNSSettings{ChallengeAliases: map[string]string{"example.com": "Example.com."}}}, "cloudflare", db.ACMEChallenge{}, "maps example.com to itself"},
+		{"http-01 with dns settings", db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, DNS: db.ACMEDNSSettings{PropagationTimeout: 60}}, "", db.ACMEChallenge{}, "can only be used with the dns-01 challenge"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := acme.NormalizeChallenge(tc.challenge, tc.dnsProvider)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("NormalizeChallenge() = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeChallenge() unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("NormalizeChallenge() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebroot(t *testing.T) {
+	cases := []struct {
+		desc      string
+		webroot   string
+		directory string
+		wantErr   string
+	}{
+		{"no webroot", "", "", ""},
+		{"webroot is the directory", "/var/www", "/var/www", ""},
+		{"webroot within the directory", "/var/www/example.com/", "/var/www", ""},
+		{"webroots disabled", "/var/www", "", "requires the acme webroot_directory"},
+		{"webroot outside the directory", "/etc/cron.d", "/var/www", "webroot must be within /var/www"},
+		{"webroot with the directory as prefix", "/var/www-other", "/var/www", "webroot must be within /var/www"},
+		{"webroot escaping the directory", "/var/www/../../etc", "/var/www", "webroot must be within /var/www"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := acme.ValidateWebroot(tc.webroot, tc.directory)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateWebroot() unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("ValidateWebroot() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignCSRWithPebble(t *testing.T) {
+	cases := []struct {
+		desc      string
+		challenge func(httpPort, tlsPort int) db.ACMEChallenge
+	}{
+		{
+			"http-01 built-in responder",
+			func(httpPort, _ int) db.ACMEChallenge {
+				return db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+			},
+		},
+		{
+			"http-01 webroot",
+			func(httpPort, _ int) db.ACMEChallenge {
+				webroot := t.TempDir()
+				mustServeWebroot(t, webroot, httpPort)
+				return db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: webroot}
+			},
+		},
+		{
+			"tls-alpn-01 built-in responder",
+			func(_, tlsPort int) db.ACMEChallenge {
+				return db.ACMEChallenge{Type: db.ACMEChallengeTLSALPN01, Port: int64(tlsPort)}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+			directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+			database := tu.MustPrepareEmptyDB(t)
+
+			challenge := tc.challenge(httpPort, tlsPort)
+			id, err := database.CreateACMEServer("pebble", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+			if err != nil {
+				t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+			}
+			server, err := database.GetACMEServer(id)
+			if err != nil {
+				t.Fatalf("GetACMEServer() unexpected error: %s", err)
+			}
+
+			issued, err := acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+			if err != nil {
+				t.Fatalf("SignCSR() unexpected error: %s", err)
+			}
+			block, _ := pem.Decode([]byte(issued.Chain))
+			if block == nil {
+				t.Fatalf("expected a PEM certificate chain, got %q", issued.Chain)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("couldn't parse issued certificate: %s", err)
+			}
+			if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "localhost" {
+				t.Fatalf("expected certificate for localhost, got %v", cert.DNSNames)
+			}
+		})
+	}
+}
+
+// mustServeWebroot serves the files of webroot over HTTP on port, like a web server already running on the host would.
+func mustServeWebroot(t *testing.T, webroot string, port int) {
+	t.Helper()
+	l, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("couldn't listen on port %d: %s", port, err)
+	}
+	srv := &http.Server{Handler: http.FileServer(http.Dir(webroot))}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+}
@@ -0,0 +1,54 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextTransportCancelsRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	client := &http.Client{Transport: &contextTransport{ctx: ctx}}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the request to succeed, got %s", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("expected the body to be read, got %q, %v", body, err)
+	}
+
+	errJobCancelled := errors.New("job cancelled")
+	time.AfterFunc(100*time.Millisecond, func() { cancel(errJobCancelled) })
+	start := time.Now()
+	if _, err := client.Get(server.URL + "/slow"); !errors.Is(err, errJobCancelled) {
+		t.Fatalf("expected the request in flight to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the request to be cancelled promptly, it took %s", elapsed)
+	}
+
+	if _, err := client.Get(server.URL); !errors.Is(err, errJobCancelled) {
+		t.Fatalf("expected the requests after the cancellation to fail, got %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package acme
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/platform/config/env"
+	"github.com/go-acme/lego/v4/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v4/providers/dns/digitalocean"
+	"github.com/go-acme/lego/v4/providers/dns/dnsimple"
+	"github.com/go-acme/lego/v4/providers/dns/exec"
+	"github.com/go-acme/lego/v4/providers/dns/gandiv5"
+	"github.com/go-acme/lego/v4/providers/dns/godaddy"
+	"github.com/go-acme/lego/v4/providers/dns/hetzner"
+	"github.com/go-acme/lego/v4/providers/dns/httpreq"
+	"github.com/go-acme/lego/v4/providers/dns/linode"
+	"github.com/go-acme/lego/v4/providers/dns/ovh"
+	"github.com/go-acme/lego/v4/providers/dns/pdns"
+	"github.com/go-acme/lego/v4/providers/dns/porkbun"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/go-acme/lego/v4/providers/dns/route53"
+	"github.com/go-acme/lego/v4/providers/dns/vultr"
+)
+
+// providerEnv holds the variables configured on an ACME server for its DNS provider.
+// The variable names are the ones documented by lego for each provider,
+// but they are never read from or written to the process environment.
+type providerEnv map[string]string
+
+// dnsProviderFactory builds a DNS-01 provider from the variables configured on an ACME server.
+type dnsProviderFactory func(vars providerEnv) (challenge.Provider, error)
+
+// dnsProviderFactories lists the DNS providers that are built from the variables of an ACME server
+// without going through the process environment.
+var dnsProviderFactories = map[string]dnsProviderFactory{
+	"cloudflare":   newCloudflareProvider,
+	"digitalocean": newDigitalOceanProvider,
+	"dnsimple":     newDNSimpleProvider,
+	"exec":         newExecProvider,
+	"gandiv5":      newGandiV5Provider,
+	"godaddy":      newGoDaddyProvider,
+	"hetzner":      newHetznerProvider,
+	"httpreq":      newHTTPReqProvider,
+	"linode":       newLinodeProvider,
+	"ovh":          newOVHProvider,
+	"pdns":         newPowerDNSProvider,
+	"porkbun":      newPorkbunProvider,
+	"rfc2136":      newRFC2136Provider,
+	"route53":      newRoute53Provider,
+	"vultr":        newVultrProvider,
+}
+
+// SupportedDNSProviders returns the sorted names of the DNS providers that can be used by an ACME server.
+func SupportedDNSProviders() []string {
+	names := make([]string, 0, len(legoDNSProviders)+1)
+	names = append(names, BuiltinDNSProvider)
+	for name := range legoDNSProviders {
+		names = append(names, name)
+	}
+	for name := range dnsProviderFactories {
+		if !legoDNSProviders[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// IsSupportedDNSProvider reports whether the DNS provider can be used by an ACME server.
+func IsSupportedDNSProvider(name string) bool {
+	_, ok := dnsProviderFactories[strings.ToLower(name)]
+	return ok || legoDNSProviders[strings.ToLower(name)] || IsBuiltinDNSProvider(name)
+}
+
+// newDNSProvider builds the named DNS-01 provider from the variables configured on an ACME server.
+// The providers without a factory are built by lego from their name.
+func newDNSProvider(name string, vars map[string]string) (challenge.Provider, error) {
+	name = strings.ToLower(name)
+	if factory, ok := dnsProviderFactories[name]; ok {
+		return factory(providerEnv(vars))
+	}
+	if legoDNSProviders[name] {
+		return newLegoDNSProvider(name, providerEnv(vars))
+	}
+	return nil, fmt.Errorf("unsupported DNS provider %q, supported providers are: %s", name, strings.Join(SupportedDNSProviders(), ", "))
+}
+
+// get returns the value of the first of the variables that is set.
+func (e providerEnv) get(names ...string) string {
+	for _, name := range names {
+		if value := e[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// getURL parses the value of the variable as a URL. It returns nil if the variable is not set.
+func (e providerEnv) getURL(name string) (*url.URL, error) {
+	value := e.get(name)
+	if value == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return u, nil
+}
+
+// timing overrides the TTL, propagation timeout and polling interval of a provider configuration
+// with the variables using the given prefix, such as CLOUDFLARE_TTL. A nil ttl is left alone.
+func (e providerEnv) timing(prefix string, ttl *int, propagationTimeout, pollingInterval *time.Duration) error {
+	if value := e.get(prefix + "TTL"); value != "" && ttl != nil {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %sTTL: %w", prefix, err)
+		}
+		*ttl = n
+	}
+	if value := e.get(prefix + "PROPAGATION_TIMEOUT"); value != "" {
+		d, err := env.ParseSecond(value)
+		if err != nil {
+			return fmt.Errorf("invalid %sPROPAGATION_TIMEOUT: %w", prefix, err)
+		}
+		*propagationTimeout = d
+	}
+	if value := e.get(prefix + "POLLING_INTERVAL"); value != "" {
+		d, err := env.ParseSecond(value)
+		if err != nil {
+			return fmt.Errorf("invalid %sPOLLING_INTERVAL: %w", prefix, err)
+		}
+		*pollingInterval = d
+	}
+	return nil
+}
+
+func newCloudflareProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := cloudflare.NewDefaultConfig()
+	cfg.AuthEmail = vars.get(cloudflare.EnvEmail, "CF_API_EMAIL")
+	cfg.AuthKey = vars.get(cloudflare.EnvAPIKey, "CF_API_KEY")
+	cfg.AuthToken = vars.get(cloudflare.EnvDNSAPIToken, "CF_DNS_API_TOKEN")
+	cfg.ZoneToken = vars.get(cloudflare.EnvZoneAPIToken, "CF_ZONE_API_TOKEN")
+	if baseURL := vars.get(cloudflare.EnvBaseURL); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if err := vars.timing("CLOUDFLARE_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return cloudflare.NewDNSProviderConfig(cfg)
+}
+
+func newDigitalOceanProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := digitalocean.NewDefaultConfig()
+	cfg.AuthToken = vars.get(digitalocean.EnvAuthToken)
+	if baseURL := vars.get(digitalocean.EnvAPIUrl); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if err := vars.timing("DO_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return digitalocean.NewDNSProviderConfig(cfg)
+}
+
+func newDNSimpleProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := dnsimple.NewDefaultConfig()
+	cfg.AccessToken = vars.get(dnsimple.EnvOAuthToken)
+	if baseURL := vars.get(dnsimple.EnvBaseURL); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	if err := vars.timing("DNSIMPLE_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return dnsimple.NewDNSProviderConfig(cfg)
+}
+
+func newExecProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := exec.NewDefaultConfig()
+	cfg.Program = vars.get(exec.EnvPath)
+	cfg.Mode = vars.get(exec.EnvMode)
+	if err := vars.timing("EXEC_", nil, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return exec.NewDNSProviderConfig(cfg)
+}
+
+func newGandiV5Provider(vars providerEnv) (challenge.Provider, error) {
+	cfg := gandiv5.NewDefaultConfig()
+	cfg.APIKey = vars.get(gandiv5.EnvAPIKey)
+	cfg.PersonalAccessToken = vars.get(gandiv5.EnvPersonalAccessToken)
+	if err := vars.timing("GANDIV5_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return gandiv5.NewDNSProviderConfig(cfg)
+}
+
+func newGoDaddyProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := godaddy.NewDefaultConfig()
+	cfg.APIKey = vars.get(godaddy.EnvAPIKey)
+	cfg.APISecret = vars.get(godaddy.EnvAPISecret)
+	if err := vars.timing("GODADDY_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return godaddy.NewDNSProviderConfig(cfg)
+}
+
+func newHetznerProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := hetzner.NewDefaultConfig()
+	cfg.APIToken = vars.get(hetzner.EnvAPIToken)
+	cfg.APIKey = vars.get(hetzner.EnvAPIKey) //nolint:staticcheck
+	if err := vars.timing("HETZNER_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return hetzner.NewDNSProviderConfig(cfg)
+}
+
+func newHTTPReqProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := httpreq.NewDefaultConfig()
+	endpoint, err := vars.getURL(httpreq.EnvEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Endpoint = endpoint
+	cfg.Mode = vars.get(httpreq.EnvMode)
+	cfg.Username = vars.get(httpreq.EnvUsername)
+	cfg.Password = vars.get(httpreq.EnvPassword)
+	if err := vars.timing("HTTPREQ_", nil, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return httpreq.NewDNSProviderConfig(cfg)
+}
+
+func newLinodeProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := linode.NewDefaultConfig()
+	cfg.Token = vars.get(linode.EnvToken)
+	if err := vars.timing("LINODE_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return linode.NewDNSProviderConfig(cfg)
+}
+
+func newOVHProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := ovh.NewDefaultConfig()
+	cfg.APIEndpoint = vars.get(ovh.EnvEndpoint)
+	if cfg.APIEndpoint == "" {
+		cfg.APIEndpoint = "ovh-eu"
+	}
+	cfg.ApplicationKey = vars.get(ovh.EnvApplicationKey)
+	cfg.ApplicationSecret = vars.get(ovh.EnvApplicationSecret)
+	cfg.ConsumerKey = vars.get(ovh.EnvConsumerKey)
+	cfg.AccessToken = vars.get(ovh.EnvAccessToken)
+	clientID := vars.get(ovh.EnvClientID)
+	clientSecret := vars.get(ovh.EnvClientSecret)
+	if clientID != "" || clientSecret != "" {
+		cfg.OAuth2Config = &ovh.OAuth2Config{ClientID: clientID, ClientSecret: clientSecret}
+	}
+	if err := vars.timing("OVH_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return ovh.NewDNSProviderConfig(cfg)
+}
+
+func newPowerDNSProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := pdns.NewDefaultConfig()
+	host, err := vars.getURL(pdns.EnvAPIURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Host = host
+	cfg.APIKey = vars.get(pdns.EnvAPIKey)
+	if serverName := vars.get(pdns.EnvServerName); serverName != "" {
+		cfg.ServerName = serverName
+	}
+	if err := vars.timing("PDNS_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return pdns.NewDNSProviderConfig(cfg)
+}
+
+func newPorkbunProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := porkbun.NewDefaultConfig()
+	cfg.APIKey = vars.get(porkbun.EnvAPIKey)
+	cfg.SecretAPIKey = vars.get(porkbun.EnvSecretAPIKey)
+	if err := vars.timing("PORKBUN_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return porkbun.NewDNSProviderConfig(cfg)
+}
+
+func newRFC2136Provider(vars providerEnv) (challenge.Provider, error) {
+	cfg := rfc2136.NewDefaultConfig()
+	cfg.Nameserver = vars.get(rfc2136.EnvNameserver, "RFC2136_NAMESERVER")
+	if zones := vars.get(rfc2136.EnvZones); zones != "" {
+		cfg.Zones = strings.Split(zones, ",")
+	}
+	cfg.TSIGAlgorithm = vars.get(rfc2136.EnvTSIGAlgorithm)
+	cfg.TSIGKey = vars.get(rfc2136.EnvTSIGKey)
+	cfg.TSIGSecret = vars.get(rfc2136.EnvTSIGSecret)
+	if err := vars.timing("DNSUPDATE_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return rfc2136.NewDNSProviderConfig(cfg)
+}
+
+func newRoute53Provider(vars providerEnv) (challenge.Provider, error) {
+	cfg := route53.NewDefaultConfig()
+	cfg.AccessKeyID = vars.get(route53.EnvAccessKeyID)
+	cfg.SecretAccessKey = vars.get(route53.EnvSecretAccessKey)
+	cfg.SessionToken = vars.get("AWS_SESSION_TOKEN")
+	if region := vars.get(route53.EnvRegion); region != "" {
+		cfg.Region = region
+	}
+	if hostedZoneID := vars.get(route53.EnvHostedZoneID); hostedZoneID != "" {
+		cfg.HostedZoneID = hostedZoneID
+	}
+	if assumeRoleArn := vars.get(route53.EnvAssumeRoleArn); assumeRoleArn != "" {
+		cfg.AssumeRoleArn = assumeRoleArn
+	}
+	if externalID := vars.get(route53.EnvExternalID); externalID != "" {
+		cfg.ExternalID = externalID
+	}
+	if privateZone := vars.get(route53.EnvPrivateZone); privateZone != "" {
+		b, err := strconv.ParseBool(privateZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", route53.EnvPrivateZone, err)
+		}
+		cfg.PrivateZone = b
+	}
+	if err := vars.timing("AWS_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return route53.NewDNSProviderConfig(cfg)
+}
+
+func newVultrProvider(vars providerEnv) (challenge.Provider, error) {
+	cfg := vultr.NewDefaultConfig()
+	cfg.APIKey = vars.get(vultr.EnvAPIKey)
+	if err := vars.timing("VULTR_", &cfg.TTL, &cfg.PropagationTimeout, &cfg.PollingInterval); err != nil {
+		return nil, err
+	}
+	return vultr.NewDNSProviderConfig(cfg)
+}
@@ -0,0 +1,238 @@
+package acme
+
+import (
+	"os"
+	"sync"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/providers/dns"
+)
+
+// legoDNSProviders lists the names that lego v4.35 builds DNS-01 providers for, which ACME servers saved by earlier
+// versions may still use. The providers without a factory are built from their name by lego. The manual provider
+// is left out since it waits for an operator to press enter.
+var legoDNSProviders = map[string]bool{
+	"acme-dns":         true,
+	"acmedns":          true,
+	"active24":         true,
+	"alidns":           true,
+	"aliesa":           true,
+	"allinkl":          true,
+	"alwaysdata":       true,
+	"anexia":           true,
+	"artfiles":         true,
+	"arvancloud":       true,
+	"auroradns":        true,
+	"autodns":          true,
+	"axelname":         true,
+	"azion":            true,
+	"azure":            true,
+	"azuredns":         true,
+	"baiducloud":       true,
+	"beget":            true,
+	"binarylane":       true,
+	"bindman":          true,
+	"bluecat":          true,
+	"bluecatv2":        true,
+	"bookmyname":       true,
+	"brandit":          true,
+	"bunny":            true,
+	"checkdomain":      true,
+	"civo":             true,
+	"clouddns":         true,
+	"cloudflare":       true,
+	"cloudns":          true,
+	"cloudru":          true,
+	"cloudxns":         true,
+	"com35":            true,
+	"conoha":           true,
+	"conohav3":         true,
+	"constellix":       true,
+	"corenetworks":     true,
+	"cpanel":           true,
+	"czechia":          true,
+	"ddnss":            true,
+	"derak":            true,
+	"desec":            true,
+	"designate":        true,
+	"digitalocean":     true,
+	"directadmin":      true,
+	"dnsexit":          true,
+	"dnshomede":        true,
+	"dnsimple":         true,
+	"dnsmadeeasy":      true,
+	"dnspod":           true,
+	"dnsupdate":        true,
+	"dode":             true,
+	"domainnameshop":   true,
+	"domeneshop":       true,
+	"dreamhost":        true,
+	"duckdns":          true,
+	"dyn":              true,
+	"dyndnsfree":       true,
+	"dynu":             true,
+	"easydns":          true,
+	"edgecenter":       true,
+	"edgedns":          true,
+	"edgeone":          true,
+	"efficientip":      true,
+	"epik":             true,
+	"eurodns":          true,
+	"excedo":           true,
+	"exec":             true,
+	"exoscale":         true,
+	"f5xc":             true,
+	"fastdns":          true,
+	"freemyip":         true,
+	"gandi":            true,
+	"gandiv5":          true,
+	"gcloud":           true,
+	"gcore":            true,
+	"gigahostno":       true,
+	"glesys":           true,
+	"godaddy":          true,
+	"googledomains":    true,
+	"gravity":          true,
+	"hetzner":          true,
+	"hostingde":        true,
+	"hostinger":        true,
+	"hostingnl":        true,
+	"hosttech":         true,
+	"httpnet":          true,
+	"httpreq":          true,
+	"huaweicloud":      true,
+	"hurricane":        true,
+	"hyperone":         true,
+	"ibmcloud":         true,
+	"iij":              true,
+	"iijdpf":           true,
+	"infoblox":         true,
+	"infomaniak":       true,
+	"internetbs":       true,
+	"inwx":             true,
+	"ionos":            true,
+	"ionoscloud":       true,
+	"ipv64":            true,
+	"ispconfig":        true,
+	"ispconfigddns":    true,
+	"iwantmyname":      true,
+	"jdcloud":          true,
+	"joker":            true,
+	"keyhelp":          true,
+	"leaseweb":         true,
+	"liara":            true,
+	"lightsail":        true,
+	"limacity":         true,
+	"linode":           true,
+	"linodev4":         true,
+	"liquidweb":        true,
+	"loopia":           true,
+	"luadns":           true,
+	"mailinabox":       true,
+	"manageengine":     true,
+	"metaname":         true,
+	"metaregistrar":    true,
+	"mijnhost":         true,
+	"mittwald":         true,
+	"myaddr":           true,
+	"mydnsjp":          true,
+	"mythicbeasts":     true,
+	"namecheap":        true,
+	"namedotcom":       true,
+	"namesilo":         true,
+	"namesurfer":       true,
+	"nearlyfreespeech": true,
+	"neodigit":         true,
+	"netcup":           true,
+	"netlify":          true,
+	"netnod":           true,
+	"nicmanager":       true,
+	"nicru":            true,
+	"nifcloud":         true,
+	"njalla":           true,
+	"nodion":           true,
+	"ns1":              true,
+	"octenium":         true,
+	"onecloudru":       true,
+	"onlinenet":        true,
+	"oraclecloud":      true,
+	"otc":              true,
+	"ovh":              true,
+	"pdns":             true,
+	"plesk":            true,
+	"porkbun":          true,
+	"rackspace":        true,
+	"rainyun":          true,
+	"rcodezero":        true,
+	"regfish":          true,
+	"regru":            true,
+	"rfc2136":          true,
+	"rimuhosting":      true,
+	"route53":          true,
+	"safedns":          true,
+	"sakuracloud":      true,
+	"scaleway":         true,
+	"selectel":         true,
+	"selectelv2":       true,
+	"selfhostde":       true,
+	"servercow":        true,
+	"shellrent":        true,
+	"simply":           true,
+	"sonic":            true,
+	"spaceship":        true,
+	"stackpath":        true,
+	"syse":             true,
+	"technitium":       true,
+	"tencentcloud":     true,
+	"timewebcloud":     true,
+	"todaynic":         true,
+	"transip":          true,
+	"ucloud":           true,
+	"ultradns":         true,
+	"uniteddomains":    true,
+	"variomedia":       true,
+	"vegadns":          true,
+	"vercel":           true,
+	"versio":           true,
+	"vinyldns":         true,
+	"virtualname":      true,
+	"vkcloud":          true,
+	"volcengine":       true,
+	"vscale":           true,
+	"vultr":            true,
+	"webnames":         true,
+	"webnamesca":       true,
+	"webnamesru":       true,
+	"websupport":       true,
+	"wedos":            true,
+	"westcn":           true,
+	"yandex":           true,
+	"yandex360":        true,
+	"yandexcloud":      true,
+	"zoneedit":         true,
+	"zoneee":           true,
+	"zonomi":           true,
+}
+
+// legoEnvMu serializes the providers built by lego from the process environment.
+var legoEnvMu sync.Mutex
+
+// newLegoDNSProvider builds a DNS provider that has no factory by its lego name. lego only reads the configuration
+// of these providers from the process environment, so the variables are set while the provider is built and
+// restored right after, before any other provider is built.
+func newLegoDNSProvider(name string, vars providerEnv) (challenge.Provider, error) {
+	legoEnvMu.Lock()
+	defer legoEnvMu.Unlock()
+	for key, value := range vars {
+		previous, ok := os.LookupEnv(key)
+		if err := os.Setenv(key, value); err != nil {
+			return nil, err
+		}
+		if ok {
+			defer os.Setenv(key, previous) //nolint:errcheck
+		} else {
+			defer os.Unsetenv(key) //nolint:errcheck
+		}
+	}
+	return dns.NewDNSChallengeProviderByName(name)
+}
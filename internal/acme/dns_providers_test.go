@@ -0,0 +1,72 @@
+package acme
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+func TestNewDNSProviderDoesNotUseProcessEnvironment(t *testing.T) {
+	provider, err := newDNSProvider("Cloudflare", map[string]string{"CF_DNS_API_TOKEN": "server-token"})
+	if err != nil {
+		t.Fatalf("couldn't build DNS provider: %s", err)
+	}
+	if provider == nil {
+		t.Fatal("expected a DNS provider")
+	}
+	if _, ok := os.LookupEnv("CF_DNS_API_TOKEN"); ok {
+		t.Fatal("DNS provider credentials must not be written to the process environment")
+	}
+}
+
+func TestNewDNSProviderBuildsProvidersWithoutFactoryByName(t *testing.T) {
+	server := &db.ACMEServer{ChallengeType: db.ACMEChallengeDNS01, DNSProvider: "DuckDNS"}
+	provider, err := NewACMERepository(server, map[string]string{"DUCKDNS_TOKEN": "server-token"}, nil).newDNSProvider()
+	if err != nil {
+		t.Fatalf("couldn't build DNS provider: %s", err)
+	}
+	if provider == nil {
+		t.Fatal("expected a DNS provider")
+	}
+	if _, ok := os.LookupEnv("DUCKDNS_TOKEN"); ok {
+		t.Fatal("DNS provider credentials must not be left in the process environment")
+	}
+}
+
+func TestNewDNSProviderErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		provider string
+		vars     map[string]string
+		wantErr  string
+	}{
+		{"unsupported provider", "not-a-provider", nil, "unsupported DNS provider"},
+		{"manual provider", "manual", nil, "unsupported DNS provider"},
+		{"missing credentials without factory", "duckdns", map[string]string{}, "duckdns"},
+		{"missing credentials", "cloudflare", map[string]string{}, "cloudflare"},
+		{"invalid TTL", "cloudflare", map[string]string{"CF_DNS_API_TOKEN": "token", "CLOUDFLARE_TTL": "soon"}, "invalid CLOUDFLARE_TTL"},
+		{"invalid propagation timeout", "digitalocean", map[string]string{"DO_AUTH_TOKEN": "token", "DO_PROPAGATION_TIMEOUT": "soon"}, "invalid DO_PROPAGATION_TIMEOUT"},
+		{"invalid endpoint", "httpreq", map[string]string{"HTTPREQ_ENDPOINT": "://nope"}, "invalid HTTPREQ_ENDPOINT"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := newDNSProvider(tc.provider, tc.vars)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("newDNSProvider(%q) = %v, want error containing %q", tc.provider, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportedDNSProviders(t *testing.T) {
+	for _, name := range SupportedDNSProviders() {
+		if !IsSupportedDNSProvider(name) {
+			t.Fatalf("%s is listed but not supported", name)
+		}
+	}
+	if IsSupportedDNSProvider("not-a-provider") {
+		t.Fatal("unexpected supported provider")
+	}
+}
@@ -0,0 +1,239 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/miekg/dns"
+)
+
+const (
+	maxDNSResolvers          = 10
+	maxDNSPropagationTimeout = 2 * 60 * 60
+)
+
+// normalizeDNSSettings validates the DNS-01 settings of an ACME server: the resolvers get the default port
+// and the domains of the aliases are lowercased without a trailing dot.
+func normalizeDNSSettings(settings db.ACMEDNSSettings) (db.ACMEDNSSettings, error) {
+	if len(settings.Resolvers) > maxDNSResolvers {
+		return settings, fmt.Errorf("dns_resolvers can't have more than %d resolvers", maxDNSResolvers)
+	}
+	resolvers := make([]string, 0, len(settings.Resolvers))
+	for _, resolver := range settings.Resolvers {
+		normalized, err := normalizeDNSResolver(resolver)
+		if err != nil {
+			return settings, err
+		}
+		resolvers = append(resolvers, normalized)
+	}
+	settings.Resolvers = nil
+	if len(resolvers) > 0 {
+		settings.Resolvers = resolvers
+	}
+
+	if settings.PropagationTimeout < 0 || settings.PropagationTimeout > maxDNSPropagationTimeout {
+		return settings, fmt.Errorf("dns_propagation_timeout must be between 0 and %d seconds", maxDNSPropagationTimeout)
+	}
+	if settings.PollingInterval < 0 {
+		return settings, errors.New("dns_polling_interval can't be negative")
+	}
+	if settings.PropagationTimeout != 0 && settings.PollingInterval > settings.PropagationTimeout {
+		return settings, errors.New("dns_polling_interval can't be longer than dns_propagation_timeout")
+	}
+
+	aliases := make(map[string]string, len(settings.ChallengeAliases))
+	for domain, alias := range settings.ChallengeAliases {
+		domain, alias = normalizeDNSName(domain), normalizeDNSName(alias)
+		if !isValidDNSName(domain) || !isValidDNSName(alias) {
+			return settings, errors.New("dns_challenge_aliases must map domain names to domain names")
+		}
+		if domain == alias {
+			return settings, fmt.Errorf("dns_challenge_aliases maps %s to itself", domain)
+		}
+		if _, ok := aliases[domain]; ok {
+			return settings, fmt.Errorf("dns_challenge_aliases has %s more than once", domain)
+		}
+		aliases[domain] = alias
+	}
+	settings.ChallengeAliases = nil
+	if len(aliases) > 0 {
+		settings.ChallengeAliases = aliases
+	}
+	return settings, nil
+}
+
+// normalizeDNSResolver validates a resolver given as an IP address or a host name, with an optional port,
+// and returns it as host:port.
+func normalizeDNSResolver(resolver string) (string, error) {
+	host, port := resolver, "53"
+	if h, p, err := net.SplitHostPort(resolver); err == nil {
+		host, port = h, p
+	}
+	if _, err := netip.ParseAddr(host); err != nil && !isValidDNSName(normalizeDNSName(host)) {
+		return "", fmt.Errorf("invalid dns_resolvers entry %q, must be an IP address or a host name", resolver)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port in dns_resolvers entry %q", resolver)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+func isValidDNSName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	_, ok := dns.IsDomainName(name)
+	return ok && !strings.ContainsAny(name, "*/: ")
+}
+
+// challengeRecordDomain returns the domain whose _acme-challenge record receives the challenges of domain:
+// the alias of the longest delegated domain that matches, or domain itself when it isn't delegated.
+func challengeRecordDomain(aliases map[string]string, domain string) string {
+	name := normalizeDNSName(domain)
+	match := ""
+	for delegated := range aliases {
+		if (name == delegated || strings.HasSuffix(name, "."+delegated)) && len(delegated) > len(match) {
+			match = delegated
+		}
+	}
+	if match == "" {
+		return domain
+	}
+	return aliases[match]
+}
+
+// dnsProvider wraps the DNS provider of an ACME server to apply its DNS-01 settings:
+// the challenges of delegated domains are written for their alias, and the propagation
+// timeout and polling interval override the ones of the provider.
+type dnsProvider struct {
+	challenge.Provider
+	settings db.ACMEDNSSettings
+}
+
+// sequentialDNSProvider is a dnsProvider for the providers that can only solve one challenge at a time.
+type sequentialDNSProvider struct {
+	*dnsProvider
+	sequential interface{ Sequential() time.Duration }
+}
+
+func (p *sequentialDNSProvider) Sequential() time.Duration {
+	return p.sequential.Sequential()
+}
+
+// wrapDNSProvider returns the provider with the DNS-01 settings applied.
+func wrapDNSProvider(provider challenge.Provider, settings db.ACMEDNSSettings) challenge.Provider {
+	wrapped := &dnsProvider{Provider: provider, settings: settings}
+	if sequential, ok := provider.(interface{ Sequential() time.Duration }); ok {
+		return &sequentialDNSProvider{dnsProvider: wrapped, sequential: sequential}
+	}
+	return wrapped
+}
+
+func (p *dnsProvider) Present(domain, token, keyAuth string) error {
+	return p.Provider.Present(challengeRecordDomain(p.settings.ChallengeAliases, domain), token, keyAuth)
+}
+
+func (p *dnsProvider) CleanUp(domain, token, keyAuth string) error {
+	return p.Provider.CleanUp(challengeRecordDomain(p.settings.ChallengeAliases, domain), token, keyAuth)
+}
+
+func (p *dnsProvider) Timeout() (time.Duration, time.Duration) {
+	timeout, interval := dns01.DefaultPropagationTimeout, dns01.DefaultPollingInterval
+	if provider, ok := p.Provider.(challenge.ProviderTimeout); ok {
+		timeout, interval = provider.Timeout()
+	}
+	if p.settings.PropagationTimeout != 0 {
+		timeout = time.Duration(p.settings.PropagationTimeout) * time.Second
+	}
+	if p.settings.PollingInterval != 0 {
+		interval = time.Duration(p.settings.PollingInterval) * time.Second
+	}
+	return timeout, interval
+}
+
+// dnsChallengeOptions returns the options of the lego DNS-01 solver for the settings.
+func dnsChallengeOptions(settings db.ACMEDNSSettings) []dns01.ChallengeOption {
+	var opts []dns01.ChallengeOption
+	if settings.SkipAuthoritativeCheck {
+		opts = append(opts, dns01.DisableAuthoritativeNssPropagationRequirement())
+	}
+	if len(settings.ChallengeAliases) > 0 {
+		// The record of a delegated domain is checked where it was written, which doesn't rely on
+		// the resolvers following the CNAME of the domain.
+		opts = append(opts, dns01.WrapPreCheck(func(domain, fqdn, value string, check dns01.PreCheckFunc) (bool, error) {
+			if record := challengeRecordDomain(settings.ChallengeAliases, domain); record != domain {
+				fqdn = dns01.ToFqdn("_acme-challenge." + record)
+			}
+			return check(fqdn, value)
+		}))
+	}
+	return opts
+}
+
+// recursiveNameservers guards the recursive nameservers of lego, which are global to the process.
+// The orders that use the same nameservers run concurrently, the others wait until they are done.
+var recursiveNameservers = newNameserverGate()
+
+type nameserverGate struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// current lists the nameservers in use, empty for the ones of the system.
+	current string
+	users   int
+}
+
+func newNameserverGate() *nameserverGate {
+	g := &nameserverGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// use makes lego use the nameservers, or the ones of the system when there are none, until the returned
+// function is called.
+func (g *nameserverGate) use(nameservers []string) func() {
+	key := strings.Join(nameservers, ",")
+	g.mu.Lock()
+	for g.users > 0 && g.current != key {
+		g.cond.Wait()
+	}
+	if g.current != key {
+		if len(nameservers) == 0 {
+			nameservers = systemNameservers()
+		}
+		// The option only sets the global nameservers, the challenge isn't used.
+		_ = dns01.AddRecursiveNameservers(nameservers)(nil)
+		g.current = key
+	}
+	g.users++
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		g.users--
+		if g.users == 0 {
+			g.cond.Broadcast()
+		}
+		g.mu.Unlock()
+	}
+}
+
+// systemNameservers returns the nameservers lego uses by default: the ones of resolv.conf, or public ones.
+func systemNameservers() []string {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil || len(config.Servers) == 0 {
+		return []string{"google-public-dns-a.google.com:53", "google-public-dns-b.google.com:53"}
+	}
+	return config.Servers
+}
@@ -0,0 +1,234 @@
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	legoacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	legoconfig "github.com/go-acme/lego/v4/lego"
+)
+
+// Dry run step names, in the order the steps run.
+const (
+	DryRunStepConfiguration    = "configuration"
+	DryRunStepDirectory        = "directory"
+	DryRunStepAccount          = "account"
+	DryRunStepChallengePresent = "challenge_present"
+	DryRunStepChallengeCleanup = "challenge_cleanup"
+)
+
+// Dry run step statuses. A step is skipped when a step before it failed, or when it can't run.
+const (
+	DryRunStatusPassed  = "passed"
+	DryRunStatusFailed  = "failed"
+	DryRunStatusSkipped = "skipped"
+)
+
+// DryRunStep is the outcome of a step of a dry run. Detail is meant to be shown to the user.
+type DryRunStep struct {
+	Name     string
+	Status   string
+	Detail   string
+	Duration time.Duration
+}
+
+// DryRunReport lists the steps of a dry run in the order they ran.
+type DryRunReport struct {
+	Steps []DryRunStep
+}
+
+// Passed reports whether no step of the dry run failed.
+func (r *DryRunReport) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Status == DryRunStatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// dryRun runs the steps of a dry run one after the other, and skips the remaining steps once one fails.
+type dryRun struct {
+	report DryRunReport
+	failed bool
+}
+
+// run runs a step, unless a step before it failed. The step returns its detail, and an error when it fails.
+func (d *dryRun) run(name string, step func() (string, error)) {
+	if d.failed {
+		d.skip(name, "a previous step failed")
+		return
+	}
+	start := time.Now()
+	detail, err := step()
+	result := DryRunStep{Name: name, Status: DryRunStatusPassed, Detail: detail, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = DryRunStatusFailed
+		result.Detail = err.Error()
+		d.failed = true
+	}
+	d.report.Steps = append(d.report.Steps, result)
+}
+
+func (d *dryRun) skip(name, detail string) {
+	d.report.Steps = append(d.report.Steps, DryRunStep{Name: name, Status: DryRunStatusSkipped, Detail: detail})
+}
+
+// DryRun checks the configuration of the ACME server without ordering a certificate: it fetches the directory,
+// loads or registers the account of the server and checks that it is valid, and presents then cleans up a
+// challenge response for domain. Presenting a dns-01 challenge creates a TXT record for the domain with the DNS
+// provider, so the domain is required for that challenge type. The built-in http-01 responder is also queried
+// over the loopback interface to check that it serves the challenge response.
+func (r *ACMERepository) DryRun(domain string) *DryRunReport {
+	d := &dryRun{}
+
+	var solver *challengeSolver
+	d.run(DryRunStepConfiguration, func() (string, error) {
+		var err error
+		solver, err = r.newChallengeSolver()
+		if err != nil {
+			return "", fmt.Errorf("failed to configure the %s challenge: %w", r.challenge.Type, err)
+		}
+		return fmt.Sprintf("the %s challenge is configured", solver.challengeType), nil
+	})
+
+	d.run(DryRunStepDirectory, func() (string, error) {
+		directory, err := r.fetchDirectory()
+		if err != nil {
+			return "", err
+		}
+		if directory.Meta.ExternalAccountRequired && !r.eab.IsSet() {
+			return "", errors.New("the ACME server requires External Account Binding, but no credentials are configured")
+		}
+		if err := r.checkProfile(directory); err != nil {
+			return "", err
+		}
+		return "fetched the directory from " + r.directoryURL, nil
+	})
+
+	d.run(DryRunStepAccount, func() (string, error) {
+		client, user, err := r.newClient(context.Background())
+		if err != nil {
+			return "", err
+		}
+		reg, err := client.Registration.QueryRegistration()
+		if err != nil {
+			return "", fmt.Errorf("failed to query ACME account %d: %w", user.id, err)
+		}
+		if reg.Body.Status != legoacme.StatusValid {
+			return "", fmt.Errorf("ACME account %d is %s", user.id, reg.Body.Status)
+		}
+		return fmt.Sprintf("ACME account %d is valid", user.id), nil
+	})
+
+	if domain == "" {
+		if solver != nil && solver.challengeType == db.ACMEChallengeDNS01 {
+			d.skip(DryRunStepChallengePresent, "a domain is required to test the dns-01 challenge")
+			d.skip(DryRunStepChallengeCleanup, "a domain is required to test the dns-01 challenge")
+			return &d.report
+		}
+		domain = "localhost"
+	}
+
+	if !d.failed {
+		unlock := solver.lock()
+		defer unlock()
+	}
+	var token, keyAuth string
+	d.run(DryRunStepChallengePresent, func() (string, error) {
+		var err error
+		token, keyAuth, err = dryRunKeyAuthorization()
+		if err != nil {
+			return "", err
+		}
+		if err := solver.provider.Present(domain, token, keyAuth); err != nil {
+			return "", fmt.Errorf("failed to present the %s challenge for %s: %w", solver.challengeType, domain, err)
+		}
+		switch {
+		case solver.challengeType == db.ACMEChallengeDNS01:
+			fqdn, _ := dns01.GetRecord(challengeRecordDomain(solver.dnsSettings.ChallengeAliases, domain), keyAuth)
+			return "created the TXT record " + fqdn, nil
+		case solver.challengeType == db.ACMEChallengeHTTP01 && solver.responderPort != 0:
+			if err := checkHTTP01Responder(solver.responderPort, domain, token, keyAuth); err != nil {
+				_ = solver.provider.CleanUp(domain, token, keyAuth)
+				return "", err
+			}
+			return fmt.Sprintf("the built-in responder served the challenge response on port %d", solver.responderPort), nil
+		case solver.responderPort != 0:
+			return fmt.Sprintf("the built-in responder is listening on port %d", solver.responderPort), nil
+		default:
+			return "wrote the challenge response to the webroot", nil
+		}
+	})
+	d.run(DryRunStepChallengeCleanup, func() (string, error) {
+		if err := solver.provider.CleanUp(domain, token, keyAuth); err != nil {
+			return "", fmt.Errorf("failed to clean up the %s challenge for %s: %w", solver.challengeType, domain, err)
+		}
+		return "cleaned up the challenge response", nil
+	})
+	return &d.report
+}
+
+// fetchDirectory fetches the directory of the ACME server, through the HTTP client that orders use.
+func (r *ACMERepository) fetchDirectory() (*legoacme.Directory, error) {
+	httpClient := legoconfig.NewConfig(&acmeUser{}).HTTPClient
+	resp, err := httpClient.Get(r.directoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the directory: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the directory: unexpected status %d", resp.StatusCode)
+	}
+	var directory legoacme.Directory
+	if err := json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+		return nil, fmt.Errorf("failed to decode the directory: %w", err)
+	}
+	if directory.NewNonceURL == "" || directory.NewAccountURL == "" || directory.NewOrderURL == "" {
+		return nil, errors.New("the directory is missing the newNonce, newAccount or newOrder URL")
+	}
+	return &directory, nil
+}
+
+// dryRunKeyAuthorization returns a random token and a key authorization for it, which only need the shape of real ones.
+func dryRunKeyAuthorization() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate a challenge token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, token + ".notary-dry-run", nil
+}
+
+// checkHTTP01Responder fetches the challenge response from the built-in responder as the ACME server would.
+func checkHTTP01Responder(port int64, domain, token, keyAuth string) error {
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:"+strconv.FormatInt(port, 10)+http01.ChallengePath(token), nil)
+	if err != nil {
+		return err
+	}
+	req.Host = domain
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query the built-in responder: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return fmt.Errorf("failed to read the response of the built-in responder: %w", err)
+	}
+	if string(body) != keyAuth {
+		return errors.New("the built-in responder didn't serve the challenge response")
+	}
+	return nil
+}
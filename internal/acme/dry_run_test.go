@@ -0,0 +1,228 @@
+package acme_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestDryRunWithPebble(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+
+	cases := []struct {
+		desc        string
+		directory   string
+		dnsProvider string
+		envVars     map[string]string
+		challenge   db.ACMEChallenge
+		domain      string
+		want        map[string]string
+	}{
+		{
+			desc:      "http-01 responder",
+			directory: directoryURL,
+			challenge: db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(tu.MustGetFreePort(t))},
+			want: map[string]string{
+				acme.DryRunStepConfiguration:    acme.DryRunStatusPassed,
+				acme.DryRunStepDirectory:        acme.DryRunStatusPassed,
+				acme.DryRunStepAccount:          acme.DryRunStatusPassed,
+				acme.DryRunStepChallengePresent: acme.DryRunStatusPassed,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusPassed,
+			},
+		},
+		{
+			desc:      "http-01 webroot",
+			directory: directoryURL,
+			challenge: db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Webroot: t.TempDir()},
+			domain:    "example.com",
+			want: map[string]string{
+				acme.DryRunStepChallengePresent: acme.DryRunStatusPassed,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusPassed,
+			},
+		},
+		{
+			desc:        "dns-01",
+			directory:   directoryURL,
+			dnsProvider: "exec",
+			envVars:     map[string]string{"EXEC_PATH": "/bin/true"},
+			challenge:   db.ACMEChallenge{Type: db.ACMEChallengeDNS01},
+			domain:      "example.com",
+			want: map[string]string{
+				acme.DryRunStepChallengePresent: acme.DryRunStatusPassed,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusPassed,
+			},
+		},
+		{
+			desc:        "dns-01 without domain",
+			directory:   directoryURL,
+			dnsProvider: "exec",
+			envVars:     map[string]string{"EXEC_PATH": "/bin/true"},
+			challenge:   db.ACMEChallenge{Type: db.ACMEChallengeDNS01},
+			want: map[string]string{
+				acme.DryRunStepAccount:          acme.DryRunStatusPassed,
+				acme.DryRunStepChallengePresent: acme.DryRunStatusSkipped,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusSkipped,
+			},
+		},
+		{
+			desc:        "dns-01 presentation fails",
+			directory:   directoryURL,
+			dnsProvider: "exec",
+			envVars:     map[string]string{"EXEC_PATH": "/bin/false"},
+			challenge:   db.ACMEChallenge{Type: db.ACMEChallengeDNS01},
+			domain:      "example.com",
+			want: map[string]string{
+				acme.DryRunStepChallengePresent: acme.DryRunStatusFailed,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusSkipped,
+			},
+		},
+		{
+			desc:        "missing DNS credentials",
+			directory:   directoryURL,
+			dnsProvider: "cloudflare",
+			envVars:     map[string]string{},
+			challenge:   db.ACMEChallenge{Type: db.ACMEChallengeDNS01},
+			domain:      "example.com",
+			want: map[string]string{
+				acme.DryRunStepConfiguration:    acme.DryRunStatusFailed,
+				acme.DryRunStepDirectory:        acme.DryRunStatusSkipped,
+				acme.DryRunStepAccount:          acme.DryRunStatusSkipped,
+				acme.DryRunStepChallengePresent: acme.DryRunStatusSkipped,
+				acme.DryRunStepChallengeCleanup: acme.DryRunStatusSkipped,
+			},
+		},
+		{
+			desc:      "unreachable directory",
+			directory: "https://127.0.0.1:1/directory",
+			challenge: db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(tu.MustGetFreePort(t))},
+			want: map[string]string{
+				acme.DryRunStepConfiguration: acme.DryRunStatusPassed,
+				acme.DryRunStepDirectory:     acme.DryRunStatusFailed,
+				acme.DryRunStepAccount:       acme.DryRunStatusSkipped,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			database := tu.MustPrepareEmptyDB(t)
+			id, err := database.CreateACMEServer("test", tc.directory, "admin@example.com", tc.dnsProvider, tc.envVars, tc.challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+			if err != nil {
+				t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+			}
+			server, err := database.GetDecryptedACMEServer(id)
+			if err != nil {
+				t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+			}
+			report := acme.NewACMERepository(server, tc.envVars, database).DryRun(tc.domain)
+			if len(report.Steps) != 5 {
+				t.Fatalf("expected 5 steps, got %+v", report.Steps)
+			}
+			passed := true
+			for _, step := range report.Steps {
+				if step.Status == acme.DryRunStatusFailed {
+					passed = false
+				}
+				if want, ok := tc.want[step.Name]; ok && step.Status != want {
+					t.Fatalf("expected step %s to be %s, got %s: %s", step.Name, want, step.Status, step.Detail)
+				}
+			}
+			if report.Passed() != passed {
+				t.Fatalf("expected Passed() to be %t", passed)
+			}
+		})
+	}
+}
+
+func TestDryRunDNSChallengeAlias(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+
+	// The exec DNS provider runs the script with the action, the FQDN of the record and its value.
+	dir := t.TempDir()
+	records := filepath.Join(dir, "records")
+	script := filepath.Join(dir, "dns.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$1 $2\" >> "+records+"\n"), 0o700); err != nil {
+		t.Fatalf("failed to write DNS script: %s", err)
+	}
+	envVars := map[string]string{"EXEC_PATH": script}
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeDNS01, DNS: db.ACMEDNSSettings{
+		ChallengeAliases: map[string]string{"example.com": "acme.example.net"},
+	}}
+
+	database := tu.MustPrepareEmptyDB(t)
+	id, err := database.CreateACMEServer("test", directoryURL, "admin@example.com", "exec", envVars, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	if got := server.Challenge().DNS.ChallengeAliases["example.com"]; got != "acme.example.net" {
+		t.Fatalf("expected the alias of example.com to be stored, got %q", got)
+	}
+
+	report := acme.NewACMERepository(server, envVars, database).DryRun("www.example.com")
+	if !report.Passed() {
+		t.Fatalf("expected the dry run to pass, got %+v", report.Steps)
+	}
+	content, err := os.ReadFile(records)
+	if err != nil {
+		t.Fatalf("failed to read DNS records: %s", err)
+	}
+	want := "present _acme-challenge.acme.example.net.\ncleanup _acme-challenge.acme.example.net.\n"
+	if string(content) != want {
+		t.Fatalf("expected the records of the alias, got %q", string(content))
+	}
+	for _, step := range report.Steps {
+		if step.Name == acme.DryRunStepChallengePresent && !strings.Contains(step.Detail, "_acme-challenge.acme.example.net.") {
+			t.Fatalf("expected the present step to name the record of the alias, got %q", step.Detail)
+		}
+	}
+}
+
+func TestDryRunBuiltinDNSProvider(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+
+	database := tu.MustPrepareEmptyDB(t)
+	registrationID, err := database.CreateACMEDNSRegistration("user", "password", "1f5e1a66-8b3b-4bde-9a0e-4c3c0f1b3e29", nil, "example.com", "")
+	if err != nil {
+		t.Fatalf("CreateACMEDNSRegistration() unexpected error: %s", err)
+	}
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeDNS01}
+	id, err := database.CreateACMEServer("test", directoryURL, "admin@example.com", acme.BuiltinDNSProvider, nil, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	repo := acme.NewACMERepository(server, nil, database)
+
+	report := repo.DryRun("Example.com")
+	if !report.Passed() {
+		t.Fatalf("expected the dry run to pass, got %+v", report.Steps)
+	}
+	registration, err := database.GetACMEDNSRegistration(registrationID)
+	if err != nil {
+		t.Fatalf("GetACMEDNSRegistration() unexpected error: %s", err)
+	}
+	if registration.TXT != "" || registration.PreviousTXT != "" {
+		t.Fatalf("expected the record to be cleaned up, got %+v", registration)
+	}
+
+	report = repo.DryRun("other.example.com")
+	for _, step := range report.Steps {
+		if step.Name == acme.DryRunStepChallengePresent && (step.Status != acme.DryRunStatusFailed || !strings.Contains(step.Detail, "no acme-dns registration")) {
+			t.Fatalf("expected the present step to fail for a domain without registration, got %+v", step)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/canonical/notary/internal/db"
+	legoacme "github.com/go-acme/lego/v4/acme"
+)
+
+const (
+	maxPreferredChainLength = 256
+	maxProfileLength        = 64
+)
+
+// ValidateOrderOptions checks the preferred chain and the profile of ACME orders. Whether the ACME server offers
+// the chain or the profile is only known once an order is placed. The returned errors are meant to be shown to the user.
+func ValidateOrderOptions(order db.ACMEOrderOptions) error {
+	if len(order.PreferredChain) > maxPreferredChainLength {
+		return fmt.Errorf("preferred_chain can't be longer than %d characters", maxPreferredChainLength)
+	}
+	if strings.TrimSpace(order.PreferredChain) != order.PreferredChain || strings.ContainsFunc(order.PreferredChain, unicode.IsControl) {
+		return errors.New("preferred_chain must be the common name of an issuer")
+	}
+	if len(order.Profile) > maxProfileLength {
+		return fmt.Errorf("profile can't be longer than %d characters", maxProfileLength)
+	}
+	if strings.ContainsFunc(order.Profile, func(r rune) bool { return r > unicode.MaxASCII || !unicode.IsGraphic(r) || r == ' ' }) {
+		return errors.New("profile must be a name without spaces")
+	}
+	return nil
+}
+
+// orderOptions returns the options of an order: the ones that are set in override, the defaults of the ACME server otherwise.
+func (r *ACMERepository) orderOptions(override db.ACMEOrderOptions) db.ACMEOrderOptions {
+	order := r.order
+	if override.PreferredChain != "" {
+		order.PreferredChain = override.PreferredChain
+	}
+	if override.Profile != "" {
+		order.Profile = override.Profile
+	}
+	return order
+}
+
+// checkProfile checks that the directory of the ACME server offers the profile of the orders of the server.
+func (r *ACMERepository) checkProfile(directory *legoacme.Directory) error {
+	if r.order.Profile == "" {
+		return nil
+	}
+	if len(directory.Meta.Profiles) == 0 {
+		return fmt.Errorf("the ACME server doesn't offer certificate profiles, but the %q profile is configured", r.order.Profile)
+	}
+	if _, ok := directory.Meta.Profiles[r.order.Profile]; !ok {
+		profiles := slices.Sorted(maps.Keys(directory.Meta.Profiles))
+		return fmt.Errorf("the ACME server doesn't offer the %q profile, it offers: %s", r.order.Profile, strings.Join(profiles, ", "))
+	}
+	return nil
+}
+
+// chainIssuer returns the common name of the issuer of the last certificate of a PEM encoded chain, which is
+// the name that preferred chains are matched against.
+func chainIssuer(chainPEM string) string {
+	var last *x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			last = cert
+		}
+	}
+	if last == nil {
+		return ""
+	}
+	return last.Issuer.CommonName
+}
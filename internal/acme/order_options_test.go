@@ -0,0 +1,143 @@
+package acme_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestValidateOrderOptions(t *testing.T) {
+	cases := []struct {
+		desc    string
+		order   db.ACMEOrderOptions
+		wantErr string
+	}{
+		{"not set", db.ACMEOrderOptions{}, ""},
+		{"issuer name with spaces", db.ACMEOrderOptions{PreferredChain: "ISRG Root X1"}, ""},
+		{"profile", db.ACMEOrderOptions{Profile: "tlsserver"}, ""},
+		{"preferred chain with leading space", db.ACMEOrderOptions{PreferredChain: " ISRG Root X1"}, "must be the common name of an issuer"},
+		{"preferred chain with newline", db.ACMEOrderOptions{PreferredChain: "ISRG\nRoot X1"}, "must be the common name of an issuer"},
+		{"preferred chain too long", db.ACMEOrderOptions{PreferredChain: strings.Repeat("a", 257)}, "can't be longer than 256 characters"},
+		{"profile with space", db.ACMEOrderOptions{Profile: "tls server"}, "must be a name without spaces"},
+		{"profile too long", db.ACMEOrderOptions{Profile: strings.Repeat("a", 65)}, "can't be longer than 64 characters"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := acme.ValidateOrderOptions(tc.order)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateOrderOptions() unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("ValidateOrderOptions() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignCSRWithOrderOptions(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL, roots := tu.MustStartPebbleWithAlternateChains(t, httpPort, tlsPort, 1)
+	database := tu.MustPrepareEmptyDB(t)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+
+	id, err := database.CreateACMEServer("pebble", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{PreferredChain: roots[1]})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	repo := acme.NewACMERepository(server, nil, database)
+
+	t.Run("preferred chain of the server", func(t *testing.T) {
+		issued, err := repo.SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+		if err != nil {
+			t.Fatalf("SignCSR() unexpected error: %s", err)
+		}
+		if issued.ChainIssuer != roots[1] {
+			t.Fatalf("expected the chain of %q, got %q", roots[1], issued.ChainIssuer)
+		}
+		if issued.Order.PreferredChain != roots[1] {
+			t.Fatalf("expected the preferred chain of the server to be recorded, got %q", issued.Order.PreferredChain)
+		}
+	})
+
+	t.Run("preferred chain of the request", func(t *testing.T) {
+		issued, err := repo.SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{PreferredChain: roots[0]})
+		if err != nil {
+			t.Fatalf("SignCSR() unexpected error: %s", err)
+		}
+		if issued.ChainIssuer != roots[0] {
+			t.Fatalf("expected the chain of %q, got %q", roots[0], issued.ChainIssuer)
+		}
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		issued, err := repo.SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{Profile: tu.PebbleShortLivedProfile})
+		if err != nil {
+			t.Fatalf("SignCSR() unexpected error: %s", err)
+		}
+		if issued.Order.Profile != tu.PebbleShortLivedProfile {
+			t.Fatalf("expected the profile to be recorded, got %q", issued.Order.Profile)
+		}
+		block, _ := pem.Decode([]byte(issued.Chain))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %s", err)
+		}
+		if validity := cert.NotAfter.Sub(cert.NotBefore); validity > tu.PebbleShortLivedValidity {
+			t.Fatalf("expected a certificate of the %s profile, got a validity of %s", tu.PebbleShortLivedProfile, validity)
+		}
+	})
+
+	t.Run("invalid profile", func(t *testing.T) {
+		_, err := repo.SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{Profile: "tls server"})
+		if err == nil || !strings.Contains(err.Error(), "must be a name without spaces") {
+			t.Fatalf("expected the profile to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestDryRunChecksProfile(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(tu.MustGetFreePort(t))}
+
+	cases := []struct {
+		desc    string
+		profile string
+		want    string
+	}{
+		{"offered profile", tu.PebbleShortLivedProfile, acme.DryRunStatusPassed},
+		{"unknown profile", "not-a-profile", acme.DryRunStatusFailed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			database := tu.MustPrepareEmptyDB(t)
+			id, err := database.CreateACMEServer("test", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{Profile: tc.profile})
+			if err != nil {
+				t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+			}
+			server, err := database.GetDecryptedACMEServer(id)
+			if err != nil {
+				t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+			}
+			report := acme.NewACMERepository(server, nil, database).DryRun("")
+			for _, step := range report.Steps {
+				if step.Name == acme.DryRunStepDirectory && step.Status != tc.want {
+					t.Fatalf("expected the directory step to be %s, got %s: %s", tc.want, step.Status, step.Detail)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+// ErrRenewalInfoUnsupported is returned when the ACME server doesn't offer ACME Renewal Information.
+var ErrRenewalInfoUnsupported = errors.New("acme: server does not support renewal information")
+
+// RenewalDue asks the ACME server whether the certificate at the start of certPEM should be renewed at now,
+// following the suggested renewal window of ACME Renewal Information (RFC 9773).
+// It returns ErrRenewalInfoUnsupported if the ACME server doesn't offer renewal information.
+func (r *ACMERepository) RenewalDue(certPEM string, now time.Time) (bool, error) {
+	leaf, err := parseLeafCertificate(certPEM)
+	if err != nil {
+		return false, err
+	}
+	client, _, err := r.newClient(context.Background())
+	if err != nil {
+		return false, err
+	}
+	info, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: leaf})
+	if errors.Is(err, api.ErrNoARI) {
+		return false, ErrRenewalInfoUnsupported
+	}
+	if err != nil {
+		return false, fmt.Errorf("acme: failed to get renewal information: %w", err)
+	}
+	return info.ShouldRenewAt(now, 0) != nil, nil
+}
+
+// parseLeafCertificate parses the first certificate of a PEM certificate chain.
+func parseLeafCertificate(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, errors.New("acme: failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("acme: failed to parse certificate: %w", err)
+	}
+	return cert, nil
+}
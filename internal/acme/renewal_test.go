@@ -0,0 +1,67 @@
+package acme_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestRenewCSRWithPebble(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	database := tu.MustPrepareEmptyDB(t)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+	id, err := database.CreateACMEServer("pebble", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+	repo := acme.NewACMERepository(server, nil, database)
+
+	csr := tu.MustGenerateCSR(t, "localhost")
+	issued, err := repo.SignCSR(context.Background(), csr, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("SignCSR() unexpected error: %s", err)
+	}
+	chain := issued.Chain
+	block, _ := pem.Decode([]byte(chain))
+	if block == nil {
+		t.Fatalf("expected a PEM certificate chain, got %q", chain)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("couldn't parse issued certificate: %s", err)
+	}
+
+	due, err := repo.RenewalDue(chain, time.Now())
+	if err != nil {
+		t.Fatalf("RenewalDue() unexpected error: %s", err)
+	}
+	if due {
+		t.Fatalf("expected a fresh certificate not to be due for renewal")
+	}
+	due, err = repo.RenewalDue(chain, cert.NotAfter)
+	if err != nil {
+		t.Fatalf("RenewalDue() unexpected error: %s", err)
+	}
+	if !due {
+		t.Fatalf("expected an expiring certificate to be due for renewal")
+	}
+
+	renewed, err := repo.RenewCSR(context.Background(), csr, chain, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("RenewCSR() unexpected error: %s", err)
+	}
+	if renewed.Chain == chain {
+		t.Fatalf("expected a new certificate chain")
+	}
+}
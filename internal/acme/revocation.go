@@ -0,0 +1,64 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/canonical/notary/internal/db"
+	legoacme "github.com/go-acme/lego/v4/acme"
+)
+
+// revocationReasons are the RFC 5280 reason codes that can be given when revoking a certificate,
+// by the name they have in RFC 5280. The reasons that only make sense for CA certificates are left out.
+var revocationReasons = map[string]uint{
+	"unspecified":          legoacme.CRLReasonUnspecified,
+	"keyCompromise":        legoacme.CRLReasonKeyCompromise,
+	"affiliationChanged":   legoacme.CRLReasonAffiliationChanged,
+	"superseded":           legoacme.CRLReasonSuperseded,
+	"cessationOfOperation": legoacme.CRLReasonCessationOfOperation,
+	"privilegeWithdrawn":   legoacme.CRLReasonPrivilegeWithdrawn,
+}
+
+// ErrInvalidRevocationReason is returned for revocation reasons that are not in revocationReasons.
+var ErrInvalidRevocationReason = errors.New("acme: invalid revocation reason")
+
+// ParseRevocationReason returns the reason code of a revocation reason name. An empty name is the unspecified reason.
+func ParseRevocationReason(name string) (uint, error) {
+	if name == "" {
+		return legoacme.CRLReasonUnspecified, nil
+	}
+	reason, ok := revocationReasons[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRevocationReason, name)
+	}
+	return reason, nil
+}
+
+// RevokeCertificate asks the ACME server of the account to revoke the certificate at the start of certPEM.
+// The request is signed with the account that ordered the certificate, whose private key must be decrypted.
+// The unspecified reason is left out of the request, as RFC 5280 recommends for CRL entries.
+// A certificate that the ACME server already revoked is considered revoked.
+func RevokeCertificate(account *db.ACMEAccount, certPEM string, reason uint) error {
+	user, err := accountUser(account)
+	if err != nil {
+		return fmt.Errorf("acme: %w", err)
+	}
+	client, err := newAccountClient(context.Background(), user, account.DirectoryURL)
+	if err != nil {
+		return err
+	}
+	var reasonCode *uint
+	if reason != legoacme.CRLReasonUnspecified {
+		reasonCode = &reason
+	}
+	err = client.Certificate.RevokeWithReason([]byte(certPEM), reasonCode)
+	var problem *legoacme.ProblemDetails
+	if errors.As(err, &problem) && problem.Type == "urn:ietf:params:acme:error:alreadyRevoked" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("acme: certificate revocation failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package acme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestParseRevocationReason(t *testing.T) {
+	cases := []struct {
+		name   string
+		reason uint
+	}{
+		{"", 0},
+		{"unspecified", 0},
+		{"keyCompromise", 1},
+		{"superseded", 4},
+		{"cessationOfOperation", 5},
+	}
+	for _, tc := range cases {
+		reason, err := acme.ParseRevocationReason(tc.name)
+		if err != nil {
+			t.Fatalf("ParseRevocationReason(%q) unexpected error: %s", tc.name, err)
+		}
+		if reason != tc.reason {
+			t.Fatalf("ParseRevocationReason(%q) = %d, expected %d", tc.name, reason, tc.reason)
+		}
+	}
+	for _, name := range []string{"certificateHold", "removeFromCRL", "KeyCompromise", "1"} {
+		if _, err := acme.ParseRevocationReason(name); !errors.Is(err, acme.ErrInvalidRevocationReason) {
+			t.Fatalf("expected ParseRevocationReason(%q) to fail, got %v", name, err)
+		}
+	}
+}
+
+func TestRevokeCertificateWithPebble(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	database := tu.MustPrepareEmptyDB(t)
+	challenge := db.ACMEChallenge{Type: db.ACMEChallengeHTTP01, Port: int64(httpPort)}
+	id, err := database.CreateACMEServer("pebble", directoryURL, "admin@example.com", "", map[string]string{}, challenge, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %s", err)
+	}
+	server, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %s", err)
+	}
+
+	issued, err := acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("SignCSR() unexpected error: %s", err)
+	}
+	if issued.ServerID != id {
+		t.Fatalf("expected the certificate to be issued by server %d, got %d", id, issued.ServerID)
+	}
+	account, err := database.GetDecryptedACMEAccount(issued.AccountID)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEAccount() unexpected error: %s", err)
+	}
+	if account.Email != "admin@example.com" || account.DirectoryURL != directoryURL {
+		t.Fatalf("expected the account of the ACME server, got %s at %s", account.Email, account.DirectoryURL)
+	}
+
+	if err := acme.RevokeCertificate(account, issued.Chain, 1); err != nil {
+		t.Fatalf("RevokeCertificate() unexpected error: %s", err)
+	}
+	// Revoking the certificate again is not an error, so that a revocation can be retried.
+	if err := acme.RevokeCertificate(account, issued.Chain, 1); err != nil {
+		t.Fatalf("RevokeCertificate() of a revoked certificate unexpected error: %s", err)
+	}
+	// Pebble doesn't accept the unused reason code.
+	other, err := acme.NewACMERepository(server, nil, database).SignCSR(context.Background(), tu.MustGenerateCSR(t, "localhost"), db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("SignCSR() unexpected error: %s", err)
+	}
+	if err := acme.RevokeCertificate(account, other.Chain, 7); err == nil {
+		t.Fatal("expected RevokeCertificate() with an invalid reason to fail")
+	}
+}
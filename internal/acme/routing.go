@@ -0,0 +1,102 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+var (
+	// ErrNoRoute is returned when no ACME route matches a name of a certificate request.
+	ErrNoRoute = errors.New("no ACME route matches")
+	// ErrConflictingRoutes is returned when the names of a certificate request are routed to different ACME servers.
+	ErrConflictingRoutes = errors.New("the names of the certificate request are routed to different ACME servers")
+)
+
+// NormalizeDomainSuffix returns the domain suffix in the form it is matched in.
+// Leading wildcard labels and dots are ignored, so "*.corp", ".corp" and "corp" are the same suffix.
+// An empty suffix matches every name.
+func NormalizeDomainSuffix(suffix string) (string, error) {
+	suffix = strings.ToLower(strings.TrimSpace(suffix))
+	suffix = strings.TrimPrefix(suffix, "*")
+	suffix = strings.Trim(suffix, ".")
+	if strings.ContainsAny(suffix, "* /") || strings.Contains(suffix, "..") {
+		return "", fmt.Errorf("invalid domain suffix %q", suffix)
+	}
+	return suffix, nil
+}
+
+// RouteCSR returns the ID of the ACME server that signs the certificate request, according to routes in the order
+// they are matched. Every DNS name of the request, and its common name, must be routed to the same server.
+// IP addresses are only matched by routes with an empty domain suffix. The returned errors are meant to be shown to the user.
+func RouteCSR(routes []db.ACMERoute, csrPEM string) (int64, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil {
+		return 0, errors.New("failed to decode CSR PEM")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return 0, errors.New("failed to parse CSR")
+	}
+
+	names := csr.DNSNames
+	if csr.Subject.CommonName != "" && !containsFold(names, csr.Subject.CommonName) {
+		names = append([]string{csr.Subject.CommonName}, names...)
+	}
+	for _, ip := range csr.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 {
+		return 0, errors.New("the certificate request has no names")
+	}
+
+	var serverID int64
+	var firstName string
+	for _, name := range names {
+		route, ok := matchRoute(routes, name)
+		if !ok {
+			return 0, fmt.Errorf("%w %s", ErrNoRoute, name)
+		}
+		if firstName == "" {
+			serverID, firstName = route.ACMEServerID, name
+			continue
+		}
+		if route.ACMEServerID != serverID {
+			return 0, fmt.Errorf("%w: %s is routed to ACME server %d and %s to ACME server %d",
+				ErrConflictingRoutes, firstName, serverID, name, route.ACMEServerID)
+		}
+	}
+	return serverID, nil
+}
+
+// matchRoute returns the first route whose domain suffix matches the name.
+func matchRoute(routes []db.ACMERoute, name string) (db.ACMERoute, bool) {
+	name = strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(name, ".")), "*.")
+	isIP := net.ParseIP(name) != nil
+	for _, route := range routes {
+		if route.DomainSuffix == "" {
+			return route, true
+		}
+		if isIP {
+			continue
+		}
+		if name == route.DomainSuffix || strings.HasSuffix(name, "."+route.DomainSuffix) {
+			return route, true
+		}
+	}
+	return db.ACMERoute{}, false
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package acme_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestNormalizeDomainSuffix(t *testing.T) {
+	cases := []struct {
+		suffix  string
+		want    string
+		wantErr bool
+	}{
+		{"corp", "corp", false},
+		{".Corp", "corp", false},
+		{"*.example.com", "example.com", false},
+		{"example.com.", "example.com", false},
+		{"", "", false},
+		{"*", "", false},
+		{"a*.corp", "", true},
+		{"example..com", "", true},
+		{"example com", "", true},
+	}
+	for _, tc := range cases {
+		got, err := acme.NormalizeDomainSuffix(tc.suffix)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("NormalizeDomainSuffix(%q) expected an error, got %q", tc.suffix, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeDomainSuffix(%q) unexpected error: %s", tc.suffix, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("NormalizeDomainSuffix(%q) = %q, expected %q", tc.suffix, got, tc.want)
+		}
+	}
+}
+
+func TestRouteCSR(t *testing.T) {
+	routes := []db.ACMERoute{
+		{DomainSuffix: "corp", ACMEServerID: 2},
+		{DomainSuffix: "example.com", ACMEServerID: 1},
+	}
+	withDefault := append(routes, db.ACMERoute{DomainSuffix: "", ACMEServerID: 3})
+
+	cases := []struct {
+		desc    string
+		routes  []db.ACMERoute
+		names   []string
+		want    int64
+		wantErr error
+	}{
+		{"suffix match", routes, []string{"git.corp"}, 2, nil},
+		{"exact match", routes, []string{"example.com", "www.example.com"}, 1, nil},
+		{"wildcard name", routes, []string{"*.dev.corp"}, 2, nil},
+		{"matches whole labels", routes, []string{"notexample.com"}, 0, acme.ErrNoRoute},
+		{"case insensitive", routes, []string{"Mail.CORP"}, 2, nil},
+		{"first route wins", append([]db.ACMERoute{{DomainSuffix: "dev.corp", ACMEServerID: 4}}, routes...), []string{"a.dev.corp"}, 4, nil},
+		{"default route", withDefault, []string{"www.example.org"}, 3, nil},
+		{"no route", routes, []string{"www.example.org"}, 0, acme.ErrNoRoute},
+		{"names span routes", routes, []string{"git.corp", "www.example.com"}, 0, acme.ErrConflictingRoutes},
+		{"names span the default route", withDefault, []string{"www.example.com", "www.example.org"}, 0, acme.ErrConflictingRoutes},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := acme.RouteCSR(tc.routes, tu.MustGenerateCSR(t, tc.names...))
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RouteCSR() unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected ACME server %d, got %d", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := acme.RouteCSR(routes, "not a csr"); err == nil {
+		t.Fatal("expected RouteCSR() to fail for an invalid CSR")
+	}
+}
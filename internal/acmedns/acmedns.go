@@ -0,0 +1,209 @@
+// Package acmedns implements a service compatible with acme-dns (https://github.com/joohoi/acme-dns):
+// an API to register accounts and set their TXT record, and an authoritative DNS server for the zone that
+// the records live in. Teams delegate the DNS-01 challenges of a domain to an account with a single CNAME
+// from _acme-challenge.<domain> to the full domain of the account, without giving out credentials to their zone.
+package acmedns
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/utils"
+	"github.com/google/uuid"
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+// The errors of the acme-dns API. Their messages are the error codes returned by acme-dns.
+var (
+	ErrForbidden          = errors.New("forbidden")
+	ErrBadSubdomain       = errors.New("bad_subdomain")
+	ErrBadTXT             = errors.New("bad_txt")
+	ErrInvalidAllowFrom   = errors.New("invalid_allowfrom_cidr")
+	ErrInvalidDomain      = errors.New("invalid_domain")
+	ErrDomainAlreadyTaken = errors.New("domain_already_registered")
+)
+
+// txtLength is the length of the value of a DNS-01 challenge record: a base64url encoded SHA-256 digest.
+const txtLength = 43
+
+const passwordBytes = 30
+
+// ACMEDNSRepository registers the accounts of the acme-dns compatible service, and answers the DNS queries
+// for the zone of the service.
+type ACMEDNSRepository struct {
+	// zone and nameserver are fully qualified, in lower case.
+	zone       string
+	nameserver string
+	// address is the address of the nameserver, served when the nameserver is in the zone. It may be nil.
+	address       net.IP
+	listenAddress string
+	db            *db.DatabaseRepository
+	logger        *zap.Logger
+
+	mu      sync.Mutex
+	servers []*dns.Server
+}
+
+// NewACMEDNSRepository creates the acme-dns compatible service for the zone domain, whose nameserver is nameserver.
+// The DNS server listens on listenAddress, over UDP and TCP, once it is started.
+func NewACMEDNSRepository(domain, nameserver string, address net.IP, listenAddress string, database *db.DatabaseRepository, logger *zap.Logger) *ACMEDNSRepository {
+	if nameserver == "" {
+		nameserver = domain
+	}
+	return &ACMEDNSRepository{
+		zone:          dns.Fqdn(strings.ToLower(domain)),
+		nameserver:    dns.Fqdn(strings.ToLower(nameserver)),
+		address:       address,
+		listenAddress: listenAddress,
+		db:            database,
+		logger:        logger,
+	}
+}
+
+// Domain returns the zone of the service, without the trailing dot.
+func (r *ACMEDNSRepository) Domain() string {
+	return strings.TrimSuffix(r.zone, ".")
+}
+
+// FullDomain returns the domain of the TXT record of the account that owns subdomain.
+func (r *ACMEDNSRepository) FullDomain(subdomain string) string {
+	return subdomain + "." + r.Domain()
+}
+
+// Registration holds the credentials of a new account. The password is only known when the account is created.
+type Registration struct {
+	ID         int64
+	Username   string
+	Password   string
+	Subdomain  string
+	FullDomain string
+	AllowFrom  []string
+	Domain     string
+}
+
+// Register creates an account whose record can be updated from the networks of allowFrom, or from any network
+// when it is empty. domain is optional: it is the domain whose challenges are delegated to the account, for
+// the ACME servers of Notary that solve DNS-01 challenges with the built-in service.
+func (r *ACMEDNSRepository) Register(allowFrom []string, domain, createdBy string) (*Registration, error) {
+	allowFrom, err := NormalizeAllowFrom(allowFrom)
+	if err != nil {
+		return nil, err
+	}
+	if domain != "" {
+		domain, err = NormalizeDomain(domain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	password, err := generatePassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	registration := &Registration{
+		Username:  uuid.NewString(),
+		Password:  password,
+		Subdomain: uuid.NewString(),
+		AllowFrom: allowFrom,
+		Domain:    domain,
+	}
+	registration.FullDomain = r.FullDomain(registration.Subdomain)
+	registration.ID, err = r.db.CreateACMEDNSRegistration(registration.Username, password, registration.Subdomain, allowFrom, domain, createdBy)
+	if err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			return nil, ErrDomainAlreadyTaken
+		}
+		return nil, err
+	}
+	return registration, nil
+}
+
+// Update sets the TXT record of the account of username to txt, if password is the password of the account,
+// subdomain is its subdomain, and remote is in one of the networks the account can be updated from.
+func (r *ACMEDNSRepository) Update(username, password, subdomain, txt string, remote netip.Addr) error {
+	if err := uuid.Validate(subdomain); err != nil {
+		return ErrBadSubdomain
+	}
+	registration, err := r.db.GetACMEDNSRegistrationByUsername(username)
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+		// Spend the time of a password check, so that usernames can't be told apart by timing.
+		_ = utils.CompareHashAndPassword("", password)
+		return ErrForbidden
+	}
+	if err := utils.CompareHashAndPassword(registration.HashedPassword, password); err != nil {
+		return ErrForbidden
+	}
+	if !allowed(registration.AllowFrom, remote) {
+		return ErrForbidden
+	}
+	if subdomain != registration.Subdomain {
+		return ErrForbidden
+	}
+	if !isValidTXT(txt) {
+		return ErrBadTXT
+	}
+	return r.db.SetACMEDNSRecord(registration.ID, txt)
+}
+
+// NormalizeAllowFrom validates the networks, in CIDR notation, that an account can be updated from.
+func NormalizeAllowFrom(allowFrom []string) ([]string, error) {
+	normalized := make([]string, 0, len(allowFrom))
+	for _, cidr := range allowFrom {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, ErrInvalidAllowFrom
+		}
+		normalized = append(normalized, prefix.Masked().String())
+	}
+	return normalized, nil
+}
+
+// NormalizeDomain validates a domain whose challenges are delegated to an account, and returns it in lower case
+// without a trailing dot.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if _, ok := dns.IsDomainName(domain); !ok || !strings.Contains(domain, ".") || strings.ContainsAny(domain, "*/: ") {
+		return "", ErrInvalidDomain
+	}
+	return domain, nil
+}
+
+// allowed reports whether remote is in one of the comma separated networks of allowFrom, which allows any when it is empty.
+func allowed(allowFrom string, remote netip.Addr) bool {
+	if allowFrom == "" {
+		return true
+	}
+	for _, cidr := range strings.Split(allowFrom, ",") {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err == nil && prefix.Contains(remote.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTXT(txt string) bool {
+	if len(txt) != txtLength {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(txt)
+	return err == nil
+}
+
+func generatePassword() (string, error) {
+	b := make([]byte, passwordBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
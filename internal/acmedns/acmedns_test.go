@@ -0,0 +1,152 @@
+package acmedns_test
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/acmedns"
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/google/uuid"
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+const (
+	validTXT   = "LHDhK3oGRvkiefQnx7OOczTY5Tic_xZ6HcMOc_gmtoM"
+	anotherTXT = "Xr1DqHOddHmTgxr1qsbqVIm9kD2JAvRE4UKBx1SU2Xg"
+)
+
+func TestRegisterAndUpdate(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	repo := acmedns.NewACMEDNSRepository("acme-dns.example.com", "", nil, "", database, zap.NewNop())
+
+	registration, err := repo.Register([]string{"192.0.2.10/24"}, "Example.COM.", "admin@example.com")
+	if err != nil {
+		t.Fatalf("Register() unexpected error: %s", err)
+	}
+	if registration.FullDomain != registration.Subdomain+".acme-dns.example.com" {
+		t.Fatalf("unexpected full domain %q", registration.FullDomain)
+	}
+	if registration.Domain != "example.com" || strings.Join(registration.AllowFrom, ",") != "192.0.2.0/24" {
+		t.Fatalf("expected the normalized domain and networks, got %+v", registration)
+	}
+	if _, err := repo.Register(nil, "example.com", ""); !errors.Is(err, acmedns.ErrDomainAlreadyTaken) {
+		t.Fatalf("expected a second registration for example.com to fail, got %v", err)
+	}
+	if _, err := repo.Register([]string{"not a network"}, "", ""); !errors.Is(err, acmedns.ErrInvalidAllowFrom) {
+		t.Fatalf("expected an invalid network to be rejected, got %v", err)
+	}
+	if _, err := repo.Register(nil, "*.example.net", ""); !errors.Is(err, acmedns.ErrInvalidDomain) {
+		t.Fatalf("expected a wildcard domain to be rejected, got %v", err)
+	}
+
+	allowed := netip.MustParseAddr("192.0.2.20")
+	cases := []struct {
+		desc      string
+		username  string
+		password  string
+		subdomain string
+		txt       string
+		remote    netip.Addr
+		wantErr   error
+	}{
+		{"unknown username", "unknown", registration.Password, registration.Subdomain, validTXT, allowed, acmedns.ErrForbidden},
+		{"wrong password", registration.Username, "wrong", registration.Subdomain, validTXT, allowed, acmedns.ErrForbidden},
+		{"remote not allowed", registration.Username, registration.Password, registration.Subdomain, validTXT, netip.MustParseAddr("198.51.100.1"), acmedns.ErrForbidden},
+		{"invalid subdomain", registration.Username, registration.Password, "another", validTXT, allowed, acmedns.ErrBadSubdomain},
+		{"subdomain of another account", registration.Username, registration.Password, uuid.NewString(), validTXT, allowed, acmedns.ErrForbidden},
+		{"invalid TXT", registration.Username, registration.Password, registration.Subdomain, "too-short", allowed, acmedns.ErrBadTXT},
+		{"IPv4-mapped remote", registration.Username, registration.Password, registration.Subdomain, validTXT, netip.MustParseAddr("::ffff:192.0.2.20"), nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := repo.Update(tc.username, tc.password, tc.subdomain, tc.txt, tc.remote)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Update() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+	stored, err := database.GetACMEDNSRegistrationByDomain("example.com")
+	if err != nil {
+		t.Fatalf("GetACMEDNSRegistrationByDomain() unexpected error: %s", err)
+	}
+	if stored.TXT != validTXT {
+		t.Fatalf("expected the TXT record to be set, got %q", stored.TXT)
+	}
+}
+
+func TestServeDNS(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(tu.MustGetFreePort(t)))
+	repo := acmedns.NewACMEDNSRepository("acme-dns.example.com", "ns.acme-dns.example.com", net.ParseIP("192.0.2.53"), addr, database, zap.NewNop())
+	if err := repo.Start(); err != nil {
+		t.Fatalf("Start() unexpected error: %s", err)
+	}
+	t.Cleanup(repo.Stop)
+
+	registration, err := repo.Register(nil, "", "")
+	if err != nil {
+		t.Fatalf("Register() unexpected error: %s", err)
+	}
+	remote := netip.MustParseAddr("127.0.0.1")
+	for _, txt := range []string{validTXT, anotherTXT} {
+		if err := repo.Update(registration.Username, registration.Password, registration.Subdomain, txt, remote); err != nil {
+			t.Fatalf("Update() unexpected error: %s", err)
+		}
+	}
+
+	cases := []struct {
+		desc      string
+		name      string
+		qtype     uint16
+		net       string
+		wantRcode int
+		want      []string
+	}{
+		{"TXT record", registration.FullDomain, dns.TypeTXT, "udp", dns.RcodeSuccess, []string{anotherTXT, validTXT}},
+		{"TXT record over TCP", strings.ToUpper(registration.FullDomain), dns.TypeTXT, "tcp", dns.RcodeSuccess, []string{anotherTXT, validTXT}},
+		{"other type of a registered name", registration.FullDomain, dns.TypeA, "udp", dns.RcodeSuccess, nil},
+		{"unknown subdomain", "unknown.acme-dns.example.com", dns.TypeTXT, "udp", dns.RcodeNameError, nil},
+		{"SOA of the zone", "acme-dns.example.com", dns.TypeSOA, "udp", dns.RcodeSuccess, []string{"ns.acme-dns.example.com."}},
+		{"NS of the zone", "acme-dns.example.com", dns.TypeNS, "udp", dns.RcodeSuccess, []string{"ns.acme-dns.example.com."}},
+		{"address of the nameserver", "ns.acme-dns.example.com", dns.TypeA, "udp", dns.RcodeSuccess, []string{"192.0.2.53"}},
+		{"name outside of the zone", "example.org", dns.TypeTXT, "udp", dns.RcodeRefused, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(tc.name), tc.qtype)
+			client := &dns.Client{Net: tc.net}
+			resp, _, err := client.Exchange(m, addr)
+			if err != nil {
+				t.Fatalf("failed to query the acme-dns server: %s", err)
+			}
+			if resp.Rcode != tc.wantRcode {
+				t.Fatalf("expected rcode %s, got %s", dns.RcodeToString[tc.wantRcode], dns.RcodeToString[resp.Rcode])
+			}
+			var got []string
+			for _, rr := range resp.Answer {
+				switch rr := rr.(type) {
+				case *dns.TXT:
+					got = append(got, rr.Txt...)
+				case *dns.SOA:
+					got = append(got, rr.Ns)
+				case *dns.NS:
+					got = append(got, rr.Ns)
+				case *dns.A:
+					got = append(got, rr.A.String())
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("expected answers %v, got %v", tc.want, got)
+			}
+			if tc.wantRcode != dns.RcodeRefused && len(resp.Answer) == 0 && len(resp.Ns) != 1 {
+				t.Fatalf("expected the SOA of the zone in the authority section, got %v", resp.Ns)
+			}
+		})
+	}
+}
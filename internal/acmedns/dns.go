@@ -0,0 +1,165 @@
+package acmedns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/miekg/dns"
+	"go.uber.org/zap"
+)
+
+const (
+	// recordTTL is the TTL of the TXT records, kept short so that resolvers pick up new challenges quickly.
+	recordTTL = 1
+	// zoneTTL is the TTL of the SOA, NS and address records of the zone.
+	zoneTTL = 3600
+)
+
+// Start listens for DNS queries on the listen address of the service, over UDP and TCP.
+func (r *ACMEDNSRepository) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.servers != nil {
+		return errors.New("the acme-dns server is already started")
+	}
+	packetConn, err := net.ListenPacket("udp", r.listenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen for DNS queries over UDP: %w", err)
+	}
+	listener, err := net.Listen("tcp", r.listenAddress)
+	if err != nil {
+		packetConn.Close() //nolint:errcheck
+		return fmt.Errorf("failed to listen for DNS queries over TCP: %w", err)
+	}
+	r.servers = []*dns.Server{
+		{PacketConn: packetConn, Handler: r},
+		{Listener: listener, Handler: r},
+	}
+	for _, server := range r.servers {
+		go func() {
+			if err := server.ActivateAndServe(); err != nil {
+				r.logger.Error("acme-dns server stopped", zap.Error(err))
+			}
+		}()
+	}
+	return nil
+}
+
+// Stop stops listening for DNS queries.
+func (r *ACMEDNSRepository) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, server := range r.servers {
+		if err := server.Shutdown(); err != nil {
+			r.logger.Warn("failed to stop acme-dns server", zap.Error(err))
+		}
+	}
+	r.servers = nil
+}
+
+// ServeDNS answers a DNS query for the zone of the service. The service is authoritative for its zone,
+// and refuses the queries for other names.
+func (r *ACMEDNSRepository) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	if req.Opcode != dns.OpcodeQuery || len(req.Question) != 1 {
+		m.SetRcode(req, dns.RcodeNotImplemented)
+		r.write(w, m)
+		return
+	}
+	q := req.Question[0]
+	name := strings.ToLower(q.Name)
+	if !dns.IsSubDomain(r.zone, name) {
+		m.SetRcode(req, dns.RcodeRefused)
+		r.write(w, m)
+		return
+	}
+	m.Authoritative = true
+	answer, found, err := r.records(name, q.Qtype)
+	if err != nil {
+		r.logger.Error("failed to answer DNS query", zap.Error(err), zap.String("name", name))
+		m.SetRcode(req, dns.RcodeServerFailure)
+		r.write(w, m)
+		return
+	}
+	if !found {
+		m.Rcode = dns.RcodeNameError
+	}
+	m.Answer = answer
+	if len(answer) == 0 {
+		m.Ns = []dns.RR{r.soa()}
+	}
+	r.write(w, m)
+}
+
+// records returns the records of type qtype for name, and whether name exists in the zone.
+func (r *ACMEDNSRepository) records(name string, qtype uint16) ([]dns.RR, bool, error) {
+	var answer []dns.RR
+	found := false
+	if name == r.zone {
+		found = true
+		switch qtype {
+		case dns.TypeSOA:
+			answer = append(answer, r.soa())
+		case dns.TypeNS:
+			answer = append(answer, &dns.NS{Hdr: r.header(name, dns.TypeNS, zoneTTL), Ns: r.nameserver})
+		}
+	}
+	if name == r.nameserver && r.address != nil {
+		found = true
+		if ip4 := r.address.To4(); ip4 != nil && qtype == dns.TypeA {
+			answer = append(answer, &dns.A{Hdr: r.header(name, dns.TypeA, zoneTTL), A: ip4})
+		} else if ip4 == nil && qtype == dns.TypeAAAA {
+			answer = append(answer, &dns.AAAA{Hdr: r.header(name, dns.TypeAAAA, zoneTTL), AAAA: r.address})
+		}
+	}
+	if found {
+		return answer, true, nil
+	}
+	subdomain, ok := strings.CutSuffix(name, "."+r.zone)
+	if !ok || strings.Contains(subdomain, ".") {
+		return nil, false, nil
+	}
+	registration, err := r.db.GetACMEDNSRegistrationBySubdomain(subdomain)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if qtype == dns.TypeTXT {
+		for _, txt := range []string{registration.TXT, registration.PreviousTXT} {
+			if txt != "" {
+				answer = append(answer, &dns.TXT{Hdr: r.header(name, dns.TypeTXT, recordTTL), Txt: []string{txt}})
+			}
+		}
+	}
+	return answer, true, nil
+}
+
+func (r *ACMEDNSRepository) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     r.header(r.zone, dns.TypeSOA, zoneTTL),
+		Ns:      r.nameserver,
+		Mbox:    "hostmaster." + r.zone,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 28800,
+		Retry:   7200,
+		Expire:  604800,
+		Minttl:  recordTTL,
+	}
+}
+
+func (r *ACMEDNSRepository) header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+func (r *ACMEDNSRepository) write(w dns.ResponseWriter, m *dns.Msg) {
+	if err := w.WriteMsg(m); err != nil {
+		r.logger.Warn("failed to write DNS response", zap.Error(err))
+	}
+}
@@ -0,0 +1,87 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+// APITokenPrefix starts every API token, so that they can be told apart from session tokens and found by secret scanners.
+const APITokenPrefix = "notary_"
+
+const (
+	apiTokenIDBytes     = 8
+	apiTokenSecretBytes = 32
+)
+
+var (
+	ErrInvalidAPIToken = errors.New("invalid API token")
+	ErrExpiredAPIToken = errors.New("expired API token")
+)
+
+// GenerateAPIToken returns a new API token, made of a public token ID and a secret, and the hash of its secret.
+func GenerateAPIToken() (token, tokenID, hashedSecret string, err error) {
+	id := make([]byte, apiTokenIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API token ID: %w", err)
+	}
+	secret := make([]byte, apiTokenSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API token secret: %w", err)
+	}
+	tokenID = hex.EncodeToString(id)
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	return APITokenPrefix + tokenID + "_" + encodedSecret, tokenID, hashAPITokenSecret(encodedSecret), nil
+}
+
+// IsAPIToken reports whether raw looks like an API token rather than a session token.
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, APITokenPrefix)
+}
+
+// VerifyAPIToken returns the API token and the service account that raw belongs to, if it is valid at now.
+func VerifyAPIToken(database *db.DatabaseRepository, raw string, now time.Time) (*db.APIToken, *db.User, error) {
+	tokenID, secret, ok := strings.Cut(strings.TrimPrefix(raw, APITokenPrefix), "_")
+	if !IsAPIToken(raw) || !ok || tokenID == "" || secret == "" {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	token, err := database.GetAPITokenByTokenID(tokenID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, ErrInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPITokenSecret(secret)), []byte(token.HashedSecret)) != 1 {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	if now.Unix() >= token.ExpiresAt {
+		return token, nil, ErrExpiredAPIToken
+	}
+	user, err := database.GetUser(db.ByUserID(token.UserID))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, nil, ErrInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+	if !user.ServiceAccount {
+		return nil, nil, ErrInvalidAPIToken
+	}
+	return token, user, nil
+}
+
+// hashAPITokenSecret hashes the secret of an API token. The secrets are random, so unlike passwords
+// they don't need a slow hash, which would be paid on every request.
+func hashAPITokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
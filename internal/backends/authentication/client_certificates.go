@@ -0,0 +1,169 @@
+package authentication
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+// The certificate fields that a client certificate can be mapped to an account with.
+const (
+	// ClientCertificateIdentityEmail maps the first email address SAN of the certificate to the email of an account.
+	ClientCertificateIdentityEmail = "email"
+	// ClientCertificateIdentityCommonName maps the common name of the subject of the certificate to the email of
+	// an account, or the name of a service account.
+	ClientCertificateIdentityCommonName = "common_name"
+)
+
+var (
+	ErrInvalidClientCertificate = errors.New("invalid client certificate")
+	ErrRevokedClientCertificate = errors.New("revoked client certificate")
+	ErrUnknownClientIdentity    = errors.New("client certificate doesn't match an account")
+	ErrForeignClientIdentity    = errors.New("client certificate names an account that didn't request it")
+)
+
+// ClientCertificateRepository authenticates the clients that present a certificate issued by one of
+// the Notary certificate authorities it trusts.
+type ClientCertificateRepository struct {
+	CertificateAuthorityIDs []int64
+	Identity                string
+
+	database *db.DatabaseRepository
+}
+
+// NewClientCertificateRepository returns a repository that trusts the client certificates issued by the certificate authorities,
+// and maps them to accounts with identity.
+func NewClientCertificateRepository(certificateAuthorityIDs []int64, identity string, database *db.DatabaseRepository) *ClientCertificateRepository {
+	return &ClientCertificateRepository{
+		CertificateAuthorityIDs: certificateAuthorityIDs,
+		Identity:                identity,
+		database:                database,
+	}
+}
+
+// IsValidClientCertificateIdentity reports whether identity is a field that client certificates can be mapped to accounts with.
+func IsValidClientCertificateIdentity(identity string) bool {
+	return identity == ClientCertificateIdentityEmail || identity == ClientCertificateIdentityCommonName
+}
+
+// Authenticate returns the account of the client that presented leaf, if it is valid at now for client authentication,
+// was issued by one of the trusted certificate authorities, isn't revoked, and was requested by the account it names.
+// The certificate authorities are read on every call, so that renewals and revocations apply right away.
+func (r *ClientCertificateRepository) Authenticate(leaf *x509.Certificate, now time.Time) (*db.User, error) {
+	if len(leaf.ExtKeyUsage) > 0 && !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageClientAuth) && !slices.Contains(leaf.ExtKeyUsage, x509.ExtKeyUsageAny) {
+		return nil, fmt.Errorf("%w: not valid for client authentication", ErrInvalidClientCertificate)
+	}
+	ca, err := r.issuer(leaf, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ca.Enabled {
+		return nil, fmt.Errorf("%w: certificate authority %d is disabled", ErrInvalidClientCertificate, ca.CertificateAuthorityID)
+	}
+	if ca.CRL != "" {
+		crl, err := db.ParseCRL(ca.CRL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the CRL of certificate authority %d: %w", ca.CertificateAuthorityID, err)
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return nil, ErrRevokedClientCertificate
+			}
+		}
+	}
+	identity, err := r.identity(leaf)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := r.owner(leaf)
+	if err != nil {
+		return nil, err
+	}
+	if owner != identity {
+		return nil, fmt.Errorf("%w: %s was requested by %s", ErrForeignClientIdentity, identity, owner)
+	}
+	user, err := r.database.GetUser(db.ByEmail(identity))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownClientIdentity, identity)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// issuer returns the trusted certificate authority that issued leaf. It must have issued it directly, so that
+// a certificate issued by another certificate authority of the same hierarchy isn't checked against the wrong CRL.
+func (r *ClientCertificateRepository) issuer(leaf *x509.Certificate, now time.Time) (*db.CertificateAuthorityDenormalized, error) {
+	for _, id := range r.CertificateAuthorityIDs {
+		ca, err := r.database.GetDenormalizedCertificateAuthority(db.ByCertificateAuthorityDenormalizedID(id))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if ca.CertificateChain == "" {
+			continue
+		}
+		chain, err := db.ParseCertificateChain(ca.CertificateChain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the certificate of certificate authority %d: %w", id, err)
+		}
+		// The certificates of Notary CAs are only valid for server authentication, so the key usage of the leaf
+		// is checked on its own rather than along the chain.
+		roots := x509.NewCertPool()
+		roots.AddCert(chain[0])
+		_, err = leaf.Verify(x509.VerifyOptions{
+			Roots:       roots,
+			CurrentTime: now,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil {
+			return ca, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: not issued by a trusted certificate authority", ErrInvalidClientCertificate)
+}
+
+// owner returns the email of the account that submitted the certificate request of leaf. The account that a certificate
+// names must have requested it, so that an account can't have a certificate that names another one signed to impersonate it.
+func (r *ClientCertificateRepository) owner(leaf *x509.Certificate) (string, error) {
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}))
+	certificate, err := r.database.GetCertificate(db.ByCertificatePEM(certPEM))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", fmt.Errorf("%w: not issued for a certificate request", ErrInvalidClientCertificate)
+		}
+		return "", err
+	}
+	csr, err := r.database.GetCertificateRequestByCertificateID(certificate.CertificateID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", fmt.Errorf("%w: not issued for a certificate request", ErrInvalidClientCertificate)
+		}
+		return "", err
+	}
+	return csr.UserEmail, nil
+}
+
+// identity returns the field of the certificate that is mapped to an account.
+func (r *ClientCertificateRepository) identity(leaf *x509.Certificate) (string, error) {
+	switch r.Identity {
+	case ClientCertificateIdentityCommonName:
+		if leaf.Subject.CommonName == "" {
+			return "", fmt.Errorf("%w: no common name", ErrUnknownClientIdentity)
+		}
+		return leaf.Subject.CommonName, nil
+	default:
+		if len(leaf.EmailAddresses) == 0 {
+			return "", fmt.Errorf("%w: no email address", ErrUnknownClientIdentity)
+		}
+		return leaf.EmailAddresses[0], nil
+	}
+}
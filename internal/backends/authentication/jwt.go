@@ -2,45 +2,13 @@ package authentication
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"slices"
 
-	"github.com/canonical/notary/internal/db"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// setUpJWTSecret checks if a JWT secret exists in the database, if not, it generates a new one and stores it.
-func SetUpJWTSecret(database *db.DatabaseRepository) error {
-	jwtSecret, err := database.GetJWTSecret()
-	if err != nil && errors.Is(err, db.ErrNotFound) {
-		// Generate new JWT secret if none exists
-		jwtSecret, err = generateJWTSecret()
-		if err != nil {
-			return err
-		}
-		if err := database.CreateJWTSecret(jwtSecret); err != nil {
-			return fmt.Errorf("failed to store JWT secret: %w", err)
-		}
-		return nil
-	}
-	if err != nil && !errors.Is(err, db.ErrNotFound) {
-		return fmt.Errorf("failed to get JWT secret: %w", err)
-	}
-	database.JWTSecret = jwtSecret
-	return nil
-}
-
-// This secret should be generated once and stored in the database, encrypted.
-func generateJWTSecret() ([]byte, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return bytes, fmt.Errorf("failed to generate JWT secret: %w", err)
-	}
-	return bytes, nil
-}
-
 func NewVerifier(providers []ProviderConfig) *Verifier {
 	return &Verifier{providers: providers}
 }
@@ -53,6 +21,7 @@ func (v *Verifier) VerifyToken(ctx context.Context, rawToken string) (*NotaryJWT
 		case ProviderOIDC:
 			claims, err := verifyOIDCAccessToken(ctx, p, rawToken)
 			if err == nil {
+				claims.Provider = ProviderOIDC
 				return claims, nil
 			}
 			errors = append(errors, fmt.Errorf("oidc: %w", err))
@@ -60,6 +29,7 @@ func (v *Verifier) VerifyToken(ctx context.Context, rawToken string) (*NotaryJWT
 		case ProviderLocal:
 			claims, err := verifyLocalJWT(ctx, p, rawToken)
 			if err == nil {
+				claims.Provider = ProviderLocal
 				return claims, nil
 			}
 			errors = append(errors, fmt.Errorf("local: %w", err))
@@ -121,15 +91,11 @@ func verifyOIDCAccessToken(ctx context.Context, p ProviderConfig, raw string) (*
 }
 
 func verifyLocalJWT(ctx context.Context, p ProviderConfig, raw string) (*NotaryJWTClaims, error) {
+	if p.Keyring == nil {
+		return nil, fmt.Errorf("signing keyring is nil")
+	}
 	claims := localJWTClaims{}
-	token, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
-		switch t.Method.(type) {
-		case *jwt.SigningMethodHMAC:
-			return p.Secret, nil
-		default:
-			return nil, fmt.Errorf("unsupported signing method: %v", t.Header["alg"])
-		}
-	})
+	token, err := p.Keyring.Parse(raw, &claims)
 	if err != nil {
 		return nil, fmt.Errorf("local token parse error: %w", err)
 	}
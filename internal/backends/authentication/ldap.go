@@ -0,0 +1,220 @@
+package authentication
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// The placeholders of the templates and filters of the LDAP configuration.
+const (
+	// LDAPUsernamePlaceholder is replaced by the username that the user logs in with.
+	LDAPUsernamePlaceholder = "{username}"
+	// LDAPDNPlaceholder is replaced by the DN of the entry of the user.
+	LDAPDNPlaceholder = "{dn}"
+)
+
+// DefaultLDAPTimeout bounds the connection to the LDAP server and each of its operations when no timeout is configured.
+const DefaultLDAPTimeout = 10 * time.Second
+
+var ErrLDAPInvalidCredentials = errors.New("invalid LDAP credentials")
+
+// LDAPRepository authenticates users with their password against an LDAP directory, such as Active Directory.
+type LDAPRepository struct {
+	// URL is the address of the LDAP server, with the ldap or ldaps scheme.
+	URL string
+	// StartTLS upgrades the ldap connections to TLS before binding.
+	StartTLS bool
+	// TLSConfig verifies the certificate of the server of ldaps and StartTLS connections.
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the credentials of the service account that searches the directory.
+	// The searches are anonymous when BindDN is empty.
+	BindDN       string
+	BindPassword string
+	// UserDNTemplate is the DN of the entry of the users, with LDAPUsernamePlaceholder in place of their username.
+	// When it is set, the users bind with it directly, and aren't searched for.
+	UserDNTemplate string
+	// UserSearchBase and UserFilter find the entry of the users, with LDAPUsernamePlaceholder in place of their username in UserFilter.
+	UserSearchBase string
+	UserFilter     string
+	// EmailAttribute is the attribute of the entry of the users with their email, which is the email of their account.
+	// The users without it get their username instead.
+	EmailAttribute string
+	// GroupAttribute is the attribute of the entry of the users with the DNs of their groups, such as memberOf.
+	GroupAttribute string
+	// GroupSearchBase and GroupFilter find the groups of the users when they are set, with LDAPDNPlaceholder in place of the
+	// DN of the user, or LDAPUsernamePlaceholder in place of their username in GroupFilter.
+	GroupSearchBase string
+	GroupFilter     string
+	// RoleMapping maps the DNs of the groups of the users to their role on every login.
+	// It is nil when the users keep the role they were given, which is read-only.
+	RoleMapping *RoleMapping
+	// Timeout bounds the connection to the server and each of its operations.
+	Timeout time.Duration
+}
+
+// LDAPIdentity is a user that authenticated against the LDAP directory.
+type LDAPIdentity struct {
+	// DN is the DN of the entry of the user, which identifies them.
+	DN     string
+	Email  string
+	Groups []string
+}
+
+// Authenticate binds to the directory as the user with username and password, and returns their identity.
+// It fails with ErrLDAPInvalidCredentials when the user doesn't exist or the password is wrong.
+func (r *LDAPRepository) Authenticate(username, password string) (*LDAPIdentity, error) {
+	// An empty password makes an unauthenticated bind, which succeeds for any DN.
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() // nolint: errcheck
+
+	dn, err := r.userDN(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind as user: %w", err)
+	}
+	// The entries are read by the service account when there is one, since users may not be allowed to read them.
+	if err := r.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	attributes := []string{r.EmailAttribute}
+	if r.GroupAttribute != "" {
+		attributes = append(attributes, r.GroupAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", attributes, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user entry: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, fmt.Errorf("failed to read user entry: %d entries found", len(result.Entries))
+	}
+	entry := result.Entries[0]
+
+	identity := &LDAPIdentity{
+		DN:    entry.DN,
+		Email: entry.GetAttributeValue(r.EmailAttribute),
+	}
+	if identity.Email == "" {
+		identity.Email = username
+	}
+	if r.GroupAttribute != "" {
+		identity.Groups = entry.GetAttributeValues(r.GroupAttribute)
+	}
+	if r.GroupSearchBase != "" {
+		groups, err := r.searchGroups(conn, username, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		identity.Groups = append(identity.Groups, groups...)
+	}
+	return identity, nil
+}
+
+// dial connects to the LDAP server, upgrading the connection to TLS if StartTLS is set.
+func (r *LDAPRepository) dial() (*ldap.Conn, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultLDAPTimeout
+	}
+	tlsConfig, err := r.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ldap.DialURL(r.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	conn.SetTimeout(timeout)
+	if r.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close() // nolint: errcheck
+			return nil, fmt.Errorf("failed to start TLS with LDAP server: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// tlsConfig returns the TLS configuration of the connections, which verifies the certificate of the server for its host.
+func (r *LDAPRepository) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if r.TLSConfig != nil {
+		tlsConfig = r.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+		}
+		tlsConfig.ServerName = u.Hostname()
+	}
+	return tlsConfig, nil
+}
+
+// bindService binds as the service account, if there is one.
+func (r *LDAPRepository) bindService(conn *ldap.Conn) error {
+	if r.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(r.BindDN, r.BindPassword); err != nil {
+		return fmt.Errorf("failed to bind as service account: %w", err)
+	}
+	return nil
+}
+
+// userDN returns the DN of the entry of the user with username, from UserDNTemplate or by searching the directory.
+func (r *LDAPRepository) userDN(conn *ldap.Conn, username string) (string, error) {
+	if r.UserDNTemplate != "" {
+		return strings.ReplaceAll(r.UserDNTemplate, LDAPUsernamePlaceholder, ldap.EscapeDN(username)), nil
+	}
+	if err := r.bindService(conn); err != nil {
+		return "", err
+	}
+	filter := strings.ReplaceAll(r.UserFilter, LDAPUsernamePlaceholder, ldap.EscapeFilter(username))
+	result, err := conn.Search(ldap.NewSearchRequest(r.UserSearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{"dn"}, nil))
+	if err != nil {
+		return "", fmt.Errorf("failed to search for user: %w", err)
+	}
+	// Users that don't exist, or aren't unique, fail the same way as wrong passwords.
+	if len(result.Entries) != 1 {
+		return "", ErrLDAPInvalidCredentials
+	}
+	return result.Entries[0].DN, nil
+}
+
+// searchGroups returns the DNs of the groups of the user with username and dn.
+func (r *LDAPRepository) searchGroups(conn *ldap.Conn, username, dn string) ([]string, error) {
+	filter := strings.NewReplacer(
+		LDAPDNPlaceholder, ldap.EscapeFilter(dn),
+		LDAPUsernamePlaceholder, ldap.EscapeFilter(username),
+	).Replace(r.GroupFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(r.GroupSearchBase, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{"dn"}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for groups: %w", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
@@ -0,0 +1,122 @@
+package authentication_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+const (
+	ldapServiceDN = "cn=notary,ou=services,dc=example,dc=com"
+	ldapAliceDN   = "uid=alice,ou=people,dc=example,dc=com"
+	ldapBobDN     = "uid=bob,ou=people,dc=example,dc=com"
+	ldapAdminsDN  = "cn=notary-admins,ou=groups,dc=example,dc=com"
+	ldapDevsDN    = "cn=developers,ou=groups,dc=example,dc=com"
+)
+
+func mustStartTestLDAPServer(t *testing.T) *tu.LDAPServer {
+	t.Helper()
+	return tu.MustStartLDAPServer(t,
+		tu.LDAPEntry{DN: ldapServiceDN, Password: "service-password"},
+		tu.LDAPEntry{DN: ldapAliceDN, Password: "alice-password", Attributes: map[string][]string{
+			"uid":      {"alice"},
+			"mail":     {"alice@example.com"},
+			"memberOf": {ldapAdminsDN, ldapDevsDN},
+		}},
+		tu.LDAPEntry{DN: ldapBobDN, Password: "bob-password", Attributes: map[string][]string{
+			"uid": {"bob"},
+		}},
+		tu.LDAPEntry{DN: ldapAdminsDN, Attributes: map[string][]string{"member": {ldapAliceDN}}},
+		tu.LDAPEntry{DN: ldapDevsDN, Attributes: map[string][]string{"member": {ldapAliceDN, ldapBobDN}}},
+	)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	server := mustStartTestLDAPServer(t)
+	directBind := &authentication.LDAPRepository{
+		URL:            server.URL,
+		UserDNTemplate: "uid={username},ou=people,dc=example,dc=com",
+		EmailAttribute: "mail",
+		GroupAttribute: "memberOf",
+	}
+	searchThenBind := &authentication.LDAPRepository{
+		URL:             server.URL,
+		BindDN:          ldapServiceDN,
+		BindPassword:    "service-password",
+		UserSearchBase:  "ou=people,dc=example,dc=com",
+		UserFilter:      "(&(uid={username})(objectClass=*))",
+		EmailAttribute:  "mail",
+		GroupSearchBase: "ou=groups,dc=example,dc=com",
+		GroupFilter:     "(member={dn})",
+	}
+	cases := []struct {
+		desc       string
+		repo       *authentication.LDAPRepository
+		username   string
+		password   string
+		wantDN     string
+		wantEmail  string
+		wantGroups []string
+		wantErr    error
+	}{
+		{"direct bind", directBind, "alice", "alice-password", ldapAliceDN, "alice@example.com", []string{ldapDevsDN, ldapAdminsDN}, nil},
+		{"direct bind without email", directBind, "bob", "bob-password", ldapBobDN, "bob", nil, nil},
+		{"direct bind with wrong password", directBind, "alice", "bob-password", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+		{"direct bind of unknown user", directBind, "carol", "alice-password", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+		{"empty password", directBind, "alice", "", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+		{"search then bind", searchThenBind, "alice", "alice-password", ldapAliceDN, "alice@example.com", []string{ldapDevsDN, ldapAdminsDN}, nil},
+		{"search then bind with group search", searchThenBind, "bob", "bob-password", ldapBobDN, "bob", []string{ldapDevsDN}, nil},
+		{"search then bind with wrong password", searchThenBind, "bob", "alice-password", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+		{"search of unknown user", searchThenBind, "carol", "alice-password", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+		{"filter injection", searchThenBind, "*", "alice-password", "", "", nil, authentication.ErrLDAPInvalidCredentials},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			identity, err := tc.repo.Authenticate(tc.username, tc.password)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("couldn't authenticate: %s", err)
+			}
+			if identity.DN != tc.wantDN || identity.Email != tc.wantEmail {
+				t.Fatalf("expected DN %q and email %q, got %+v", tc.wantDN, tc.wantEmail, identity)
+			}
+			slices.Sort(identity.Groups)
+			if !slices.Equal(identity.Groups, tc.wantGroups) {
+				t.Fatalf("expected groups %v, got %v", tc.wantGroups, identity.Groups)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateStartTLS(t *testing.T) {
+	server := mustStartTestLDAPServer(t)
+	repo := &authentication.LDAPRepository{
+		URL:            server.URL,
+		StartTLS:       true,
+		TLSConfig:      &tls.Config{RootCAs: server.RootCAs, MinVersion: tls.VersionTLS12},
+		UserDNTemplate: "uid={username},ou=people,dc=example,dc=com",
+		EmailAttribute: "mail",
+	}
+	if _, err := repo.Authenticate("alice", "alice-password"); err != nil {
+		t.Fatalf("couldn't authenticate: %s", err)
+	}
+	binds := server.Binds()
+	if len(binds) != 1 || binds[0].DN != ldapAliceDN || !binds[0].TLS {
+		t.Fatalf("expected a bind of alice over TLS, got %+v", binds)
+	}
+
+	untrusted := *repo
+	untrusted.TLSConfig = nil
+	if _, err := untrusted.Authenticate("alice", "alice-password"); err == nil || errors.Is(err, authentication.ErrLDAPInvalidCredentials) {
+		t.Fatalf("expected a TLS error for an untrusted server, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const passwordTokenBytes = 32
+
+// GeneratePasswordToken returns a new token that lets a user set their password, along with its hash.
+func GeneratePasswordToken() (token, hashedToken string, err error) {
+	b := make([]byte, passwordTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate password token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashPasswordToken(token), nil
+}
+
+// HashPasswordToken returns the hash of a password token.
+// The tokens are random, so a fast hash is enough to keep them from being read back.
+func HashPasswordToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
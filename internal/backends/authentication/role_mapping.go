@@ -0,0 +1,78 @@
+package authentication
+
+import (
+	"strings"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+// RoleMapping maps values from an identity provider, such as the groups of the users, to Notary roles.
+// It is applied on every OIDC or LDAP login.
+type RoleMapping struct {
+	// ClaimKey is the claim of the OIDC ID tokens whose values are mapped. Nested claims are separated by dots,
+	// such as realm_access.roles.
+	ClaimKey string
+	// Roles maps the values to the roles that they grant.
+	Roles map[string]db.RoleID
+	// DefaultRole is the role of the users with none of the values, or nil when they can't log in.
+	DefaultRole *db.RoleID
+}
+
+// Role returns the role that the claims of an ID token map to. When several values of the claim are mapped,
+// the most privileged of their roles is returned. It returns false when the claims map to no role.
+func (m *RoleMapping) Role(claims map[string]any) (db.RoleID, bool) {
+	return m.RoleOf(claimValues(claims, m.ClaimKey))
+}
+
+// RoleOf returns the role that values map to, such as the groups of an LDAP user. When several values are mapped,
+// the most privileged of their roles is returned. It returns false when the values map to no role.
+func (m *RoleMapping) RoleOf(values []string) (db.RoleID, bool) {
+	found := false
+	var role db.RoleID
+	for _, value := range values {
+		mapped, ok := m.Roles[value]
+		if !ok {
+			continue
+		}
+		// The role IDs go from the most privileged, admin, to the least, reader.
+		if !found || mapped < role {
+			role = mapped
+			found = true
+		}
+	}
+	if found {
+		return role, true
+	}
+	if m.DefaultRole != nil {
+		return *m.DefaultRole, true
+	}
+	return 0, false
+}
+
+// claimValues returns the string values of the claim at the dotted path key, whether it is a single string or a list.
+func claimValues(claims map[string]any, key string) []string {
+	var value any = claims
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
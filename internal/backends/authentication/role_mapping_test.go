@@ -0,0 +1,68 @@
+package authentication_test
+
+import (
+	"testing"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/db"
+)
+
+func TestRoleMappingClaims(t *testing.T) {
+	reader := db.RoleReadOnly
+	mapping := &authentication.RoleMapping{
+		ClaimKey: "realm_access.roles",
+		Roles: map[string]db.RoleID{
+			"notary-admins":   db.RoleAdmin,
+			"pki-team":        db.RoleCertificateManager,
+			"developers":      db.RoleCertificateRequestor,
+			"single-value-ok": db.RoleCertificateRequestor,
+		},
+	}
+	withRoles := func(roles any) map[string]any {
+		return map[string]any{"realm_access": map[string]any{"roles": roles}}
+	}
+	cases := []struct {
+		desc        string
+		claims      map[string]any
+		defaultRole *db.RoleID
+		wantRole    db.RoleID
+		wantOK      bool
+	}{
+		{"single mapped value", withRoles([]any{"developers"}), nil, db.RoleCertificateRequestor, true},
+		{"most privileged value wins", withRoles([]any{"developers", "notary-admins", "pki-team"}), nil, db.RoleAdmin, true},
+		{"string claim", withRoles("single-value-ok"), nil, db.RoleCertificateRequestor, true},
+		{"unmapped values are ignored", withRoles([]any{"marketing", 42, "pki-team"}), nil, db.RoleCertificateManager, true},
+		{"no mapped value", withRoles([]any{"marketing"}), nil, 0, false},
+		{"missing claim", map[string]any{"sub": "123"}, nil, 0, false},
+		{"claim that isn't nested as configured", map[string]any{"realm_access": "notary-admins"}, nil, 0, false},
+		{"default role", withRoles([]any{"marketing"}), &reader, db.RoleReadOnly, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mapping.DefaultRole = tc.defaultRole
+			role, ok := mapping.Role(tc.claims)
+			if ok != tc.wantOK || role != tc.wantRole {
+				t.Fatalf("Role() = %d, %t, want %d, %t", role, ok, tc.wantRole, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestRoleMappingValues(t *testing.T) {
+	mapping := &authentication.RoleMapping{
+		Roles: map[string]db.RoleID{
+			"cn=notary-admins,ou=groups,dc=example,dc=com": db.RoleAdmin,
+			"cn=developers,ou=groups,dc=example,dc=com":    db.RoleCertificateRequestor,
+		},
+	}
+	role, ok := mapping.RoleOf([]string{"cn=developers,ou=groups,dc=example,dc=com", "cn=notary-admins,ou=groups,dc=example,dc=com"})
+	if !ok || role != db.RoleAdmin {
+		t.Fatalf("RoleOf() = %d, %t, want %d, true", role, ok, db.RoleAdmin)
+	}
+	if role, ok := mapping.RoleOf([]string{"cn=marketing,ou=groups,dc=example,dc=com"}); ok {
+		t.Fatalf("RoleOf() = %d, true, want no role", role)
+	}
+	if role, ok := mapping.RoleOf(nil); ok {
+		t.Fatalf("RoleOf() = %d, true, want no role", role)
+	}
+}
@@ -0,0 +1,56 @@
+package authentication
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+)
+
+const sessionIDBytes = 24
+
+var (
+	ErrRevokedSession = errors.New("session is revoked")
+	ErrExpiredSession = errors.New("session is expired")
+)
+
+// GenerateSessionID returns a new random session ID, for the token of a session to reference.
+func GenerateSessionID() (string, error) {
+	id := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// VerifySession returns the session that the claims of a local token reference, if it still exists and is valid at now.
+// Tokens that don't reference a session were issued before sessions were stored, and are treated as revoked.
+func VerifySession(database *db.DatabaseRepository, claims *NotaryJWTClaims, now time.Time) (*db.Session, error) {
+	if claims.ID == "" {
+		return nil, ErrRevokedSession
+	}
+	session, err := database.GetSessionBySessionID(claims.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrRevokedSession
+		}
+		return nil, err
+	}
+	if now.Unix() >= session.ExpiresAt {
+		return nil, ErrExpiredSession
+	}
+	user, err := database.GetUser(db.ByUserID(session.UserID))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrRevokedSession
+		}
+		return nil, err
+	}
+	if user.Email != claims.Email {
+		return nil, ErrRevokedSession
+	}
+	return session, nil
+}
@@ -0,0 +1,291 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The algorithms of the keys that sign the session tokens.
+const (
+	SigningAlgorithmES256 = "ES256"
+	SigningAlgorithmEdDSA = "EdDSA"
+)
+
+var ErrUnknownSigningKey = errors.New("unknown or expired signing key")
+
+// SigningKeyring signs the tokens of Notary with its newest key, and verifies them with the key named by their kid header.
+// The keys that were rotated out keep verifying tokens for the grace period, so that rotations don't end the sessions.
+type SigningKeyring struct {
+	database *db.DatabaseRepository
+	// algorithm is the algorithm of the keys created from now on. The keys keep the algorithm they were created with.
+	algorithm        string
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+
+	mu sync.RWMutex
+	// keys are the keys that can verify tokens, the newest first. The first key signs the tokens.
+	keys []*signingKey
+}
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signer    crypto.Signer
+	createdAt time.Time
+	// retiredAt is when the key was rotated out, or the zero time while it signs the tokens.
+	retiredAt time.Time
+}
+
+// SigningKeyInfo describes a signing key, without its private key.
+type SigningKeyInfo struct {
+	KID       string
+	Algorithm string
+	CreatedAt time.Time
+	// RetiredAt is when the key was rotated out, or the zero time while it signs the tokens.
+	RetiredAt time.Time
+	// ExpiresAt is when the key stops verifying tokens, or the zero time while it signs the tokens.
+	ExpiresAt time.Time
+}
+
+// NewSigningKeyring loads the signing keys from the database, and creates the first key when there is none.
+// A rotationInterval of 0 disables scheduled rotations.
+func NewSigningKeyring(database *db.DatabaseRepository, algorithm string, rotationInterval, gracePeriod time.Duration) (*SigningKeyring, error) {
+	if signingMethod(algorithm) == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	k := &SigningKeyring{
+		database:         database,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		gracePeriod:      gracePeriod,
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+	if len(k.keys) == 0 {
+		if _, err := k.rotate(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Sign returns a token with claims, signed with the newest key.
+func (k *SigningKeyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	key := k.keys[0]
+	k.mu.RUnlock()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.signer)
+}
+
+// Parse verifies the token with the key named by its kid header, and parses its claims.
+func (k *SigningKeyring) Parse(raw string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{SigningAlgorithmES256, SigningAlgorithmEdDSA}))
+	return jwt.ParseWithClaims(raw, claims, k.keyFunc, opts...)
+}
+
+func (k *SigningKeyring) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.kid != kid || !k.verifies(key, now) {
+			continue
+		}
+		if t.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("token algorithm %s doesn't match signing key %s", t.Method.Alg(), kid)
+		}
+		return key.signer.Public(), nil
+	}
+	return nil, ErrUnknownSigningKey
+}
+
+// verifies returns whether the key still verifies tokens at now.
+func (k *SigningKeyring) verifies(key *signingKey, now time.Time) bool {
+	return key.retiredAt.IsZero() || now.Before(key.retiredAt.Add(k.gracePeriod))
+}
+
+// Rotate creates a new key that signs the tokens from now on. The previous key keeps verifying tokens for the grace period.
+// It returns the kid of the new key.
+func (k *SigningKeyring) Rotate(now time.Time) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.rotate(now)
+}
+
+// RotateIfDue rotates the keys when the newest key is older than the rotation interval, and deletes the keys whose
+// grace period is over. It returns the kid of the new key, or an empty string when the keys weren't rotated.
+func (k *SigningKeyring) RotateIfDue(now time.Time) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.rotationInterval > 0 && now.Sub(k.keys[0].createdAt) >= k.rotationInterval {
+		return k.rotate(now)
+	}
+	if err := k.database.DeleteRetiredSigningKeys(now.Add(-k.gracePeriod)); err != nil {
+		return "", err
+	}
+	return "", k.load()
+}
+
+// rotate creates a new key and deletes the keys whose grace period is over. The caller must hold the write lock.
+func (k *SigningKeyring) rotate(now time.Time) (string, error) {
+	kid, privateKeyPEM, err := generateSigningKey(k.algorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := k.database.CreateSigningKey(kid, k.algorithm, privateKeyPEM, now); err != nil {
+		return "", fmt.Errorf("failed to store signing key: %w", err)
+	}
+	if err := k.database.DeleteRetiredSigningKeys(now.Add(-k.gracePeriod)); err != nil {
+		return "", err
+	}
+	return kid, k.load()
+}
+
+// load reads the keys from the database. The caller must hold the write lock.
+func (k *SigningKeyring) load() error {
+	rows, err := k.database.ListSigningKeys()
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	keys := make([]*signingKey, 0, len(rows))
+	for _, row := range rows {
+		key, err := parseSigningKey(row)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	k.keys = keys
+	return nil
+}
+
+// Keys describes the keys that verify tokens, the newest first.
+func (k *SigningKeyring) Keys() []SigningKeyInfo {
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var infos []SigningKeyInfo
+	for _, key := range k.keys {
+		if !k.verifies(key, now) {
+			continue
+		}
+		info := SigningKeyInfo{
+			KID:       key.kid,
+			Algorithm: key.method.Alg(),
+			CreatedAt: key.createdAt,
+			RetiredAt: key.retiredAt,
+		}
+		if !key.retiredAt.IsZero() {
+			info.ExpiresAt = key.retiredAt.Add(k.gracePeriod)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// JWKS returns the public keys that verify tokens, for other services to verify the tokens of Notary.
+func (k *SigningKeyring) JWKS() jose.JSONWebKeySet {
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	for _, key := range k.keys {
+		if !k.verifies(key, now) {
+			continue
+		}
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       key.signer.Public(),
+			KeyID:     key.kid,
+			Algorithm: key.method.Alg(),
+			Use:       "sig",
+		})
+	}
+	return set
+}
+
+func signingMethod(algorithm string) jwt.SigningMethod {
+	switch algorithm {
+	case SigningAlgorithmES256:
+		return jwt.SigningMethodES256
+	case SigningAlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return nil
+	}
+}
+
+// generateSigningKey returns a new private key in PEM, and its kid, which is the RFC 7638 thumbprint of its public key.
+func generateSigningKey(algorithm string) (string, string, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case SigningAlgorithmES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case SigningAlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", "", fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	thumbprint, err := (&jose.JSONWebKey{Key: signer.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to compute signing key ID: %w", err)
+	}
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return base64.RawURLEncoding.EncodeToString(thumbprint), string(privateKeyPEM), nil
+}
+
+func parseSigningKey(row db.SigningKey) (*signingKey, error) {
+	method := signingMethod(row.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("signing key %s has unsupported algorithm %s", row.KID, row.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(row.PrivateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", row.KID)
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", row.KID, err)
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s can't sign", row.KID)
+	}
+	key := &signingKey{
+		kid:       row.KID,
+		method:    method,
+		signer:    signer,
+		createdAt: time.Unix(row.CreatedAt, 0),
+	}
+	if row.RetiredAt != 0 {
+		key.retiredAt = time.Unix(row.RetiredAt, 0)
+	}
+	return key, nil
+}
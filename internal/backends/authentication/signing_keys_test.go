@@ -0,0 +1,139 @@
+package authentication_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func mustSign(t *testing.T, keyring *authentication.SigningKeyring, subject string) string {
+	t.Helper()
+	token, err := keyring.Sign(jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	if err != nil {
+		t.Fatalf("couldn't sign token: %s", err)
+	}
+	return token
+}
+
+func parseSubject(keyring *authentication.SigningKeyring, token string) (string, error) {
+	claims := jwt.RegisteredClaims{}
+	if _, err := keyring.Parse(token, &claims); err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func TestSigningKeyringSignsAndVerifies(t *testing.T) {
+	for _, algorithm := range []string{authentication.SigningAlgorithmES256, authentication.SigningAlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			database := tu.MustPrepareEmptyDB(t)
+			keyring, err := authentication.NewSigningKeyring(database, algorithm, 0, time.Hour)
+			if err != nil {
+				t.Fatalf("couldn't create keyring: %s", err)
+			}
+			token := mustSign(t, keyring, "alice@example.com")
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("couldn't parse token: %s", err)
+			}
+			jwks := keyring.JWKS()
+			if len(jwks.Keys) != 1 || parsed.Header["kid"] != jwks.Keys[0].KeyID || parsed.Method.Alg() != algorithm || jwks.Keys[0].Algorithm != algorithm {
+				t.Fatalf("expected the token to name the published key, got header %v and keys %+v", parsed.Header, jwks.Keys)
+			}
+			if !jwks.Keys[0].IsPublic() {
+				t.Fatalf("expected only the public key to be published")
+			}
+			if subject, err := parseSubject(keyring, token); err != nil || subject != "alice@example.com" {
+				t.Fatalf("expected the token to verify, got %q, %v", subject, err)
+			}
+
+			// The keys are kept in the database, so the tokens verify after a restart.
+			reloaded, err := authentication.NewSigningKeyring(database, algorithm, 0, time.Hour)
+			if err != nil {
+				t.Fatalf("couldn't reload keyring: %s", err)
+			}
+			if _, err := parseSubject(reloaded, token); err != nil {
+				t.Fatalf("expected the token to verify after a reload, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSigningKeyringRotation(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	keyring, err := authentication.NewSigningKeyring(database, authentication.SigningAlgorithmES256, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("couldn't create keyring: %s", err)
+	}
+	now := time.Now()
+	first := mustSign(t, keyring, "first")
+
+	if kid, err := keyring.RotateIfDue(now); err != nil || kid != "" {
+		t.Fatalf("expected a new key not to be rotated, got %q, %v", kid, err)
+	}
+
+	// A key that was rotated out longer than the grace period ago no longer verifies tokens.
+	if _, err := keyring.Rotate(now.Add(-2 * time.Hour)); err != nil {
+		t.Fatalf("couldn't rotate keys: %s", err)
+	}
+	if _, err := parseSubject(keyring, first); !errors.Is(err, authentication.ErrUnknownSigningKey) {
+		t.Fatalf("expected a token of an expired key to be rejected, got %v", err)
+	}
+	second := mustSign(t, keyring, "second")
+
+	// The previous key keeps verifying tokens during the grace period.
+	kid, err := keyring.RotateIfDue(now)
+	if err != nil || kid == "" {
+		t.Fatalf("expected a key older than the rotation interval to be rotated, got %q, %v", kid, err)
+	}
+	if _, err := parseSubject(keyring, second); err != nil {
+		t.Fatalf("expected a token of the previous key to verify, got %v", err)
+	}
+	third := mustSign(t, keyring, "third")
+	if _, err := parseSubject(keyring, third); err != nil {
+		t.Fatalf("expected a token of the new key to verify, got %v", err)
+	}
+	keys := keyring.Keys()
+	if len(keys) != 2 || keys[0].KID != kid || !keys[0].RetiredAt.IsZero() || keys[1].ExpiresAt.IsZero() {
+		t.Fatalf("expected the new key and the previous key, got %+v", keys)
+	}
+	if len(keyring.JWKS().Keys) != 2 {
+		t.Fatalf("expected the keys in their grace period to be published, got %+v", keyring.JWKS().Keys)
+	}
+
+	// The keys whose grace period is over are deleted, which leaves the newest two keys.
+	if _, err := keyring.RotateIfDue(now.Add(2 * time.Hour)); err != nil {
+		t.Fatalf("RotateIfDue() unexpected error: %s", err)
+	}
+	if rows, _ := database.ListSigningKeys(); len(rows) != 2 {
+		t.Fatalf("expected the expired keys to be deleted, got %d keys", len(rows))
+	}
+	if _, err := parseSubject(keyring, second); err == nil {
+		t.Fatalf("expected a token of a deleted key to be rejected")
+	}
+}
+
+func TestSigningKeyringRejectsOtherAlgorithms(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	keyring, err := authentication.NewSigningKeyring(database, authentication.SigningAlgorithmES256, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("couldn't create keyring: %s", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: "mallory"})
+	token.Header["kid"] = keyring.JWKS().Keys[0].KeyID
+	raw, err := token.SignedString([]byte{})
+	if err != nil {
+		t.Fatalf("couldn't sign token: %s", err)
+	}
+	if _, err := parseSubject(keyring, raw); err == nil {
+		t.Fatalf("expected an HMAC token to be rejected")
+	}
+
+	if _, err := authentication.NewSigningKeyring(database, "HS256", 0, time.Hour); err == nil {
+		t.Fatalf("expected an unsupported algorithm to fail")
+	}
+}
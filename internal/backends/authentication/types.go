@@ -10,6 +10,8 @@ import (
 type NotaryJWTClaims struct {
 	Email  string `json:"email"`
 	RoleID int    `json:"role_id"`
+	// Provider is the provider that issued the token. Only the tokens issued by Notary itself reference a session.
+	Provider ProviderType `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -29,7 +31,7 @@ const (
 type ProviderConfig struct {
 	Type     ProviderType
 	Provider *OIDCRepository // for OIDC key verification
-	Secret   []byte          // for HMAC local tokens (if used)
+	Keyring  *SigningKeyring // for the tokens signed by Notary
 }
 
 type Verifier struct {
@@ -50,6 +52,9 @@ type OIDCRepository struct {
 	EmailClaimKey string
 	// This is the key for the permissions claim in the access token
 	PermissionsClaimKey string
+	// RoleMapping maps the claims of the ID token to the role of the user on every login.
+	// It is nil when the users keep the role they were given, which is read-only for the users that aren't the first.
+	RoleMapping *RoleMapping
 	// This is the key function for verifying the access token coming from the IDP
 	KeyFunc keyfunc.Keyfunc
 }
@@ -43,12 +43,13 @@ func UserID(email string) string {
 // SQLite connection. dbPath is the filesystem path to the SQLite database.
 func InitializeLocalOpenFGA(database *db.DatabaseRepository, logger *zap.Logger) (*AuthzRepository, error) {
 	// Run OpenFGA's SQLite schema migrations on the shared DB connection.
+	// They share the goose version table with Notary's migrations, which can be ahead of them.
 	goose.SetLogger(goose.NopLogger())
 	goose.SetBaseFS(assets.EmbedMigrations)
 	if err := goose.SetDialect("sqlite"); err != nil {
 		return nil, fmt.Errorf("failed to set goose dialect: %w", err)
 	}
-	if err := goose.Up(database.Conn.PlainDB(), assets.SqliteMigrationDir, goose.WithNoColor(true)); err != nil {
+	if err := goose.Up(database.Conn.PlainDB(), assets.SqliteMigrationDir, goose.WithNoColor(true), goose.WithAllowMissing()); err != nil {
 		return nil, fmt.Errorf("failed to run OpenFGA migrations: %w", err)
 	}
 
@@ -143,6 +144,29 @@ func (r *AuthzRepository) DeleteTuple(object, relation, user string) error {
 	return err
 }
 
+// ReplaceTuple replaces the relation of user on object from one relation to another, in a single write that either
+// changes both tuples or none. A missing tuple of the old relation, or an existing one of the new relation, isn't an
+// error, so that tuples that drifted from the roles are repaired.
+func (r *AuthzRepository) ReplaceTuple(object, from, to, user string) error {
+	_, err := r.FGAClient.Write(context.Background(), &openfgav1.WriteRequest{
+		StoreId:              r.StoreID,
+		AuthorizationModelId: r.AuthorizationModelID,
+		Writes: &openfgav1.WriteRequestWrites{
+			TupleKeys: []*openfgav1.TupleKey{
+				{Object: object, Relation: to, User: user},
+			},
+			OnDuplicate: "ignore",
+		},
+		Deletes: &openfgav1.WriteRequestDeletes{
+			TupleKeys: []*openfgav1.TupleKeyWithoutCondition{
+				{Object: object, Relation: from, User: user},
+			},
+			OnMissing: "ignore",
+		},
+	})
+	return err
+}
+
 // Check returns whether user has relation on object.
 func (r *AuthzRepository) Check(object, relation, user string) (bool, error) {
 	resp, err := r.FGAClient.Check(context.Background(), &openfgav1.CheckRequest{
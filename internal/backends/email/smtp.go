@@ -0,0 +1,137 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The ways the connections to the SMTP server are secured.
+const (
+	// SMTPSecurityStartTLS upgrades the connections to TLS with STARTTLS, and fails if the server doesn't support it.
+	SMTPSecurityStartTLS = "starttls"
+	// SMTPSecurityTLS connects with TLS from the start, usually on port 465.
+	SMTPSecurityTLS = "tls"
+	// SMTPSecurityNone sends the emails in plain text, which is only meant for local relays.
+	SMTPSecurityNone = "none"
+)
+
+// DefaultSMTPTimeout bounds the delivery of an email when no timeout is configured.
+const DefaultSMTPTimeout = 30 * time.Second
+
+var ErrInvalidHeader = errors.New("invalid email header")
+
+// SMTPSender sends emails through an SMTP server.
+type SMTPSender struct {
+	Host string
+	Port int
+	// Username and Password authenticate to the server with PLAIN authentication. No authentication is made when Username is empty.
+	Username string
+	Password string
+	// From is the address that the emails are sent from.
+	From string
+	// Security is how the connections are secured: one of the SMTPSecurity constants.
+	Security string
+	// TLSConfig verifies the certificate of the server. The certificate is verified for Host when it is nil.
+	TLSConfig *tls.Config
+	// Timeout bounds the delivery of an email.
+	Timeout time.Duration
+}
+
+// Send sends a plain text email to the address to.
+func (s *SMTPSender) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close() // nolint: errcheck
+
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate to SMTP server: %w", err)
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(message(s.From, to, subject, body)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the SMTP server and secures the connection as configured.
+func (s *SMTPSender) dial() (*smtp.Client, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	tlsConfig := &tls.Config{ServerName: s.Host, MinVersion: tls.VersionTLS12}
+	if s.TLSConfig != nil {
+		tlsConfig = s.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = s.Host
+		}
+	}
+	address := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if s.Security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if s.Security == SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close() // nolint: errcheck
+			return nil, fmt.Errorf("failed to start TLS with SMTP server: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// message formats a plain text email with its headers.
+func message(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,79 @@
+package email_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/backends/email"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestSMTPSend(t *testing.T) {
+	server := tu.MustStartSMTPServer(t)
+	cases := []struct {
+		desc     string
+		sender   *email.SMTPSender
+		wantTLS  bool
+		wantUser string
+	}{
+		{"plain text", &email.SMTPSender{
+			Host:     server.Host,
+			Port:     server.Port,
+			From:     "notary@example.com",
+			Security: email.SMTPSecurityNone,
+		}, false, ""},
+		{"starttls with authentication", &email.SMTPSender{
+			Host:      server.Host,
+			Port:      server.Port,
+			Username:  "notary",
+			Password:  "smtp-password",
+			From:      "notary@example.com",
+			Security:  email.SMTPSecurityStartTLS,
+			TLSConfig: &tls.Config{RootCAs: server.RootCAs, MinVersion: tls.VersionTLS12},
+		}, true, "notary"},
+	}
+	for i, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if err := tc.sender.Send("alice@example.com", "Welcome to Notary", "Hello,\nwelcome."); err != nil {
+				t.Fatalf("couldn't send email: %s", err)
+			}
+			messages := server.Messages()
+			if len(messages) != i+1 {
+				t.Fatalf("expected %d messages, got %d", i+1, len(messages))
+			}
+			message := messages[i]
+			if message.From != "notary@example.com" || !slices.Equal(message.To, []string{"alice@example.com"}) {
+				t.Fatalf("unexpected envelope %+v", message)
+			}
+			if message.TLS != tc.wantTLS || message.Username != tc.wantUser {
+				t.Fatalf("expected TLS %t and user %q, got %+v", tc.wantTLS, tc.wantUser, message)
+			}
+			if !strings.Contains(message.Data, "Subject: Welcome to Notary\r\n") || !strings.HasSuffix(message.Data, "\r\n\r\nHello,\r\nwelcome.\r\n") {
+				t.Fatalf("unexpected message %q", message.Data)
+			}
+		})
+	}
+}
+
+func TestSMTPSendErrors(t *testing.T) {
+	server := tu.MustStartSMTPServer(t)
+	sender := &email.SMTPSender{
+		Host:     server.Host,
+		Port:     server.Port,
+		From:     "notary@example.com",
+		Security: email.SMTPSecurityStartTLS,
+	}
+
+	if err := sender.Send("alice@example.com\r\nBcc: eve@example.com", "Welcome", "Hello"); !errors.Is(err, email.ErrInvalidHeader) {
+		t.Fatalf("expected a header injection to fail with ErrInvalidHeader, got %v", err)
+	}
+	if err := sender.Send("alice@example.com", "Welcome", "Hello"); err == nil {
+		t.Fatalf("expected an untrusted server certificate to fail")
+	}
+	if len(server.Messages()) != 0 {
+		t.Fatalf("expected no message to be sent, got %+v", server.Messages())
+	}
+}
@@ -54,6 +54,22 @@ func (a *AuditLogger) LoginFailed(username string, opts ...AuditOption) {
 	a.logger.Warn(fmt.Sprintf("User %s login failed", username), fields...)
 }
 
+// LoginUnlocked logs when the lockout of an account or an IP address after failed login attempts is lifted by an admin.
+func (a *AuditLogger) LoginUnlocked(scope, identifier string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_login_unlocked:%s,%s", scope, identifier)),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Login lockout of %s %s lifted", scope, identifier), fields...)
+}
+
 // TokenCreated logs when a JWT authentication token is created.
 func (a *AuditLogger) TokenCreated(username string, opts ...AuditOption) {
 	ctx := &auditContext{severity: SeverityInfo}
@@ -328,6 +344,191 @@ func (a *AuditLogger) UserUpdated(username, updateType string, opts ...AuditOpti
 	a.logger.Warn(description, fields...)
 }
 
+// API Token Events
+
+// APITokenCreated logs when an API token is created for a service account.
+func (a *AuditLogger) APITokenCreated(username, tokenName string, scopes []string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_api_token_created:%s,%s", username, tokenName)),
+		zap.String("username", username),
+		zap.String("token_name", tokenName),
+		zap.Strings("scopes", scopes),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("API token %s created for %s", tokenName, username), fields...)
+}
+
+// APITokenRevoked logs when an API token of a service account is revoked.
+func (a *AuditLogger) APITokenRevoked(username, tokenName string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_api_token_revoked:%s,%s", username, tokenName)),
+		zap.String("username", username),
+		zap.String("token_name", tokenName),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("API token %s of %s revoked", tokenName, username), fields...)
+}
+
+// APITokenRejected logs when a request presents an API token that is unknown, expired, or doesn't allow the request.
+func (a *AuditLogger) APITokenRejected(tokenID string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_api_token_rejected:%s", tokenID)),
+		zap.String("token_id", tokenID),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn("API token rejected", fields...)
+}
+
+// Multi-Factor Authentication Events
+
+// MFAEnrolled logs when a user enables TOTP multi-factor authentication.
+func (a *AuditLogger) MFAEnrolled(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_mfa_enrolled:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Multi-factor authentication enabled for %s", username), fields...)
+}
+
+// MFADisabled logs when the TOTP multi-factor authentication of a user is disabled or reset.
+func (a *AuditLogger) MFADisabled(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_mfa_disabled:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Multi-factor authentication disabled for %s", username), fields...)
+}
+
+// MFASuccess logs when a user passes multi-factor authentication, with a TOTP code or a recovery code.
+func (a *AuditLogger) MFASuccess(username, method string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_mfa_success:%s,%s", username, method)),
+		zap.String("username", username),
+		zap.String("method", method),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info(fmt.Sprintf("Multi-factor authentication of %s succeeded with %s", username, method), fields...)
+}
+
+// MFAFailed logs when a user fails multi-factor authentication.
+func (a *AuditLogger) MFAFailed(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_mfa_failed:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Multi-factor authentication of %s failed", username), fields...)
+}
+
+// Session Events
+
+// SessionRevoked logs when a session of a user is revoked, which logs it out.
+func (a *AuditLogger) SessionRevoked(username string, sessionID int64, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_session_revoked:%s,%d", username, sessionID)),
+		zap.String("username", username),
+		zap.Int64("session_id", sessionID),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Session %d of %s revoked", sessionID, username), fields...)
+}
+
+// SessionsRevoked logs when all the sessions of a user are revoked, but maybe the one the request was made with.
+func (a *AuditLogger) SessionsRevoked(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_sessions_revoked:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Sessions of %s revoked", username), fields...)
+}
+
+// Client Certificate Events
+
+// ClientCertificateRejected logs when a request presents a client certificate that isn't trusted, is revoked,
+// or doesn't match an account.
+func (a *AuditLogger) ClientCertificateRejected(subject, serialNumber string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_client_certificate_rejected:%s", serialNumber)),
+		zap.String("subject", subject),
+		zap.String("serial_number", serialNumber),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Client certificate %s rejected", subject), fields...)
+}
+
 // Access Control Events
 
 // AccessDenied logs when a user is denied access to a resource.
@@ -438,6 +639,63 @@ func (a *AuditLogger) CertificateSigned(csrID string, caID string, opts ...Audit
 	a.logger.Info("Certificate request signed by CA", fields...)
 }
 
+// CertificateRenewed logs when a certificate is automatically renewed. The owner of the certificate request
+// is included so that the owner can be notified.
+func (a *AuditLogger) CertificateRenewed(csrID string, signingMethod string, owner string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", "cert_renewed"),
+		zap.String("csr_id", csrID),
+		zap.String("signing_method", signingMethod),
+		zap.String("owner", owner),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info("Certificate automatically renewed", fields...)
+}
+
+// AutoRenewalUpdated logs when automatic renewal is turned on or off for a certificate request or a certificate authority.
+func (a *AuditLogger) AutoRenewalUpdated(resourceType string, resourceID string, enabled bool, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", "auto_renewal_updated"),
+		zap.String("resource_type", resourceType),
+		zap.String("resource_id", resourceID),
+		zap.Bool("enabled", enabled),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info("Automatic certificate renewal updated", fields...)
+}
+
+// CertificateRenewalFailed logs when the automatic renewal of a certificate fails for good.
+func (a *AuditLogger) CertificateRenewalFailed(csrID string, owner string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", "cert_renewal_fail"),
+		zap.String("csr_id", csrID),
+		zap.String("owner", owner),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn("Certificate automatic renewal failed", fields...)
+}
+
 // CertificateDeleted logs when a certificate is deleted.
 func (a *AuditLogger) CertificateDeleted(csrID string, opts ...AuditOption) {
 	ctx := &auditContext{severity: SeverityWarn}
@@ -455,6 +713,63 @@ func (a *AuditLogger) CertificateDeleted(csrID string, opts ...AuditOption) {
 	a.logger.Warn("Certificate deleted", fields...)
 }
 
+// PrivateKeyDelivered logs when a private key generated by Notary is handed out to its requestor.
+func (a *AuditLogger) PrivateKeyDelivered(csrID string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", "cert_private_key_delivered"),
+		zap.String("csr_id", csrID),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn("Generated private key delivered", fields...)
+}
+
+// Timestamping Events
+
+// TimestampIssued logs when the timestamping authority issues a timestamp token.
+func (a *AuditLogger) TimestampIssued(serialNumber string, caID int, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", "timestamp_issued"),
+		zap.String("serial_number", serialNumber),
+		zap.Int("ca_id", caID),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info("Timestamp token issued", fields...)
+}
+
+// Job Events
+
+// JobCancelled logs when a background job is cancelled.
+func (a *AuditLogger) JobCancelled(jobID string, jobType string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "audit"),
+		zap.String("event", "job_cancelled"),
+		zap.String("job_id", jobID),
+		zap.String("job_type", jobType),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info("Job cancelled", fields...)
+}
+
 // Logout logs when a user ends their authenticated session.
 func (a *AuditLogger) Logout(username string, opts ...AuditOption) {
 	ctx := &auditContext{severity: SeverityInfo}
@@ -488,6 +803,93 @@ func (a *AuditLogger) OIDCLoginFailed(reason string, opts ...AuditOption) {
 	a.logger.Warn(fmt.Sprintf("OIDC login failed: %s", reason), fields...)
 }
 
+// OIDCLinked logs when an OIDC identity is linked to an account.
+func (a *AuditLogger) OIDCLinked(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_oidc_linked:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info(fmt.Sprintf("OIDC identity linked to user %s", username), fields...)
+}
+
+// OIDCUnlinked logs when the OIDC identity of an account is unlinked.
+func (a *AuditLogger) OIDCUnlinked(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_oidc_unlinked:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("OIDC identity unlinked from user %s", username), fields...)
+}
+
+// PasswordTokenIssued logs when a token that lets a user set their password is issued, for an invitation or a reset.
+func (a *AuditLogger) PasswordTokenIssued(username, purpose string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_password_token_issued:%s,%s", username, purpose)),
+		zap.String("username", username),
+		zap.String("purpose", purpose),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Password %s token issued for user %s", purpose, username), fields...)
+}
+
+// PasswordTokenUsed logs when a user sets their password with a password token.
+func (a *AuditLogger) PasswordTokenUsed(username, purpose string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityInfo}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_password_token_used:%s,%s", username, purpose)),
+		zap.String("username", username),
+		zap.String("purpose", purpose),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Info(fmt.Sprintf("User %s set their password with a %s token", username, purpose), fields...)
+}
+
+// PasswordTokenRejected logs when an invalid, expired or already used password token is presented.
+func (a *AuditLogger) PasswordTokenRejected(username string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("authn_password_token_rejected:%s", username)),
+		zap.String("username", username),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn("Password token rejected", fields...)
+}
+
 // System Events
 
 // SystemStartup logs when the application starts.
@@ -523,6 +925,23 @@ func (a *AuditLogger) SystemShutdown(reason string, opts ...AuditOption) {
 	a.logger.Warn(fmt.Sprintf("System shutdown: %s", reason), fields...)
 }
 
+// SigningKeyRotated logs when a new key starts signing the session tokens, on schedule or on request.
+func (a *AuditLogger) SigningKeyRotated(kid string, opts ...AuditOption) {
+	ctx := &auditContext{severity: SeverityWarn}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+
+	fields := []zap.Field{
+		zap.String("type", "security"),
+		zap.String("event", fmt.Sprintf("sys_signing_key_rotated:%s", kid)),
+		zap.String("kid", kid),
+	}
+	fields = append(fields, ctx.toZapFields()...)
+
+	a.logger.Warn(fmt.Sprintf("Session signing key rotated to %s", kid), fields...)
+}
+
 // DatabaseBackupCreated logs when a database backup is created.
 func (a *AuditLogger) DatabaseBackupCreated(archivePath string, opts ...AuditOption) {
 	ctx := &auditContext{severity: SeverityWarn}
@@ -1,13 +1,24 @@
 package config
 
 import (
+	"encoding/asn1"
 	"errors"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/canonical/notary/internal/acmedns"
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/email"
+	"github.com/canonical/notary/internal/db"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
@@ -44,10 +55,41 @@ func ParseConfig(cmdFlags *pflag.FlagSet, configFilePath string) (*AppConfig, er
 
 	appConfig.ShouldEnablePebbleNotifications = cfg.GetBool("pebble_notifications")
 
+	appConfig.RenewalWindow, _ = time.ParseDuration(cfg.GetString("renewal.window"))
+	appConfig.RenewalCheckInterval, _ = time.ParseDuration(cfg.GetString("renewal.check_interval"))
+
+	if webrootDirectory := cfg.GetString("acme.webroot_directory"); webrootDirectory != "" {
+		appConfig.ACMEWebrootDirectory = filepath.Clean(webrootDirectory)
+	}
+
+	for _, name := range cfg.GetStringSlice("authentication.mfa.required_roles") {
+		appConfig.MFARequiredRoles = append(appConfig.MFARequiredRoles, roleNames[name])
+	}
+
+	appConfig.LoginMaxAttempts = cfg.GetInt("authentication.lockout.max_attempts")
+	appConfig.LoginMaxAttemptsPerIP = cfg.GetInt("authentication.lockout.max_attempts_per_ip")
+	appConfig.LoginLockoutDuration, _ = time.ParseDuration(cfg.GetString("authentication.lockout.duration"))
+	appConfig.LoginMaxLockoutDuration, _ = time.ParseDuration(cfg.GetString("authentication.lockout.max_duration"))
+
+	appConfig.PasswordInvitationLifetime, _ = time.ParseDuration(cfg.GetString("authentication.password_tokens.invitation_lifetime"))
+	appConfig.PasswordResetLifetime, _ = time.ParseDuration(cfg.GetString("authentication.password_tokens.reset_lifetime"))
+	appConfig.SigningKeyAlgorithm = cfg.GetString("authentication.signing_keys.algorithm")
+	appConfig.SigningKeyRotationInterval, _ = time.ParseDuration(cfg.GetString("authentication.signing_keys.rotation_interval"))
+	appConfig.SigningKeyGracePeriod, _ = time.ParseDuration(cfg.GetString("authentication.signing_keys.grace_period"))
+
 	appConfig.LoggingConfig = cfg.Sub("logging")
 	appConfig.TracingConfig = cfg.Sub("tracing")
 	appConfig.OIDCConfig = cfg.Sub("authentication.oidc")
+	appConfig.ClientCertificatesConfig = cfg.Sub("authentication.client_certificates")
+	appConfig.LDAPConfig = cfg.Sub("authentication.ldap")
 	appConfig.EncryptionConfig = cfg.Sub("encryption_backend")
+	appConfig.TimestampingConfig = cfg.Sub("timestamping")
+	appConfig.TimestampRateLimit = defaultTimestampRateLimit
+	if cfg.IsSet("timestamping.rate_limit") {
+		appConfig.TimestampRateLimit = cfg.GetInt("timestamping.rate_limit")
+	}
+	appConfig.ACMEDNSConfig = cfg.Sub("acme_dns")
+	appConfig.SMTPConfig = cfg.Sub("smtp")
 
 	return appConfig, nil
 }
@@ -70,6 +112,17 @@ func initializeServerConfig(cmdFlags *pflag.FlagSet, configFilePath string) (*vi
 	v.SetDefault("logging.system.level", "debug")
 	v.SetDefault("logging.system.output", "stdout")
 	v.SetDefault("logging.audit.output", "stdout")
+	v.SetDefault("renewal.window", "720h")
+	v.SetDefault("renewal.check_interval", "1h")
+	v.SetDefault("authentication.lockout.max_attempts", 5)
+	v.SetDefault("authentication.lockout.max_attempts_per_ip", 20)
+	v.SetDefault("authentication.lockout.duration", "1m")
+	v.SetDefault("authentication.lockout.max_duration", "1h")
+	v.SetDefault("authentication.password_tokens.invitation_lifetime", "72h")
+	v.SetDefault("authentication.password_tokens.reset_lifetime", "24h")
+	v.SetDefault("authentication.signing_keys.algorithm", authentication.SigningAlgorithmES256)
+	v.SetDefault("authentication.signing_keys.rotation_interval", "720h")
+	v.SetDefault("authentication.signing_keys.grace_period", "24h")
 
 	if configFilePath == "" {
 		return nil, errors.New("config file path not provided")
@@ -115,9 +168,365 @@ func validateServerConfig(cfg *viper.Viper) error {
 	if err := validateEncryptionBackendConfig(cfg.Sub("encryption_backend")); err != nil {
 		return err
 	}
+	if cfg.IsSet("timestamping") {
+		if err := validateTimestampingConfig(cfg.Sub("timestamping")); err != nil {
+			return err
+		}
+	}
+	if err := validateRenewalConfig(cfg); err != nil {
+		return err
+	}
+	if cfg.IsSet("acme.webroot_directory") && !filepath.IsAbs(cfg.GetString("acme.webroot_directory")) {
+		return errors.New("acme webroot_directory must be an absolute path")
+	}
+	if err := validateLockoutConfig(cfg); err != nil {
+		return err
+	}
+	if err := validatePasswordTokensConfig(cfg); err != nil {
+		return err
+	}
+	if err := validateSigningKeysConfig(cfg); err != nil {
+		return err
+	}
+	if cfg.IsSet("smtp") {
+		if err := validateSMTPConfig(cfg.Sub("smtp")); err != nil {
+			return err
+		}
+	}
+	if cfg.IsSet("acme_dns") {
+		if err := validateACMEDNSConfig(cfg.Sub("acme_dns")); err != nil {
+			return err
+		}
+	}
+	if cfg.IsSet("authentication.client_certificates") {
+		if err := validateClientCertificatesConfig(cfg.Sub("authentication.client_certificates")); err != nil {
+			return err
+		}
+	}
+	if cfg.IsSet("authentication.oidc.role_mapping") {
+		if err := validateOIDCRoleMappingConfig(cfg.Sub("authentication.oidc")); err != nil {
+			return err
+		}
+	}
+	if cfg.IsSet("authentication.ldap") {
+		if err := validateLDAPConfig(cfg.Sub("authentication.ldap")); err != nil {
+			return err
+		}
+	}
+	if cfg.IsSet("authentication.mfa") {
+		if err := validateMFAConfig(cfg.Sub("authentication.mfa")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// minSigningKeyGracePeriod is the lifetime of the session tokens, which a signing key must verify after it is rotated out.
+const minSigningKeyGracePeriod = time.Hour
+
+// roleNames maps the names of the roles in the configuration to their IDs.
+var roleNames = map[string]db.RoleID{
+	"admin":                 db.RoleAdmin,
+	"certificate_manager":   db.RoleCertificateManager,
+	"certificate_requestor": db.RoleCertificateRequestor,
+	"reader":                db.RoleReadOnly,
+}
+
+// validateMFAConfig validates the multi-factor authentication policy.
+func validateMFAConfig(mfaCfg *viper.Viper) error {
+	if mfaCfg == nil {
+		return errors.New("`authentication.mfa` must be a map")
+	}
+	for _, name := range mfaCfg.GetStringSlice("required_roles") {
+		if _, ok := roleNames[name]; !ok {
+			return fmt.Errorf("invalid mfa required_roles: unknown role %q", name)
+		}
+	}
+	return nil
+}
+
+// validateOIDCRoleMappingConfig validates the mapping of the claims of the OIDC identity provider to roles.
+func validateOIDCRoleMappingConfig(oidcCfg *viper.Viper) error {
+	mappingCfg := oidcCfg.Sub("role_mapping")
+	if mappingCfg == nil {
+		return errors.New("`authentication.oidc.role_mapping` must be a map")
+	}
+	if mappingCfg.GetString("claim") == "" && oidcCfg.GetString("permissions_scope_key") == "" {
+		return errors.New("oidc role_mapping claim is required when permissions_scope_key is not set")
+	}
+	return validateRoleMappingConfig(mappingCfg, "oidc")
+}
+
+// validateRoleMappingConfig validates the mapping of the values of the provider, such as claims or groups, to roles.
+func validateRoleMappingConfig(mappingCfg *viper.Viper, provider string) error {
+	roles := mappingCfg.GetStringMapStringSlice("roles")
+	if len(roles) == 0 && !mappingCfg.IsSet("default_role") {
+		return fmt.Errorf("%s role_mapping must map values to roles", provider)
+	}
+	for name, values := range roles {
+		if _, ok := roleNames[name]; !ok {
+			return fmt.Errorf("invalid %s role_mapping roles: unknown role %q", provider, name)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("invalid %s role_mapping roles: no value for role %q", provider, name)
+		}
+	}
+	if mappingCfg.IsSet("default_role") {
+		if _, ok := roleNames[mappingCfg.GetString("default_role")]; !ok {
+			return fmt.Errorf("invalid %s role_mapping default_role: unknown role %q", provider, mappingCfg.GetString("default_role"))
+		}
+	}
+	return nil
+}
+
+// validateLDAPConfig validates the configuration of the LDAP authentication.
+func validateLDAPConfig(ldapCfg *viper.Viper) error {
+	if ldapCfg == nil {
+		return errors.New("`authentication.ldap` must be a map")
+	}
+	u, err := url.Parse(ldapCfg.GetString("url"))
+	if err != nil || u.Host == "" || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+		return errors.New("invalid ldap url: must be an ldap:// or ldaps:// URL")
+	}
+	if ldapCfg.GetBool("start_tls") && u.Scheme != "ldap" {
+		return errors.New("invalid ldap start_tls: only ldap:// URLs can start TLS")
+	}
+	if ldapCfg.IsSet("bind_dn") != ldapCfg.IsSet("bind_password") {
+		return errors.New("ldap bind_dn and bind_password must be set together")
+	}
+	template := ldapCfg.GetString("user_dn_template")
+	searchBase := ldapCfg.GetString("user_search_base")
+	if (template == "") == (searchBase == "") {
+		return errors.New("exactly one of ldap user_dn_template or user_search_base is required")
+	}
+	if template != "" && !strings.Contains(template, authentication.LDAPUsernamePlaceholder) {
+		return fmt.Errorf("invalid ldap user_dn_template: must contain %s", authentication.LDAPUsernamePlaceholder)
+	}
+	if ldapCfg.IsSet("user_filter") && !strings.Contains(ldapCfg.GetString("user_filter"), authentication.LDAPUsernamePlaceholder) {
+		return fmt.Errorf("invalid ldap user_filter: must contain %s", authentication.LDAPUsernamePlaceholder)
+	}
+	if ldapCfg.IsSet("timeout") {
+		if d, err := time.ParseDuration(ldapCfg.GetString("timeout")); err != nil || d <= 0 {
+			return errors.New("invalid ldap timeout: must be a positive duration")
+		}
+	}
+	if ldapCfg.IsSet("role_mapping") {
+		mappingCfg := ldapCfg.Sub("role_mapping")
+		if mappingCfg == nil {
+			return errors.New("`authentication.ldap.role_mapping` must be a map")
+		}
+		if err := validateRoleMappingConfig(mappingCfg, "ldap"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateClientCertificatesConfig validates the configuration of the client certificate authentication.
+func validateClientCertificatesConfig(clientCertsCfg *viper.Viper) error {
+	if clientCertsCfg == nil {
+		return errors.New("`authentication.client_certificates` must be a map")
+	}
+	ids := clientCertsCfg.GetIntSlice("certificate_authority_ids")
+	if len(ids) == 0 {
+		return errors.New("client_certificates certificate_authority_ids is missing")
+	}
+	for _, id := range ids {
+		if id <= 0 {
+			return errors.New("client_certificates certificate_authority_ids must be positive integers")
+		}
+	}
+	if clientCertsCfg.IsSet("identity") && !authentication.IsValidClientCertificateIdentity(clientCertsCfg.GetString("identity")) {
+		return fmt.Errorf("invalid client_certificates identity: must be %q or %q", authentication.ClientCertificateIdentityEmail, authentication.ClientCertificateIdentityCommonName)
+	}
 	return nil
 }
 
+// validateACMEDNSConfig validates the configuration of the acme-dns compatible service.
+func validateACMEDNSConfig(acmeDNSCfg *viper.Viper) error {
+	if acmeDNSCfg == nil {
+		return errors.New("`acme_dns` must be a map")
+	}
+	if !acmeDNSCfg.IsSet("domain") {
+		return errors.New("acme_dns domain is missing")
+	}
+	if _, err := acmedns.NormalizeDomain(acmeDNSCfg.GetString("domain")); err != nil {
+		return errors.New("invalid acme_dns domain")
+	}
+	if acmeDNSCfg.IsSet("nameserver") {
+		if _, err := acmedns.NormalizeDomain(acmeDNSCfg.GetString("nameserver")); err != nil {
+			return errors.New("invalid acme_dns nameserver")
+		}
+	}
+	if acmeDNSCfg.IsSet("address") && net.ParseIP(acmeDNSCfg.GetString("address")) == nil {
+		return errors.New("invalid acme_dns address: must be an IP address")
+	}
+	if acmeDNSCfg.IsSet("listen_address") {
+		if _, _, err := net.SplitHostPort(acmeDNSCfg.GetString("listen_address")); err != nil {
+			return fmt.Errorf("invalid acme_dns listen_address: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateRenewalConfig validates the automatic certificate renewal configuration.
+func validateRenewalConfig(cfg *viper.Viper) error {
+	for _, key := range []string{"window", "check_interval"} {
+		d, err := time.ParseDuration(cfg.GetString("renewal." + key))
+		if err != nil {
+			return fmt.Errorf("invalid renewal %s: %w", key, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("renewal %s must be positive", key)
+		}
+	}
+	return nil
+}
+
+// validateLockoutConfig validates the lockout of the accounts and IP addresses with too many failed login attempts.
+func validateLockoutConfig(cfg *viper.Viper) error {
+	for _, key := range []string{"max_attempts", "max_attempts_per_ip"} {
+		if cfg.GetInt("authentication.lockout."+key) < 0 {
+			return fmt.Errorf("lockout %s can't be negative", key)
+		}
+	}
+	durations := map[string]time.Duration{}
+	for _, key := range []string{"duration", "max_duration"} {
+		d, err := time.ParseDuration(cfg.GetString("authentication.lockout." + key))
+		if err != nil {
+			return fmt.Errorf("invalid lockout %s: %w", key, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("lockout %s must be positive", key)
+		}
+		durations[key] = d
+	}
+	if durations["max_duration"] < durations["duration"] {
+		return errors.New("lockout max_duration can't be shorter than duration")
+	}
+	return nil
+}
+
+// validatePasswordTokensConfig validates the lifetimes of the invitation and password reset tokens.
+func validatePasswordTokensConfig(cfg *viper.Viper) error {
+	for _, key := range []string{"invitation_lifetime", "reset_lifetime"} {
+		d, err := time.ParseDuration(cfg.GetString("authentication.password_tokens." + key))
+		if err != nil {
+			return fmt.Errorf("invalid password_tokens %s: %w", key, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("password_tokens %s must be positive", key)
+		}
+	}
+	return nil
+}
+
+// validateSigningKeysConfig validates the algorithm and the rotation of the keys that sign the session tokens.
+func validateSigningKeysConfig(cfg *viper.Viper) error {
+	algorithm := cfg.GetString("authentication.signing_keys.algorithm")
+	if algorithm != authentication.SigningAlgorithmES256 && algorithm != authentication.SigningAlgorithmEdDSA {
+		return fmt.Errorf("invalid signing_keys algorithm: must be %s or %s", authentication.SigningAlgorithmES256, authentication.SigningAlgorithmEdDSA)
+	}
+	rotationInterval, err := time.ParseDuration(cfg.GetString("authentication.signing_keys.rotation_interval"))
+	if err != nil {
+		return fmt.Errorf("invalid signing_keys rotation_interval: %w", err)
+	}
+	if rotationInterval < 0 {
+		return errors.New("signing_keys rotation_interval can't be negative")
+	}
+	gracePeriod, err := time.ParseDuration(cfg.GetString("authentication.signing_keys.grace_period"))
+	if err != nil {
+		return fmt.Errorf("invalid signing_keys grace_period: %w", err)
+	}
+	// The tokens signed by a key that was rotated out must keep working until they expire.
+	if gracePeriod < minSigningKeyGracePeriod {
+		return fmt.Errorf("signing_keys grace_period can't be shorter than %s", minSigningKeyGracePeriod)
+	}
+	return nil
+}
+
+// validateSMTPConfig validates the configuration of the SMTP server that Notary sends emails through.
+func validateSMTPConfig(smtpCfg *viper.Viper) error {
+	if smtpCfg == nil {
+		return errors.New("`smtp` must be a map")
+	}
+	if smtpCfg.GetString("host") == "" {
+		return errors.New("smtp host is missing")
+	}
+	if smtpCfg.IsSet("port") && (smtpCfg.GetInt("port") <= 0 || smtpCfg.GetInt("port") > 65535) {
+		return errors.New("invalid smtp port")
+	}
+	if _, err := mail.ParseAddress(smtpCfg.GetString("from")); err != nil {
+		return errors.New("invalid smtp from: must be an email address")
+	}
+	if smtpCfg.IsSet("password") && !smtpCfg.IsSet("username") {
+		return errors.New("smtp password requires a username")
+	}
+	security := smtpCfg.GetString("security")
+	if smtpCfg.IsSet("security") && security != email.SMTPSecurityStartTLS && security != email.SMTPSecurityTLS && security != email.SMTPSecurityNone {
+		return fmt.Errorf("invalid smtp security: must be %q, %q or %q", email.SMTPSecurityStartTLS, email.SMTPSecurityTLS, email.SMTPSecurityNone)
+	}
+	if smtpCfg.IsSet("timeout") {
+		if d, err := time.ParseDuration(smtpCfg.GetString("timeout")); err != nil || d <= 0 {
+			return errors.New("invalid smtp timeout: must be a positive duration")
+		}
+	}
+	return nil
+}
+
+// defaultTimestampRateLimit is how many timestamp queries an IP address can make per minute by default.
+// It isn't a viper default, which would enable timestamping.
+const defaultTimestampRateLimit = 60
+
+// validateTimestampingConfig validates the timestamping authority configuration.
+func validateTimestampingConfig(timestampingCfg *viper.Viper) error {
+	if timestampingCfg == nil {
+		return errors.New("`timestamping` must be a map")
+	}
+	if !timestampingCfg.IsSet("certificate_authority_id") {
+		return errors.New("timestamping certificate_authority_id is missing")
+	}
+	if timestampingCfg.GetInt64("certificate_authority_id") <= 0 {
+		return errors.New("timestamping certificate_authority_id must be a positive integer")
+	}
+	if !timestampingCfg.IsSet("policy_oid") {
+		return errors.New("timestamping policy_oid is missing")
+	}
+	if _, err := parseOID(timestampingCfg.GetString("policy_oid")); err != nil {
+		return fmt.Errorf("invalid timestamping policy_oid: %w", err)
+	}
+	if timestampingCfg.IsSet("rate_limit") && timestampingCfg.GetInt("rate_limit") < 0 {
+		return errors.New("timestamping rate_limit can't be negative")
+	}
+	if timestampingCfg.IsSet("accuracy") {
+		accuracy, err := time.ParseDuration(timestampingCfg.GetString("accuracy"))
+		if err != nil {
+			return fmt.Errorf("invalid timestamping accuracy: %w", err)
+		}
+		if accuracy <= 0 {
+			return errors.New("timestamping accuracy must be positive")
+		}
+	}
+	return nil
+}
+
+// parseOID parses an object identifier in dotted decimal notation, such as "1.3.6.1.4.1.343".
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%q is not a dotted object identifier", s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q is not a dotted object identifier", s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
 // validateEncryptionBackendConfig validates the encryption backend configuration.
 func validateEncryptionBackendConfig(encryptionCfg *viper.Viper) error {
 	backendType := encryptionCfg.GetString("type")
@@ -5,8 +5,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/canonical/notary/internal/config"
+	"github.com/canonical/notary/internal/db"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/spf13/pflag"
@@ -28,6 +30,18 @@ func TestValidConfig(t *testing.T) {
 			ShouldEnablePebbleNotifications: false,
 			TLSCertificate:                  []byte(validCert),
 			TLSPrivateKey:                   []byte(validPK),
+			RenewalWindow:                   720 * time.Hour,
+			RenewalCheckInterval:            time.Hour,
+			LoginMaxAttempts:                5,
+			LoginMaxAttemptsPerIP:           20,
+			LoginLockoutDuration:            time.Minute,
+			LoginMaxLockoutDuration:         time.Hour,
+			PasswordInvitationLifetime:      72 * time.Hour,
+			PasswordResetLifetime:           24 * time.Hour,
+			SigningKeyAlgorithm:             "ES256",
+			SigningKeyRotationInterval:      720 * time.Hour,
+			SigningKeyGracePeriod:           24 * time.Hour,
+			TimestampRateLimit:              60,
 		}}, // This case tests the expected default values for missing fields are filled correctly
 		{"full config", validFullConfig, &config.AppConfig{
 			Port:                            8000,
@@ -37,6 +51,20 @@ func TestValidConfig(t *testing.T) {
 			ShouldEnablePebbleNotifications: false,
 			TLSCertificate:                  []byte(validCert),
 			TLSPrivateKey:                   []byte(validPK),
+			RenewalWindow:                   240 * time.Hour,
+			RenewalCheckInterval:            30 * time.Minute,
+			MFARequiredRoles:                []db.RoleID{db.RoleAdmin, db.RoleCertificateManager},
+			LoginMaxAttempts:                10,
+			LoginMaxAttemptsPerIP:           0,
+			LoginLockoutDuration:            30 * time.Second,
+			LoginMaxLockoutDuration:         15 * time.Minute,
+			PasswordInvitationLifetime:      168 * time.Hour,
+			PasswordResetLifetime:           time.Hour,
+			SigningKeyAlgorithm:             "EdDSA",
+			SigningKeyRotationInterval:      168 * time.Hour,
+			SigningKeyGracePeriod:           2 * time.Hour,
+			TimestampRateLimit:              120,
+			ACMEWebrootDirectory:            "/var/www",
 		}}, // This case tests that the variables from the yaml are correctly copied to the final config
 	}
 	for _, tc := range cases {
@@ -50,7 +78,7 @@ func TestValidConfig(t *testing.T) {
 				t.Errorf("ParseConfig(%q) = %v, want nil", "config.yaml", err)
 				return
 			}
-			if !cmp.Equal(gotCfg, tc.wantCfg, cmpopts.IgnoreFields(config.AppConfig{}, "LoggingConfig", "TracingConfig", "OIDCConfig", "EncryptionConfig")) {
+			if !cmp.Equal(gotCfg, tc.wantCfg, cmpopts.IgnoreFields(config.AppConfig{}, "LoggingConfig", "TracingConfig", "OIDCConfig", "EncryptionConfig", "TimestampingConfig", "ACMEDNSConfig", "ClientCertificatesConfig", "LDAPConfig", "SMTPConfig")) {
 				t.Errorf("ParseConfig returned unexpected diff (-want+got):\n%v", cmp.Diff(tc.wantCfg, gotCfg))
 			}
 		})
@@ -74,6 +102,34 @@ func TestInvalidConfig(t *testing.T) {
 		{"no encryption backend", noEncryptionBackendConfig, "`encryption_backend` is empty"},
 		{"invalid pkcs11 encryption backend config", invalidEncryptionBackendConfigType, "invalid encryption backend type; must be 'none', 'vault' or 'pkcs11'"},
 		{"incomplete pkcs11 encryption backend config", incompleteEncryptionBackendConfig, "pin is missing"},
+		{"timestamping without certificate authority", noTimestampingCAConfig, "timestamping certificate_authority_id is missing"},
+		{"invalid timestamping policy oid", invalidTimestampingPolicyConfig, "invalid timestamping policy_oid"},
+		{"invalid timestamping accuracy", invalidTimestampingAccuracyConfig, "invalid timestamping accuracy"},
+		{"negative timestamping rate limit", negativeTimestampingRateLimitConfig, "timestamping rate_limit can't be negative"},
+		{"acme-dns without domain", noACMEDNSDomainConfig, "acme_dns domain is missing"},
+		{"invalid acme-dns address", invalidACMEDNSAddressConfig, "invalid acme_dns address"},
+		{"client certificates without certificate authorities", noClientCertificatesCAConfig, "client_certificates certificate_authority_ids is missing"},
+		{"invalid client certificates identity", invalidClientCertificatesIdentityConfig, "invalid client_certificates identity"},
+		{"unknown mfa required role", invalidMFARequiredRoleConfig, "invalid mfa required_roles"},
+		{"unknown oidc mapped role", invalidOIDCRoleMappingRoleConfig, "invalid oidc role_mapping roles: unknown role \"superuser\""},
+		{"oidc role mapping without claim", invalidOIDCRoleMappingClaimConfig, "oidc role_mapping claim is required"},
+		{"unknown oidc default role", invalidOIDCRoleMappingDefaultRoleConfig, "invalid oidc role_mapping default_role"},
+		{"invalid ldap url", invalidLDAPURLConfig, "invalid ldap url"},
+		{"ldap start_tls with ldaps", invalidLDAPStartTLSConfig, "invalid ldap start_tls"},
+		{"ldap with template and search base", invalidLDAPUserLookupConfig, "exactly one of ldap user_dn_template or user_search_base is required"},
+		{"ldap template without username", invalidLDAPUserDNTemplateConfig, "invalid ldap user_dn_template"},
+		{"unknown ldap mapped role", invalidLDAPRoleMappingRoleConfig, "invalid ldap role_mapping roles: unknown role \"superuser\""},
+		{"non-positive password reset lifetime", invalidPasswordResetLifetimeConfig, "password_tokens reset_lifetime must be positive"},
+		{"invalid signing key algorithm", invalidSigningKeyAlgorithmConfig, "invalid signing_keys algorithm"},
+		{"short signing key grace period", shortSigningKeyGracePeriodConfig, "signing_keys grace_period can't be shorter than 1h0m0s"},
+		{"smtp without host", noSMTPHostConfig, "smtp host is missing"},
+		{"invalid smtp from", invalidSMTPFromConfig, "invalid smtp from"},
+		{"invalid smtp security", invalidSMTPSecurityConfig, "invalid smtp security"},
+		{"negative lockout max attempts", invalidLockoutMaxAttemptsConfig, "lockout max_attempts can't be negative"},
+		{"lockout max duration shorter than duration", invalidLockoutMaxDurationConfig, "lockout max_duration can't be shorter than duration"},
+		{"invalid renewal window", invalidRenewalWindowConfig, "invalid renewal window"},
+		{"non-positive renewal check interval", invalidRenewalCheckIntervalConfig, "renewal check_interval must be positive"},
+		{"relative acme webroot directory", relativeACMEWebrootDirectoryConfig, "acme webroot_directory must be an absolute path"},
 	}
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -127,6 +183,67 @@ logging:
   output: "stdout"
 encryption_backend:
   type: "none"
+timestamping:
+  certificate_authority_id: 1
+  policy_oid: "1.3.6.1.4.1.99999.1"
+  accuracy: "500ms"
+  rate_limit: 120
+renewal:
+  window: "240h"
+  check_interval: "30m"
+acme:
+  webroot_directory: "/var/www/"
+acme_dns:
+  domain: "acme-dns.example.com"
+  nameserver: "ns.example.com"
+  address: "192.0.2.53"
+  listen_address: "0.0.0.0:5353"
+authentication:
+  client_certificates:
+    certificate_authority_ids: [1, 2]
+    identity: "common_name"
+  oidc:
+    domain: "example.auth0.com"
+    client_id: "client-id"
+    client_secret: "client-secret"
+    permissions_scope_key: "groups"
+    role_mapping:
+      roles:
+        admin: ["notary-admins"]
+        certificate_manager: ["pki-team", "security"]
+      default_role: "reader"
+  ldap:
+    url: "ldap://ldap.example.com"
+    start_tls: true
+    bind_dn: "cn=notary,ou=services,dc=example,dc=com"
+    bind_password: "service-password"
+    user_search_base: "ou=people,dc=example,dc=com"
+    user_filter: "(uid={username})"
+    role_mapping:
+      roles:
+        admin: ["cn=notary-admins,ou=groups,dc=example,dc=com"]
+      default_role: "reader"
+  mfa:
+    required_roles: ["admin", "certificate_manager"]
+  lockout:
+    max_attempts: 10
+    max_attempts_per_ip: 0
+    duration: "30s"
+    max_duration: "15m"
+  password_tokens:
+    invitation_lifetime: "168h"
+    reset_lifetime: "1h"
+  signing_keys:
+    algorithm: "EdDSA"
+    rotation_interval: "168h"
+    grace_period: "2h"
+smtp:
+  host: "smtp.example.com"
+  port: 465
+  username: "notary"
+  password: "smtp-password"
+  from: "notary@example.com"
+  security: "tls"
 `
 )
 
@@ -246,6 +363,331 @@ encryption_backend:
   type: "pkcs11"
   lib_path: "/usr/local/lib/pkcs11/yubihsm_pkcs11.so"
   aes_encryption_key_id: 0x1234
+`
+	noTimestampingCAConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+timestamping:
+  policy_oid: "1.3.6.1.4.1.99999.1"
+`
+	invalidTimestampingPolicyConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+timestamping:
+  certificate_authority_id: 1
+  policy_oid: "not.an.oid"
+`
+	invalidTimestampingAccuracyConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+timestamping:
+  certificate_authority_id: 1
+  policy_oid: "1.3.6.1.4.1.99999.1"
+  accuracy: "very accurate"
+`
+	negativeTimestampingRateLimitConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+timestamping:
+  certificate_authority_id: 1
+  policy_oid: "1.3.6.1.4.1.99999.1"
+  rate_limit: -1
+`
+	noACMEDNSDomainConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+acme_dns:
+  listen_address: ":5353"
+`
+	invalidACMEDNSAddressConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+acme_dns:
+  domain: "acme-dns.example.com"
+  address: "ns.example.com"
+`
+	noClientCertificatesCAConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  client_certificates:
+    identity: "email"
+`
+	invalidClientCertificatesIdentityConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  client_certificates:
+    certificate_authority_ids: [1]
+    identity: "uri"
+`
+	invalidMFARequiredRoleConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  mfa:
+    required_roles: ["admin", "superuser"]
+`
+	invalidOIDCRoleMappingRoleConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  oidc:
+    permissions_scope_key: "groups"
+    role_mapping:
+      roles:
+        superuser: ["notary-admins"]
+`
+	invalidOIDCRoleMappingClaimConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  oidc:
+    role_mapping:
+      roles:
+        admin: ["notary-admins"]
+`
+	invalidOIDCRoleMappingDefaultRoleConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  oidc:
+    permissions_scope_key: "groups"
+    role_mapping:
+      claim: "groups"
+      default_role: "everyone"
+`
+	invalidLDAPURLConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  ldap:
+    url: "https://ldap.example.com"
+    user_dn_template: "uid={username},ou=people,dc=example,dc=com"
+`
+	invalidLDAPStartTLSConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  ldap:
+    url: "ldaps://ldap.example.com"
+    start_tls: true
+    user_dn_template: "uid={username},ou=people,dc=example,dc=com"
+`
+	invalidLDAPUserLookupConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  ldap:
+    url: "ldap://ldap.example.com"
+    user_dn_template: "uid={username},ou=people,dc=example,dc=com"
+    user_search_base: "ou=people,dc=example,dc=com"
+`
+	invalidLDAPUserDNTemplateConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  ldap:
+    url: "ldap://ldap.example.com"
+    user_dn_template: "ou=people,dc=example,dc=com"
+`
+	invalidLDAPRoleMappingRoleConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  ldap:
+    url: "ldap://ldap.example.com"
+    user_dn_template: "uid={username},ou=people,dc=example,dc=com"
+    role_mapping:
+      roles:
+        superuser: ["cn=notary-admins,ou=groups,dc=example,dc=com"]
+`
+	invalidPasswordResetLifetimeConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  password_tokens:
+    reset_lifetime: "0s"
+`
+	invalidSigningKeyAlgorithmConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  signing_keys:
+    algorithm: "HS256"
+`
+	shortSigningKeyGracePeriodConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  signing_keys:
+    grace_period: "10m"
+`
+	noSMTPHostConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+smtp:
+  from: "notary@example.com"
+`
+	invalidSMTPFromConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+smtp:
+  host: "smtp.example.com"
+  from: "notary"
+`
+	invalidSMTPSecurityConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+smtp:
+  host: "smtp.example.com"
+  from: "notary@example.com"
+  security: "ssl"
+`
+	invalidLockoutMaxAttemptsConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  lockout:
+    max_attempts: -1
+`
+	invalidLockoutMaxDurationConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+authentication:
+  lockout:
+    duration: "1h"
+    max_duration: "1m"
+`
+	invalidRenewalWindowConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+renewal:
+  window: "a month"
+`
+	invalidRenewalCheckIntervalConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+renewal:
+  check_interval: "0s"
+`
+	relativeACMEWebrootDirectoryConfig = `
+key_path:  "./key_test.pem"
+cert_path: "./cert_test.pem"
+db_path: "./notary.db"
+port: 8000
+encryption_backend:
+  type: "none"
+acme:
+  webroot_directory: "var/www"
 `
 	invalidYAMLConfig = `just_an=invalid
 yaml.here`
@@ -2,17 +2,26 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/MicahParks/keyfunc/v3"
+	"github.com/canonical/notary/internal/acmedns"
 	"github.com/canonical/notary/internal/backends/authentication"
 	authz "github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/email"
 	"github.com/canonical/notary/internal/backends/encryption"
 	"github.com/canonical/notary/internal/backends/observability/log"
 	"github.com/canonical/notary/internal/backends/observability/tracing"
 	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
+	"github.com/canonical/notary/internal/tsa"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel"
@@ -51,24 +60,61 @@ func InitializeAppEnvironment(appConfig *AppConfig, database *db.DatabaseReposit
 		return nil, fmt.Errorf("couldn't initialize encryption subsystem: %w", err)
 	}
 
+	// initialize the keys that sign the session tokens, which are encrypted
+	signingKeys, err := authentication.NewSigningKeyring(database, appConfig.SigningKeyAlgorithm, appConfig.SigningKeyRotationInterval, appConfig.SigningKeyGracePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize signing keys: %w", err)
+	}
+
 	// initialize OIDC config
 	authnRepo, err := initializeOIDC(appConfig.OIDCConfig, database, appConfig.ExternalHostname)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't initialize OIDC subsystem: %w", err)
 	}
 
+	// initialize client certificate authentication
+	clientCertRepo := initializeClientCertificates(appConfig.ClientCertificatesConfig, database)
+
+	// initialize LDAP authentication
+	ldapRepo, err := initializeLDAP(appConfig.LDAPConfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize LDAP subsystem: %w", err)
+	}
+
 	// initialize openfga server routine
 	authzRepo, err := InitializeAuthorizationConfig(database, systemLogger)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't initialize authorization subsystem: %w", err)
 	}
 
+	// initialize timestamping authority
+	tsaRepo, err := initializeTimestamping(appConfig.TimestampingConfig, database, appConfig.ExternalHostname)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize timestamping subsystem: %w", err)
+	}
+
+	// initialize acme-dns compatible service
+	acmeDNSRepo := initializeACMEDNS(appConfig.ACMEDNSConfig, database, systemLogger)
+
+	// initialize email sending
+	emailSender, err := initializeSMTP(appConfig.SMTPConfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize email subsystem: %w", err)
+	}
+
 	appEnv.SystemLogger = systemLogger
 	appEnv.AuditLogger = auditLogger
 	appEnv.TracingRepository = tracingRepo
 	appEnv.EncryptionRepository = encryptionRepo
+	appEnv.SigningKeys = signingKeys
 	appEnv.AuthnRepository = authnRepo
+	appEnv.ClientCertRepository = clientCertRepo
+	appEnv.LDAPRepository = ldapRepo
 	appEnv.AuthzRepository = authzRepo
+	appEnv.TSARepository = tsaRepo
+	appEnv.ACMEDNSRepository = acmeDNSRepo
+	appEnv.EmailSender = emailSender
+	appEnv.JobRunner = jobs.NewRunner(database, systemLogger)
 
 	return appEnv, nil
 }
@@ -227,11 +273,6 @@ func initializeOIDC(cfg *viper.Viper, database *db.DatabaseRepository, externalH
 		return nil, nil
 	}
 
-	err := authentication.SetUpJWTSecret(database)
-	if err != nil {
-		return nil, fmt.Errorf("failed to set up JWT secret: %w", err)
-	}
-
 	oidcServer := fmt.Sprintf("https://%s/", cfg.GetString("domain"))
 	clientID := cfg.GetString("client_id")
 	clientSecret := cfg.GetString("client_secret")
@@ -278,9 +319,172 @@ func initializeOIDC(cfg *viper.Viper, database *db.DatabaseRepository, externalH
 		KeyFunc:             keyfunc,
 		EmailClaimKey:       emailScope,
 		PermissionsClaimKey: permissionsScope,
+		RoleMapping:         initializeOIDCRoleMapping(cfg.Sub("role_mapping"), permissionsScope),
+	}, nil
+}
+
+// initializeOIDCRoleMapping sets up the mapping of the claims of the ID tokens to roles. It returns nil if it is not configured.
+// The claim is the permissions claim unless it is set.
+func initializeOIDCRoleMapping(cfg *viper.Viper, permissionsClaimKey string) *authentication.RoleMapping {
+	if cfg == nil {
+		return nil
+	}
+	cfg.SetDefault("claim", permissionsClaimKey)
+	mapping := initializeRoleMapping(cfg)
+	mapping.ClaimKey = cfg.GetString("claim")
+	return mapping
+}
+
+// initializeRoleMapping sets up the mapping of values, such as claims or groups, to roles. It returns nil if it is not configured.
+func initializeRoleMapping(cfg *viper.Viper) *authentication.RoleMapping {
+	if cfg == nil {
+		return nil
+	}
+	mapping := &authentication.RoleMapping{
+		Roles: map[string]db.RoleID{},
+	}
+	for name, values := range cfg.GetStringMapStringSlice("roles") {
+		for _, value := range values {
+			// A value mapped to several roles grants the most privileged of them.
+			if role, ok := mapping.Roles[value]; !ok || roleNames[name] < role {
+				mapping.Roles[value] = roleNames[name]
+			}
+		}
+	}
+	if cfg.IsSet("default_role") {
+		role := roleNames[cfg.GetString("default_role")]
+		mapping.DefaultRole = &role
+	}
+	return mapping
+}
+
+// initializeLDAP sets up the LDAP authentication. It returns nil if it is not configured.
+// The users are found with the uid attribute, and their groups with the memberOf attribute, unless they are set.
+func initializeLDAP(cfg *viper.Viper) (*authentication.LDAPRepository, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	cfg.SetDefault("user_filter", "(uid="+authentication.LDAPUsernamePlaceholder+")")
+	cfg.SetDefault("email_attribute", "mail")
+	cfg.SetDefault("group_attribute", "memberOf")
+	cfg.SetDefault("group_filter", "(member="+authentication.LDAPDNPlaceholder+")")
+	cfg.SetDefault("timeout", authentication.DefaultLDAPTimeout.String())
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.IsSet("ca_path") {
+		caPEM, err := os.ReadFile(cfg.GetString("ca_path"))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read ldap ca_path: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid ldap ca_path: no PEM certificate found")
+		}
+	}
+	timeout, _ := time.ParseDuration(cfg.GetString("timeout"))
+
+	return &authentication.LDAPRepository{
+		URL:             cfg.GetString("url"),
+		StartTLS:        cfg.GetBool("start_tls"),
+		TLSConfig:       tlsConfig,
+		BindDN:          cfg.GetString("bind_dn"),
+		BindPassword:    cfg.GetString("bind_password"),
+		UserDNTemplate:  cfg.GetString("user_dn_template"),
+		UserSearchBase:  cfg.GetString("user_search_base"),
+		UserFilter:      cfg.GetString("user_filter"),
+		EmailAttribute:  cfg.GetString("email_attribute"),
+		GroupAttribute:  cfg.GetString("group_attribute"),
+		GroupSearchBase: cfg.GetString("group_search_base"),
+		GroupFilter:     cfg.GetString("group_filter"),
+		RoleMapping:     initializeRoleMapping(cfg.Sub("role_mapping")),
+		Timeout:         timeout,
+	}, nil
+}
+
+// initializeSMTP sets up the sending of emails through an SMTP server. It returns nil if no server is configured.
+func initializeSMTP(cfg *viper.Viper) (*email.SMTPSender, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	cfg.SetDefault("port", 587)
+	cfg.SetDefault("security", email.SMTPSecurityStartTLS)
+	cfg.SetDefault("timeout", email.DefaultSMTPTimeout.String())
+
+	tlsConfig := &tls.Config{ServerName: cfg.GetString("host"), MinVersion: tls.VersionTLS12}
+	if cfg.IsSet("ca_path") {
+		caPEM, err := os.ReadFile(cfg.GetString("ca_path"))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read smtp ca_path: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid smtp ca_path: no PEM certificate found")
+		}
+	}
+	timeout, _ := time.ParseDuration(cfg.GetString("timeout"))
+
+	return &email.SMTPSender{
+		Host:      cfg.GetString("host"),
+		Port:      cfg.GetInt("port"),
+		Username:  cfg.GetString("username"),
+		Password:  cfg.GetString("password"),
+		From:      cfg.GetString("from"),
+		Security:  cfg.GetString("security"),
+		TLSConfig: tlsConfig,
+		Timeout:   timeout,
 	}, nil
 }
 
+// initializeClientCertificates sets up the client certificate authentication. It returns nil if it is not configured.
+// Client certificates are mapped to accounts by their email address unless identity is set.
+func initializeClientCertificates(cfg *viper.Viper, database *db.DatabaseRepository) *authentication.ClientCertificateRepository {
+	if cfg == nil {
+		return nil
+	}
+	cfg.SetDefault("identity", authentication.ClientCertificateIdentityEmail)
+	var ids []int64
+	for _, id := range cfg.GetIntSlice("certificate_authority_ids") {
+		ids = append(ids, int64(id))
+	}
+	return authentication.NewClientCertificateRepository(ids, cfg.GetString("identity"), database)
+}
+
+// initializeTimestamping sets up the RFC 3161 timestamping authority. It returns nil if timestamping is not configured.
+func initializeTimestamping(cfg *viper.Viper, database *db.DatabaseRepository, externalHostname string) (*tsa.TSARepository, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	policyOID, err := parseOID(cfg.GetString("policy_oid"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy_oid: %w", err)
+	}
+	accuracy := time.Second
+	if cfg.IsSet("accuracy") {
+		accuracy, err = time.ParseDuration(cfg.GetString("accuracy"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid accuracy: %w", err)
+		}
+	}
+	return tsa.NewTSARepository(cfg.GetInt64("certificate_authority_id"), policyOID, accuracy, externalHostname, database), nil
+}
+
+// initializeACMEDNS sets up the acme-dns compatible service. It returns nil if the service is not configured.
+// The DNS server listens on port 53 of every interface unless listen_address is set.
+func initializeACMEDNS(cfg *viper.Viper, database *db.DatabaseRepository, logger *zap.Logger) *acmedns.ACMEDNSRepository {
+	if cfg == nil {
+		return nil
+	}
+	cfg.SetDefault("listen_address", ":53")
+	return acmedns.NewACMEDNSRepository(
+		cfg.GetString("domain"),
+		cfg.GetString("nameserver"),
+		net.ParseIP(cfg.GetString("address")),
+		cfg.GetString("listen_address"),
+		database,
+		logger,
+	)
+}
+
 // initializeTracing creates and configures a tracer based on the configuration.
 func initializeTracing(cfg *viper.Viper, logger *zap.Logger) (*tracing.TracingRepository, error) {
 	if cfg == nil {
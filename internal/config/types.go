@@ -1,12 +1,18 @@
 package config
 
 import (
+	"time"
+
+	"github.com/canonical/notary/internal/acmedns"
 	authn "github.com/canonical/notary/internal/backends/authentication"
 	authz "github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/email"
 	"github.com/canonical/notary/internal/backends/encryption"
 	"github.com/canonical/notary/internal/backends/observability/log"
 	"github.com/canonical/notary/internal/backends/observability/tracing"
 	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
+	"github.com/canonical/notary/internal/tsa"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -32,11 +38,60 @@ type AppConfig struct {
 	// Send pebble notifications if enabled. Read more at github.com/canonical/pebble
 	ShouldEnablePebbleNotifications bool
 
+	// RenewalWindow is how long before expiry a certificate that opted into automatic renewal is renewed,
+	// and RenewalCheckInterval is how often Notary looks for certificates to renew.
+	RenewalWindow        time.Duration
+	RenewalCheckInterval time.Duration
+
+	// ACMEWebrootDirectory is the directory that the webroots of the ACME servers must be in.
+	// ACME servers can't use a webroot when it is empty.
+	ACMEWebrootDirectory string
+
+	// MFARequiredRoles are the roles whose local accounts must use multi-factor authentication.
+	MFARequiredRoles []db.RoleID
+
+	// LoginMaxAttempts and LoginMaxAttemptsPerIP are how many failed login attempts lock out an account or an IP address,
+	// for LoginLockoutDuration at first, doubling with every further failure up to LoginMaxLockoutDuration.
+	// The lockout of accounts or of IP addresses is disabled when its number of attempts is 0.
+	LoginMaxAttempts        int
+	LoginMaxAttemptsPerIP   int
+	LoginLockoutDuration    time.Duration
+	LoginMaxLockoutDuration time.Duration
+
+	// PasswordInvitationLifetime and PasswordResetLifetime are how long the tokens that let users set their password
+	// are valid, for an invitation to a new account and for a password reset.
+	PasswordInvitationLifetime time.Duration
+	PasswordResetLifetime      time.Duration
+
+	// SigningKeyAlgorithm is the algorithm of the new keys that sign the session tokens. A new key signs the tokens
+	// every SigningKeyRotationInterval, or only on request when it is 0, and the previous keys keep verifying them
+	// for SigningKeyGracePeriod.
+	SigningKeyAlgorithm        string
+	SigningKeyRotationInterval time.Duration
+	SigningKeyGracePeriod      time.Duration
+
 	// Configurations for Subsystems
 	LoggingConfig    *viper.Viper
 	TracingConfig    *viper.Viper
 	OIDCConfig       *viper.Viper
 	EncryptionConfig *viper.Viper
+
+	// Configuration of the client certificate authentication. It is nil when clients can't authenticate with certificates.
+	ClientCertificatesConfig *viper.Viper
+
+	// Configuration of the LDAP authentication. It is nil when users can't log in with an LDAP directory.
+	LDAPConfig *viper.Viper
+
+	// Configuration of the RFC 3161 timestamping authority. It is nil when timestamping is disabled.
+	TimestampingConfig *viper.Viper
+	// TimestampRateLimit is how many timestamp queries an IP address can make per minute, or 0 for no limit.
+	TimestampRateLimit int
+
+	// Configuration of the acme-dns compatible service. It is nil when the service is disabled.
+	ACMEDNSConfig *viper.Viper
+
+	// Configuration of the SMTP server that emails are sent through. It is nil when Notary doesn't send emails.
+	SMTPConfig *viper.Viper
 }
 
 // AppEnvironment contains repositories and connections to external services that the application needs to run.
@@ -50,4 +105,16 @@ type AppEnvironment struct {
 	EncryptionRepository *encryption.EncryptionRepository
 	AuthzRepository      *authz.AuthzRepository
 	AuthnRepository      *authn.OIDCRepository
+	ClientCertRepository *authn.ClientCertificateRepository
+	LDAPRepository       *authn.LDAPRepository
+	TSARepository        *tsa.TSARepository
+	ACMEDNSRepository    *acmedns.ACMEDNSRepository
+
+	// SigningKeys sign and verify the session tokens of Notary.
+	SigningKeys *authn.SigningKeyring
+
+	// EmailSender sends the emails of Notary, such as invitations. It is nil when no SMTP server is configured.
+	EmailSender *email.SMTPSender
+
+	JobRunner *jobs.Runner
 }
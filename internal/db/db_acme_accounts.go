@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -33,20 +34,24 @@ func (db *DatabaseRepository) GetOrCreateACMEAccount(email, directoryURL, privKe
 		PrivateKeyPEM:    privKeyPEM,
 		RegistrationURI:  regURI,
 		RegistrationBody: regBody,
+		Status:           ACMEAccountStatusValid,
 	}, nil
 }
 
+// ListACMEAccounts returns the ACME accounts with their private keys encrypted.
+func (db *DatabaseRepository) ListACMEAccounts() ([]ACMEAccount, error) {
+	return ListEntities[ACMEAccount](db, db.stmts.ListACMEAccounts)
+}
+
 func (db *DatabaseRepository) GetDecryptedACMEAccount(id int64) (*ACMEAccount, error) {
 	row := ACMEAccount{ID: id}
 	account, err := GetOneEntity[ACMEAccount](db, db.stmts.GetACMEAccount, row)
 	if err != nil {
 		return nil, err
 	}
-	decryptedPK, err := utils.Decrypt(account.PrivateKeyPEM, db.EncryptionKey)
-	if err != nil {
-		return nil, fmt.Errorf("%w: failed to decrypt ACME account private key", ErrInternal)
+	if err := db.decryptACMEAccountKeys(account); err != nil {
+		return nil, err
 	}
-	account.PrivateKeyPEM = decryptedPK
 	return account, nil
 }
 
@@ -64,6 +69,65 @@ func (db *DatabaseRepository) UpdateACMEAccount(id int64, privKeyPEM, regURI, re
 	return UpdateEntity[ACMEAccount](db, db.stmts.UpdateACMEAccount, row)
 }
 
+// SetACMEAccountPendingKey stores the new key of a key rollover of the account, before the ACME server is asked to
+// change the key. The key replaces the current one with PromoteACMEAccountPendingKey once the server accepted it,
+// or is discarded with ClearACMEAccountPendingKey.
+func (db *DatabaseRepository) SetACMEAccountPendingKey(id int64, privKeyPEM string) error {
+	encryptedPK, err := utils.Encrypt(privKeyPEM, db.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("%w: failed to encrypt ACME account private key", ErrInternal)
+	}
+	row := ACMEAccount{
+		ID:                   id,
+		PendingPrivateKeyPEM: encryptedPK,
+	}
+	return UpdateEntity[ACMEAccount](db, db.stmts.SetACMEAccountPendingKey, row)
+}
+
+// PromoteACMEAccountPendingKey replaces the key of the account with its pending key.
+// It returns ErrNotFound if the account has no pending key.
+func (db *DatabaseRepository) PromoteACMEAccountPendingKey(id int64) error {
+	return UpdateEntity[ACMEAccount](db, db.stmts.PromoteACMEAccountPendingKey, ACMEAccount{ID: id})
+}
+
+// ClearACMEAccountPendingKey discards the pending key of the account.
+func (db *DatabaseRepository) ClearACMEAccountPendingKey(id int64) error {
+	return UpdateEntity[ACMEAccount](db, db.stmts.SetACMEAccountPendingKey, ACMEAccount{ID: id})
+}
+
+// UpdateACMEAccountRegistration stores the registration of an ACME account as its ACME server last returned it.
+// ACME servers linked to an account that is no longer valid are unlinked from it, so that their next order
+// registers a new account.
+func (db *DatabaseRepository) UpdateACMEAccountRegistration(id int64, regBody, status string) error {
+	tx, err := db.Conn.PlainDB().BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", ErrInternal)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	result, err := tx.Exec("UPDATE acme_accounts SET registration_body = ?, status = ? WHERE id = ?", regBody, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", ErrInternal)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", ErrInternal)
+	}
+	if affected == 0 {
+		return fmt.Errorf("failed to update ACME account: %w", ErrNotFound)
+	}
+	if status != ACMEAccountStatusValid {
+		if _, err := tx.Exec("UPDATE acme_servers SET acme_account_id = NULL WHERE acme_account_id = ?", id); err != nil {
+			return fmt.Errorf("failed to unlink ACME account from servers: %w", ErrInternal)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update ACME account: %w", ErrInternal)
+	}
+	return nil
+}
+
 func (db *DatabaseRepository) LinkAccountToServer(serverID, accountID int64) error {
 	row := ACMEServer{
 		ID:            serverID,
@@ -72,17 +136,34 @@ func (db *DatabaseRepository) LinkAccountToServer(serverID, accountID int64) err
 	return UpdateEntity[ACMEServer](db, db.stmts.LinkACMEAccountToServer, row)
 }
 
-// GetACMEAccountByEmailAndURL returns ErrNotFound if no matching account exists.
+// GetACMEAccountByEmailAndURL returns the valid account registered with the email on the directory URL.
+// It returns ErrNotFound if no matching account exists.
 func (db *DatabaseRepository) GetACMEAccountByEmailAndURL(email, directoryURL string) (*ACMEAccount, error) {
 	row := ACMEAccount{Email: email, DirectoryURL: directoryURL}
 	account, err := GetOneEntity[ACMEAccount](db, db.stmts.GetACMEAccountByEmailAndURL, row)
 	if err != nil {
 		return nil, err
 	}
+	if err := db.decryptACMEAccountKeys(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// decryptACMEAccountKeys decrypts the private key of the account, and its pending key if it has one.
+func (db *DatabaseRepository) decryptACMEAccountKeys(account *ACMEAccount) error {
 	decryptedPK, err := utils.Decrypt(account.PrivateKeyPEM, db.EncryptionKey)
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to decrypt ACME account private key", ErrInternal)
+		return fmt.Errorf("%w: failed to decrypt ACME account private key", ErrInternal)
 	}
 	account.PrivateKeyPEM = decryptedPK
-	return account, nil
+	if account.PendingPrivateKeyPEM == "" {
+		return nil
+	}
+	decryptedPK, err = utils.Decrypt(account.PendingPrivateKeyPEM, db.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decrypt ACME account pending private key", ErrInternal)
+	}
+	account.PendingPrivateKeyPEM = decryptedPK
+	return nil
 }
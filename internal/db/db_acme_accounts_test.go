@@ -142,3 +142,137 @@ func TestGetDecryptedACMEAccount_NotFound(t *testing.T) {
 		t.Errorf("expected ErrNotFound, got %v", err)
 	}
 }
+
+func TestDeactivatedACMEAccount(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	account, err := database.GetOrCreateACMEAccount(testEmail, testDirectoryURL, testPrivKeyPEM, testRegURI, testRegBody)
+	if err != nil {
+		t.Fatalf("GetOrCreateACMEAccount() unexpected error: %v", err)
+	}
+	serverID, err := database.CreateACMEServer("test", testDirectoryURL, testEmail, "", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
+	}
+	if err := database.LinkAccountToServer(serverID, account.ID); err != nil {
+		t.Fatalf("LinkAccountToServer() unexpected error: %v", err)
+	}
+
+	if err := database.UpdateACMEAccountRegistration(account.ID, `{"body":{"status":"deactivated"}}`, db.ACMEAccountStatusDeactivated); err != nil {
+		t.Fatalf("UpdateACMEAccountRegistration() unexpected error: %v", err)
+	}
+	server, err := database.GetACMEServer(serverID)
+	if err != nil {
+		t.Fatalf("GetACMEServer() unexpected error: %v", err)
+	}
+	if server.ACMEAccountID != nil {
+		t.Fatalf("expected the server to be unlinked from the deactivated account, got account %d", *server.ACMEAccountID)
+	}
+	if _, err := database.GetACMEAccountByEmailAndURL(testEmail, testDirectoryURL); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a deactivated account, got %v", err)
+	}
+
+	replacement, err := database.GetOrCreateACMEAccount(testEmail, testDirectoryURL, testPrivKeyPEM, testRegURI+"2", testRegBody)
+	if err != nil {
+		t.Fatalf("GetOrCreateACMEAccount() unexpected error: %v", err)
+	}
+	if replacement.ID == account.ID {
+		t.Fatal("expected a new account to be registered in place of the deactivated one")
+	}
+	accounts, err := database.ListACMEAccounts()
+	if err != nil {
+		t.Fatalf("ListACMEAccounts() unexpected error: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Status != db.ACMEAccountStatusDeactivated || accounts[1].Status != db.ACMEAccountStatusValid {
+		t.Fatalf("expected the deactivated and the new account, got %+v", accounts)
+	}
+
+	if err := database.UpdateACMEAccountRegistration(100, testRegBody, db.ACMEAccountStatusValid); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestUpdateACMEServerUnlinksAccount(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	account, err := database.GetOrCreateACMEAccount(testEmail, testDirectoryURL, testPrivKeyPEM, testRegURI, testRegBody)
+	if err != nil {
+		t.Fatalf("GetOrCreateACMEAccount() unexpected error: %v", err)
+	}
+	serverID, err := database.CreateACMEServer("test", testDirectoryURL, testEmail, "", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
+	}
+	if err := database.LinkAccountToServer(serverID, account.ID); err != nil {
+		t.Fatalf("LinkAccountToServer() unexpected error: %v", err)
+	}
+
+	if err := database.UpdateACMEServer(serverID, "renamed", testDirectoryURL, testEmail, "", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{}); err != nil {
+		t.Fatalf("UpdateACMEServer() unexpected error: %v", err)
+	}
+	server, err := database.GetACMEServer(serverID)
+	if err != nil {
+		t.Fatalf("GetACMEServer() unexpected error: %v", err)
+	}
+	if server.ACMEAccountID == nil || *server.ACMEAccountID != account.ID {
+		t.Fatal("expected renaming the server to keep its account")
+	}
+
+	if err := database.UpdateACMEServer(serverID, "renamed", testDirectoryURL, "other@example.com", "", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{}); err != nil {
+		t.Fatalf("UpdateACMEServer() unexpected error: %v", err)
+	}
+	server, err = database.GetACMEServer(serverID)
+	if err != nil {
+		t.Fatalf("GetACMEServer() unexpected error: %v", err)
+	}
+	if server.ACMEAccountID != nil {
+		t.Fatal("expected changing the email of the server to unlink its account")
+	}
+}
+
+func TestACMEAccountPendingKey(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	account, err := database.GetOrCreateACMEAccount(testEmail, testDirectoryURL, testPrivKeyPEM, testRegURI, testRegBody)
+	if err != nil {
+		t.Fatalf("GetOrCreateACMEAccount() unexpected error: %v", err)
+	}
+	if err := database.PromoteACMEAccountPendingKey(account.ID); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound promoting an account without a pending key, got %v", err)
+	}
+
+	if err := database.SetACMEAccountPendingKey(account.ID, "discarded-key"); err != nil {
+		t.Fatalf("SetACMEAccountPendingKey() unexpected error: %v", err)
+	}
+	stored, err := database.GetDecryptedACMEAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEAccount() unexpected error: %v", err)
+	}
+	if stored.PrivateKeyPEM != testPrivKeyPEM || stored.PendingPrivateKeyPEM != "discarded-key" {
+		t.Fatalf("expected the current key and the pending key, got %q and %q", stored.PrivateKeyPEM, stored.PendingPrivateKeyPEM)
+	}
+	if err := database.ClearACMEAccountPendingKey(account.ID); err != nil {
+		t.Fatalf("ClearACMEAccountPendingKey() unexpected error: %v", err)
+	}
+	stored, err = database.GetDecryptedACMEAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEAccount() unexpected error: %v", err)
+	}
+	if stored.PrivateKeyPEM != testPrivKeyPEM || stored.PendingPrivateKeyPEM != "" {
+		t.Fatal("expected clearing the pending key to keep the current key")
+	}
+
+	if err := database.SetACMEAccountPendingKey(account.ID, "new-key"); err != nil {
+		t.Fatalf("SetACMEAccountPendingKey() unexpected error: %v", err)
+	}
+	if err := database.PromoteACMEAccountPendingKey(account.ID); err != nil {
+		t.Fatalf("PromoteACMEAccountPendingKey() unexpected error: %v", err)
+	}
+	stored, err = database.GetDecryptedACMEAccount(account.ID)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEAccount() unexpected error: %v", err)
+	}
+	if stored.PrivateKeyPEM != "new-key" || stored.PendingPrivateKeyPEM != "" {
+		t.Fatalf("expected the pending key to replace the current key, got %q and %q", stored.PrivateKeyPEM, stored.PendingPrivateKeyPEM)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/canonical/notary/internal/utils"
+)
+
+// CreateACMEDNSRegistration creates an account of the acme-dns compatible service. The password passed in should be
+// in plaintext, it is hashed before it is stored. allowFrom lists the networks that can update the record of the account.
+func (db *DatabaseRepository) CreateACMEDNSRegistration(username, password, subdomain string, allowFrom []string, domain, createdBy string) (int64, error) {
+	if username == "" || subdomain == "" {
+		return 0, fmt.Errorf("%w: username and subdomain can't be empty", ErrInvalidInput)
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to hash acme-dns password", ErrInternal)
+	}
+	row := ACMEDNSRegistration{
+		Username:       username,
+		HashedPassword: hashedPassword,
+		Subdomain:      subdomain,
+		AllowFrom:      strings.Join(allowFrom, ","),
+		Domain:         domain,
+		CreatedBy:      createdBy,
+		CreatedAt:      time.Now().Unix(),
+	}
+	return CreateEntity(db, db.stmts.CreateACMEDNSRegistration, row)
+}
+
+// ListACMEDNSRegistrations returns the accounts of the acme-dns compatible service.
+func (db *DatabaseRepository) ListACMEDNSRegistrations() ([]ACMEDNSRegistration, error) {
+	return ListEntities[ACMEDNSRegistration](db, db.stmts.ListACMEDNSRegistrations)
+}
+
+// GetACMEDNSRegistration gets an account of the acme-dns compatible service by its ID.
+func (db *DatabaseRepository) GetACMEDNSRegistration(id int64) (*ACMEDNSRegistration, error) {
+	return GetOneEntity[ACMEDNSRegistration](db, db.stmts.GetACMEDNSRegistration, ACMEDNSRegistration{ID: id})
+}
+
+// GetACMEDNSRegistrationByUsername gets an account of the acme-dns compatible service by its username.
+func (db *DatabaseRepository) GetACMEDNSRegistrationByUsername(username string) (*ACMEDNSRegistration, error) {
+	if username == "" {
+		return nil, fmt.Errorf("failed to get ACMEDNSRegistration: %w", ErrNotFound)
+	}
+	return GetOneEntity[ACMEDNSRegistration](db, db.stmts.GetACMEDNSRegistration, ACMEDNSRegistration{Username: username})
+}
+
+// GetACMEDNSRegistrationBySubdomain gets the account of the acme-dns compatible service that owns the subdomain.
+func (db *DatabaseRepository) GetACMEDNSRegistrationBySubdomain(subdomain string) (*ACMEDNSRegistration, error) {
+	if subdomain == "" {
+		return nil, fmt.Errorf("failed to get ACMEDNSRegistration: %w", ErrNotFound)
+	}
+	return GetOneEntity[ACMEDNSRegistration](db, db.stmts.GetACMEDNSRegistration, ACMEDNSRegistration{Subdomain: subdomain})
+}
+
+// GetACMEDNSRegistrationByDomain gets the account of the acme-dns compatible service that the challenges of domain
+// are delegated to.
+func (db *DatabaseRepository) GetACMEDNSRegistrationByDomain(domain string) (*ACMEDNSRegistration, error) {
+	return GetOneEntity[ACMEDNSRegistration](db, db.stmts.GetACMEDNSRegistrationByDomain, ACMEDNSRegistration{Domain: domain})
+}
+
+// SetACMEDNSRecord sets the TXT record of an account of the acme-dns compatible service. The previous value
+// is kept alongside it.
+func (db *DatabaseRepository) SetACMEDNSRecord(id int64, txt string) error {
+	return UpdateEntity(db, db.stmts.SetACMEDNSRecord, ACMEDNSRegistration{ID: id, TXT: txt, UpdatedAt: time.Now().Unix()})
+}
+
+// RemoveACMEDNSRecord removes txt from the values of the TXT record of an account of the acme-dns compatible service.
+func (db *DatabaseRepository) RemoveACMEDNSRecord(id int64, txt string) error {
+	return UpdateEntity(db, db.stmts.RemoveACMEDNSRecord, ACMEDNSRegistration{ID: id, TXT: txt, UpdatedAt: time.Now().Unix()})
+}
+
+// DeleteACMEDNSRegistration deletes an account of the acme-dns compatible service.
+func (db *DatabaseRepository) DeleteACMEDNSRegistration(id int64) error {
+	return DeleteEntity(db, db.stmts.DeleteACMEDNSRegistration, ACMEDNSRegistration{ID: id})
+}
@@ -0,0 +1,81 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/canonical/notary/internal/utils"
+)
+
+func TestACMEDNSRegistrationsEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	id, err := database.CreateACMEDNSRegistration("user-1", "secret", "sub-1", []string{"10.0.0.0/8", "192.0.2.0/24"}, "example.com", "admin@example.com")
+	if err != nil {
+		t.Fatalf("CreateACMEDNSRegistration() unexpected error: %s", err)
+	}
+	if _, err := database.CreateACMEDNSRegistration("user-2", "secret", "sub-2", nil, "example.com", ""); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("expected a second registration for the same domain to fail with ErrAlreadyExists, got %v", err)
+	}
+	if _, err := database.CreateACMEDNSRegistration("user-3", "secret", "sub-3", nil, "", ""); err != nil {
+		t.Fatalf("CreateACMEDNSRegistration() unexpected error: %s", err)
+	}
+	if _, err := database.CreateACMEDNSRegistration("user-4", "secret", "sub-4", nil, "", ""); err != nil {
+		t.Fatalf("expected registrations without a domain not to conflict, got %s", err)
+	}
+
+	registration, err := database.GetACMEDNSRegistrationByUsername("user-1")
+	if err != nil {
+		t.Fatalf("GetACMEDNSRegistrationByUsername() unexpected error: %s", err)
+	}
+	if registration.ID != id || registration.Subdomain != "sub-1" || registration.AllowFrom != "10.0.0.0/8,192.0.2.0/24" {
+		t.Fatalf("unexpected registration: %+v", registration)
+	}
+	if err := utils.CompareHashAndPassword(registration.HashedPassword, "secret"); err != nil {
+		t.Fatalf("expected the hashed password to match: %s", err)
+	}
+	if _, err := database.GetACMEDNSRegistrationByDomain(""); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected registrations without a domain not to be found by domain, got %v", err)
+	}
+
+	if err := database.SetACMEDNSRecord(id, "first"); err != nil {
+		t.Fatalf("SetACMEDNSRecord() unexpected error: %s", err)
+	}
+	if err := database.SetACMEDNSRecord(id, "second"); err != nil {
+		t.Fatalf("SetACMEDNSRecord() unexpected error: %s", err)
+	}
+	registration, err = database.GetACMEDNSRegistrationBySubdomain("sub-1")
+	if err != nil {
+		t.Fatalf("GetACMEDNSRegistrationBySubdomain() unexpected error: %s", err)
+	}
+	if registration.TXT != "second" || registration.PreviousTXT != "first" || registration.UpdatedAt == 0 {
+		t.Fatalf("expected the two most recent values, got %+v", registration)
+	}
+
+	if err := database.RemoveACMEDNSRecord(id, "first"); err != nil {
+		t.Fatalf("RemoveACMEDNSRecord() unexpected error: %s", err)
+	}
+	registration, err = database.GetACMEDNSRegistrationByDomain("example.com")
+	if err != nil {
+		t.Fatalf("GetACMEDNSRegistrationByDomain() unexpected error: %s", err)
+	}
+	if registration.TXT != "second" || registration.PreviousTXT != "" {
+		t.Fatalf("expected only the removed value to be cleared, got %+v", registration)
+	}
+
+	if err := database.DeleteACMEDNSRegistration(id); err != nil {
+		t.Fatalf("DeleteACMEDNSRegistration() unexpected error: %s", err)
+	}
+	if _, err := database.GetACMEDNSRegistration(id); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the registration to be deleted, got %v", err)
+	}
+	registrations, err := database.ListACMEDNSRegistrations()
+	if err != nil {
+		t.Fatalf("ListACMEDNSRegistrations() unexpected error: %s", err)
+	}
+	if len(registrations) != 2 {
+		t.Fatalf("expected 2 registrations, got %d", len(registrations))
+	}
+}
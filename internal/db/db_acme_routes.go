@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ListACMERoutes returns the ACME routes in the order they are matched.
+func (db *DatabaseRepository) ListACMERoutes() ([]ACMERoute, error) {
+	return ListEntities[ACMERoute](db, db.stmts.ListACMERoutes)
+}
+
+// ReplaceACMERoutes replaces every ACME route with routes, which are matched in the given order.
+// It returns ErrInvalidInput if a route sends certificate requests to an ACME server that doesn't exist.
+func (db *DatabaseRepository) ReplaceACMERoutes(routes []ACMERoute) error {
+	tx, err := db.Conn.PlainDB().BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace ACME routes: %w", ErrInternal)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := replaceACMERoutes(tx, routes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace ACME routes: %w", ErrInternal)
+	}
+	return nil
+}
+
+// replaceACMERoutes replaces every ACME route with routes in the transaction. Their positions follow their order.
+func replaceACMERoutes(tx *sql.Tx, routes []ACMERoute) error {
+	if _, err := tx.Exec("DELETE FROM acme_routes"); err != nil {
+		return fmt.Errorf("failed to delete ACME routes: %w", ErrInternal)
+	}
+	for i, route := range routes {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM acme_servers WHERE id = ?)", route.ACMEServerID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check ACME server: %w", ErrInternal)
+		}
+		if !exists {
+			return fmt.Errorf("%w: ACME server %d does not exist", ErrInvalidInput, route.ACMEServerID)
+		}
+		_, err := tx.Exec("INSERT INTO acme_routes (position, domain_suffix, acme_server_id) VALUES (?, ?, ?)", i, route.DomainSuffix, route.ACMEServerID)
+		if err != nil {
+			return fmt.Errorf("failed to create ACME route: %w", ErrInternal)
+		}
+	}
+	return nil
+}
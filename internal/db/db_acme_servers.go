@@ -4,21 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/canonical/notary/internal/utils"
 )
 
-func (db *DatabaseRepository) CreateACMEServer(name, directoryURL, email, dnsProvider string, envVars map[string]string) (int64, error) {
+func (db *DatabaseRepository) CreateACMEServer(name, directoryURL, email, dnsProvider string, envVars map[string]string, challenge ACMEChallenge, eab ACMEExternalAccountBinding, order ACMEOrderOptions) (int64, error) {
 	encryptedEnvVars, err := encryptEnvVars(envVars, db.EncryptionKey)
 	if err != nil {
 		return 0, err
 	}
-	row := ACMEServer{
-		Name:         name,
-		DirectoryURL: directoryURL,
-		Email:        email,
-		DNSProvider:  dnsProvider,
-		EnvVars:      encryptedEnvVars,
+	encryptedEAB, err := encryptExternalAccountBinding(eab, db.EncryptionKey)
+	if err != nil {
+		return 0, err
+	}
+	row, err := withDNSSettings(ACMEServer{
+		Name:           name,
+		DirectoryURL:   directoryURL,
+		Email:          email,
+		DNSProvider:    dnsProvider,
+		EnvVars:        encryptedEnvVars,
+		ChallengeType:  challengeTypeOrDefault(challenge.Type),
+		ChallengePort:  challenge.Port,
+		Webroot:        challenge.Webroot,
+		EABKeyID:       encryptedEAB.KeyID,
+		EABHMACKey:     encryptedEAB.HMACKey,
+		PreferredChain: order.PreferredChain,
+		Profile:        order.Profile,
+	}, challenge.DNS)
+	if err != nil {
+		return 0, err
 	}
 	return CreateEntity[ACMEServer](db, db.stmts.CreateACMEServer, row)
 }
@@ -40,35 +55,39 @@ func (db *DatabaseRepository) GetDecryptedACMEServer(id int64) (*ACMEServer, err
 	return decryptServerEnvVars(server, db.EncryptionKey)
 }
 
-func (db *DatabaseRepository) GetActiveACMEServer() (*ACMEServer, error) {
-	return GetOneEntity[ACMEServer](db, db.stmts.GetActiveACMEServer)
-}
-
-func (db *DatabaseRepository) GetDecryptedActiveACMEServer() (*ACMEServer, error) {
-	server, err := db.GetActiveACMEServer()
+func (db *DatabaseRepository) UpdateACMEServer(id int64, name, directoryURL, email, dnsProvider string, envVars map[string]string, challenge ACMEChallenge, eab ACMEExternalAccountBinding, order ACMEOrderOptions) error {
+	encryptedEnvVars, err := encryptEnvVars(envVars, db.EncryptionKey)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return decryptServerEnvVars(server, db.EncryptionKey)
-}
-
-func (db *DatabaseRepository) UpdateACMEServer(id int64, name, directoryURL, email, dnsProvider string, envVars map[string]string) error {
-	encryptedEnvVars, err := encryptEnvVars(envVars, db.EncryptionKey)
+	encryptedEAB, err := encryptExternalAccountBinding(eab, db.EncryptionKey)
 	if err != nil {
 		return err
 	}
-	row := ACMEServer{
-		ID:           id,
-		Name:         name,
-		DirectoryURL: directoryURL,
-		Email:        email,
-		DNSProvider:  dnsProvider,
-		EnvVars:      encryptedEnvVars,
+	row, err := withDNSSettings(ACMEServer{
+		ID:             id,
+		Name:           name,
+		DirectoryURL:   directoryURL,
+		Email:          email,
+		DNSProvider:    dnsProvider,
+		EnvVars:        encryptedEnvVars,
+		ChallengeType:  challengeTypeOrDefault(challenge.Type),
+		ChallengePort:  challenge.Port,
+		Webroot:        challenge.Webroot,
+		EABKeyID:       encryptedEAB.KeyID,
+		EABHMACKey:     encryptedEAB.HMACKey,
+		PreferredChain: order.PreferredChain,
+		Profile:        order.Profile,
+	}, challenge.DNS)
+	if err != nil {
+		return err
 	}
 	return UpdateEntity[ACMEServer](db, db.stmts.UpdateACMEServer, row)
 }
 
-// SetActiveACMEServer deactivates all servers then activates the given ID in a transaction.
+// SetActiveACMEServer sends the certificate requests that no other route matches to the given ID.
+// The route with an empty domain suffix is pointed to the server, or added after the other routes if there is none.
+// The other routes are kept.
 func (db *DatabaseRepository) SetActiveACMEServer(id int64) error {
 	tx, err := db.Conn.PlainDB().BeginTx(context.Background(), nil)
 	if err != nil {
@@ -76,20 +95,26 @@ func (db *DatabaseRepository) SetActiveACMEServer(id int64) error {
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	if _, err := tx.Exec("UPDATE acme_servers SET active = 0"); err != nil {
-		return fmt.Errorf("failed to deactivate ACME servers: %w", ErrInternal)
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM acme_servers WHERE id = ?)", id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to activate ACME server: %w", ErrInternal)
 	}
-
-	result, err := tx.Exec("UPDATE acme_servers SET active = 1 WHERE id = ?", id)
+	if !exists {
+		return fmt.Errorf("failed to activate ACME server: %w", ErrNotFound)
+	}
+	result, err := tx.Exec("UPDATE acme_routes SET acme_server_id = ? WHERE domain_suffix = ''", id)
 	if err != nil {
-		return fmt.Errorf("failed to activate ACME server: %w", ErrInternal)
+		return fmt.Errorf("failed to update the default ACME route: %w", ErrInternal)
 	}
-	affected, err := result.RowsAffected()
+	updated, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to activate ACME server: %w", ErrInternal)
+		return fmt.Errorf("failed to update the default ACME route: %w", ErrInternal)
 	}
-	if affected == 0 {
-		return fmt.Errorf("failed to activate ACME server: %w", ErrNotFound)
+	if updated == 0 {
+		_, err := tx.Exec("INSERT INTO acme_routes (position, domain_suffix, acme_server_id) SELECT COALESCE(MAX(position) + 1, 0), '', ? FROM acme_routes", id)
+		if err != nil {
+			return fmt.Errorf("failed to create the default ACME route: %w", ErrInternal)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -98,9 +123,36 @@ func (db *DatabaseRepository) SetActiveACMEServer(id int64) error {
 	return nil
 }
 
+// DeleteACMEServer deletes the ACME server with the given ID.
+// It returns ErrInUse if a route sends certificate requests to the server.
 func (db *DatabaseRepository) DeleteACMEServer(id int64) error {
-	row := ACMEServer{ID: id}
-	return DeleteEntity[ACMEServer](db, db.stmts.DeleteACMEServer, row)
+	result, err := db.Conn.PlainDB().Exec("DELETE FROM acme_servers WHERE id = ? AND NOT EXISTS (SELECT 1 FROM acme_routes WHERE acme_server_id = ?)", id, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete ACME server: %w", ErrInternal)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete ACME server: %w", ErrInternal)
+	}
+	if affected > 0 {
+		return nil
+	}
+	var exists bool
+	if err := db.Conn.PlainDB().QueryRow("SELECT EXISTS (SELECT 1 FROM acme_servers WHERE id = ?)", id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to delete ACME server: %w", ErrInternal)
+	}
+	if exists {
+		return fmt.Errorf("failed to delete ACME server: %w: ACME routes send certificate requests to it", ErrInUse)
+	}
+	return fmt.Errorf("failed to delete ACME server: %w", ErrNotFound)
+}
+
+// challengeTypeOrDefault keeps servers created without a challenge type on DNS-01, the only type supported before.
+func challengeTypeOrDefault(challengeType string) string {
+	if challengeType == "" {
+		return ACMEChallengeDNS01
+	}
+	return challengeType
 }
 
 func encryptEnvVars(envVars map[string]string, key []byte) (string, error) {
@@ -121,5 +173,47 @@ func decryptServerEnvVars(server *ACMEServer, key []byte) (*ACMEServer, error) {
 		return nil, fmt.Errorf("%w: failed to decrypt env vars", ErrInternal)
 	}
 	server.EnvVars = decrypted
+	if server.EABKeyID != "" {
+		if server.EABKeyID, err = utils.Decrypt(server.EABKeyID, key); err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt external account binding", ErrInternal)
+		}
+		if server.EABHMACKey, err = utils.Decrypt(server.EABHMACKey, key); err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt external account binding", ErrInternal)
+		}
+	}
 	return server, nil
 }
+
+// encryptExternalAccountBinding encrypts the External Account Binding credentials.
+// Servers without External Account Binding store empty values.
+func encryptExternalAccountBinding(eab ACMEExternalAccountBinding, key []byte) (ACMEExternalAccountBinding, error) {
+	if !eab.IsSet() {
+		return ACMEExternalAccountBinding{}, nil
+	}
+	keyID, err := utils.Encrypt(eab.KeyID, key)
+	if err != nil {
+		return ACMEExternalAccountBinding{}, fmt.Errorf("%w: failed to encrypt external account binding", ErrInternal)
+	}
+	hmacKey, err := utils.Encrypt(eab.HMACKey, key)
+	if err != nil {
+		return ACMEExternalAccountBinding{}, fmt.Errorf("%w: failed to encrypt external account binding", ErrInternal)
+	}
+	return ACMEExternalAccountBinding{KeyID: keyID, HMACKey: hmacKey}, nil
+}
+
+// withDNSSettings sets the columns of the DNS-01 settings on the row of an ACME server.
+func withDNSSettings(row ACMEServer, dns ACMEDNSSettings) (ACMEServer, error) {
+	row.DNSResolvers = strings.Join(dns.Resolvers, ",")
+	row.DNSPropagationTimeout = dns.PropagationTimeout
+	row.DNSPollingInterval = dns.PollingInterval
+	row.DNSSkipAuthoritativeCheck = dns.SkipAuthoritativeCheck
+	row.DNSChallengeAliases = ""
+	if len(dns.ChallengeAliases) > 0 {
+		aliases, err := json.Marshal(dns.ChallengeAliases)
+		if err != nil {
+			return row, fmt.Errorf("%w: failed to marshal DNS challenge aliases", ErrInternal)
+		}
+		row.DNSChallengeAliases = string(aliases)
+	}
+	return row, nil
+}
@@ -11,7 +11,7 @@ import (
 func TestCreateAndListACMEServers(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	id1, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{"AWS_REGION": "us-east-1"})
+	id1, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{"AWS_REGION": "us-east-1"}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
@@ -19,7 +19,7 @@ func TestCreateAndListACMEServers(t *testing.T) {
 		t.Fatal("expected non-zero ID")
 	}
 
-	_, err = database.CreateACMEServer("staging", "https://acme-staging-v02.api.letsencrypt.org/directory", "admin@example.com", "cloudflare", map[string]string{"CF_TOKEN": "secret"})
+	_, err = database.CreateACMEServer("staging", "https://acme-staging-v02.api.letsencrypt.org/directory", "admin@example.com", "cloudflare", map[string]string{"CF_TOKEN": "secret"}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() second server unexpected error: %v", err)
 	}
@@ -36,7 +36,7 @@ func TestCreateAndListACMEServers(t *testing.T) {
 func TestGetACMEServer(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	id, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{})
+	id, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
@@ -67,7 +67,7 @@ func TestGetACMEServer(t *testing.T) {
 func TestDeleteACMEServer(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	id, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{})
+	id, err := database.CreateACMEServer("letsencrypt", "https://acme-v02.api.letsencrypt.org/directory", "admin@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
@@ -89,11 +89,11 @@ func TestDeleteACMEServer(t *testing.T) {
 func TestSetActiveACMEServer(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	id1, err := database.CreateACMEServer("server1", "https://acme1.example.com/directory", "a@example.com", "route53", map[string]string{})
+	id1, err := database.CreateACMEServer("server1", "https://acme1.example.com/directory", "a@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() 1 unexpected error: %v", err)
 	}
-	id2, err := database.CreateACMEServer("server2", "https://acme2.example.com/directory", "b@example.com", "cloudflare", map[string]string{})
+	id2, err := database.CreateACMEServer("server2", "https://acme2.example.com/directory", "b@example.com", "cloudflare", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() 2 unexpected error: %v", err)
 	}
@@ -125,12 +125,89 @@ func TestSetActiveACMEServer(t *testing.T) {
 	}
 }
 
-func TestGetActiveACMEServer_NoneActive(t *testing.T) {
+func TestACMERoutes(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	_, err := database.GetActiveACMEServer()
-	if !errors.Is(err, db.ErrNotFound) {
-		t.Errorf("expected ErrNotFound when no active server, got %v", err)
+	routes, err := database.ListACMERoutes()
+	if err != nil {
+		t.Fatalf("ListACMERoutes() unexpected error: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Fatalf("expected no ACME routes, got %+v", routes)
+	}
+
+	public, err := database.CreateACMEServer("public", "https://acme1.example.com/directory", "a@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() 1 unexpected error: %v", err)
+	}
+	internal, err := database.CreateACMEServer("internal", "https://acme2.example.com/directory", "b@example.com", "cloudflare", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() 2 unexpected error: %v", err)
+	}
+
+	err = database.ReplaceACMERoutes([]db.ACMERoute{
+		{DomainSuffix: "corp", ACMEServerID: internal},
+		{DomainSuffix: "", ACMEServerID: public},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceACMERoutes() unexpected error: %v", err)
+	}
+	routes, err = database.ListACMERoutes()
+	if err != nil {
+		t.Fatalf("ListACMERoutes() unexpected error: %v", err)
+	}
+	if len(routes) != 2 || routes[0].DomainSuffix != "corp" || routes[0].ACMEServerID != internal || routes[1].ACMEServerID != public {
+		t.Fatalf("expected the routes in the given order, got %+v", routes)
+	}
+	s, err := database.GetACMEServer(internal)
+	if err != nil {
+		t.Fatalf("GetACMEServer() unexpected error: %v", err)
+	}
+	if !s.Active {
+		t.Error("expected a routed server to be active")
+	}
+
+	// Routes to a missing server are rejected and the existing routes are kept.
+	err = database.ReplaceACMERoutes([]db.ACMERoute{{DomainSuffix: "", ACMEServerID: 100}})
+	if !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for a missing ACME server, got %v", err)
+	}
+	routes, _ = database.ListACMERoutes()
+	if len(routes) != 2 {
+		t.Fatalf("expected the routes to be kept, got %+v", routes)
+	}
+
+	// A routed server can't be deleted.
+	if err := database.DeleteACMEServer(internal); !errors.Is(err, db.ErrInUse) {
+		t.Fatalf("expected ErrInUse when deleting a routed server, got %v", err)
+	}
+	routes, _ = database.ListACMERoutes()
+	if len(routes) != 2 {
+		t.Fatalf("expected the routes to be kept, got %+v", routes)
+	}
+
+	// Setting a server active only replaces the default route.
+	if err := database.SetActiveACMEServer(internal); err != nil {
+		t.Fatalf("SetActiveACMEServer() unexpected error: %v", err)
+	}
+	routes, _ = database.ListACMERoutes()
+	if len(routes) != 2 || routes[0].DomainSuffix != "corp" || routes[1].DomainSuffix != "" || routes[1].ACMEServerID != internal {
+		t.Fatalf("expected the default route to be replaced, got %+v", routes)
+	}
+	s, _ = database.GetACMEServer(public)
+	if s.Active {
+		t.Error("expected a server without routes to be inactive")
+	}
+	if err := database.DeleteACMEServer(public); err != nil {
+		t.Fatalf("DeleteACMEServer() unexpected error: %v", err)
+	}
+
+	if err := database.ReplaceACMERoutes(nil); err != nil {
+		t.Fatalf("ReplaceACMERoutes() unexpected error: %v", err)
+	}
+	s, _ = database.GetACMEServer(internal)
+	if s.Active {
+		t.Error("expected a server without routes to be inactive")
 	}
 }
 
@@ -141,7 +218,7 @@ func TestGetDecryptedACMEServer_EnvVarsEncryptedAtRest(t *testing.T) {
 		"SECRET_KEY": "super-secret-value",
 		"REGION":     "us-east-1",
 	}
-	id, err := database.CreateACMEServer("myserver", "https://acme.example.com/directory", "admin@example.com", "route53", envVars)
+	id, err := database.CreateACMEServer("myserver", "https://acme.example.com/directory", "admin@example.com", "route53", envVars, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
@@ -173,12 +250,12 @@ func TestGetDecryptedACMEServer_EnvVarsEncryptedAtRest(t *testing.T) {
 func TestUpdateACMEServer(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	id, err := database.CreateACMEServer("original", "https://acme.example.com/directory", "old@example.com", "route53", map[string]string{"KEY": "val"})
+	id, err := database.CreateACMEServer("original", "https://acme.example.com/directory", "old@example.com", "route53", map[string]string{"KEY": "val"}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
 
-	err = database.UpdateACMEServer(id, "updated", "https://new.acme.example.com/directory", "new@example.com", "cloudflare", map[string]string{"NEW_KEY": "new_val"})
+	err = database.UpdateACMEServer(id, "updated", "https://new.acme.example.com/directory", "new@example.com", "cloudflare", map[string]string{"NEW_KEY": "new_val"}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("UpdateACMEServer() unexpected error: %v", err)
 	}
@@ -197,7 +274,7 @@ func TestUpdateACMEServer(t *testing.T) {
 		t.Errorf("expected dns_provider %q, got %q", "cloudflare", server.DNSProvider)
 	}
 
-	err = database.UpdateACMEServer(99999, "x", "x", "x", "x", map[string]string{})
+	err = database.UpdateACMEServer(99999, "x", "x", "x", "x", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if !errors.Is(err, db.ErrNotFound) {
 		t.Errorf("expected ErrNotFound for missing server, got %v", err)
 	}
@@ -206,7 +283,7 @@ func TestUpdateACMEServer(t *testing.T) {
 func TestLinkAccountToServer(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
-	serverID, err := database.CreateACMEServer("myserver", "https://acme.example.com/directory", "admin@example.com", "route53", map[string]string{})
+	serverID, err := database.CreateACMEServer("myserver", "https://acme.example.com/directory", "admin@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{})
 	if err != nil {
 		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
 	}
@@ -231,3 +308,40 @@ func TestLinkAccountToServer(t *testing.T) {
 		t.Errorf("expected acme_account_id %d, got %d", account.ID, *server.ACMEAccountID)
 	}
 }
+
+func TestACMEServerExternalAccountBindingEncryptedAtRest(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	eab := db.ACMEExternalAccountBinding{KeyID: "kid-1", HMACKey: "c2VjcmV0LWhtYWMta2V5"}
+	id, err := database.CreateACMEServer("zerossl", "https://acme.zerossl.com/v2/DV90", "admin@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, eab, db.ACMEOrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() unexpected error: %v", err)
+	}
+
+	raw, err := database.GetACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetACMEServer() unexpected error: %v", err)
+	}
+	if raw.EABKeyID == eab.KeyID || raw.EABHMACKey == eab.HMACKey {
+		t.Fatal("expected External Account Binding to be encrypted at rest")
+	}
+
+	decrypted, err := database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %v", err)
+	}
+	if decrypted.ExternalAccountBinding() != eab {
+		t.Fatalf("expected decrypted binding %+v, got %+v", eab, decrypted.ExternalAccountBinding())
+	}
+
+	if err := database.UpdateACMEServer(id, "zerossl", "https://acme.zerossl.com/v2/DV90", "admin@example.com", "route53", map[string]string{}, db.ACMEChallenge{}, db.ACMEExternalAccountBinding{}, db.ACMEOrderOptions{}); err != nil {
+		t.Fatalf("UpdateACMEServer() unexpected error: %v", err)
+	}
+	decrypted, err = database.GetDecryptedACMEServer(id)
+	if err != nil {
+		t.Fatalf("GetDecryptedACMEServer() unexpected error: %v", err)
+	}
+	if decrypted.ExternalAccountBinding().IsSet() {
+		t.Fatal("expected External Account Binding to be removed")
+	}
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateServiceAccount creates an account without a password, for automation that authenticates with API tokens.
+func (db *DatabaseRepository) CreateServiceAccount(name string, roleID RoleID) (int64, error) {
+	if err := ValidateUser(name, roleID); err != nil {
+		return 0, err
+	}
+	return CreateEntity(db, db.stmts.CreateServiceAccount, User{Email: name, RoleID: roleID})
+}
+
+// CreateAPIToken creates an API token of the user. hashedSecret is the hash of the secret of the token,
+// which isn't stored. The token is limited to certificateAuthorityIDs, unless it is empty.
+func (db *DatabaseRepository) CreateAPIToken(userID int64, name, tokenID, hashedSecret string, scopes []string, certificateAuthorityIDs []int64, expiresAt time.Time, createdBy string) (int64, error) {
+	if name == "" || tokenID == "" || hashedSecret == "" || len(scopes) == 0 {
+		return 0, fmt.Errorf("%w: name, token ID, secret and scopes can't be empty", ErrInvalidInput)
+	}
+	ids := make([]string, 0, len(certificateAuthorityIDs))
+	for _, id := range certificateAuthorityIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	row := APIToken{
+		UserID:                  userID,
+		Name:                    name,
+		TokenID:                 tokenID,
+		HashedSecret:            hashedSecret,
+		Scopes:                  strings.Join(scopes, ","),
+		CertificateAuthorityIDs: strings.Join(ids, ","),
+		ExpiresAt:               expiresAt.Unix(),
+		CreatedBy:               createdBy,
+		CreatedAt:               time.Now().Unix(),
+	}
+	return CreateEntity(db, db.stmts.CreateAPIToken, row)
+}
+
+// ListAPITokens returns the API tokens of the user.
+func (db *DatabaseRepository) ListAPITokens(userID int64) ([]APIToken, error) {
+	return ListEntities[APIToken](db, db.stmts.ListAPITokens, APIToken{UserID: userID})
+}
+
+// GetAPIToken gets an API token of the user by its ID.
+func (db *DatabaseRepository) GetAPIToken(userID, id int64) (*APIToken, error) {
+	return GetOneEntity[APIToken](db, db.stmts.GetAPIToken, APIToken{ID: id, UserID: userID})
+}
+
+// GetAPITokenByTokenID gets an API token by the public part of the token.
+func (db *DatabaseRepository) GetAPITokenByTokenID(tokenID string) (*APIToken, error) {
+	return GetOneEntity[APIToken](db, db.stmts.GetAPITokenByTokenID, APIToken{TokenID: tokenID})
+}
+
+// UpdateAPITokenLastUse records when an API token was last used.
+func (db *DatabaseRepository) UpdateAPITokenLastUse(id int64, usedAt time.Time) error {
+	return UpdateEntity(db, db.stmts.UpdateAPITokenLastUse, APIToken{ID: id, LastUsedAt: usedAt.Unix()})
+}
+
+// DeleteAPIToken deletes an API token of the user, which revokes it.
+func (db *DatabaseRepository) DeleteAPIToken(userID, id int64) error {
+	return DeleteEntity(db, db.stmts.DeleteAPIToken, APIToken{ID: id, UserID: userID})
+}
+
+// ScopeList returns the scopes of the token.
+func (t *APIToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// CertificateAuthorityIDList returns the certificate authorities that the token is limited to.
+func (t *APIToken) CertificateAuthorityIDList() []int64 {
+	ids := []int64{}
+	for _, id := range strings.Split(t.CertificateAuthorityIDs, ",") {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			ids = append(ids, n)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,99 @@
+package db_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestAPITokensEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	if _, err := database.CreateUser("admin@example.com", "Admin1234!", db.RoleAdmin); err != nil {
+		t.Fatalf("CreateUser() unexpected error: %s", err)
+	}
+	userID, err := database.CreateServiceAccount("ci-pipeline", db.RoleCertificateRequestor)
+	if err != nil {
+		t.Fatalf("CreateServiceAccount() unexpected error: %s", err)
+	}
+	if _, err := database.CreateServiceAccount("ci-pipeline", db.RoleReadOnly); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("expected a duplicate service account to fail with ErrAlreadyExists, got %v", err)
+	}
+	user, err := database.GetUser(db.ByUserID(userID))
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %s", err)
+	}
+	if !user.ServiceAccount || user.HasPassword() {
+		t.Fatalf("expected a service account without a password, got %+v", user)
+	}
+	num, err := database.NumUsers()
+	if err != nil {
+		t.Fatalf("NumUsers() unexpected error: %s", err)
+	}
+	if num != 1 {
+		t.Fatalf("expected service accounts not to be counted as users, got %d", num)
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	id, err := database.CreateAPIToken(userID, "deploy", "0123456789abcdef", "hash", []string{"certificate_requests:read", "certificate_requests:submit"}, []int64{1, 3}, expiresAt, "admin@example.com")
+	if err != nil {
+		t.Fatalf("CreateAPIToken() unexpected error: %s", err)
+	}
+	if _, err := database.CreateAPIToken(userID, "deploy", "fedcba9876543210", "hash", []string{"certificate_requests:read"}, nil, expiresAt, ""); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("expected a duplicate token name to fail with ErrAlreadyExists, got %v", err)
+	}
+	if _, err := database.CreateAPIToken(userID, "empty", "fedcba9876543210", "hash", nil, nil, expiresAt, ""); !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("expected a token without scopes to fail with ErrInvalidInput, got %v", err)
+	}
+
+	token, err := database.GetAPITokenByTokenID("0123456789abcdef")
+	if err != nil {
+		t.Fatalf("GetAPITokenByTokenID() unexpected error: %s", err)
+	}
+	if token.ID != id || token.UserID != userID || token.ExpiresAt != expiresAt.Unix() || token.LastUsedAt != 0 {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+	if !slices.Equal(token.ScopeList(), []string{"certificate_requests:read", "certificate_requests:submit"}) || !slices.Equal(token.CertificateAuthorityIDList(), []int64{1, 3}) {
+		t.Fatalf("unexpected scopes or certificate authorities: %+v", token)
+	}
+
+	usedAt := time.Now()
+	if err := database.UpdateAPITokenLastUse(id, usedAt); err != nil {
+		t.Fatalf("UpdateAPITokenLastUse() unexpected error: %s", err)
+	}
+	token, err = database.GetAPIToken(userID, id)
+	if err != nil {
+		t.Fatalf("GetAPIToken() unexpected error: %s", err)
+	}
+	if token.LastUsedAt != usedAt.Unix() {
+		t.Fatalf("expected the last use to be recorded, got %d", token.LastUsedAt)
+	}
+	if _, err := database.GetAPIToken(userID+1, id); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the token not to be found for another user, got %v", err)
+	}
+
+	if err := database.DeleteAPIToken(userID, id); err != nil {
+		t.Fatalf("DeleteAPIToken() unexpected error: %s", err)
+	}
+	tokens, err := database.ListAPITokens(userID)
+	if err != nil {
+		t.Fatalf("ListAPITokens() unexpected error: %s", err)
+	}
+	if len(tokens) != 0 {
+		t.Fatalf("expected no tokens after deletion, got %+v", tokens)
+	}
+
+	if _, err := database.CreateAPIToken(userID, "deploy", "0123456789abcdef", "hash", []string{"certificate_requests:read"}, nil, expiresAt, ""); err != nil {
+		t.Fatalf("CreateAPIToken() unexpected error: %s", err)
+	}
+	if err := database.DeleteUser(db.ByUserID(userID)); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %s", err)
+	}
+	if _, err := database.GetAPITokenByTokenID("0123456789abcdef"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the tokens of a deleted service account to be deleted, got %v", err)
+	}
+}
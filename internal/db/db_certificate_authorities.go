@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -11,6 +13,11 @@ import (
 	"time"
 )
 
+var (
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageTimeStamping   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
 // ListCertificateAuthorities gets every Certificate Authority entry in the table.
 func (db *DatabaseRepository) ListCertificateAuthorities() ([]CertificateAuthority, error) {
 	return ListEntities[CertificateAuthority](db, db.stmts.ListCertificateAuthorities)
@@ -139,6 +146,40 @@ func (db *DatabaseRepository) UpdateCertificateAuthorityEnabledStatus(filter Cer
 	return UpdateEntity(db, db.stmts.UpdateCertificateAuthority, ca)
 }
 
+// UpdateCertificateAuthorityAutoRenew opts the certificates issued by a certificate authority in or out of automatic renewal.
+func (db *DatabaseRepository) UpdateCertificateAuthorityAutoRenew(filter CertificateAuthorityFilter, autoRenew bool) error {
+	ca, err := db.GetCertificateAuthority(filter)
+	if err != nil {
+		return err
+	}
+	ca.AutoRenew = autoRenew
+	return UpdateEntity(db, db.stmts.SetCertificateAuthorityAutoRenew, ca)
+}
+
+// GetIssuingCertificateAuthority gets the certificate authority that issued the certificate of a certificate request.
+// It returns ErrNotFound if the certificate was not issued by a Notary certificate authority.
+func (db *DatabaseRepository) GetIssuingCertificateAuthority(filter CSRFilter) (*CertificateAuthority, error) {
+	csrRow, err := db.GetCertificateRequestAndChain(filter)
+	if err != nil {
+		return nil, err
+	}
+	if csrRow.CertificateChain == "" {
+		return nil, fmt.Errorf("%w: certificate request has no certificate", ErrNotFound)
+	}
+	certChain, err := SplitCertificateBundle(csrRow.CertificateChain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: couldn't process certificate chain", ErrInternal)
+	}
+	if len(certChain) < 2 {
+		return nil, fmt.Errorf("%w: certificate has no issuer in its chain", ErrNotFound)
+	}
+	issuerCert, err := db.GetCertificate(ByCertificatePEM(certChain[1]))
+	if err != nil {
+		return nil, err
+	}
+	return db.GetCertificateAuthority(ByCertificateAuthorityCertificateID(issuerCert.CertificateID))
+}
+
 // UpdateCertificateAuthorityCRL updates the CRL of a certificate authority.
 func (db *DatabaseRepository) UpdateCertificateAuthorityCRL(filter CertificateAuthorityFilter, crl string) error {
 	ca, err := db.GetCertificateAuthority(filter)
@@ -166,9 +207,31 @@ func (db *DatabaseRepository) DeleteCertificateAuthority(filter CertificateAutho
 	return db.DeletePrivateKey(ByPrivateKeyID(caRow.PrivateKeyID))
 }
 
+// SignOption changes the certificate issued by SignCertificateRequest.
+type SignOption func(*x509.Certificate)
+
+// WithNotAfter sets the end of the validity of the certificate.
+func WithNotAfter(notAfter time.Time) SignOption {
+	return func(cert *x509.Certificate) {
+		cert.NotAfter = notAfter
+	}
+}
+
+// WithTimestampingUsage issues a certificate for an RFC 3161 timestamping authority,
+// whose extended key usage must be critical and only contain timeStamping.
+func WithTimestampingUsage() SignOption {
+	return func(cert *x509.Certificate) {
+		cert.KeyUsage = x509.KeyUsageDigitalSignature
+		cert.ExtKeyUsage = nil
+		// crypto/x509 never marks the extended key usage as critical, so the extension is written out.
+		value, _ := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimeStamping})
+		cert.ExtraExtensions = append(cert.ExtraExtensions, pkix.Extension{Id: oidExtensionExtendedKeyUsage, Critical: true, Value: value})
+	}
+}
+
 // SignCertificateRequest receives a CSR and a certificate authority.
 // The CSR filter finds the CSR to sign. the CA Filter finds the CA that will issue the certificate.
-func (db *DatabaseRepository) SignCertificateRequest(csrFilter CSRFilter, caFilter CertificateAuthorityDenormalizedFilter, externalHostname string) error {
+func (db *DatabaseRepository) SignCertificateRequest(csrFilter CSRFilter, caFilter CertificateAuthorityDenormalizedFilter, externalHostname string, opts ...SignOption) error {
 	csrRow, err := db.GetCertificateRequest(csrFilter)
 	if err != nil {
 		return err
@@ -239,6 +302,10 @@ func (db *DatabaseRepository) SignCertificateRequest(csrFilter CSRFilter, caFilt
 		CRLDistributionPoints: []string{fmt.Sprintf("https://%s/api/v1/certificate_authorities/%d/crl", externalHostname, caRow.CertificateAuthorityID)},
 	}
 
+	for _, opt := range opts {
+		opt(certTemplate)
+	}
+
 	if CSRIsForACertificateAuthority {
 		certTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 		certTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
@@ -271,12 +338,22 @@ func (db *DatabaseRepository) SignCertificateRequest(csrFilter CSRFilter, caFilt
 		if err != nil {
 			return err
 		}
+		err = db.SetCertificateRequestSigningMethod(csrFilter, SigningMethodCA)
+		if err != nil {
+			return err
+		}
 	}
 	return err
 }
 
 // RevokeCertificate revokes a certificate previously signed by a Notary CA by placing the serial number of the certificate in its issuer's CRL.
 func (db *DatabaseRepository) RevokeCertificate(filter CSRFilter) error {
+	return db.RevokeCertificateWithReason(filter, 0)
+}
+
+// RevokeCertificateWithReason revokes a certificate like RevokeCertificate, recording the RFC 5280 reason code
+// in the reasonCode extension of its CRL entry. The unspecified reason, 0, is left out of the entry.
+func (db *DatabaseRepository) RevokeCertificateWithReason(filter CSRFilter, reasonCode int) error {
 	oldRow, err := db.GetCertificateRequestAndChain(filter)
 	if err != nil {
 		return err
@@ -311,7 +388,7 @@ func (db *DatabaseRepository) RevokeCertificate(filter CSRFilter) error {
 	if err != nil {
 		return err
 	}
-	newCRL, err := AddCertificateToCRL(oldRow.CertificateChain, pk.PrivateKeyPEM, ca.CRL)
+	newCRL, err := AddCertificateToCRLWithReason(oldRow.CertificateChain, pk.PrivateKeyPEM, ca.CRL, reasonCode)
 	if err != nil {
 		return fmt.Errorf("%w: couldn't add certificate to certificate authority", ErrInternal)
 	}
@@ -346,6 +423,37 @@ func (db *DatabaseRepository) RevokeCertificate(filter CSRFilter) error {
 	return err
 }
 
+// MarkCertificateRevoked removes the certificate of a certificate request and sets the request status to revoked,
+// for certificates that were revoked by the ACME server that issued them rather than by a Notary CA.
+func (db *DatabaseRepository) MarkCertificateRevoked(filter CSRFilter) error {
+	oldRow, err := db.GetCertificateRequestAndChain(filter)
+	if err != nil {
+		return err
+	}
+	if oldRow.CertificateChain == "" {
+		return fmt.Errorf("%w: no certificate to revoke with associated CSR", ErrInvalidInput)
+	}
+	certChain, err := SplitCertificateBundle(oldRow.CertificateChain)
+	if err != nil {
+		return fmt.Errorf("%w: couldn't process certificate chain", ErrInternal)
+	}
+	certToRevoke, err := db.GetCertificate(ByCertificatePEM(certChain[0]))
+	if err != nil {
+		return err
+	}
+	err = db.DeleteCertificate(ByCertificateID(certToRevoke.CertificateID))
+	if err != nil {
+		return err
+	}
+	newRow := CertificateRequest{
+		CSR_ID:        oldRow.CSR_ID,
+		CSR:           oldRow.CSR,
+		CertificateID: 0,
+		Status:        "Revoked",
+	}
+	return UpdateEntity(db, db.stmts.UpdateCertificateRequest, newRow)
+}
+
 func certificateExpiryDate(certString string) time.Time {
 	certBlock, _ := pem.Decode([]byte(certString))
 	cert, _ := x509.ParseCertificate(certBlock.Bytes)
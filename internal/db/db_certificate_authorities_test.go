@@ -795,3 +795,86 @@ func encodePEM(blockType string, derBytes []byte) string {
 	_ = pem.Encode(&b, &pem.Block{Type: blockType, Bytes: derBytes})
 	return b.String()
 }
+
+func TestCertificateRenewalSettings(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userEmail := "testuser@example.com"
+	_, err := database.CreateUser(userEmail, "whateverpassword", 0)
+	if err != nil {
+		t.Fatalf("Couldn't create user: %s", err)
+	}
+	caID, err := database.CreateCertificateAuthority(tu.RootCACSR, tu.RootCAPrivateKey, tu.RootCACRL, tu.RootCACertificate+"\n"+tu.RootCACertificate, userEmail)
+	if err != nil {
+		t.Fatalf("Couldn't create certificate authority: %s", err)
+	}
+	signedID, err := database.CreateCertificateRequest(tu.AppleCSR, userEmail)
+	if err != nil {
+		t.Fatalf("Couldn't create CSR: %s", err)
+	}
+	pendingID, err := database.CreateCertificateRequest(tu.BananaCSR, userEmail)
+	if err != nil {
+		t.Fatalf("Couldn't create CSR: %s", err)
+	}
+	err = database.SignCertificateRequest(db.ByCSRID(signedID), db.ByCertificateAuthorityDenormalizedID(caID), "example.com")
+	if err != nil {
+		t.Fatalf("Couldn't sign CSR: %s", err)
+	}
+
+	csr, err := database.GetCertificateRequest(db.ByCSRID(signedID))
+	if err != nil {
+		t.Fatalf("Couldn't get CSR: %s", err)
+	}
+	if csr.SigningMethod != db.SigningMethodCA {
+		t.Fatalf("expected signing method %q, got %q", db.SigningMethodCA, csr.SigningMethod)
+	}
+	if csr.AutoRenew {
+		t.Fatalf("expected certificate requests not to be renewed automatically by default")
+	}
+
+	active, err := database.ListActiveCertificateRequests()
+	if err != nil {
+		t.Fatalf("Couldn't list active CSRs: %s", err)
+	}
+	if len(active) != 1 || active[0].CSR_ID != signedID {
+		t.Fatalf("expected only the signed CSR to be active, got %+v", active)
+	}
+
+	issuer, err := database.GetIssuingCertificateAuthority(db.ByCSRID(signedID))
+	if err != nil {
+		t.Fatalf("Couldn't get issuing certificate authority: %s", err)
+	}
+	if issuer.CertificateAuthorityID != caID {
+		t.Fatalf("expected issuing certificate authority %d, got %d", caID, issuer.CertificateAuthorityID)
+	}
+	_, err = database.GetIssuingCertificateAuthority(db.ByCSRID(pendingID))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a CSR without certificate, got %v", err)
+	}
+
+	if err := database.SetCertificateRequestAutoRenew(db.ByCSRID(signedID), true); err != nil {
+		t.Fatalf("Couldn't enable renewal of CSR: %s", err)
+	}
+	if err := database.UpdateCertificateAuthorityAutoRenew(db.ByCertificateAuthorityID(caID), true); err != nil {
+		t.Fatalf("Couldn't enable renewal of certificate authority: %s", err)
+	}
+	csrWithChain, err := database.GetCertificateRequestAndChain(db.ByCSRID(signedID))
+	if err != nil {
+		t.Fatalf("Couldn't get CSR: %s", err)
+	}
+	if !csrWithChain.AutoRenew {
+		t.Fatalf("expected CSR to be renewed automatically")
+	}
+	ca, err := database.GetDenormalizedCertificateAuthority(db.ByCertificateAuthorityDenormalizedID(caID))
+	if err != nil {
+		t.Fatalf("Couldn't get certificate authority: %s", err)
+	}
+	if !ca.AutoRenew {
+		t.Fatalf("expected certificate authority to renew its certificates automatically")
+	}
+
+	err = database.SetCertificateRequestAutoRenew(db.ByCSRID(100), true)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing CSR, got %v", err)
+	}
+}
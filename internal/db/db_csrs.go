@@ -24,12 +24,22 @@ func (db *DatabaseRepository) ListCertificateRequestWithCertificatesWithoutCAS(f
 	return ListEntities[CertificateRequestWithChain](db, db.stmts.ListCertificateRequestsWithoutChainByUserEmail, *csrRow)
 }
 
+// ListActiveCertificateRequests gets the certificate requests that have a certificate, except the ones of certificate authorities.
+func (db *DatabaseRepository) ListActiveCertificateRequests() ([]CertificateRequest, error) {
+	return ListEntities[CertificateRequest](db, db.stmts.ListActiveCertificateRequests)
+}
+
 // GetCertificateRequestByID gets a CSR row from the repository from a given ID.
 func (db *DatabaseRepository) GetCertificateRequest(filter CSRFilter) (*CertificateRequest, error) {
 	csrRow := filter.AsCertificateRequest()
 	return GetOneEntity[CertificateRequest](db, db.stmts.GetCertificateRequest, *csrRow)
 }
 
+// GetCertificateRequestByCertificateID gets the CSR row that the certificate was issued for.
+func (db *DatabaseRepository) GetCertificateRequestByCertificateID(certificateID int64) (*CertificateRequest, error) {
+	return GetOneEntity[CertificateRequest](db, db.stmts.GetCertificateRequestByCertificate, CertificateRequest{CertificateID: certificateID})
+}
+
 // GetCertificateRequestAndChain gets a CSR row from the repository from a given ID.
 func (db *DatabaseRepository) GetCertificateRequestAndChain(filter CSRFilter) (*CertificateRequestWithChain, error) {
 	csrRow := filter.AsCertificateRequestWithChain()
@@ -63,3 +73,32 @@ func (db *DatabaseRepository) DeleteCertificateRequest(filter CSRFilter) error {
 	csrRow := filter.AsCertificateRequest()
 	return DeleteEntity(db, db.stmts.DeleteCertificateRequest, csrRow)
 }
+
+// SetCertificateRequestAutoRenew opts a certificate request in or out of automatic renewal.
+func (db *DatabaseRepository) SetCertificateRequestAutoRenew(filter CSRFilter, autoRenew bool) error {
+	csrRow := filter.AsCertificateRequest()
+	csrRow.AutoRenew = autoRenew
+	return UpdateEntity(db, db.stmts.SetCertificateRequestAutoRenew, csrRow)
+}
+
+// SetCertificateRequestSigningMethod records how the certificate of a certificate request was obtained,
+// so that it can be renewed the same way. Adding a certificate chain to a request clears its signing method.
+func (db *DatabaseRepository) SetCertificateRequestSigningMethod(filter CSRFilter, signingMethod string) error {
+	csrRow := filter.AsCertificateRequest()
+	csrRow.SigningMethod = signingMethod
+	return UpdateEntity(db, db.stmts.SetCertificateRequestSigningMethod, csrRow)
+}
+
+// SetCertificateRequestACMEIssuer records that the certificate of a certificate request was obtained via ACME,
+// from the given ACME server and account, so that it can be renewed and revoked there. It also records the
+// options the certificate was ordered with, and chain, the issuer at the top of the chain that was selected.
+func (db *DatabaseRepository) SetCertificateRequestACMEIssuer(filter CSRFilter, serverID, accountID int64, order ACMEOrderOptions, chain string) error {
+	csrRow := filter.AsCertificateRequest()
+	csrRow.SigningMethod = SigningMethodACME
+	csrRow.ACMEServerID = &serverID
+	csrRow.ACMEAccountID = &accountID
+	csrRow.ACMEPreferredChain = order.PreferredChain
+	csrRow.ACMEProfile = order.Profile
+	csrRow.ACMEChain = chain
+	return UpdateEntity(db, db.stmts.SetCertificateRequestACMEIssuer, csrRow)
+}
@@ -181,6 +181,34 @@ func TestGetCertificateRequestFails(t *testing.T) {
 	}
 }
 
+func TestGetCertificateRequestByCertificateID(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userEmail := "testuser@example.com"
+	_, err := database.CreateUser(userEmail, "testpassword", 0)
+	if err != nil {
+		t.Fatalf("Couldn't create user: %s", err)
+	}
+	csrID, err := database.CreateCertificateRequest(tu.AppleCSR, userEmail)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %s", err)
+	}
+	if _, err := database.GetCertificateRequestByCertificateID(0); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected a not found error for the certificate of an unsigned CSR, got %v", err)
+	}
+	certificateID, err := database.AddCertificateChainToCertificateRequest(db.ByCSRID(csrID), tu.AppleCert+tu.IntermediateCert+tu.RootCert)
+	if err != nil {
+		t.Fatalf("Failed to add certificate chain to CSR: %s", err)
+	}
+	csr, err := database.GetCertificateRequestByCertificateID(certificateID)
+	if err != nil {
+		t.Fatalf("Failed to get CSR by certificate: %s", err)
+	}
+	if csr.CSR_ID != csrID || csr.UserEmail != userEmail {
+		t.Fatalf("Expected CSR %d of %s, got CSR %d of %s", csrID, userEmail, csr.CSR_ID, csr.UserEmail)
+	}
+}
+
 func TestDeleteCertificateRequest(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
@@ -247,6 +275,65 @@ func TestRevokeCertificateRequestFails(t *testing.T) {
 	}
 }
 
+func TestMarkACMECertificateRevoked(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userEmail := "testuser@example.com"
+	_, err := database.CreateUser(userEmail, "testpassword", 0)
+	if err != nil {
+		t.Fatalf("Couldn't create user: %s", err)
+	}
+	csrID, err := database.CreateCertificateRequest(tu.BananaCSR, userEmail)
+	if err != nil {
+		t.Fatalf("Couldn't create CSR: %s", err)
+	}
+	if err = database.MarkCertificateRevoked(db.ByCSRID(csrID)); !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for a CSR without certificate, got %v", err)
+	}
+	_, err = database.AddCertificateChainToCertificateRequest(db.ByCSRID(csrID), tu.BananaCert+tu.IntermediateCert+tu.RootCert)
+	if err != nil {
+		t.Fatalf("Couldn't add certificate chain to CSR: %s", err)
+	}
+	order := db.ACMEOrderOptions{PreferredChain: "ISRG Root X1", Profile: "shortlived"}
+	if err = database.SetCertificateRequestACMEIssuer(db.ByCSRID(csrID), 2, 3, order, "ISRG Root X2"); err != nil {
+		t.Fatalf("Couldn't record ACME issuer: %s", err)
+	}
+	csr, err := database.GetCertificateRequest(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't get CSR: %s", err)
+	}
+	if csr.SigningMethod != db.SigningMethodACME {
+		t.Fatalf("expected signing method %q, got %q", db.SigningMethodACME, csr.SigningMethod)
+	}
+	if csr.ACMEServerID == nil || *csr.ACMEServerID != 2 || csr.ACMEAccountID == nil || *csr.ACMEAccountID != 3 {
+		t.Fatalf("expected ACME server 2 and account 3 to be recorded, got %v and %v", csr.ACMEServerID, csr.ACMEAccountID)
+	}
+	if csr.ACMEPreferredChain != "ISRG Root X1" || csr.ACMEProfile != "shortlived" || csr.ACMEChain != "ISRG Root X2" {
+		t.Fatalf("expected the ACME order options and chain to be recorded, got %q, %q and %q", csr.ACMEPreferredChain, csr.ACMEProfile, csr.ACMEChain)
+	}
+
+	if err = database.MarkCertificateRevoked(db.ByCSRID(csrID)); err != nil {
+		t.Fatalf("Couldn't mark certificate revoked: %s", err)
+	}
+	csrWithChain, err := database.GetCertificateRequestAndChain(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't get CSR: %s", err)
+	}
+	if csrWithChain.Status != "Revoked" || csrWithChain.CertificateChain != "" {
+		t.Fatalf("expected a revoked CSR without certificate, got status %q", csrWithChain.Status)
+	}
+	csr, err = database.GetCertificateRequest(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't get CSR: %s", err)
+	}
+	if csr.ACMEServerID != nil || csr.ACMEAccountID != nil || csr.ACMEChain != "" || csr.ACMEProfile != "" {
+		t.Fatalf("expected the ACME issuer to be cleared with the certificate")
+	}
+	if err = database.SetCertificateRequestACMEIssuer(db.ByCSRID(100), 2, 3, db.ACMEOrderOptions{}, ""); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing CSR, got %v", err)
+	}
+}
+
 func TestRejectCertificateRequestFails(t *testing.T) {
 	database := tu.MustPrepareEmptyDB(t)
 
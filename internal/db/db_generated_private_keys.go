@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/canonical/notary/internal/utils"
+	"github.com/canonical/sqlair"
+)
+
+// CreateCertificateRequestWithGeneratedKey creates a new CSR entry in the repository together with the
+// private key that Notary generated for it. The private key is stored encrypted until it is delivered.
+func (db *DatabaseRepository) CreateCertificateRequestWithGeneratedKey(csr string, privPEM string, userEmail string) (int64, error) {
+	if err := ValidatePrivateKey(privPEM); err != nil {
+		return 0, err
+	}
+	encryptedPK, err := utils.Encrypt(privPEM, db.EncryptionKey)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to encrypt private key", ErrInternal)
+	}
+	if err := ValidateCertificateRequest(csr); err != nil {
+		return 0, err
+	}
+
+	// The certificate request and its key are written together, so that no key is left without its request.
+	tx, err := db.Conn.Begin(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create certificate request: %w", ErrInternal)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var outcome sqlair.Outcome
+	err = tx.Query(context.Background(), db.stmts.CreateCertificateRequest, CertificateRequest{CSR: csr, UserEmail: userEmail}).Get(&outcome)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("failed to create certificate request: %w", ErrAlreadyExists)
+		}
+		return 0, fmt.Errorf("failed to create certificate request: %w", ErrInternal)
+	}
+	csrID, err := outcome.Result().LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create certificate request: %w", ErrInternal)
+	}
+	row := GeneratedPrivateKey{
+		CSR_ID:        csrID,
+		PrivateKeyPEM: encryptedPK,
+	}
+	if err := tx.Query(context.Background(), db.stmts.CreateGeneratedPrivateKey, row).Run(); err != nil {
+		return 0, fmt.Errorf("failed to create generated private key: %w", ErrInternal)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to create certificate request: %w", ErrInternal)
+	}
+	return csrID, nil
+}
+
+// GetDecryptedGeneratedPrivateKey gets the generated private key of a certificate request.
+// If the key was already delivered, the returned row is marked as delivered and holds no key.
+func (db *DatabaseRepository) GetDecryptedGeneratedPrivateKey(filter CSRFilter) (*GeneratedPrivateKey, error) {
+	csr, err := db.GetCertificateRequest(filter)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := GetOneEntity[GeneratedPrivateKey](db, db.stmts.GetGeneratedPrivateKey, GeneratedPrivateKey{CSR_ID: csr.CSR_ID})
+	if err != nil {
+		return nil, err
+	}
+	if pk.Delivered {
+		return pk, nil
+	}
+	decryptedPK, err := utils.Decrypt(pk.PrivateKeyPEM, db.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt private key", ErrInternal)
+	}
+	pk.PrivateKeyPEM = decryptedPK
+	return pk, nil
+}
+
+// DeliverGeneratedPrivateKey wipes the generated private key of a certificate request and marks it as delivered.
+// It returns ErrNotFound if there is no key left to deliver, so that a key is only ever handed out once.
+func (db *DatabaseRepository) DeliverGeneratedPrivateKey(filter CSRFilter) error {
+	csr, err := db.GetCertificateRequest(filter)
+	if err != nil {
+		return err
+	}
+	return UpdateEntity(db, db.stmts.DeliverGeneratedPrivateKey, GeneratedPrivateKey{CSR_ID: csr.CSR_ID})
+}
@@ -0,0 +1,102 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestGeneratedPrivateKeysEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	csrID, err := database.CreateCertificateRequestWithGeneratedKey(tu.AppleCSR, tu.RootCAPrivateKey, "testuser@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create certificate request with generated key: %s", err)
+	}
+	if csrID != 1 {
+		t.Fatalf("Couldn't create certificate request: expected csr id 1, got %d", csrID)
+	}
+
+	pk, err := database.GetDecryptedGeneratedPrivateKey(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't get generated private key: %s", err)
+	}
+	if pk.Delivered {
+		t.Fatalf("Generated private key should not be delivered yet")
+	}
+	if pk.PrivateKeyPEM != tu.RootCAPrivateKey {
+		t.Fatalf("Generated private key is not correct")
+	}
+
+	err = database.DeliverGeneratedPrivateKey(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't deliver generated private key: %s", err)
+	}
+	pk, err = database.GetDecryptedGeneratedPrivateKey(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't get generated private key: %s", err)
+	}
+	if !pk.Delivered {
+		t.Fatalf("Generated private key should be delivered")
+	}
+	if pk.PrivateKeyPEM != "" {
+		t.Fatalf("Generated private key should have been wiped after delivery")
+	}
+
+	err = database.DeliverGeneratedPrivateKey(db.ByCSRID(csrID))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when delivering a key twice, got %s", err)
+	}
+
+	err = database.DeleteCertificateRequest(db.ByCSRID(csrID))
+	if err != nil {
+		t.Fatalf("Couldn't delete certificate request: %s", err)
+	}
+	_, err = database.GetDecryptedGeneratedPrivateKey(db.ByCSRID(csrID))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %s", err)
+	}
+}
+
+func TestGeneratedPrivateKeyFails(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	_, err := database.CreateCertificateRequestWithGeneratedKey(tu.AppleCSR, "nope", "testuser@example.com")
+	if !errors.Is(err, db.ErrInvalidPrivateKey) {
+		t.Fatalf("Expected ErrInvalidPrivateKey, got %s", err)
+	}
+	_, err = database.GetCertificateRequest(db.ByCSRID(1))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Certificate request should not have been created, got %s", err)
+	}
+
+	csrID, err := database.CreateCertificateRequest(tu.BananaCSR, "testuser@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create certificate request: %s", err)
+	}
+	_, err = database.GetDecryptedGeneratedPrivateKey(db.ByCSRID(csrID))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a request without a generated key, got %s", err)
+	}
+}
+
+func TestGeneratedPrivateKeyIsWrittenWithItsRequest(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	if _, err := database.Conn.PlainDB().Exec("DROP TABLE generated_private_keys"); err != nil {
+		t.Fatalf("Couldn't drop table: %s", err)
+	}
+
+	_, err := database.CreateCertificateRequestWithGeneratedKey(tu.AppleCSR, tu.RootCAPrivateKey, "testuser@example.com")
+	if !errors.Is(err, db.ErrInternal) {
+		t.Fatalf("Expected ErrInternal when the key can't be stored, got %v", err)
+	}
+	csrs, err := database.ListCertificateRequests()
+	if err != nil {
+		t.Fatalf("Couldn't list certificate requests: %s", err)
+	}
+	if len(csrs) != 0 {
+		t.Fatalf("Expected the certificate request to be rolled back with its key, got %d requests", len(csrs))
+	}
+}
@@ -33,6 +33,21 @@ func (db *DatabaseRepository) Close() error {
 	return nil
 }
 
+// MigrateUp applies Notary's migrations up to version, or all of them when version is 0.
+// OpenFGA's migrations share the goose version table and use versions 5 and 6, so the databases
+// created before a Notary migration below them was added already record a later version.
+// goose refuses to apply such missing migrations by default, which would block the upgrade.
+func MigrateUp(ctx context.Context, conn *sql.DB, version int64) error {
+	goose.SetBaseFS(migrations.EmbedMigrations)
+	if err := goose.SetDialect("sqlite"); err != nil {
+		return err
+	}
+	if version == 0 {
+		return goose.UpContext(ctx, conn, ".", goose.WithNoColor(true), goose.WithAllowMissing())
+	}
+	return goose.UpToContext(ctx, conn, ".", version, goose.WithNoColor(true), goose.WithAllowMissing())
+}
+
 // NewDatabase connects to a given table in a given database,
 // stores the connection information and returns an object containing the information.
 // The database path must be a valid file path or ":memory:".
@@ -57,8 +72,7 @@ func NewDatabase(dbOpts *DatabaseOpts) (*DatabaseRepository, error) {
 	}
 	if version < 1 {
 		if dbOpts.ApplyMigrations {
-			goose.SetBaseFS(migrations.EmbedMigrations)
-			if err := goose.Up(sqlConnection, ".", goose.WithNoColor(true)); err != nil {
+			if err := MigrateUp(context.Background(), sqlConnection, 0); err != nil {
 				return nil, fmt.Errorf("failed to apply migrations: %w", err)
 			}
 		} else {
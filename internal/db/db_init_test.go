@@ -1,6 +1,7 @@
 package db_test
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"path/filepath"
@@ -66,3 +67,32 @@ func Example() {
 		log.Fatalln(err)
 	}
 }
+
+// The databases of earlier versions of Notary record OpenFGA's versions 5 and 6 above
+// the Notary migrations that were added since, which must still be applied.
+func TestMigrateUpAppliesMigrationsBelowOpenFGAVersions(t *testing.T) {
+	sqlConnection, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatalf("Couldn't create temporary database: %s", err)
+	}
+	defer sqlConnection.Close() // nolint: errcheck
+	if err := db.MigrateUp(context.Background(), sqlConnection, 2); err != nil {
+		t.Fatalf("Couldn't apply the first migrations: %s", err)
+	}
+	for _, version := range []int{5, 6} {
+		if _, err := sqlConnection.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, 1)", version); err != nil {
+			t.Fatalf("Couldn't record OpenFGA migration: %s", err)
+		}
+	}
+
+	if err := db.MigrateUp(context.Background(), sqlConnection, 0); err != nil {
+		t.Fatalf("Couldn't apply the missing migrations: %s", err)
+	}
+	var count int
+	if err := sqlConnection.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name IN ('generated_private_keys', 'timestamp_tokens')").Scan(&count); err != nil {
+		t.Fatalf("Couldn't list tables: %s", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected migrations 3 and 4 to be applied, got %d of their tables", count)
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CreateJob queues a new job of the given type. The job becomes eligible to run at runAfter.
+func (db *DatabaseRepository) CreateJob(jobType string, payload string, maxAttempts int64, runAfter time.Time, createdBy string) (int64, error) {
+	if jobType == "" {
+		return 0, fmt.Errorf("%w: job type can't be empty", ErrInvalidInput)
+	}
+	if maxAttempts < 1 {
+		return 0, fmt.Errorf("%w: max attempts must be at least 1", ErrInvalidInput)
+	}
+	now := time.Now().Unix()
+	row := Job{
+		Type:        jobType,
+		Payload:     payload,
+		MaxAttempts: maxAttempts,
+		RunAfter:    runAfter.Unix(),
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return CreateEntity(db, db.stmts.CreateJob, row)
+}
+
+// GetJob gets a job by its ID.
+func (db *DatabaseRepository) GetJob(id int64) (*Job, error) {
+	return GetOneEntity[Job](db, db.stmts.GetJob, Job{ID: id})
+}
+
+// ListJobs gets every job in the table.
+func (db *DatabaseRepository) ListJobs() ([]Job, error) {
+	return ListEntities[Job](db, db.stmts.ListJobs)
+}
+
+// GetPendingJob gets a queued or running job with the given type and payload.
+// It is used to avoid queueing the same work twice.
+func (db *DatabaseRepository) GetPendingJob(jobType string, payload string) (*Job, error) {
+	return GetOneEntity[Job](db, db.stmts.GetPendingJob, Job{Type: jobType, Payload: payload})
+}
+
+// GetLatestJob gets the most recent job with the given type and payload, whatever its status.
+func (db *DatabaseRepository) GetLatestJob(jobType string, payload string) (*Job, error) {
+	return GetOneEntity[Job](db, db.stmts.GetLatestJob, Job{Type: jobType, Payload: payload})
+}
+
+// ClaimNextJob marks the next queued job that is due as running and returns it.
+// It returns ErrNotFound if there is no job to run.
+func (db *DatabaseRepository) ClaimNextJob() (*Job, error) {
+	for {
+		now := time.Now().Unix()
+		job, err := GetOneEntity[Job](db, db.stmts.GetNextQueuedJob, Job{RunAfter: now})
+		if err != nil {
+			return nil, err
+		}
+		err = UpdateEntity(db, db.stmts.ClaimJob, Job{ID: job.ID, UpdatedAt: now})
+		if errors.Is(err, ErrNotFound) {
+			// Another worker claimed or cancelled the job in the meantime.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return db.GetJob(job.ID)
+	}
+}
+
+// CompleteJob records the outcome of a running job. A nil jobErr marks the job as succeeded, otherwise as failed.
+// It returns ErrNotFound if the job is no longer running, for example because it was cancelled.
+func (db *DatabaseRepository) CompleteJob(id int64, jobErr error) error {
+	row := Job{ID: id, Status: JobStatusSucceeded, UpdatedAt: time.Now().Unix()}
+	if jobErr != nil {
+		row.Status = JobStatusFailed
+		row.LastError = jobErr.Error()
+	}
+	return UpdateEntity(db, db.stmts.FinishJob, row)
+}
+
+// RetryJob puts a running job back in the queue, to be run again at runAfter.
+// It returns ErrNotFound if the job is no longer running, for example because it was cancelled.
+func (db *DatabaseRepository) RetryJob(id int64, jobErr error, runAfter time.Time) error {
+	row := Job{
+		ID:        id,
+		LastError: jobErr.Error(),
+		RunAfter:  runAfter.Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}
+	return UpdateEntity(db, db.stmts.RetryJob, row)
+}
+
+// CancelJob cancels a queued or running job.
+// It returns ErrNotFound if there is no job with this ID that is still queued or running.
+func (db *DatabaseRepository) CancelJob(id int64) error {
+	return UpdateEntity(db, db.stmts.CancelJob, Job{ID: id, UpdatedAt: time.Now().Unix()})
+}
+
+// RequeueRunningJobs puts every running job back in the queue.
+// It is meant to be called on startup, to resume the jobs that were interrupted when the process stopped.
+func (db *DatabaseRepository) RequeueRunningJobs() error {
+	err := UpdateEntity(db, db.stmts.RequeueRunningJobs, Job{UpdatedAt: time.Now().Unix()})
+	if realError(err) {
+		return err
+	}
+	return nil
+}
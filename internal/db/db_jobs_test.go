@@ -0,0 +1,135 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestJobsEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	_, err := database.ClaimNextJob()
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound with an empty queue, got %s", err)
+	}
+
+	firstID, err := database.CreateJob("test", `{"n":1}`, 2, time.Now(), "testuser@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create job: %s", err)
+	}
+	laterID, err := database.CreateJob("test", `{"n":2}`, 1, time.Now().Add(time.Hour), "testuser@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create job: %s", err)
+	}
+
+	pending, err := database.GetPendingJob("test", `{"n":1}`)
+	if err != nil || pending.ID != firstID {
+		t.Fatalf("Expected job %d to be pending, got %v, %v", firstID, pending, err)
+	}
+
+	job, err := database.ClaimNextJob()
+	if err != nil {
+		t.Fatalf("Couldn't claim job: %s", err)
+	}
+	if job.ID != firstID || job.Status != db.JobStatusRunning || job.Attempts != 1 {
+		t.Fatalf("Unexpected claimed job %+v", job)
+	}
+	_, err = database.ClaimNextJob()
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected jobs scheduled in the future not to be claimed, got %s", err)
+	}
+
+	err = database.RetryJob(firstID, errors.New("transient"), time.Now())
+	if err != nil {
+		t.Fatalf("Couldn't retry job: %s", err)
+	}
+	job, err = database.ClaimNextJob()
+	if err != nil {
+		t.Fatalf("Couldn't claim job: %s", err)
+	}
+	if job.ID != firstID || job.Attempts != 2 || job.LastError != "transient" {
+		t.Fatalf("Unexpected claimed job %+v", job)
+	}
+
+	err = database.RequeueRunningJobs()
+	if err != nil {
+		t.Fatalf("Couldn't requeue running jobs: %s", err)
+	}
+	job, err = database.GetJob(firstID)
+	if err != nil {
+		t.Fatalf("Couldn't get job: %s", err)
+	}
+	if job.Status != db.JobStatusQueued {
+		t.Fatalf("Expected running job to be queued again, got %s", job.Status)
+	}
+	err = database.RequeueRunningJobs()
+	if err != nil {
+		t.Fatalf("Requeueing with no running jobs should not fail: %s", err)
+	}
+
+	_, err = database.ClaimNextJob()
+	if err != nil {
+		t.Fatalf("Couldn't claim job: %s", err)
+	}
+	err = database.CompleteJob(firstID, nil)
+	if err != nil {
+		t.Fatalf("Couldn't complete job: %s", err)
+	}
+	job, err = database.GetJob(firstID)
+	if err != nil {
+		t.Fatalf("Couldn't get job: %s", err)
+	}
+	if job.Status != db.JobStatusSucceeded {
+		t.Fatalf("Expected job to have succeeded, got %s", job.Status)
+	}
+	err = database.CompleteJob(firstID, errors.New("too late"))
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when completing a job that isn't running, got %s", err)
+	}
+	_, err = database.GetPendingJob("test", `{"n":1}`)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected finished jobs not to be pending, got %s", err)
+	}
+	latest, err := database.GetLatestJob("test", `{"n":1}`)
+	if err != nil || latest.ID != firstID || latest.Status != db.JobStatusSucceeded {
+		t.Fatalf("Expected job %d to be the latest, got %v, %v", firstID, latest, err)
+	}
+
+	err = database.CancelJob(laterID)
+	if err != nil {
+		t.Fatalf("Couldn't cancel job: %s", err)
+	}
+	err = database.CancelJob(laterID)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when cancelling a cancelled job, got %s", err)
+	}
+	err = database.CancelJob(firstID)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when cancelling a finished job, got %s", err)
+	}
+
+	jobs, err := database.ListJobs()
+	if err != nil {
+		t.Fatalf("Couldn't list jobs: %s", err)
+	}
+	if len(jobs) != 2 || jobs[1].Status != db.JobStatusCancelled {
+		t.Fatalf("Unexpected jobs %+v", jobs)
+	}
+}
+
+func TestCreateJobInvalidInput(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	_, err := database.CreateJob("", "", 1, time.Now(), "")
+	if !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for an empty job type, got %s", err)
+	}
+	_, err = database.CreateJob("test", "", 0, time.Now(), "")
+	if !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for zero attempts, got %s", err)
+	}
+}
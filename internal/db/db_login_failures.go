@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/sqlair"
+)
+
+// ReserveLoginAttempt counts a login attempt of identifier in scope at now as a failure before its credentials are
+// checked, and returns the updated count. The failures before since are forgotten, so the count starts over. The attempt
+// that reaches maxAttempts failures locks out identifier until leaseUntil, so that no other attempt runs along with it.
+// The increment and the check happen in one statement, so concurrent attempts can't all get past the lockout.
+// It returns ErrLoginLocked when identifier is locked out at now, without counting the attempt.
+func (db *DatabaseRepository) ReserveLoginAttempt(scope, identifier string, now, since time.Time, maxAttempts int, leaseUntil time.Time) (*LoginFailure, error) {
+	row := LoginFailure{Scope: scope, Identifier: identifier, LastFailureAt: now.Unix()}
+	limits := LoginAttemptLimits{Since: since.Unix(), MaxAttempts: int64(maxAttempts), LeaseUntil: leaseUntil.Unix()}
+	var failure LoginFailure
+	err := db.Conn.Query(context.Background(), db.stmts.ReserveLoginAttempt, row, limits).Get(&failure)
+	if err != nil {
+		if errors.Is(err, sqlair.ErrNoRows) {
+			return nil, fmt.Errorf("failed to reserve login attempt: %w", ErrLoginLocked)
+		}
+		return nil, fmt.Errorf("%w: failed to reserve login attempt", ErrInternal)
+	}
+	return &failure, nil
+}
+
+// ReleaseLoginAttempt uncounts a login attempt that ReserveLoginAttempt counted, once it didn't fail. It lifts the
+// lockout of identifier in scope when it is still the one that the reservation returned.
+func (db *DatabaseRepository) ReleaseLoginAttempt(reservation *LoginFailure) error {
+	return UpdateEntity(db, db.stmts.ReleaseLoginAttempt, LoginFailure{Scope: reservation.Scope, Identifier: reservation.Identifier, LockedUntil: reservation.LockedUntil})
+}
+
+// GetLoginFailure gets the failed login attempts of identifier in scope.
+func (db *DatabaseRepository) GetLoginFailure(scope, identifier string) (*LoginFailure, error) {
+	return GetOneEntity[LoginFailure](db, db.stmts.GetLoginFailure, LoginFailure{Scope: scope, Identifier: identifier})
+}
+
+// ListLockedLoginFailures returns the failed login attempts that cause a lockout that hasn't ended by now.
+func (db *DatabaseRepository) ListLockedLoginFailures(now time.Time) ([]LoginFailure, error) {
+	return ListEntities[LoginFailure](db, db.stmts.ListLockedLoginFailures, LoginFailure{LockedUntil: now.Unix()})
+}
+
+// LockLogin locks out identifier in scope until the given time, unless it is already locked out for longer.
+func (db *DatabaseRepository) LockLogin(scope, identifier string, until time.Time) error {
+	return UpdateEntity(db, db.stmts.LockLogin, LoginFailure{Scope: scope, Identifier: identifier, LockedUntil: until.Unix()})
+}
+
+// DeleteLoginFailure forgets the failed login attempts of identifier in scope, which lifts its lockout.
+func (db *DatabaseRepository) DeleteLoginFailure(scope, identifier string) error {
+	return DeleteEntity(db, db.stmts.DeleteLoginFailure, LoginFailure{Scope: scope, Identifier: identifier})
+}
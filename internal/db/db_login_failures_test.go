@@ -0,0 +1,137 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestLoginFailuresEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+
+	for i := range 3 {
+		failure, err := database.ReserveLoginAttempt(db.LoginFailureScopeAccount, "admin@example.com", now, since, 5, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+		}
+		if failure.Failures != int64(i+1) || failure.LastFailureAt != now.Unix() || failure.LockedUntil != 0 {
+			t.Fatalf("unexpected login failure %+v", failure)
+		}
+	}
+	if _, err := database.ReserveLoginAttempt(db.LoginFailureScopeIP, "192.0.2.1", now, since, 5, now.Add(time.Minute)); err != nil {
+		t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+	}
+
+	lockedUntil := now.Add(time.Minute)
+	if err := database.LockLogin(db.LoginFailureScopeAccount, "admin@example.com", lockedUntil); err != nil {
+		t.Fatalf("LockLogin() unexpected error: %s", err)
+	}
+	if err := database.LockLogin(db.LoginFailureScopeAccount, "admin@example.com", now); err != nil {
+		t.Fatalf("LockLogin() unexpected error: %s", err)
+	}
+	failure, err := database.GetLoginFailure(db.LoginFailureScopeAccount, "admin@example.com")
+	if err != nil {
+		t.Fatalf("GetLoginFailure() unexpected error: %s", err)
+	}
+	if failure.LockedUntil != lockedUntil.Unix() {
+		t.Fatalf("expected a shorter lockout not to replace a longer one, got %+v", failure)
+	}
+	if err := database.LockLogin(db.LoginFailureScopeAccount, "nobody@example.com", lockedUntil); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected locking out without failures to fail with ErrNotFound, got %v", err)
+	}
+
+	locked, err := database.ListLockedLoginFailures(now)
+	if err != nil {
+		t.Fatalf("ListLockedLoginFailures() unexpected error: %s", err)
+	}
+	if len(locked) != 1 || locked[0].Identifier != "admin@example.com" {
+		t.Fatalf("expected only the locked out account to be listed, got %+v", locked)
+	}
+	locked, err = database.ListLockedLoginFailures(lockedUntil)
+	if err != nil {
+		t.Fatalf("ListLockedLoginFailures() unexpected error: %s", err)
+	}
+	if len(locked) != 0 {
+		t.Fatalf("expected ended lockouts not to be listed, got %+v", locked)
+	}
+
+	// Failures are counted again from one once the previous ones are older than since.
+	later := now.Add(2 * time.Hour)
+	failure, err = database.ReserveLoginAttempt(db.LoginFailureScopeAccount, "admin@example.com", later, later.Add(-time.Hour), 5, later.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+	}
+	if failure.Failures != 1 {
+		t.Fatalf("expected the old failures to be forgotten, got %+v", failure)
+	}
+
+	if err := database.DeleteLoginFailure(db.LoginFailureScopeAccount, "admin@example.com"); err != nil {
+		t.Fatalf("DeleteLoginFailure() unexpected error: %s", err)
+	}
+	if _, err := database.GetLoginFailure(db.LoginFailureScopeAccount, "admin@example.com"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the failures to be deleted, got %v", err)
+	}
+	if _, err := database.GetLoginFailure(db.LoginFailureScopeIP, "192.0.2.1"); err != nil {
+		t.Fatalf("expected the failures of other identifiers to be kept, got %v", err)
+	}
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	leaseUntil := now.Add(time.Minute)
+
+	first, err := database.ReserveLoginAttempt(db.LoginFailureScopeIP, "192.0.2.1", now, since, 2, leaseUntil)
+	if err != nil {
+		t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+	}
+	if first.Failures != 1 || first.LockedUntil != 0 {
+		t.Fatalf("expected the first attempt not to lock out, got %+v", first)
+	}
+	last, err := database.ReserveLoginAttempt(db.LoginFailureScopeIP, "192.0.2.1", now, since, 2, leaseUntil)
+	if err != nil {
+		t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+	}
+	if last.Failures != 2 || last.LockedUntil != leaseUntil.Unix() {
+		t.Fatalf("expected the attempt that reaches the maximum to lock out the others, got %+v", last)
+	}
+	if _, err := database.ReserveLoginAttempt(db.LoginFailureScopeIP, "192.0.2.1", now, since, 2, leaseUntil); !errors.Is(err, db.ErrLoginLocked) {
+		t.Fatalf("expected an attempt during the lockout to fail with ErrLoginLocked, got %v", err)
+	}
+
+	if err := database.ReleaseLoginAttempt(last); err != nil {
+		t.Fatalf("ReleaseLoginAttempt() unexpected error: %s", err)
+	}
+	failure, err := database.GetLoginFailure(db.LoginFailureScopeIP, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("GetLoginFailure() unexpected error: %s", err)
+	}
+	if failure.Failures != 1 || failure.LockedUntil != 0 {
+		t.Fatalf("expected the released attempt to be uncounted and its lockout lifted, got %+v", failure)
+	}
+
+	// A release doesn't lift a lockout that another attempt placed since.
+	if _, err := database.ReserveLoginAttempt(db.LoginFailureScopeIP, "192.0.2.1", now, since, 2, leaseUntil); err != nil {
+		t.Fatalf("ReserveLoginAttempt() unexpected error: %s", err)
+	}
+	if err := database.ReleaseLoginAttempt(first); err != nil {
+		t.Fatalf("ReleaseLoginAttempt() unexpected error: %s", err)
+	}
+	failure, err = database.GetLoginFailure(db.LoginFailureScopeIP, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("GetLoginFailure() unexpected error: %s", err)
+	}
+	if failure.Failures != 1 || failure.LockedUntil != leaseUntil.Unix() {
+		t.Fatalf("expected the lockout of the other attempt to be kept, got %+v", failure)
+	}
+
+	if err := database.ReleaseLoginAttempt(&db.LoginFailure{Scope: db.LoginFailureScopeIP, Identifier: "192.0.2.2"}); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected releasing an attempt that wasn't reserved to fail with ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreateInvitedUser creates an account without a password, whose user sets it with an invitation token.
+func (db *DatabaseRepository) CreateInvitedUser(email string, roleID RoleID) (int64, error) {
+	if err := ValidateUser(email, roleID); err != nil {
+		return 0, err
+	}
+	return CreateEntity(db, db.stmts.CreateInvitedUser, User{Email: email, RoleID: roleID})
+}
+
+// CreatePasswordToken creates a password token of the user for purpose, which replaces the unused tokens of the user.
+// hashedToken is the hash of the token, which isn't stored.
+func (db *DatabaseRepository) CreatePasswordToken(userID int64, hashedToken, purpose string, expiresAt time.Time, createdBy string) (int64, error) {
+	if hashedToken == "" || (purpose != PasswordTokenPurposeInvitation && purpose != PasswordTokenPurposeReset) {
+		return 0, fmt.Errorf("%w: token and a valid purpose are required", ErrInvalidInput)
+	}
+	if err := db.DeleteUnusedPasswordTokens(userID); err != nil {
+		return 0, err
+	}
+	row := PasswordToken{
+		UserID:      userID,
+		HashedToken: hashedToken,
+		Purpose:     purpose,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().Unix(),
+		ExpiresAt:   expiresAt.Unix(),
+	}
+	return CreateEntity(db, db.stmts.CreatePasswordToken, row)
+}
+
+// GetPasswordToken gets a password token by its hash.
+func (db *DatabaseRepository) GetPasswordToken(hashedToken string) (*PasswordToken, error) {
+	return GetOneEntity[PasswordToken](db, db.stmts.GetPasswordToken, PasswordToken{HashedToken: hashedToken})
+}
+
+// UsePasswordToken marks a password token as used at now. It returns ErrNotFound if the token was already used,
+// or expired by now, so that a token can only be used once.
+func (db *DatabaseRepository) UsePasswordToken(id int64, now time.Time) error {
+	return UpdateEntity(db, db.stmts.UsePasswordToken, PasswordToken{ID: id, UsedAt: now.Unix()})
+}
+
+// DeleteUnusedPasswordTokens deletes the password tokens of the user that weren't used, which revokes them.
+// It doesn't fail when there is no token to delete.
+func (db *DatabaseRepository) DeleteUnusedPasswordTokens(userID int64) error {
+	err := db.Conn.Query(context.Background(), db.stmts.DeleteUnusedPasswordTokens, PasswordToken{UserID: userID}).Run()
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete password tokens", ErrInternal)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestPasswordTokensEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	now := time.Now()
+
+	userID, err := database.CreateInvitedUser("invited@example.com", db.RoleCertificateRequestor)
+	if err != nil {
+		t.Fatalf("CreateInvitedUser() unexpected error: %s", err)
+	}
+	user, err := database.GetUser(db.ByUserID(userID))
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %s", err)
+	}
+	if user.HasPassword() || user.RoleID != db.RoleCertificateRequestor {
+		t.Fatalf("expected a requestor without a password, got %+v", user)
+	}
+
+	if _, err := database.CreatePasswordToken(userID, "first-hash", db.PasswordTokenPurposeInvitation, now.Add(time.Hour), "admin@example.com"); err != nil {
+		t.Fatalf("CreatePasswordToken() unexpected error: %s", err)
+	}
+	id, err := database.CreatePasswordToken(userID, "second-hash", db.PasswordTokenPurposeInvitation, now.Add(time.Hour), "admin@example.com")
+	if err != nil {
+		t.Fatalf("CreatePasswordToken() unexpected error: %s", err)
+	}
+	if _, err := database.GetPasswordToken("first-hash"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected a new token to replace the unused one, got %v", err)
+	}
+	token, err := database.GetPasswordToken("second-hash")
+	if err != nil {
+		t.Fatalf("GetPasswordToken() unexpected error: %s", err)
+	}
+	if token.ID != id || token.UserID != userID || token.Purpose != db.PasswordTokenPurposeInvitation ||
+		token.CreatedBy != "admin@example.com" || token.UsedAt != 0 {
+		t.Fatalf("unexpected password token %+v", token)
+	}
+	if _, err := database.CreatePasswordToken(userID, "third-hash", "unknown", now.Add(time.Hour), ""); !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("expected an unknown purpose to fail with ErrInvalidInput, got %v", err)
+	}
+
+	if err := database.UsePasswordToken(id, now.Add(2*time.Hour)); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected an expired token not to be used, got %v", err)
+	}
+	if err := database.UsePasswordToken(id, now); err != nil {
+		t.Fatalf("UsePasswordToken() unexpected error: %s", err)
+	}
+	if err := database.UsePasswordToken(id, now); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected a token to be used only once, got %v", err)
+	}
+
+	// Used tokens are kept when a new token is issued, since they can't be used again.
+	if _, err := database.CreatePasswordToken(userID, "reset-hash", db.PasswordTokenPurposeReset, now.Add(time.Hour), ""); err != nil {
+		t.Fatalf("CreatePasswordToken() unexpected error: %s", err)
+	}
+	if _, err := database.GetPasswordToken("second-hash"); err != nil {
+		t.Fatalf("expected the used token to be kept, got %v", err)
+	}
+	if err := database.DeleteUnusedPasswordTokens(userID); err != nil {
+		t.Fatalf("DeleteUnusedPasswordTokens() unexpected error: %s", err)
+	}
+	if _, err := database.GetPasswordToken("reset-hash"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the unused token to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CreateSession creates a session of the user, which expires at expiresAt. authMethod is how the user authenticated.
+// The sessions that have already expired are deleted along the way.
+func (db *DatabaseRepository) CreateSession(userID int64, sessionID, authMethod, ipAddress, userAgent string, expiresAt time.Time) (int64, error) {
+	if sessionID == "" {
+		return 0, fmt.Errorf("%w: session ID can't be empty", ErrInvalidInput)
+	}
+	now := time.Now()
+	if err := db.DeleteExpiredSessions(now); err != nil {
+		return 0, err
+	}
+	row := Session{
+		UserID:     userID,
+		SessionID:  sessionID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		CreatedAt:  now.Unix(),
+		LastSeenAt: now.Unix(),
+		ExpiresAt:  expiresAt.Unix(),
+		AuthMethod: authMethod,
+	}
+	return CreateEntity(db, db.stmts.CreateSession, row)
+}
+
+// ListSessions returns the sessions of the user that haven't expired.
+func (db *DatabaseRepository) ListSessions(userID int64) ([]Session, error) {
+	return ListEntities[Session](db, db.stmts.ListSessions, Session{UserID: userID, ExpiresAt: time.Now().Unix()})
+}
+
+// GetSessionBySessionID gets a session by the ID that its token references.
+func (db *DatabaseRepository) GetSessionBySessionID(sessionID string) (*Session, error) {
+	return GetOneEntity[Session](db, db.stmts.GetSessionBySessionID, Session{SessionID: sessionID})
+}
+
+// UpdateSessionLastSeen records when a session was last used.
+func (db *DatabaseRepository) UpdateSessionLastSeen(id int64, seenAt time.Time) error {
+	return UpdateEntity(db, db.stmts.UpdateSessionLastSeen, Session{ID: id, LastSeenAt: seenAt.Unix()})
+}
+
+// UpdateSessionAuthMethod records that the user of a session authenticated with authMethod since it started.
+func (db *DatabaseRepository) UpdateSessionAuthMethod(id int64, authMethod string) error {
+	return UpdateEntity(db, db.stmts.UpdateSessionAuthMethod, Session{ID: id, AuthMethod: authMethod})
+}
+
+// DeleteSession deletes a session of the user, which revokes its token.
+func (db *DatabaseRepository) DeleteSession(userID, id int64) error {
+	return DeleteEntity(db, db.stmts.DeleteSession, Session{ID: id, UserID: userID})
+}
+
+// DeleteSessionBySessionID deletes the session that a token references, which revokes the token.
+func (db *DatabaseRepository) DeleteSessionBySessionID(sessionID string) error {
+	return DeleteEntity(db, db.stmts.DeleteSessionBySessionID, Session{SessionID: sessionID})
+}
+
+// DeleteUserSessions deletes all the sessions of the user but the one with keepSessionID, which can be empty.
+// It doesn't fail when there is no session to delete.
+func (db *DatabaseRepository) DeleteUserSessions(userID int64, keepSessionID string) error {
+	err := db.Conn.Query(context.Background(), db.stmts.DeleteUserSessions, Session{UserID: userID, SessionID: keepSessionID}).Run()
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete sessions", ErrInternal)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions deletes the sessions that expired by now.
+func (db *DatabaseRepository) DeleteExpiredSessions(now time.Time) error {
+	err := db.Conn.Query(context.Background(), db.stmts.DeleteExpiredSessions, Session{ExpiresAt: now.Unix()}).Run()
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete expired sessions", ErrInternal)
+	}
+	return nil
+}
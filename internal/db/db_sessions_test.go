@@ -0,0 +1,105 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestSessionsEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userID, err := database.CreateUser("admin@example.com", "Admin1234!", db.RoleAdmin)
+	if err != nil {
+		t.Fatalf("CreateUser() unexpected error: %s", err)
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	for _, sessionID := range []string{"first", "second", "third"} {
+		if _, err := database.CreateSession(userID, sessionID, db.SessionAuthMethodPassword, "127.0.0.1:1234", "curl", expiresAt); err != nil {
+			t.Fatalf("CreateSession() unexpected error: %s", err)
+		}
+	}
+	if _, err := database.CreateSession(userID, "first", db.SessionAuthMethodPassword, "", "", expiresAt); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("expected a duplicate session ID to fail with ErrAlreadyExists, got %v", err)
+	}
+	if _, err := database.CreateSession(userID, "", db.SessionAuthMethodPassword, "", "", expiresAt); !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("expected an empty session ID to fail with ErrInvalidInput, got %v", err)
+	}
+
+	session, err := database.GetSessionBySessionID("first")
+	if err != nil {
+		t.Fatalf("GetSessionBySessionID() unexpected error: %s", err)
+	}
+	if session.UserID != userID || session.IPAddress != "127.0.0.1:1234" || session.UserAgent != "curl" || session.ExpiresAt != expiresAt.Unix() || session.AuthMethod != db.SessionAuthMethodPassword {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	seenAt := time.Now().Add(time.Minute)
+	if err := database.UpdateSessionLastSeen(session.ID, seenAt); err != nil {
+		t.Fatalf("UpdateSessionLastSeen() unexpected error: %s", err)
+	}
+	session, err = database.GetSessionBySessionID("first")
+	if err != nil {
+		t.Fatalf("GetSessionBySessionID() unexpected error: %s", err)
+	}
+	if session.LastSeenAt != seenAt.Unix() {
+		t.Fatalf("expected last seen at %d, got %d", seenAt.Unix(), session.LastSeenAt)
+	}
+	if err := database.UpdateSessionAuthMethod(session.ID, db.SessionAuthMethodMFA); err != nil {
+		t.Fatalf("UpdateSessionAuthMethod() unexpected error: %s", err)
+	}
+	session, err = database.GetSessionBySessionID("first")
+	if err != nil {
+		t.Fatalf("GetSessionBySessionID() unexpected error: %s", err)
+	}
+	if session.AuthMethod != db.SessionAuthMethodMFA {
+		t.Fatalf("expected auth method %q, got %q", db.SessionAuthMethodMFA, session.AuthMethod)
+	}
+
+	if err := database.DeleteSession(userID+1, session.ID); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected deleting the session of another user to fail with ErrNotFound, got %v", err)
+	}
+	if err := database.DeleteSession(userID, session.ID); err != nil {
+		t.Fatalf("DeleteSession() unexpected error: %s", err)
+	}
+	if _, err := database.GetSessionBySessionID("first"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the deleted session to be gone, got %v", err)
+	}
+
+	if err := database.DeleteUserSessions(userID, "third"); err != nil {
+		t.Fatalf("DeleteUserSessions() unexpected error: %s", err)
+	}
+	sessions, err := database.ListSessions(userID)
+	if err != nil {
+		t.Fatalf("ListSessions() unexpected error: %s", err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != "third" {
+		t.Fatalf("expected only the kept session to remain, got %+v", sessions)
+	}
+
+	if _, err := database.CreateSession(userID, "expired", db.SessionAuthMethodPassword, "", "", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreateSession() unexpected error: %s", err)
+	}
+	sessions, err = database.ListSessions(userID)
+	if err != nil {
+		t.Fatalf("ListSessions() unexpected error: %s", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected expired sessions not to be listed, got %+v", sessions)
+	}
+	if err := database.DeleteExpiredSessions(time.Now()); err != nil {
+		t.Fatalf("DeleteExpiredSessions() unexpected error: %s", err)
+	}
+	if _, err := database.GetSessionBySessionID("expired"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the expired session to be deleted, got %v", err)
+	}
+
+	if err := database.DeleteUser(db.ByUserID(userID)); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %s", err)
+	}
+	if _, err := database.GetSessionBySessionID("third"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected the sessions of a deleted user to be deleted, got %v", err)
+	}
+}
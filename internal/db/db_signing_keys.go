@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/canonical/notary/internal/utils"
+)
+
+// CreateSigningKey encrypts and stores a new signing key, which signs the tokens from then on.
+// The other keys are retired at the creation time of the new key.
+func (db *DatabaseRepository) CreateSigningKey(kid, algorithm, privateKeyPEM string, createdAt time.Time) (int64, error) {
+	if kid == "" || algorithm == "" || privateKeyPEM == "" {
+		return 0, fmt.Errorf("%w: kid, algorithm and private key are required", ErrInvalidInput)
+	}
+	encryptedPK, err := utils.Encrypt(privateKeyPEM, db.EncryptionKey)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to encrypt signing key", ErrInternal)
+	}
+	row := SigningKey{
+		KID:           kid,
+		Algorithm:     algorithm,
+		PrivateKeyPEM: encryptedPK,
+		CreatedAt:     createdAt.Unix(),
+	}
+	id, err := CreateEntity(db, db.stmts.CreateSigningKey, row)
+	if err != nil {
+		return 0, err
+	}
+	err = db.Conn.Query(context.Background(), db.stmts.RetireSigningKeys, SigningKey{ID: id, RetiredAt: createdAt.Unix()}).Run()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to retire signing keys", ErrInternal)
+	}
+	return id, nil
+}
+
+// ListSigningKeys returns the signing keys with their decrypted private key, the newest first.
+func (db *DatabaseRepository) ListSigningKeys() ([]SigningKey, error) {
+	keys, err := ListEntities[SigningKey](db, db.stmts.ListSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		decryptedPK, err := utils.Decrypt(keys[i].PrivateKeyPEM, db.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decrypt signing key", ErrInternal)
+		}
+		keys[i].PrivateKeyPEM = decryptedPK
+	}
+	return keys, nil
+}
+
+// DeleteRetiredSigningKeys deletes the signing keys that were retired at or before retiredBefore.
+// It doesn't fail when there is no key to delete.
+func (db *DatabaseRepository) DeleteRetiredSigningKeys(retiredBefore time.Time) error {
+	err := db.Conn.Query(context.Background(), db.stmts.DeleteRetiredSigningKeys, SigningKey{RetiredAt: retiredBefore.Unix()}).Run()
+	if err != nil {
+		return fmt.Errorf("%w: failed to delete retired signing keys", ErrInternal)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestSigningKeysEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	now := time.Now()
+
+	if _, err := database.CreateSigningKey("first", "ES256", "first-private-key", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("CreateSigningKey() unexpected error: %s", err)
+	}
+	if _, err := database.CreateSigningKey("second", "EdDSA", "second-private-key", now); err != nil {
+		t.Fatalf("CreateSigningKey() unexpected error: %s", err)
+	}
+	keys, err := database.ListSigningKeys()
+	if err != nil {
+		t.Fatalf("ListSigningKeys() unexpected error: %s", err)
+	}
+	if len(keys) != 2 || keys[0].KID != "second" || keys[1].KID != "first" {
+		t.Fatalf("expected the keys to be listed newest first, got %+v", keys)
+	}
+	if keys[0].RetiredAt != 0 || keys[1].RetiredAt != now.Unix() {
+		t.Fatalf("expected the new key to retire the previous one, got %+v", keys)
+	}
+	if keys[0].PrivateKeyPEM != "second-private-key" || keys[0].Algorithm != "EdDSA" {
+		t.Fatalf("unexpected signing key %+v", keys[0])
+	}
+
+	var stored string
+	if err := database.Conn.PlainDB().QueryRow("SELECT private_key FROM signing_keys WHERE kid = ?", "second").Scan(&stored); err != nil {
+		t.Fatalf("Couldn't query raw signing key: %s", err)
+	}
+	if stored == "" || stored == "second-private-key" {
+		t.Fatalf("expected the private key to be encrypted, got %q", stored)
+	}
+
+	if err := database.DeleteRetiredSigningKeys(now.Add(-time.Second)); err != nil {
+		t.Fatalf("DeleteRetiredSigningKeys() unexpected error: %s", err)
+	}
+	if keys, _ := database.ListSigningKeys(); len(keys) != 2 {
+		t.Fatalf("expected a key retired after the cutoff to be kept, got %d keys", len(keys))
+	}
+	if err := database.DeleteRetiredSigningKeys(now); err != nil {
+		t.Fatalf("DeleteRetiredSigningKeys() unexpected error: %s", err)
+	}
+	keys, _ = database.ListSigningKeys()
+	if len(keys) != 1 || keys[0].KID != "second" {
+		t.Fatalf("expected only the active key to be kept, got %+v", keys)
+	}
+}
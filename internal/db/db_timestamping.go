@@ -0,0 +1,55 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/canonical/notary/internal/utils"
+)
+
+// CreateTSASigner stores a new signing key and certificate chain for the timestamping authority,
+// along with the certificate request that the certificate was issued for. The private key is stored encrypted.
+func (db *DatabaseRepository) CreateTSASigner(caID int64, csrID int64, privPEM string, certChainPEM string) (int64, error) {
+	if err := ValidatePrivateKey(privPEM); err != nil {
+		return 0, err
+	}
+	encryptedPK, err := utils.Encrypt(privPEM, db.EncryptionKey)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to encrypt TSA private key", ErrInternal)
+	}
+	row := TSASigner{
+		CertificateAuthorityID: caID,
+		CSRID:                  csrID,
+		PrivateKeyPEM:          encryptedPK,
+		CertificateChain:       certChainPEM,
+	}
+	return CreateEntity(db, db.stmts.CreateTSASigner, row)
+}
+
+// GetDecryptedTSASigner gets the most recent timestamping authority signer issued by the given certificate authority.
+func (db *DatabaseRepository) GetDecryptedTSASigner(caID int64) (*TSASigner, error) {
+	signer, err := GetOneEntity[TSASigner](db, db.stmts.GetLatestTSASigner, TSASigner{CertificateAuthorityID: caID})
+	if err != nil {
+		return nil, err
+	}
+	decryptedPK, err := utils.Decrypt(signer.PrivateKeyPEM, db.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt TSA private key", ErrInternal)
+	}
+	signer.PrivateKeyPEM = decryptedPK
+	return signer, nil
+}
+
+// CreateTimestampToken records a timestamp token issued by the timestamping authority.
+func (db *DatabaseRepository) CreateTimestampToken(token TimestampToken) (int64, error) {
+	return CreateEntity(db, db.stmts.CreateTimestampToken, token)
+}
+
+// GetTimestampToken gets a timestamp token record by its serial number.
+func (db *DatabaseRepository) GetTimestampToken(serialNumber string) (*TimestampToken, error) {
+	return GetOneEntity[TimestampToken](db, db.stmts.GetTimestampToken, TimestampToken{SerialNumber: serialNumber})
+}
+
+// ListTimestampTokens gets every timestamp token record in the table.
+func (db *DatabaseRepository) ListTimestampTokens() ([]TimestampToken, error) {
+	return ListEntities[TimestampToken](db, db.stmts.ListTimestampTokens)
+}
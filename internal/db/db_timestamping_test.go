@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestTimestampingEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	caID, err := database.CreateCertificateAuthority(tu.RootCACSR, tu.RootCAPrivateKey, tu.RootCACRL, tu.RootCACertificate+"\n"+tu.RootCACertificate, "testuser@example.com")
+	if err != nil {
+		t.Fatalf("Couldn't create certificate authority: %s", err)
+	}
+
+	_, err = database.GetDecryptedTSASigner(caID)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound before a signer is created, got %s", err)
+	}
+
+	firstID, err := database.CreateTSASigner(caID, 0, tu.RootCAPrivateKey, tu.RootCACertificate)
+	if err != nil {
+		t.Fatalf("Couldn't create TSA signer: %s", err)
+	}
+	secondID, err := database.CreateTSASigner(caID, 0, tu.RootCAPrivateKey, tu.RootCACertificate)
+	if err != nil {
+		t.Fatalf("Couldn't create TSA signer: %s", err)
+	}
+	signer, err := database.GetDecryptedTSASigner(caID)
+	if err != nil {
+		t.Fatalf("Couldn't get TSA signer: %s", err)
+	}
+	if signer.ID != secondID || signer.ID == firstID {
+		t.Fatalf("Expected the most recent signer %d, got %d", secondID, signer.ID)
+	}
+	if signer.PrivateKeyPEM != tu.RootCAPrivateKey {
+		t.Fatalf("TSA signer private key is not correct")
+	}
+
+	token := db.TimestampToken{
+		SerialNumber:  "1234",
+		GenTime:       "2025-01-01T00:00:00Z",
+		PolicyOID:     "1.2.3.4",
+		HashAlgorithm: "SHA-256",
+		HashedMessage: "abcd",
+		TSASignerID:   signer.ID,
+	}
+	_, err = database.CreateTimestampToken(token)
+	if err != nil {
+		t.Fatalf("Couldn't create timestamp token: %s", err)
+	}
+	_, err = database.CreateTimestampToken(token)
+	if !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for a duplicate serial number, got %s", err)
+	}
+	got, err := database.GetTimestampToken("1234")
+	if err != nil {
+		t.Fatalf("Couldn't get timestamp token: %s", err)
+	}
+	if got.PolicyOID != "1.2.3.4" || got.TSASignerID != signer.ID {
+		t.Fatalf("Timestamp token is not correct: %+v", got)
+	}
+	tokens, err := database.ListTimestampTokens()
+	if err != nil {
+		t.Fatalf("Couldn't list timestamp tokens: %s", err)
+	}
+	if len(tokens) != 1 {
+		t.Fatalf("Expected 1 timestamp token, got %d", len(tokens))
+	}
+
+	err = database.DeleteCertificateAuthority(db.ByCertificateAuthorityID(caID))
+	if err != nil {
+		t.Fatalf("Couldn't delete certificate authority: %s", err)
+	}
+	tokens, err = database.ListTimestampTokens()
+	if err != nil {
+		t.Fatalf("Couldn't list timestamp tokens: %s", err)
+	}
+	if len(tokens) != 0 {
+		t.Fatalf("Timestamp tokens should be removed with their certificate authority, got %d", len(tokens))
+	}
+}
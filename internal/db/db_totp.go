@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/canonical/notary/internal/utils"
+)
+
+// SetTOTPSecret encrypts and stores a new pending TOTP secret for the user, replacing the one it had.
+// It is only enabled once EnableTOTP is called.
+func (db *DatabaseRepository) SetTOTPSecret(userID int64, secret string) error {
+	if secret == "" {
+		return fmt.Errorf("%w: TOTP secret can't be empty", ErrInvalidInput)
+	}
+	encryptedSecret, err := utils.Encrypt(secret, db.EncryptionKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP secret: %w", ErrInternal)
+	}
+	row := TOTPCredential{
+		UserID:          userID,
+		EncryptedSecret: encryptedSecret,
+		CreatedAt:       time.Now().Unix(),
+	}
+	_, err = CreateEntity(db, db.stmts.UpsertTOTPCredential, row)
+	return err
+}
+
+// GetTOTPCredential gets the TOTP credential of the user along with its decrypted secret.
+func (db *DatabaseRepository) GetTOTPCredential(userID int64) (*TOTPCredential, string, error) {
+	credential, err := GetOneEntity[TOTPCredential](db, db.stmts.GetTOTPCredential, TOTPCredential{UserID: userID})
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.Decrypt(credential.EncryptedSecret, db.EncryptionKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return credential, secret, nil
+}
+
+// IsTOTPEnabled returns whether the user has an enabled TOTP credential.
+func (db *DatabaseRepository) IsTOTPEnabled(userID int64) (bool, error) {
+	credential, err := GetOneEntity[TOTPCredential](db, db.stmts.GetTOTPCredential, TOTPCredential{UserID: userID})
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return credential.Enabled, nil
+}
+
+// EnableTOTP enables the pending TOTP credential of the user, whose code of time step lastUsedStep was verified,
+// and replaces its recovery codes with hashedRecoveryCodes.
+func (db *DatabaseRepository) EnableTOTP(userID int64, lastUsedStep int64, hashedRecoveryCodes []string) error {
+	tx, err := db.Conn.PlainDB().BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", ErrInternal)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	res, err := tx.Exec("UPDATE totp_credentials SET enabled = 1, last_used_step = ? WHERE user_id = ?", lastUsedStep, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", ErrInternal)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("failed to enable TOTP: %w", ErrNotFound)
+	}
+	if err := replaceRecoveryCodes(tx, userID, hashedRecoveryCodes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", ErrInternal)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of the user, whose TOTP credential must exist.
+func (db *DatabaseRepository) ReplaceRecoveryCodes(userID int64, hashedRecoveryCodes []string) error {
+	tx, err := db.Conn.PlainDB().BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", ErrInternal)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := replaceRecoveryCodes(tx, userID, hashedRecoveryCodes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", ErrInternal)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes replaces the recovery codes of the user in the transaction.
+func replaceRecoveryCodes(tx *sql.Tx, userID int64, hashedRecoveryCodes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", ErrInternal)
+	}
+	for _, hashedCode := range hashedRecoveryCodes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, hashed_code) VALUES (?, ?)", userID, hashedCode); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", ErrInternal)
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep records that the code of time step was used by the user. It returns ErrNotFound if a code
+// of that time step or a later one was already used, so that a code can't be replayed.
+func (db *DatabaseRepository) UseTOTPStep(userID int64, step int64) error {
+	return UpdateEntity(db, db.stmts.UpdateTOTPLastUsedStep, TOTPCredential{UserID: userID, LastUsedStep: step})
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used. It returns ErrNotFound if there is no such code.
+func (db *DatabaseRepository) UseRecoveryCode(userID int64, hashedCode string) error {
+	return UpdateEntity(db, db.stmts.UseRecoveryCode, RecoveryCode{UserID: userID, HashedCode: hashedCode, UsedAt: time.Now().Unix()})
+}
+
+// CountUnusedRecoveryCodes returns the number of recovery codes of the user that are left.
+func (db *DatabaseRepository) CountUnusedRecoveryCodes(userID int64) (int, error) {
+	result := NumRecoveryCodes{}
+	err := db.Conn.Query(context.Background(), db.stmts.CountUnusedRecoveryCodes, RecoveryCode{UserID: userID}).Get(&result)
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to count recovery codes", ErrInternal)
+	}
+	return result.Count, nil
+}
+
+// DeleteTOTPCredential deletes the TOTP credential of the user along with its recovery codes.
+func (db *DatabaseRepository) DeleteTOTPCredential(userID int64) error {
+	return DeleteEntity(db, db.stmts.DeleteTOTPCredential, TOTPCredential{UserID: userID})
+}
@@ -0,0 +1,92 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestTOTPEndToEnd(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userID, err := database.CreateUser("admin@example.com", "Admin1234!", db.RoleAdmin)
+	if err != nil {
+		t.Fatalf("CreateUser() unexpected error: %s", err)
+	}
+	if enabled, err := database.IsTOTPEnabled(userID); err != nil || enabled {
+		t.Fatalf("expected TOTP not to be enabled, got %t, %v", enabled, err)
+	}
+	if err := database.EnableTOTP(userID, 1, nil); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected enabling a missing credential to fail with ErrNotFound, got %v", err)
+	}
+
+	if err := database.SetTOTPSecret(userID, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatalf("SetTOTPSecret() unexpected error: %s", err)
+	}
+	credential, secret, err := database.GetTOTPCredential(userID)
+	if err != nil {
+		t.Fatalf("GetTOTPCredential() unexpected error: %s", err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" || credential.EncryptedSecret == secret || credential.Enabled {
+		t.Fatalf("unexpected credential %+v with secret %q", credential, secret)
+	}
+
+	if err := database.EnableTOTP(userID, 100, []string{"first", "second"}); err != nil {
+		t.Fatalf("EnableTOTP() unexpected error: %s", err)
+	}
+	if enabled, err := database.IsTOTPEnabled(userID); err != nil || !enabled {
+		t.Fatalf("expected TOTP to be enabled, got %t, %v", enabled, err)
+	}
+	if err := database.UseTOTPStep(userID, 100); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected a used time step to fail with ErrNotFound, got %v", err)
+	}
+	if err := database.UseTOTPStep(userID, 101); err != nil {
+		t.Fatalf("UseTOTPStep() unexpected error: %s", err)
+	}
+	if err := database.UseTOTPStep(userID, 100); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected an earlier time step to fail with ErrNotFound, got %v", err)
+	}
+
+	if err := database.UseRecoveryCode(userID, "first"); err != nil {
+		t.Fatalf("UseRecoveryCode() unexpected error: %s", err)
+	}
+	if err := database.UseRecoveryCode(userID, "first"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected a used recovery code to fail with ErrNotFound, got %v", err)
+	}
+	if count, err := database.CountUnusedRecoveryCodes(userID); err != nil || count != 1 {
+		t.Fatalf("expected 1 unused recovery code, got %d, %v", count, err)
+	}
+	if err := database.ReplaceRecoveryCodes(userID, []string{"third", "fourth", "fifth"}); err != nil {
+		t.Fatalf("ReplaceRecoveryCodes() unexpected error: %s", err)
+	}
+	if err := database.UseRecoveryCode(userID, "second"); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected a replaced recovery code to fail with ErrNotFound, got %v", err)
+	}
+	if count, err := database.CountUnusedRecoveryCodes(userID); err != nil || count != 3 {
+		t.Fatalf("expected 3 unused recovery codes, got %d, %v", count, err)
+	}
+
+	// Starting a new enrollment resets the credential until it is enabled again.
+	if err := database.SetTOTPSecret(userID, "KRSXG5CTMVRXEZLU"); err != nil {
+		t.Fatalf("SetTOTPSecret() unexpected error: %s", err)
+	}
+	credential, _, err = database.GetTOTPCredential(userID)
+	if err != nil {
+		t.Fatalf("GetTOTPCredential() unexpected error: %s", err)
+	}
+	if credential.Enabled || credential.LastUsedStep != 0 {
+		t.Fatalf("expected a pending credential, got %+v", credential)
+	}
+
+	if err := database.DeleteTOTPCredential(userID); err != nil {
+		t.Fatalf("DeleteTOTPCredential() unexpected error: %s", err)
+	}
+	if count, err := database.CountUnusedRecoveryCodes(userID); err != nil || count != 0 {
+		t.Fatalf("expected the recovery codes to be deleted, got %d, %v", count, err)
+	}
+	if err := database.DeleteTOTPCredential(userID); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected deleting a missing credential to fail with ErrNotFound, got %v", err)
+	}
+}
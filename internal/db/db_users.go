@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/canonical/notary/internal/utils"
 )
@@ -69,6 +70,28 @@ func (db *DatabaseRepository) CreateOIDCUser(email, oidcSubject string, roleID R
 	return db.GetUser(ByUserID(insertedRowID))
 }
 
+// CreateLDAPUser creates a new user from LDAP login (no password required), identified by the DN of their entry.
+func (db *DatabaseRepository) CreateLDAPUser(email, dn string, roleID RoleID) (*User, error) {
+	err := ValidateUser(email, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(dn) == "" {
+		return nil, fmt.Errorf("%w: ldap_dn cannot be empty", ErrInvalidUser)
+	}
+
+	row := User{
+		Email:  email,
+		RoleID: roleID,
+		LDAPDN: &dn,
+	}
+	insertedRowID, err := CreateEntity(db, db.stmts.CreateLDAPUser, row)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetUser(ByUserID(insertedRowID))
+}
+
 // UpdateUser updates the password of the given user.
 // Just like with CreateUser, this function handles hashing and salting the password before storage.
 func (db *DatabaseRepository) UpdateUserPassword(filter UserFilter, password string) error {
@@ -91,6 +114,26 @@ func (db *DatabaseRepository) UpdateUserRole(filter UserFilter, roleID RoleID) e
 	return UpdateEntity(db, db.stmts.UpdateUserRole, userRow)
 }
 
+// LinkUserOIDCSubject links the OIDC identity with oidcSubject to the user, so that they can log in with it.
+// It fails with ErrAlreadyExists when the identity is linked to another user.
+func (db *DatabaseRepository) LinkUserOIDCSubject(userID int64, oidcSubject string) error {
+	if strings.TrimSpace(oidcSubject) == "" {
+		return fmt.Errorf("%w: OIDC subject can't be empty", ErrInvalidInput)
+	}
+	if _, err := db.GetUser(ByOIDCSubject(oidcSubject)); err == nil {
+		return fmt.Errorf("%w: OIDC identity is linked to another user", ErrAlreadyExists)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return UpdateEntity(db, db.stmts.UpdateUserOIDCSubject, User{ID: userID, OIDCSubject: &oidcSubject})
+}
+
+// UnlinkUserOIDCSubject removes the OIDC identity of the user. It fails with ErrNotFound when none is linked.
+// The callers keep the OIDC identity of the users without a password, which is the only way they can log in.
+func (db *DatabaseRepository) UnlinkUserOIDCSubject(userID int64) error {
+	return UpdateEntity(db, db.stmts.ClearUserOIDCSubject, User{ID: userID})
+}
+
 // DeleteUserByID removes a user from the table.
 func (db *DatabaseRepository) DeleteUser(filter UserFilter) error {
 	userRow := filter.AsUser()
@@ -449,3 +449,72 @@ func TestDeleteUserFails(t *testing.T) {
 		t.Fatalf("The number of users should be 2.")
 	}
 }
+
+// TestLinkUserOIDCSubject tests linking and unlinking the OIDC identity of a local user
+func TestLinkUserOIDCSubject(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+
+	userID, err := database.CreateUser("local@example.com", "Admin1234!", db.RoleReadOnly)
+	if err != nil {
+		t.Fatalf("Failed to create user: %s", err)
+	}
+	if _, err := database.CreateOIDCUser("oidc@example.com", "auth0|taken", db.RoleReadOnly); err != nil {
+		t.Fatalf("Failed to create OIDC user: %s", err)
+	}
+
+	if err := database.LinkUserOIDCSubject(userID, ""); !errors.Is(err, db.ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput for an empty subject, got %v", err)
+	}
+	if err := database.LinkUserOIDCSubject(userID, "auth0|taken"); !errors.Is(err, db.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for a subject linked to another user, got %v", err)
+	}
+	if err := database.LinkUserOIDCSubject(userID, "auth0|local"); err != nil {
+		t.Fatalf("Failed to link OIDC subject: %s", err)
+	}
+	user, err := database.GetUser(db.ByOIDCSubject("auth0|local"))
+	if err != nil {
+		t.Fatalf("Failed to get user by OIDC subject: %s", err)
+	}
+	if user.ID != userID || !user.HasPassword() {
+		t.Fatalf("Expected the local user with their password, got %+v", user)
+	}
+
+	if err := database.UnlinkUserOIDCSubject(userID); err != nil {
+		t.Fatalf("Failed to unlink OIDC subject: %s", err)
+	}
+	if _, err := database.GetUser(db.ByOIDCSubject("auth0|local")); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected the unlinked subject not to be found, got %v", err)
+	}
+	if err := database.UnlinkUserOIDCSubject(userID); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound when no OIDC identity is linked, got %v", err)
+	}
+}
+
+func TestCreateLDAPUser(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	const dn = "uid=alice,ou=people,dc=example,dc=com"
+
+	if _, err := database.CreateLDAPUser("alice@example.com", "", db.RoleReadOnly); !errors.Is(err, db.ErrInvalidUser) {
+		t.Fatalf("Expected ErrInvalidUser for an empty DN, got %v", err)
+	}
+	user, err := database.CreateLDAPUser("alice@example.com", dn, db.RoleCertificateManager)
+	if err != nil {
+		t.Fatalf("Failed to create LDAP user: %s", err)
+	}
+	if !user.HasLDAP() || user.HasPassword() || user.RoleID != db.RoleCertificateManager {
+		t.Fatalf("Expected an LDAP user without a password, got %+v", user)
+	}
+	got, err := database.GetUser(db.ByLDAPDN(dn))
+	if err != nil {
+		t.Fatalf("Failed to get user by LDAP DN: %s", err)
+	}
+	if got.ID != user.ID || got.Email != "alice@example.com" {
+		t.Fatalf("Expected the LDAP user, got %+v", got)
+	}
+	if _, err := database.GetUser(db.ByLDAPDN("uid=bob,ou=people,dc=example,dc=com")); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for an unknown DN, got %v", err)
+	}
+	if _, err := database.CreateLDAPUser("alice2@example.com", dn, db.RoleReadOnly); err == nil {
+		t.Fatalf("Expected an error for a DN of another user")
+	}
+}
@@ -14,6 +14,8 @@ var (
 	ErrInvalidCertificateRequest = errors.New("invalid certificate request")
 	ErrInvalidPrivateKey         = errors.New("invalid private key")
 	ErrInvalidUser               = errors.New("invalid user")
+	ErrLoginLocked               = errors.New("login locked out")
+	ErrInUse                     = errors.New("resource in use")
 )
 
 // When a row doesn't exist, an ErrNotFound error is returned.
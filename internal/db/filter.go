@@ -83,6 +83,7 @@ type UserFilter struct {
 	ID          *int64
 	Email       *string
 	OIDCSubject *string
+	LDAPDN      *string
 }
 
 func ByUserID(id int64) UserFilter {
@@ -97,6 +98,10 @@ func ByOIDCSubject(subject string) UserFilter {
 	return UserFilter{OIDCSubject: &subject}
 }
 
+func ByLDAPDN(dn string) UserFilter {
+	return UserFilter{LDAPDN: &dn}
+}
+
 func (filter *UserFilter) AsUser() *User {
 	var userRow User
 
@@ -107,8 +112,10 @@ func (filter *UserFilter) AsUser() *User {
 		userRow = User{Email: *filter.Email}
 	case filter.OIDCSubject != nil:
 		userRow = User{OIDCSubject: filter.OIDCSubject}
+	case filter.LDAPDN != nil:
+		userRow = User{LDAPDN: filter.LDAPDN}
 	default:
-		panic(fmt.Errorf("%w: only user ID, email, or OIDC subject is supported but none was provided", ErrInvalidFilter))
+		panic(fmt.Errorf("%w: only user ID, email, OIDC subject or LDAP DN is supported but none was provided", ErrInvalidFilter))
 	}
 	return &userRow
 }
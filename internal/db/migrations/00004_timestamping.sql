@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tsa_signers
+(
+    id                       INTEGER PRIMARY KEY AUTOINCREMENT,
+    certificate_authority_id INTEGER NOT NULL REFERENCES certificate_authorities(certificate_authority_id) ON DELETE CASCADE,
+    private_key              TEXT NOT NULL,
+    certificate_chain        TEXT NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS timestamp_tokens
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    serial_number  TEXT NOT NULL UNIQUE,
+    gen_time       TEXT NOT NULL,
+    policy_oid     TEXT NOT NULL,
+    hash_algorithm TEXT NOT NULL,
+    hashed_message TEXT NOT NULL,
+    nonce          TEXT NOT NULL DEFAULT '',
+    tsa_signer_id  INTEGER NOT NULL REFERENCES tsa_signers(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS timestamp_tokens;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tsa_signers;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN challenge_type TEXT NOT NULL DEFAULT 'dns-01';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN challenge_port INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN webroot TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN webroot;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN challenge_port;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN challenge_type;
+-- +goose StatementEnd
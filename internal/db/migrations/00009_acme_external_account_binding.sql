@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN eab_key_id TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN eab_hmac_key TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN eab_hmac_key;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN eab_key_id;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN auto_renew INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN signing_method TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_authorities ADD COLUMN auto_renew INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE certificate_authorities DROP COLUMN auto_renew;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN signing_method;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN auto_renew;
+-- +goose StatementEnd
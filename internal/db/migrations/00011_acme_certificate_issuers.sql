@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN acme_server_id INTEGER;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN acme_account_id INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN acme_account_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN acme_server_id;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS acme_routes
+(
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    position       INTEGER NOT NULL,
+    domain_suffix  TEXT NOT NULL,
+    acme_server_id INTEGER NOT NULL REFERENCES acme_servers(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO acme_routes (position, domain_suffix, acme_server_id) SELECT 0, '', id FROM acme_servers WHERE active = 1;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN active;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN active INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE acme_servers SET active = 1 WHERE id = (SELECT acme_server_id FROM acme_routes ORDER BY position LIMIT 1);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS acme_routes;
+-- +goose StatementEnd
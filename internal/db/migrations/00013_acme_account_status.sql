@@ -0,0 +1,89 @@
+-- +goose Up
+-- Deactivated and revoked accounts are kept so that their certificates stay attributed to them, but they must not
+-- prevent a new account from being registered with the same email and directory URL. The table is rebuilt
+-- to replace its UNIQUE constraint with a unique index on valid accounts only, and the
+-- ACME server links, which the rebuild would clear, are restored afterwards.
+-- +goose StatementBegin
+CREATE TABLE acme_accounts_new
+(
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    email             TEXT NOT NULL,
+    directory_url     TEXT NOT NULL,
+    private_key       TEXT NOT NULL,
+    registration_uri  TEXT NOT NULL,
+    registration_body TEXT NOT NULL,
+    status            TEXT NOT NULL DEFAULT 'valid' CHECK (status IN ('valid', 'deactivated', 'revoked'))
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO acme_accounts_new (id, email, directory_url, private_key, registration_uri, registration_body)
+SELECT id, email, directory_url, private_key, registration_uri, registration_body FROM acme_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TEMP TABLE acme_server_accounts AS SELECT id, acme_account_id FROM acme_servers;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE acme_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_accounts_new RENAME TO acme_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE acme_servers SET acme_account_id = (SELECT acme_account_id FROM acme_server_accounts WHERE acme_server_accounts.id = acme_servers.id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE acme_server_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX acme_accounts_email_directory_url ON acme_accounts (email, directory_url) WHERE status = 'valid';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE acme_servers SET acme_account_id = NULL WHERE acme_account_id IN (SELECT id FROM acme_accounts WHERE status != 'valid');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE acme_accounts_old
+(
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    email             TEXT NOT NULL,
+    directory_url     TEXT NOT NULL,
+    private_key       TEXT NOT NULL,
+    registration_uri  TEXT NOT NULL,
+    registration_body TEXT NOT NULL,
+    UNIQUE(email, directory_url)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+INSERT INTO acme_accounts_old (id, email, directory_url, private_key, registration_uri, registration_body)
+SELECT id, email, directory_url, private_key, registration_uri, registration_body FROM acme_accounts WHERE status = 'valid';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TEMP TABLE acme_server_accounts AS SELECT id, acme_account_id FROM acme_servers;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE acme_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_accounts_old RENAME TO acme_accounts;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE acme_servers SET acme_account_id = (SELECT acme_account_id FROM acme_server_accounts WHERE acme_server_accounts.id = acme_servers.id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE acme_server_accounts;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN dns_resolvers TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN dns_propagation_timeout INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN dns_polling_interval INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN dns_skip_authoritative_check INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN dns_challenge_aliases TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN dns_challenge_aliases;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN dns_skip_authoritative_check;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN dns_polling_interval;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN dns_propagation_timeout;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN dns_resolvers;
+-- +goose StatementEnd
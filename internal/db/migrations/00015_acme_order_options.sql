@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN preferred_chain TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers ADD COLUMN profile TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN acme_preferred_chain TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN acme_profile TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests ADD COLUMN acme_chain TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN acme_chain;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN acme_profile;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE certificate_requests DROP COLUMN acme_preferred_chain;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN profile;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE acme_servers DROP COLUMN preferred_chain;
+-- +goose StatementEnd
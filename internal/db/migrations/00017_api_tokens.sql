@@ -0,0 +1,36 @@
+-- +goose Up
+-- Service accounts are users without a password, which authenticate with API tokens only.
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN service_account INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS api_tokens
+(
+    id                        INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id                   INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name                      TEXT NOT NULL,
+    token_id                  TEXT NOT NULL UNIQUE,
+    hashed_secret             TEXT NOT NULL,
+    scopes                    TEXT NOT NULL,
+    certificate_authority_ids TEXT NOT NULL DEFAULT '',
+    expires_at                INTEGER NOT NULL,
+    last_used_at              INTEGER NOT NULL DEFAULT 0,
+    created_by                TEXT NOT NULL DEFAULT '',
+    created_at                INTEGER NOT NULL,
+    UNIQUE(user_id, name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_tokens;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM users WHERE service_account = 1;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN service_account;
+-- +goose StatementEnd
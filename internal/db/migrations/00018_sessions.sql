@@ -0,0 +1,20 @@
+-- +goose Up
+-- Sessions are the logins of users. The tokens of the session cookie reference them, so that deleting a session revokes its token.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id   TEXT NOT NULL UNIQUE,
+    ip_address   TEXT NOT NULL DEFAULT '',
+    user_agent   TEXT NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL,
+    last_seen_at INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS sessions;
+-- +goose StatementEnd
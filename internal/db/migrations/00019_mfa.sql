@@ -0,0 +1,41 @@
+-- +goose Up
+-- A TOTP credential is pending until its user verifies a first code, and enabled from then on.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS totp_credentials
+(
+    user_id          INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    encrypted_secret TEXT NOT NULL,
+    enabled          INTEGER NOT NULL DEFAULT 0,
+    last_used_step   INTEGER NOT NULL DEFAULT 0,
+    created_at       INTEGER NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id     INTEGER NOT NULL REFERENCES totp_credentials(user_id) ON DELETE CASCADE,
+    hashed_code TEXT NOT NULL,
+    used_at     INTEGER NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- The sessions record how they were authenticated, so that the roles that require multi-factor authentication
+-- can be limited to enrolling when they logged in with a password alone.
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN auth_method TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN auth_method;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS recovery_codes;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TABLE IF EXISTS totp_credentials;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- The failed login attempts are counted per account, by email, and per IP address.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_failures
+(
+    scope           TEXT NOT NULL,
+    identifier      TEXT NOT NULL,
+    failures        INTEGER NOT NULL DEFAULT 0,
+    last_failure_at INTEGER NOT NULL,
+    locked_until    INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (scope, identifier)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_failures;
+-- +goose StatementEnd
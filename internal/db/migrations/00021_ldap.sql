@@ -0,0 +1,20 @@
+-- +goose Up
+-- LDAP users are identified by the DN of their entry in the directory, and have no password.
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN ldap_dn TEXT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_ldap_dn
+ON users(ldap_dn)
+WHERE ldap_dn IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_ldap_dn;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN ldap_dn;
+-- +goose StatementEnd
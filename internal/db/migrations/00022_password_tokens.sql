@@ -0,0 +1,21 @@
+-- +goose Up
+-- Password tokens let users set their own password, from an invitation to a new account or a password reset.
+-- Only the hash of the tokens is stored, and each one can only be used once.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS password_tokens
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hashed_token TEXT NOT NULL UNIQUE,
+    purpose      TEXT NOT NULL,
+    created_by   TEXT NOT NULL DEFAULT '',
+    created_at   INTEGER NOT NULL,
+    expires_at   INTEGER NOT NULL,
+    used_at      INTEGER NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS password_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Signing keys sign the session tokens of Notary, in place of the single HMAC secret. They are rotated, and the keys
+-- that were rotated out keep verifying tokens for a grace period. The private keys are encrypted.
+-- The tokens signed with the HMAC secret are no longer accepted, so the users log in again once.
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS signing_keys
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    kid         TEXT NOT NULL UNIQUE,
+    algorithm   TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at  INTEGER NOT NULL,
+    retired_at  INTEGER NOT NULL DEFAULT 0
+);
+DROP TABLE IF EXISTS jwt_secret;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS signing_keys;
+CREATE TABLE IF NOT EXISTS jwt_secret
+(
+	id               INTEGER PRIMARY KEY CHECK (id = 1),
+	encrypted_secret TEXT NOT NULL
+);
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- The certificate of a TSA signer is issued for a certificate request, so that it can be listed and revoked
+-- like the other certificates of the certificate authority. The signers issued before have no certificate
+-- request, and are replaced on the next timestamp query.
+-- +goose StatementBegin
+ALTER TABLE tsa_signers ADD COLUMN csr_id INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tsa_signers DROP COLUMN csr_id;
+-- +goose StatementEnd
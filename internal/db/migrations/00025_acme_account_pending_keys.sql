@@ -0,0 +1,12 @@
+-- +goose Up
+-- The new key of an account key rollover is stored before the ACME server is asked to change the key,
+-- so that it isn't lost if Notary stops before storing the server's answer. It replaces the key once
+-- the server has accepted it.
+-- +goose StatementBegin
+ALTER TABLE acme_accounts ADD COLUMN pending_private_key TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE acme_accounts DROP COLUMN pending_private_key;
+-- +goose StatementEnd
@@ -2,5 +2,8 @@ package migrations
 
 import "embed"
 
+// EmbedMigrations holds Notary's database migrations. They share the goose version table
+// with OpenFGA's migrations, which use versions 5 and 6, so those versions must not be used here.
+//
 //go:embed *.sql
 var EmbedMigrations embed.FS
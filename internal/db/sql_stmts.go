@@ -8,12 +8,17 @@ const (
 	// // // // // // // // // // // // //
 	//  Certificate Request SQL Strings //
 	// // // // // // // // // // // // //
-	listCertificateRequestsStmt           = "SELECT &CertificateRequest.* FROM certificate_requests"
-	listCertificateRequestsWithoutCASStmt = "SELECT csrs.&CertificateRequest.csr_id, csrs.&CertificateRequest.csr, csrs.&CertificateRequest.status, csrs.&CertificateRequest.certificate_id FROM certificate_requests csrs LEFT JOIN certificate_authorities cas ON csrs.csr_id = cas.csr_id WHERE cas.certificate_authority_id IS NULL"
-	getCertificateRequestStmt             = "SELECT &CertificateRequest.* FROM certificate_requests WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
-	updateCertificateRequestStmt          = "UPDATE certificate_requests SET certificate_id=$CertificateRequest.certificate_id, status=$CertificateRequest.status WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
-	createCertificateRequestStmt          = "INSERT INTO certificate_requests (csr, user_email) VALUES ($CertificateRequest.csr, $CertificateRequest.user_email)"
-	deleteCertificateRequestStmt          = "DELETE FROM certificate_requests WHERE csr_id=$CertificateRequest.csr_id or csr=$CertificateRequest.csr"
+	listCertificateRequestsStmt            = "SELECT &CertificateRequest.* FROM certificate_requests"
+	listCertificateRequestsWithoutCASStmt  = "SELECT csrs.&CertificateRequest.csr_id, csrs.&CertificateRequest.csr, csrs.&CertificateRequest.status, csrs.&CertificateRequest.certificate_id FROM certificate_requests csrs LEFT JOIN certificate_authorities cas ON csrs.csr_id = cas.csr_id WHERE cas.certificate_authority_id IS NULL"
+	getCertificateRequestStmt              = "SELECT &CertificateRequest.* FROM certificate_requests WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
+	getCertificateRequestByCertificateStmt = "SELECT &CertificateRequest.* FROM certificate_requests WHERE certificate_id!=0 AND certificate_id==$CertificateRequest.certificate_id"
+	updateCertificateRequestStmt           = "UPDATE certificate_requests SET certificate_id=$CertificateRequest.certificate_id, status=$CertificateRequest.status, signing_method=$CertificateRequest.signing_method, acme_server_id=$CertificateRequest.acme_server_id, acme_account_id=$CertificateRequest.acme_account_id, acme_preferred_chain=$CertificateRequest.acme_preferred_chain, acme_profile=$CertificateRequest.acme_profile, acme_chain=$CertificateRequest.acme_chain WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
+	createCertificateRequestStmt           = "INSERT INTO certificate_requests (csr, user_email) VALUES ($CertificateRequest.csr, $CertificateRequest.user_email)"
+	deleteCertificateRequestStmt           = "DELETE FROM certificate_requests WHERE csr_id=$CertificateRequest.csr_id or csr=$CertificateRequest.csr"
+	setCertificateRequestAutoRenewStmt     = "UPDATE certificate_requests SET auto_renew=$CertificateRequest.auto_renew WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
+	setCertificateRequestSigningStmt       = "UPDATE certificate_requests SET signing_method=$CertificateRequest.signing_method WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
+	setCertificateRequestACMEIssuerStmt    = "UPDATE certificate_requests SET signing_method=$CertificateRequest.signing_method, acme_server_id=$CertificateRequest.acme_server_id, acme_account_id=$CertificateRequest.acme_account_id, acme_preferred_chain=$CertificateRequest.acme_preferred_chain, acme_profile=$CertificateRequest.acme_profile, acme_chain=$CertificateRequest.acme_chain WHERE csr_id==$CertificateRequest.csr_id or csr==$CertificateRequest.csr"
+	listActiveCertificateRequestsStmt      = "SELECT &CertificateRequest.* FROM certificate_requests WHERE status = 'Active' AND csr_id NOT IN (SELECT csr_id FROM certificate_authorities WHERE csr_id IS NOT NULL)"
 
 	listCertificateRequestsWithCertificatesStmt = `
 WITH RECURSIVE certificate_chain AS (
@@ -22,6 +27,9 @@ WITH RECURSIVE certificate_chain AS (
         csr.csr,
 		csr.status,
 		csr.user_email,
+		csr.auto_renew,
+		csr.acme_profile,
+		csr.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -38,6 +46,9 @@ WITH RECURSIVE certificate_chain AS (
         cc.csr,
 		cc.status,
 		cc.user_email,
+		cc.auto_renew,
+		cc.acme_profile,
+		cc.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -50,6 +61,9 @@ SELECT
 	&CertificateRequestWithChain.csr_id,
 	&CertificateRequestWithChain.csr,
 	&CertificateRequestWithChain.status,
+	&CertificateRequestWithChain.auto_renew,
+	&CertificateRequestWithChain.acme_profile,
+	&CertificateRequestWithChain.acme_chain,
 	chain AS &CertificateRequestWithChain.certificate_chain
 FROM certificate_chain
 WHERE chain = '' OR issuer_id = 0`
@@ -60,6 +74,9 @@ WITH RECURSIVE certificate_chain AS (
         csr.csr,
         csr.status,
         csr.user_email,
+        csr.auto_renew,
+        csr.acme_profile,
+        csr.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -76,6 +93,9 @@ WITH RECURSIVE certificate_chain AS (
         cc.csr,
         cc.status,
         cc.user_email,
+        cc.auto_renew,
+        cc.acme_profile,
+        cc.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -88,6 +108,9 @@ SELECT
 	cc.&CertificateRequestWithChain.csr_id,
 	cc.&CertificateRequestWithChain.csr,
 	cc.&CertificateRequestWithChain.status,
+	cc.&CertificateRequestWithChain.auto_renew,
+	cc.&CertificateRequestWithChain.acme_profile,
+	cc.&CertificateRequestWithChain.acme_chain,
 	cc.&CertificateRequestWithChain.user_email,
 	chain AS &CertificateRequestWithChain.certificate_chain
 FROM certificate_chain cc
@@ -100,6 +123,9 @@ WITH RECURSIVE certificate_chain AS (
         csr.csr,
         csr.status,
         csr.user_email,
+        csr.auto_renew,
+        csr.acme_profile,
+        csr.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -116,6 +142,9 @@ WITH RECURSIVE certificate_chain AS (
         cc.csr,
         cc.status,
         cc.user_email,
+        cc.auto_renew,
+        cc.acme_profile,
+        cc.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -128,6 +157,9 @@ SELECT
 	cc.&CertificateRequestWithChain.csr_id,
 	cc.&CertificateRequestWithChain.csr,
 	cc.&CertificateRequestWithChain.status,
+	cc.&CertificateRequestWithChain.auto_renew,
+	cc.&CertificateRequestWithChain.acme_profile,
+	cc.&CertificateRequestWithChain.acme_chain,
 	cc.&CertificateRequestWithChain.user_email,
 	chain AS &CertificateRequestWithChain.certificate_chain
 FROM certificate_chain cc
@@ -140,6 +172,9 @@ WITH RECURSIVE certificate_chain AS (
         csr.csr,
 		csr.status,
 		csr.user_email,
+		csr.auto_renew,
+		csr.acme_profile,
+		csr.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -156,6 +191,9 @@ WITH RECURSIVE certificate_chain AS (
         cc.csr,
 		cc.status,
 		cc.user_email,
+		cc.auto_renew,
+		cc.acme_profile,
+		cc.acme_chain,
         cert.certificate_id,
         cert.issuer_id,
         cert.certificate,
@@ -168,6 +206,9 @@ SELECT
 	&CertificateRequestWithChain.csr_id,
 	&CertificateRequestWithChain.csr,
 	&CertificateRequestWithChain.status,
+	&CertificateRequestWithChain.auto_renew,
+	&CertificateRequestWithChain.acme_profile,
+	&CertificateRequestWithChain.acme_chain,
 	&CertificateRequestWithChain.user_email,
 	chain AS &CertificateRequestWithChain.certificate_chain
 FROM certificate_chain
@@ -202,11 +243,12 @@ SELECT &Certificate.* FROM cert_chain;`
 	// // // // // // // // // // // // // //
 	//  Certificate Authority SQL Strings  //
 	// // // // // // // // // // // // // //
-	createCertificateAuthorityStmt = "INSERT INTO certificate_authorities (crl, enabled, private_key_id, csr_id, certificate_id) VALUES ($CertificateAuthority.crl, $CertificateAuthority.enabled, $CertificateAuthority.private_key_id, $CertificateAuthority.csr_id, $CertificateAuthority.certificate_id)"
-	getCertificateAuthorityStmt    = "SELECT &CertificateAuthority.* FROM certificate_authorities WHERE certificate_authority_id==$CertificateAuthority.certificate_authority_id or csr_id==$CertificateAuthority.csr_id or certificate_id==$CertificateAuthority.certificate_id"
-	listCertificateAuthoritiesStmt = "SELECT &CertificateAuthority.* FROM certificate_authorities"
-	updateCertificateAuthorityStmt = "UPDATE certificate_authorities SET crl=$CertificateAuthority.crl, enabled=$CertificateAuthority.enabled, certificate_id=$CertificateAuthority.certificate_id WHERE certificate_authority_id==$CertificateAuthority.certificate_authority_id or csr_id==$CertificateAuthority.csr_id"
-	deleteCertificateAuthorityStmt = "DELETE FROM certificate_authorities WHERE certificate_authority_id=$CertificateAuthority.certificate_authority_id or csr_id=$CertificateAuthority.csr_id"
+	createCertificateAuthorityStmt       = "INSERT INTO certificate_authorities (crl, enabled, private_key_id, csr_id, certificate_id) VALUES ($CertificateAuthority.crl, $CertificateAuthority.enabled, $CertificateAuthority.private_key_id, $CertificateAuthority.csr_id, $CertificateAuthority.certificate_id)"
+	getCertificateAuthorityStmt          = "SELECT &CertificateAuthority.* FROM certificate_authorities WHERE certificate_authority_id==$CertificateAuthority.certificate_authority_id or csr_id==$CertificateAuthority.csr_id or certificate_id==$CertificateAuthority.certificate_id"
+	listCertificateAuthoritiesStmt       = "SELECT &CertificateAuthority.* FROM certificate_authorities"
+	updateCertificateAuthorityStmt       = "UPDATE certificate_authorities SET crl=$CertificateAuthority.crl, enabled=$CertificateAuthority.enabled, certificate_id=$CertificateAuthority.certificate_id WHERE certificate_authority_id==$CertificateAuthority.certificate_authority_id or csr_id==$CertificateAuthority.csr_id"
+	setCertificateAuthorityAutoRenewStmt = "UPDATE certificate_authorities SET auto_renew=$CertificateAuthority.auto_renew WHERE certificate_authority_id==$CertificateAuthority.certificate_authority_id"
+	deleteCertificateAuthorityStmt       = "DELETE FROM certificate_authorities WHERE certificate_authority_id=$CertificateAuthority.certificate_authority_id or csr_id=$CertificateAuthority.csr_id"
 
 	listDenormalizedCertificateAuthoritiesStmt = `
 WITH RECURSIVE cas_with_chain AS (
@@ -215,6 +257,7 @@ WITH RECURSIVE cas_with_chain AS (
         cas.private_key_id,
 		cas.csr_id,
         cas.enabled,
+        cas.auto_renew,
         cas.crl,
         certs.certificate_id,
         certs.issuer_id,
@@ -230,6 +273,7 @@ WITH RECURSIVE cas_with_chain AS (
 		cc.private_key_id,
 		cc.csr_id,
         cc.enabled,
+        cc.auto_renew,
 		cc.crl,
         certs.certificate_id,
         certs.issuer_id,
@@ -242,6 +286,7 @@ WITH RECURSIVE cas_with_chain AS (
 		cc.certificate_authority_id as &CertificateAuthorityDenormalized.certificate_authority_id,
 		cc.crl as &CertificateAuthorityDenormalized.crl,
 		cc.enabled as &CertificateAuthorityDenormalized.enabled,
+		cc.auto_renew AS &CertificateAuthorityDenormalized.auto_renew,
 		cc.private_key_id AS &CertificateAuthorityDenormalized.private_key_id,
 		cc.chain AS &CertificateAuthorityDenormalized.certificate_chain,
 		csrs.csr AS &CertificateAuthorityDenormalized.csr
@@ -255,6 +300,7 @@ WITH RECURSIVE cas_with_chain AS (
         cas.private_key_id,
 		cas.csr_id,
         cas.enabled,
+        cas.auto_renew,
         cas.crl,
         certs.certificate_id,
         certs.issuer_id,
@@ -270,6 +316,7 @@ WITH RECURSIVE cas_with_chain AS (
 		cc.private_key_id,
 		cc.csr_id,
         cc.enabled,
+        cc.auto_renew,
 		cc.crl,
         certs.certificate_id,
         certs.issuer_id,
@@ -282,6 +329,7 @@ WITH RECURSIVE cas_with_chain AS (
 		cc.certificate_authority_id as &CertificateAuthorityDenormalized.certificate_authority_id,
 		cc.crl as &CertificateAuthorityDenormalized.crl,
 		cc.enabled as &CertificateAuthorityDenormalized.enabled,
+		cc.auto_renew AS &CertificateAuthorityDenormalized.auto_renew,
 		cc.private_key_id AS &CertificateAuthorityDenormalized.private_key_id,
 		cc.chain AS &CertificateAuthorityDenormalized.certificate_chain,
 		csrs.csr AS &CertificateAuthorityDenormalized.csr
@@ -298,17 +346,54 @@ WITH RECURSIVE cas_with_chain AS (
 	createPrivateKeyStmt = "INSERT INTO private_keys (private_key) VALUES ($PrivateKey.private_key)"
 	deletePrivateKeyStmt = "DELETE FROM private_keys WHERE private_key_id==$PrivateKey.private_key_id or private_key==$PrivateKey.private_key"
 
+	// // // // // // // // // // // // //
+	// Generated Private Key SQL Strings //
+	// // // // // // // // // // // // //
+	createGeneratedPrivateKeyStmt  = "INSERT INTO generated_private_keys (csr_id, private_key) VALUES ($GeneratedPrivateKey.csr_id, $GeneratedPrivateKey.private_key)"
+	getGeneratedPrivateKeyStmt     = "SELECT &GeneratedPrivateKey.* FROM generated_private_keys WHERE csr_id==$GeneratedPrivateKey.csr_id"
+	deliverGeneratedPrivateKeyStmt = "UPDATE generated_private_keys SET private_key='', delivered=1 WHERE csr_id==$GeneratedPrivateKey.csr_id AND delivered==0"
+
+	// // // // // // // // // //
+	// Timestamping SQL Strings //
+	// // // // // // // // // //
+	createTSASignerStmt      = "INSERT INTO tsa_signers (certificate_authority_id, csr_id, private_key, certificate_chain) VALUES ($TSASigner.certificate_authority_id, $TSASigner.csr_id, $TSASigner.private_key, $TSASigner.certificate_chain)"
+	getLatestTSASignerStmt   = "SELECT &TSASigner.* FROM tsa_signers WHERE certificate_authority_id==$TSASigner.certificate_authority_id ORDER BY id DESC LIMIT 1"
+	createTimestampTokenStmt = "INSERT INTO timestamp_tokens (serial_number, gen_time, policy_oid, hash_algorithm, hashed_message, nonce, tsa_signer_id) VALUES ($TimestampToken.serial_number, $TimestampToken.gen_time, $TimestampToken.policy_oid, $TimestampToken.hash_algorithm, $TimestampToken.hashed_message, $TimestampToken.nonce, $TimestampToken.tsa_signer_id)"
+	getTimestampTokenStmt    = "SELECT &TimestampToken.* FROM timestamp_tokens WHERE id==$TimestampToken.id or serial_number==$TimestampToken.serial_number"
+	listTimestampTokensStmt  = "SELECT &TimestampToken.* FROM timestamp_tokens ORDER BY id"
+
+	// // // // // // // //
+	// Jobs SQL Strings //
+	// // // // // // // //
+	createJobStmt          = "INSERT INTO jobs (type, payload, status, max_attempts, run_after, created_by, created_at, updated_at) VALUES ($Job.type, $Job.payload, 'queued', $Job.max_attempts, $Job.run_after, $Job.created_by, $Job.created_at, $Job.updated_at)"
+	getJobStmt             = "SELECT &Job.* FROM jobs WHERE id==$Job.id"
+	listJobsStmt           = "SELECT &Job.* FROM jobs ORDER BY id"
+	getPendingJobStmt      = "SELECT &Job.* FROM jobs WHERE type==$Job.type AND payload==$Job.payload AND status IN ('queued', 'running') LIMIT 1"
+	getLatestJobStmt       = "SELECT &Job.* FROM jobs WHERE type==$Job.type AND payload==$Job.payload ORDER BY id DESC LIMIT 1"
+	getNextQueuedJobStmt   = "SELECT &Job.* FROM jobs WHERE status=='queued' AND run_after<=$Job.run_after ORDER BY run_after, id LIMIT 1"
+	claimJobStmt           = "UPDATE jobs SET status='running', attempts=attempts+1, updated_at=$Job.updated_at WHERE id==$Job.id AND status=='queued'"
+	finishJobStmt          = "UPDATE jobs SET status=$Job.status, last_error=$Job.last_error, updated_at=$Job.updated_at WHERE id==$Job.id AND status=='running'"
+	retryJobStmt           = "UPDATE jobs SET status='queued', last_error=$Job.last_error, run_after=$Job.run_after, updated_at=$Job.updated_at WHERE id==$Job.id AND status=='running'"
+	cancelJobStmt          = "UPDATE jobs SET status='cancelled', updated_at=$Job.updated_at WHERE id==$Job.id AND status IN ('queued', 'running')"
+	requeueRunningJobsStmt = "UPDATE jobs SET status='queued', updated_at=$Job.updated_at WHERE status=='running'"
+
 	// // // // // // // // // //
 	// Users Table SQL Strings //
 	// // // // // // // // // //
-	listUsersStmt      = "SELECT &User.* from users"
-	getUserStmt        = "SELECT &User.* from users WHERE id==$User.id or email==$User.email or oidc_subject==$User.oidc_subject"
-	createUserStmt     = "INSERT INTO users (email, hashed_password, role_id) VALUES ($User.email, $User.hashed_password, $User.role_id)"
-	createOIDCUserStmt = "INSERT INTO users (email, hashed_password, role_id, oidc_subject) VALUES ($User.email, NULL, $User.role_id, $User.oidc_subject)"
-	updateUserStmt     = "UPDATE users SET hashed_password=$User.hashed_password WHERE id==$User.id or email==$User.email"
-	updateUserRoleStmt = "UPDATE users SET role_id=$User.role_id WHERE id==$User.id"
-	deleteUserStmt     = "DELETE FROM users WHERE id==$User.id"
-	getNumUsersStmt    = "SELECT COUNT(*) AS &NumUsers.count FROM users"
+	listUsersStmt             = "SELECT &User.* from users"
+	getUserStmt               = "SELECT &User.* from users WHERE id==$User.id or email==$User.email or oidc_subject==$User.oidc_subject or ldap_dn==$User.ldap_dn"
+	createUserStmt            = "INSERT INTO users (email, hashed_password, role_id) VALUES ($User.email, $User.hashed_password, $User.role_id)"
+	createOIDCUserStmt        = "INSERT INTO users (email, hashed_password, role_id, oidc_subject) VALUES ($User.email, NULL, $User.role_id, $User.oidc_subject)"
+	createLDAPUserStmt        = "INSERT INTO users (email, hashed_password, role_id, ldap_dn) VALUES ($User.email, NULL, $User.role_id, $User.ldap_dn)"
+	createInvitedUserStmt     = "INSERT INTO users (email, hashed_password, role_id) VALUES ($User.email, NULL, $User.role_id)"
+	updateUserStmt            = "UPDATE users SET hashed_password=$User.hashed_password WHERE id==$User.id or email==$User.email"
+	updateUserRoleStmt        = "UPDATE users SET role_id=$User.role_id WHERE id==$User.id"
+	updateUserOIDCSubjectStmt = "UPDATE users SET oidc_subject=$User.oidc_subject WHERE id==$User.id"
+	clearUserOIDCSubjectStmt  = "UPDATE users SET oidc_subject=NULL WHERE id==$User.id AND oidc_subject IS NOT NULL"
+	deleteUserStmt            = "DELETE FROM users WHERE id==$User.id"
+	getNumUsersStmt           = "SELECT COUNT(*) AS &NumUsers.count FROM users WHERE service_account == 0"
+
+	createServiceAccountStmt = "INSERT INTO users (email, hashed_password, role_id, service_account) VALUES ($User.email, NULL, $User.role_id, 1)"
 
 	// // // // // // // // // //
 	// Encryption Key SQL Strings //
@@ -318,27 +403,82 @@ WITH RECURSIVE cas_with_chain AS (
 	deleteEncryptionKeyStmt = "DELETE FROM encryption_keys WHERE encryption_key_id=$AES256GCMEncryptionKey.encryption_key_id"
 
 	// // // // // // // // // //
-	// JWT Secret SQL Strings //
+	// Signing Key SQL Strings //
 	// // // // // // // // // //
-	createJWTSecretStmt = "INSERT INTO jwt_secret (id, encrypted_secret) VALUES ($JWTSecret.id, $JWTSecret.encrypted_secret)"
-	getJWTSecretStmt    = "SELECT &JWTSecret.* FROM jwt_secret WHERE id=$JWTSecret.id"
-	deleteJWTSecretStmt = "DELETE FROM jwt_secret WHERE id=$JWTSecret.id"
+	createSigningKeyStmt         = "INSERT INTO signing_keys (kid, algorithm, private_key, created_at) VALUES ($SigningKey.kid, $SigningKey.algorithm, $SigningKey.private_key, $SigningKey.created_at)"
+	listSigningKeysStmt          = "SELECT &SigningKey.* FROM signing_keys ORDER BY id DESC"
+	retireSigningKeysStmt        = "UPDATE signing_keys SET retired_at=$SigningKey.retired_at WHERE retired_at==0 AND id!=$SigningKey.id"
+	deleteRetiredSigningKeysStmt = "DELETE FROM signing_keys WHERE retired_at!=0 AND retired_at<=$SigningKey.retired_at"
 
 	// ACME Account statements
-	insertACMEAccountStmt           = "INSERT INTO acme_accounts (email, directory_url, private_key, registration_uri, registration_body) VALUES ($ACMEAccount.email, $ACMEAccount.directory_url, $ACMEAccount.private_key, $ACMEAccount.registration_uri, $ACMEAccount.registration_body)"
-	getACMEAccountStmt              = "SELECT &ACMEAccount.* FROM acme_accounts WHERE id==$ACMEAccount.id"
-	getACMEAccountByEmailAndURLStmt = "SELECT &ACMEAccount.* FROM acme_accounts WHERE email==$ACMEAccount.email AND directory_url==$ACMEAccount.directory_url"
-	updateACMEAccountStmt           = "UPDATE acme_accounts SET private_key=$ACMEAccount.private_key, registration_uri=$ACMEAccount.registration_uri, registration_body=$ACMEAccount.registration_body WHERE id==$ACMEAccount.id"
-	deleteACMEAccountStmt           = "DELETE FROM acme_accounts WHERE id==$ACMEAccount.id"
+	insertACMEAccountStmt            = "INSERT INTO acme_accounts (email, directory_url, private_key, registration_uri, registration_body) VALUES ($ACMEAccount.email, $ACMEAccount.directory_url, $ACMEAccount.private_key, $ACMEAccount.registration_uri, $ACMEAccount.registration_body)"
+	listACMEAccountsStmt             = "SELECT &ACMEAccount.* FROM acme_accounts ORDER BY id"
+	getACMEAccountStmt               = "SELECT &ACMEAccount.* FROM acme_accounts WHERE id==$ACMEAccount.id"
+	getACMEAccountByEmailAndURLStmt  = "SELECT &ACMEAccount.* FROM acme_accounts WHERE email==$ACMEAccount.email AND directory_url==$ACMEAccount.directory_url AND status=='valid'"
+	updateACMEAccountStmt            = "UPDATE acme_accounts SET private_key=$ACMEAccount.private_key, registration_uri=$ACMEAccount.registration_uri, registration_body=$ACMEAccount.registration_body WHERE id==$ACMEAccount.id"
+	deleteACMEAccountStmt            = "DELETE FROM acme_accounts WHERE id==$ACMEAccount.id"
+	setACMEAccountPendingKeyStmt     = "UPDATE acme_accounts SET pending_private_key=$ACMEAccount.pending_private_key WHERE id==$ACMEAccount.id"
+	promoteACMEAccountPendingKeyStmt = "UPDATE acme_accounts SET private_key=pending_private_key, pending_private_key='' WHERE id==$ACMEAccount.id AND pending_private_key!=''"
 
 	// ACME Server statements
-	createACMEServerStmt        = "INSERT INTO acme_servers (name, directory_url, email, dns_provider, env_vars) VALUES ($ACMEServer.name, $ACMEServer.directory_url, $ACMEServer.email, $ACMEServer.dns_provider, $ACMEServer.env_vars)"
-	listACMEServersStmt         = "SELECT &ACMEServer.* FROM acme_servers"
-	getACMEServerStmt           = "SELECT &ACMEServer.* FROM acme_servers WHERE id==$ACMEServer.id"
-	getActiveACMEServerStmt     = "SELECT &ACMEServer.* FROM acme_servers WHERE active = 1"
-	updateACMEServerStmt        = "UPDATE acme_servers SET name=$ACMEServer.name, directory_url=$ACMEServer.directory_url, email=$ACMEServer.email, dns_provider=$ACMEServer.dns_provider, env_vars=$ACMEServer.env_vars WHERE id==$ACMEServer.id"
-	deleteACMEServerStmt        = "DELETE FROM acme_servers WHERE id==$ACMEServer.id"
+	createACMEServerStmt        = "INSERT INTO acme_servers (name, directory_url, email, dns_provider, env_vars, challenge_type, challenge_port, webroot, eab_key_id, eab_hmac_key, dns_resolvers, dns_propagation_timeout, dns_polling_interval, dns_skip_authoritative_check, dns_challenge_aliases, preferred_chain, profile) VALUES ($ACMEServer.name, $ACMEServer.directory_url, $ACMEServer.email, $ACMEServer.dns_provider, $ACMEServer.env_vars, $ACMEServer.challenge_type, $ACMEServer.challenge_port, $ACMEServer.webroot, $ACMEServer.eab_key_id, $ACMEServer.eab_hmac_key, $ACMEServer.dns_resolvers, $ACMEServer.dns_propagation_timeout, $ACMEServer.dns_polling_interval, $ACMEServer.dns_skip_authoritative_check, $ACMEServer.dns_challenge_aliases, $ACMEServer.preferred_chain, $ACMEServer.profile)"
+	listACMEServersStmt         = "SELECT &ACMEServer.* FROM (SELECT *, EXISTS (SELECT 1 FROM acme_routes WHERE acme_server_id = acme_servers.id) AS active FROM acme_servers)"
+	getACMEServerStmt           = "SELECT &ACMEServer.* FROM (SELECT *, EXISTS (SELECT 1 FROM acme_routes WHERE acme_server_id = acme_servers.id) AS active FROM acme_servers) WHERE id==$ACMEServer.id"
+	updateACMEServerStmt        = "UPDATE acme_servers SET acme_account_id=CASE WHEN directory_url==$ACMEServer.directory_url AND email==$ACMEServer.email THEN acme_account_id END, name=$ACMEServer.name, directory_url=$ACMEServer.directory_url, email=$ACMEServer.email, dns_provider=$ACMEServer.dns_provider, env_vars=$ACMEServer.env_vars, challenge_type=$ACMEServer.challenge_type, challenge_port=$ACMEServer.challenge_port, webroot=$ACMEServer.webroot, eab_key_id=$ACMEServer.eab_key_id, eab_hmac_key=$ACMEServer.eab_hmac_key, dns_resolvers=$ACMEServer.dns_resolvers, dns_propagation_timeout=$ACMEServer.dns_propagation_timeout, dns_polling_interval=$ACMEServer.dns_polling_interval, dns_skip_authoritative_check=$ACMEServer.dns_skip_authoritative_check, dns_challenge_aliases=$ACMEServer.dns_challenge_aliases, preferred_chain=$ACMEServer.preferred_chain, profile=$ACMEServer.profile WHERE id==$ACMEServer.id"
 	linkACMEAccountToServerStmt = "UPDATE acme_servers SET acme_account_id=$ACMEServer.acme_account_id WHERE id==$ACMEServer.id"
+
+	// ACME Route statements
+	listACMERoutesStmt = "SELECT &ACMERoute.* FROM acme_routes ORDER BY position"
+
+	// ACME DNS Registration statements
+	createACMEDNSRegistrationStmt      = "INSERT INTO acme_dns_registrations (username, hashed_password, subdomain, allow_from, domain, created_by, created_at) VALUES ($ACMEDNSRegistration.username, $ACMEDNSRegistration.hashed_password, $ACMEDNSRegistration.subdomain, $ACMEDNSRegistration.allow_from, $ACMEDNSRegistration.domain, $ACMEDNSRegistration.created_by, $ACMEDNSRegistration.created_at)"
+	listACMEDNSRegistrationsStmt       = "SELECT &ACMEDNSRegistration.* FROM acme_dns_registrations ORDER BY id"
+	getACMEDNSRegistrationStmt         = "SELECT &ACMEDNSRegistration.* FROM acme_dns_registrations WHERE id==$ACMEDNSRegistration.id or username==$ACMEDNSRegistration.username or subdomain==$ACMEDNSRegistration.subdomain"
+	getACMEDNSRegistrationByDomainStmt = "SELECT &ACMEDNSRegistration.* FROM acme_dns_registrations WHERE domain==$ACMEDNSRegistration.domain AND domain!=''"
+	setACMEDNSRecordStmt               = "UPDATE acme_dns_registrations SET previous_txt=txt, txt=$ACMEDNSRegistration.txt, updated_at=$ACMEDNSRegistration.updated_at WHERE id==$ACMEDNSRegistration.id"
+	removeACMEDNSRecordStmt            = "UPDATE acme_dns_registrations SET txt=CASE WHEN txt==$ACMEDNSRegistration.txt THEN '' ELSE txt END, previous_txt=CASE WHEN previous_txt==$ACMEDNSRegistration.txt THEN '' ELSE previous_txt END, updated_at=$ACMEDNSRegistration.updated_at WHERE id==$ACMEDNSRegistration.id"
+	deleteACMEDNSRegistrationStmt      = "DELETE FROM acme_dns_registrations WHERE id==$ACMEDNSRegistration.id"
+
+	// API Token statements
+	createAPITokenStmt        = "INSERT INTO api_tokens (user_id, name, token_id, hashed_secret, scopes, certificate_authority_ids, expires_at, created_by, created_at) VALUES ($APIToken.user_id, $APIToken.name, $APIToken.token_id, $APIToken.hashed_secret, $APIToken.scopes, $APIToken.certificate_authority_ids, $APIToken.expires_at, $APIToken.created_by, $APIToken.created_at)"
+	listAPITokensStmt         = "SELECT &APIToken.* FROM api_tokens WHERE user_id==$APIToken.user_id ORDER BY id"
+	getAPITokenStmt           = "SELECT &APIToken.* FROM api_tokens WHERE id==$APIToken.id AND user_id==$APIToken.user_id"
+	getAPITokenByTokenIDStmt  = "SELECT &APIToken.* FROM api_tokens WHERE token_id==$APIToken.token_id"
+	updateAPITokenLastUseStmt = "UPDATE api_tokens SET last_used_at=$APIToken.last_used_at WHERE id==$APIToken.id"
+	deleteAPITokenStmt        = "DELETE FROM api_tokens WHERE id==$APIToken.id AND user_id==$APIToken.user_id"
+
+	// Session statements
+	createSessionStmt            = "INSERT INTO sessions (user_id, session_id, ip_address, user_agent, created_at, last_seen_at, expires_at, auth_method) VALUES ($Session.user_id, $Session.session_id, $Session.ip_address, $Session.user_agent, $Session.created_at, $Session.last_seen_at, $Session.expires_at, $Session.auth_method)"
+	listSessionsStmt             = "SELECT &Session.* FROM sessions WHERE user_id==$Session.user_id AND expires_at>$Session.expires_at ORDER BY id"
+	getSessionBySessionIDStmt    = "SELECT &Session.* FROM sessions WHERE session_id==$Session.session_id"
+	updateSessionLastSeenStmt    = "UPDATE sessions SET last_seen_at=$Session.last_seen_at WHERE id==$Session.id"
+	deleteSessionStmt            = "DELETE FROM sessions WHERE id==$Session.id AND user_id==$Session.user_id"
+	deleteSessionBySessionIDStmt = "DELETE FROM sessions WHERE session_id==$Session.session_id"
+	deleteUserSessionsStmt       = "DELETE FROM sessions WHERE user_id==$Session.user_id AND session_id!=$Session.session_id"
+	deleteExpiredSessionsStmt    = "DELETE FROM sessions WHERE expires_at<=$Session.expires_at"
+	updateSessionAuthMethodStmt  = "UPDATE sessions SET auth_method=$Session.auth_method WHERE id==$Session.id"
+
+	// TOTP statements
+	upsertTOTPCredentialStmt     = "INSERT INTO totp_credentials (user_id, encrypted_secret, enabled, last_used_step, created_at) VALUES ($TOTPCredential.user_id, $TOTPCredential.encrypted_secret, 0, 0, $TOTPCredential.created_at) ON CONFLICT(user_id) DO UPDATE SET encrypted_secret=excluded.encrypted_secret, enabled=0, last_used_step=0, created_at=excluded.created_at"
+	getTOTPCredentialStmt        = "SELECT &TOTPCredential.* FROM totp_credentials WHERE user_id==$TOTPCredential.user_id"
+	updateTOTPLastUsedStepStmt   = "UPDATE totp_credentials SET last_used_step=$TOTPCredential.last_used_step WHERE user_id==$TOTPCredential.user_id AND last_used_step<$TOTPCredential.last_used_step"
+	deleteTOTPCredentialStmt     = "DELETE FROM totp_credentials WHERE user_id==$TOTPCredential.user_id"
+	useRecoveryCodeStmt          = "UPDATE recovery_codes SET used_at=$RecoveryCode.used_at WHERE user_id==$RecoveryCode.user_id AND hashed_code==$RecoveryCode.hashed_code AND used_at==0"
+	countUnusedRecoveryCodesStmt = "SELECT COUNT(*) AS &NumRecoveryCodes.count FROM recovery_codes WHERE user_id==$RecoveryCode.user_id AND used_at==0"
+
+	// Login failure statements
+	reserveLoginAttemptStmt     = "INSERT INTO login_failures (scope, identifier, failures, last_failure_at, locked_until) SELECT $LoginFailure.scope, $LoginFailure.identifier, 1, $LoginFailure.last_failure_at, CASE WHEN $LoginAttemptLimits.max_attempts<=1 THEN $LoginAttemptLimits.lease_until ELSE 0 END WHERE true ON CONFLICT(scope, identifier) DO UPDATE SET failures=CASE WHEN login_failures.last_failure_at<$LoginAttemptLimits.since THEN 1 ELSE login_failures.failures+1 END, last_failure_at=excluded.last_failure_at, locked_until=CASE WHEN CASE WHEN login_failures.last_failure_at<$LoginAttemptLimits.since THEN 1 ELSE login_failures.failures+1 END>=$LoginAttemptLimits.max_attempts THEN $LoginAttemptLimits.lease_until ELSE login_failures.locked_until END WHERE login_failures.locked_until<=excluded.last_failure_at RETURNING &LoginFailure.*"
+	releaseLoginAttemptStmt     = "UPDATE login_failures SET failures=MAX(failures-1, 0), locked_until=CASE WHEN locked_until==$LoginFailure.locked_until THEN 0 ELSE locked_until END WHERE scope==$LoginFailure.scope AND identifier==$LoginFailure.identifier"
+	getLoginFailureStmt         = "SELECT &LoginFailure.* FROM login_failures WHERE scope==$LoginFailure.scope AND identifier==$LoginFailure.identifier"
+	listLockedLoginFailuresStmt = "SELECT &LoginFailure.* FROM login_failures WHERE locked_until>$LoginFailure.locked_until ORDER BY scope, identifier"
+	lockLoginStmt               = "UPDATE login_failures SET locked_until=MAX(locked_until, $LoginFailure.locked_until) WHERE scope==$LoginFailure.scope AND identifier==$LoginFailure.identifier"
+	deleteLoginFailureStmt      = "DELETE FROM login_failures WHERE scope==$LoginFailure.scope AND identifier==$LoginFailure.identifier"
+
+	// Password token statements
+	createPasswordTokenStmt        = "INSERT INTO password_tokens (user_id, hashed_token, purpose, created_by, created_at, expires_at) VALUES ($PasswordToken.user_id, $PasswordToken.hashed_token, $PasswordToken.purpose, $PasswordToken.created_by, $PasswordToken.created_at, $PasswordToken.expires_at)"
+	getPasswordTokenStmt           = "SELECT &PasswordToken.* FROM password_tokens WHERE hashed_token==$PasswordToken.hashed_token"
+	usePasswordTokenStmt           = "UPDATE password_tokens SET used_at=$PasswordToken.used_at WHERE id==$PasswordToken.id AND used_at==0 AND expires_at>$PasswordToken.used_at"
+	deleteUnusedPasswordTokensStmt = "DELETE FROM password_tokens WHERE user_id==$PasswordToken.user_id AND used_at==0"
 )
 
 // Statements contains all prepared SQL statements used by the database
@@ -346,6 +486,7 @@ type Statements struct {
 	// Certificate Request statements
 	CreateCertificateRequest                       *sqlair.Statement
 	GetCertificateRequest                          *sqlair.Statement
+	GetCertificateRequestByCertificate             *sqlair.Statement
 	GetCertificateRequestWithChain                 *sqlair.Statement
 	UpdateCertificateRequest                       *sqlair.Statement
 	ListCertificateRequests                        *sqlair.Statement
@@ -354,6 +495,10 @@ type Statements struct {
 	ListCertificateRequestsWithoutChain            *sqlair.Statement
 	ListCertificateRequestsWithoutChainByUserEmail *sqlair.Statement
 	DeleteCertificateRequest                       *sqlair.Statement
+	SetCertificateRequestAutoRenew                 *sqlair.Statement
+	SetCertificateRequestSigningMethod             *sqlair.Statement
+	SetCertificateRequestACMEIssuer                *sqlair.Statement
+	ListActiveCertificateRequests                  *sqlair.Statement
 
 	// Certificate statements
 	CreateCertificate   *sqlair.Statement
@@ -370,21 +515,53 @@ type Statements struct {
 	ListCertificateAuthorities             *sqlair.Statement
 	ListDenormalizedCertificateAuthorities *sqlair.Statement
 	DeleteCertificateAuthority             *sqlair.Statement
+	SetCertificateAuthorityAutoRenew       *sqlair.Statement
 
 	// Private Key statements
 	CreatePrivateKey *sqlair.Statement
 	GetPrivateKey    *sqlair.Statement
 	DeletePrivateKey *sqlair.Statement
 
+	// Generated Private Key statements
+	CreateGeneratedPrivateKey  *sqlair.Statement
+	GetGeneratedPrivateKey     *sqlair.Statement
+	DeliverGeneratedPrivateKey *sqlair.Statement
+
+	// Timestamping statements
+	CreateTSASigner      *sqlair.Statement
+	GetLatestTSASigner   *sqlair.Statement
+	CreateTimestampToken *sqlair.Statement
+	GetTimestampToken    *sqlair.Statement
+	ListTimestampTokens  *sqlair.Statement
+
+	// Job statements
+	CreateJob          *sqlair.Statement
+	GetJob             *sqlair.Statement
+	ListJobs           *sqlair.Statement
+	GetPendingJob      *sqlair.Statement
+	GetLatestJob       *sqlair.Statement
+	GetNextQueuedJob   *sqlair.Statement
+	ClaimJob           *sqlair.Statement
+	FinishJob          *sqlair.Statement
+	RetryJob           *sqlair.Statement
+	CancelJob          *sqlair.Statement
+	RequeueRunningJobs *sqlair.Statement
+
 	// User statements
-	CreateUser     *sqlair.Statement
-	CreateOIDCUser *sqlair.Statement
-	GetUser        *sqlair.Statement
-	UpdateUser     *sqlair.Statement
-	UpdateUserRole *sqlair.Statement
-	ListUsers      *sqlair.Statement
-	DeleteUser     *sqlair.Statement
-	GetNumUsers    *sqlair.Statement
+	CreateUser            *sqlair.Statement
+	CreateOIDCUser        *sqlair.Statement
+	CreateLDAPUser        *sqlair.Statement
+	CreateInvitedUser     *sqlair.Statement
+	GetUser               *sqlair.Statement
+	UpdateUser            *sqlair.Statement
+	UpdateUserRole        *sqlair.Statement
+	UpdateUserOIDCSubject *sqlair.Statement
+	ClearUserOIDCSubject  *sqlair.Statement
+	ListUsers             *sqlair.Statement
+	DeleteUser            *sqlair.Statement
+	GetNumUsers           *sqlair.Statement
+
+	CreateServiceAccount *sqlair.Statement
 
 	// Encryption Key statements
 	CreateEncryptionKey *sqlair.Statement
@@ -392,25 +569,80 @@ type Statements struct {
 	DeleteEncryptionKey *sqlair.Statement
 
 	// JWT Secret statements
-	CreateJWTSecret *sqlair.Statement
-	GetJWTSecret    *sqlair.Statement
-	DeleteJWTSecret *sqlair.Statement
+	CreateSigningKey         *sqlair.Statement
+	ListSigningKeys          *sqlair.Statement
+	RetireSigningKeys        *sqlair.Statement
+	DeleteRetiredSigningKeys *sqlair.Statement
 
 	// ACME Account statements
-	InsertACMEAccount           *sqlair.Statement
-	GetACMEAccount              *sqlair.Statement
-	GetACMEAccountByEmailAndURL *sqlair.Statement
-	UpdateACMEAccount           *sqlair.Statement
-	DeleteACMEAccount           *sqlair.Statement
+	InsertACMEAccount            *sqlair.Statement
+	ListACMEAccounts             *sqlair.Statement
+	GetACMEAccount               *sqlair.Statement
+	GetACMEAccountByEmailAndURL  *sqlair.Statement
+	UpdateACMEAccount            *sqlair.Statement
+	DeleteACMEAccount            *sqlair.Statement
+	SetACMEAccountPendingKey     *sqlair.Statement
+	PromoteACMEAccountPendingKey *sqlair.Statement
 
 	// ACME Server statements
 	CreateACMEServer        *sqlair.Statement
 	ListACMEServers         *sqlair.Statement
 	GetACMEServer           *sqlair.Statement
-	GetActiveACMEServer     *sqlair.Statement
 	UpdateACMEServer        *sqlair.Statement
-	DeleteACMEServer        *sqlair.Statement
 	LinkACMEAccountToServer *sqlair.Statement
+
+	// ACME Route statements
+	ListACMERoutes *sqlair.Statement
+
+	// ACME DNS Registration statements
+	CreateACMEDNSRegistration      *sqlair.Statement
+	ListACMEDNSRegistrations       *sqlair.Statement
+	GetACMEDNSRegistration         *sqlair.Statement
+	GetACMEDNSRegistrationByDomain *sqlair.Statement
+	SetACMEDNSRecord               *sqlair.Statement
+	RemoveACMEDNSRecord            *sqlair.Statement
+	DeleteACMEDNSRegistration      *sqlair.Statement
+
+	// API Token statements
+	CreateAPIToken        *sqlair.Statement
+	ListAPITokens         *sqlair.Statement
+	GetAPIToken           *sqlair.Statement
+	GetAPITokenByTokenID  *sqlair.Statement
+	UpdateAPITokenLastUse *sqlair.Statement
+	DeleteAPIToken        *sqlair.Statement
+
+	// Session statements
+	CreateSession            *sqlair.Statement
+	ListSessions             *sqlair.Statement
+	GetSessionBySessionID    *sqlair.Statement
+	UpdateSessionLastSeen    *sqlair.Statement
+	DeleteSession            *sqlair.Statement
+	DeleteSessionBySessionID *sqlair.Statement
+	DeleteUserSessions       *sqlair.Statement
+	DeleteExpiredSessions    *sqlair.Statement
+	UpdateSessionAuthMethod  *sqlair.Statement
+
+	// TOTP statements
+	UpsertTOTPCredential     *sqlair.Statement
+	GetTOTPCredential        *sqlair.Statement
+	UpdateTOTPLastUsedStep   *sqlair.Statement
+	DeleteTOTPCredential     *sqlair.Statement
+	UseRecoveryCode          *sqlair.Statement
+	CountUnusedRecoveryCodes *sqlair.Statement
+
+	// Login failure statements
+	ReserveLoginAttempt     *sqlair.Statement
+	ReleaseLoginAttempt     *sqlair.Statement
+	GetLoginFailure         *sqlair.Statement
+	ListLockedLoginFailures *sqlair.Statement
+	LockLogin               *sqlair.Statement
+	DeleteLoginFailure      *sqlair.Statement
+
+	// Password token statements
+	CreatePasswordToken        *sqlair.Statement
+	GetPasswordToken           *sqlair.Statement
+	UsePasswordToken           *sqlair.Statement
+	DeleteUnusedPasswordTokens *sqlair.Statement
 }
 
 // PrepareStatements prepares all SQL statements used by the database.
@@ -422,6 +654,7 @@ func PrepareStatements() *Statements {
 	// Certificate Request statements
 	stmts.CreateCertificateRequest = sqlair.MustPrepare(createCertificateRequestStmt, CertificateRequest{})
 	stmts.GetCertificateRequest = sqlair.MustPrepare(getCertificateRequestStmt, CertificateRequest{})
+	stmts.GetCertificateRequestByCertificate = sqlair.MustPrepare(getCertificateRequestByCertificateStmt, CertificateRequest{})
 	stmts.GetCertificateRequestWithChain = sqlair.MustPrepare(getCertificateRequestWithCertificateStmt, CertificateRequestWithChain{})
 	stmts.UpdateCertificateRequest = sqlair.MustPrepare(updateCertificateRequestStmt, CertificateRequest{})
 	stmts.ListCertificateRequests = sqlair.MustPrepare(listCertificateRequestsStmt, CertificateRequest{})
@@ -430,6 +663,10 @@ func PrepareStatements() *Statements {
 	stmts.ListCertificateRequestsWithoutChain = sqlair.MustPrepare(listCertificateRequestsWithCertificatesWithoutCASStmt, CertificateRequestWithChain{})
 	stmts.ListCertificateRequestsWithoutChainByUserEmail = sqlair.MustPrepare(listCertificateRequestsWithCertificatesWithoutCASByUserEmailStmt, CertificateRequestWithChain{})
 	stmts.DeleteCertificateRequest = sqlair.MustPrepare(deleteCertificateRequestStmt, CertificateRequest{})
+	stmts.SetCertificateRequestAutoRenew = sqlair.MustPrepare(setCertificateRequestAutoRenewStmt, CertificateRequest{})
+	stmts.SetCertificateRequestSigningMethod = sqlair.MustPrepare(setCertificateRequestSigningStmt, CertificateRequest{})
+	stmts.SetCertificateRequestACMEIssuer = sqlair.MustPrepare(setCertificateRequestACMEIssuerStmt, CertificateRequest{})
+	stmts.ListActiveCertificateRequests = sqlair.MustPrepare(listActiveCertificateRequestsStmt, CertificateRequest{})
 
 	// Certificate statements
 	stmts.CreateCertificate = sqlair.MustPrepare(createCertificateStmt, Certificate{})
@@ -446,21 +683,52 @@ func PrepareStatements() *Statements {
 	stmts.ListCertificateAuthorities = sqlair.MustPrepare(listCertificateAuthoritiesStmt, CertificateAuthority{})
 	stmts.ListDenormalizedCertificateAuthorities = sqlair.MustPrepare(listDenormalizedCertificateAuthoritiesStmt, CertificateAuthorityDenormalized{})
 	stmts.DeleteCertificateAuthority = sqlair.MustPrepare(deleteCertificateAuthorityStmt, CertificateAuthority{})
+	stmts.SetCertificateAuthorityAutoRenew = sqlair.MustPrepare(setCertificateAuthorityAutoRenewStmt, CertificateAuthority{})
 
 	// Private Key statements
 	stmts.CreatePrivateKey = sqlair.MustPrepare(createPrivateKeyStmt, PrivateKey{})
 	stmts.GetPrivateKey = sqlair.MustPrepare(getPrivateKeyStmt, PrivateKey{})
 	stmts.DeletePrivateKey = sqlair.MustPrepare(deletePrivateKeyStmt, PrivateKey{})
 
+	// Generated Private Key statements
+	stmts.CreateGeneratedPrivateKey = sqlair.MustPrepare(createGeneratedPrivateKeyStmt, GeneratedPrivateKey{})
+	stmts.GetGeneratedPrivateKey = sqlair.MustPrepare(getGeneratedPrivateKeyStmt, GeneratedPrivateKey{})
+	stmts.DeliverGeneratedPrivateKey = sqlair.MustPrepare(deliverGeneratedPrivateKeyStmt, GeneratedPrivateKey{})
+
+	// Timestamping statements
+	stmts.CreateTSASigner = sqlair.MustPrepare(createTSASignerStmt, TSASigner{})
+	stmts.GetLatestTSASigner = sqlair.MustPrepare(getLatestTSASignerStmt, TSASigner{})
+	stmts.CreateTimestampToken = sqlair.MustPrepare(createTimestampTokenStmt, TimestampToken{})
+	stmts.GetTimestampToken = sqlair.MustPrepare(getTimestampTokenStmt, TimestampToken{})
+	stmts.ListTimestampTokens = sqlair.MustPrepare(listTimestampTokensStmt, TimestampToken{})
+
+	// Job statements
+	stmts.CreateJob = sqlair.MustPrepare(createJobStmt, Job{})
+	stmts.GetJob = sqlair.MustPrepare(getJobStmt, Job{})
+	stmts.ListJobs = sqlair.MustPrepare(listJobsStmt, Job{})
+	stmts.GetPendingJob = sqlair.MustPrepare(getPendingJobStmt, Job{})
+	stmts.GetLatestJob = sqlair.MustPrepare(getLatestJobStmt, Job{})
+	stmts.GetNextQueuedJob = sqlair.MustPrepare(getNextQueuedJobStmt, Job{})
+	stmts.ClaimJob = sqlair.MustPrepare(claimJobStmt, Job{})
+	stmts.FinishJob = sqlair.MustPrepare(finishJobStmt, Job{})
+	stmts.RetryJob = sqlair.MustPrepare(retryJobStmt, Job{})
+	stmts.CancelJob = sqlair.MustPrepare(cancelJobStmt, Job{})
+	stmts.RequeueRunningJobs = sqlair.MustPrepare(requeueRunningJobsStmt, Job{})
+
 	// User statements
 	stmts.CreateUser = sqlair.MustPrepare(createUserStmt, User{})
 	stmts.CreateOIDCUser = sqlair.MustPrepare(createOIDCUserStmt, User{})
+	stmts.CreateLDAPUser = sqlair.MustPrepare(createLDAPUserStmt, User{})
+	stmts.CreateInvitedUser = sqlair.MustPrepare(createInvitedUserStmt, User{})
 	stmts.GetUser = sqlair.MustPrepare(getUserStmt, User{})
 	stmts.UpdateUser = sqlair.MustPrepare(updateUserStmt, User{})
 	stmts.UpdateUserRole = sqlair.MustPrepare(updateUserRoleStmt, User{})
+	stmts.UpdateUserOIDCSubject = sqlair.MustPrepare(updateUserOIDCSubjectStmt, User{})
+	stmts.ClearUserOIDCSubject = sqlair.MustPrepare(clearUserOIDCSubjectStmt, User{})
 	stmts.ListUsers = sqlair.MustPrepare(listUsersStmt, User{})
 	stmts.DeleteUser = sqlair.MustPrepare(deleteUserStmt, User{})
 	stmts.GetNumUsers = sqlair.MustPrepare(getNumUsersStmt, NumUsers{})
+	stmts.CreateServiceAccount = sqlair.MustPrepare(createServiceAccountStmt, User{})
 
 	// Encryption Key statements
 	stmts.CreateEncryptionKey = sqlair.MustPrepare(createEncryptionKeyStmt, AES256GCMEncryptionKey{})
@@ -468,25 +736,80 @@ func PrepareStatements() *Statements {
 	stmts.DeleteEncryptionKey = sqlair.MustPrepare(deleteEncryptionKeyStmt, AES256GCMEncryptionKey{})
 
 	// JWT Secret statements
-	stmts.CreateJWTSecret = sqlair.MustPrepare(createJWTSecretStmt, JWTSecret{})
-	stmts.GetJWTSecret = sqlair.MustPrepare(getJWTSecretStmt, JWTSecret{})
-	stmts.DeleteJWTSecret = sqlair.MustPrepare(deleteJWTSecretStmt, JWTSecret{})
+	stmts.CreateSigningKey = sqlair.MustPrepare(createSigningKeyStmt, SigningKey{})
+	stmts.ListSigningKeys = sqlair.MustPrepare(listSigningKeysStmt, SigningKey{})
+	stmts.RetireSigningKeys = sqlair.MustPrepare(retireSigningKeysStmt, SigningKey{})
+	stmts.DeleteRetiredSigningKeys = sqlair.MustPrepare(deleteRetiredSigningKeysStmt, SigningKey{})
 
 	// ACME Account statements
 	stmts.InsertACMEAccount = sqlair.MustPrepare(insertACMEAccountStmt, ACMEAccount{})
+	stmts.ListACMEAccounts = sqlair.MustPrepare(listACMEAccountsStmt, ACMEAccount{})
 	stmts.GetACMEAccount = sqlair.MustPrepare(getACMEAccountStmt, ACMEAccount{})
 	stmts.GetACMEAccountByEmailAndURL = sqlair.MustPrepare(getACMEAccountByEmailAndURLStmt, ACMEAccount{})
 	stmts.UpdateACMEAccount = sqlair.MustPrepare(updateACMEAccountStmt, ACMEAccount{})
 	stmts.DeleteACMEAccount = sqlair.MustPrepare(deleteACMEAccountStmt, ACMEAccount{})
+	stmts.SetACMEAccountPendingKey = sqlair.MustPrepare(setACMEAccountPendingKeyStmt, ACMEAccount{})
+	stmts.PromoteACMEAccountPendingKey = sqlair.MustPrepare(promoteACMEAccountPendingKeyStmt, ACMEAccount{})
 
 	// ACME Server statements
 	stmts.CreateACMEServer = sqlair.MustPrepare(createACMEServerStmt, ACMEServer{})
 	stmts.ListACMEServers = sqlair.MustPrepare(listACMEServersStmt, ACMEServer{})
 	stmts.GetACMEServer = sqlair.MustPrepare(getACMEServerStmt, ACMEServer{})
-	stmts.GetActiveACMEServer = sqlair.MustPrepare(getActiveACMEServerStmt, ACMEServer{})
 	stmts.UpdateACMEServer = sqlair.MustPrepare(updateACMEServerStmt, ACMEServer{})
-	stmts.DeleteACMEServer = sqlair.MustPrepare(deleteACMEServerStmt, ACMEServer{})
 	stmts.LinkACMEAccountToServer = sqlair.MustPrepare(linkACMEAccountToServerStmt, ACMEServer{})
 
+	// ACME Route statements
+	stmts.ListACMERoutes = sqlair.MustPrepare(listACMERoutesStmt, ACMERoute{})
+
+	// ACME DNS Registration statements
+	stmts.CreateACMEDNSRegistration = sqlair.MustPrepare(createACMEDNSRegistrationStmt, ACMEDNSRegistration{})
+	stmts.ListACMEDNSRegistrations = sqlair.MustPrepare(listACMEDNSRegistrationsStmt, ACMEDNSRegistration{})
+	stmts.GetACMEDNSRegistration = sqlair.MustPrepare(getACMEDNSRegistrationStmt, ACMEDNSRegistration{})
+	stmts.GetACMEDNSRegistrationByDomain = sqlair.MustPrepare(getACMEDNSRegistrationByDomainStmt, ACMEDNSRegistration{})
+	stmts.SetACMEDNSRecord = sqlair.MustPrepare(setACMEDNSRecordStmt, ACMEDNSRegistration{})
+	stmts.RemoveACMEDNSRecord = sqlair.MustPrepare(removeACMEDNSRecordStmt, ACMEDNSRegistration{})
+	stmts.DeleteACMEDNSRegistration = sqlair.MustPrepare(deleteACMEDNSRegistrationStmt, ACMEDNSRegistration{})
+
+	// API Token statements
+	stmts.CreateAPIToken = sqlair.MustPrepare(createAPITokenStmt, APIToken{})
+	stmts.ListAPITokens = sqlair.MustPrepare(listAPITokensStmt, APIToken{})
+	stmts.GetAPIToken = sqlair.MustPrepare(getAPITokenStmt, APIToken{})
+	stmts.GetAPITokenByTokenID = sqlair.MustPrepare(getAPITokenByTokenIDStmt, APIToken{})
+	stmts.UpdateAPITokenLastUse = sqlair.MustPrepare(updateAPITokenLastUseStmt, APIToken{})
+	stmts.DeleteAPIToken = sqlair.MustPrepare(deleteAPITokenStmt, APIToken{})
+
+	// Session statements
+	stmts.CreateSession = sqlair.MustPrepare(createSessionStmt, Session{})
+	stmts.ListSessions = sqlair.MustPrepare(listSessionsStmt, Session{})
+	stmts.GetSessionBySessionID = sqlair.MustPrepare(getSessionBySessionIDStmt, Session{})
+	stmts.UpdateSessionLastSeen = sqlair.MustPrepare(updateSessionLastSeenStmt, Session{})
+	stmts.DeleteSession = sqlair.MustPrepare(deleteSessionStmt, Session{})
+	stmts.DeleteSessionBySessionID = sqlair.MustPrepare(deleteSessionBySessionIDStmt, Session{})
+	stmts.DeleteUserSessions = sqlair.MustPrepare(deleteUserSessionsStmt, Session{})
+	stmts.DeleteExpiredSessions = sqlair.MustPrepare(deleteExpiredSessionsStmt, Session{})
+	stmts.UpdateSessionAuthMethod = sqlair.MustPrepare(updateSessionAuthMethodStmt, Session{})
+
+	// TOTP statements
+	stmts.UpsertTOTPCredential = sqlair.MustPrepare(upsertTOTPCredentialStmt, TOTPCredential{})
+	stmts.GetTOTPCredential = sqlair.MustPrepare(getTOTPCredentialStmt, TOTPCredential{})
+	stmts.UpdateTOTPLastUsedStep = sqlair.MustPrepare(updateTOTPLastUsedStepStmt, TOTPCredential{})
+	stmts.DeleteTOTPCredential = sqlair.MustPrepare(deleteTOTPCredentialStmt, TOTPCredential{})
+	stmts.UseRecoveryCode = sqlair.MustPrepare(useRecoveryCodeStmt, RecoveryCode{})
+	stmts.CountUnusedRecoveryCodes = sqlair.MustPrepare(countUnusedRecoveryCodesStmt, NumRecoveryCodes{}, RecoveryCode{})
+
+	// Login failure statements
+	stmts.ReserveLoginAttempt = sqlair.MustPrepare(reserveLoginAttemptStmt, LoginFailure{}, LoginAttemptLimits{})
+	stmts.ReleaseLoginAttempt = sqlair.MustPrepare(releaseLoginAttemptStmt, LoginFailure{})
+	stmts.GetLoginFailure = sqlair.MustPrepare(getLoginFailureStmt, LoginFailure{})
+	stmts.ListLockedLoginFailures = sqlair.MustPrepare(listLockedLoginFailuresStmt, LoginFailure{})
+	stmts.LockLogin = sqlair.MustPrepare(lockLoginStmt, LoginFailure{})
+	stmts.DeleteLoginFailure = sqlair.MustPrepare(deleteLoginFailureStmt, LoginFailure{})
+
+	// Password token statements
+	stmts.CreatePasswordToken = sqlair.MustPrepare(createPasswordTokenStmt, PasswordToken{})
+	stmts.GetPasswordToken = sqlair.MustPrepare(getPasswordTokenStmt, PasswordToken{})
+	stmts.UsePasswordToken = sqlair.MustPrepare(usePasswordTokenStmt, PasswordToken{})
+	stmts.DeleteUnusedPasswordTokens = sqlair.MustPrepare(deleteUnusedPasswordTokensStmt, PasswordToken{})
+
 	return stmts
 }
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/canonical/sqlair"
 	"go.uber.org/zap"
 )
@@ -18,7 +21,6 @@ type DatabaseRepository struct {
 
 	Path          string
 	EncryptionKey []byte
-	JWTSecret     []byte
 }
 
 const CAMaxExpiryYears = 1
@@ -29,8 +31,9 @@ const CAMaxExpiryYears = 1
 type CertificateAuthority struct {
 	CertificateAuthorityID int64 `db:"certificate_authority_id"`
 
-	CRL     string `db:"crl"`
-	Enabled bool   `db:"enabled"`
+	CRL       string `db:"crl"`
+	Enabled   bool   `db:"enabled"`
+	AutoRenew bool   `db:"auto_renew"`
 
 	PrivateKeyID  int64 `db:"private_key_id"`
 	CertificateID int64 `db:"certificate_id"`
@@ -44,6 +47,7 @@ type CertificateAuthorityDenormalized struct {
 	CertificateAuthorityID int64  `db:"certificate_authority_id"`
 	CRL                    string `db:"crl"`
 	Enabled                bool   `db:"enabled"`
+	AutoRenew              bool   `db:"auto_renew"`
 	PrivateKeyID           int64  `db:"private_key_id"`
 	CertificateChain       string `db:"certificate_chain"`
 	CSRPEM                 string `db:"csr"`
@@ -69,8 +73,25 @@ type CertificateRequest struct {
 	Status        string `db:"status"`
 	CertificateID int64  `db:"certificate_id"`
 	UserEmail     string `db:"user_email"`
+	AutoRenew     bool   `db:"auto_renew"`
+	SigningMethod string `db:"signing_method"`
+	// ACMEServerID and ACMEAccountID identify the ACME server and account that issued the certificate,
+	// when it was obtained via ACME.
+	ACMEServerID  *int64 `db:"acme_server_id"`
+	ACMEAccountID *int64 `db:"acme_account_id"`
+	// ACMEPreferredChain and ACMEProfile are the options the certificate was ordered with, which renewals reuse,
+	// and ACMEChain is the common name of the issuer at the top of the chain that the ACME server returned.
+	ACMEPreferredChain string `db:"acme_preferred_chain"`
+	ACMEProfile        string `db:"acme_profile"`
+	ACMEChain          string `db:"acme_chain"`
 }
 
+// Signing methods of a certificate request. Certificates that were uploaded have no signing method.
+const (
+	SigningMethodCA   = "ca"
+	SigningMethodACME = "acme"
+)
+
 // CertificateRequestWithChain contains the same information as the CertificateRequest object,
 // but this object contains the PEM encoded string chain of its assigned certificate directly embedded to
 // the struct instead of an ID integer.
@@ -81,6 +102,9 @@ type CertificateRequestWithChain struct {
 	Status           string `db:"status"`
 	CertificateChain string `db:"certificate_chain"`
 	UserEmail        string `db:"user_email"`
+	AutoRenew        bool   `db:"auto_renew"`
+	ACMEProfile      string `db:"acme_profile"`
+	ACMEChain        string `db:"acme_chain"`
 }
 
 // PrivateKey contains the PEM encoded string of a private key. This object is only used in relation
@@ -109,6 +133,10 @@ type User struct {
 	HashedPassword *string `db:"hashed_password"` // Nullable for OIDC-only users
 	RoleID         RoleID  `db:"role_id"`
 	OIDCSubject    *string `db:"oidc_subject"` // OIDC provider's subject identifier
+	// ServiceAccount is set for the accounts of automation, which have no password and authenticate with API tokens.
+	ServiceAccount bool `db:"service_account"`
+	// LDAPDN is the DN of the entry of the user in the LDAP directory that they log in with.
+	LDAPDN *string `db:"ldap_dn"`
 }
 
 // HasPassword checks if a user has a local password set
@@ -121,10 +149,161 @@ func (u *User) HasOIDC() bool {
 	return u.OIDCSubject != nil && *u.OIDCSubject != ""
 }
 
+// HasLDAP checks if a user logs in with an LDAP directory
+func (u *User) HasLDAP() bool {
+	return u.LDAPDN != nil && *u.LDAPDN != ""
+}
+
 type NumUsers struct {
 	Count int `db:"count"`
 }
 
+// APIToken is a long-lived token of a service account. Only a hash of its secret is stored.
+type APIToken struct {
+	ID     int64  `db:"id"`
+	UserID int64  `db:"user_id"`
+	Name   string `db:"name"`
+	// TokenID is the public part of the token, which identifies it.
+	TokenID      string `db:"token_id"`
+	HashedSecret string `db:"hashed_secret"`
+	// Scopes is a comma separated list of the actions that the token can be used for.
+	Scopes string `db:"scopes"`
+	// CertificateAuthorityIDs is a comma separated list of the certificate authorities that the token is limited to.
+	// It isn't limited to any when it is empty.
+	CertificateAuthorityIDs string `db:"certificate_authority_ids"`
+	ExpiresAt               int64  `db:"expires_at"`
+	LastUsedAt              int64  `db:"last_used_at"`
+	CreatedBy               string `db:"created_by"`
+	CreatedAt               int64  `db:"created_at"`
+}
+
+// Session is a login of a user. The token of the session cookie references it by SessionID,
+// and it stops being valid once the session is deleted.
+type Session struct {
+	ID        int64  `db:"id"`
+	UserID    int64  `db:"user_id"`
+	SessionID string `db:"session_id"`
+	IPAddress string `db:"ip_address"`
+	UserAgent string `db:"user_agent"`
+	CreatedAt int64  `db:"created_at"`
+	// LastSeenAt is when the session was last used, to the minute.
+	LastSeenAt int64 `db:"last_seen_at"`
+	ExpiresAt  int64 `db:"expires_at"`
+	// AuthMethod is how the user authenticated to start the session: one of the SessionAuthMethod constants.
+	AuthMethod string `db:"auth_method"`
+}
+
+// The ways a user can authenticate to start a session.
+const (
+	SessionAuthMethodPassword = "password"
+	SessionAuthMethodMFA      = "mfa"
+	SessionAuthMethodOIDC     = "oidc"
+	SessionAuthMethodLDAP     = "ldap"
+)
+
+// TOTPCredential is the TOTP authenticator of a user. Its secret is encrypted, and it is pending until
+// the user verifies a first code with it.
+type TOTPCredential struct {
+	UserID          int64  `db:"user_id"`
+	EncryptedSecret string `db:"encrypted_secret"`
+	Enabled         bool   `db:"enabled"`
+	// LastUsedStep is the time step of the last code accepted, so that a code can't be used twice.
+	LastUsedStep int64 `db:"last_used_step"`
+	CreatedAt    int64 `db:"created_at"`
+}
+
+// RecoveryCode is a single use code that stands in for a TOTP code. Only its hash is stored.
+type RecoveryCode struct {
+	ID         int64  `db:"id"`
+	UserID     int64  `db:"user_id"`
+	HashedCode string `db:"hashed_code"`
+	UsedAt     int64  `db:"used_at"`
+}
+
+type NumRecoveryCodes struct {
+	Count int `db:"count"`
+}
+
+// The purposes of password tokens.
+const (
+	// PasswordTokenPurposeInvitation tokens let invited users set the password of their new account.
+	PasswordTokenPurposeInvitation = "invitation"
+	// PasswordTokenPurposeReset tokens let users set a new password, in place of the one they forgot.
+	PasswordTokenPurposeReset = "reset"
+)
+
+// PasswordToken is a single use token that lets a user set their own password. Only its hash is stored.
+type PasswordToken struct {
+	ID          int64  `db:"id"`
+	UserID      int64  `db:"user_id"`
+	HashedToken string `db:"hashed_token"`
+	// Purpose is why the token was issued: one of the PasswordTokenPurpose constants.
+	Purpose   string `db:"purpose"`
+	CreatedBy string `db:"created_by"`
+	CreatedAt int64  `db:"created_at"`
+	ExpiresAt int64  `db:"expires_at"`
+	// UsedAt is when the token was used, or 0 when it wasn't.
+	UsedAt int64 `db:"used_at"`
+}
+
+// The scopes that failed login attempts are counted in.
+const (
+	LoginFailureScopeAccount = "account"
+	LoginFailureScopeIP      = "ip"
+)
+
+// LoginFailure counts the recent failed login attempts of an account, identified by its email, or of an IP address.
+type LoginFailure struct {
+	Scope         string `db:"scope"`
+	Identifier    string `db:"identifier"`
+	Failures      int64  `db:"failures"`
+	LastFailureAt int64  `db:"last_failure_at"`
+	// LockedUntil is when the lockout that the failures caused ends, or 0 when there is none.
+	LockedUntil int64 `db:"locked_until"`
+}
+
+// LoginAttemptLimits are how a login attempt is counted: the failed attempts before Since are forgotten, and the attempt
+// that reaches MaxAttempts failures locks out the others until LeaseUntil, while it runs.
+type LoginAttemptLimits struct {
+	Since       int64 `db:"since"`
+	MaxAttempts int64 `db:"max_attempts"`
+	LeaseUntil  int64 `db:"lease_until"`
+}
+
+// GeneratedPrivateKey is a private key that Notary generated on behalf of a requestor.
+// The key is wiped once it has been delivered, only the delivered flag remains.
+type GeneratedPrivateKey struct {
+	CSR_ID int64 `db:"csr_id"`
+
+	PrivateKeyPEM string `db:"private_key"`
+	Delivered     bool   `db:"delivered"`
+}
+
+// TSASigner is the key and certificate that the timestamping authority signs tokens with.
+// The certificate is issued by the certificate authority that backs the timestamping authority.
+type TSASigner struct {
+	ID                     int64 `db:"id"`
+	CertificateAuthorityID int64 `db:"certificate_authority_id"`
+	// CSRID is the certificate request that the certificate was issued for. It is 0 for the signers of earlier versions.
+	CSRID int64 `db:"csr_id"`
+
+	PrivateKeyPEM    string `db:"private_key"`
+	CertificateChain string `db:"certificate_chain"`
+}
+
+// TimestampToken is the record of a timestamp token issued by the timestamping authority.
+type TimestampToken struct {
+	ID int64 `db:"id"`
+
+	SerialNumber  string `db:"serial_number"`
+	GenTime       string `db:"gen_time"`
+	PolicyOID     string `db:"policy_oid"`
+	HashAlgorithm string `db:"hash_algorithm"`
+	HashedMessage string `db:"hashed_message"`
+	Nonce         string `db:"nonce"`
+	TSASignerID   int64  `db:"tsa_signer_id"`
+}
+
 type ACMEAccount struct {
 	ID               int64  `db:"id"`
 	Email            string `db:"email"`
@@ -132,15 +311,196 @@ type ACMEAccount struct {
 	PrivateKeyPEM    string `db:"private_key"`
 	RegistrationURI  string `db:"registration_uri"`
 	RegistrationBody string `db:"registration_body"`
+	// Status is the status of the account on its ACME server. Only valid accounts are used to order certificates.
+	Status string `db:"status"`
+	// PendingPrivateKeyPEM is the new key of a key rollover that the ACME server hasn't confirmed yet, empty if
+	// no rollover is in progress.
+	PendingPrivateKeyPEM string `db:"pending_private_key"`
 }
 
+// ACME account statuses, as defined in RFC 8555
+const (
+	ACMEAccountStatusValid       = "valid"
+	ACMEAccountStatusDeactivated = "deactivated"
+	ACMEAccountStatusRevoked     = "revoked"
+)
+
 type ACMEServer struct {
-	ID            int64  `db:"id"`
-	Name          string `db:"name"`
-	DirectoryURL  string `db:"directory_url"`
-	Email         string `db:"email"`
-	DNSProvider   string `db:"dns_provider"`
-	EnvVars       string `db:"env_vars"`
+	ID           int64  `db:"id"`
+	Name         string `db:"name"`
+	DirectoryURL string `db:"directory_url"`
+	Email        string `db:"email"`
+	DNSProvider  string `db:"dns_provider"`
+	EnvVars      string `db:"env_vars"`
+	// Active is set when an ACME route sends certificate requests to the server.
 	Active        bool   `db:"active"`
 	ACMEAccountID *int64 `db:"acme_account_id"`
+	ChallengeType string `db:"challenge_type"`
+	ChallengePort int64  `db:"challenge_port"`
+	Webroot       string `db:"webroot"`
+	EABKeyID      string `db:"eab_key_id"`
+	EABHMACKey    string `db:"eab_hmac_key"`
+	// DNSResolvers is a comma separated list of host:port, and DNSChallengeAliases a JSON object.
+	DNSResolvers              string `db:"dns_resolvers"`
+	DNSPropagationTimeout     int64  `db:"dns_propagation_timeout"`
+	DNSPollingInterval        int64  `db:"dns_polling_interval"`
+	DNSSkipAuthoritativeCheck bool   `db:"dns_skip_authoritative_check"`
+	DNSChallengeAliases       string `db:"dns_challenge_aliases"`
+	PreferredChain            string `db:"preferred_chain"`
+	Profile                   string `db:"profile"`
+}
+
+// ACMERoute sends the certificate requests whose names end with DomainSuffix to an ACME server.
+// Routes are matched in order of Position, and a route with an empty domain suffix matches every name.
+type ACMERoute struct {
+	ID           int64  `db:"id"`
+	Position     int64  `db:"position"`
+	DomainSuffix string `db:"domain_suffix"`
+	ACMEServerID int64  `db:"acme_server_id"`
+}
+
+// ACME challenge types
+const (
+	ACMEChallengeDNS01     = "dns-01"
+	ACMEChallengeHTTP01    = "http-01"
+	ACMEChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+// ACMEChallenge describes how the challenges of an ACME server are solved.
+// DNS-01 challenges use the DNS provider of the server. HTTP-01 challenges are served from
+// the webroot directory if it is set, otherwise by a built-in responder listening on the port,
+// and TLS-ALPN-01 challenges are served by a built-in responder listening on the port.
+type ACMEChallenge struct {
+	Type    string
+	Port    int64
+	Webroot string
+	DNS     ACMEDNSSettings
+}
+
+// ACMEDNSSettings tune how DNS-01 challenges are checked before the ACME server is asked to validate them.
+// Zero values keep the defaults of the DNS provider and of the system resolver.
+type ACMEDNSSettings struct {
+	// Resolvers are the recursive nameservers, as host:port, used to look up zones and check propagation.
+	Resolvers []string
+	// PropagationTimeout and PollingInterval are in seconds.
+	PropagationTimeout int64
+	PollingInterval    int64
+	// SkipAuthoritativeCheck only checks propagation on the recursive nameservers, for authoritative
+	// nameservers that aren't reachable.
+	SkipAuthoritativeCheck bool
+	// ChallengeAliases maps a domain to the domain whose _acme-challenge record its challenges are written to,
+	// for domains whose _acme-challenge record is a CNAME delegating the challenge to another zone.
+	// A domain also applies to its subdomains.
+	ChallengeAliases map[string]string
+}
+
+// Challenge returns the challenge settings of the ACME server.
+func (s *ACMEServer) Challenge() ACMEChallenge {
+	dns := ACMEDNSSettings{
+		PropagationTimeout:     s.DNSPropagationTimeout,
+		PollingInterval:        s.DNSPollingInterval,
+		SkipAuthoritativeCheck: s.DNSSkipAuthoritativeCheck,
+	}
+	if s.DNSResolvers != "" {
+		dns.Resolvers = strings.Split(s.DNSResolvers, ",")
+	}
+	if s.DNSChallengeAliases != "" {
+		// The aliases are validated and encoded by CreateACMEServer and UpdateACMEServer.
+		_ = json.Unmarshal([]byte(s.DNSChallengeAliases), &dns.ChallengeAliases)
+	}
+	return ACMEChallenge{Type: s.ChallengeType, Port: s.ChallengePort, Webroot: s.Webroot, DNS: dns}
+}
+
+// ACMEExternalAccountBinding holds the External Account Binding credentials that some ACME CAs
+// require to register an account. The HMAC key is base64url encoded, as handed out by the CA.
+type ACMEExternalAccountBinding struct {
+	KeyID   string
+	HMACKey string
+}
+
+// IsSet reports whether External Account Binding is configured.
+func (b ACMEExternalAccountBinding) IsSet() bool {
+	return b.KeyID != ""
+}
+
+// ACMEOrderOptions select the certificate that an ACME server issues for an order. PreferredChain is the
+// common name of the issuer at the top of the preferred alternate chain, and Profile is one of the certificate
+// profiles that the ACME server advertises in its directory. Empty values keep the defaults of the ACME server.
+type ACMEOrderOptions struct {
+	PreferredChain string
+	Profile        string
+}
+
+// OrderOptions returns the default order options of the ACME server.
+func (s *ACMEServer) OrderOptions() ACMEOrderOptions {
+	return ACMEOrderOptions{PreferredChain: s.PreferredChain, Profile: s.Profile}
+}
+
+// ExternalAccountBinding returns the External Account Binding credentials of the ACME server.
+// They are only usable on a server returned by one of the GetDecrypted functions.
+func (s *ACMEServer) ExternalAccountBinding() ACMEExternalAccountBinding {
+	return ACMEExternalAccountBinding{KeyID: s.EABKeyID, HMACKey: s.EABHMACKey}
+}
+
+// ACMEDNSRegistration is an account of the built-in acme-dns compatible service. Its holder sets the TXT record
+// of Subdomain, under the zone of the service, to answer the DNS-01 challenges that are delegated to it with a CNAME.
+// Timestamps are unix seconds.
+type ACMEDNSRegistration struct {
+	ID             int64  `db:"id"`
+	Username       string `db:"username"`
+	HashedPassword string `db:"hashed_password"`
+	Subdomain      string `db:"subdomain"`
+	// AllowFrom is a comma separated list of the networks, in CIDR notation, that can update the record.
+	// Any network can when it is empty.
+	AllowFrom string `db:"allow_from"`
+	// Domain is the domain whose challenges are delegated to the registration, for the ACME servers
+	// that solve DNS-01 challenges with the built-in service. It is empty for the other registrations.
+	Domain string `db:"domain"`
+	// TXT and PreviousTXT are the two most recent values of the record, so that a wildcard
+	// and its base domain can be validated by the same order.
+	TXT         string `db:"txt"`
+	PreviousTXT string `db:"previous_txt"`
+	CreatedBy   string `db:"created_by"`
+	CreatedAt   int64  `db:"created_at"`
+	UpdatedAt   int64  `db:"updated_at"`
+}
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job is a unit of background work. The payload is a JSON document whose shape depends on the job type.
+// Timestamps are unix seconds.
+type Job struct {
+	ID int64 `db:"id"`
+
+	Type        string `db:"type"`
+	Payload     string `db:"payload"`
+	Status      string `db:"status"`
+	Attempts    int64  `db:"attempts"`
+	MaxAttempts int64  `db:"max_attempts"`
+	LastError   string `db:"last_error"`
+	RunAfter    int64  `db:"run_after"`
+	CreatedBy   string `db:"created_by"`
+	CreatedAt   int64  `db:"created_at"`
+	UpdatedAt   int64  `db:"updated_at"`
+}
+
+// SigningKey is a key that signs the session tokens of Notary. The newest key signs the tokens, and the keys that
+// were rotated out keep verifying them for a grace period.
+type SigningKey struct {
+	ID int64 `db:"id"`
+	// KID identifies the key in the header of the tokens it signs.
+	KID       string `db:"kid"`
+	Algorithm string `db:"algorithm"`
+	// PrivateKeyPEM is the PKCS #8 private key, which is encrypted in the database.
+	PrivateKeyPEM string `db:"private_key"`
+	CreatedAt     int64  `db:"created_at"`
+	// RetiredAt is when the key was rotated out, or 0 while it signs the tokens.
+	RetiredAt int64 `db:"retired_at"`
 }
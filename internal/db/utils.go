@@ -74,6 +74,12 @@ func SplitCertificateBundle(cert string) ([]string, error) {
 // adds the first certificate in the chain to the CRL, uses the second certificate in the chain and
 // the private key to sign a new CRL and returns this new CRL with the certificate added.
 func AddCertificateToCRL(certChainPEM string, caPKPEM string, crlPEM string) (string, error) {
+	return AddCertificateToCRLWithReason(certChainPEM, caPKPEM, crlPEM, 0)
+}
+
+// AddCertificateToCRLWithReason adds a certificate to a CRL like AddCertificateToCRL, with the RFC 5280 reason code
+// of its revocation. The reason codes of the entries that are already in the CRL are kept.
+func AddCertificateToCRLWithReason(certChainPEM string, caPKPEM string, crlPEM string, reasonCode int) (string, error) {
 	pk, err := ParsePrivateKey(caPKPEM)
 	if err != nil {
 		return "", err
@@ -89,6 +95,7 @@ func AddCertificateToCRL(certChainPEM string, caPKPEM string, crlPEM string) (st
 	crl.RevokedCertificateEntries = append(crl.RevokedCertificateEntries, x509.RevocationListEntry{
 		SerialNumber:   certificates[0].SerialNumber,
 		RevocationTime: time.Now(),
+		ReasonCode:     reasonCode,
 	})
 	crlBytes, err := x509.CreateRevocationList(rand.Reader, crl, certificates[1], pk)
 	if err != nil {
@@ -0,0 +1,264 @@
+// Package jobs runs background work that is persisted in the database, so that it survives restarts.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultWorkers         = 2
+	DefaultPollInterval    = 5 * time.Second
+	DefaultRetryBackoff    = 30 * time.Second
+	DefaultMaxRetryBackoff = 10 * time.Minute
+)
+
+// ErrCancelled is the cause of the context given to a handler when its job is cancelled.
+var ErrCancelled = errors.New("job cancelled")
+
+// Handler does the work of a job. Returning an error makes the job fail,
+// it is retried with a backoff unless the error is wrapped with Permanent or the job is out of attempts.
+type Handler func(ctx context.Context, job *db.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as one that retrying the job won't fix.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent.
+func IsPermanent(err error) bool {
+	return errors.As(err, new(*permanentError))
+}
+
+// Runner claims queued jobs from the database and runs them on a pool of workers.
+// Settings can be changed until Start is called.
+type Runner struct {
+	Workers         int
+	PollInterval    time.Duration
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	db       *db.DatabaseRepository
+	logger   *zap.Logger
+	handlers map[string]Handler
+	tasks    []periodicTask
+
+	wake chan struct{}
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[int64]context.CancelCauseFunc
+}
+
+// NewRunner creates a job runner with the default settings.
+func NewRunner(database *db.DatabaseRepository, logger *zap.Logger) *Runner {
+	return &Runner{
+		Workers:         DefaultWorkers,
+		PollInterval:    DefaultPollInterval,
+		RetryBackoff:    DefaultRetryBackoff,
+		MaxRetryBackoff: DefaultMaxRetryBackoff,
+		db:              database,
+		logger:          logger,
+		handlers:        make(map[string]Handler),
+		wake:            make(chan struct{}, 1),
+		running:         make(map[int64]context.CancelCauseFunc),
+	}
+}
+
+// Register sets the handler for a job type. Handlers must be registered before Start is called.
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.handlers[jobType] = handler
+}
+
+// periodicTask is a function the runner calls on a fixed interval, such as one that enqueues jobs.
+type periodicTask struct {
+	interval time.Duration
+	run      func(ctx context.Context)
+}
+
+// Every registers a task that runs when the runner starts and then every interval, until the runner stops.
+// Tasks must be registered before Start is called.
+func (r *Runner) Every(interval time.Duration, task func(ctx context.Context)) {
+	r.tasks = append(r.tasks, periodicTask{interval: interval, run: task})
+}
+
+// Enqueue queues a job and wakes up an idle worker.
+func (r *Runner) Enqueue(jobType string, payload string, maxAttempts int64, createdBy string) (int64, error) {
+	if _, ok := r.handlers[jobType]; !ok {
+		return 0, fmt.Errorf("no handler registered for job type %q", jobType)
+	}
+	id, err := r.db.CreateJob(jobType, payload, maxAttempts, time.Now(), createdBy)
+	if err != nil {
+		return 0, err
+	}
+	r.notify()
+	return id, nil
+}
+
+// Cancel cancels a queued or running job. A running job has its context cancelled,
+// the outcome of the handler is discarded once it returns.
+// It returns db.ErrNotFound if there is no job with this ID that is still queued or running.
+func (r *Runner) Cancel(id int64) error {
+	if err := r.db.CancelJob(id); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	cancel, ok := r.running[id]
+	r.mu.Unlock()
+	if ok {
+		cancel(ErrCancelled)
+	}
+	return nil
+}
+
+// Start requeues the jobs that were interrupted by a previous shutdown and starts the workers.
+func (r *Runner) Start() error {
+	if err := r.db.RequeueRunningJobs(); err != nil {
+		return fmt.Errorf("couldn't requeue interrupted jobs: %w", err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	for range r.Workers {
+		r.wg.Add(1)
+		go r.work(ctx)
+	}
+	for _, task := range r.tasks {
+		r.wg.Add(1)
+		go r.repeat(ctx, task)
+	}
+	return nil
+}
+
+// Stop cancels the running jobs and waits for the workers to exit.
+// Jobs that were running are left as such in the database and are requeued on the next Start.
+func (r *Runner) Stop() {
+	if r.stop == nil {
+		return
+	}
+	r.stop()
+	r.wg.Wait()
+}
+
+func (r *Runner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Runner) work(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := r.db.ClaimNextJob()
+		if err == nil {
+			r.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			r.logger.Error("failed to claim job", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) repeat(ctx context.Context, task periodicTask) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		task.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job *db.Job) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	r.mu.Lock()
+	r.running[job.ID] = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, job.ID)
+		r.mu.Unlock()
+	}()
+
+	logger := r.logger.With(zap.Int64("job_id", job.ID), zap.String("job_type", job.Type), zap.Int64("attempt", job.Attempts))
+	jobErr := r.handle(jobCtx, job)
+	if ctx.Err() != nil {
+		// Shutting down, the job is resumed on the next start.
+		return
+	}
+
+	var err error
+	switch {
+	case jobErr == nil:
+		err = r.db.CompleteJob(job.ID, nil)
+	case errors.Is(context.Cause(jobCtx), ErrCancelled):
+		return
+	case job.Attempts >= job.MaxAttempts || IsPermanent(jobErr):
+		logger.Error("job failed", zap.Error(jobErr))
+		err = r.db.CompleteJob(job.ID, jobErr)
+	default:
+		backoff := r.backoff(job.Attempts)
+		logger.Warn("job failed, retrying", zap.Error(jobErr), zap.Duration("backoff", backoff))
+		err = r.db.RetryJob(job.ID, jobErr, time.Now().Add(backoff))
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		logger.Info("job was cancelled while running, discarding its outcome")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to record job outcome", zap.Error(err))
+	}
+}
+
+func (r *Runner) handle(ctx context.Context, job *db.Job) (err error) {
+	handler, ok := r.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for job type %q", job.Type))
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job handler panicked: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff doubles the retry delay with every attempt, up to MaxRetryBackoff.
+func (r *Runner) backoff(attempts int64) time.Duration {
+	backoff := r.RetryBackoff
+	for i := int64(1); i < attempts && backoff < r.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.MaxRetryBackoff)
+}
@@ -0,0 +1,201 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
+	tu "github.com/canonical/notary/internal/testutils"
+	"go.uber.org/zap"
+)
+
+func mustStartRunner(t *testing.T, database *db.DatabaseRepository, handlers map[string]jobs.Handler) *jobs.Runner {
+	t.Helper()
+	runner := jobs.NewRunner(database, zap.NewNop())
+	runner.PollInterval = 10 * time.Millisecond
+	runner.RetryBackoff = 0
+	for jobType, handler := range handlers {
+		runner.Register(jobType, handler)
+	}
+	if err := runner.Start(); err != nil {
+		t.Fatalf("couldn't start job runner: %s", err)
+	}
+	t.Cleanup(runner.Stop)
+	return runner
+}
+
+func mustWaitForStatus(t *testing.T, database *db.DatabaseRepository, id int64, status string) *db.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := database.GetJob(id)
+		if err != nil {
+			t.Fatalf("couldn't get job: %s", err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected job %d to be %s, got %+v", id, status, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunnerRetriesUntilSuccess(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	var calls atomic.Int64
+	runner := mustStartRunner(t, database, map[string]jobs.Handler{
+		"flaky": func(ctx context.Context, job *db.Job) error {
+			if calls.Add(1) < 3 {
+				return errors.New("transient failure")
+			}
+			if job.Payload != "payload" {
+				return jobs.Permanent(errors.New("unexpected payload"))
+			}
+			return nil
+		},
+	})
+
+	id, err := runner.Enqueue("flaky", "payload", 3, "testuser@example.com")
+	if err != nil {
+		t.Fatalf("couldn't enqueue job: %s", err)
+	}
+	job := mustWaitForStatus(t, database, id, db.JobStatusSucceeded)
+	if job.Attempts != 3 || job.LastError != "" || job.CreatedBy != "testuser@example.com" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+}
+
+func TestRunnerFailures(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	runner := mustStartRunner(t, database, map[string]jobs.Handler{
+		"failing": func(ctx context.Context, job *db.Job) error {
+			return errors.New("always fails")
+		},
+		"permanent": func(ctx context.Context, job *db.Job) error {
+			return jobs.Permanent(errors.New("bad input"))
+		},
+		"panicking": func(ctx context.Context, job *db.Job) error {
+			panic("boom")
+		},
+	})
+
+	cases := []struct {
+		jobType          string
+		maxAttempts      int64
+		expectedAttempts int64
+		expectedError    string
+	}{
+		{"failing", 2, 2, "always fails"},
+		{"permanent", 5, 1, "bad input"},
+		{"panicking", 1, 1, "job handler panicked: boom"},
+	}
+	for _, tc := range cases {
+		id, err := runner.Enqueue(tc.jobType, "", tc.maxAttempts, "")
+		if err != nil {
+			t.Fatalf("couldn't enqueue job: %s", err)
+		}
+		job := mustWaitForStatus(t, database, id, db.JobStatusFailed)
+		if job.Attempts != tc.expectedAttempts || job.LastError != tc.expectedError {
+			t.Fatalf("%s: unexpected job %+v", tc.jobType, job)
+		}
+	}
+
+	_, err := runner.Enqueue("unknown", "", 1, "")
+	if err == nil {
+		t.Fatalf("expected an error when enqueueing a job with no handler")
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	started := make(chan struct{})
+	var cause atomic.Value
+	runner := mustStartRunner(t, database, map[string]jobs.Handler{
+		"blocking": func(ctx context.Context, job *db.Job) error {
+			close(started)
+			<-ctx.Done()
+			cause.Store(context.Cause(ctx))
+			return ctx.Err()
+		},
+	})
+
+	id, err := runner.Enqueue("blocking", "", 3, "")
+	if err != nil {
+		t.Fatalf("couldn't enqueue job: %s", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job didn't start")
+	}
+	if err := runner.Cancel(id); err != nil {
+		t.Fatalf("couldn't cancel job: %s", err)
+	}
+	job := mustWaitForStatus(t, database, id, db.JobStatusCancelled)
+	if job.Attempts != 1 {
+		t.Fatalf("expected the cancelled job not to be retried, got %+v", job)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if c, _ := cause.Load().(error); !errors.Is(c, jobs.ErrCancelled) {
+		t.Fatalf("expected the handler context to be cancelled with ErrCancelled, got %v", c)
+	}
+	if job, _ := database.GetJob(id); job.Status != db.JobStatusCancelled {
+		t.Fatalf("expected the job to stay cancelled, got %s", job.Status)
+	}
+	if err := runner.Cancel(id); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound when cancelling a cancelled job, got %v", err)
+	}
+}
+
+func TestRunnerResumesInterruptedJobs(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	id, err := database.CreateJob("resumable", "", 3, time.Now(), "")
+	if err != nil {
+		t.Fatalf("couldn't create job: %s", err)
+	}
+	// Simulate a process that stopped while the job was running.
+	if _, err := database.ClaimNextJob(); err != nil {
+		t.Fatalf("couldn't claim job: %s", err)
+	}
+
+	mustStartRunner(t, database, map[string]jobs.Handler{
+		"resumable": func(ctx context.Context, job *db.Job) error {
+			return nil
+		},
+	})
+	job := mustWaitForStatus(t, database, id, db.JobStatusSucceeded)
+	if job.Attempts != 2 {
+		t.Fatalf("expected the interrupted job to be run a second time, got %+v", job)
+	}
+}
+
+func TestRunnerPeriodicTasks(t *testing.T) {
+	database := tu.MustPrepareEmptyDB(t)
+	runner := jobs.NewRunner(database, zap.NewNop())
+	var runs atomic.Int64
+	runner.Every(10*time.Millisecond, func(context.Context) { runs.Add(1) })
+	if err := runner.Start(); err != nil {
+		t.Fatalf("couldn't start job runner: %s", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the task to run repeatedly, ran %d times", runs.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	runner.Stop()
+	stopped := runs.Load()
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Fatalf("expected the task to stop running with the runner")
+	}
+}
@@ -1,6 +1,10 @@
 package server
 
-import "github.com/canonical/notary/internal/db"
+import (
+	"slices"
+
+	"github.com/canonical/notary/internal/db"
+)
 
 // Role name constants used in OpenFGA tuples and checks against "system:notary".
 const (
@@ -42,3 +46,98 @@ func RoleIDToRelation(roleID db.RoleID) string {
 		return RoleNameReader
 	}
 }
+
+// API token scope constants. A scope grants an API token the routes of routeScopes that map to it,
+// within the limits of the role of its service account.
+const (
+	ScopeCertificateRequestsRead      = "certificate_requests:read"
+	ScopeCertificateRequestsSubmit    = "certificate_requests:submit"
+	ScopeCertificateRequestsManage    = "certificate_requests:manage"
+	ScopeCertificateAuthoritiesRead   = "certificate_authorities:read"
+	ScopeCertificateAuthoritiesManage = "certificate_authorities:manage"
+	ScopeACMERead                     = "acme:read"
+	ScopeACMEManage                   = "acme:manage"
+	ScopeACMEDNSRegister              = "acme_dns:register"
+	ScopeJobsRead                     = "jobs:read"
+	ScopeJobsManage                   = "jobs:manage"
+	ScopeTimestampsRead               = "timestamps:read"
+	ScopeConfigRead                   = "config:read"
+)
+
+// APITokenScopes are the scopes that can be given to an API token.
+var APITokenScopes = []string{
+	ScopeCertificateRequestsRead,
+	ScopeCertificateRequestsSubmit,
+	ScopeCertificateRequestsManage,
+	ScopeCertificateAuthoritiesRead,
+	ScopeCertificateAuthoritiesManage,
+	ScopeACMERead,
+	ScopeACMEManage,
+	ScopeACMEDNSRegister,
+	ScopeJobsRead,
+	ScopeJobsManage,
+	ScopeTimestampsRead,
+	ScopeConfigRead,
+}
+
+// routeScopes maps the route patterns that API tokens can call to the scope they require.
+// The routes that aren't listed, such as the management of accounts and API tokens, can't be called with an API token.
+var routeScopes = map[string]string{
+	"GET /certificate_requests":                          ScopeCertificateRequestsRead,
+	"GET /certificate_requests/{id}":                     ScopeCertificateRequestsRead,
+	"GET /certificate_requests/{id}/certificate":         ScopeCertificateRequestsRead,
+	"POST /certificate_requests":                         ScopeCertificateRequestsSubmit,
+	"POST /certificate_requests/generate":                ScopeCertificateRequestsSubmit,
+	"POST /certificate_requests/{id}/pkcs12":             ScopeCertificateRequestsSubmit,
+	"DELETE /certificate_requests/{id}":                  ScopeCertificateRequestsManage,
+	"POST /certificate_requests/{id}/reject":             ScopeCertificateRequestsManage,
+	"POST /certificate_requests/{id}/sign":               ScopeCertificateRequestsManage,
+	"POST /certificate_requests/{id}/certificate":        ScopeCertificateRequestsManage,
+	"DELETE /certificate_requests/{id}/certificate":      ScopeCertificateRequestsManage,
+	"POST /certificate_requests/{id}/certificate/revoke": ScopeCertificateRequestsManage,
+	"PUT /certificate_requests/{id}/renewal":             ScopeCertificateRequestsManage,
+
+	"GET /certificate_authorities":                   ScopeCertificateAuthoritiesRead,
+	"GET /certificate_authorities/{id}":              ScopeCertificateAuthoritiesRead,
+	"GET /certificate_authorities/{id}/chain":        ScopeCertificateAuthoritiesRead,
+	"POST /certificate_authorities":                  ScopeCertificateAuthoritiesManage,
+	"PUT /certificate_authorities/{id}":              ScopeCertificateAuthoritiesManage,
+	"DELETE /certificate_authorities/{id}":           ScopeCertificateAuthoritiesManage,
+	"POST /certificate_authorities/{id}/sign":        ScopeCertificateAuthoritiesManage,
+	"POST /certificate_authorities/{id}/certificate": ScopeCertificateAuthoritiesManage,
+	"POST /certificate_authorities/{id}/revoke":      ScopeCertificateAuthoritiesManage,
+	"PUT /certificate_authorities/{id}/renewal":      ScopeCertificateAuthoritiesManage,
+
+	"GET /acme_servers":                     ScopeACMERead,
+	"GET /acme_servers/{id}":                ScopeACMERead,
+	"GET /acme_accounts":                    ScopeACMERead,
+	"GET /acme_accounts/{id}":               ScopeACMERead,
+	"GET /acme_routes":                      ScopeACMERead,
+	"GET /acme_dns_registrations":           ScopeACMERead,
+	"GET /acme_dns_registrations/{id}":      ScopeACMERead,
+	"POST /acme_servers":                    ScopeACMEManage,
+	"PUT /acme_servers/{id}":                ScopeACMEManage,
+	"DELETE /acme_servers/{id}":             ScopeACMEManage,
+	"PUT /acme_servers/{id}/active":         ScopeACMEManage,
+	"POST /acme_servers/{id}/test":          ScopeACMEManage,
+	"PUT /acme_servers/{id}/account":        ScopeACMEManage,
+	"POST /acme_accounts/{id}/refresh":      ScopeACMEManage,
+	"PUT /acme_accounts/{id}/contacts":      ScopeACMEManage,
+	"POST /acme_accounts/{id}/key_rollover": ScopeACMEManage,
+	"POST /acme_accounts/{id}/deactivate":   ScopeACMEManage,
+	"PUT /acme_routes":                      ScopeACMEManage,
+	"DELETE /acme_dns_registrations/{id}":   ScopeACMEManage,
+	"POST /register":                        ScopeACMEDNSRegister,
+
+	"GET /jobs":              ScopeJobsRead,
+	"GET /jobs/{id}":         ScopeJobsRead,
+	"POST /jobs/{id}/cancel": ScopeJobsManage,
+
+	"GET /timestamps": ScopeTimestampsRead,
+	"GET /config":     ScopeConfigRead,
+}
+
+// IsValidAPITokenScope returns whether scope can be given to an API token.
+func IsValidAPITokenScope(scope string) bool {
+	return slices.Contains(APITokenScopes, scope)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/smallstep/pkcs7"
+	"go.uber.org/zap"
+)
+
+// certificateFormat is one of the encodings a certificate or a certificate chain can be downloaded in.
+type certificateFormat string
+
+const (
+	certificateFormatPEM   certificateFormat = "pem"
+	certificateFormatDER   certificateFormat = "der"
+	certificateFormatPKCS7 certificateFormat = "pkcs7"
+
+	// certificateFormatPKCS12 is only used to deliver keys generated by Notary, it can't be negotiated.
+	certificateFormatPKCS12 certificateFormat = "pkcs12"
+)
+
+var errUnsupportedCertificateFormat = errors.New("unsupported certificate format")
+
+// certificateFormatMediaTypes maps the media types accepted in the Accept header to a certificate format.
+var certificateFormatMediaTypes = map[string]certificateFormat{
+	"application/pem-certificate-chain": certificateFormatPEM,
+	"application/x-pem-file":            certificateFormatPEM,
+	"text/plain":                        certificateFormatPEM,
+	"application/pkix-cert":             certificateFormatDER,
+	"application/x-x509-ca-cert":        certificateFormatDER,
+	"application/pkcs7-mime":            certificateFormatPKCS7,
+	"application/x-pkcs7-certificates":  certificateFormatPKCS7,
+}
+
+// contentType returns the media type that is sent back for the format.
+func (format certificateFormat) contentType() string {
+	switch format {
+	case certificateFormatDER:
+		return "application/pkix-cert"
+	case certificateFormatPKCS7:
+		return "application/pkcs7-mime; smime-type=certs-only"
+	case certificateFormatPKCS12:
+		return "application/x-pkcs12"
+	default:
+		return "application/pem-certificate-chain"
+	}
+}
+
+// fileExtension returns the conventional file extension for the format.
+func (format certificateFormat) fileExtension() string {
+	switch format {
+	case certificateFormatDER:
+		return "der"
+	case certificateFormatPKCS7:
+		return "p7b"
+	case certificateFormatPKCS12:
+		return "p12"
+	default:
+		return "pem"
+	}
+}
+
+// negotiateCertificateFormat picks the format of a certificate download.
+// The "format" query parameter takes precedence over the Accept header.
+// When neither is given, or the client accepts anything, the chain is returned as PEM.
+func negotiateCertificateFormat(r *http.Request) (certificateFormat, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch certificateFormat(strings.ToLower(format)) {
+		case certificateFormatPEM:
+			return certificateFormatPEM, nil
+		case certificateFormatDER:
+			return certificateFormatDER, nil
+		case certificateFormatPKCS7, "p7b":
+			return certificateFormatPKCS7, nil
+		}
+		return "", fmt.Errorf("%w: %s", errUnsupportedCertificateFormat, format)
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return certificateFormatPEM, nil
+	}
+	if format, ok := acceptedCertificateFormat(accept); ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: %s", errUnsupportedCertificateFormat, accept)
+}
+
+// acceptedCertificateFormat returns the format of the media range of an Accept header with the highest quality,
+// the first one on ties. Media ranges with a quality of 0 are refused, and a wildcard stands for the first format
+// that isn't refused, in the order PEM, DER and PKCS#7.
+func acceptedCertificateFormat(accept string) (certificateFormat, bool) {
+	refused := map[certificateFormat]bool{}
+	var best certificateFormat
+	wildcard := false
+	bestQuality := 0.0
+	for mediaRange := range strings.SplitSeq(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		isWildcard := mediaType == "*/*" || mediaType == "application/*"
+		format, ok := certificateFormatMediaTypes[mediaType]
+		if !ok && !isWildcard {
+			continue
+		}
+		if quality == 0 {
+			if ok {
+				refused[format] = true
+			}
+			continue
+		}
+		if quality > bestQuality {
+			best, wildcard, bestQuality = format, isWildcard, quality
+		}
+	}
+	if bestQuality == 0 {
+		return "", false
+	}
+	if !wildcard {
+		return best, true
+	}
+	for _, format := range []certificateFormat{certificateFormatPEM, certificateFormatDER, certificateFormatPKCS7} {
+		if !refused[format] {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// includeRootParam reads the "include_root" query parameter, which defaults to true.
+func includeRootParam(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("include_root")
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// isSelfSigned reports whether the certificate is a root certificate.
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
+
+// encodeCertificateChain encodes a PEM certificate chain in the given format.
+// The DER format only carries the first certificate of the chain.
+// If includeRoot is false, a self-signed certificate at the end of the chain is left out,
+// unless it is the only certificate in the chain.
+func encodeCertificateChain(chainPEM string, format certificateFormat, includeRoot bool) ([]byte, error) {
+	certs, err := db.ParseCertificateChain(chainPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate chain: %w", err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("certificate chain is empty")
+	}
+	if !includeRoot && len(certs) > 1 && isSelfSigned(certs[len(certs)-1]) {
+		certs = certs[:len(certs)-1]
+	}
+
+	switch format {
+	case certificateFormatDER:
+		return certs[0].Raw, nil
+	case certificateFormatPKCS7:
+		var raw []byte
+		for _, cert := range certs {
+			raw = append(raw, cert.Raw...)
+		}
+		p7, err := pkcs7.DegenerateCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode PKCS#7 certificate chain: %w", err)
+		}
+		return p7, nil
+	default:
+		var buff bytes.Buffer
+		for _, cert := range certs {
+			if err := pem.Encode(&buff, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+				return nil, fmt.Errorf("failed to encode PEM certificate chain: %w", err)
+			}
+		}
+		return buff.Bytes(), nil
+	}
+}
+
+// writeCertificateDownload writes an encoded certificate file as an attachment.
+func writeCertificateDownload(w http.ResponseWriter, body []byte, format certificateFormat, filename string, logger *zap.Logger) {
+	w.Header().Set("Content-Type", format.contentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("%s.%s", filename, format.fileExtension())}))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		logger.Error("error writing certificate download", zap.Error(err))
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateCertificateFormat(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   certificateFormat
+	}{
+		{"", certificateFormatPEM},
+		{"application/pkix-cert", certificateFormatDER},
+		{"application/pkix-cert;q=0, application/x-pem-file", certificateFormatPEM},
+		{"application/pkix-cert;q=0.5, application/pkcs7-mime;q=0.8", certificateFormatPKCS7},
+		{"application/pkcs7-mime;q=0.8, application/pkix-cert;q=0.8", certificateFormatPKCS7},
+		{"text/html, application/pkix-cert;q=0.1", certificateFormatDER},
+		{"*/*", certificateFormatPEM},
+		{"*/*;q=0.1, application/pkix-cert", certificateFormatDER},
+		{"application/pkix-cert;q=0.1, */*", certificateFormatPEM},
+		{"application/x-pem-file;q=0, text/plain;q=0, */*", certificateFormatDER},
+		{"application/*;q=0.5, application/pkcs7-mime;q=0.9", certificateFormatPKCS7},
+		{"application/pkix-cert;q=invalid, application/pkcs7-mime", certificateFormatPKCS7},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/certificate", nil)
+		r.Header.Set("Accept", tc.accept)
+		got, err := negotiateCertificateFormat(r)
+		if err != nil {
+			t.Errorf("negotiateCertificateFormat(%q) unexpected error: %s", tc.accept, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("negotiateCertificateFormat(%q) = %s, want %s", tc.accept, got, tc.want)
+		}
+	}
+
+	for _, accept := range []string{"text/html", "application/pkix-cert;q=0", "*/*;q=0", "application/pkix-cert;q=0, */*;q=0"} {
+		r := httptest.NewRequest("GET", "/certificate", nil)
+		r.Header.Set("Accept", accept)
+		if _, err := negotiateCertificateFormat(r); !errors.Is(err, errUnsupportedCertificateFormat) {
+			t.Errorf("negotiateCertificateFormat(%q) expected errUnsupportedCertificateFormat, got %v", accept, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package server_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+// mustIssueClientCertificate submits a certificate request for subject as the requestor, has the admin sign it with the
+// certificate authority, and returns a client that presents the certificate.
+func mustIssueClientCertificate(t *testing.T, ts *httptest.Server, requestorToken, adminToken string, caID int64, subject pkix.Name, email string) (*http.Client, int) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	template := &x509.CertificateRequest{Subject: subject}
+	if email != "" {
+		template.EmailAddresses = []string{email}
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatalf("couldn't create CSR: %v", err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	statusCode, createResp, err := tu.CreateCertificateRequest(ts.URL, ts.Client(), requestorToken, tu.CreateCertificateRequestParams{CSR: csrPEM})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create certificate request: %d %v", statusCode, err)
+	}
+	csrID := createResp.Data.ID
+	statusCode, _, err = tu.SignCertificateRequest(ts.URL, ts.Client(), adminToken, csrID, server.SignCertificateRequestParams{CertificateAuthorityID: strconv.FormatInt(caID, 10)})
+	if err != nil || statusCode != http.StatusAccepted {
+		t.Fatalf("couldn't sign certificate request: %d %v", statusCode, err)
+	}
+	_, getResp, err := tu.GetCertificateRequest(ts.URL, ts.Client(), adminToken, csrID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(getResp.Data.CertificateChain))
+	if block == nil {
+		t.Fatalf("no certificate for certificate request %d", csrID)
+	}
+
+	transport := ts.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{block.Bytes}, PrivateKey: key}}
+	return &http.Client{Transport: transport}, csrID
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t, tu.WithClientCertificates(authentication.ClientCertificateIdentityEmail, 1))
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	machineToken := tu.MustPrepareAccount(t, ts, "machine@canonical.com", tu.RoleCertificateRequestor, adminToken)
+
+	for _, commonName := range []string{"trusted.example.com", "untrusted.example.com"} {
+		statusCode, resp, err := tu.CreateCertificateAuthority(ts.URL, ts.Client(), adminToken, tu.CreateCertificateAuthorityParams{SelfSigned: true, CommonName: commonName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+	}
+
+	machineClient, machineCSRID := mustIssueClientCertificate(t, ts, machineToken, adminToken, 1, pkix.Name{CommonName: "machine"}, "machine@canonical.com")
+
+	t.Run("trusted certificate", func(t *testing.T) {
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, machineClient, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK || resp.Data.Email != "machine@canonical.com" {
+			t.Fatalf("expected the account of the certificate, got %d %+v", statusCode, resp.Data)
+		}
+		statusCode, _, err = tu.GetAccount(ts.URL, machineClient, "", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected the role of the account to apply, got status %d", statusCode)
+		}
+	})
+
+	t.Run("untrusted certificates", func(t *testing.T) {
+		unknownClient, _ := mustIssueClientCertificate(t, ts, adminToken, adminToken, 1, pkix.Name{CommonName: "unknown"}, "unknown@canonical.com")
+		untrustedClient, _ := mustIssueClientCertificate(t, ts, machineToken, adminToken, 2, pkix.Name{CommonName: "machine"}, "machine@canonical.com")
+		for desc, client := range map[string]*http.Client{
+			"unknown account":  unknownClient,
+			"untrusted issuer": untrustedClient,
+			"no certificate":   ts.Client(),
+		} {
+			statusCode, _, err := tu.GetMyAccount(ts.URL, client, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusUnauthorized {
+				t.Fatalf("%s: expected status %d, got %d", desc, http.StatusUnauthorized, statusCode)
+			}
+		}
+	})
+
+	t.Run("certificate naming another account", func(t *testing.T) {
+		// The requestor has a certificate that names the admin signed, and presents it to act as the admin.
+		impersonatingClient, _ := mustIssueClientCertificate(t, ts, machineToken, adminToken, 1, pkix.Name{CommonName: "admin"}, "admin@canonical.com")
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, impersonatingClient, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the certificate to be rejected, got status %d %+v", statusCode, resp.Data)
+		}
+		rejected := false
+		for _, e := range logs.All() {
+			if strings.HasPrefix(findStringField(e, "event"), "authn_client_certificate_rejected:") && strings.Contains(findStringField(e, "reason"), "didn't request it") {
+				rejected = true
+			}
+		}
+		if !rejected {
+			t.Fatalf("expected an audit event for the certificate naming another account")
+		}
+	})
+
+	t.Run("revoked certificate", func(t *testing.T) {
+		statusCode, _, err := tu.RevokeCertificateRequest(ts.URL, ts.Client(), adminToken, machineCSRID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		statusCode, _, err = tu.GetMyAccount(ts.URL, machineClient, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the revoked certificate to be rejected, got status %d", statusCode)
+		}
+		rejected := false
+		for _, e := range logs.All() {
+			if strings.HasPrefix(findStringField(e, "event"), "authn_client_certificate_rejected:") && strings.Contains(findStringField(e, "reason"), "revoked") {
+				rejected = true
+			}
+		}
+		if !rejected {
+			t.Fatalf("expected an audit event for the revoked certificate")
+		}
+	})
+}
+
+func TestClientCertificateCommonNameIdentity(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithClientCertificates(authentication.ClientCertificateIdentityCommonName, 1))
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	statusCode, resp, err := tu.CreateServiceAccount(ts.URL, ts.Client(), adminToken, server.CreateServiceAccountParams{Name: "build-agent", RoleID: server.RoleCertificateRequestor})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create service account: %d %v", statusCode, err)
+	}
+	statusCode, tokenResp, err := tu.CreateAPIToken(ts.URL, ts.Client(), adminToken, int64(resp.Data.ID), server.CreateAPITokenParams{
+		Name:      "submit-csrs",
+		Scopes:    []string{server.ScopeCertificateRequestsSubmit},
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create API token: %d %v", statusCode, err)
+	}
+	statusCode, caResp, err := tu.CreateCertificateAuthority(ts.URL, ts.Client(), adminToken, tu.CreateCertificateAuthorityParams{SelfSigned: true, CommonName: "trusted.example.com"})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create certificate authority: %d %v", statusCode, err)
+	}
+	client, _ := mustIssueClientCertificate(t, ts, tokenResp.Data.Token, adminToken, int64(caResp.Data.ID), pkix.Name{CommonName: "build-agent"}, "")
+
+	statusCode, account, err := tu.GetMyAccount(ts.URL, client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusOK || int64(account.Data.ID) != int64(resp.Data.ID) {
+		t.Fatalf("expected the service account of the certificate, got %d %+v", statusCode, account.Data)
+	}
+}
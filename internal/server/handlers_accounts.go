@@ -73,6 +73,8 @@ type GetAccountResponse struct {
 	HasOIDC     bool     `json:"has_oidc"`
 	OIDCSubject *string  `json:"oidc_subject,omitempty"`
 	AuthMethods []string `json:"auth_methods"`
+	// ServiceAccount is true for the accounts of automation, which authenticate with API tokens.
+	ServiceAccount bool `json:"service_account"`
 }
 
 // userToAccountResponse converts a db.User to GetAccountResponse
@@ -84,6 +86,12 @@ func userToAccountResponse(user *db.User) GetAccountResponse {
 	if user.HasOIDC() {
 		authMethods = append(authMethods, "oidc")
 	}
+	if user.HasLDAP() {
+		authMethods = append(authMethods, "ldap")
+	}
+	if user.ServiceAccount {
+		authMethods = append(authMethods, "api_token")
+	}
 
 	return GetAccountResponse{
 		ID:          user.ID,
@@ -93,6 +101,8 @@ func userToAccountResponse(user *db.User) GetAccountResponse {
 		HasOIDC:     user.HasOIDC(),
 		OIDCSubject: user.OIDCSubject,
 		AuthMethods: authMethods,
+
+		ServiceAccount: user.ServiceAccount,
 	}
 }
 
@@ -145,7 +155,7 @@ func GetAccount(env *HandlerDependencies) http.HandlerFunc {
 		var account *db.User
 		var err error
 		if id == "me" {
-			claims, jwtErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+			claims, jwtErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 			if jwtErr != nil {
 				env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(jwtErr))
 				writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -182,7 +192,7 @@ func GetAccount(env *HandlerDependencies) http.HandlerFunc {
 // it uses the JWT claims to retrieve the account information.
 func GetMyAccount(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		claims, jwtErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, jwtErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if jwtErr != nil {
 			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(jwtErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -248,7 +258,7 @@ func CreateAccount(env *HandlerDependencies) http.HandlerFunc {
 		}
 
 		var actor string
-		claims, claimsErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, claimsErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if claimsErr == nil {
 			actor = claims.Email
 		}
@@ -283,18 +293,21 @@ func DeleteAccount(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
-		numUsers, err := env.Database.NumUsers()
-		if err != nil {
-			env.SystemLogger.Error("failed to count users", zap.Error(err))
-			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
-			return
-		}
-		if numUsers <= 1 && env.AuthnRepository == nil {
-			writeResponse(w, http.StatusBadRequest, "cannot delete the last user account when OIDC is not enabled", nil, env.SystemLogger)
-			return
+		// Service accounts can't log in, so they don't count towards the last user account.
+		if !account.ServiceAccount {
+			numUsers, err := env.Database.NumUsers()
+			if err != nil {
+				env.SystemLogger.Error("failed to count users", zap.Error(err))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+			if numUsers <= 1 && env.AuthnRepository == nil {
+				writeResponse(w, http.StatusBadRequest, "cannot delete the last user account when OIDC is not enabled", nil, env.SystemLogger)
+				return
+			}
 		}
 
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if err != nil {
 			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -350,8 +363,12 @@ func ChangeAccountPassword(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
+		if targetAccount.ServiceAccount {
+			writeResponse(w, http.StatusBadRequest, "service accounts authenticate with API tokens and can't have a password", nil, env.SystemLogger)
+			return
+		}
 
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if err != nil {
 			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -394,6 +411,12 @@ func ChangeAccountPassword(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
+		if err := revokeSessions(r, env, targetAccount, "password changed"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions after password change", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
 		env.AuditLogger.PasswordChanged(targetAccount.Email,
 			log.WithActor(claims.Email),
 			log.WithRequest(r),
@@ -410,7 +433,7 @@ func ChangeAccountPassword(env *HandlerDependencies) http.HandlerFunc {
 func ChangeMyPassword(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var idNum int64
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if err != nil {
 			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -456,6 +479,12 @@ func ChangeMyPassword(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
+		if err := revokeSessions(r, env, account, "password changed"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions after password change", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
 		env.AuditLogger.PasswordChanged(account.Email, log.WithRequest(r))
 		env.AuditLogger.UserUpdated(account.Email, "password_change", log.WithRequest(r))
 
@@ -466,7 +495,7 @@ func ChangeMyPassword(env *HandlerDependencies) http.HandlerFunc {
 // UpdateAccountRole updates an existing account's role.
 func UpdateAccountRole(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if err != nil {
 			writeResponse(w, http.StatusUnauthorized, "Unauthorized", err, env.SystemLogger)
 			return
@@ -504,15 +533,22 @@ func UpdateAccountRole(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		if err := env.Database.UpdateUserRole(db.ByUserID(idNum), db.RoleID(params.RoleID)); err != nil {
+		if err := updateRole(env, account, db.RoleID(params.RoleID)); err != nil {
 			if errors.Is(err, db.ErrNotFound) {
 				writeResponse(w, http.StatusNotFound, "Not Found", err, env.SystemLogger)
 				return
 			}
+			env.SystemLogger.Error("failed to update account role", zap.Error(err))
 			writeResponse(w, http.StatusInternalServerError, "Internal Error", err, env.SystemLogger)
 			return
 		}
 
+		if err := revokeAllSessions(r, env, account, "role changed"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions after role change", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
 		env.AuditLogger.UserUpdated(account.Email, "role_change",
 			log.WithActor(claims.Email),
 			log.WithRequest(r),
@@ -520,3 +556,31 @@ func UpdateAccountRole(env *HandlerDependencies) http.HandlerFunc {
 		writeResponse(w, http.StatusCreated, "", nil, env.SystemLogger)
 	}
 }
+
+// updateRoleTuple replaces the OpenFGA tuple of the role of the account with email, when authorization is backed by OpenFGA.
+// It is called before the role is stored, so that a failure leaves both unchanged and can be retried.
+func updateRoleTuple(env *HandlerDependencies, email string, from, to db.RoleID) error {
+	if env.AuthzRepository == nil || from == to {
+		return nil
+	}
+	userID := authorization.UserID(email)
+	if err := env.AuthzRepository.ReplaceTuple("system:notary", RoleIDToRelation(from), RoleIDToRelation(to), userID); err != nil {
+		return fmt.Errorf("failed to replace role tuple of %s from %s to %s: %w", userID, RoleIDToRelation(from), RoleIDToRelation(to), err)
+	}
+	return nil
+}
+
+// updateRole changes the role of the account in the database and in OpenFGA. The tuple is restored when the role
+// can't be stored, so that they stay consistent.
+func updateRole(env *HandlerDependencies, account *db.User, role db.RoleID) error {
+	if err := updateRoleTuple(env, account.Email, account.RoleID, role); err != nil {
+		return err
+	}
+	if err := env.Database.UpdateUserRole(db.ByUserID(account.ID), role); err != nil {
+		if restoreErr := updateRoleTuple(env, account.Email, role, account.RoleID); restoreErr != nil {
+			env.SystemLogger.Error("failed to restore role tuple", zap.Error(restoreErr), zap.Int64("user_id", account.ID))
+		}
+		return err
+	}
+	return nil
+}
@@ -293,7 +293,9 @@ func TestAccountsEndToEnd(t *testing.T) {
 	})
 
 	t.Run("12. Update account role - forbidden for non-admin", func(t *testing.T) {
-		statusCode, response, err := tu.UpdateAccountRole(ts.URL, client, nonAdminToken, 4, &tu.UpdateAccountRoleParams{RoleID: tu.RoleCertificateManager})
+		// The session of whatever@canonical.com ended with the deletion of its account.
+		managerToken := tu.MustPrepareAccount(t, ts, "manager@canonical.com", tu.RoleCertificateManager, adminToken)
+		statusCode, response, err := tu.UpdateAccountRole(ts.URL, client, managerToken, 4, &tu.UpdateAccountRoleParams{RoleID: tu.RoleCertificateManager})
 		if err != nil {
 			t.Fatalf("couldn't update account role: %s", err)
 		}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	notaryacme "github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type ACMEAccountResponse struct {
+	ID              int64    `json:"id"`
+	Email           string   `json:"email"`
+	DirectoryURL    string   `json:"directory_url"`
+	RegistrationURI string   `json:"registration_uri"`
+	Status          string   `json:"status"`
+	Contacts        []string `json:"contacts"`
+}
+
+type UpdateACMEAccountContactsParams struct {
+	Emails []string `json:"emails"`
+}
+
+type LinkACMEServerAccountParams struct {
+	ACMEAccountID int64 `json:"acme_account_id"`
+}
+
+func dbACMEAccountToResponse(a *db.ACMEAccount) ACMEAccountResponse {
+	return ACMEAccountResponse{
+		ID:              a.ID,
+		Email:           a.Email,
+		DirectoryURL:    a.DirectoryURL,
+		RegistrationURI: a.RegistrationURI,
+		Status:          a.Status,
+		Contacts:        notaryacme.AccountContacts(a),
+	}
+}
+
+// ListACMEAccounts returns the accounts registered with ACME servers, with their status as last seen.
+func ListACMEAccounts(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accounts, err := env.Database.ListACMEAccounts()
+		if err != nil {
+			env.SystemLogger.Error("failed to list ACME accounts", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		resp := make([]ACMEAccountResponse, 0, len(accounts))
+		for i := range accounts {
+			resp = append(resp, dbACMEAccountToResponse(&accounts[i]))
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
+
+func GetACMEAccount(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := acmeAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMEAccountToResponse(account), env.SystemLogger)
+	}
+}
+
+// RefreshACMEAccount fetches the registration of the account from its ACME server, which may have
+// revoked or deactivated it, and stores it.
+func RefreshACMEAccount(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := acmeAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		updated, err := notaryacme.QueryAccount(account)
+		if err != nil {
+			env.SystemLogger.Warn("failed to query ACME account", zap.Error(err), zap.Int64("id", account.ID))
+			writeResponse(w, http.StatusBadGateway, "the ACME server failed to return the account", nil, env.SystemLogger)
+			return
+		}
+		storeACMEAccountRegistration(w, env, updated)
+	}
+}
+
+// UpdateACMEAccountContacts replaces the contact emails of the account on its ACME server.
+func UpdateACMEAccountContacts(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params UpdateACMEAccountContactsParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
+			return
+		}
+		if len(params.Emails) == 0 {
+			writeResponse(w, http.StatusBadRequest, "emails is required", nil, env.SystemLogger)
+			return
+		}
+		for _, email := range params.Emails {
+			if !validateEmail(email) {
+				writeResponse(w, http.StatusBadRequest, "invalid email format", nil, env.SystemLogger)
+				return
+			}
+		}
+		account, ok := validACMEAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		updated, err := notaryacme.UpdateAccountContacts(account, params.Emails)
+		if err != nil {
+			env.SystemLogger.Warn("failed to update ACME account contacts", zap.Error(err), zap.Int64("id", account.ID))
+			writeResponse(w, http.StatusBadGateway, "the ACME server failed to update the account contacts", nil, env.SystemLogger)
+			return
+		}
+		storeACMEAccountRegistration(w, env, updated)
+	}
+}
+
+// RollOverACMEAccountKey replaces the key of the account with a new one on its ACME server.
+// The new key is stored as pending before the ACME server is asked to change the key, and replaces
+// the current key once the server has accepted it.
+func RollOverACMEAccountKey(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := validACMEAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		updated, err := notaryacme.RollOverAccountKey(env.Database, account.ID)
+		if err != nil {
+			if errors.Is(err, db.ErrInternal) {
+				env.SystemLogger.Error("failed to store rolled over ACME account key", zap.Error(err), zap.Int64("id", account.ID))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Warn("failed to roll over ACME account key", zap.Error(err), zap.Int64("id", account.ID))
+			writeResponse(w, http.StatusBadGateway, "the ACME server failed to roll over the account key", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMEAccountToResponse(updated), env.SystemLogger)
+	}
+}
+
+// DeactivateACMEAccount deactivates the account on its ACME server. The ACME servers that used the account
+// register a new one on their next order.
+func DeactivateACMEAccount(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := validACMEAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		updated, err := notaryacme.DeactivateAccount(account)
+		if err != nil {
+			env.SystemLogger.Warn("failed to deactivate ACME account", zap.Error(err), zap.Int64("id", account.ID))
+			writeResponse(w, http.StatusBadGateway, "the ACME server failed to deactivate the account", nil, env.SystemLogger)
+			return
+		}
+		storeACMEAccountRegistration(w, env, updated)
+	}
+}
+
+// LinkACMEServerAccount links an ACME server to an account registered with its directory URL,
+// which the server then uses for its orders instead of the account registered with its email.
+func LinkACMEServerAccount(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid id", nil, env.SystemLogger)
+			return
+		}
+		var params LinkACMEServerAccountParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
+			return
+		}
+		if params.ACMEAccountID <= 0 {
+			writeResponse(w, http.StatusBadRequest, "acme_account_id is required", nil, env.SystemLogger)
+			return
+		}
+		server, err := env.Database.GetACMEServer(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get ACME server", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		account, err := env.Database.GetDecryptedACMEAccount(params.ACMEAccountID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusUnprocessableEntity, "ACME account not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get ACME account", zap.Error(err), zap.Int64("id", params.ACMEAccountID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if account.DirectoryURL != server.DirectoryURL {
+			writeResponse(w, http.StatusUnprocessableEntity, "the ACME account is registered with another directory URL", nil, env.SystemLogger)
+			return
+		}
+		if account.Status != db.ACMEAccountStatusValid {
+			writeResponse(w, http.StatusUnprocessableEntity, "the ACME account is "+account.Status, nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.LinkAccountToServer(id, account.ID); err != nil {
+			env.SystemLogger.Error("failed to link ACME account to server", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		linked, err := env.Database.GetDecryptedACMEServer(id)
+		if err != nil {
+			env.SystemLogger.Error("failed to retrieve linked ACME server", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMEServerToResponse(linked), env.SystemLogger)
+	}
+}
+
+// acmeAccountFromPath returns the decrypted account whose ID is in the path, or writes the error response.
+func acmeAccountFromPath(w http.ResponseWriter, r *http.Request, env *HandlerDependencies) (*db.ACMEAccount, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, "invalid id", nil, env.SystemLogger)
+		return nil, false
+	}
+	account, err := env.Database.GetDecryptedACMEAccount(id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return nil, false
+		}
+		env.SystemLogger.Error("failed to get ACME account", zap.Error(err), zap.Int64("id", id))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+	return account, true
+}
+
+// validACMEAccountFromPath is acmeAccountFromPath for the operations that ACME servers only accept from valid accounts.
+func validACMEAccountFromPath(w http.ResponseWriter, r *http.Request, env *HandlerDependencies) (*db.ACMEAccount, bool) {
+	account, ok := acmeAccountFromPath(w, r, env)
+	if !ok {
+		return nil, false
+	}
+	if account.Status != db.ACMEAccountStatusValid {
+		writeResponse(w, http.StatusConflict, "the ACME account is "+account.Status, nil, env.SystemLogger)
+		return nil, false
+	}
+	return account, true
+}
+
+// storeACMEAccountRegistration stores the registration of an account returned by its ACME server and writes the account.
+func storeACMEAccountRegistration(w http.ResponseWriter, env *HandlerDependencies, account *db.ACMEAccount) {
+	if err := env.Database.UpdateACMEAccountRegistration(account.ID, account.RegistrationBody, account.Status); err != nil {
+		env.SystemLogger.Error("failed to store ACME account registration", zap.Error(err), zap.Int64("id", account.ID))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	writeResponse(w, http.StatusOK, "", dbACMEAccountToResponse(account), env.SystemLogger)
+}
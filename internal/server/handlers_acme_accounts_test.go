@@ -0,0 +1,219 @@
+package server_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestACMEAccountsEndToEnd(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	client := ts.Client()
+
+	newPebbleServer := func(email string) int64 {
+		t.Helper()
+		statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+			Name:          "pebble",
+			DirectoryURL:  directoryURL,
+			Email:         email,
+			ChallengeType: db.ACMEChallengeHTTP01,
+			ChallengePort: int64(httpPort),
+			EnvVars:       map[string]string{},
+		})
+		if err != nil {
+			t.Fatalf("CreateACMEServer() error: %v", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+		}
+		return created.Data.ID
+	}
+	serverID := newPebbleServer("ops@example.com")
+	if statusCode, _, err := tu.SetActiveACMEServer(ts.URL, client, adminToken, int(serverID)); err != nil || statusCode != http.StatusOK {
+		t.Fatalf("SetActiveACMEServer() failed with status %d: %v", statusCode, err)
+	}
+	// Notary compares the keys of CSRs and certificates as RSA keys.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "localhost"},
+		DNSNames: []string{"localhost"},
+	}, key)
+	if err != nil {
+		t.Fatalf("couldn't create CSR: %v", err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	if statusCode, _, err := tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: csrPEM}); err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("CreateCertificateRequest() failed with status %d: %v", statusCode, err)
+	}
+	statusCode, _, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+	if err != nil || statusCode != http.StatusAccepted {
+		t.Fatalf("SignCertificateRequest() failed with status %d: %v", statusCode, err)
+	}
+	mustWaitForRenewal(t, ts.URL, client, adminToken, 1, "")
+
+	var accountID int64
+	t.Run("list accounts", func(t *testing.T) {
+		statusCode, resp, err := tu.ListACMEAccounts(ts.URL, client, readerToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 1 {
+			t.Fatalf("expected the account registered by the order, got %+v", resp.Data)
+		}
+		account := resp.Data[0]
+		if account.Status != db.ACMEAccountStatusValid || account.DirectoryURL != directoryURL || len(account.Contacts) != 1 || account.Contacts[0] != "ops@example.com" {
+			t.Fatalf("unexpected account %+v", account)
+		}
+		accountID = account.ID
+		_, acmeServer, err := tu.GetACMEServer(ts.URL, client, adminToken, int(serverID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acmeServer.Data.ACMEAccountID == nil || *acmeServer.Data.ACMEAccountID != accountID {
+			t.Fatalf("expected the server to be linked to account %d, got %v", accountID, acmeServer.Data.ACMEAccountID)
+		}
+	})
+
+	t.Run("readers can't change accounts", func(t *testing.T) {
+		statusCode, _, err := tu.DeactivateACMEAccount(ts.URL, client, readerToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("update contacts", func(t *testing.T) {
+		statusCode, _, err := tu.UpdateACMEAccountContacts(ts.URL, client, adminToken, accountID, []string{"not an email"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		statusCode, resp, err := tu.UpdateACMEAccountContacts(ts.URL, client, adminToken, accountID, []string{"pki@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		_, resp, err = tu.RefreshACMEAccount(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data.Contacts) != 1 || resp.Data.Contacts[0] != "pki@example.com" {
+			t.Fatalf("expected the ACME server to return the updated contacts, got %v", resp.Data.Contacts)
+		}
+	})
+
+	t.Run("roll over key", func(t *testing.T) {
+		statusCode, resp, err := tu.RollOverACMEAccountKey(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		statusCode, resp, err = tu.RefreshACMEAccount(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected the account to be usable with its new key, got status %d: %s", statusCode, resp.Message)
+		}
+	})
+
+	t.Run("link a server to an account", func(t *testing.T) {
+		otherServerID := newPebbleServer("other@example.com")
+		statusCode, resp, err := tu.LinkACMEServerAccount(ts.URL, client, adminToken, otherServerID, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		if resp.Data.ACMEAccountID == nil || *resp.Data.ACMEAccountID != accountID {
+			t.Fatalf("expected the server to be linked to account %d, got %v", accountID, resp.Data.ACMEAccountID)
+		}
+
+		statusCode, _, err = tu.LinkACMEServerAccount(ts.URL, client, adminToken, otherServerID, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d for an unknown account, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+		elsewhereID := mustCreateACMEServer(t, ts.URL, client, adminToken, "elsewhere")
+		statusCode, resp, err = tu.LinkACMEServerAccount(ts.URL, client, adminToken, elsewhereID, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d for an account of another directory, got %d: %s", http.StatusUnprocessableEntity, statusCode, resp.Message)
+		}
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		statusCode, resp, err := tu.DeactivateACMEAccount(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		if resp.Data.Status != db.ACMEAccountStatusDeactivated {
+			t.Fatalf("expected a deactivated account, got %q", resp.Data.Status)
+		}
+		_, acmeServer, err := tu.GetACMEServer(ts.URL, client, adminToken, int(serverID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acmeServer.Data.ACMEAccountID != nil {
+			t.Fatalf("expected the server to be unlinked from the deactivated account, got %d", *acmeServer.Data.ACMEAccountID)
+		}
+		statusCode, _, err = tu.DeactivateACMEAccount(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+		statusCode, _, err = tu.LinkACMEServerAccount(ts.URL, client, adminToken, serverID, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+	})
+
+	t.Run("unknown account", func(t *testing.T) {
+		statusCode, _, err := tu.GetACMEAccount(ts.URL, client, readerToken, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
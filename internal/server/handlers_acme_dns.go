@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/notary/internal/acmedns"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+// The acme-dns API headers that carry the credentials of an update.
+const (
+	acmeDNSUserHeader = "X-Api-User"
+	acmeDNSKeyHeader  = "X-Api-Key"
+)
+
+// acmeDNSMalformedJSON is the acme-dns error code of a request body that is not valid JSON.
+const acmeDNSMalformedJSON = "malformed_json"
+
+type RegisterACMEDNSParams struct {
+	AllowFrom []string `json:"allowfrom"`
+	Domain    string   `json:"domain"`
+}
+
+type RegisterACMEDNSResponse struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password"`
+	FullDomain string   `json:"fulldomain"`
+	Subdomain  string   `json:"subdomain"`
+	AllowFrom  []string `json:"allowfrom"`
+}
+
+type UpdateACMEDNSParams struct {
+	Subdomain string `json:"subdomain"`
+	TXT       string `json:"txt"`
+}
+
+type UpdateACMEDNSResponse struct {
+	TXT string `json:"txt"`
+}
+
+type ACMEDNSErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type ACMEDNSRegistrationResponse struct {
+	ID         int64    `json:"id"`
+	Username   string   `json:"username"`
+	Subdomain  string   `json:"subdomain"`
+	FullDomain string   `json:"fulldomain"`
+	AllowFrom  []string `json:"allowfrom"`
+	Domain     string   `json:"domain"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  int64    `json:"created_at"`
+	UpdatedAt  int64    `json:"updated_at"`
+}
+
+func dbACMEDNSRegistrationToResponse(env *HandlerDependencies, r *db.ACMEDNSRegistration) ACMEDNSRegistrationResponse {
+	allowFrom := []string{}
+	if r.AllowFrom != "" {
+		allowFrom = strings.Split(r.AllowFrom, ",")
+	}
+	return ACMEDNSRegistrationResponse{
+		ID:         r.ID,
+		Username:   r.Username,
+		Subdomain:  r.Subdomain,
+		FullDomain: env.ACMEDNSRepository.FullDomain(r.Subdomain),
+		AllowFrom:  allowFrom,
+		Domain:     r.Domain,
+		CreatedBy:  r.CreatedBy,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
+
+// RegisterACMEDNS handler is the register endpoint of the acme-dns API. Unlike acme-dns, it requires a Notary user.
+// The responses follow acme-dns rather than the Notary API, so that acme-dns clients can use them.
+// It returns a 201 Created with the credentials of the new account on success.
+func RegisterACMEDNS(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params RegisterACMEDNSParams
+		// acme-dns clients may register with an empty body.
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeACMEDNSResponse(w, http.StatusBadRequest, ACMEDNSErrorResponse{Error: acmeDNSMalformedJSON}, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			writeACMEDNSResponse(w, http.StatusUnauthorized, ACMEDNSErrorResponse{Error: acmedns.ErrForbidden.Error()}, env.SystemLogger)
+			return
+		}
+		registration, err := env.ACMEDNSRepository.Register(params.AllowFrom, params.Domain, claims.Email)
+		if err != nil {
+			switch {
+			case errors.Is(err, acmedns.ErrInvalidAllowFrom), errors.Is(err, acmedns.ErrInvalidDomain):
+				writeACMEDNSResponse(w, http.StatusBadRequest, ACMEDNSErrorResponse{Error: err.Error()}, env.SystemLogger)
+			case errors.Is(err, acmedns.ErrDomainAlreadyTaken):
+				writeACMEDNSResponse(w, http.StatusConflict, ACMEDNSErrorResponse{Error: err.Error()}, env.SystemLogger)
+			default:
+				env.SystemLogger.Error("failed to register acme-dns account", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		env.SystemLogger.Info("registered acme-dns account",
+			zap.String("subdomain", registration.Subdomain),
+			zap.String("domain", registration.Domain),
+			zap.String("created_by", claims.Email),
+		)
+		writeACMEDNSResponse(w, http.StatusCreated, RegisterACMEDNSResponse{
+			Username:   registration.Username,
+			Password:   registration.Password,
+			FullDomain: registration.FullDomain,
+			Subdomain:  registration.Subdomain,
+			AllowFrom:  registration.AllowFrom,
+		}, env.SystemLogger)
+	}
+}
+
+// UpdateACMEDNS handler is the update endpoint of the acme-dns API: it sets the TXT record of an account,
+// authenticated by the X-Api-User and X-Api-Key headers.
+// It returns a 200 OK with the new record on success.
+func UpdateACMEDNS(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params UpdateACMEDNSParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeACMEDNSResponse(w, http.StatusBadRequest, ACMEDNSErrorResponse{Error: acmeDNSMalformedJSON}, env.SystemLogger)
+			return
+		}
+		remote, err := remoteAddr(r)
+		if err != nil {
+			env.SystemLogger.Warn("failed to parse the remote address of an acme-dns update", zap.Error(err))
+			writeACMEDNSResponse(w, http.StatusUnauthorized, ACMEDNSErrorResponse{Error: acmedns.ErrForbidden.Error()}, env.SystemLogger)
+			return
+		}
+		err = env.ACMEDNSRepository.Update(r.Header.Get(acmeDNSUserHeader), r.Header.Get(acmeDNSKeyHeader), params.Subdomain, params.TXT, remote)
+		if err != nil {
+			switch {
+			case errors.Is(err, acmedns.ErrForbidden):
+				writeACMEDNSResponse(w, http.StatusUnauthorized, ACMEDNSErrorResponse{Error: err.Error()}, env.SystemLogger)
+			case errors.Is(err, acmedns.ErrBadSubdomain), errors.Is(err, acmedns.ErrBadTXT):
+				writeACMEDNSResponse(w, http.StatusBadRequest, ACMEDNSErrorResponse{Error: err.Error()}, env.SystemLogger)
+			default:
+				env.SystemLogger.Error("failed to update acme-dns record", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		writeACMEDNSResponse(w, http.StatusOK, UpdateACMEDNSResponse{TXT: params.TXT}, env.SystemLogger)
+	}
+}
+
+// ListACMEDNSRegistrations handler returns the accounts of the acme-dns compatible service, without their password.
+func ListACMEDNSRegistrations(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registrations, err := env.Database.ListACMEDNSRegistrations()
+		if err != nil {
+			env.SystemLogger.Error("failed to list acme-dns registrations", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		resp := make([]ACMEDNSRegistrationResponse, 0, len(registrations))
+		for i := range registrations {
+			resp = append(resp, dbACMEDNSRegistrationToResponse(env, &registrations[i]))
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
+
+func GetACMEDNSRegistration(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid id", nil, env.SystemLogger)
+			return
+		}
+		registration, err := env.Database.GetACMEDNSRegistration(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get acme-dns registration", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMEDNSRegistrationToResponse(env, registration), env.SystemLogger)
+	}
+}
+
+// DeleteACMEDNSRegistration handler deletes an account of the acme-dns compatible service. Its record stops being served,
+// so the CNAMEs that point to it no longer validate challenges.
+func DeleteACMEDNSRegistration(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid id", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.DeleteACMEDNSRegistration(id); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to delete acme-dns registration", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeACMEDNSResponse writes a response of the acme-dns API, which isn't wrapped like the Notary API responses.
+// The response isn't logged, as it may hold the password of an account.
+func writeACMEDNSResponse(w http.ResponseWriter, status int, resp any, logger *zap.Logger) {
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error("error marshalling response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(respBytes); err != nil {
+		logger.Error("error writing response", zap.Error(err))
+	}
+}
+
+// remoteAddr returns the address of the client of the request.
+func remoteAddr(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.ParseAddr(host)
+}
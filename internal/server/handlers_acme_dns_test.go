@@ -0,0 +1,221 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+const acmeDNSTXT = "LHDhK3oGRvkiefQnx7OOczTY5Tic_xZ6HcMOc_gmtoM"
+
+func TestACMEDNSEndToEnd(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithACMEDNS("acme-dns.example.com"))
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	client := ts.Client()
+
+	var registration server.RegisterACMEDNSResponse
+	t.Run("register", func(t *testing.T) {
+		statusCode, body, err := tu.RegisterACMEDNS(ts.URL, client, adminToken, server.RegisterACMEDNSParams{
+			AllowFrom: []string{"127.0.0.1/8"},
+			Domain:    "app.example.com",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, body)
+		}
+		if err := json.Unmarshal(body, &registration); err != nil {
+			t.Fatal(err)
+		}
+		if registration.Username == "" || registration.Password == "" || registration.FullDomain != registration.Subdomain+".acme-dns.example.com" {
+			t.Fatalf("unexpected registration %+v", registration)
+		}
+		if len(registration.AllowFrom) != 1 || registration.AllowFrom[0] != "127.0.0.0/8" {
+			t.Fatalf("expected the normalized network, got %v", registration.AllowFrom)
+		}
+	})
+
+	t.Run("invalid registrations", func(t *testing.T) {
+		cases := []struct {
+			desc       string
+			token      string
+			params     server.RegisterACMEDNSParams
+			statusCode int
+			error      string
+		}{
+			{"readers can't register", readerToken, server.RegisterACMEDNSParams{}, http.StatusForbidden, ""},
+			{"invalid network", adminToken, server.RegisterACMEDNSParams{AllowFrom: []string{"localhost"}}, http.StatusBadRequest, "invalid_allowfrom_cidr"},
+			{"invalid domain", adminToken, server.RegisterACMEDNSParams{Domain: "*.example.com"}, http.StatusBadRequest, "invalid_domain"},
+			{"domain already registered", adminToken, server.RegisterACMEDNSParams{Domain: "APP.example.com."}, http.StatusConflict, "domain_already_registered"},
+		}
+		for _, tc := range cases {
+			statusCode, body, err := tu.RegisterACMEDNS(ts.URL, client, tc.token, tc.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != tc.statusCode {
+				t.Fatalf("%s: expected status %d, got %d: %s", tc.desc, tc.statusCode, statusCode, body)
+			}
+			if tc.error == "" {
+				continue
+			}
+			var resp server.ACMEDNSErrorResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error != tc.error {
+				t.Fatalf("%s: expected error %q, got %q", tc.desc, tc.error, resp.Error)
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		cases := []struct {
+			desc       string
+			password   string
+			params     server.UpdateACMEDNSParams
+			statusCode int
+			error      string
+		}{
+			{"wrong password", "wrong", server.UpdateACMEDNSParams{Subdomain: registration.Subdomain, TXT: acmeDNSTXT}, http.StatusUnauthorized, "forbidden"},
+			{"bad subdomain", registration.Password, server.UpdateACMEDNSParams{Subdomain: "app", TXT: acmeDNSTXT}, http.StatusBadRequest, "bad_subdomain"},
+			{"bad TXT", registration.Password, server.UpdateACMEDNSParams{Subdomain: registration.Subdomain, TXT: "short"}, http.StatusBadRequest, "bad_txt"},
+			{"valid update", registration.Password, server.UpdateACMEDNSParams{Subdomain: registration.Subdomain, TXT: acmeDNSTXT}, http.StatusOK, ""},
+		}
+		for _, tc := range cases {
+			statusCode, body, err := tu.UpdateACMEDNS(ts.URL, client, registration.Username, tc.password, tc.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != tc.statusCode {
+				t.Fatalf("%s: expected status %d, got %d: %s", tc.desc, tc.statusCode, statusCode, body)
+			}
+			if tc.error != "" {
+				var resp server.ACMEDNSErrorResponse
+				if err := json.Unmarshal(body, &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Error != tc.error {
+					t.Fatalf("%s: expected error %q, got %q", tc.desc, tc.error, resp.Error)
+				}
+				continue
+			}
+			var resp server.UpdateACMEDNSResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.TXT != acmeDNSTXT {
+				t.Fatalf("expected the TXT record in the response, got %q", resp.TXT)
+			}
+		}
+	})
+
+	t.Run("updates from other networks are forbidden", func(t *testing.T) {
+		statusCode, body, err := tu.RegisterACMEDNS(ts.URL, client, adminToken, server.RegisterACMEDNSParams{AllowFrom: []string{"192.0.2.0/24"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var other server.RegisterACMEDNSResponse
+		if statusCode != http.StatusCreated || json.Unmarshal(body, &other) != nil {
+			t.Fatalf("failed to register: %d %s", statusCode, body)
+		}
+		statusCode, _, err = tu.UpdateACMEDNS(ts.URL, client, other.Username, other.Password, server.UpdateACMEDNSParams{Subdomain: other.Subdomain, TXT: acmeDNSTXT})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+	})
+
+	var registrationID int64
+	t.Run("list registrations", func(t *testing.T) {
+		statusCode, resp, err := tu.ListACMEDNSRegistrations(ts.URL, client, readerToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 2 {
+			t.Fatalf("expected 2 registrations, got %+v", resp.Data)
+		}
+		first := resp.Data[0]
+		if first.Subdomain != registration.Subdomain || first.Domain != "app.example.com" || first.CreatedBy != "admin@canonical.com" || first.FullDomain != registration.FullDomain {
+			t.Fatalf("unexpected registration %+v", first)
+		}
+		registrationID = first.ID
+	})
+
+	t.Run("delete registration", func(t *testing.T) {
+		statusCode, err := tu.DeleteACMEDNSRegistration(ts.URL, client, readerToken, registrationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, err = tu.DeleteACMEDNSRegistration(ts.URL, client, adminToken, registrationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, statusCode)
+		}
+		statusCode, _, err = tu.GetACMEDNSRegistration(ts.URL, client, adminToken, registrationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+		statusCode, _, err = tu.UpdateACMEDNS(ts.URL, client, registration.Username, registration.Password, server.UpdateACMEDNSParams{Subdomain: registration.Subdomain, TXT: acmeDNSTXT})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the deleted account to be forbidden, got status %d", statusCode)
+		}
+	})
+}
+
+func TestACMEDNSDisabled(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, _, err := tu.RegisterACMEDNS(ts.URL, client, adminToken, server.RegisterACMEDNSParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode == http.StatusCreated {
+		t.Fatalf("expected the acme-dns API to be disabled")
+	}
+	statusCode, err = tu.DeleteACMEDNSRegistration(ts.URL, client, adminToken, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+	}
+
+	statusCode, resp, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:          "builtin",
+		DirectoryURL:  "https://acme.example.com/directory",
+		Email:         "ops@example.com",
+		ChallengeType: db.ACMEChallengeDNS01,
+		DNSProvider:   "notary",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected the notary dns_provider to be rejected, got status %d: %s", statusCode, resp.Message)
+	}
+}
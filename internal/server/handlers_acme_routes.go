@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	notaryacme "github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type ACMERouteResponse struct {
+	DomainSuffix string `json:"domain_suffix"`
+	ACMEServerID int64  `json:"acme_server_id"`
+}
+
+type ACMERouteParams struct {
+	DomainSuffix string `json:"domain_suffix"`
+	ACMEServerID int64  `json:"acme_server_id"`
+}
+
+type ReplaceACMERoutesParams struct {
+	Routes []ACMERouteParams `json:"routes"`
+}
+
+// routes validates the routes of the request and normalizes their domain suffixes.
+// The returned errors are meant to be shown to the user.
+func (p *ReplaceACMERoutesParams) routes() ([]db.ACMERoute, error) {
+	routes := make([]db.ACMERoute, 0, len(p.Routes))
+	seen := make(map[string]bool, len(p.Routes))
+	for _, route := range p.Routes {
+		suffix, err := notaryacme.NormalizeDomainSuffix(route.DomainSuffix)
+		if err != nil {
+			return nil, err
+		}
+		if seen[suffix] {
+			return nil, fmt.Errorf("domain suffix %q is routed more than once", suffix)
+		}
+		seen[suffix] = true
+		if route.ACMEServerID <= 0 {
+			return nil, errors.New("acme_server_id is required")
+		}
+		routes = append(routes, db.ACMERoute{DomainSuffix: suffix, ACMEServerID: route.ACMEServerID})
+	}
+	return routes, nil
+}
+
+func dbACMERoutesToResponse(routes []db.ACMERoute) []ACMERouteResponse {
+	resp := make([]ACMERouteResponse, 0, len(routes))
+	for _, route := range routes {
+		resp = append(resp, ACMERouteResponse{DomainSuffix: route.DomainSuffix, ACMEServerID: route.ACMEServerID})
+	}
+	return resp
+}
+
+// ListACMERoutes returns the ACME routes in the order they are matched.
+func ListACMERoutes(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routes, err := env.Database.ListACMERoutes()
+		if err != nil {
+			env.SystemLogger.Error("failed to list ACME routes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMERoutesToResponse(routes), env.SystemLogger)
+	}
+}
+
+// ReplaceACMERoutes replaces the ACME routes with the ones in the request, which are matched in the given order.
+func ReplaceACMERoutes(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ReplaceACMERoutesParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
+			return
+		}
+		routes, err := params.routes()
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.ReplaceACMERoutes(routes); err != nil {
+			if errors.Is(err, db.ErrInvalidInput) {
+				writeResponse(w, http.StatusUnprocessableEntity, err.Error(), nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to replace ACME routes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		routes, err = env.Database.ListACMERoutes()
+		if err != nil {
+			env.SystemLogger.Error("failed to retrieve replaced ACME routes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbACMERoutesToResponse(routes), env.SystemLogger)
+	}
+}
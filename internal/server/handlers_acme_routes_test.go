@@ -0,0 +1,212 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func mustCreateACMEServer(t *testing.T, url string, client *http.Client, token string, name string) int64 {
+	t.Helper()
+	statusCode, created, err := tu.CreateACMEServer(url, client, token, tu.CreateACMEServerParams{
+		Name:          name,
+		DirectoryURL:  "https://" + name + ".example.com/directory",
+		Email:         "ops@example.com",
+		ChallengeType: db.ACMEChallengeHTTP01,
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	return created.Data.ID
+}
+
+func TestACMERoutesEndToEnd(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	client := ts.Client()
+
+	publicID := mustCreateACMEServer(t, ts.URL, client, adminToken, "public")
+	internalID := mustCreateACMEServer(t, ts.URL, client, adminToken, "internal")
+
+	t.Run("no routes initially", func(t *testing.T) {
+		statusCode, resp, err := tu.ListACMERoutes(ts.URL, client, readerToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 0 {
+			t.Fatalf("expected no routes, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("readers can't replace routes", func(t *testing.T) {
+		statusCode, _, err := tu.ReplaceACMERoutes(ts.URL, client, readerToken, server.ReplaceACMERoutesParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("invalid routes", func(t *testing.T) {
+		cases := []struct {
+			desc       string
+			routes     []server.ACMERouteParams
+			statusCode int
+			message    string
+		}{
+			{"invalid suffix", []server.ACMERouteParams{{DomainSuffix: "a*.corp", ACMEServerID: internalID}}, http.StatusBadRequest, "invalid domain suffix"},
+			{"duplicate suffix", []server.ACMERouteParams{{DomainSuffix: "corp", ACMEServerID: internalID}, {DomainSuffix: "*.corp", ACMEServerID: publicID}}, http.StatusBadRequest, "routed more than once"},
+			{"missing server ID", []server.ACMERouteParams{{DomainSuffix: "corp"}}, http.StatusBadRequest, "acme_server_id is required"},
+			{"missing server", []server.ACMERouteParams{{DomainSuffix: "corp", ACMEServerID: 100}}, http.StatusUnprocessableEntity, "ACME server 100 does not exist"},
+		}
+		for _, tc := range cases {
+			statusCode, resp, err := tu.ReplaceACMERoutes(ts.URL, client, adminToken, server.ReplaceACMERoutesParams{Routes: tc.routes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != tc.statusCode {
+				t.Fatalf("%s: expected status %d, got %d", tc.desc, tc.statusCode, statusCode)
+			}
+			if !strings.Contains(resp.Message, tc.message) {
+				t.Fatalf("%s: expected message to contain %q, got %q", tc.desc, tc.message, resp.Message)
+			}
+		}
+	})
+
+	t.Run("replace routes", func(t *testing.T) {
+		statusCode, resp, err := tu.ReplaceACMERoutes(ts.URL, client, adminToken, server.ReplaceACMERoutesParams{Routes: []server.ACMERouteParams{
+			{DomainSuffix: "*.corp", ACMEServerID: internalID},
+			{DomainSuffix: "", ACMEServerID: publicID},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		if len(resp.Data) != 2 || resp.Data[0].DomainSuffix != "corp" || resp.Data[0].ACMEServerID != internalID || resp.Data[1].ACMEServerID != publicID {
+			t.Fatalf("expected the normalized routes in order, got %+v", resp.Data)
+		}
+		_, servers, err := tu.ListACMEServers(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range servers.Data {
+			if !s.Active {
+				t.Fatalf("expected routed ACME server %d to be active", s.ID)
+			}
+		}
+		_, config, err := getConfig(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !config.Data.ACMEEnabled || config.Data.ACMEServerName != "public" {
+			t.Fatalf("expected ACME to be enabled with the default ACME server, got %+v", config.Data)
+		}
+	})
+
+	t.Run("sign a certificate request whose names span routes", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: tu.MustGenerateCSR(t, "git.corp", "www.example.com")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, resp, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+		if !strings.Contains(resp.Message, "routed to different ACME servers") || !strings.Contains(resp.Message, "www.example.com") {
+			t.Fatalf("expected a message naming the conflicting names, got %q", resp.Message)
+		}
+	})
+
+	t.Run("sign a certificate request that no route matches", func(t *testing.T) {
+		statusCode, _, err := tu.ReplaceACMERoutes(ts.URL, client, adminToken, server.ReplaceACMERoutesParams{Routes: []server.ACMERouteParams{
+			{DomainSuffix: "corp", ACMEServerID: internalID},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		statusCode, resp, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+		if resp.Message != "no ACME route matches www.example.com" {
+			t.Fatalf("expected a message naming the unrouted name, got %q", resp.Message)
+		}
+	})
+
+	t.Run("setting an active server adds a default route", func(t *testing.T) {
+		statusCode, _, err := tu.SetActiveACMEServer(ts.URL, client, adminToken, int(publicID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		_, resp, err := tu.ListACMERoutes(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) != 2 || resp.Data[0].ACMEServerID != internalID || resp.Data[1].DomainSuffix != "" || resp.Data[1].ACMEServerID != publicID {
+			t.Fatalf("expected the routes to be kept and a default route to the active server, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("setting an active server replaces the default route", func(t *testing.T) {
+		statusCode, _, err := tu.SetActiveACMEServer(ts.URL, client, adminToken, int(internalID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		_, resp, err := tu.ListACMERoutes(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) != 2 || resp.Data[0].DomainSuffix != "corp" || resp.Data[1].DomainSuffix != "" || resp.Data[1].ACMEServerID != internalID {
+			t.Fatalf("expected the default route to be sent to the active server, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("a routed server can't be deleted", func(t *testing.T) {
+		statusCode, err := tu.DeleteACMEServer(ts.URL, client, adminToken, int(internalID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+		statusCode, err = tu.DeleteACMEServer(ts.URL, client, adminToken, int(publicID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, statusCode)
+		}
+	})
+}
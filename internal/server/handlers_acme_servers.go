@@ -3,29 +3,131 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	notaryacme "github.com/canonical/notary/internal/acme"
 	"github.com/canonical/notary/internal/db"
 	"go.uber.org/zap"
 )
 
 type ACMEServerResponse struct {
-	ID           int64    `json:"id"`
-	Name         string   `json:"name"`
-	DirectoryURL string   `json:"directory_url"`
-	Email        string   `json:"email"`
-	DNSProvider  string   `json:"dns_provider"`
-	Active       bool     `json:"active"`
-	EnvVarKeys   []string `json:"env_var_keys"`
+	ID            int64    `json:"id"`
+	Name          string   `json:"name"`
+	DirectoryURL  string   `json:"directory_url"`
+	Email         string   `json:"email"`
+	DNSProvider   string   `json:"dns_provider"`
+	ChallengeType string   `json:"challenge_type"`
+	ChallengePort int64    `json:"challenge_port"`
+	Webroot       string   `json:"webroot"`
+	EABConfigured bool     `json:"eab_configured"`
+	Active        bool     `json:"active"`
+	ACMEAccountID *int64   `json:"acme_account_id"`
+	EnvVarKeys    []string `json:"env_var_keys"`
+
+	DNSResolvers              []string          `json:"dns_resolvers"`
+	DNSPropagationTimeout     int64             `json:"dns_propagation_timeout"`
+	DNSPollingInterval        int64             `json:"dns_polling_interval"`
+	DNSSkipAuthoritativeCheck bool              `json:"dns_skip_authoritative_check"`
+	DNSChallengeAliases       map[string]string `json:"dns_challenge_aliases"`
+
+	PreferredChain string `json:"preferred_chain"`
+	Profile        string `json:"profile"`
 }
 
 type ACMEServerParams struct {
-	Name         string            `json:"name"`
-	DirectoryURL string            `json:"directory_url"`
-	Email        string            `json:"email"`
-	DNSProvider  string            `json:"dns_provider"`
-	EnvVars      map[string]string `json:"env_vars"`
+	Name          string            `json:"name"`
+	DirectoryURL  string            `json:"directory_url"`
+	Email         string            `json:"email"`
+	DNSProvider   string            `json:"dns_provider"`
+	ChallengeType string            `json:"challenge_type"`
+	ChallengePort int64             `json:"challenge_port"`
+	Webroot       string            `json:"webroot"`
+	EABKeyID      *string           `json:"eab_key_id"`
+	EABHMACKey    string            `json:"eab_hmac_key"`
+	EnvVars       map[string]string `json:"env_vars"`
+
+	DNSResolvers              []string          `json:"dns_resolvers"`
+	DNSPropagationTimeout     int64             `json:"dns_propagation_timeout"`
+	DNSPollingInterval        int64             `json:"dns_polling_interval"`
+	DNSSkipAuthoritativeCheck bool              `json:"dns_skip_authoritative_check"`
+	DNSChallengeAliases       map[string]string `json:"dns_challenge_aliases"`
+
+	PreferredChain string `json:"preferred_chain"`
+	Profile        string `json:"profile"`
+}
+
+type TestACMEServerParams struct {
+	Domain string `json:"domain"`
+}
+
+type ACMEServerTestStepResponse struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type ACMEServerTestResponse struct {
+	Passed bool                         `json:"passed"`
+	Steps  []ACMEServerTestStepResponse `json:"steps"`
+}
+
+// challenge validates the challenge settings of the request and fills in their defaults.
+// The webroot must be within webrootDirectory.
+func (p *ACMEServerParams) challenge(webrootDirectory string) (db.ACMEChallenge, error) {
+	challenge, err := notaryacme.NormalizeChallenge(db.ACMEChallenge{
+		Type:    p.ChallengeType,
+		Port:    p.ChallengePort,
+		Webroot: p.Webroot,
+		DNS: db.ACMEDNSSettings{
+			Resolvers:              p.DNSResolvers,
+			PropagationTimeout:     p.DNSPropagationTimeout,
+			PollingInterval:        p.DNSPollingInterval,
+			SkipAuthoritativeCheck: p.DNSSkipAuthoritativeCheck,
+			ChallengeAliases:       p.DNSChallengeAliases,
+		},
+	}, p.DNSProvider)
+	if err != nil {
+		return challenge, err
+	}
+	return challenge, notaryacme.ValidateWebroot(challenge.Webroot, webrootDirectory)
+}
+
+// acmeWebrootDirectory returns the directory that the webroots of the ACME servers must be in.
+func acmeWebrootDirectory(env *HandlerDependencies) string {
+	if env.AppConfig == nil {
+		return ""
+	}
+	return env.ACMEWebrootDirectory
+}
+
+// orderOptions validates the default options of the orders of the request.
+func (p *ACMEServerParams) orderOptions() (db.ACMEOrderOptions, error) {
+	order := db.ACMEOrderOptions{PreferredChain: p.PreferredChain, Profile: p.Profile}
+	if err := notaryacme.ValidateOrderOptions(order); err != nil {
+		return db.ACMEOrderOptions{}, err
+	}
+	return order, nil
+}
+
+// externalAccountBinding returns the External Account Binding credentials of the request.
+// On update, existing is the binding stored on the server: it is kept when eab_key_id is omitted,
+// and its HMAC key is kept when the key ID is unchanged and eab_hmac_key is empty.
+func (p *ACMEServerParams) externalAccountBinding(existing db.ACMEExternalAccountBinding) (db.ACMEExternalAccountBinding, error) {
+	if p.EABKeyID == nil {
+		return existing, nil
+	}
+	eab := db.ACMEExternalAccountBinding{KeyID: *p.EABKeyID, HMACKey: p.EABHMACKey}
+	if eab.HMACKey == "" && eab.KeyID != "" && eab.KeyID == existing.KeyID {
+		eab.HMACKey = existing.HMACKey
+	}
+	if err := notaryacme.ValidateExternalAccountBinding(eab); err != nil {
+		return db.ACMEExternalAccountBinding{}, err
+	}
+	return eab, nil
 }
 
 func dbACMEServerToResponse(s *db.ACMEServer) ACMEServerResponse {
@@ -38,14 +140,35 @@ func dbACMEServerToResponse(s *db.ACMEServer) ACMEServerResponse {
 			}
 		}
 	}
+	dns := s.Challenge().DNS
+	if dns.Resolvers == nil {
+		dns.Resolvers = []string{}
+	}
+	if dns.ChallengeAliases == nil {
+		dns.ChallengeAliases = map[string]string{}
+	}
 	return ACMEServerResponse{
-		ID:           s.ID,
-		Name:         s.Name,
-		DirectoryURL: s.DirectoryURL,
-		Email:        s.Email,
-		DNSProvider:  s.DNSProvider,
-		Active:       s.Active,
-		EnvVarKeys:   envVarKeys,
+		ID:            s.ID,
+		Name:          s.Name,
+		DirectoryURL:  s.DirectoryURL,
+		Email:         s.Email,
+		DNSProvider:   s.DNSProvider,
+		ChallengeType: s.ChallengeType,
+		ChallengePort: s.ChallengePort,
+		Webroot:       s.Webroot,
+		EABConfigured: s.EABKeyID != "",
+		Active:        s.Active,
+		ACMEAccountID: s.ACMEAccountID,
+		EnvVarKeys:    envVarKeys,
+
+		DNSResolvers:              dns.Resolvers,
+		DNSPropagationTimeout:     dns.PropagationTimeout,
+		DNSPollingInterval:        dns.PollingInterval,
+		DNSSkipAuthoritativeCheck: dns.SkipAuthoritativeCheck,
+		DNSChallengeAliases:       dns.ChallengeAliases,
+
+		PreferredChain: s.PreferredChain,
+		Profile:        s.Profile,
 	}
 }
 
@@ -99,14 +222,33 @@ func CreateACMEServer(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
 			return
 		}
-		if params.Name == "" || params.DirectoryURL == "" || params.Email == "" || params.DNSProvider == "" {
-			writeResponse(w, http.StatusBadRequest, "name, directory_url, email, and dns_provider are required", nil, env.SystemLogger)
+		if params.Name == "" || params.DirectoryURL == "" || params.Email == "" {
+			writeResponse(w, http.StatusBadRequest, "name, directory_url and email are required", nil, env.SystemLogger)
+			return
+		}
+		challenge, err := params.challenge(acmeWebrootDirectory(env))
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		if challenge.Type == db.ACMEChallengeDNS01 && notaryacme.IsBuiltinDNSProvider(params.DNSProvider) && env.ACMEDNSRepository == nil {
+			writeResponse(w, http.StatusBadRequest, "the notary dns_provider requires the acme_dns service to be configured", nil, env.SystemLogger)
+			return
+		}
+		eab, err := params.externalAccountBinding(db.ACMEExternalAccountBinding{})
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		order, err := params.orderOptions()
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
 			return
 		}
 		if params.EnvVars == nil {
 			params.EnvVars = map[string]string{}
 		}
-		newID, err := env.Database.CreateACMEServer(params.Name, params.DirectoryURL, params.Email, params.DNSProvider, params.EnvVars)
+		newID, err := env.Database.CreateACMEServer(params.Name, params.DirectoryURL, params.Email, params.DNSProvider, params.EnvVars, challenge, eab, order)
 		if err != nil {
 			env.SystemLogger.Error("failed to create ACME server", zap.Error(err))
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
@@ -134,8 +276,22 @@ func UpdateACMEServer(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
 			return
 		}
-		if params.Name == "" || params.DirectoryURL == "" || params.Email == "" || params.DNSProvider == "" {
-			writeResponse(w, http.StatusBadRequest, "name, directory_url, email, and dns_provider are required", nil, env.SystemLogger)
+		if params.Name == "" || params.DirectoryURL == "" || params.Email == "" {
+			writeResponse(w, http.StatusBadRequest, "name, directory_url and email are required", nil, env.SystemLogger)
+			return
+		}
+		challenge, err := params.challenge(acmeWebrootDirectory(env))
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		if challenge.Type == db.ACMEChallengeDNS01 && notaryacme.IsBuiltinDNSProvider(params.DNSProvider) && env.ACMEDNSRepository == nil {
+			writeResponse(w, http.StatusBadRequest, "the notary dns_provider requires the acme_dns service to be configured", nil, env.SystemLogger)
+			return
+		}
+		order, err := params.orderOptions()
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
 			return
 		}
 		// Merge env vars: empty values for existing keys mean "keep existing credential".
@@ -149,6 +305,11 @@ func UpdateACMEServer(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
+		eab, err := params.externalAccountBinding(existing.ExternalAccountBinding())
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
 		var existingEnvVars map[string]string
 		if existing.EnvVars != "" {
 			if err := json.Unmarshal([]byte(existing.EnvVars), &existingEnvVars); err != nil {
@@ -171,7 +332,7 @@ func UpdateACMEServer(env *HandlerDependencies) http.HandlerFunc {
 				}
 			}
 		}
-		if err := env.Database.UpdateACMEServer(id, params.Name, params.DirectoryURL, params.Email, params.DNSProvider, envVarsToStore); err != nil {
+		if err := env.Database.UpdateACMEServer(id, params.Name, params.DirectoryURL, params.Email, params.DNSProvider, envVarsToStore, challenge, eab, order); err != nil {
 			if errors.Is(err, db.ErrNotFound) {
 				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
 				return
@@ -202,6 +363,10 @@ func DeleteACMEServer(env *HandlerDependencies) http.HandlerFunc {
 				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
 				return
 			}
+			if errors.Is(err, db.ErrInUse) {
+				writeResponse(w, http.StatusConflict, "the ACME server is used by ACME routes", nil, env.SystemLogger)
+				return
+			}
 			env.SystemLogger.Error("failed to delete ACME server", zap.Error(err), zap.Int64("id", id))
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
@@ -236,3 +401,70 @@ func SetActiveACMEServer(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+// TestACMEServer checks the configuration of an ACME server without ordering a certificate, and returns
+// the report of each step. The response is 200 OK whether the steps pass or not.
+func TestACMEServer(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid id", nil, env.SystemLogger)
+			return
+		}
+		var params TestACMEServerParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeResponse(w, http.StatusBadRequest, "invalid request body", nil, env.SystemLogger)
+			return
+		}
+		domain := strings.ToLower(strings.TrimSuffix(params.Domain, "."))
+		if strings.ContainsAny(domain, "*/: ") {
+			writeResponse(w, http.StatusBadRequest, "invalid domain", nil, env.SystemLogger)
+			return
+		}
+		server, err := env.Database.GetDecryptedACMEServer(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get ACME server", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		var envVars map[string]string
+		if err := json.Unmarshal([]byte(server.EnvVars), &envVars); err != nil {
+			env.SystemLogger.Error("failed to decode ACME server env vars", zap.Error(err), zap.Int64("id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		report := notaryacme.NewACMERepository(server, envVars, env.Database).DryRun(domain)
+		resp := ACMEServerTestResponse{Passed: report.Passed(), Steps: make([]ACMEServerTestStepResponse, 0, len(report.Steps))}
+		for _, step := range report.Steps {
+			resp.Steps = append(resp.Steps, ACMEServerTestStepResponse{
+				Name:       step.Name,
+				Status:     step.Status,
+				Detail:     step.Detail,
+				DurationMS: step.Duration.Milliseconds(),
+			})
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
+
+// warnUnsupportedDNSProviders logs the ACME servers that were saved with a DNS provider that can no longer be used,
+// such as the manual provider of lego accepted by earlier versions. Their orders fail until the provider is changed.
+func warnUnsupportedDNSProviders(env *HandlerDependencies) {
+	servers, err := env.Database.ListACMEServers()
+	if err != nil {
+		env.SystemLogger.Error("failed to list ACME servers", zap.Error(err))
+		return
+	}
+	for _, server := range servers {
+		if server.ChallengeType == db.ACMEChallengeDNS01 && !notaryacme.IsSupportedDNSProvider(server.DNSProvider) {
+			env.SystemLogger.Warn("ACME server uses an unsupported DNS provider, its orders will fail until it is updated",
+				zap.Int64("id", server.ID),
+				zap.String("name", server.Name),
+				zap.String("dns_provider", server.DNSProvider),
+				zap.Strings("supported_dns_providers", notaryacme.SupportedDNSProviders()))
+		}
+	}
+}
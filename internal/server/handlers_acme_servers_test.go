@@ -2,8 +2,11 @@ package server_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/server"
 	tu "github.com/canonical/notary/internal/testutils"
 )
 
@@ -200,8 +203,14 @@ func TestACMEServersEndToEnd(t *testing.T) {
 			t.Fatal("expected first server to be inactive after second was activated")
 		}
 
-		// Clean up second server
-		tu.DeleteACMEServer(ts.URL, client, adminToken, secondID) //nolint:errcheck
+		// The active server is routed to, so it can't be deleted
+		statusCode, err = tu.DeleteACMEServer(ts.URL, client, adminToken, secondID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected %d, got %d", http.StatusConflict, statusCode)
+		}
 	})
 
 	t.Run("12. Requestor cannot create ACME server", func(t *testing.T) {
@@ -372,3 +381,481 @@ func TestACMEServerEnvVarKeysInResponse(t *testing.T) {
 		t.Fatalf("expected 0 env_var_keys after empty env_vars update, got %d: %v", len(updated.Data.EnvVarKeys), updated.Data.EnvVarKeys)
 	}
 }
+
+func TestACMEServerUnsupportedDNSProvider(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, resp, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:         "Unknown provider",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		DNSProvider:  "not-a-provider",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+	}
+	if !strings.Contains(resp.Message, "cloudflare") {
+		t.Fatalf("expected the supported providers to be listed, got %q", resp.Message)
+	}
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:         "Cloudflare",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		DNSProvider:  "Cloudflare",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+
+	statusCode, _, err = tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "Cloudflare",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		DNSProvider:  "not-a-provider",
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+	}
+}
+
+func TestACMEServerWebrootRequiresConfiguredDirectory(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+
+	statusCode, resp, err := tu.CreateACMEServer(ts.URL, ts.Client(), adminToken, tu.CreateACMEServerParams{
+		Name:          "HTTP-01",
+		DirectoryURL:  "https://acme-v02.api.letsencrypt.org/directory",
+		Email:         "ops@example.com",
+		ChallengeType: "http-01",
+		Webroot:       "/var/www/html",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest || !strings.Contains(resp.Message, "webroot_directory") {
+		t.Fatalf("expected the webroot to be rejected, got status %d: %s", statusCode, resp.Message)
+	}
+}
+
+func TestACMEServerChallengeSettings(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithACMEWebroot("/var/www"))
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:          "HTTP-01",
+		DirectoryURL:  "https://acme-v02.api.letsencrypt.org/directory",
+		Email:         "ops@example.com",
+		ChallengeType: "http-01",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	if created.Data.ChallengeType != "http-01" || created.Data.ChallengePort != 80 {
+		t.Fatalf("expected http-01 on port 80, got %s on port %d", created.Data.ChallengeType, created.Data.ChallengePort)
+	}
+
+	statusCode, updated, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:          "HTTP-01",
+		DirectoryURL:  "https://acme-v02.api.letsencrypt.org/directory",
+		Email:         "ops@example.com",
+		ChallengeType: "http-01",
+		Webroot:       "/var/www/html",
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, updated.Message)
+	}
+	if updated.Data.Webroot != "/var/www/html" || updated.Data.ChallengePort != 0 {
+		t.Fatalf("expected webroot /var/www/html without port, got %q on port %d", updated.Data.Webroot, updated.Data.ChallengePort)
+	}
+
+	for _, webroot := range []string{"/etc/cron.d", "/var/www/../../etc"} {
+		statusCode, updated, err = tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+			Name:          "HTTP-01",
+			DirectoryURL:  "https://acme-v02.api.letsencrypt.org/directory",
+			Email:         "ops@example.com",
+			ChallengeType: "http-01",
+			Webroot:       webroot,
+		})
+		if err != nil {
+			t.Fatalf("UpdateACMEServer() error: %v", err)
+		}
+		if statusCode != http.StatusBadRequest || !strings.Contains(updated.Message, "webroot must be within /var/www") {
+			t.Fatalf("expected webroot %s to be rejected, got status %d: %s", webroot, statusCode, updated.Message)
+		}
+	}
+
+	statusCode, resp, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:          "TLS-ALPN-01",
+		DirectoryURL:  "https://acme-v02.api.letsencrypt.org/directory",
+		Email:         "ops@example.com",
+		ChallengeType: "tls-alpn-01",
+		Webroot:       "/var/www/html",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+	}
+	if !strings.Contains(resp.Message, "webroot") {
+		t.Fatalf("expected a webroot error, got %q", resp.Message)
+	}
+
+	statusCode, _, err = tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:         "No DNS provider",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d for dns-01 without dns_provider, got %d", http.StatusBadRequest, statusCode)
+	}
+}
+
+func TestACMEServerDNSSettings(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:                      "Internal DNS",
+		DirectoryURL:              "https://acme-v02.api.letsencrypt.org/directory",
+		Email:                     "ops@example.com",
+		DNSProvider:               "cloudflare",
+		EnvVars:                   map[string]string{"CLOUDFLARE_DNS_API_TOKEN": "secret"},
+		DNSResolvers:              []string{"10.0.0.53", "10.0.1.53:5353"},
+		DNSPropagationTimeout:     900,
+		DNSPollingInterval:        15,
+		DNSSkipAuthoritativeCheck: true,
+		DNSChallengeAliases:       map[string]string{"Example.com.": "acme.example.net"},
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	if strings.Join(created.Data.DNSResolvers, ",") != "10.0.0.53:53,10.0.1.53:5353" {
+		t.Fatalf("expected the resolvers with their port, got %v", created.Data.DNSResolvers)
+	}
+	if created.Data.DNSPropagationTimeout != 900 || created.Data.DNSPollingInterval != 15 || !created.Data.DNSSkipAuthoritativeCheck {
+		t.Fatalf("expected the propagation settings to be stored, got %+v", created.Data)
+	}
+	if created.Data.DNSChallengeAliases["example.com"] != "acme.example.net" {
+		t.Fatalf("expected the normalized challenge alias, got %v", created.Data.DNSChallengeAliases)
+	}
+
+	statusCode, updated, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "Internal DNS",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, updated.Message)
+	}
+	if len(updated.Data.DNSResolvers) != 0 || updated.Data.DNSPropagationTimeout != 0 || len(updated.Data.DNSChallengeAliases) != 0 {
+		t.Fatalf("expected the DNS settings to be reset to their defaults, got %+v", updated.Data)
+	}
+
+	cases := []struct {
+		desc    string
+		params  tu.UpdateACMEServerParams
+		wantErr string
+	}{
+		{
+			desc:    "invalid resolver",
+			params:  tu.UpdateACMEServerParams{DNSProvider: "cloudflare", DNSResolvers: []string{"not a resolver"}},
+			wantErr: "dns_resolvers",
+		},
+		{
+			desc:    "polling interval longer than timeout",
+			params:  tu.UpdateACMEServerParams{DNSProvider: "cloudflare", DNSPropagationTimeout: 30, DNSPollingInterval: 60},
+			wantErr: "dns_polling_interval",
+		},
+		{
+			desc:    "invalid alias",
+			params:  tu.UpdateACMEServerParams{DNSProvider: "cloudflare", DNSChallengeAliases: map[string]string{"example.com": "https://example.net"}},
+			wantErr: "dns_challenge_aliases",
+		},
+		{
+			desc:    "settings without dns-01",
+			params:  tu.UpdateACMEServerParams{ChallengeType: "http-01", DNSSkipAuthoritativeCheck: true},
+			wantErr: "dns-01",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.params.Name = "Internal DNS"
+			tc.params.DirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+			tc.params.Email = "ops@example.com"
+			statusCode, resp, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tc.params)
+			if err != nil {
+				t.Fatalf("UpdateACMEServer() error: %v", err)
+			}
+			if statusCode != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+			}
+			if !strings.Contains(resp.Message, tc.wantErr) {
+				t.Fatalf("expected an error about %s, got %q", tc.wantErr, resp.Message)
+			}
+		})
+	}
+}
+
+func TestACMEServerOrderOptions(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:           "Let's Encrypt",
+		DirectoryURL:   "https://acme-v02.api.letsencrypt.org/directory",
+		Email:          "ops@example.com",
+		DNSProvider:    "cloudflare",
+		EnvVars:        map[string]string{"CLOUDFLARE_DNS_API_TOKEN": "secret"},
+		PreferredChain: "ISRG Root X1",
+		Profile:        "tlsserver",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	if created.Data.PreferredChain != "ISRG Root X1" || created.Data.Profile != "tlsserver" {
+		t.Fatalf("expected the order options to be stored, got %+v", created.Data)
+	}
+
+	statusCode, updated, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "Let's Encrypt",
+		DirectoryURL: "https://acme-v02.api.letsencrypt.org/directory",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+		Profile:      "shortlived",
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, updated.Message)
+	}
+	if updated.Data.PreferredChain != "" || updated.Data.Profile != "shortlived" {
+		t.Fatalf("expected the order options to be replaced, got %+v", updated.Data)
+	}
+
+	cases := []struct {
+		desc    string
+		params  tu.UpdateACMEServerParams
+		wantErr string
+	}{
+		{
+			desc:    "invalid preferred chain",
+			params:  tu.UpdateACMEServerParams{PreferredChain: "ISRG Root X1\n"},
+			wantErr: "preferred_chain",
+		},
+		{
+			desc:    "invalid profile",
+			params:  tu.UpdateACMEServerParams{Profile: "tls server"},
+			wantErr: "profile",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tc.params.Name = "Let's Encrypt"
+			tc.params.DirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+			tc.params.Email = "ops@example.com"
+			tc.params.DNSProvider = "cloudflare"
+			statusCode, resp, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tc.params)
+			if err != nil {
+				t.Fatalf("UpdateACMEServer() error: %v", err)
+			}
+			if statusCode != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+			}
+			if !strings.Contains(resp.Message, tc.wantErr) {
+				t.Fatalf("expected an error about %s, got %q", tc.wantErr, resp.Message)
+			}
+		})
+	}
+}
+
+func TestACMEServerExternalAccountBinding(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "acme-admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	keyID := "kid-1"
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:         "ZeroSSL",
+		DirectoryURL: "https://acme.zerossl.com/v2/DV90",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+		EABKeyID:     &keyID,
+		EABHMACKey:   "c2VjcmV0LWhtYWMta2V5",
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	if !created.Data.EABConfigured {
+		t.Fatal("expected eab_configured to be true")
+	}
+
+	statusCode, updated, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "ZeroSSL",
+		DirectoryURL: "https://acme.zerossl.com/v2/DV90",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, updated.Message)
+	}
+	if !updated.Data.EABConfigured {
+		t.Fatal("expected the binding to be kept when eab_key_id is omitted")
+	}
+
+	otherKeyID := "kid-2"
+	statusCode, resp, err := tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "ZeroSSL",
+		DirectoryURL: "https://acme.zerossl.com/v2/DV90",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+		EABKeyID:     &otherKeyID,
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a new key ID without HMAC key, got %d", http.StatusBadRequest, statusCode)
+	}
+	if !strings.Contains(resp.Message, "eab_hmac_key") {
+		t.Fatalf("expected an eab_hmac_key error, got %q", resp.Message)
+	}
+
+	noKeyID := ""
+	statusCode, updated, err = tu.UpdateACMEServer(ts.URL, client, adminToken, int(created.Data.ID), tu.UpdateACMEServerParams{
+		Name:         "ZeroSSL",
+		DirectoryURL: "https://acme.zerossl.com/v2/DV90",
+		Email:        "ops@example.com",
+		DNSProvider:  "cloudflare",
+		EABKeyID:     &noKeyID,
+	})
+	if err != nil {
+		t.Fatalf("UpdateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, updated.Message)
+	}
+	if updated.Data.EABConfigured {
+		t.Fatal("expected an empty eab_key_id to remove the binding")
+	}
+}
+
+func TestTestACMEServer(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	client := ts.Client()
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:          "pebble",
+		DirectoryURL:  directoryURL,
+		Email:         "ops@example.com",
+		ChallengeType: db.ACMEChallengeHTTP01,
+		ChallengePort: int64(tu.MustGetFreePort(t)),
+		EnvVars:       map[string]string{},
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+
+	t.Run("readers can't test servers", func(t *testing.T) {
+		statusCode, _, err := tu.TestACMEServer(ts.URL, client, readerToken, created.Data.ID, server.TestACMEServerParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("invalid domain", func(t *testing.T) {
+		statusCode, _, err := tu.TestACMEServer(ts.URL, client, adminToken, created.Data.ID, server.TestACMEServerParams{Domain: "*.example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+	})
+
+	t.Run("unknown server", func(t *testing.T) {
+		statusCode, _, err := tu.TestACMEServer(ts.URL, client, adminToken, 100, server.TestACMEServerParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		statusCode, resp, err := tu.TestACMEServer(ts.URL, client, adminToken, created.Data.ID, server.TestACMEServerParams{Domain: "localhost"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, statusCode, resp.Message)
+		}
+		if !resp.Data.Passed || len(resp.Data.Steps) != 5 {
+			t.Fatalf("expected every step to pass, got %+v", resp.Data)
+		}
+		for _, step := range resp.Data.Steps {
+			if step.Status != "passed" {
+				t.Fatalf("expected step %s to pass, got %s: %s", step.Name, step.Status, step.Detail)
+			}
+		}
+		_, acmeServer, err := tu.GetACMEServer(ts.URL, client, adminToken, int(created.Data.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acmeServer.Data.ACMEAccountID == nil {
+			t.Fatal("expected the test to register the account of the server")
+		}
+	})
+}
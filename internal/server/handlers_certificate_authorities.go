@@ -43,6 +43,7 @@ type CertificateAuthority struct {
 	CertificatePEM string `json:"certificate"`
 	CSRPEM         string `json:"csr"`
 	CRL            string `json:"crl"`
+	AutoRenew      bool   `json:"auto_renew"`
 }
 
 type CRL struct {
@@ -73,6 +74,9 @@ type UploadCertificateToCertificateAuthorityParams struct {
 type SignCertificateRequestParams struct {
 	CertificateAuthorityID string `json:"certificate_authority_id"`
 	SigningMethod          string `json:"signing_method"`
+	// ACMEPreferredChain and ACMEProfile override the defaults of the ACME server for this order.
+	ACMEPreferredChain string `json:"acme_preferred_chain"`
+	ACMEProfile        string `json:"acme_profile"`
 }
 
 type SignCertificateAuthorityParams struct {
@@ -241,16 +245,20 @@ func ListCertificateAuthorities(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
-		caResponse := make([]CertificateAuthority, len(cas))
-		for i, ca := range cas {
-			caResponse[i] = CertificateAuthority{
+		caResponse := make([]CertificateAuthority, 0, len(cas))
+		for _, ca := range cas {
+			if !certificateAuthorityAllowed(r, ca.CertificateAuthorityID) {
+				continue
+			}
+			caResponse = append(caResponse, CertificateAuthority{
 				ID:             ca.CertificateAuthorityID,
 				Enabled:        ca.Enabled,
 				PrivateKeyPEM:  "",
 				CSRPEM:         ca.CSRPEM,
 				CertificatePEM: ca.CertificateChain,
 				CRL:            ca.CRL,
-			}
+				AutoRenew:      ca.AutoRenew,
+			})
 		}
 		writeResponse(w, http.StatusOK, "", caResponse, env.SystemLogger)
 	}
@@ -271,7 +279,7 @@ func CreateCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err), nil, env.SystemLogger)
 			return
 		}
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -332,6 +340,7 @@ func GetCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 			CSRPEM:         ca.CSRPEM,
 			CertificatePEM: ca.CertificateChain,
 			CRL:            ca.CRL,
+			AutoRenew:      ca.AutoRenew,
 		}
 
 		writeResponse(w, http.StatusOK, "", caResponse, env.SystemLogger)
@@ -355,7 +364,7 @@ func UpdateCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -382,6 +391,49 @@ func UpdateCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+// UpdateCertificateAuthorityRenewal opts the certificates issued by a Certificate Authority in or out of automatic renewal.
+// It returns a 200 OK on success
+func UpdateCertificateAuthorityRenewal(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idNum, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		var params UpdateAutoRenewParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if cookieErr != nil {
+			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		err = env.Database.UpdateCertificateAuthorityAutoRenew(db.ByCertificateAuthorityID(idNum), params.Enabled)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to update certificate authority renewal", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		env.AuditLogger.AutoRenewalUpdated("certificate_authority", id, params.Enabled,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeResponse(w, http.StatusOK, "", nil, env.SystemLogger)
+	}
+}
+
 // DeleteCertificateAuthority handler deletes a Certificate Authority given its id
 // It returns a 200 OK on success
 func DeleteCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
@@ -393,7 +445,7 @@ func DeleteCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -453,7 +505,7 @@ func PostCertificateAuthorityCertificate(env *HandlerDependencies) http.HandlerF
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -499,7 +551,7 @@ func SignCertificateAuthority(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -569,6 +621,54 @@ func GetCertificateAuthorityCRL(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+// GetCertificateAuthorityChain handler returns the certificate chain of the associated CA as a file.
+// The format is negotiated with the "format" query parameter or the Accept header,
+// and the root certificate can be left out with "include_root=false".
+// It returns a 200 OK on success
+func GetCertificateAuthorityChain(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idNum, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		format, err := negotiateCertificateFormat(r)
+		if err != nil {
+			writeResponse(w, http.StatusNotAcceptable, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		includeRoot, err := includeRootParam(r)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid include_root value", nil, env.SystemLogger)
+			return
+		}
+
+		ca, err := env.Database.GetDenormalizedCertificateAuthority(db.ByCertificateAuthorityDenormalizedID(idNum))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get certificate authority chain", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if ca.CertificateChain == "" {
+			writeResponse(w, http.StatusNotFound, "certificate not found", nil, env.SystemLogger)
+			return
+		}
+
+		body, err := encodeCertificateChain(ca.CertificateChain, format, includeRoot)
+		if err != nil {
+			env.SystemLogger.Error("failed to encode certificate authority chain", zap.Error(err), zap.Int64("ca_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeCertificateDownload(w, body, format, fmt.Sprintf("ca-%d-chain", ca.CertificateAuthorityID), env.SystemLogger)
+	}
+}
+
 // RevokeCertificateAuthorityCertificate handler receives an id as a path parameter,
 // and revokes the corresponding certificate by placing the certificate serial number to the CRL
 // It returns a 200 OK on success
@@ -581,7 +681,7 @@ func RevokeCertificateAuthorityCertificate(env *HandlerDependencies) http.Handle
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Info("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -1081,7 +1081,7 @@ func TestCertificateRevocationListsEndToEnd(t *testing.T) {
 		if response.Message != "" {
 			t.Fatalf("expected success, got %s", response.Message)
 		}
-		statusCode, response, err = tu.RevokeCertificateRequest(ts.URL, client, adminToken, 4)
+		statusCode, response, err = tu.RevokeCertificateRequestWithReason(ts.URL, client, adminToken, 4, "keyCompromise")
 		if err != nil {
 			t.Fatal("expected no error, got: ", err)
 		}
@@ -1135,6 +1135,7 @@ func TestCertificateRevocationListsEndToEnd(t *testing.T) {
 		if len(cas.Data) != 2 {
 			t.Fatalf("expected 2 certificate authorities, got %d", len(cas.Data))
 		}
+		reasonCodes := make(map[int]bool)
 		for _, ca := range cas.Data {
 			crl, err := db.ParseCRL(ca.CRL)
 			if err != nil {
@@ -1146,6 +1147,10 @@ func TestCertificateRevocationListsEndToEnd(t *testing.T) {
 			if crl.RevokedCertificateEntries[0].SerialNumber == big.NewInt(int64(0)) {
 				t.Fatalf("expected a real serial number, got %d", crl.RevokedCertificateEntries[0].SerialNumber)
 			}
+			reasonCodes[crl.RevokedCertificateEntries[0].ReasonCode] = true
+		}
+		if !reasonCodes[0] || !reasonCodes[1] {
+			t.Fatalf("expected one unspecified and one keyCompromise revocation, got reason codes %v", reasonCodes)
 		}
 	})
 
@@ -1,20 +1,32 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	notaryacme "github.com/canonical/notary/internal/acme"
 	"github.com/canonical/notary/internal/backends/observability/log"
 	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
 	"go.uber.org/zap"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	generatedKeySize        = 2048
+	minPKCS12PasswordLength = 8
 )
 
 type CreateCertificateRequestParams struct {
@@ -62,18 +74,69 @@ func (params *CreateCertificateParams) IsValid() (bool, error) {
 	return true, nil
 }
 
+type GenerateCertificateRequestParams struct {
+	CommonName          string `json:"common_name"`
+	SANsDNS             string `json:"sans_dns"`
+	CountryName         string `json:"country_name"`
+	StateOrProvinceName string `json:"state_or_province_name"`
+	LocalityName        string `json:"locality_name"`
+	OrganizationName    string `json:"organization_name"`
+	OrganizationalUnit  string `json:"organizational_unit_name"`
+}
+
+func (params *GenerateCertificateRequestParams) IsValid() (bool, error) {
+	if strings.TrimSpace(params.CommonName) == "" && strings.TrimSpace(params.SANsDNS) == "" {
+		return false, errors.New("common_name or sans_dns is required")
+	}
+	// If a country is provided, it must be exactly two letters (ISO 3166-1 alpha-2).
+	if params.CountryName != "" && len(params.CountryName) != 2 {
+		return false, fmt.Errorf("country_name must be a 2-letter ISO code")
+	}
+	for _, san := range params.dnsNames() {
+		if strings.ContainsAny(san, " /") {
+			return false, fmt.Errorf("invalid DNS name in sans_dns: %s", san)
+		}
+	}
+	return true, nil
+}
+
+// dnsNames returns the comma separated DNS names of the sans_dns field.
+func (params *GenerateCertificateRequestParams) dnsNames() []string {
+	var names []string
+	for name := range strings.SplitSeq(params.SANsDNS, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type GetCertificateRequestPKCS12Params struct {
+	Password string `json:"password"`
+}
+
+func (params *GetCertificateRequestPKCS12Params) IsValid() (bool, error) {
+	if len(params.Password) < minPKCS12PasswordLength {
+		return false, fmt.Errorf("password must be at least %d characters long", minPKCS12PasswordLength)
+	}
+	return true, nil
+}
+
 type CertificateRequest struct {
 	ID               int64  `json:"id"`
 	CSR              string `json:"csr"`
 	CertificateChain string `json:"certificate_chain"`
 	Status           string `json:"status"`
 	Email            string `json:"email"`
+	AutoRenew        bool   `json:"auto_renew"`
+	ACMEProfile      string `json:"acme_profile"`
+	ACMEChain        string `json:"acme_chain"`
 }
 
 // ListCertificateRequests returns all of the Certificate Requests
 func ListCertificateRequests(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -95,6 +158,23 @@ func ListCertificateRequests(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
+		if caller, ok := r.Context().Value(principalContextKey{}).(*principal); ok {
+			// API tokens limited to some certificate authorities only see the certificate requests they can act on.
+			allowedCSRs := csrs[:0]
+			for _, csr := range csrs {
+				allowed, err := certificateRequestAllowed(env, caller, csr.CSR_ID)
+				if err != nil {
+					env.SystemLogger.Error("failed to check the certificate authority of certificate request", zap.Error(err), zap.Int64("csr_id", csr.CSR_ID))
+					writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+					return
+				}
+				if allowed {
+					allowedCSRs = append(allowedCSRs, csr)
+				}
+			}
+			csrs = allowedCSRs
+		}
+
 		certificateRequestsResponse := make([]CertificateRequest, len(csrs))
 		for i, csr := range csrs {
 			var email string
@@ -118,6 +198,9 @@ func ListCertificateRequests(env *HandlerDependencies) http.HandlerFunc {
 				Status:           csr.Status,
 				CertificateChain: csr.CertificateChain,
 				Email:            email,
+				AutoRenew:        csr.AutoRenew,
+				ACMEProfile:      csr.ACMEProfile,
+				ACMEChain:        csr.ACMEChain,
 			}
 		}
 		writeResponse(w, http.StatusOK, "", certificateRequestsResponse, env.SystemLogger)
@@ -137,7 +220,7 @@ func CreateCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err), nil, env.SystemLogger)
 			return
 		}
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -168,11 +251,210 @@ func CreateCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+// generateCertificateRequest uses the input fields from the certificate request generation form to create
+// a private key and an x.509 certificate request signed with it. It returns them as PEM strings.
+func generateCertificateRequest(fields GenerateCertificateRequestParams) (string, string, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, generatedKeySize)
+	if err != nil {
+		return "", "", fmt.Errorf("error generating certificate request: %w", err)
+	}
+	privPEM := new(bytes.Buffer)
+	err = pem.Encode(privPEM, &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(priv),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("error generating certificate request: %w", err)
+	}
+	subject := pkix.Name{CommonName: fields.CommonName}
+	if fields.CountryName != "" {
+		subject.Country = []string{fields.CountryName}
+	}
+	if fields.StateOrProvinceName != "" {
+		subject.Province = []string{fields.StateOrProvinceName}
+	}
+	if fields.LocalityName != "" {
+		subject.Locality = []string{fields.LocalityName}
+	}
+	if fields.OrganizationName != "" {
+		subject.Organization = []string{fields.OrganizationName}
+	}
+	if fields.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{fields.OrganizationalUnit}
+	}
+	csrTemplate := &x509.CertificateRequest{
+		Subject:  subject,
+		DNSNames: fields.dnsNames(),
+	}
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, priv)
+	if err != nil {
+		return "", "", fmt.Errorf("error generating certificate request: %w", err)
+	}
+	csrPEM := new(bytes.Buffer)
+	err = pem.Encode(csrPEM, &pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csrBytes,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("error generating certificate request: %w", err)
+	}
+	return csrPEM.String(), privPEM.String(), nil
+}
+
+// GenerateCertificateRequest generates a private key and a Certificate Request from the given subject fields,
+// and returns the id of the created row. The private key is kept until it is collected as a PKCS#12 file.
+func GenerateCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params GenerateCertificateRequestParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		valid, err := params.IsValid()
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err), nil, env.SystemLogger)
+			return
+		}
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if cookieErr != nil {
+			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		csrPEM, privPEM, err := generateCertificateRequest(params)
+		if err != nil {
+			env.SystemLogger.Error("failed to generate certificate request", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		newCSRID, err := env.Database.CreateCertificateRequestWithGeneratedKey(csrPEM, privPEM, claims.Email)
+		if err != nil {
+			env.SystemLogger.Error("failed to create certificate request with generated key", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		env.AuditLogger.CertificateRequested(strconv.FormatInt(newCSRID, 10), 0,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeResponse(w, http.StatusCreated, "", map[string]int64{"id": newCSRID}, env.SystemLogger)
+	}
+}
+
+// GetCertificateRequestPKCS12 receives an id as a path parameter and a password, and returns the
+// generated private key and certificate chain of the corresponding Certificate Request as a password
+// protected PKCS#12 file. The private key is wiped afterwards, so this only succeeds once.
+func GetCertificateRequestPKCS12(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, headerErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if headerErr != nil {
+			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(headerErr))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		id := r.PathValue("id")
+		idNum, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		var params GetCertificateRequestPKCS12Params
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		valid, err := params.IsValid()
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %s", err), nil, env.SystemLogger)
+			return
+		}
+
+		csr, err := env.Database.GetCertificateRequestAndChain(db.ByCSRID(idNum))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get certificate request", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		// Only the requestor that generated the key can collect it, regardless of their role
+		if claims.Email != csr.UserEmail {
+			env.SystemLogger.Warn("generated private key access denied", zap.String("requester_email", claims.Email), zap.String("owner_email", csr.UserEmail), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusForbidden, "access denied", nil, env.SystemLogger)
+			return
+		}
+
+		generatedKey, err := env.Database.GetDecryptedGeneratedPrivateKey(db.ByCSRID(idNum))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "no generated private key for this certificate request", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get generated private key", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if generatedKey.Delivered {
+			writeResponse(w, http.StatusGone, "private key has already been delivered", nil, env.SystemLogger)
+			return
+		}
+		if csr.Status != "Active" || csr.CertificateChain == "" {
+			writeResponse(w, http.StatusConflict, "certificate request is not signed yet", nil, env.SystemLogger)
+			return
+		}
+
+		privateKey, err := db.ParsePrivateKey(generatedKey.PrivateKeyPEM)
+		if err != nil {
+			env.SystemLogger.Error("failed to parse generated private key", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		certs, err := db.ParseCertificateChain(csr.CertificateChain)
+		if err != nil || len(certs) == 0 {
+			env.SystemLogger.Error("failed to parse certificate chain", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		pfx, err := pkcs12.Modern.Encode(privateKey, certs[0], certs[1:], params.Password)
+		if err != nil {
+			env.SystemLogger.Error("failed to encode PKCS#12 file", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		// Wiping the key is what guarantees that it is delivered once, even with concurrent requests
+		err = env.Database.DeliverGeneratedPrivateKey(db.ByCSRID(idNum))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusGone, "private key has already been delivered", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to mark generated private key as delivered", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		env.AuditLogger.PrivateKeyDelivered(strconv.FormatInt(idNum, 10),
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeCertificateDownload(w, pfx, certificateFormatPKCS12, fmt.Sprintf("certificate-%d", idNum), env.SystemLogger)
+	}
+}
+
 // GetCertificateRequest receives an id as a path parameter, and
 // returns the corresponding Certificate Request
 func GetCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		claims, headerErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, headerErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if headerErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(headerErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -237,12 +519,89 @@ func GetCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 			CertificateChain: csr.CertificateChain,
 			Status:           csr.Status,
 			Email:            email,
+			AutoRenew:        csr.AutoRenew,
+			ACMEProfile:      csr.ACMEProfile,
+			ACMEChain:        csr.ACMEChain,
 		}
 
 		writeResponse(w, http.StatusOK, "", certificateRequestResponse, env.SystemLogger)
 	}
 }
 
+// GetCertificateRequestCertificate receives an id as a path parameter, and
+// returns the certificate chain of the corresponding Certificate Request as a file.
+// The format is negotiated with the "format" query parameter or the Accept header,
+// and the root certificate can be left out with "include_root=false".
+func GetCertificateRequestCertificate(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, headerErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if headerErr != nil {
+			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(headerErr))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		id := r.PathValue("id")
+		idNum, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		format, err := negotiateCertificateFormat(r)
+		if err != nil {
+			writeResponse(w, http.StatusNotAcceptable, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		includeRoot, err := includeRootParam(r)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid include_root value", nil, env.SystemLogger)
+			return
+		}
+
+		csr, err := env.Database.GetCertificateRequestAndChain(db.ByCSRID(idNum))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get certificate request", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		// Restrict access to certificate requestors' own requests
+		if RoleID(claims.RoleID) == RoleCertificateRequestor && claims.Email != csr.UserEmail {
+			env.SystemLogger.Warn("certificate request access denied", zap.String("requester_email", claims.Email), zap.String("owner_email", csr.UserEmail), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusForbidden, "access denied", nil, env.SystemLogger)
+			return
+		}
+
+		_, err = env.Database.GetCertificateAuthority(db.ByCertificateAuthorityCSRID(csr.CSR_ID))
+		if rowFound(err) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return
+		}
+		if realError(err) {
+			env.SystemLogger.Error("failed to check whether certificate request belongs to a certificate authority", zap.Error(err), zap.Int64("csr_id", csr.CSR_ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		if csr.CertificateChain == "" {
+			writeResponse(w, http.StatusNotFound, "certificate not found", nil, env.SystemLogger)
+			return
+		}
+
+		body, err := encodeCertificateChain(csr.CertificateChain, format, includeRoot)
+		if err != nil {
+			env.SystemLogger.Error("failed to encode certificate chain", zap.Error(err), zap.Int64("csr_id", csr.CSR_ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeCertificateDownload(w, body, format, fmt.Sprintf("certificate-%d", csr.CSR_ID), env.SystemLogger)
+	}
+}
+
 // DeleteCertificateRequest handler receives an id as a path parameter,
 // deletes the corresponding Certificate Request, and returns a http.StatusNoContent on success
 func DeleteCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
@@ -254,7 +613,7 @@ func DeleteCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -312,7 +671,7 @@ func PostCertificateRequestCertificate(env *HandlerDependencies) http.HandlerFun
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -367,7 +726,7 @@ func RejectCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -422,7 +781,7 @@ func DeleteCertificate(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -465,9 +824,17 @@ func DeleteCertificate(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
-// RevokeCertificate handler receives an id as a path parameter,
-// and attempts to revoke the corresponding certificate request by adding the certificate to the CRL
-// It returns a 200 OK on success
+type RevokeCertificateParams struct {
+	Reason string `json:"reason"`
+}
+
+// errACMERevocationFailed is returned when the ACME server that issued a certificate doesn't revoke it.
+var errACMERevocationFailed = errors.New("the ACME server failed to revoke the certificate")
+
+// RevokeCertificate handler receives an id as a path parameter and an optional revocation reason,
+// and attempts to revoke the corresponding certificate. Certificates issued by a Notary CA are added to its CRL,
+// and certificates obtained via ACME are revoked by the ACME server that issued them.
+// It returns a 202 Accepted on success
 func RevokeCertificate(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
@@ -476,8 +843,19 @@ func RevokeCertificate(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
 			return
 		}
+		// The body is optional, a certificate is revoked without a reason if it is empty.
+		var params RevokeCertificateParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		reason, err := notaryacme.ParseRevocationReason(params.Reason)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid revocation reason", nil, env.SystemLogger)
+			return
+		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -494,7 +872,14 @@ func RevokeCertificate(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
-		err = env.Database.RevokeCertificate(db.ByCSRID(idNum))
+		csr, err := env.Database.GetCertificateRequest(db.ByCSRID(idNum))
+		if err == nil {
+			if csr.SigningMethod == db.SigningMethodACME {
+				err = revokeACMECertificate(env, csr, reason)
+			} else {
+				err = env.Database.RevokeCertificateWithReason(db.ByCSRID(idNum), int(reason))
+			}
+		}
 		if err != nil {
 			if errors.Is(err, db.ErrNotFound) {
 				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
@@ -504,15 +889,21 @@ func RevokeCertificate(env *HandlerDependencies) http.HandlerFunc {
 				writeResponse(w, http.StatusUnprocessableEntity, err.Error(), nil, env.SystemLogger)
 				return
 			}
+			if errors.Is(err, errACMERevocationFailed) {
+				env.SystemLogger.Warn("ACME server failed to revoke certificate", zap.Error(err), zap.Int64("csr_id", idNum))
+				writeResponse(w, http.StatusBadGateway, errACMERevocationFailed.Error(), nil, env.SystemLogger)
+				return
+			}
 			env.SystemLogger.Error("failed to revoke certificate", zap.Error(err), zap.Int64("csr_id", idNum))
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
 
-		env.AuditLogger.CertificateRevoked(id,
-			log.WithActor(claims.Email),
-			log.WithRequest(r),
-		)
+		opts := []log.AuditOption{log.WithActor(claims.Email), log.WithRequest(r)}
+		if params.Reason != "" {
+			opts = append(opts, log.WithReason(params.Reason))
+		}
+		env.AuditLogger.CertificateRevoked(id, opts...)
 
 		if env.ShouldEnablePebbleNotifications {
 			err := SendPebbleNotification(CertificateUpdate, idNum)
@@ -524,8 +915,35 @@ func RevokeCertificate(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+// revokeACMECertificate asks the ACME server that issued the certificate of csr to revoke it,
+// with the account that ordered it, and then marks the certificate as revoked.
+func revokeACMECertificate(env *HandlerDependencies, csr *db.CertificateRequest, reason uint) error {
+	if csr.ACMEAccountID == nil {
+		return fmt.Errorf("%w: the ACME account that issued the certificate is unknown", db.ErrInvalidInput)
+	}
+	csrWithChain, err := env.Database.GetCertificateRequestAndChain(db.ByCSRID(csr.CSR_ID))
+	if err != nil {
+		return err
+	}
+	if csrWithChain.CertificateChain == "" {
+		return fmt.Errorf("%w: no certificate to revoke with associated CSR", db.ErrInvalidInput)
+	}
+	account, err := env.Database.GetDecryptedACMEAccount(*csr.ACMEAccountID)
+	if errors.Is(err, db.ErrNotFound) {
+		return fmt.Errorf("%w: the ACME account that issued the certificate no longer exists", db.ErrInvalidInput)
+	}
+	if err != nil {
+		return err
+	}
+	if err := notaryacme.RevokeCertificate(account, csrWithChain.CertificateChain, reason); err != nil {
+		return fmt.Errorf("%w: %w", errACMERevocationFailed, err)
+	}
+	return env.Database.MarkCertificateRevoked(db.ByCSRID(csr.CSR_ID))
+}
+
 // SignCertificateRequest handler signs a certificate request available in Notary using either a
 // certificate authority ("ca") or ACME ("acme") signing method.
+// ACME orders can take minutes to complete, so they are run as a background job whose ID is returned.
 // It returns a 202 Accepted on success.
 func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -541,7 +959,7 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
-		claims, cookieErr := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if cookieErr != nil {
 			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
@@ -562,6 +980,15 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 		if signCertificateRequestParams.SigningMethod == "" {
 			signCertificateRequestParams.SigningMethod = "ca"
 		}
+		order := db.ACMEOrderOptions{PreferredChain: signCertificateRequestParams.ACMEPreferredChain, Profile: signCertificateRequestParams.ACMEProfile}
+		if order != (db.ACMEOrderOptions{}) && signCertificateRequestParams.SigningMethod != "acme" {
+			writeResponse(w, http.StatusBadRequest, "acme_preferred_chain and acme_profile can only be used with the acme signing method", nil, env.SystemLogger)
+			return
+		}
+		if err := notaryacme.ValidateOrderOptions(order); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid acme_preferred_chain or acme_profile: "+err.Error(), nil, env.SystemLogger)
+			return
+		}
 
 		switch signCertificateRequestParams.SigningMethod {
 		case "ca":
@@ -570,6 +997,10 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 				writeResponse(w, http.StatusBadRequest, "invalid certificate authority ID", nil, env.SystemLogger)
 				return
 			}
+			if !certificateAuthorityAllowed(r, caIDInt) {
+				writeResponse(w, http.StatusForbidden, "forbidden: certificate authority not allowed for API token", nil, env.SystemLogger)
+				return
+			}
 			err = env.Database.SignCertificateRequest(db.ByCSRID(idNum), db.ByCertificateAuthorityDenormalizedID(caIDInt), env.ExternalHostname)
 			if err != nil {
 				if errors.Is(err, db.ErrNotFound) {
@@ -585,28 +1016,21 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 				log.WithRequest(r),
 			)
 		case "acme":
-			// DNS propagation can take minutes; extend write deadline.
-			rc := http.NewResponseController(w)
-			if err := rc.SetWriteDeadline(time.Now().Add(3 * time.Minute)); err != nil {
-				env.SystemLogger.Warn("failed to extend write deadline for ACME sign", zap.Error(err))
+			if limitedToCertificateAuthorities(r) {
+				writeResponse(w, http.StatusForbidden, "forbidden: API token limited to certificate authorities", nil, env.SystemLogger)
+				return
 			}
-			activeServer, err := env.Database.GetDecryptedActiveACMEServer()
+			routes, err := env.Database.ListACMERoutes()
 			if err != nil {
-				if errors.Is(err, db.ErrNotFound) {
-					writeResponse(w, http.StatusServiceUnavailable, "ACME is not configured", nil, env.SystemLogger)
-					return
-				}
-				env.SystemLogger.Error("failed to get active ACME server", zap.Error(err))
+				env.SystemLogger.Error("failed to list ACME routes", zap.Error(err))
 				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 				return
 			}
-			var envVars map[string]string
-			if err := json.Unmarshal([]byte(activeServer.EnvVars), &envVars); err != nil {
-				env.SystemLogger.Error("failed to decode ACME server env vars", zap.Error(err))
-				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			if len(routes) == 0 {
+				writeResponse(w, http.StatusServiceUnavailable, "ACME is not configured", nil, env.SystemLogger)
 				return
 			}
-			csr, err := env.Database.GetCertificateRequestAndChain(db.ByCSRID(idNum))
+			csr, err := env.Database.GetCertificateRequest(db.ByCSRID(idNum))
 			if err != nil {
 				if errors.Is(err, db.ErrNotFound) {
 					writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
@@ -616,23 +1040,41 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 				return
 			}
-			acmeRepo := notaryacme.NewACMERepository(activeServer.ID, activeServer.Email, activeServer.DirectoryURL, activeServer.DNSProvider, envVars, env.Database)
-			certChain, err := acmeRepo.SignCSR(csr.CSR)
+			// The job routes the request again, this only rejects requests that can't be routed before queueing them.
+			if _, err := notaryacme.RouteCSR(routes, csr.CSR); err != nil {
+				writeResponse(w, http.StatusUnprocessableEntity, err.Error(), nil, env.SystemLogger)
+				return
+			}
+			payload, err := json.Marshal(acmeSignJobPayload{
+				CertificateRequestID: idNum,
+				PreferredChain:       signCertificateRequestParams.ACMEPreferredChain,
+				Profile:              signCertificateRequestParams.ACMEProfile,
+			})
 			if err != nil {
-				env.SystemLogger.Error("failed to sign certificate request via ACME", zap.Error(err), zap.Int64("csr_id", idNum))
+				env.SystemLogger.Error("failed to encode ACME signing job", zap.Error(err))
 				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 				return
 			}
-			_, err = env.Database.AddCertificateChainToCertificateRequest(db.ByCSRID(idNum), certChain)
+			// Signing the same request twice would place a second order with the ACME server.
+			pendingJob, err := env.Database.GetPendingJob(acmeSignJobType, string(payload))
+			if rowFound(err) {
+				writeResponse(w, http.StatusAccepted, "", SignCertificateRequestResponse{JobID: pendingJob.ID}, env.SystemLogger)
+				return
+			}
+			if realError(err) {
+				env.SystemLogger.Error("failed to check for a pending ACME signing job", zap.Error(err), zap.Int64("csr_id", idNum))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+			jobID, err := env.JobRunner.Enqueue(acmeSignJobType, string(payload), acmeSignJobMaxAttempts, claims.Email)
 			if err != nil {
-				env.SystemLogger.Error("failed to store ACME certificate chain", zap.Error(err), zap.Int64("csr_id", idNum))
+				env.SystemLogger.Error("failed to queue ACME signing job", zap.Error(err), zap.Int64("csr_id", idNum))
 				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 				return
 			}
-			env.AuditLogger.CertificateSigned(id, "acme",
-				log.WithActor(claims.Email),
-				log.WithRequest(r),
-			)
+			// The certificate is stored, audited and notified about by the job once the order completes.
+			writeResponse(w, http.StatusAccepted, "", SignCertificateRequestResponse{JobID: jobID}, env.SystemLogger)
+			return
 		default:
 			writeResponse(w, http.StatusBadRequest, "invalid signing_method: must be 'ca' or 'acme'", nil, env.SystemLogger)
 			return
@@ -648,6 +1090,161 @@ func SignCertificateRequest(env *HandlerDependencies) http.HandlerFunc {
 	}
 }
 
+type UpdateAutoRenewParams struct {
+	Enabled bool `json:"enabled"`
+}
+
+// UpdateCertificateRequestRenewal opts a certificate request in or out of automatic renewal.
+// It returns a 200 OK on success
+func UpdateCertificateRequestRenewal(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		idNum, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		var params UpdateAutoRenewParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+
+		claims, cookieErr := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if cookieErr != nil {
+			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(cookieErr))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		// The certificates of certificate authorities are not renewed automatically.
+		_, err = env.Database.GetCertificateAuthority(db.ByCertificateAuthorityCSRID(idNum))
+		if rowFound(err) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return
+		}
+		if realError(err) {
+			env.SystemLogger.Error("failed to check whether certificate request belongs to a certificate authority", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		err = env.Database.SetCertificateRequestAutoRenew(db.ByCSRID(idNum), params.Enabled)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to update certificate request renewal", zap.Error(err), zap.Int64("csr_id", idNum))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.AutoRenewalUpdated("certificate_request", id, params.Enabled,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+		writeResponse(w, http.StatusOK, "", nil, env.SystemLogger)
+	}
+}
+
+const (
+	acmeSignJobType        = "acme_sign"
+	acmeSignJobMaxAttempts = 3
+)
+
+type SignCertificateRequestResponse struct {
+	JobID int64 `json:"job_id,omitempty"`
+}
+
+type acmeSignJobPayload struct {
+	CertificateRequestID int64  `json:"certificate_request_id"`
+	PreferredChain       string `json:"preferred_chain,omitempty"`
+	Profile              string `json:"profile,omitempty"`
+}
+
+// acmeSignJob places an order for a certificate request with the ACME server that the ACME routes
+// send it to, and stores the issued certificate chain.
+func acmeSignJob(env *HandlerDependencies) jobs.Handler {
+	return func(ctx context.Context, job *db.Job) error {
+		var payload acmeSignJobPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return jobs.Permanent(fmt.Errorf("invalid job payload: %w", err))
+		}
+		csr, err := env.Database.GetCertificateRequestAndChain(db.ByCSRID(payload.CertificateRequestID))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return jobs.Permanent(errors.New("certificate request not found"))
+			}
+			return fmt.Errorf("failed to get certificate request: %w", err)
+		}
+		acmeRepo, err := routedACMERepository(env, csr.CSR)
+		if err != nil {
+			return err
+		}
+		cert, err := acmeRepo.SignCSR(ctx, csr.CSR, db.ACMEOrderOptions{PreferredChain: payload.PreferredChain, Profile: payload.Profile})
+		if err != nil {
+			return fmt.Errorf("failed to sign certificate request via ACME: %w", err)
+		}
+		// A cancellation that arrives once the certificate is issued still keeps it from being stored.
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+		_, err = env.Database.AddCertificateChainToCertificateRequest(db.ByCSRID(payload.CertificateRequestID), cert.Chain)
+		if err != nil {
+			return fmt.Errorf("failed to store ACME certificate chain: %w", err)
+		}
+		// Renewals and revocations go back to the same ACME server and account, so they are recorded with the chain.
+		err = env.Database.SetCertificateRequestACMEIssuer(db.ByCSRID(payload.CertificateRequestID), cert.ServerID, cert.AccountID, cert.Order, cert.ChainIssuer)
+		if err != nil {
+			return fmt.Errorf("failed to record ACME issuer: %w", err)
+		}
+		env.AuditLogger.CertificateSigned(strconv.FormatInt(payload.CertificateRequestID, 10), "acme",
+			log.WithActor(job.CreatedBy),
+		)
+
+		if env.ShouldEnablePebbleNotifications {
+			err := SendPebbleNotification(CertificateUpdate, payload.CertificateRequestID)
+			if err != nil {
+				env.SystemLogger.Warn("pebble notify failed", zap.Error(err))
+			}
+		}
+		return nil
+	}
+}
+
+// routedACMERepository creates an ACMERepository for the ACME server that the ACME routes send the certificate request to.
+// Its errors are meant to be returned by jobs.
+func routedACMERepository(env *HandlerDependencies, csrPEM string) (*notaryacme.ACMERepository, error) {
+	routes, err := env.Database.ListACMERoutes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ACME routes: %w", err)
+	}
+	if len(routes) == 0 {
+		return nil, jobs.Permanent(errors.New("ACME is not configured"))
+	}
+	serverID, err := notaryacme.RouteCSR(routes, csrPEM)
+	if err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	return acmeRepositoryForServer(env, serverID)
+}
+
+// acmeRepositoryForServer creates an ACMERepository for the ACME server with the given ID. Its errors are meant to be returned by jobs.
+func acmeRepositoryForServer(env *HandlerDependencies, serverID int64) (*notaryacme.ACMERepository, error) {
+	server, err := env.Database.GetDecryptedACMEServer(serverID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, jobs.Permanent(fmt.Errorf("ACME server %d not found", serverID))
+		}
+		return nil, fmt.Errorf("failed to get ACME server: %w", err)
+	}
+	var envVars map[string]string
+	if err := json.Unmarshal([]byte(server.EnvVars), &envVars); err != nil {
+		return nil, jobs.Permanent(fmt.Errorf("failed to decode ACME server env vars: %w", err))
+	}
+	return notaryacme.NewACMERepository(server, envVars, env.Database), nil
+}
+
 func realError(err error) bool {
 	return err != nil && !errors.Is(err, db.ErrNotFound)
 }
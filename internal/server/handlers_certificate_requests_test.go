@@ -1,12 +1,21 @@
 package server_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/canonical/notary/internal/db"
 	"github.com/canonical/notary/internal/server"
 	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/smallstep/pkcs7"
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // This is an end-to-end test for the certificate requests endpoint.
@@ -630,7 +639,7 @@ func TestSignCertificateRequestSigningMethodBranching(t *testing.T) {
 	})
 
 	t.Run("signing_method=acme with no ACMERepository returns 503", func(t *testing.T) {
-		// The default test DB has no active ACME server, so GetDecryptedActiveACMEServer returns ErrNotFound → 503.
+		// The default test DB has no active ACME server, so GetActiveACMEServer returns ErrNotFound → 503.
 		statusCode, response, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{
 			SigningMethod: "acme",
 		})
@@ -645,6 +654,38 @@ func TestSignCertificateRequestSigningMethodBranching(t *testing.T) {
 		}
 	})
 
+	t.Run("acme options with signing_method=ca return 400", func(t *testing.T) {
+		statusCode, response, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{
+			SigningMethod: "ca",
+			ACMEProfile:   "shortlived",
+		})
+		if err != nil {
+			t.Fatalf("SignCertificateRequest() error: %v", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		if !strings.Contains(response.Message, "acme signing method") {
+			t.Fatalf("unexpected message: %q", response.Message)
+		}
+	})
+
+	t.Run("invalid acme_profile returns 400", func(t *testing.T) {
+		statusCode, response, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{
+			SigningMethod: "acme",
+			ACMEProfile:   "short lived",
+		})
+		if err != nil {
+			t.Fatalf("SignCertificateRequest() error: %v", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		if !strings.Contains(response.Message, "acme_profile") {
+			t.Fatalf("unexpected message: %q", response.Message)
+		}
+	})
+
 	t.Run("empty signing_method defaults to ca behaviour", func(t *testing.T) {
 		// With no CertificateAuthorityID, the CA signing path returns 400 (invalid CA ID).
 		// This confirms the default "ca" branch is taken, not the "acme" or unknown branch.
@@ -702,3 +743,513 @@ func TestRevokeNonCACertificateReturns422(t *testing.T) {
 		t.Fatal("expected non-empty error message in response body, got empty string")
 	}
 }
+
+func TestRevokeACMECertificate(t *testing.T) {
+	httpPort, tlsPort := tu.MustGetFreePort(t), tu.MustGetFreePort(t)
+	directoryURL := tu.MustStartPebble(t, httpPort, tlsPort)
+	ts, logs := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, created, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+		Name:          "pebble",
+		DirectoryURL:  directoryURL,
+		Email:         "ops@example.com",
+		ChallengeType: db.ACMEChallengeHTTP01,
+		ChallengePort: int64(httpPort),
+		EnvVars:       map[string]string{},
+	})
+	if err != nil {
+		t.Fatalf("CreateACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, created.Message)
+	}
+	statusCode, _, err = tu.SetActiveACMEServer(ts.URL, client, adminToken, int(created.Data.ID))
+	if err != nil {
+		t.Fatalf("SetActiveACMEServer() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	// Notary compares the keys of CSRs and certificates as RSA keys.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %v", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "localhost"},
+		DNSNames: []string{"localhost"},
+	}, key)
+	if err != nil {
+		t.Fatalf("couldn't create CSR: %v", err)
+	}
+	csrPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}))
+	statusCode, _, err = tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: csrPEM})
+	if err != nil {
+		t.Fatalf("CreateCertificateRequest() error: %v", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+	}
+	statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+	if err != nil {
+		t.Fatalf("SignCertificateRequest() error: %v", err)
+	}
+	if statusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+	}
+	mustWaitForRenewal(t, ts.URL, client, adminToken, 1, "")
+
+	t.Run("invalid revocation reason", func(t *testing.T) {
+		statusCode, response, err := tu.RevokeCertificateRequestWithReason(ts.URL, client, adminToken, 1, "certificateHold")
+		if err != nil {
+			t.Fatalf("RevokeCertificateRequestWithReason() error: %v", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		if response.Message != "invalid revocation reason" {
+			t.Fatalf("expected message %q, got %q", "invalid revocation reason", response.Message)
+		}
+	})
+
+	t.Run("revoke through the ACME server", func(t *testing.T) {
+		statusCode, response, err := tu.RevokeCertificateRequestWithReason(ts.URL, client, adminToken, 1, "keyCompromise")
+		if err != nil {
+			t.Fatalf("RevokeCertificateRequestWithReason() error: %v", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, statusCode, response.Message)
+		}
+		_, getResponse, err := tu.GetCertificateRequest(ts.URL, client, adminToken, 1)
+		if err != nil {
+			t.Fatalf("GetCertificateRequest() error: %v", err)
+		}
+		if getResponse.Data.Status != "Revoked" || getResponse.Data.CertificateChain != "" {
+			t.Fatalf("expected a revoked certificate request without certificate, got status %q", getResponse.Data.Status)
+		}
+		var haveRevoked bool
+		for _, e := range logs.All() {
+			if findStringField(e, "event") == "cert_revoked" && findStringField(e, "reason") == "keyCompromise" {
+				haveRevoked = true
+				break
+			}
+		}
+		if !haveRevoked {
+			t.Fatal("expected a cert_revoked audit entry with the revocation reason")
+		}
+	})
+
+	t.Run("revoke a revoked certificate", func(t *testing.T) {
+		statusCode, _, err := tu.RevokeCertificateRequest(ts.URL, client, adminToken, 1)
+		if err != nil {
+			t.Fatalf("RevokeCertificateRequest() error: %v", err)
+		}
+		if statusCode != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, statusCode)
+		}
+	})
+}
+
+// This is an end-to-end test for the certificate download endpoints.
+// The order of the tests is important, as some tests depend on the
+// state of the server after previous tests.
+func TestCertificateDownloadsEndToEnd(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	t.Run("1. Create self signed certificate authority and sign a certificate request", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{
+			SelfSigned:    true,
+			CommonName:    "Self Signed CA",
+			NotValidAfter: "2030-01-01T00:00:00Z",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, _, err = tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: tu.AppleCSR})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, adminToken, 2, server.SignCertificateRequestParams{CertificateAuthorityID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	})
+
+	t.Run("2. Download certificate chain as PEM by default", func(t *testing.T) {
+		statusCode, header, body, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if header.Get("Content-Type") != "application/pem-certificate-chain" {
+			t.Fatalf("expected PEM content type, got %s", header.Get("Content-Type"))
+		}
+		if !strings.Contains(header.Get("Content-Disposition"), "certificate-2.pem") {
+			t.Fatalf("expected certificate-2.pem filename, got %s", header.Get("Content-Disposition"))
+		}
+		certs, err := db.ParseCertificateChain(string(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certs) != 2 {
+			t.Fatalf("expected 2 certificates, got %d", len(certs))
+		}
+	})
+
+	t.Run("3. Download certificate chain as PEM without the root", func(t *testing.T) {
+		statusCode, _, body, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "include_root=false", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		certs, err := db.ParseCertificateChain(string(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(certs) != 1 {
+			t.Fatalf("expected 1 certificate, got %d", len(certs))
+		}
+		if certs[0].IsCA {
+			t.Fatalf("expected the leaf certificate, got a CA certificate")
+		}
+	})
+
+	t.Run("4. Download leaf certificate as DER with the format parameter", func(t *testing.T) {
+		statusCode, header, body, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "format=der", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if header.Get("Content-Type") != "application/pkix-cert" {
+			t.Fatalf("expected DER content type, got %s", header.Get("Content-Type"))
+		}
+		cert, err := x509.ParseCertificate(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.IsCA {
+			t.Fatalf("expected the leaf certificate, got a CA certificate")
+		}
+	})
+
+	t.Run("5. Download certificate chain as PKCS#7 with the Accept header", func(t *testing.T) {
+		statusCode, header, body, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "", "application/pkcs7-mime")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if !strings.Contains(header.Get("Content-Disposition"), "certificate-2.p7b") {
+			t.Fatalf("expected certificate-2.p7b filename, got %s", header.Get("Content-Disposition"))
+		}
+		p7, err := pkcs7.Parse(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p7.Certificates) != 2 {
+			t.Fatalf("expected 2 certificates, got %d", len(p7.Certificates))
+		}
+	})
+
+	t.Run("6. Download certificate with unsupported format", func(t *testing.T) {
+		statusCode, _, _, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "", "application/json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotAcceptable {
+			t.Fatalf("expected status %d, got %d", http.StatusNotAcceptable, statusCode)
+		}
+		statusCode, _, _, err = tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/2/certificate", "format=pfx", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotAcceptable {
+			t.Fatalf("expected status %d, got %d", http.StatusNotAcceptable, statusCode)
+		}
+	})
+
+	t.Run("7. Download certificate of a certificate request without a certificate", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: tu.BananaCSR})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, _, _, err = tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_requests/3/certificate", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+
+	t.Run("8. Download certificate of another user's request as a certificate requestor", func(t *testing.T) {
+		requestorToken := tu.MustPrepareAccount(t, ts, "requestor@canonical.com", tu.RoleCertificateRequestor, adminToken)
+		statusCode, _, _, err := tu.DownloadCertificate(ts.URL, client, requestorToken, "/certificate_requests/2/certificate", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("9. Download certificate authority chain", func(t *testing.T) {
+		statusCode, header, body, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_authorities/1/chain", "format=p7b", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if !strings.Contains(header.Get("Content-Disposition"), "ca-1-chain.p7b") {
+			t.Fatalf("expected ca-1-chain.p7b filename, got %s", header.Get("Content-Disposition"))
+		}
+		p7, err := pkcs7.Parse(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p7.Certificates) != 1 {
+			t.Fatalf("expected 1 certificate, got %d", len(p7.Certificates))
+		}
+		if !p7.Certificates[0].IsCA {
+			t.Fatalf("expected a CA certificate")
+		}
+	})
+
+	t.Run("10. Download chain of a certificate authority that does not exist", func(t *testing.T) {
+		statusCode, _, _, err := tu.DownloadCertificate(ts.URL, client, adminToken, "/certificate_authorities/10/chain", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
+
+// This is an end-to-end test for certificate requests with a private key generated by Notary.
+// The order of the tests is important, as some tests depend on the
+// state of the server after previous tests.
+func TestGeneratedCertificateRequestsEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	requestorToken := tu.MustPrepareAccount(t, ts, "requestor@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	client := ts.Client()
+	password := "correct-horse"
+
+	t.Run("1. Create self signed certificate authority", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{
+			SelfSigned:    true,
+			CommonName:    "Self Signed CA",
+			NotValidAfter: "2030-01-01T00:00:00Z",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+	})
+
+	t.Run("2. Generate certificate request", func(t *testing.T) {
+		statusCode, generateResponse, err := tu.GenerateCertificateRequest(ts.URL, client, requestorToken, tu.GenerateCertificateRequestParams{
+			CommonName:  "device.example.com",
+			SANsDNS:     "device.example.com, device.internal",
+			CountryName: "GB",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		if generateResponse.Data.ID != 2 {
+			t.Fatalf("expected id 2, got %d", generateResponse.Data.ID)
+		}
+		statusCode, getResponse, err := tu.GetCertificateRequest(ts.URL, client, requestorToken, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		block, _ := pem.Decode([]byte(getResponse.Data.CSR))
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if csr.Subject.CommonName != "device.example.com" {
+			t.Fatalf("expected common name device.example.com, got %s", csr.Subject.CommonName)
+		}
+		if len(csr.DNSNames) != 2 || csr.DNSNames[1] != "device.internal" {
+			t.Fatalf("expected 2 DNS SANs, got %v", csr.DNSNames)
+		}
+	})
+
+	t.Run("3. Collect PKCS#12 before the request is signed", func(t *testing.T) {
+		statusCode, _, err := tu.GetCertificateRequestPKCS12(ts.URL, client, requestorToken, 2, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+	})
+
+	t.Run("4. Sign the generated certificate request", func(t *testing.T) {
+		statusCode, _, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 2, server.SignCertificateRequestParams{CertificateAuthorityID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	})
+
+	t.Run("5. Collect PKCS#12 with a short password", func(t *testing.T) {
+		statusCode, _, err := tu.GetCertificateRequestPKCS12(ts.URL, client, requestorToken, 2, "short")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+	})
+
+	t.Run("6. Collect PKCS#12 as another user", func(t *testing.T) {
+		statusCode, _, err := tu.GetCertificateRequestPKCS12(ts.URL, client, adminToken, 2, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("7. Collect PKCS#12", func(t *testing.T) {
+		_ = logs.TakeAll()
+		statusCode, body, err := tu.GetCertificateRequestPKCS12(ts.URL, client, requestorToken, 2, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		key, cert, caCerts, err := pkcs12.DecodeChain(body, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			t.Fatalf("expected an RSA private key")
+		}
+		if !rsaKey.PublicKey.Equal(cert.PublicKey) {
+			t.Fatalf("expected the private key to match the certificate")
+		}
+		if len(caCerts) != 1 {
+			t.Fatalf("expected 1 CA certificate, got %d", len(caCerts))
+		}
+		var haveDelivered bool
+		for _, e := range logs.TakeAll() {
+			if e.LoggerName == "audit" && findStringField(e, "event") == "cert_private_key_delivered" {
+				haveDelivered = true
+				break
+			}
+		}
+		if !haveDelivered {
+			t.Fatalf("expected PrivateKeyDelivered audit entry")
+		}
+	})
+
+	t.Run("8. Collect PKCS#12 a second time", func(t *testing.T) {
+		statusCode, _, err := tu.GetCertificateRequestPKCS12(ts.URL, client, requestorToken, 2, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusGone {
+			t.Fatalf("expected status %d, got %d", http.StatusGone, statusCode)
+		}
+	})
+
+	t.Run("9. Collect PKCS#12 for a request without a generated key", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateRequest(ts.URL, client, requestorToken, tu.CreateCertificateRequestParams{CSR: tu.AppleCSR})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, _, err = tu.GetCertificateRequestPKCS12(ts.URL, client, requestorToken, 3, password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
+
+func TestGenerateCertificateRequestInvalidInputs(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	tests := []struct {
+		testName string
+		params   tu.GenerateCertificateRequestParams
+		error    string
+	}{
+		{
+			testName: "No common name or SANs",
+			params:   tu.GenerateCertificateRequestParams{OrganizationName: "Canonical"},
+			error:    "invalid request: common_name or sans_dns is required",
+		},
+		{
+			testName: "Invalid country name",
+			params:   tu.GenerateCertificateRequestParams{CommonName: "example.com", CountryName: "Turkey"},
+			error:    "invalid request: country_name must be a 2-letter ISO code",
+		},
+		{
+			testName: "Invalid DNS SAN",
+			params:   tu.GenerateCertificateRequestParams{SANsDNS: "example.com, not a name"},
+			error:    "invalid request: invalid DNS name in sans_dns: not a name",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.testName, func(t *testing.T) {
+			statusCode, generateResponse, err := tu.GenerateCertificateRequest(ts.URL, client, adminToken, test.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+			}
+			if generateResponse.Message != test.error {
+				t.Fatalf("expected error %s, got %s", test.error, generateResponse.Message)
+			}
+		})
+	}
+}
@@ -1,10 +1,8 @@
 package server
 
 import (
-	"errors"
 	"net/http"
 
-	"github.com/canonical/notary/internal/db"
 	"go.uber.org/zap"
 )
 
@@ -22,12 +20,24 @@ func GetConfigContent(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var acmeEnabled bool
 		var acmeServerName string
-		activeServer, acmeErr := env.Database.GetActiveACMEServer()
-		if acmeErr == nil && activeServer != nil {
+		routes, acmeErr := env.Database.ListACMERoutes()
+		if acmeErr != nil {
+			env.SystemLogger.Error("failed to list ACME routes", zap.Error(acmeErr))
+		}
+		for _, route := range routes {
 			acmeEnabled = true
-			acmeServerName = activeServer.Name
-		} else if acmeErr != nil && !errors.Is(acmeErr, db.ErrNotFound) {
-			env.SystemLogger.Error("failed to query active ACME server", zap.Error(acmeErr))
+			// The name shown is the one of the ACME server that receives the requests no other route matches.
+			if route.DomainSuffix != "" {
+				continue
+			}
+			server, err := env.Database.GetACMEServer(route.ACMEServerID)
+			if realError(err) {
+				env.SystemLogger.Error("failed to get ACME server", zap.Error(err), zap.Int64("id", route.ACMEServerID))
+			}
+			if rowFound(err) {
+				acmeServerName = server.Name
+			}
+			break
 		}
 		configContent := GetConfigContentResponse{
 			Port:                  env.Port,
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type JobResponse struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      string          `json:"status"`
+	Attempts    int64           `json:"attempts"`
+	MaxAttempts int64           `json:"max_attempts"`
+	LastError   string          `json:"last_error"`
+	RunAfter    string          `json:"run_after"`
+	CreatedBy   string          `json:"created_by,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// dbJobToResponse converts a job to its response. The payload and the account that created the job
+// are only included when detailed is set, as they name certificate requests and accounts of other users.
+func dbJobToResponse(j *db.Job, detailed bool) JobResponse {
+	resp := JobResponse{
+		ID:          j.ID,
+		Type:        j.Type,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		LastError:   j.LastError,
+		RunAfter:    time.Unix(j.RunAfter, 0).UTC().Format(time.RFC3339),
+		CreatedAt:   time.Unix(j.CreatedAt, 0).UTC().Format(time.RFC3339),
+		UpdatedAt:   time.Unix(j.UpdatedAt, 0).UTC().Format(time.RFC3339),
+	}
+	if !detailed {
+		return resp
+	}
+	resp.CreatedBy = j.CreatedBy
+	if json.Valid([]byte(j.Payload)) {
+		resp.Payload = json.RawMessage(j.Payload)
+	}
+	return resp
+}
+
+// jobDetailsAllowed returns whether the caller can see the payload of jobs and who created them, which only admins can.
+func jobDetailsAllowed(r *http.Request, env *HandlerDependencies) bool {
+	claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+	return err == nil && RoleID(claims.RoleID) == RoleAdmin
+}
+
+// ListJobs handler returns every background job.
+// It returns a 200 OK on success
+func ListJobs(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := env.Database.ListJobs()
+		if err != nil {
+			env.SystemLogger.Error("failed to list jobs", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		detailed := jobDetailsAllowed(r, env)
+		resp := make([]JobResponse, 0, len(jobs))
+		for i := range jobs {
+			resp = append(resp, dbJobToResponse(&jobs[i], detailed))
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
+
+// GetJob handler returns the status of a background job.
+// It returns a 200 OK on success
+func GetJob(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		job, err := env.Database.GetJob(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get job", zap.Error(err), zap.Int64("job_id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusOK, "", dbJobToResponse(job, jobDetailsAllowed(r, env)), env.SystemLogger)
+	}
+}
+
+// CancelJob handler cancels a queued or running background job.
+// It returns a 202 Accepted on success, and a 409 Conflict if the job is already finished.
+func CancelJob(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Warn("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		job, err := env.Database.GetJob(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get job", zap.Error(err), zap.Int64("job_id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		err = env.JobRunner.Cancel(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusConflict, "job is already finished", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to cancel job", zap.Error(err), zap.Int64("job_id", id))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.JobCancelled(strconv.FormatInt(id, 10), job.Type,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
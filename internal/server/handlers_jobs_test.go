@@ -0,0 +1,222 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+// This is an end-to-end test for the background job endpoints, driven by ACME signing.
+// The ACME server is unreachable, so the signing job keeps failing and waits to be retried.
+// The order of the tests is important, as some tests depend on the
+// state of the server after previous tests.
+func TestJobsEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	requestorToken := tu.MustPrepareAccount(t, ts, "requestor@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	client := ts.Client()
+
+	t.Run("1. Prepare a certificate request and an active ACME server", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: tu.AppleCSR})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, resp, err := tu.CreateACMEServer(ts.URL, client, adminToken, tu.CreateACMEServerParams{
+			Name:         "Unreachable",
+			DirectoryURL: "https://127.0.0.1:1/directory",
+			Email:        "admin@example.com",
+			DNSProvider:  "cloudflare",
+			EnvVars:      map[string]string{"CF_DNS_API_TOKEN": "test-token"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		statusCode, _, err = tu.SetActiveACMEServer(ts.URL, client, adminToken, int(resp.Data.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+	})
+
+	var jobID int64
+	t.Run("2. Signing with ACME queues a job", func(t *testing.T) {
+		statusCode, resp, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, statusCode, resp.Message)
+		}
+		if resp.Data.JobID == 0 {
+			t.Fatalf("expected a job ID")
+		}
+		jobID = resp.Data.JobID
+	})
+
+	t.Run("3. Signing again returns the pending job", func(t *testing.T) {
+		statusCode, resp, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 1, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		if resp.Data.JobID != jobID {
+			t.Fatalf("expected job %d, got %d", jobID, resp.Data.JobID)
+		}
+	})
+
+	t.Run("4. Signing a missing certificate request with ACME returns 404", func(t *testing.T) {
+		statusCode, _, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 100, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+
+	t.Run("5. The failed attempt is recorded and the job waits to be retried", func(t *testing.T) {
+		deadline := time.Now().Add(30 * time.Second)
+		for {
+			statusCode, resp, err := tu.GetJob(ts.URL, client, readerToken, jobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+			}
+			job := resp.Data
+			if job.Attempts == 1 && job.LastError != "" {
+				if job.Status != "queued" || job.Type != "acme_sign" || job.MaxAttempts != 3 {
+					t.Fatalf("unexpected job %+v", job)
+				}
+				if job.Payload != nil || job.CreatedBy != "" {
+					t.Fatalf("expected the payload and creator to be hidden from readers, got %+v", job)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the job to have been attempted, got %+v", job)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+
+	t.Run("6. Admins see the payload and creator of jobs", func(t *testing.T) {
+		statusCode, resp, err := tu.GetJob(ts.URL, client, adminToken, jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if resp.Data.CreatedBy != "admin@canonical.com" {
+			t.Fatalf("unexpected job creator %q", resp.Data.CreatedBy)
+		}
+		var payload map[string]int64
+		if err := json.Unmarshal(resp.Data.Payload, &payload); err != nil || payload["certificate_request_id"] != 1 {
+			t.Fatalf("unexpected job payload %s", resp.Data.Payload)
+		}
+	})
+
+	t.Run("7. List jobs", func(t *testing.T) {
+		statusCode, resp, err := tu.ListJobs(ts.URL, client, readerToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].ID != jobID || resp.Data[0].Payload != nil || resp.Data[0].CreatedBy != "" {
+			t.Fatalf("expected only job %d without its payload and creator, got %+v", jobID, resp.Data)
+		}
+		statusCode, _, err = tu.ListJobs(ts.URL, client, requestorToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("8. Readers can't cancel jobs", func(t *testing.T) {
+		statusCode, _, err := tu.CancelJob(ts.URL, client, readerToken, jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("9. Cancel the job", func(t *testing.T) {
+		_ = logs.TakeAll()
+		statusCode, _, err := tu.CancelJob(ts.URL, client, adminToken, jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		_, resp, err := tu.GetJob(ts.URL, client, adminToken, jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Data.Status != "cancelled" {
+			t.Fatalf("expected the job to be cancelled, got %s", resp.Data.Status)
+		}
+		found := false
+		for _, e := range logs.TakeAll() {
+			if findStringField(e, "event") == "job_cancelled" && findStringField(e, "actor") == "admin@canonical.com" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected job_cancelled audit event")
+		}
+	})
+
+	t.Run("10. Cancelling a finished job returns 409", func(t *testing.T) {
+		statusCode, resp, err := tu.CancelJob(ts.URL, client, adminToken, jobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+		if resp.Message != "job is already finished" {
+			t.Fatalf("unexpected message %q", resp.Message)
+		}
+	})
+
+	t.Run("11. Missing and invalid jobs", func(t *testing.T) {
+		statusCode, _, err := tu.GetJob(ts.URL, client, adminToken, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+		statusCode, _, err = tu.CancelJob(ts.URL, client, adminToken, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
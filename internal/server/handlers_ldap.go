@@ -0,0 +1,131 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+// loginLDAP logs in the user with the username and password of their LDAP directory entry. The account of the user
+// is provisioned on their first login, and its role follows their groups on every login when they are mapped to roles.
+// The attempt was reserved by the login, and is marked failed when the credentials are invalid.
+func loginLDAP(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, attempt *loginAttempt, username, password string) {
+	identity, err := env.LDAPRepository.Authenticate(username, password)
+	if err != nil {
+		if !errors.Is(err, authentication.ErrLDAPInvalidCredentials) {
+			env.SystemLogger.Error("failed to authenticate against LDAP directory during login", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.LoginFailed(username,
+			log.WithRequest(r),
+			log.WithReason("invalid credentials"),
+		)
+		attempt.failed = true
+		writeResponse(w, http.StatusUnauthorized, "invalid credentials", nil, env.SystemLogger)
+		return
+	}
+
+	var mappedRole *db.RoleID
+	if mapping := env.LDAPRepository.RoleMapping; mapping != nil {
+		role, ok := mapping.RoleOf(identity.Groups)
+		if !ok {
+			env.AuditLogger.LoginFailed(username,
+				log.WithRequest(r),
+				log.WithReason("no LDAP group is mapped to a role"),
+			)
+			writeResponse(w, http.StatusForbidden, "forbidden: no role is mapped to your identity", nil, env.SystemLogger)
+			return
+		}
+		mappedRole = &role
+	}
+
+	user, err := env.Database.GetUser(db.ByLDAPDN(identity.DN))
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) {
+			env.SystemLogger.Error("failed to get LDAP user during login", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		var ok bool
+		user, ok = provisionLDAPUser(w, r, env, identity, mappedRole)
+		if !ok {
+			return
+		}
+	} else if mappedRole != nil && user.RoleID != *mappedRole {
+		if err := syncMappedRole(r, env, user, *mappedRole, "the LDAP groups"); err != nil {
+			env.SystemLogger.Error("failed to update the role of LDAP user", zap.Error(err), zap.Int64("user_id", user.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+	}
+
+	// The users who enabled multi-factor authentication complete their LDAP login with a code, like local users.
+	challenged, err := writeMFAChallenge(w, env, user)
+	if err != nil {
+		env.SystemLogger.Error("failed to start multi-factor authentication during LDAP login", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	if challenged {
+		return
+	}
+	if err := startSession(w, r, env, user, db.SessionAuthMethodLDAP); err != nil {
+		env.SystemLogger.Error("failed to start session during LDAP login", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	clearLoginFailures(env, username)
+	env.AuditLogger.TokenCreated(user.Email, log.WithRequest(r))
+	env.AuditLogger.LoginSuccess(user.Email, log.WithRequest(r))
+	writeResponse(w, http.StatusOK, "", nil, env.SystemLogger)
+}
+
+// provisionLDAPUser creates the account of a user that logs in from the LDAP directory for the first time, with
+// their mapped role, or the read-only role when the groups aren't mapped. It writes the error response when it fails.
+func provisionLDAPUser(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, identity *authentication.LDAPIdentity, mappedRole *db.RoleID) (*db.User, bool) {
+	// Accounts that already have the email of the user aren't taken over by the directory.
+	if _, err := env.Database.GetUser(db.ByEmail(identity.Email)); err == nil {
+		env.SystemLogger.Warn("LDAP login attempted with email that matches an existing account",
+			zap.String("email", identity.Email),
+			zap.String("dn", identity.DN))
+		env.AuditLogger.LoginFailed(identity.Email,
+			log.WithRequest(r),
+			log.WithReason("email already registered to another account"),
+		)
+		writeResponse(w, http.StatusConflict, "email already registered to another account", nil, env.SystemLogger)
+		return nil, false
+	} else if !errors.Is(err, db.ErrNotFound) && !errors.Is(err, db.ErrInvalidFilter) {
+		env.SystemLogger.Error("failed to get user during LDAP login", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+
+	role := db.RoleReadOnly
+	if mappedRole != nil {
+		role = *mappedRole
+	}
+	user, err := env.Database.CreateLDAPUser(identity.Email, identity.DN, role)
+	if err != nil {
+		env.SystemLogger.Error("failed to create LDAP user", zap.Error(err), zap.String("dn", identity.DN))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+	if env.AuthzRepository != nil {
+		userID := authorization.UserID(user.Email)
+		if err := env.AuthzRepository.WriteTuple("system:notary", RoleIDToRelation(role), userID); err != nil {
+			env.SystemLogger.Error("failed to write role tuple for LDAP user", zap.Error(err), zap.String("user", userID))
+		}
+	}
+	env.SystemLogger.Info("LDAP user auto-provisioned",
+		zap.String("email", user.Email),
+		zap.String("dn", identity.DN),
+		zap.Int("role_id", int(role)))
+	env.AuditLogger.UserCreated(user.Email, int(role), log.WithRequest(r))
+	return user, true
+}
@@ -0,0 +1,178 @@
+package server_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+const (
+	ldapAdminsDN   = "cn=notary-admins,ou=groups,dc=example,dc=com"
+	ldapManagersDN = "cn=pki-team,ou=groups,dc=example,dc=com"
+)
+
+func mustPrepareLDAPServer(t *testing.T) (*tu.LDAPServer, *authentication.LDAPRepository) {
+	t.Helper()
+	directory := tu.MustStartLDAPServer(t,
+		tu.LDAPEntry{DN: "uid=alice,ou=people,dc=example,dc=com", Password: "alice-password", Attributes: map[string][]string{
+			"mail":     {"alice@example.com"},
+			"memberOf": {ldapManagersDN},
+		}},
+		tu.LDAPEntry{DN: "uid=bob,ou=people,dc=example,dc=com", Password: "bob-password", Attributes: map[string][]string{
+			"mail": {"bob@example.com"},
+		}},
+		tu.LDAPEntry{DN: "uid=admin,ou=people,dc=example,dc=com", Password: "admin-password", Attributes: map[string][]string{
+			"mail":     {"admin@canonical.com"},
+			"memberOf": {ldapAdminsDN},
+		}},
+	)
+	repo := &authentication.LDAPRepository{
+		URL:            directory.URL,
+		UserDNTemplate: "uid={username},ou=people,dc=example,dc=com",
+		EmailAttribute: "mail",
+		GroupAttribute: "memberOf",
+		RoleMapping: &authentication.RoleMapping{
+			Roles: map[string]db.RoleID{
+				ldapAdminsDN:   db.RoleAdmin,
+				ldapManagersDN: db.RoleCertificateManager,
+			},
+		},
+	}
+	return directory, repo
+}
+
+func TestLDAPLogin(t *testing.T) {
+	_, repo := mustPrepareLDAPServer(t)
+	var database *db.DatabaseRepository
+	ts, logs := tu.MustPrepareServer(t, tu.WithLDAP(repo), tu.WithDatabase(&database))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+
+	t.Run("1. First login provisions the account with the mapped role", func(t *testing.T) {
+		token := mustLogin(t, ts.URL, client, "alice", "alice-password")
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if resp.Data.Email != "alice@example.com" || resp.Data.RoleID != int(tu.RoleCertificateManager) ||
+			resp.Data.HasPassword || !slices.Equal(resp.Data.AuthMethods, []string{"ldap"}) {
+			t.Fatalf("expected an LDAP certificate manager account, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("2. Later logins reuse the account", func(t *testing.T) {
+		mustLogin(t, ts.URL, client, "alice", "alice-password")
+		numUsers, err := database.NumUsers()
+		if err != nil {
+			t.Fatalf("couldn't count users: %s", err)
+		}
+		if numUsers != 3 {
+			t.Fatalf("expected 3 users, got %d", numUsers)
+		}
+	})
+
+	t.Run("3. Role follows the groups of the user", func(t *testing.T) {
+		user, err := database.GetUser(db.ByLDAPDN("uid=alice,ou=people,dc=example,dc=com"))
+		if err != nil {
+			t.Fatalf("couldn't get user: %s", err)
+		}
+		if err := database.UpdateUserRole(db.ByUserID(user.ID), db.RoleReadOnly); err != nil {
+			t.Fatalf("couldn't update role: %s", err)
+		}
+		token := mustLogin(t, ts.URL, client, "alice", "alice-password")
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		if statusCode != http.StatusOK || resp.Data.RoleID != int(tu.RoleCertificateManager) {
+			t.Fatalf("expected the mapped role to be restored, got status %d and %+v", statusCode, resp.Data)
+		}
+	})
+
+	t.Run("4. Users without a mapped group are rejected", func(t *testing.T) {
+		statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "bob", Password: "bob-password"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("5. Wrong passwords are rejected", func(t *testing.T) {
+		_ = logs.TakeAll()
+		statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "alice", Password: "bob-password"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+		if !hasEvent(logs, "authn_login_fail:alice") {
+			t.Fatalf("expected an audit event for the failed login")
+		}
+	})
+
+	t.Run("6. Local accounts log in with their local password", func(t *testing.T) {
+		mustLogin(t, ts.URL, client, "admin@canonical.com", "Admin123")
+		statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "admin@canonical.com", Password: "admin-password"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+	})
+
+	t.Run("7. Directory users don't take over local accounts", func(t *testing.T) {
+		statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "admin", Password: "admin-password"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+		expectStatus(t, ts.URL, client, adminToken, http.StatusOK)
+	})
+}
+
+func TestLDAPLoginMFARequired(t *testing.T) {
+	_, repo := mustPrepareLDAPServer(t)
+	ts, _ := tu.MustPrepareServer(t, tu.WithLDAP(repo), tu.WithMFARequired(db.RoleCertificateManager))
+	client := ts.Client()
+
+	token := mustLogin(t, ts.URL, client, "alice", "alice-password")
+	statusCode, _, err := tu.ListCertificateRequests(ts.URL, client, token)
+	if err != nil {
+		t.Fatalf("couldn't list certificate requests: %s", err)
+	}
+	if statusCode != http.StatusForbidden {
+		t.Fatalf("expected an LDAP manager without MFA to be denied with status %d, got %d", http.StatusForbidden, statusCode)
+	}
+
+	secret, _ := mustEnrollTOTP(t, ts.URL, client, token)
+	mfaToken := mustStartMFALogin(t, ts.URL, client, "alice", "alice-password")
+	code := totpCode(t, secret, authentication.TOTPStep(time.Now())+1)
+	statusCode, mfaSession, err := tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: code})
+	if err != nil {
+		t.Fatalf("couldn't complete login: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	statusCode, _, err = tu.ListCertificateRequests(ts.URL, client, mfaSession)
+	if err != nil {
+		t.Fatalf("couldn't list certificate requests: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected the LDAP manager that completed MFA to be allowed with status %d, got %d", http.StatusOK, statusCode)
+	}
+}
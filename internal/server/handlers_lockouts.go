@@ -0,0 +1,214 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type LoginLockoutResponse struct {
+	Scope       string `json:"scope"`
+	Identifier  string `json:"identifier"`
+	Failures    int64  `json:"failures"`
+	LockedUntil int64  `json:"locked_until"`
+}
+
+// loginIdentifier returns the identifier of the IP address of the client of the request,
+// which its failed login attempts are counted with.
+func loginIdentifier(r *http.Request) string {
+	addr, err := remoteAddr(r)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return addr.String()
+}
+
+// loginMaxAttempts returns how many failed login attempts lock out an account or an IP address, or 0 when they aren't locked out.
+func loginMaxAttempts(env *HandlerDependencies, scope string) int {
+	if env.AppConfig == nil {
+		return 0
+	}
+	if scope == db.LoginFailureScopeIP {
+		return env.LoginMaxAttemptsPerIP
+	}
+	return env.LoginMaxAttempts
+}
+
+// lockoutDuration returns how long a number of failed attempts lock out an account or an IP address for. The first lockout lasts
+// LoginLockoutDuration, and every further failure doubles it, up to LoginMaxLockoutDuration.
+func lockoutDuration(env *HandlerDependencies, failures int64, maxAttempts int) time.Duration {
+	if failures < int64(maxAttempts) {
+		return 0
+	}
+	d := env.LoginLockoutDuration
+	for i := int64(maxAttempts); i < failures && d < env.LoginMaxLockoutDuration; i++ {
+		d *= 2
+	}
+	return min(d, env.LoginMaxLockoutDuration)
+}
+
+// loginAttempt is a login attempt that reserveLoginAttempt counted as a failure of the account and of the IP address
+// of the request before its credentials are checked, so that concurrent attempts can't all get past the lockout.
+type loginAttempt struct {
+	email        string
+	reservations []*db.LoginFailure
+	// failed is set when the credentials of the attempt are invalid, so that settle keeps it counted.
+	failed bool
+}
+
+// reserveLoginAttempt counts a login attempt of the account with email, from the IP address of the request, unless
+// either is locked out. It returns the attempt, which must be settled once its credentials are checked. When they are
+// locked out, it writes the response to the attempt and returns false.
+func reserveLoginAttempt(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, email string) (*loginAttempt, bool) {
+	now := time.Now()
+	attempt := &loginAttempt{email: email}
+	for _, scope := range []db.LoginFailure{{Scope: db.LoginFailureScopeAccount, Identifier: email}, {Scope: db.LoginFailureScopeIP, Identifier: loginIdentifier(r)}} {
+		maxAttempts := loginMaxAttempts(env, scope.Scope)
+		if maxAttempts == 0 {
+			continue
+		}
+		// The failures are forgotten once the longest lockout has passed since the last one, and the attempt that
+		// reaches the maximum holds the shortest lockout while it runs.
+		reservation, err := env.Database.ReserveLoginAttempt(scope.Scope, scope.Identifier, now, now.Add(-env.LoginMaxLockoutDuration), maxAttempts, now.Add(env.LoginLockoutDuration))
+		if err == nil {
+			attempt.reservations = append(attempt.reservations, reservation)
+			continue
+		}
+		attempt.release(env)
+		if !errors.Is(err, db.ErrLoginLocked) {
+			env.SystemLogger.Error("failed to reserve login attempt", zap.Error(err), zap.String("scope", scope.Scope))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return nil, false
+		}
+		env.AuditLogger.LoginFailed(email,
+			log.WithRequest(r),
+			log.WithReason("locked out after too many failed attempts"),
+		)
+		if failure, err := env.Database.GetLoginFailure(scope.Scope, scope.Identifier); err == nil {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(time.Unix(failure.LockedUntil, 0).Sub(now).Seconds())+1, 10))
+		}
+		writeResponse(w, http.StatusTooManyRequests, "too many failed login attempts, try again later", nil, env.SystemLogger)
+		return nil, false
+	}
+	return attempt, true
+}
+
+// settle keeps the attempt counted as a failure when its credentials were invalid, and locks out the account and the
+// IP address when they have too many. Otherwise, it uncounts the attempt. Failures that can't be recorded are only
+// logged, so that the login fails the same way.
+func (a *loginAttempt) settle(env *HandlerDependencies, r *http.Request) {
+	if !a.failed {
+		a.release(env)
+		return
+	}
+	now := time.Now()
+	for _, reservation := range a.reservations {
+		d := lockoutDuration(env, reservation.Failures, loginMaxAttempts(env, reservation.Scope))
+		if d == 0 {
+			continue
+		}
+		if err := env.Database.LockLogin(reservation.Scope, reservation.Identifier, now.Add(d)); err != nil {
+			env.SystemLogger.Error("failed to lock out login", zap.Error(err), zap.String("scope", reservation.Scope))
+			continue
+		}
+		env.AuditLogger.LoginFailed(a.email,
+			log.WithRequest(r),
+			log.WithReason(fmt.Sprintf("%s %s locked out for %s after %d failed attempts", reservation.Scope, reservation.Identifier, d, reservation.Failures)),
+		)
+	}
+}
+
+// release uncounts the reservations of the attempt. The account ones may already be cleared by a successful login.
+func (a *loginAttempt) release(env *HandlerDependencies) {
+	for _, reservation := range a.reservations {
+		if err := env.Database.ReleaseLoginAttempt(reservation); err != nil && !errors.Is(err, db.ErrNotFound) {
+			env.SystemLogger.Error("failed to release login attempt", zap.Error(err), zap.String("scope", reservation.Scope))
+		}
+	}
+}
+
+// clearLoginFailures forgets the failed login attempts of the account with email once it logged in.
+// The failures of the IP address are kept, so that logging in to an account doesn't allow guessing the passwords of others.
+func clearLoginFailures(env *HandlerDependencies, email string) {
+	if loginMaxAttempts(env, db.LoginFailureScopeAccount) == 0 {
+		return
+	}
+	if err := env.Database.DeleteLoginFailure(db.LoginFailureScopeAccount, email); err != nil && !errors.Is(err, db.ErrNotFound) {
+		env.SystemLogger.Error("failed to clear login failures", zap.Error(err))
+	}
+}
+
+// ListLoginLockouts handler returns the accounts and IP addresses that are locked out after too many failed login attempts.
+func ListLoginLockouts(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failures, err := env.Database.ListLockedLoginFailures(time.Now())
+		if err != nil {
+			env.SystemLogger.Error("failed to list login lockouts", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		lockouts := make([]LoginLockoutResponse, 0, len(failures))
+		for _, failure := range failures {
+			lockouts = append(lockouts, LoginLockoutResponse{
+				Scope:       failure.Scope,
+				Identifier:  failure.Identifier,
+				Failures:    failure.Failures,
+				LockedUntil: failure.LockedUntil,
+			})
+		}
+		writeResponse(w, http.StatusOK, "", lockouts, env.SystemLogger)
+	}
+}
+
+// UnlockAccountLogin handler lifts the lockout of an account after too many failed login attempts.
+func UnlockAccountLogin(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		unlockLogin(w, r, env, db.LoginFailureScopeAccount, account.Email)
+	}
+}
+
+// UnlockIPAddressLogin handler lifts the lockout of an IP address after too many failed login attempts.
+func UnlockIPAddressLogin(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, err := netip.ParseAddr(r.PathValue("address"))
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid IP address", nil, env.SystemLogger)
+			return
+		}
+		unlockLogin(w, r, env, db.LoginFailureScopeIP, addr.String())
+	}
+}
+
+func unlockLogin(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, scope, identifier string) {
+	claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+	if err != nil {
+		env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+		writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+		return
+	}
+	if err := env.Database.DeleteLoginFailure(scope, identifier); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return
+		}
+		env.SystemLogger.Error("failed to unlock login", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	env.AuditLogger.LoginUnlocked(scope, identifier,
+		log.WithActor(claims.Email),
+		log.WithRequest(r),
+	)
+	writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+}
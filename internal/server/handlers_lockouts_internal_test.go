@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/config"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	env := &HandlerDependencies{AppConfig: &config.AppConfig{
+		LoginLockoutDuration:    time.Minute,
+		LoginMaxLockoutDuration: 10 * time.Minute,
+	}}
+	cases := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := lockoutDuration(env, tc.failures, 5); got != tc.want {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
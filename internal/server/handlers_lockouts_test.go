@@ -0,0 +1,194 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func mustFailLogin(t *testing.T, url string, client *http.Client, email string, expected int) {
+	t.Helper()
+	statusCode, _, err := tu.Login(url, client, &tu.LoginParams{Email: email, Password: "wrong-password"})
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if statusCode != expected {
+		t.Fatalf("expected status %d, got %d", expected, statusCode)
+	}
+}
+
+func TestAccountLockout(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t, tu.WithLockout(3, 0, time.Minute, time.Hour))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	const userID = 3
+
+	t.Run("1. Failed attempts lock out the account", func(t *testing.T) {
+		_ = logs.TakeAll()
+		for range 3 {
+			mustFailLogin(t, ts.URL, client, "user@canonical.com", http.StatusUnauthorized)
+		}
+		statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "user@canonical.com", Password: "Admin123"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected a locked out account to be rejected with status %d, got %d", http.StatusTooManyRequests, statusCode)
+		}
+		mustLogin(t, ts.URL, client, "admin@canonical.com", "Admin123")
+
+		lockedOut := false
+		for _, e := range logs.All() {
+			if findStringField(e, "event") == "authn_login_fail:user@canonical.com" &&
+				strings.Contains(findStringField(e, "reason"), "locked out for 1m0s after 3 failed attempts") {
+				lockedOut = true
+			}
+		}
+		if !lockedOut {
+			t.Fatalf("expected an audit event for the lockout")
+		}
+	})
+
+	t.Run("2. Admin lists the lockouts", func(t *testing.T) {
+		statusCode, _, err := tu.ListLoginLockouts(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't list lockouts: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, resp, err := tu.ListLoginLockouts(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't list lockouts: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].Scope != "account" || resp.Data[0].Identifier != "user@canonical.com" || resp.Data[0].Failures != 3 {
+			t.Fatalf("unexpected lockouts %+v", resp.Data)
+		}
+		if resp.Data[0].LockedUntil <= time.Now().Unix() {
+			t.Fatalf("expected the lockout not to have ended, got %d", resp.Data[0].LockedUntil)
+		}
+	})
+
+	t.Run("3. Admin unlocks the account", func(t *testing.T) {
+		statusCode, err := tu.UnlockAccountLogin(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't unlock account: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		if !hasEvent(logs, "authn_login_unlocked:account,user@canonical.com") {
+			t.Fatalf("expected an audit event for the unlock")
+		}
+
+		statusCode, err = tu.UnlockAccountLogin(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't unlock account: %s", err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+
+	t.Run("4. A successful login forgets the failed attempts", func(t *testing.T) {
+		for range 2 {
+			mustFailLogin(t, ts.URL, client, "user@canonical.com", http.StatusUnauthorized)
+		}
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		for range 2 {
+			mustFailLogin(t, ts.URL, client, "user@canonical.com", http.StatusUnauthorized)
+		}
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+	})
+
+	t.Run("5. Unknown accounts are locked out too", func(t *testing.T) {
+		for range 3 {
+			mustFailLogin(t, ts.URL, client, "nobody@canonical.com", http.StatusUnauthorized)
+		}
+		mustFailLogin(t, ts.URL, client, "nobody@canonical.com", http.StatusTooManyRequests)
+	})
+}
+
+func TestIPAddressLockout(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithLockout(0, 3, time.Minute, time.Hour))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+
+	for _, email := range []string{"first@canonical.com", "second@canonical.com", "third@canonical.com"} {
+		mustFailLogin(t, ts.URL, client, email, http.StatusUnauthorized)
+	}
+	statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "admin@canonical.com", Password: "Admin123"})
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if statusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected a locked out IP address to be rejected with status %d, got %d", http.StatusTooManyRequests, statusCode)
+	}
+
+	statusCode, err = tu.UnlockIPAddressLogin(ts.URL, client, adminToken, "not-an-address")
+	if err != nil {
+		t.Fatalf("couldn't unlock IP address: %s", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+	}
+	statusCode, err = tu.UnlockIPAddressLogin(ts.URL, client, adminToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("couldn't unlock IP address: %s", err)
+	}
+	if statusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+	}
+	mustLogin(t, ts.URL, client, "admin@canonical.com", "Admin123")
+}
+
+func TestConcurrentLoginLockout(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithLockout(3, 0, time.Minute, time.Hour))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+
+	// The attempts are counted before their passwords are checked, so the ones beyond the maximum are rejected
+	// even when they all start before any has failed.
+	const attempts = 20
+	statusCodes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "user@canonical.com", Password: "wrong-password"})
+			if err != nil {
+				t.Errorf("couldn't log in: %s", err)
+				return
+			}
+			statusCodes <- statusCode
+		}()
+	}
+	wg.Wait()
+	close(statusCodes)
+
+	counts := map[int]int{}
+	for statusCode := range statusCodes {
+		counts[statusCode]++
+	}
+	if counts[http.StatusUnauthorized] != 3 || counts[http.StatusTooManyRequests] != attempts-3 {
+		t.Fatalf("expected 3 attempts to be checked and the others to be locked out, got %v", counts)
+	}
+	statusCode, _, err := tu.Login(ts.URL, client, &tu.LoginParams{Email: "user@canonical.com", Password: "Admin123"})
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if statusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the account to be locked out with status %d, got %d", http.StatusTooManyRequests, statusCode)
+	}
+}
@@ -24,25 +24,50 @@ type LoginParams struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token string `json:"token,omitempty"`
+	// MFARequired is true when the account has multi-factor authentication enabled. The login is then completed
+	// by sending a code along with MFAToken to /login/mfa.
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
 }
 
-// Helper function to generate a JWT
-func generateJWT(id int64, email string, jwtSecret []byte, roleID RoleID) (string, error) {
-	expiresAt := jwt.NewNumericDate(expireAfter())
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, authentication.NotaryJWTClaims{
+// Helper function to generate a JWT that references the session with sessionID
+func generateJWT(id int64, email string, signingKeys *authentication.SigningKeyring, roleID RoleID, sessionID string, expiresAt time.Time) (string, error) {
+	return signingKeys.Sign(authentication.NotaryJWTClaims{
 		Email:  email,
 		RoleID: int(roleID),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: expiresAt,
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	})
-	tokenString, err := token.SignedString(jwtSecret)
+}
+
+// startSession stores a new session of the user, who authenticated with authMethod,
+// and sets the session cookie to a token that references it.
+func startSession(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, user *db.User, authMethod string) error {
+	sessionID, err := authentication.GenerateSessionID()
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	return tokenString, nil
+	expiresAt := expireAfter()
+	if _, err := env.Database.CreateSession(user.ID, sessionID, authMethod, r.RemoteAddr, r.UserAgent(), expiresAt); err != nil {
+		return err
+	}
+	jwt, err := generateJWT(user.ID, user.Email, env.SigningKeys, RoleID(user.RoleID), sessionID, expiresAt)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieSessionTokenKey,
+		Value:    jwt,
+		HttpOnly: true,
+		Secure:   true,
+		Expires:  time.Now().Add(2 * time.Hour),
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
 }
 
 func Login(env *HandlerDependencies) http.HandlerFunc {
@@ -60,6 +85,11 @@ func Login(env *HandlerDependencies) http.HandlerFunc {
 			writeResponse(w, http.StatusBadRequest, "password is required", nil, env.SystemLogger)
 			return
 		}
+		attempt, ok := reserveLoginAttempt(w, r, env, loginParams.Email)
+		if !ok {
+			return
+		}
+		defer attempt.settle(env, r)
 		userAccount, err := env.Database.GetUser(db.ByEmail(loginParams.Email))
 		if err != nil {
 			if !errors.Is(err, db.ErrNotFound) && !errors.Is(err, db.ErrInvalidFilter) {
@@ -68,6 +98,11 @@ func Login(env *HandlerDependencies) http.HandlerFunc {
 				return
 			}
 		}
+		// The users without a local password log in from the LDAP directory, when there is one.
+		if env.LDAPRepository != nil && (userAccount == nil || !userAccount.HasPassword()) {
+			loginLDAP(w, r, env, attempt, loginParams.Email, loginParams.Password)
+			return
+		}
 		hashedPassword := ""
 		if userAccount != nil && userAccount.HashedPassword != nil {
 			hashedPassword = *userAccount.HashedPassword
@@ -77,38 +112,46 @@ func Login(env *HandlerDependencies) http.HandlerFunc {
 				log.WithRequest(r),
 				log.WithReason("invalid credentials"),
 			)
+			attempt.failed = true
 			writeResponse(w, http.StatusUnauthorized, "invalid credentials", nil, env.SystemLogger)
 			return
 		}
-		jwt, err := generateJWT(userAccount.ID, userAccount.Email, env.Database.JWTSecret, RoleID(userAccount.RoleID))
+		challenged, err := writeMFAChallenge(w, env, userAccount)
 		if err != nil {
-			env.SystemLogger.Error("failed to generate JWT during login", zap.Error(err))
+			env.SystemLogger.Error("failed to start multi-factor authentication during login", zap.Error(err))
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
-		http.SetCookie(w, &http.Cookie{
-			Name:     CookieSessionTokenKey,
-			Value:    jwt,
-			HttpOnly: true,
-			Secure:   true,
-			Expires:  time.Now().Add(2 * time.Hour),
-			Path:     "/",
-			SameSite: http.SameSiteStrictMode,
-		})
+		if challenged {
+			return
+		}
+		if err := startSession(w, r, env, userAccount, db.SessionAuthMethodPassword); err != nil {
+			env.SystemLogger.Error("failed to start session during login", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		clearLoginFailures(env, userAccount.Email)
 		env.AuditLogger.TokenCreated(userAccount.Email, log.WithRequest(r))
 		env.AuditLogger.LoginSuccess(userAccount.Email, log.WithRequest(r))
 		writeResponse(w, http.StatusOK, "", nil, env.SystemLogger)
 	}
 }
 
-// Expire both cookies if logging out
+// Delete the session and expire the cookie if logging out
 func Logout(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract user identity before expiring the cookie
 		var username string
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
 		if err == nil {
 			username = claims.Email
+			if claims.Provider == authentication.ProviderLocal && claims.ID != "" {
+				if err := env.Database.DeleteSessionBySessionID(claims.ID); err != nil && !errors.Is(err, db.ErrNotFound) {
+					env.SystemLogger.Error("failed to delete session during logout", zap.Error(err))
+					writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+					return
+				}
+			}
 		}
 
 		http.SetCookie(w, &http.Cookie{
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// mfaTokenAudience is the audience of the tokens that stand for a login waiting for its second factor,
+// so that they can't be mistaken for session tokens, nor the other way around.
+const mfaTokenAudience = "notary-mfa"
+
+// mfaTokenLifetime is how long a user has to send the code of their second factor after their password.
+const mfaTokenLifetime = 5 * time.Minute
+
+// totpIssuer is the name that authenticator apps show for the TOTP secrets of Notary.
+const totpIssuer = "Notary"
+
+// The ways a user can pass multi-factor authentication.
+const (
+	mfaMethodTOTP         = "totp"
+	mfaMethodRecoveryCode = "recovery_code"
+)
+
+// mfaEnrollmentRoutes are the routes that the sessions of users who must enroll in multi-factor authentication are limited to.
+var mfaEnrollmentRoutes = []string{
+	"GET /accounts/me",
+	"GET /accounts/me/mfa",
+	"POST /accounts/me/mfa/totp",
+	"POST /accounts/me/mfa/totp/verify",
+}
+
+type LoginMFAParams struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type MFACodeParams struct {
+	Code string `json:"code"`
+}
+
+type MFAStatusResponse struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+	// Required is true when the role of the account requires multi-factor authentication.
+	Required bool `json:"required"`
+	// EnrollmentRequired is true when the session of the request can only enroll a TOTP authenticator.
+	EnrollmentRequired bool `json:"enrollment_required"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// generateMFAToken returns a short-lived token that stands for the login of email, until they send the code of their second factor.
+func generateMFAToken(email string, signingKeys *authentication.SigningKeyring) (string, error) {
+	return signingKeys.Sign(jwt.RegisteredClaims{
+		Subject:   email,
+		Audience:  jwt.ClaimStrings{mfaTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenLifetime)),
+	})
+}
+
+// parseMFAToken returns the email of the login that the token stands for, if it is valid.
+func parseMFAToken(raw string, signingKeys *authentication.SigningKeyring) (string, error) {
+	claims := jwt.RegisteredClaims{}
+	_, err := signingKeys.Parse(raw, &claims, jwt.WithAudience(mfaTokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", errors.New("MFA token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// mfaSatisfyingAuthMethods are the authentication methods of the sessions that meet a multi-factor authentication
+// requirement: a login completed with a TOTP or recovery code, or an OIDC login, whose identity provider enforces
+// its own factors. The sessions started with a password, from an LDAP directory or with any other method don't.
+var mfaSatisfyingAuthMethods = []string{db.SessionAuthMethodMFA, db.SessionAuthMethodOIDC}
+
+// mfaAvailable returns whether the user logs in with a password, local or from an LDAP directory, that a TOTP
+// authenticator can be a second factor of.
+func mfaAvailable(user *db.User) bool {
+	return user.HasPassword() || user.HasLDAP()
+}
+
+// mfaRequired returns whether the accounts of the role must use multi-factor authentication.
+func mfaRequired(env *HandlerDependencies, roleID db.RoleID) bool {
+	return env.AppConfig != nil && slices.Contains(env.MFARequiredRoles, roleID)
+}
+
+// mfaEnrollmentRequired returns whether the session of an account of the role is limited to the enrollment of a
+// TOTP authenticator, because the role requires multi-factor authentication and the session didn't meet it.
+// Requests without a session, made with an API token or a client certificate, aren't limited.
+func mfaEnrollmentRequired(env *HandlerDependencies, session *db.Session, roleID db.RoleID) bool {
+	return session != nil && !slices.Contains(mfaSatisfyingAuthMethods, session.AuthMethod) && mfaRequired(env, roleID)
+}
+
+// writeMFAChallenge answers a login whose password is valid with the token to complete it with a TOTP or recovery code,
+// if the user enabled multi-factor authentication. It returns whether it answered, or an error.
+func writeMFAChallenge(w http.ResponseWriter, env *HandlerDependencies, user *db.User) (bool, error) {
+	mfaEnabled, err := env.Database.IsTOTPEnabled(user.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get multi-factor authentication: %w", err)
+	}
+	if !mfaEnabled {
+		return false, nil
+	}
+	mfaToken, err := generateMFAToken(user.Email, env.SigningKeys)
+	if err != nil {
+		return false, fmt.Errorf("failed to generate MFA token: %w", err)
+	}
+	writeResponse(w, http.StatusOK, "", LoginResponse{MFARequired: true, MFAToken: mfaToken}, env.SystemLogger)
+	return true, nil
+}
+
+// verifyMFACode checks code against the enabled TOTP credential of the user, and then against their unused recovery codes.
+// It returns how the user passed, or an error that can be shown to them.
+func verifyMFACode(env *HandlerDependencies, user *db.User, code string) (string, error) {
+	credential, secret, err := env.Database.GetTOTPCredential(user.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", errors.New("multi-factor authentication is not enabled")
+		}
+		return "", err
+	}
+	if !credential.Enabled {
+		return "", errors.New("multi-factor authentication is not enabled")
+	}
+	if step, ok := authentication.ValidateTOTP(secret, code, time.Now()); ok {
+		if err := env.Database.UseTOTPStep(user.ID, step); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return "", errors.New("code was already used")
+			}
+			return "", err
+		}
+		return mfaMethodTOTP, nil
+	}
+	if err := env.Database.UseRecoveryCode(user.ID, authentication.HashRecoveryCode(code)); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return "", errors.New("invalid code")
+		}
+		return "", err
+	}
+	return mfaMethodRecoveryCode, nil
+}
+
+// LoginMFA handler completes the login of a user with multi-factor authentication, with the token returned by Login
+// and either a TOTP code or a recovery code.
+func LoginMFA(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params LoginMFAParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		if params.MFAToken == "" {
+			writeResponse(w, http.StatusBadRequest, "mfa_token is required", nil, env.SystemLogger)
+			return
+		}
+		if params.Code == "" {
+			writeResponse(w, http.StatusBadRequest, "code is required", nil, env.SystemLogger)
+			return
+		}
+		email, err := parseMFAToken(params.MFAToken, env.SigningKeys)
+		if err != nil {
+			env.AuditLogger.MFAFailed("", log.WithRequest(r), log.WithReason("invalid MFA token"))
+			writeResponse(w, http.StatusUnauthorized, "invalid or expired mfa_token", nil, env.SystemLogger)
+			return
+		}
+		attempt, ok := reserveLoginAttempt(w, r, env, email)
+		if !ok {
+			return
+		}
+		defer attempt.settle(env, r)
+		user, err := env.Database.GetUser(db.ByEmail(email))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusUnauthorized, "invalid or expired mfa_token", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get user during MFA login", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		method, err := verifyMFACode(env, user, params.Code)
+		if err != nil {
+			env.AuditLogger.MFAFailed(user.Email, log.WithRequest(r), log.WithReason(err.Error()))
+			attempt.failed = true
+			writeResponse(w, http.StatusUnauthorized, "invalid code", nil, env.SystemLogger)
+			return
+		}
+		if err := startSession(w, r, env, user, db.SessionAuthMethodMFA); err != nil {
+			env.SystemLogger.Error("failed to start session during MFA login", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		clearLoginFailures(env, user.Email)
+		env.AuditLogger.MFASuccess(user.Email, method, log.WithRequest(r))
+		env.AuditLogger.TokenCreated(user.Email, log.WithRequest(r))
+		env.AuditLogger.LoginSuccess(user.Email, log.WithRequest(r))
+		writeResponse(w, http.StatusOK, "", nil, env.SystemLogger)
+	}
+}
+
+// GetMyMFA handler returns the multi-factor authentication status of the account of the request.
+func GetMyMFA(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		enabled, err := env.Database.IsTOTPEnabled(account.ID)
+		if err != nil {
+			env.SystemLogger.Error("failed to get TOTP credential", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		remaining := 0
+		if enabled {
+			remaining, err = env.Database.CountUnusedRecoveryCodes(account.ID)
+			if err != nil {
+				env.SystemLogger.Error("failed to count recovery codes", zap.Error(err))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+		}
+		writeResponse(w, http.StatusOK, "", MFAStatusResponse{
+			TOTPEnabled:            enabled,
+			RecoveryCodesRemaining: remaining,
+			Required:               mfaAvailable(account) && mfaRequired(env, account.RoleID),
+			EnrollmentRequired:     mfaEnrollmentRequired(env, callerSession(r), account.RoleID),
+		}, env.SystemLogger)
+	}
+}
+
+// EnrollTOTP handler starts the TOTP enrollment of the account of the request. It returns a new secret,
+// which is pending until a code of it is sent to VerifyTOTP.
+func EnrollTOTP(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		if !mfaAvailable(account) {
+			writeResponse(w, http.StatusBadRequest, "multi-factor authentication is only available to accounts that log in with a password", nil, env.SystemLogger)
+			return
+		}
+		enabled, err := env.Database.IsTOTPEnabled(account.ID)
+		if err != nil {
+			env.SystemLogger.Error("failed to get TOTP credential", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if enabled {
+			writeResponse(w, http.StatusConflict, "TOTP is already enabled", nil, env.SystemLogger)
+			return
+		}
+		secret, err := authentication.GenerateTOTPSecret()
+		if err != nil {
+			env.SystemLogger.Error("failed to generate TOTP secret", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.SetTOTPSecret(account.ID, secret); err != nil {
+			env.SystemLogger.Error("failed to store TOTP secret", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusCreated, "", EnrollTOTPResponse{
+			Secret: secret,
+			URI:    authentication.TOTPURI(totpIssuer, account.Email, secret),
+		}, env.SystemLogger)
+	}
+}
+
+// VerifyTOTP handler completes the TOTP enrollment of the account of the request with a code of its pending secret.
+// It returns the recovery codes of the account, which are only shown once.
+func VerifyTOTP(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		var params MFACodeParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		credential, secret, err := env.Database.GetTOTPCredential(account.ID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusBadRequest, "TOTP enrollment was not started", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get TOTP credential", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if credential.Enabled {
+			writeResponse(w, http.StatusConflict, "TOTP is already enabled", nil, env.SystemLogger)
+			return
+		}
+		step, valid := authentication.ValidateTOTP(secret, params.Code, time.Now())
+		if !valid {
+			env.AuditLogger.MFAFailed(account.Email, log.WithRequest(r), log.WithReason("invalid code during enrollment"))
+			writeResponse(w, http.StatusBadRequest, "invalid code", nil, env.SystemLogger)
+			return
+		}
+		codes, hashedCodes, err := authentication.GenerateRecoveryCodes()
+		if err != nil {
+			env.SystemLogger.Error("failed to generate recovery codes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.EnableTOTP(account.ID, step, hashedCodes); err != nil {
+			env.SystemLogger.Error("failed to enable TOTP", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		// The user just proved the second factor, so the session they enrolled with no longer needs to be limited.
+		if session := callerSession(r); session != nil {
+			if err := env.Database.UpdateSessionAuthMethod(session.ID, db.SessionAuthMethodMFA); err != nil {
+				env.SystemLogger.Warn("failed to update the authentication method of the session", zap.Error(err))
+			}
+		}
+		env.AuditLogger.MFAEnrolled(account.Email, log.WithRequest(r))
+		writeResponse(w, http.StatusCreated, "", RecoveryCodesResponse{RecoveryCodes: codes}, env.SystemLogger)
+	}
+}
+
+// DisableMyTOTP handler disables the TOTP multi-factor authentication of the account of the request,
+// given a TOTP code or a recovery code. It can't be disabled when the role of the account requires it.
+func DisableMyTOTP(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		var params MFACodeParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		if mfaRequired(env, account.RoleID) {
+			writeResponse(w, http.StatusBadRequest, "multi-factor authentication is required for the role of the account", nil, env.SystemLogger)
+			return
+		}
+		if _, err := verifyMFACode(env, account, params.Code); err != nil {
+			env.AuditLogger.MFAFailed(account.Email, log.WithRequest(r), log.WithReason(err.Error()))
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("couldn't verify code: %s", err), nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.DeleteTOTPCredential(account.ID); err != nil {
+			env.SystemLogger.Error("failed to delete TOTP credential", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.MFADisabled(account.Email, log.WithRequest(r))
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
+
+// RegenerateRecoveryCodes handler replaces the recovery codes of the account of the request, given a TOTP code
+// or a recovery code. The new codes are only shown once.
+func RegenerateRecoveryCodes(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		var params MFACodeParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		if _, err := verifyMFACode(env, account, params.Code); err != nil {
+			env.AuditLogger.MFAFailed(account.Email, log.WithRequest(r), log.WithReason(err.Error()))
+			writeResponse(w, http.StatusBadRequest, fmt.Sprintf("couldn't verify code: %s", err), nil, env.SystemLogger)
+			return
+		}
+		codes, hashedCodes, err := authentication.GenerateRecoveryCodes()
+		if err != nil {
+			env.SystemLogger.Error("failed to generate recovery codes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.ReplaceRecoveryCodes(account.ID, hashedCodes); err != nil {
+			env.SystemLogger.Error("failed to replace recovery codes", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusCreated, "", RecoveryCodesResponse{RecoveryCodes: codes}, env.SystemLogger)
+	}
+}
+
+// ResetAccountMFA handler removes the TOTP multi-factor authentication of an account, for a user who lost their
+// authenticator and recovery codes. The sessions of the account are revoked.
+func ResetAccountMFA(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.DeleteTOTPCredential(account.ID); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to delete TOTP credential", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := revokeSessions(r, env, account, "multi-factor authentication reset"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions after MFA reset", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.MFADisabled(account.Email,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
@@ -0,0 +1,340 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := authentication.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatalf("couldn't compute TOTP code: %s", err)
+	}
+	return code
+}
+
+// mustEnrollTOTP enrolls the account of the token with the code of the current time step, and returns the secret
+// along with the recovery codes.
+func mustEnrollTOTP(t *testing.T, url string, client *http.Client, token string) (string, []string) {
+	t.Helper()
+	statusCode, enrollResp, err := tu.EnrollTOTP(url, client, token)
+	if err != nil {
+		t.Fatalf("couldn't enroll TOTP: %s", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+	}
+	secret := enrollResp.Data.Secret
+	statusCode, verifyResp, err := tu.VerifyTOTP(url, client, token, totpCode(t, secret, authentication.TOTPStep(time.Now())))
+	if err != nil {
+		t.Fatalf("couldn't verify TOTP: %s", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+	}
+	return secret, verifyResp.Data.RecoveryCodes
+}
+
+// mustStartMFALogin logs in with a password, expects to be asked for a second factor, and returns the MFA token.
+func mustStartMFALogin(t *testing.T, url string, client *http.Client, email, password string) string {
+	t.Helper()
+	statusCode, resp, err := tu.Login(url, client, &tu.LoginParams{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	if !resp.Data.MFARequired || resp.Data.MFAToken == "" || resp.Data.Token != "" {
+		t.Fatalf("expected an MFA challenge and no session, got %+v", resp.Data)
+	}
+	return resp.Data.MFAToken
+}
+
+func hasEvent(logs *observer.ObservedLogs, event string) bool {
+	for _, e := range logs.All() {
+		if findStringField(e, "event") == event {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMFAEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	const userID = 3
+
+	var secret string
+	var recoveryCodes []string
+
+	t.Run("1. Enroll TOTP", func(t *testing.T) {
+		statusCode, enrollResp, err := tu.EnrollTOTP(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't enroll TOTP: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		secret = enrollResp.Data.Secret
+		if secret == "" || enrollResp.Data.URI == "" {
+			t.Fatalf("expected a secret and its URI, got %+v", enrollResp.Data)
+		}
+
+		_, mfaResp, err := tu.GetMyMFA(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't get MFA status: %s", err)
+		}
+		if mfaResp.Data.TOTPEnabled {
+			t.Fatalf("expected TOTP not to be enabled before it is verified")
+		}
+
+		statusCode, _, err = tu.VerifyTOTP(ts.URL, client, userToken, "000000")
+		if err != nil {
+			t.Fatalf("couldn't verify TOTP: %s", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+
+		statusCode, verifyResp, err := tu.VerifyTOTP(ts.URL, client, userToken, totpCode(t, secret, authentication.TOTPStep(time.Now())))
+		if err != nil {
+			t.Fatalf("couldn't verify TOTP: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		recoveryCodes = verifyResp.Data.RecoveryCodes
+		if len(recoveryCodes) != 10 {
+			t.Fatalf("expected 10 recovery codes, got %d", len(recoveryCodes))
+		}
+
+		_, mfaResp, err = tu.GetMyMFA(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't get MFA status: %s", err)
+		}
+		if !mfaResp.Data.TOTPEnabled || mfaResp.Data.RecoveryCodesRemaining != 10 || mfaResp.Data.Required {
+			t.Fatalf("unexpected MFA status %+v", mfaResp.Data)
+		}
+		statusCode, _, err = tu.EnrollTOTP(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't enroll TOTP: %s", err)
+		}
+		if statusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, statusCode)
+		}
+		if !hasEvent(logs, "authn_mfa_enrolled:user@canonical.com") {
+			t.Fatalf("expected an audit event for the enrollment")
+		}
+	})
+
+	t.Run("2. Login requires a TOTP code", func(t *testing.T) {
+		_ = logs.TakeAll()
+		mfaToken := mustStartMFALogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		expectStatus(t, ts.URL, client, mfaToken, http.StatusUnauthorized)
+
+		statusCode, _, err := tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: "000000"})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+		if !hasEvent(logs, "authn_mfa_failed:user@canonical.com") {
+			t.Fatalf("expected an audit event for the failed attempt")
+		}
+
+		// The code of the current time step was used during the enrollment, so use the next one.
+		code := totpCode(t, secret, authentication.TOTPStep(time.Now())+1)
+		statusCode, token, err := tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: code})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusOK)
+		if !hasEvent(logs, "authn_mfa_success:user@canonical.com,totp") {
+			t.Fatalf("expected an audit event for the successful attempt")
+		}
+
+		statusCode, _, err = tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: code})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected a replayed code to be rejected with status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+
+		statusCode, _, err = tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: userToken, Code: code})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected a session token to be rejected as MFA token with status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+	})
+
+	t.Run("3. Recovery codes can be used once", func(t *testing.T) {
+		mfaToken := mustStartMFALogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		statusCode, token, err := tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: recoveryCodes[0]})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusOK)
+
+		statusCode, _, err = tu.LoginMFA(ts.URL, client, &tu.LoginMFAParams{MFAToken: mfaToken, Code: recoveryCodes[0]})
+		if err != nil {
+			t.Fatalf("couldn't complete login: %s", err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+
+		_, mfaResp, err := tu.GetMyMFA(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't get MFA status: %s", err)
+		}
+		if mfaResp.Data.RecoveryCodesRemaining != 9 {
+			t.Fatalf("expected 9 recovery codes left, got %d", mfaResp.Data.RecoveryCodesRemaining)
+		}
+	})
+
+	t.Run("4. Regenerate recovery codes", func(t *testing.T) {
+		statusCode, resp, err := tu.RegenerateRecoveryCodes(ts.URL, client, userToken, recoveryCodes[1])
+		if err != nil {
+			t.Fatalf("couldn't regenerate recovery codes: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		if len(resp.Data.RecoveryCodes) != 10 {
+			t.Fatalf("expected 10 recovery codes, got %d", len(resp.Data.RecoveryCodes))
+		}
+		statusCode, _, err = tu.RegenerateRecoveryCodes(ts.URL, client, userToken, recoveryCodes[2])
+		if err != nil {
+			t.Fatalf("couldn't regenerate recovery codes: %s", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected the previous recovery codes to be rejected with status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		recoveryCodes = resp.Data.RecoveryCodes
+	})
+
+	t.Run("5. Disable TOTP", func(t *testing.T) {
+		_ = logs.TakeAll()
+		statusCode, err := tu.DisableTOTP(ts.URL, client, userToken, "000000")
+		if err != nil {
+			t.Fatalf("couldn't disable TOTP: %s", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+		statusCode, err = tu.DisableTOTP(ts.URL, client, userToken, recoveryCodes[0])
+		if err != nil {
+			t.Fatalf("couldn't disable TOTP: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		if !hasEvent(logs, "authn_mfa_disabled:user@canonical.com") {
+			t.Fatalf("expected an audit event for disabling MFA")
+		}
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+	})
+
+	t.Run("6. Admin resets MFA", func(t *testing.T) {
+		mustEnrollTOTP(t, ts.URL, client, userToken)
+		statusCode, err := tu.ResetAccountMFA(ts.URL, client, userToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't reset MFA: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, err = tu.ResetAccountMFA(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't reset MFA: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		expectStatus(t, ts.URL, client, userToken, http.StatusUnauthorized)
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+
+		statusCode, err = tu.ResetAccountMFA(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't reset MFA: %s", err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
+
+func TestMFARequiredRoles(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithMFARequired(db.RoleCertificateManager))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	managerToken := tu.MustPrepareAccount(t, ts, "manager@canonical.com", tu.RoleCertificateManager, adminToken)
+
+	statusCode, _, err := tu.ListCertificateRequests(ts.URL, client, managerToken)
+	if err != nil {
+		t.Fatalf("couldn't list certificate requests: %s", err)
+	}
+	if statusCode != http.StatusForbidden {
+		t.Fatalf("expected a manager without MFA to be denied with status %d, got %d", http.StatusForbidden, statusCode)
+	}
+	expectStatus(t, ts.URL, client, managerToken, http.StatusOK)
+	_, mfaResp, err := tu.GetMyMFA(ts.URL, client, managerToken)
+	if err != nil {
+		t.Fatalf("couldn't get MFA status: %s", err)
+	}
+	if !mfaResp.Data.Required || !mfaResp.Data.EnrollmentRequired {
+		t.Fatalf("expected MFA to be required for the manager, got %+v", mfaResp.Data)
+	}
+
+	secret, _ := mustEnrollTOTP(t, ts.URL, client, managerToken)
+	_, mfaResp, err = tu.GetMyMFA(ts.URL, client, managerToken)
+	if err != nil {
+		t.Fatalf("couldn't get MFA status: %s", err)
+	}
+	if !mfaResp.Data.Required || mfaResp.Data.EnrollmentRequired {
+		t.Fatalf("expected the session that enrolled not to be limited, got %+v", mfaResp.Data)
+	}
+	statusCode, _, err = tu.ListCertificateRequests(ts.URL, client, managerToken)
+	if err != nil {
+		t.Fatalf("couldn't list certificate requests: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected the session that enrolled to be allowed with status %d, got %d", http.StatusOK, statusCode)
+	}
+
+	statusCode, err = tu.DisableTOTP(ts.URL, client, managerToken, totpCode(t, secret, authentication.TOTPStep(time.Now())+1))
+	if err != nil {
+		t.Fatalf("couldn't disable TOTP: %s", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected required MFA not to be disabled, got status %d", statusCode)
+	}
+
+	statusCode, _, err = tu.ListCertificateRequests(ts.URL, client, adminToken)
+	if err != nil {
+		t.Fatalf("couldn't list certificate requests: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected roles without the requirement to be allowed with status %d, got %d", http.StatusOK, statusCode)
+	}
+}
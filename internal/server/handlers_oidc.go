@@ -2,6 +2,7 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -13,6 +14,20 @@ import (
 	"golang.org/x/oauth2"
 )
 
+const (
+	// oidcLinkCookie holds the state of the link flow that the browser started, so that the callback can't be
+	// completed in another browser to link its identity to the account.
+	oidcLinkCookie = "notary_oidc_link"
+	// oidcLinkLifetime is how long the link flow can take, which is the lifetime of its state.
+	oidcLinkLifetime = 5 * time.Minute
+)
+
+type OIDCIdentityResponse struct {
+	AccountID int64  `json:"account_id"`
+	Email     string `json:"email"`
+	Subject   string `json:"subject"`
+}
+
 func LoginOIDC(env *HandlerDependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		state := generateRandomString(32)
@@ -33,7 +48,8 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 		code := r.URL.Query().Get("code")
 		state := r.URL.Query().Get("state")
 
-		if !env.StateStore.Validate(state, r.UserAgent()) {
+		entry, ok := env.StateStore.Consume(state, r.UserAgent())
+		if !ok {
 			env.SystemLogger.Warn("OIDC callback with invalid state",
 				zap.String("state_prefix", state[:min(8, len(state))]+"..."),
 				zap.String("user_agent", r.UserAgent()),
@@ -46,6 +62,10 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
+		if entry.Type == StateTypeLink && !checkOIDCLinkCookie(w, r, env, state) {
+			return
+		}
+
 		env.SystemLogger.Debug("OIDC callback state validated successfully",
 			zap.String("user_agent", r.UserAgent()))
 
@@ -93,6 +113,11 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 			return
 		}
 
+		if entry.Type == StateTypeLink {
+			completeOIDCLink(w, r, env, *entry.UserID, sub)
+			return
+		}
+
 		// Extract email using configured claim key
 		email, _ := allClaims[env.AuthnRepository.EmailClaimKey].(string)
 
@@ -110,6 +135,21 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 			zap.String("email_claim_key", env.AuthnRepository.EmailClaimKey),
 			zap.Any("all_claims", allClaims))
 
+		// When the claims are mapped to roles, the role of the user follows the identity provider on every login.
+		var mappedRole *db.RoleID
+		if mapping := env.AuthnRepository.RoleMapping; mapping != nil {
+			role, ok := mapping.Role(allClaims)
+			if !ok {
+				env.AuditLogger.OIDCLoginFailed("no role mapped from the identity provider claims",
+					log.WithRequest(r),
+					log.WithReason(fmt.Sprintf("no value of claim %q is mapped to a role", mapping.ClaimKey)),
+				)
+				writeResponse(w, http.StatusForbidden, "forbidden: no role is mapped to your identity", nil, env.SystemLogger)
+				return
+			}
+			mappedRole = &role
+		}
+
 		// Try to find existing user by OIDC subject
 		user, err := env.Database.GetUser(db.ByOIDCSubject(sub))
 		if err != nil {
@@ -137,9 +177,9 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 	<p>This email address is already associated with a local account.</p>
 	<p>To use OIDC authentication with this account:</p>
 	<ol>
-		<li>Login with your local password</li>
-		<li>Navigate to Account Settings</li>
-		<li>Click "Link OIDC Account"</li>
+		<li>Log in with your local password.</li>
+		<li>In the same browser, open <a href="/api/v1/accounts/me/oidc/link">/api/v1/accounts/me/oidc/link</a>, which redirects to your identity provider.</li>
+		<li>Log in to your identity provider. Its identity is then linked to your account, and you can log in with OIDC.</li>
 	</ol>
 	<a href="/login">Return to Login</a>
 </body>
@@ -169,7 +209,10 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 			}
 			role := db.RoleReadOnly
 			ofgaRelation := RoleNameReader
-			if numUsers == 0 {
+			if mappedRole != nil {
+				role = *mappedRole
+				ofgaRelation = RoleIDToRelation(role)
+			} else if numUsers == 0 {
 				role = db.RoleAdmin
 				ofgaRelation = RoleNameAdmin
 				env.SystemLogger.Info("First user in system — granting admin role via OIDC",
@@ -204,30 +247,215 @@ func CallbackOIDC(env *HandlerDependencies) http.HandlerFunc {
 				zap.Int64("user_id", user.ID),
 				zap.Int("role_id", int(role)))
 			env.AuditLogger.UserCreated(emailOrPlaceholder, int(role), log.WithRequest(r))
+		} else if mappedRole != nil && user.RoleID != *mappedRole {
+			if err := syncMappedRole(r, env, user, *mappedRole, "the identity provider claims"); err != nil {
+				env.SystemLogger.Error("failed to update the role of OIDC user", zap.Error(err), zap.Int64("user_id", user.ID))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
 		}
 
-		// Generate local JWT with user's database role permissions
-		jwt, err := generateJWT(user.ID, user.Email, env.Database.JWTSecret, RoleID(user.RoleID))
-		if err != nil {
-			env.SystemLogger.Error("failed to generate JWT", zap.Error(err))
+		// Start a session with the user's database role permissions (same as local login)
+		if err := startSession(w, r, env, user, db.SessionAuthMethodOIDC); err != nil {
+			env.SystemLogger.Error("failed to start session", zap.Error(err))
 			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
 			return
 		}
 
-		// Set JWT cookie (same as local login)
+		env.AuditLogger.TokenCreated(user.Email, log.WithRequest(r))
+		env.AuditLogger.LoginSuccess(user.Email, log.WithRequest(r))
+
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+// syncMappedRole gives a user the role mapped from their external identity, described by source, and revokes their
+// sessions, which carry their previous role. The role of the default admin account never changes.
+func syncMappedRole(r *http.Request, env *HandlerDependencies, user *db.User, role db.RoleID, source string) error {
+	if user.ID == 1 {
+		env.SystemLogger.Warn("not updating the role of the default admin account from "+source,
+			zap.Int("mapped_role_id", int(role)))
+		return nil
+	}
+	if err := updateRole(env, user, role); err != nil {
+		return err
+	}
+	if err := revokeAllSessions(r, env, user, "role changed by the identity provider"); err != nil {
+		return err
+	}
+	env.AuditLogger.UserUpdated(user.Email, "role_change",
+		log.WithRequest(r),
+		log.WithReason(fmt.Sprintf("role %d mapped from %s", role, source)),
+	)
+	user.RoleID = role
+	return nil
+}
+
+// StartOIDCLink handler starts linking an OIDC identity to the account of the request. It redirects to the
+// identity provider, whose callback links the identity that the user logs in with.
+func StartOIDCLink(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		if account.HasOIDC() {
+			writeResponse(w, http.StatusConflict, "account already has a linked OIDC identity", nil, env.SystemLogger)
+			return
+		}
+		state := generateRandomString(32)
+		env.StateStore.StoreLink(state, r.UserAgent(), account.ID)
+
+		// The session cookie isn't sent with the redirect from the identity provider, so the account is kept with
+		// the state, and the browser that started the flow is recognized by this cookie.
 		http.SetCookie(w, &http.Cookie{
-			Name:     CookieSessionTokenKey,
-			Value:    jwt,
+			Name:     oidcLinkCookie,
+			Value:    state,
 			HttpOnly: true,
 			Secure:   true,
-			Expires:  time.Now().Add(2 * time.Hour),
+			MaxAge:   int(oidcLinkLifetime.Seconds()),
 			Path:     "/",
-			SameSite: http.SameSiteStrictMode,
+			SameSite: http.SameSiteLaxMode,
 		})
 
-		env.AuditLogger.TokenCreated(user.Email, log.WithRequest(r))
-		env.AuditLogger.LoginSuccess(user.Email, log.WithRequest(r))
+		env.SystemLogger.Debug("OIDC link initiated",
+			zap.String("state", state[:8]+"..."),
+			zap.Int64("user_id", account.ID))
 
-		http.Redirect(w, r, "/", http.StatusFound)
+		aud := oauth2.SetAuthURLParam("audience", env.AuthnRepository.Audience)
+		http.Redirect(w, r, env.AuthnRepository.OAuth2Config.AuthCodeURL(state, aud), http.StatusFound)
+	}
+}
+
+// checkOIDCLinkCookie returns whether the callback of the link flow with state comes to the browser that started it.
+// Otherwise, it writes the response to the callback and returns false.
+func checkOIDCLinkCookie(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, state string) bool {
+	http.SetCookie(w, &http.Cookie{
+		Name:    oidcLinkCookie,
+		Value:   "",
+		Path:    "/",
+		Expires: time.Unix(0, 0),
+	})
+	cookie, err := r.Cookie(oidcLinkCookie)
+	if err == nil && cookie.Value == state {
+		return true
+	}
+	env.AuditLogger.OIDCLoginFailed("OIDC link not started by this browser",
+		log.WithRequest(r),
+		log.WithReason("link state doesn't match the link cookie"),
+	)
+	writeResponse(w, http.StatusBadRequest, "invalid or expired state parameter", nil, env.SystemLogger)
+	return false
+}
+
+// completeOIDCLink links the OIDC identity with sub to the account with userID, which started the link flow.
+func completeOIDCLink(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, userID int64, sub string) {
+	account, err := env.Database.GetUser(db.ByUserID(userID))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return
+		}
+		env.SystemLogger.Error("failed to get user", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	if account.HasOIDC() {
+		writeResponse(w, http.StatusConflict, "account already has a linked OIDC identity", nil, env.SystemLogger)
+		return
+	}
+	if err := env.Database.LinkUserOIDCSubject(account.ID, sub); err != nil {
+		if errors.Is(err, db.ErrAlreadyExists) {
+			env.AuditLogger.OIDCLoginFailed("OIDC identity already linked to another account",
+				log.WithRequest(r),
+				log.WithReason(fmt.Sprintf("user %s tried to link an identity of another account", account.Email)),
+			)
+			writeResponse(w, http.StatusConflict, "OIDC identity is already linked to another account", nil, env.SystemLogger)
+			return
+		}
+		env.SystemLogger.Error("failed to link OIDC identity", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	env.AuditLogger.OIDCLinked(account.Email, log.WithRequest(r))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// UnlinkMyOIDC handler removes the OIDC identity of the account of the request.
+func UnlinkMyOIDC(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		unlinkOIDC(w, r, env, account)
+	}
+}
+
+// ListOIDCIdentities handler returns the OIDC identities that are linked to accounts.
+func ListOIDCIdentities(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accounts, err := env.Database.ListUsers()
+		if err != nil {
+			env.SystemLogger.Error("failed to list users", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		identities := []OIDCIdentityResponse{}
+		for _, account := range accounts {
+			if !account.HasOIDC() {
+				continue
+			}
+			identities = append(identities, OIDCIdentityResponse{
+				AccountID: account.ID,
+				Email:     account.Email,
+				Subject:   *account.OIDCSubject,
+			})
+		}
+		writeResponse(w, http.StatusOK, "", identities, env.SystemLogger)
+	}
+}
+
+// UnlinkAccountOIDC handler removes the OIDC identity of an account.
+func UnlinkAccountOIDC(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		unlinkOIDC(w, r, env, account)
+	}
+}
+
+// unlinkOIDC removes the OIDC identity of an account and revokes its sessions. Accounts without a password keep their
+// identity, which is the only way that they can log in.
+func unlinkOIDC(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, account *db.User) {
+	if !account.HasOIDC() {
+		writeResponse(w, http.StatusNotFound, "account has no linked OIDC identity", nil, env.SystemLogger)
+		return
+	}
+	if !account.HasPassword() {
+		writeResponse(w, http.StatusBadRequest, "cannot unlink the OIDC identity of an account without a password", nil, env.SystemLogger)
+		return
+	}
+	if err := env.Database.UnlinkUserOIDCSubject(account.ID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "account has no linked OIDC identity", nil, env.SystemLogger)
+			return
+		}
+		env.SystemLogger.Error("failed to unlink OIDC identity", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	if err := revokeSessions(r, env, account, "OIDC identity unlinked"); err != nil {
+		env.SystemLogger.Error("failed to revoke sessions", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	opts := []log.AuditOption{log.WithRequest(r)}
+	if claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository); err == nil && claims.Email != account.Email {
+		opts = append(opts, log.WithActor(claims.Email))
 	}
+	env.AuditLogger.OIDCUnlinked(account.Email, opts...)
+	writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
 }
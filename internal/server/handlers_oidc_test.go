@@ -0,0 +1,296 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+	openfgav1 "github.com/openfga/api/proto/openfga/v1"
+)
+
+func TestOIDCLinkStart(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithOIDC("http://127.0.0.1:1/authorize"))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+
+	res, err := tu.StartOIDCLink(ts.URL, client, userToken)
+	if err != nil {
+		t.Fatalf("couldn't start OIDC link: %s", err)
+	}
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("couldn't parse redirect: %s", err)
+	}
+	if location.Host != "127.0.0.1:1" || location.Path != "/authorize" {
+		t.Fatalf("expected a redirect to the identity provider, got %s", location)
+	}
+	state := location.Query().Get("state")
+	if state == "" {
+		t.Fatalf("expected a state in the redirect, got %s", location)
+	}
+	var linkCookie *http.Cookie
+	for _, c := range res.Cookies() {
+		if c.Name == "notary_oidc_link" {
+			linkCookie = c
+		}
+	}
+	if linkCookie == nil || linkCookie.Value != state || !linkCookie.HttpOnly || !linkCookie.Secure || linkCookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected a link cookie with the state, got %+v", linkCookie)
+	}
+
+	t.Run("Callback without the link cookie is rejected", func(t *testing.T) {
+		req, err := http.NewRequest("GET", ts.URL+"/api/v1/oauth/callback?code=code&state="+state, nil)
+		if err != nil {
+			t.Fatalf("couldn't create request: %s", err)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("couldn't call callback: %s", err)
+		}
+		defer res.Body.Close() // nolint: errcheck
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, res.StatusCode)
+		}
+	})
+
+	t.Run("State can't be reused", func(t *testing.T) {
+		req, err := http.NewRequest("GET", ts.URL+"/api/v1/oauth/callback?code=code&state="+state, nil)
+		if err != nil {
+			t.Fatalf("couldn't create request: %s", err)
+		}
+		req.AddCookie(linkCookie)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("couldn't call callback: %s", err)
+		}
+		defer res.Body.Close() // nolint: errcheck
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, res.StatusCode)
+		}
+	})
+}
+
+func TestOIDCUnlink(t *testing.T) {
+	var database *db.DatabaseRepository
+	ts, logs := tu.MustPrepareServer(t, tu.WithOIDC("http://127.0.0.1:1/authorize"), tu.WithDatabase(&database))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	const adminID, userID = 2, 3
+
+	if err := database.LinkUserOIDCSubject(userID, "user-subject"); err != nil {
+		t.Fatalf("couldn't link OIDC subject: %s", err)
+	}
+	if err := database.LinkUserOIDCSubject(adminID, "admin-subject"); err != nil {
+		t.Fatalf("couldn't link OIDC subject: %s", err)
+	}
+
+	t.Run("1. Linked accounts can't start another link", func(t *testing.T) {
+		res, err := tu.StartOIDCLink(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't start OIDC link: %s", err)
+		}
+		if res.StatusCode != http.StatusConflict {
+			t.Fatalf("expected status %d, got %d", http.StatusConflict, res.StatusCode)
+		}
+	})
+
+	t.Run("2. Admin lists the linked identities", func(t *testing.T) {
+		statusCode, _, err := tu.ListOIDCIdentities(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't list OIDC identities: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, resp, err := tu.ListOIDCIdentities(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't list OIDC identities: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		expected := []server.OIDCIdentityResponse{
+			{AccountID: adminID, Email: "admin@canonical.com", Subject: "admin-subject"},
+			{AccountID: userID, Email: "user@canonical.com", Subject: "user-subject"},
+		}
+		if len(resp.Data) != len(expected) || resp.Data[0] != expected[0] || resp.Data[1] != expected[1] {
+			t.Fatalf("expected identities %+v, got %+v", expected, resp.Data)
+		}
+	})
+
+	t.Run("3. User unlinks their identity", func(t *testing.T) {
+		_ = logs.TakeAll()
+		statusCode, err := tu.UnlinkMyOIDC(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't unlink OIDC identity: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		if !hasEvent(logs, "authn_oidc_unlinked:user@canonical.com") {
+			t.Fatalf("expected an audit event for the unlink")
+		}
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		if statusCode != http.StatusOK || resp.Data.HasOIDC {
+			t.Fatalf("expected the account to have no OIDC identity, got status %d and %+v", statusCode, resp.Data)
+		}
+		statusCode, err = tu.UnlinkMyOIDC(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't unlink OIDC identity: %s", err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+
+	t.Run("4. Admin clears the identity of an account", func(t *testing.T) {
+		statusCode, err := tu.UnlinkAccountOIDC(ts.URL, client, userToken, adminID)
+		if err != nil {
+			t.Fatalf("couldn't unlink OIDC identity: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		_ = logs.TakeAll()
+		statusCode, err = tu.UnlinkAccountOIDC(ts.URL, client, adminToken, adminID)
+		if err != nil {
+			t.Fatalf("couldn't unlink OIDC identity: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		if !hasEvent(logs, "authn_oidc_unlinked:admin@canonical.com") {
+			t.Fatalf("expected an audit event for the unlink")
+		}
+		statusCode, resp, err := tu.ListOIDCIdentities(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't list OIDC identities: %s", err)
+		}
+		if statusCode != http.StatusOK || len(resp.Data) != 0 {
+			t.Fatalf("expected no identities, got status %d and %+v", statusCode, resp.Data)
+		}
+	})
+
+	t.Run("5. Accounts without a password keep their identity", func(t *testing.T) {
+		oidcUser, err := database.CreateOIDCUser("oidc@canonical.com", "oidc-subject", db.RoleReadOnly)
+		if err != nil {
+			t.Fatalf("couldn't create OIDC user: %s", err)
+		}
+		statusCode, err := tu.UnlinkAccountOIDC(ts.URL, client, adminToken, oidcUser.ID)
+		if err != nil {
+			t.Fatalf("couldn't unlink OIDC identity: %s", err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+	})
+}
+
+// mustLoginOIDC logs in through the identity provider, which issues the claims that it was given, and returns the
+// token of the session.
+func mustLoginOIDC(t *testing.T, serverURL string, client *http.Client) string {
+	t.Helper()
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	res, err := noRedirect.Get(serverURL + "/api/v1/oauth/login")
+	if err != nil {
+		t.Fatalf("couldn't start OIDC login: %s", err)
+	}
+	res.Body.Close() // nolint: errcheck
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("couldn't parse redirect: %s", err)
+	}
+	res, err = noRedirect.Get(serverURL + "/api/v1/oauth/callback?code=code&state=" + url.QueryEscape(location.Query().Get("state")))
+	if err != nil {
+		t.Fatalf("couldn't call callback: %s", err)
+	}
+	res.Body.Close() // nolint: errcheck
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("expected status %d, got %d", http.StatusFound, res.StatusCode)
+	}
+	for _, c := range res.Cookies() {
+		if c.Name == server.CookieSessionTokenKey {
+			return c.Value
+		}
+	}
+	t.Fatalf("expected a session cookie")
+	return ""
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	provider := tu.MustStartOIDCProvider(t)
+	var database *db.DatabaseRepository
+	var authzRepo *authorization.AuthzRepository
+	ts, _ := tu.MustPrepareServer(t, tu.WithDatabase(&database), tu.WithAuthzRepository(&authzRepo), tu.WithOIDCProvider(provider, &authentication.RoleMapping{
+		ClaimKey: "groups",
+		Roles: map[string]db.RoleID{
+			"notary-admins": db.RoleAdmin,
+			"pki-team":      db.RoleCertificateManager,
+		},
+	}))
+	client := ts.Client()
+	tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+
+	expectRole := func(t *testing.T, token string, role db.RoleID) {
+		t.Helper()
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		if statusCode != http.StatusOK || resp.Data.RoleID != int(role) {
+			t.Fatalf("expected role %d, got status %d and %+v", role, statusCode, resp.Data)
+		}
+		// The role is a single direct tuple, the roles it includes follow from the model.
+		tuples, err := authzRepo.FGAClient.Read(context.Background(), &openfgav1.ReadRequest{
+			StoreId:  authzRepo.StoreID,
+			TupleKey: &openfgav1.ReadRequestTupleKey{Object: "system:notary", User: "user:alice@example.com"},
+		})
+		if err != nil {
+			t.Fatalf("couldn't read OpenFGA tuples: %s", err)
+		}
+		var relations []string
+		for _, tuple := range tuples.GetTuples() {
+			relations = append(relations, tuple.GetKey().GetRelation())
+		}
+		if len(relations) != 1 || relations[0] != server.RoleIDToRelation(role) {
+			t.Fatalf("expected the OpenFGA relation %s, got %v", server.RoleIDToRelation(role), relations)
+		}
+	}
+
+	provider.SetClaims(map[string]any{"sub": "alice-subject", "email": "alice@example.com", "groups": []string{"pki-team"}})
+	managerToken := mustLoginOIDC(t, ts.URL, client)
+	expectRole(t, managerToken, db.RoleCertificateManager)
+	numUsers, err := database.NumUsers()
+	if err != nil {
+		t.Fatalf("couldn't count users: %s", err)
+	}
+
+	provider.SetClaims(map[string]any{"sub": "alice-subject", "email": "alice@example.com", "groups": []string{"notary-admins", "pki-team"}})
+	adminSessionToken := mustLoginOIDC(t, ts.URL, client)
+	expectRole(t, adminSessionToken, db.RoleAdmin)
+	// The session of the previous login carries the previous role, so it is revoked.
+	expectStatus(t, ts.URL, client, managerToken, http.StatusUnauthorized)
+
+	numUsersAfter, err := database.NumUsers()
+	if err != nil {
+		t.Fatalf("couldn't count users: %s", err)
+	}
+	if numUsersAfter != numUsers {
+		t.Fatalf("expected the second login to reuse the account, got %d users instead of %d", numUsersAfter, numUsers)
+	}
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type CreateInvitationParams struct {
+	Email  string `json:"email"`
+	RoleID RoleID `json:"role_id"`
+	// SendEmail emails the token to the user instead of returning it.
+	SendEmail bool `json:"send_email"`
+}
+
+func (params *CreateInvitationParams) IsValid() (bool, error) {
+	if params.Email == "" {
+		return false, errors.New("email is required")
+	}
+	if !validateEmail(params.Email) {
+		return false, errors.New("invalid email format")
+	}
+	if !params.RoleID.IsValid() {
+		return false, fmt.Errorf("invalid role ID: %d", params.RoleID)
+	}
+	return true, nil
+}
+
+type CreatePasswordResetParams struct {
+	// SendEmail emails the token to the user instead of returning it.
+	SendEmail bool `json:"send_email"`
+}
+
+type SetPasswordParams struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (params *SetPasswordParams) IsValid() (bool, error) {
+	if params.Token == "" {
+		return false, errors.New("token is required")
+	}
+	if params.Password == "" {
+		return false, errors.New("password is required")
+	}
+	if !validatePassword(params.Password) {
+		return false, errors.New("password must have 8 or more characters, must include at least one capital letter, one lowercase letter, and either a number or a symbol")
+	}
+	return true, nil
+}
+
+type PasswordTokenResponse struct {
+	AccountID int64 `json:"account_id"`
+	// Token is only returned when it wasn't emailed to the user.
+	Token     string `json:"token,omitempty"`
+	ExpiresAt int64  `json:"expires_at"`
+	Emailed   bool   `json:"emailed"`
+}
+
+// CreateInvitation creates an account without a password, and issues the token that lets the user set it.
+// An account that was invited and never set a password is invited again, with the given role, so that an
+// invitation whose token was lost or couldn't be emailed can be retried.
+func CreateInvitation(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params CreateInvitationParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		valid, err := params.IsValid()
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		if params.SendEmail && env.EmailSender == nil {
+			writeResponse(w, http.StatusBadRequest, "email is not configured", nil, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+
+		userID, err := env.Database.CreateInvitedUser(params.Email, db.RoleID(params.RoleID))
+		if errors.Is(err, db.ErrAlreadyExists) {
+			reinvite(w, r, env, params, claims.Email)
+			return
+		}
+		if err != nil {
+			env.SystemLogger.Error("failed to create invited user", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if env.AuthzRepository != nil {
+			relation := RoleIDToRelation(db.RoleID(params.RoleID))
+			tupleUser := authorization.UserID(params.Email)
+			if err := env.AuthzRepository.WriteTuple("system:notary", relation, tupleUser); err != nil {
+				env.SystemLogger.Error("Failed to write role tuple to OpenFGA", zap.Error(err), zap.String("user", tupleUser), zap.String("relation", relation))
+			}
+		}
+		env.AuditLogger.UserCreated(params.Email, int(params.RoleID),
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		user := &db.User{ID: userID, Email: params.Email}
+		issuePasswordToken(w, r, env, user, db.PasswordTokenPurposeInvitation, params.SendEmail, claims.Email)
+	}
+}
+
+// reinvite issues a new invitation token for the existing account with the email of params, if it is still
+// waiting for its invitation to be accepted. Its role is changed to the role of params.
+func reinvite(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, params CreateInvitationParams, actor string) {
+	account, err := env.Database.GetUser(db.ByEmail(params.Email))
+	if err != nil {
+		env.SystemLogger.Error("failed to get invited user", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	if !awaitsInvitation(account) {
+		writeResponse(w, http.StatusBadRequest, "account with given email already exists", nil, env.SystemLogger)
+		return
+	}
+	if role := db.RoleID(params.RoleID); role != account.RoleID {
+		if err := updateRole(env, account, role); err != nil {
+			env.SystemLogger.Error("failed to update the role of invited user", zap.Error(err), zap.Int64("user_id", account.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.UserUpdated(account.Email, "role_change",
+			log.WithActor(actor),
+			log.WithRequest(r),
+		)
+	}
+	issuePasswordToken(w, r, env, account, db.PasswordTokenPurposeInvitation, params.SendEmail, actor)
+}
+
+// awaitsInvitation reports whether the account was invited and has no way to log in yet,
+// because its user never set a password with the invitation token.
+func awaitsInvitation(account *db.User) bool {
+	return !account.HasPassword() && !account.HasOIDC() && !account.HasLDAP() && !account.ServiceAccount
+}
+
+// CreatePasswordReset issues the token that lets the user of an account set a new password.
+// The current password keeps working until the token is used.
+func CreatePasswordReset(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		if account.ServiceAccount {
+			writeResponse(w, http.StatusBadRequest, "service accounts authenticate with API tokens and can't have a password", nil, env.SystemLogger)
+			return
+		}
+		if account.HasLDAP() {
+			writeResponse(w, http.StatusBadRequest, "LDAP accounts authenticate with the password of their directory", nil, env.SystemLogger)
+			return
+		}
+		var params CreatePasswordResetParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		if params.SendEmail && env.EmailSender == nil {
+			writeResponse(w, http.StatusBadRequest, "email is not configured", nil, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		issuePasswordToken(w, r, env, account, db.PasswordTokenPurposeReset, params.SendEmail, claims.Email)
+	}
+}
+
+// issuePasswordToken issues a token for the user to set their password, which replaces their unused tokens, and
+// writes the response. The token is either emailed to the user or returned, so that the admin never sees both.
+func issuePasswordToken(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, user *db.User, purpose string, sendEmail bool, actor string) {
+	lifetime := env.PasswordResetLifetime
+	if purpose == db.PasswordTokenPurposeInvitation {
+		lifetime = env.PasswordInvitationLifetime
+	}
+	token, hashedToken, err := authentication.GeneratePasswordToken()
+	if err != nil {
+		env.SystemLogger.Error("failed to generate password token", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	expiresAt := time.Now().Add(lifetime)
+	if _, err := env.Database.CreatePasswordToken(user.ID, hashedToken, purpose, expiresAt, actor); err != nil {
+		env.SystemLogger.Error("failed to create password token", zap.Error(err), zap.Int64("user_id", user.ID))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+
+	response := PasswordTokenResponse{AccountID: user.ID, ExpiresAt: expiresAt.Unix()}
+	if sendEmail {
+		subject, body := passwordTokenEmail(env, purpose, token, expiresAt)
+		if err := env.EmailSender.Send(user.Email, subject, body); err != nil {
+			env.SystemLogger.Error("failed to email password token", zap.Error(err), zap.String("email", user.Email))
+			// The token was never delivered, so it mustn't stay usable.
+			if err := env.Database.DeleteUnusedPasswordTokens(user.ID); err != nil {
+				env.SystemLogger.Error("failed to delete undelivered password token", zap.Error(err), zap.Int64("user_id", user.ID))
+			}
+			writeResponse(w, http.StatusBadGateway, "the SMTP server failed to send the email", nil, env.SystemLogger)
+			return
+		}
+		response.Emailed = true
+	} else {
+		response.Token = token
+	}
+
+	env.AuditLogger.PasswordTokenIssued(user.Email, purpose,
+		log.WithActor(actor),
+		log.WithRequest(r),
+	)
+	writeResponse(w, http.StatusCreated, "", response, env.SystemLogger)
+}
+
+// passwordTokenEmail returns the subject and the body of the email that delivers a password token.
+func passwordTokenEmail(env *HandlerDependencies, purpose, token string, expiresAt time.Time) (string, string) {
+	subject := "Reset your Notary password"
+	intro := fmt.Sprintf("An administrator reset your password on Notary at https://%s.", env.ExternalHostname)
+	if purpose == db.PasswordTokenPurposeInvitation {
+		subject = "You are invited to Notary"
+		intro = fmt.Sprintf("An administrator invited you to Notary at https://%s.", env.ExternalHostname)
+	}
+	body := fmt.Sprintf("%s\n\n"+
+		"Set your password by sending this token along with your new password to https://%s/api/v1/set_password before %s:\n\n"+
+		"%s\n\n"+
+		"The token can only be used once. If you weren't expecting this email, you can ignore it.\n",
+		intro, env.ExternalHostname, expiresAt.UTC().Format(time.RFC1123), token)
+	return subject, body
+}
+
+// SetPassword sets the password of a user with an invitation or reset token. It doesn't require authentication:
+// the token is single-use, and every session of the user is revoked once it is used.
+func SetPassword(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params SetPasswordParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		// The password is validated first, so that a weak password doesn't use up the token.
+		valid, err := params.IsValid()
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+
+		now := time.Now()
+		reject := func(username, reason string) {
+			env.AuditLogger.PasswordTokenRejected(username,
+				log.WithRequest(r),
+				log.WithReason(reason),
+			)
+			writeResponse(w, http.StatusUnauthorized, "invalid or expired token", nil, env.SystemLogger)
+		}
+		token, err := env.Database.GetPasswordToken(authentication.HashPasswordToken(params.Token))
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				reject("", "unknown token")
+				return
+			}
+			env.SystemLogger.Error("failed to get password token", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		user, err := env.Database.GetUser(db.ByUserID(token.UserID))
+		if err != nil {
+			env.SystemLogger.Error("failed to get user of password token", zap.Error(err), zap.Int64("user_id", token.UserID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if token.UsedAt != 0 {
+			reject(user.Email, "token already used")
+			return
+		}
+		if now.Unix() >= token.ExpiresAt {
+			reject(user.Email, "token expired")
+			return
+		}
+		if user.ServiceAccount || user.HasLDAP() {
+			reject(user.Email, "account can't have a password")
+			return
+		}
+		if err := env.Database.UsePasswordToken(token.ID, now); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				// Another request used the token in the meantime.
+				reject(user.Email, "token already used")
+				return
+			}
+			env.SystemLogger.Error("failed to use password token", zap.Error(err), zap.Int64("token_id", token.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		if err := env.Database.UpdateUserPassword(db.ByUserID(user.ID), params.Password); err != nil {
+			env.SystemLogger.Error("failed to set user password with token", zap.Error(err), zap.Int64("user_id", user.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := revokeSessions(r, env, user, "password set with a "+token.Purpose+" token"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions after password was set", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		clearLoginFailures(env, user.Email)
+
+		env.AuditLogger.PasswordTokenUsed(user.Email, token.Purpose, log.WithRequest(r))
+		writeResponse(w, http.StatusCreated, "", nil, env.SystemLogger)
+	}
+}
@@ -0,0 +1,246 @@
+package server_test
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/canonical/notary/internal/backends/email"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func mustSetPassword(t *testing.T, url string, client *http.Client, token, password string, expected int) {
+	t.Helper()
+	statusCode, err := tu.SetPassword(url, client, server.SetPasswordParams{Token: token, Password: password})
+	if err != nil {
+		t.Fatalf("couldn't set password: %s", err)
+	}
+	if statusCode != expected {
+		t.Fatalf("expected status %d, got %d", expected, statusCode)
+	}
+}
+
+func TestPasswordTokensEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	const userID = 3
+
+	var invitationToken string
+	var invitedID int64
+	t.Run("1. Invite a user", func(t *testing.T) {
+		statusCode, resp, err := tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "invited@canonical.com", RoleID: server.RoleCertificateManager})
+		if err != nil {
+			t.Fatalf("couldn't create invitation: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		if resp.Data.Token == "" || resp.Data.Emailed || resp.Data.ExpiresAt == 0 {
+			t.Fatalf("unexpected invitation %+v", resp.Data)
+		}
+		invitationToken, invitedID = resp.Data.Token, resp.Data.AccountID
+		if !hasEvent(logs, "authn_password_token_issued:invited@canonical.com,invitation") {
+			t.Fatalf("expected the invitation to be audited")
+		}
+		statusCode, _, err = tu.Login(ts.URL, client, &tu.LoginParams{Email: "invited@canonical.com", Password: "Invited123"})
+		if err != nil {
+			t.Fatalf("couldn't log in: %s", err)
+		}
+		if statusCode == http.StatusOK {
+			t.Fatalf("expected the invited user not to log in before setting a password")
+		}
+	})
+
+	t.Run("2. A weak password doesn't use up the token", func(t *testing.T) {
+		mustSetPassword(t, ts.URL, client, invitationToken, "weak", http.StatusBadRequest)
+	})
+
+	t.Run("3. Set the password with the invitation", func(t *testing.T) {
+		mustSetPassword(t, ts.URL, client, invitationToken, "Invited123", http.StatusCreated)
+		token := mustLogin(t, ts.URL, client, "invited@canonical.com", "Invited123")
+		statusCode, resp, err := tu.GetMyAccount(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		if statusCode != http.StatusOK || int64(resp.Data.ID) != invitedID || resp.Data.RoleID != int(tu.RoleCertificateManager) {
+			t.Fatalf("unexpected account %d %+v", statusCode, resp.Data)
+		}
+		if !hasEvent(logs, "authn_password_token_used:invited@canonical.com,invitation") {
+			t.Fatalf("expected the use of the token to be audited")
+		}
+	})
+
+	t.Run("4. Tokens are single-use", func(t *testing.T) {
+		mustSetPassword(t, ts.URL, client, invitationToken, "Another123", http.StatusUnauthorized)
+		mustLogin(t, ts.URL, client, "invited@canonical.com", "Invited123")
+		if !hasEvent(logs, "authn_password_token_rejected:invited@canonical.com") {
+			t.Fatalf("expected the rejected token to be audited")
+		}
+	})
+
+	t.Run("5. A reset revokes the sessions of the user", func(t *testing.T) {
+		_, first, err := tu.CreatePasswordReset(ts.URL, client, adminToken, userID, server.CreatePasswordResetParams{})
+		if err != nil {
+			t.Fatalf("couldn't create password reset: %s", err)
+		}
+		statusCode, resp, err := tu.CreatePasswordReset(ts.URL, client, adminToken, userID, server.CreatePasswordResetParams{})
+		if err != nil {
+			t.Fatalf("couldn't create password reset: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		// The password keeps working until the token is used.
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		mustSetPassword(t, ts.URL, client, first.Data.Token, "Reset1234", http.StatusUnauthorized)
+		mustSetPassword(t, ts.URL, client, resp.Data.Token, "Reset1234", http.StatusCreated)
+		expectStatus(t, ts.URL, client, userToken, http.StatusUnauthorized)
+		mustFailLogin(t, ts.URL, client, "user@canonical.com", http.StatusUnauthorized)
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Reset1234")
+	})
+
+	t.Run("6. Unknown tokens are rejected", func(t *testing.T) {
+		mustSetPassword(t, ts.URL, client, "not-a-token", "Unknown123", http.StatusUnauthorized)
+		mustSetPassword(t, ts.URL, client, "", "Unknown123", http.StatusBadRequest)
+	})
+
+	t.Run("7. Invalid requests", func(t *testing.T) {
+		statusCode, _, err := tu.CreateServiceAccount(ts.URL, client, adminToken, server.CreateServiceAccountParams{Name: "ci-pipeline", RoleID: server.RoleCertificateManager})
+		if err != nil || statusCode != http.StatusCreated {
+			t.Fatalf("couldn't create service account: %d %v", statusCode, err)
+		}
+		cases := []struct {
+			desc     string
+			do       func() (int, *tu.PasswordTokenResponse, error)
+			expected int
+		}{
+			{"existing email", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "user@canonical.com", RoleID: server.RoleReadOnly})
+			}, http.StatusBadRequest},
+			{"invalid role", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "new@canonical.com", RoleID: 42})
+			}, http.StatusBadRequest},
+			{"email without SMTP", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "new@canonical.com", RoleID: server.RoleReadOnly, SendEmail: true})
+			}, http.StatusBadRequest},
+			{"service account reset", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreatePasswordReset(ts.URL, client, adminToken, invitedID+1, server.CreatePasswordResetParams{})
+			}, http.StatusBadRequest},
+			{"unknown account reset", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreatePasswordReset(ts.URL, client, adminToken, 100, server.CreatePasswordResetParams{})
+			}, http.StatusNotFound},
+			{"invitation by non-admin", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreateInvitation(ts.URL, client, mustLogin(t, ts.URL, client, "user@canonical.com", "Reset1234"), server.CreateInvitationParams{Email: "new@canonical.com", RoleID: server.RoleReadOnly})
+			}, http.StatusForbidden},
+			{"reset by non-admin", func() (int, *tu.PasswordTokenResponse, error) {
+				return tu.CreatePasswordReset(ts.URL, client, mustLogin(t, ts.URL, client, "user@canonical.com", "Reset1234"), 2, server.CreatePasswordResetParams{})
+			}, http.StatusForbidden},
+		}
+		for _, tc := range cases {
+			t.Run(tc.desc, func(t *testing.T) {
+				statusCode, _, err := tc.do()
+				if err != nil {
+					t.Fatalf("couldn't send request: %s", err)
+				}
+				if statusCode != tc.expected {
+					t.Fatalf("expected status %d, got %d", tc.expected, statusCode)
+				}
+			})
+		}
+	})
+}
+
+func TestPasswordTokensByEmail(t *testing.T) {
+	smtpServer := tu.MustStartSMTPServer(t)
+	ts, _ := tu.MustPrepareServer(t, tu.WithSMTP(&email.SMTPSender{
+		Host:     smtpServer.Host,
+		Port:     smtpServer.Port,
+		From:     "notary@example.com",
+		Security: email.SMTPSecurityNone,
+	}))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+
+	statusCode, resp, err := tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "invited@canonical.com", RoleID: server.RoleReadOnly, SendEmail: true})
+	if err != nil {
+		t.Fatalf("couldn't create invitation: %s", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+	}
+	if resp.Data.Token != "" || !resp.Data.Emailed {
+		t.Fatalf("expected the token to be emailed and not returned, got %+v", resp.Data)
+	}
+
+	messages := smtpServer.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "invited@canonical.com" {
+		t.Fatalf("expected one email to the invited user, got %+v", messages)
+	}
+	// The token is on a line of its own in the body.
+	var token string
+	_, body, _ := strings.Cut(messages[0].Data, "\r\n\r\n")
+	for line := range strings.SplitSeq(body, "\r\n") {
+		if len(line) == 43 && !strings.Contains(line, " ") {
+			token = line
+		}
+	}
+	if token == "" {
+		t.Fatalf("expected the email to contain the token, got %q", messages[0].Data)
+	}
+	mustSetPassword(t, ts.URL, client, token, "Invited123", http.StatusCreated)
+	mustLogin(t, ts.URL, client, "invited@canonical.com", "Invited123")
+}
+
+func TestInvitationCanBeRetried(t *testing.T) {
+	// Nothing listens on the port of the SMTP server, so that emailing the invitation fails.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't reserve a port: %s", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close() //nolint:errcheck
+	ts, _ := tu.MustPrepareServer(t, tu.WithSMTP(&email.SMTPSender{
+		Host:     "127.0.0.1",
+		Port:     port,
+		From:     "notary@example.com",
+		Security: email.SMTPSecurityNone,
+	}))
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+
+	statusCode, _, err := tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "invited@canonical.com", RoleID: server.RoleReadOnly, SendEmail: true})
+	if err != nil {
+		t.Fatalf("couldn't create invitation: %s", err)
+	}
+	if statusCode != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, statusCode)
+	}
+
+	statusCode, resp, err := tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "invited@canonical.com", RoleID: server.RoleCertificateManager})
+	if err != nil {
+		t.Fatalf("couldn't create invitation: %s", err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected the invitation to be retried with status %d, got %d", http.StatusCreated, statusCode)
+	}
+	statusCode, account, err := tu.GetAccount(ts.URL, client, adminToken, int(resp.Data.AccountID))
+	if err != nil {
+		t.Fatalf("couldn't get account: %s", err)
+	}
+	if statusCode != http.StatusOK || account.Data.RoleID != int(server.RoleCertificateManager) {
+		t.Fatalf("expected the role of the retried invitation, got %d %+v", statusCode, account.Data)
+	}
+	mustSetPassword(t, ts.URL, client, resp.Data.Token, "Invited123", http.StatusCreated)
+
+	statusCode, _, err = tu.CreateInvitation(ts.URL, client, adminToken, server.CreateInvitationParams{Email: "invited@canonical.com", RoleID: server.RoleReadOnly})
+	if err != nil {
+		t.Fatalf("couldn't create invitation: %s", err)
+	}
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected an account with a password not to be invited again, got status %d", statusCode)
+	}
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+// serviceAccountNameRegex matches the names of service accounts. They can't hold an @, so that they never
+// collide with the email of a user.
+var serviceAccountNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+type CreateServiceAccountParams struct {
+	Name   string `json:"name"`
+	RoleID RoleID `json:"role_id"`
+}
+
+func (params *CreateServiceAccountParams) IsValid() (bool, error) {
+	if params.Name == "" {
+		return false, errors.New("name is required")
+	}
+	if !serviceAccountNameRegex.MatchString(params.Name) {
+		return false, errors.New("name must be 1 to 63 lowercase letters, digits, dots, dashes or underscores, starting with a letter or a digit")
+	}
+	if !params.RoleID.IsValid() {
+		return false, fmt.Errorf("invalid role ID: %d", params.RoleID)
+	}
+	return true, nil
+}
+
+type CreateAPITokenParams struct {
+	Name                    string   `json:"name"`
+	Scopes                  []string `json:"scopes"`
+	CertificateAuthorityIDs []int64  `json:"certificate_authority_ids"`
+	ExpiresAt               int64    `json:"expires_at"`
+}
+
+func (params *CreateAPITokenParams) IsValid(now time.Time) (bool, error) {
+	if params.Name == "" {
+		return false, errors.New("name is required")
+	}
+	if len(params.Scopes) == 0 {
+		return false, errors.New("at least one scope is required")
+	}
+	for _, scope := range params.Scopes {
+		if !IsValidAPITokenScope(scope) {
+			return false, fmt.Errorf("invalid scope: %q", scope)
+		}
+	}
+	if params.ExpiresAt <= now.Unix() {
+		return false, errors.New("expires_at must be in the future")
+	}
+	return true, nil
+}
+
+type APITokenResponse struct {
+	ID                      int64    `json:"id"`
+	Name                    string   `json:"name"`
+	TokenID                 string   `json:"token_id"`
+	Scopes                  []string `json:"scopes"`
+	CertificateAuthorityIDs []int64  `json:"certificate_authority_ids"`
+	ExpiresAt               int64    `json:"expires_at"`
+	LastUsedAt              int64    `json:"last_used_at"`
+	CreatedBy               string   `json:"created_by"`
+	CreatedAt               int64    `json:"created_at"`
+}
+
+type CreateAPITokenResponse struct {
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+}
+
+func dbAPITokenToResponse(t *db.APIToken) APITokenResponse {
+	caIDs := t.CertificateAuthorityIDList()
+	if caIDs == nil {
+		caIDs = []int64{}
+	}
+	return APITokenResponse{
+		ID:                      t.ID,
+		Name:                    t.Name,
+		TokenID:                 t.TokenID,
+		Scopes:                  t.ScopeList(),
+		CertificateAuthorityIDs: caIDs,
+		ExpiresAt:               t.ExpiresAt,
+		LastUsedAt:              t.LastUsedAt,
+		CreatedBy:               t.CreatedBy,
+		CreatedAt:               t.CreatedAt,
+	}
+}
+
+// CreateServiceAccount handler creates an account for automation. Service accounts have a role like users,
+// but no password: they authenticate with API tokens.
+// It returns a 201 Created on success
+func CreateServiceAccount(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params CreateServiceAccountParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		valid, err := params.IsValid()
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		newUserID, err := env.Database.CreateServiceAccount(params.Name, db.RoleID(params.RoleID))
+		if err != nil {
+			if errors.Is(err, db.ErrAlreadyExists) {
+				writeResponse(w, http.StatusBadRequest, "account with given name already exists", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to create service account", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		if env.AuthzRepository != nil {
+			relation := RoleIDToRelation(db.RoleID(params.RoleID))
+			userID := authorization.UserID(params.Name)
+			if err := env.AuthzRepository.WriteTuple("system:notary", relation, userID); err != nil {
+				env.SystemLogger.Error("Failed to write role tuple to OpenFGA", zap.Error(err), zap.String("user", userID), zap.String("relation", relation))
+			}
+		}
+
+		env.AuditLogger.UserCreated(params.Name, int(params.RoleID),
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeResponse(w, http.StatusCreated, "", map[string]int64{"id": newUserID}, env.SystemLogger)
+	}
+}
+
+// ListAPITokens handler returns the API tokens of a service account, without their secret.
+func ListAPITokens(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getServiceAccount(w, r, env)
+		if !ok {
+			return
+		}
+		tokens, err := env.Database.ListAPITokens(account.ID)
+		if err != nil {
+			env.SystemLogger.Error("failed to list API tokens", zap.Error(err), zap.Int64("account_id", account.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		resp := make([]APITokenResponse, 0, len(tokens))
+		for i := range tokens {
+			resp = append(resp, dbAPITokenToResponse(&tokens[i]))
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
+
+// CreateAPIToken handler creates an API token for a service account.
+// It returns a 201 Created with the token on success, which is the only time the token is returned.
+func CreateAPIToken(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getServiceAccount(w, r, env)
+		if !ok {
+			return
+		}
+		var params CreateAPITokenParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid JSON format", nil, env.SystemLogger)
+			return
+		}
+		valid, err := params.IsValid(time.Now())
+		if !valid {
+			writeResponse(w, http.StatusBadRequest, err.Error(), nil, env.SystemLogger)
+			return
+		}
+		for _, caID := range params.CertificateAuthorityIDs {
+			if _, err := env.Database.GetCertificateAuthority(db.ByCertificateAuthorityID(caID)); err != nil {
+				if errors.Is(err, db.ErrNotFound) {
+					writeResponse(w, http.StatusBadRequest, fmt.Sprintf("certificate authority %d not found", caID), nil, env.SystemLogger)
+					return
+				}
+				env.SystemLogger.Error("failed to get certificate authority", zap.Error(err), zap.Int64("certificate_authority_id", caID))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		token, tokenID, hashedSecret, err := authentication.GenerateAPIToken()
+		if err != nil {
+			env.SystemLogger.Error("failed to generate API token", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		id, err := env.Database.CreateAPIToken(account.ID, params.Name, tokenID, hashedSecret, params.Scopes, params.CertificateAuthorityIDs, time.Unix(params.ExpiresAt, 0), claims.Email)
+		if err != nil {
+			if errors.Is(err, db.ErrAlreadyExists) {
+				writeResponse(w, http.StatusBadRequest, "API token with given name already exists", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to create API token", zap.Error(err), zap.Int64("account_id", account.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		env.AuditLogger.APITokenCreated(account.Email, params.Name, params.Scopes,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeResponse(w, http.StatusCreated, "", CreateAPITokenResponse{ID: id, Token: token}, env.SystemLogger)
+	}
+}
+
+// DeleteAPIToken handler revokes an API token of a service account. The token is rejected from then on.
+func DeleteAPIToken(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getServiceAccount(w, r, env)
+		if !ok {
+			return
+		}
+		tokenID, err := strconv.ParseInt(r.PathValue("token_id"), 10, 64)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, "invalid token ID", nil, env.SystemLogger)
+			return
+		}
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		token, err := env.Database.GetAPIToken(account.ID, tokenID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to get API token", zap.Error(err), zap.Int64("id", tokenID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		if err := env.Database.DeleteAPIToken(account.ID, tokenID); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+				return
+			}
+			env.SystemLogger.Error("failed to delete API token", zap.Error(err), zap.Int64("id", tokenID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+
+		env.AuditLogger.APITokenRevoked(account.Email, token.Name,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
+
+// getServiceAccount returns the service account of the id path parameter. It writes the error response
+// and returns false when the account doesn't exist or isn't a service account.
+func getServiceAccount(w http.ResponseWriter, r *http.Request, env *HandlerDependencies) (*db.User, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+		return nil, false
+	}
+	account, err := env.Database.GetUser(db.ByUserID(id))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return nil, false
+		}
+		env.SystemLogger.Error("failed to get user", zap.Error(err), zap.Int64("id", id))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+	if !account.ServiceAccount {
+		writeResponse(w, http.StatusBadRequest, "API tokens can only be created for service accounts", nil, env.SystemLogger)
+		return nil, false
+	}
+	return account, true
+}
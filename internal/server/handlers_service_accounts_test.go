@@ -0,0 +1,408 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func TestServiceAccountsEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	caIDs := make([]int64, 0, 2)
+	for _, commonName := range []string{"ci.example.com", "other.example.com"} {
+		statusCode, resp, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{SelfSigned: true, CommonName: commonName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		caIDs = append(caIDs, int64(resp.Data.ID))
+	}
+
+	var accountID int64
+	t.Run("create service account", func(t *testing.T) {
+		statusCode, resp, err := tu.CreateServiceAccount(ts.URL, client, adminToken, server.CreateServiceAccountParams{Name: "ci-pipeline", RoleID: server.RoleCertificateManager})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		accountID = int64(resp.Data.ID)
+
+		statusCode, account, err := tu.GetAccount(ts.URL, client, adminToken, int(accountID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK || !account.Data.ServiceAccount || account.Data.HasPassword {
+			t.Fatalf("unexpected service account %+v", account.Data)
+		}
+		if len(account.Data.AuthMethods) != 1 || account.Data.AuthMethods[0] != "api_token" {
+			t.Fatalf("expected the api_token auth method, got %v", account.Data.AuthMethods)
+		}
+	})
+
+	t.Run("invalid service accounts", func(t *testing.T) {
+		cases := []struct {
+			desc   string
+			params server.CreateServiceAccountParams
+		}{
+			{"missing name", server.CreateServiceAccountParams{RoleID: server.RoleReadOnly}},
+			{"name like an email", server.CreateServiceAccountParams{Name: "ci@canonical.com", RoleID: server.RoleReadOnly}},
+			{"invalid role", server.CreateServiceAccountParams{Name: "ci", RoleID: 12}},
+			{"name already taken", server.CreateServiceAccountParams{Name: "ci-pipeline", RoleID: server.RoleReadOnly}},
+		}
+		for _, tc := range cases {
+			statusCode, _, err := tu.CreateServiceAccount(ts.URL, client, adminToken, tc.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusBadRequest {
+				t.Fatalf("%s: expected status %d, got %d", tc.desc, http.StatusBadRequest, statusCode)
+			}
+		}
+	})
+
+	t.Run("service accounts have no password", func(t *testing.T) {
+		statusCode, _, err := tu.ChangeAccountPassword(ts.URL, client, adminToken, int(accountID), &tu.ChangeAccountPasswordParams{Password: "Pa55word!"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, statusCode)
+		}
+	})
+
+	expiresAt := time.Now().Add(24 * time.Hour).Unix()
+	t.Run("invalid API tokens", func(t *testing.T) {
+		cases := []struct {
+			desc      string
+			accountID int64
+			params    server.CreateAPITokenParams
+		}{
+			{"not a service account", 1, server.CreateAPITokenParams{Name: "ci", Scopes: []string{server.ScopeJobsRead}, ExpiresAt: expiresAt}},
+			{"missing scopes", accountID, server.CreateAPITokenParams{Name: "ci", ExpiresAt: expiresAt}},
+			{"invalid scope", accountID, server.CreateAPITokenParams{Name: "ci", Scopes: []string{"accounts:manage"}, ExpiresAt: expiresAt}},
+			{"no expiry", accountID, server.CreateAPITokenParams{Name: "ci", Scopes: []string{server.ScopeJobsRead}}},
+			{"unknown certificate authority", accountID, server.CreateAPITokenParams{Name: "ci", Scopes: []string{server.ScopeJobsRead}, CertificateAuthorityIDs: []int64{42}, ExpiresAt: expiresAt}},
+		}
+		for _, tc := range cases {
+			statusCode, resp, err := tu.CreateAPIToken(ts.URL, client, adminToken, tc.accountID, tc.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusBadRequest {
+				t.Fatalf("%s: expected status %d, got %d: %s", tc.desc, http.StatusBadRequest, statusCode, resp.Message)
+			}
+		}
+	})
+
+	var apiToken string
+	var apiTokenID int64
+	t.Run("create API token", func(t *testing.T) {
+		statusCode, resp, err := tu.CreateAPIToken(ts.URL, client, adminToken, accountID, server.CreateAPITokenParams{
+			Name:                    "submit-csrs",
+			Scopes:                  []string{server.ScopeCertificateRequestsSubmit, server.ScopeCertificateRequestsManage, server.ScopeCertificateAuthoritiesRead},
+			CertificateAuthorityIDs: caIDs[:1],
+			ExpiresAt:               expiresAt,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		if !strings.HasPrefix(resp.Data.Token, "notary_") {
+			t.Fatalf("unexpected API token %q", resp.Data.Token)
+		}
+		apiToken = resp.Data.Token
+		apiTokenID = resp.Data.ID
+	})
+
+	var csrID int
+	t.Run("API token is limited to its scopes", func(t *testing.T) {
+		cases := []struct {
+			desc       string
+			method     string
+			path       string
+			body       string
+			statusCode int
+		}{
+			{"submit a certificate request", "POST", "/api/v1/certificate_requests", fmt.Sprintf(`{"csr":%q}`, tu.ExampleCSR), http.StatusCreated},
+			{"missing scope", "GET", "/api/v1/certificate_requests", "", http.StatusForbidden},
+			{"accounts are out of reach", "GET", "/api/v1/accounts", "", http.StatusForbidden},
+			{"API tokens are out of reach", "GET", "/api/v1/accounts/" + strconv.FormatInt(accountID, 10) + "/tokens", "", http.StatusForbidden},
+			{"allowed certificate authority", "GET", "/api/v1/certificate_authorities/" + strconv.FormatInt(caIDs[0], 10), "", http.StatusOK},
+			{"other certificate authority", "GET", "/api/v1/certificate_authorities/" + strconv.FormatInt(caIDs[1], 10), "", http.StatusForbidden},
+			{"invalid API token", "GET", "/api/v1/certificate_authorities", "", http.StatusUnauthorized},
+		}
+		for _, tc := range cases {
+			token := apiToken
+			if tc.statusCode == http.StatusUnauthorized {
+				token = apiToken + "x"
+			}
+			statusCode, body, err := tu.DoBearerRequest(client, token, tc.method, ts.URL+tc.path, []byte(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != tc.statusCode {
+				t.Fatalf("%s: expected status %d, got %d: %s", tc.desc, tc.statusCode, statusCode, body)
+			}
+			if tc.statusCode == http.StatusCreated {
+				var resp tu.CreateCertificateRequestResponse
+				if err := json.Unmarshal(body, &resp); err != nil {
+					t.Fatal(err)
+				}
+				csrID = resp.Data.ID
+			}
+		}
+	})
+
+	t.Run("API token is limited to its certificate authorities", func(t *testing.T) {
+		statusCode, resp, err := tu.ListCertificateAuthorities(ts.URL, client, apiToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK || len(resp.Data) != 1 || resp.Data[0].ID != caIDs[0] {
+			t.Fatalf("expected only the allowed certificate authority, got %d %+v", statusCode, resp.Data)
+		}
+		statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, apiToken, csrID, server.SignCertificateRequestParams{CertificateAuthorityID: strconv.FormatInt(caIDs[1], 10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, apiToken, csrID, server.SignCertificateRequestParams{CertificateAuthorityID: strconv.FormatInt(caIDs[0], 10)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	})
+
+	t.Run("API token only acts on the certificate requests of its certificate authorities", func(t *testing.T) {
+		statusCode, resp, err := tu.CreateAPIToken(ts.URL, client, adminToken, accountID, server.CreateAPITokenParams{
+			Name:                    "manage-csrs",
+			Scopes:                  []string{server.ScopeCertificateRequestsRead, server.ScopeCertificateRequestsSubmit, server.ScopeCertificateRequestsManage},
+			CertificateAuthorityIDs: caIDs[:1],
+			ExpiresAt:               expiresAt,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		token := resp.Data.Token
+
+		// The admin submits one request signed by each certificate authority, and one that stays unsigned.
+		adminCSRIDs := make([]int, 0, 3)
+		csrs := []string{tu.AppleCSR, tu.BananaCSR, tu.StrawberryCSR}
+		for i, caID := range []int64{caIDs[0], caIDs[1], 0} {
+			statusCode, created, err := tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: csrs[i]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+			}
+			if caID != 0 {
+				statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, adminToken, created.Data.ID, server.SignCertificateRequestParams{CertificateAuthorityID: strconv.FormatInt(caID, 10)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if statusCode != http.StatusAccepted {
+					t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+				}
+			}
+			adminCSRIDs = append(adminCSRIDs, created.Data.ID)
+		}
+		allowedCA, otherCA, unsigned := adminCSRIDs[0], adminCSRIDs[1], adminCSRIDs[2]
+
+		statusCode, list, err := tu.ListCertificateRequests(ts.URL, client, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		listed := make([]int64, 0, len(list.Data))
+		for _, csr := range list.Data {
+			listed = append(listed, csr.ID)
+		}
+		if len(listed) != 2 || !slices.Contains(listed, int64(csrID)) || !slices.Contains(listed, int64(allowedCA)) {
+			t.Fatalf("expected only the requests signed by the allowed certificate authority, got %v", listed)
+		}
+
+		cases := []struct {
+			desc       string
+			method     string
+			path       string
+			body       string
+			statusCode int
+		}{
+			{"read a request of the allowed certificate authority", "GET", fmt.Sprintf("/api/v1/certificate_requests/%d", allowedCA), "", http.StatusOK},
+			{"read a request of another certificate authority", "GET", fmt.Sprintf("/api/v1/certificate_requests/%d", otherCA), "", http.StatusForbidden},
+			{"read an unsigned request of another account", "GET", fmt.Sprintf("/api/v1/certificate_requests/%d", unsigned), "", http.StatusForbidden},
+			{"download a certificate of another certificate authority", "GET", fmt.Sprintf("/api/v1/certificate_requests/%d/certificate", otherCA), "", http.StatusForbidden},
+			{"revoke a certificate of another certificate authority", "POST", fmt.Sprintf("/api/v1/certificate_requests/%d/certificate/revoke", otherCA), "", http.StatusForbidden},
+			{"delete a certificate of another certificate authority", "DELETE", fmt.Sprintf("/api/v1/certificate_requests/%d/certificate", otherCA), "", http.StatusForbidden},
+			{"reject a request of another certificate authority", "POST", fmt.Sprintf("/api/v1/certificate_requests/%d/reject", otherCA), "", http.StatusForbidden},
+			{"delete a request of another certificate authority", "DELETE", fmt.Sprintf("/api/v1/certificate_requests/%d", otherCA), "", http.StatusForbidden},
+			{"sign an unsigned request of another account", "POST", fmt.Sprintf("/api/v1/certificate_requests/%d/sign", unsigned), fmt.Sprintf(`{"certificate_authority_id":"%d"}`, caIDs[0]), http.StatusForbidden},
+			{"upload a certificate", "POST", fmt.Sprintf("/api/v1/certificate_requests/%d/certificate", allowedCA), `{"certificate":""}`, http.StatusForbidden},
+		}
+		for _, tc := range cases {
+			statusCode, body, err := tu.DoBearerRequest(client, token, tc.method, ts.URL+tc.path, []byte(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != tc.statusCode {
+				t.Fatalf("%s: expected status %d, got %d: %s", tc.desc, tc.statusCode, statusCode, body)
+			}
+		}
+
+		statusCode, created, err := tu.CreateCertificateRequest(ts.URL, client, token, tu.CreateCertificateRequestParams{CSR: tu.MustGenerateCSR(t, "acme.example.com")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, token, created.Data.ID, server.SignCertificateRequestParams{SigningMethod: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected the API token not to sign via ACME, got status %d", statusCode)
+		}
+		statusCode, err = tu.DeleteAPIToken(ts.URL, client, adminToken, accountID, resp.Data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	})
+
+	t.Run("API token requests are audited with the service account", func(t *testing.T) {
+		statusCode, account, err := tu.GetAccount(ts.URL, client, adminToken, int(accountID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		_ = logs.TakeAll()
+		statusCode, _, err = tu.DoBearerRequest(client, apiToken, "GET", fmt.Sprintf("%s/api/v1/certificate_authorities/%d", ts.URL, caIDs[0]), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		var actor string
+		for _, e := range logs.TakeAll() {
+			if e.LoggerName == "audit" && findStringField(e, "event") == "api_action" {
+				actor = findStringField(e, "actor")
+			}
+		}
+		if actor != account.Data.Email {
+			t.Fatalf("expected the service account %q as actor, got %q", account.Data.Email, actor)
+		}
+	})
+
+	t.Run("list API tokens", func(t *testing.T) {
+		statusCode, resp, err := tu.ListAPITokens(ts.URL, client, adminToken, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK || len(resp.Data) != 1 {
+			t.Fatalf("expected 1 API token, got %d %+v", statusCode, resp.Data)
+		}
+		token := resp.Data[0]
+		if token.Name != "submit-csrs" || token.CreatedBy != "admin@canonical.com" || token.ExpiresAt != expiresAt || token.LastUsedAt == 0 {
+			t.Fatalf("unexpected API token %+v", token)
+		}
+		if len(token.CertificateAuthorityIDs) != 1 || token.CertificateAuthorityIDs[0] != caIDs[0] {
+			t.Fatalf("unexpected certificate authorities %v", token.CertificateAuthorityIDs)
+		}
+	})
+
+	t.Run("expired API token", func(t *testing.T) {
+		shortExpiry := time.Now().Unix() + 2
+		statusCode, resp, err := tu.CreateAPIToken(ts.URL, client, adminToken, accountID, server.CreateAPITokenParams{
+			Name:      "short-lived",
+			Scopes:    []string{server.ScopeJobsRead},
+			ExpiresAt: shortExpiry,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, statusCode, resp.Message)
+		}
+		time.Sleep(time.Until(time.Unix(shortExpiry, 0)))
+		statusCode, _, err = tu.DoBearerRequest(client, resp.Data.Token, "GET", ts.URL+"/api/v1/jobs", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+	})
+
+	t.Run("revoke API token", func(t *testing.T) {
+		statusCode, err := tu.DeleteAPIToken(ts.URL, client, adminToken, accountID, apiTokenID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		statusCode, _, err = tu.DoBearerRequest(client, apiToken, "GET", ts.URL+"/api/v1/certificate_authorities", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected the revoked API token to be rejected, got status %d", statusCode)
+		}
+	})
+
+	t.Run("audit events", func(t *testing.T) {
+		events := map[string]bool{}
+		for _, e := range logs.All() {
+			event, _, _ := strings.Cut(findStringField(e, "event"), ":")
+			events[event] = true
+		}
+		for _, event := range []string{"authn_api_token_created", "authn_api_token_rejected", "authn_api_token_revoked"} {
+			if !events[event] {
+				t.Errorf("expected audit event %s", event)
+			}
+		}
+	})
+
+	t.Run("delete service account", func(t *testing.T) {
+		statusCode, _, err := tu.DeleteAccount(ts.URL, client, adminToken, int(accountID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"go.uber.org/zap"
+)
+
+type SessionResponse struct {
+	ID         int64  `json:"id"`
+	IPAddress  string `json:"ip_address"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  int64  `json:"created_at"`
+	LastSeenAt int64  `json:"last_seen_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+	// Current is true for the session that the request was made with.
+	Current bool `json:"current"`
+}
+
+func dbSessionToResponse(s *db.Session, current *db.Session) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID,
+		IPAddress:  s.IPAddress,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    current != nil && current.ID == s.ID,
+	}
+}
+
+// ListMySessions handler returns the sessions of the account of the request.
+func ListMySessions(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		writeSessions(w, r, env, account)
+	}
+}
+
+// DeleteMySession handler revokes a session of the account of the request.
+func DeleteMySession(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		deleteSession(w, r, env, account)
+	}
+}
+
+// DeleteMySessions handler revokes all the sessions of the account of the request but the one it was made with.
+func DeleteMySessions(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getMyAccount(w, r, env)
+		if !ok {
+			return
+		}
+		if err := revokeSessions(r, env, account, "revoked by the user"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions", zap.Error(err), zap.Int64("user_id", account.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
+
+// ListAccountSessions handler returns the sessions of an account.
+func ListAccountSessions(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		writeSessions(w, r, env, account)
+	}
+}
+
+// DeleteAccountSession handler revokes a session of an account.
+func DeleteAccountSession(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		deleteSession(w, r, env, account)
+	}
+}
+
+// DeleteAccountSessions handler revokes all the sessions of an account, which logs it out everywhere.
+// The session that the request was made with is kept, so that an admin doesn't log themselves out.
+func DeleteAccountSessions(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := getAccountFromPath(w, r, env)
+		if !ok {
+			return
+		}
+		if err := revokeSessions(r, env, account, "revoked by an administrator"); err != nil {
+			env.SystemLogger.Error("failed to revoke sessions", zap.Error(err), zap.Int64("user_id", account.ID))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+	}
+}
+
+func writeSessions(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, account *db.User) {
+	sessions, err := env.Database.ListSessions(account.ID)
+	if err != nil {
+		env.SystemLogger.Error("failed to list sessions", zap.Error(err), zap.Int64("user_id", account.ID))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+	current := callerSession(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, dbSessionToResponse(&s, current))
+	}
+	writeResponse(w, http.StatusOK, "", response, env.SystemLogger)
+}
+
+func deleteSession(w http.ResponseWriter, r *http.Request, env *HandlerDependencies, account *db.User) {
+	sessionID, err := strconv.ParseInt(r.PathValue("session_id"), 10, 64)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, "invalid session ID", nil, env.SystemLogger)
+		return
+	}
+	claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+	if err != nil {
+		env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+		writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+		return
+	}
+	if err := env.Database.DeleteSession(account.ID, sessionID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return
+		}
+		env.SystemLogger.Error("failed to delete session", zap.Error(err), zap.Int64("id", sessionID))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return
+	}
+
+	env.AuditLogger.SessionRevoked(account.Email, sessionID,
+		log.WithActor(claims.Email),
+		log.WithRequest(r),
+	)
+
+	writeResponse(w, http.StatusAccepted, "", nil, env.SystemLogger)
+}
+
+// revokeSessions deletes all the sessions of the account, but the one that the request was made with.
+// It is called whenever the credentials of an account change, so that they apply to every session.
+func revokeSessions(r *http.Request, env *HandlerDependencies, account *db.User, reason string) error {
+	keep := ""
+	if current := callerSession(r); current != nil && current.UserID == account.ID {
+		keep = current.SessionID
+	}
+	return deleteUserSessions(r, env, account, keep, reason)
+}
+
+// revokeAllSessions deletes all the sessions of the account, including the one that the request was made with.
+// It is called whenever the role of an account changes, since the role is carried by the token of each session.
+func revokeAllSessions(r *http.Request, env *HandlerDependencies, account *db.User, reason string) error {
+	return deleteUserSessions(r, env, account, "", reason)
+}
+
+// deleteUserSessions deletes the sessions of the account but the one with the keep session ID, if not empty.
+func deleteUserSessions(r *http.Request, env *HandlerDependencies, account *db.User, keep string, reason string) error {
+	if err := env.Database.DeleteUserSessions(account.ID, keep); err != nil {
+		return err
+	}
+	opts := []log.AuditOption{log.WithRequest(r), log.WithReason(reason)}
+	if claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository); err == nil {
+		opts = append(opts, log.WithActor(claims.Email))
+	}
+	env.AuditLogger.SessionsRevoked(account.Email, opts...)
+	return nil
+}
+
+// callerSession returns the session that the request was made with, or nil if it wasn't made with the session cookie.
+func callerSession(r *http.Request) *db.Session {
+	p, ok := r.Context().Value(principalContextKey{}).(*principal)
+	if !ok {
+		return nil
+	}
+	return p.session
+}
+
+// getMyAccount returns the account of the request. It writes the error response and returns false when it can't be found.
+func getMyAccount(w http.ResponseWriter, r *http.Request, env *HandlerDependencies) (*db.User, bool) {
+	claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+	if err != nil {
+		env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+		writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+		return nil, false
+	}
+	account, err := env.Database.GetUser(db.ByEmail(claims.Email))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return nil, false
+		}
+		env.SystemLogger.Error("failed to get current user", zap.Error(err))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+	return account, true
+}
+
+// getAccountFromPath returns the account of the id path parameter. It writes the error response
+// and returns false when the account doesn't exist.
+func getAccountFromPath(w http.ResponseWriter, r *http.Request, env *HandlerDependencies) (*db.User, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, "invalid ID", nil, env.SystemLogger)
+		return nil, false
+	}
+	account, err := env.Database.GetUser(db.ByUserID(id))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeResponse(w, http.StatusNotFound, "not found", nil, env.SystemLogger)
+			return nil, false
+		}
+		env.SystemLogger.Error("failed to get user", zap.Error(err), zap.Int64("id", id))
+		writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+		return nil, false
+	}
+	return account, true
+}
@@ -0,0 +1,218 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+func mustLogin(t *testing.T, url string, client *http.Client, email, password string) string {
+	t.Helper()
+	statusCode, resp, err := tu.Login(url, client, &tu.LoginParams{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("couldn't log in: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	return resp.Data.Token
+}
+
+func expectStatus(t *testing.T, url string, client *http.Client, token string, expected int) {
+	t.Helper()
+	statusCode, _, err := tu.GetMyAccount(url, client, token)
+	if err != nil {
+		t.Fatalf("couldn't get account: %s", err)
+	}
+	if statusCode != expected {
+		t.Fatalf("expected status %d, got %d", expected, statusCode)
+	}
+}
+
+func TestSessionsEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+	const userID = 3
+
+	t.Run("1. List my sessions", func(t *testing.T) {
+		mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		statusCode, resp, err := tu.ListSessions(ts.URL, client, userToken, 0)
+		if err != nil {
+			t.Fatalf("couldn't list sessions: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(resp.Data))
+		}
+		current := 0
+		for _, s := range resp.Data {
+			if s.Current {
+				current++
+			}
+			if s.ExpiresAt <= s.CreatedAt || s.IPAddress == "" {
+				t.Fatalf("unexpected session %+v", s)
+			}
+		}
+		if current != 1 {
+			t.Fatalf("expected exactly one current session, got %d", current)
+		}
+	})
+
+	t.Run("2. Logout revokes the session", func(t *testing.T) {
+		token := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		expectStatus(t, ts.URL, client, token, http.StatusOK)
+		statusCode, err := tu.Logout(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't log out: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+		expectStatus(t, ts.URL, client, userToken, http.StatusOK)
+	})
+
+	t.Run("3. Delete one of my sessions", func(t *testing.T) {
+		_ = logs.TakeAll()
+		token := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		_, resp, err := tu.ListSessions(ts.URL, client, token, 0)
+		if err != nil {
+			t.Fatalf("couldn't list sessions: %s", err)
+		}
+		var currentID int64
+		for _, s := range resp.Data {
+			if s.Current {
+				currentID = s.ID
+			}
+		}
+		statusCode, err := tu.DeleteSession(ts.URL, client, userToken, 0, currentID)
+		if err != nil {
+			t.Fatalf("couldn't delete session: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+		expectStatus(t, ts.URL, client, userToken, http.StatusOK)
+
+		statusCode, err = tu.DeleteSession(ts.URL, client, userToken, 0, currentID)
+		if err != nil {
+			t.Fatalf("couldn't delete session: %s", err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+
+		revoked := false
+		for _, e := range logs.All() {
+			if strings.HasPrefix(findStringField(e, "event"), "authn_session_revoked:user@canonical.com,") {
+				revoked = true
+			}
+		}
+		if !revoked {
+			t.Fatalf("expected an audit event for the revoked session")
+		}
+	})
+
+	t.Run("4. Sessions of other accounts are admin only", func(t *testing.T) {
+		statusCode, _, err := tu.ListSessions(ts.URL, client, userToken, 1)
+		if err != nil {
+			t.Fatalf("couldn't list sessions: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, resp, err := tu.ListSessions(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't list sessions: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) == 0 || resp.Data[0].Current {
+			t.Fatalf("expected the sessions of the user, none of them current, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("5. Admin revokes all the sessions of an account", func(t *testing.T) {
+		statusCode, err := tu.DeleteSessions(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't delete sessions: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		expectStatus(t, ts.URL, client, userToken, http.StatusUnauthorized)
+		expectStatus(t, ts.URL, client, adminToken, http.StatusOK)
+	})
+
+	t.Run("6. Revoke my other sessions", func(t *testing.T) {
+		first := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		second := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		statusCode, err := tu.DeleteSessions(ts.URL, client, second, 0)
+		if err != nil {
+			t.Fatalf("couldn't delete sessions: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		expectStatus(t, ts.URL, client, first, http.StatusUnauthorized)
+		expectStatus(t, ts.URL, client, second, http.StatusOK)
+	})
+
+	t.Run("7. Password and role changes revoke the sessions", func(t *testing.T) {
+		token := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		statusCode, _, err := tu.ChangeAccountPassword(ts.URL, client, adminToken, userID, &tu.ChangeAccountPasswordParams{Password: "Admin1234!"})
+		if err != nil {
+			t.Fatalf("couldn't change password: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+
+		token = mustLogin(t, ts.URL, client, "user@canonical.com", "Admin1234!")
+		statusCode, _, err = tu.UpdateAccountRole(ts.URL, client, adminToken, userID, &tu.UpdateAccountRoleParams{RoleID: tu.RoleReadOnly})
+		if err != nil {
+			t.Fatalf("couldn't update role: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+	})
+
+	t.Run("8. Account deletion revokes the sessions", func(t *testing.T) {
+		token := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin1234!")
+		statusCode, _, err := tu.DeleteAccount(ts.URL, client, adminToken, userID)
+		if err != nil {
+			t.Fatalf("couldn't delete account: %s", err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+	})
+
+	t.Run("9. Changing my own role revokes the session it was made with", func(t *testing.T) {
+		token := tu.MustPrepareAccount(t, ts, "other-admin@canonical.com", tu.RoleAdmin, adminToken)
+		_, me, err := tu.GetMyAccount(ts.URL, client, token)
+		if err != nil {
+			t.Fatalf("couldn't get account: %s", err)
+		}
+		statusCode, _, err := tu.UpdateAccountRole(ts.URL, client, token, me.Data.ID, &tu.UpdateAccountRoleParams{RoleID: tu.RoleReadOnly})
+		if err != nil {
+			t.Fatalf("couldn't update role: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		expectStatus(t, ts.URL, client, token, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"go.uber.org/zap"
+)
+
+// signingKeyCheckInterval is how often Notary checks whether the signing keys are due for rotation.
+const signingKeyCheckInterval = time.Hour
+
+type SigningKeyResponse struct {
+	KID       string `json:"kid"`
+	Algorithm string `json:"algorithm"`
+	// Active is true for the key that signs the tokens. The other keys only verify them until ExpiresAt.
+	Active    bool  `json:"active"`
+	CreatedAt int64 `json:"created_at"`
+	RetiredAt int64 `json:"retired_at,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+type RotateSigningKeyResponse struct {
+	KID string `json:"kid"`
+}
+
+// GetJWKS returns the public keys that verify the session tokens of Notary, as a JSON Web Key Set.
+// It doesn't require authentication, so that other services can verify the tokens.
+func GetJWKS(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respBytes, err := json.Marshal(env.SigningKeys.JWKS())
+		if err != nil {
+			env.SystemLogger.Error("error marshalling JWKS", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(respBytes); err != nil {
+			env.SystemLogger.Error("error writing response", zap.Error(err))
+		}
+	}
+}
+
+// ListSigningKeys returns the keys that sign and verify the session tokens, the newest first.
+func ListSigningKeys(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys := []SigningKeyResponse{}
+		for _, key := range env.SigningKeys.Keys() {
+			resp := SigningKeyResponse{
+				KID:       key.KID,
+				Algorithm: key.Algorithm,
+				Active:    key.RetiredAt.IsZero(),
+				CreatedAt: key.CreatedAt.Unix(),
+			}
+			if !resp.Active {
+				resp.RetiredAt = key.RetiredAt.Unix()
+				resp.ExpiresAt = key.ExpiresAt.Unix()
+			}
+			keys = append(keys, resp)
+		}
+		writeResponse(w, http.StatusOK, "", keys, env.SystemLogger)
+	}
+}
+
+// RotateSigningKey creates a new key that signs the session tokens from now on.
+// The previous key keeps verifying the tokens it signed for the grace period, so the sessions go on.
+func RotateSigningKey(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+		if err != nil {
+			env.SystemLogger.Error("failed to get JWT claims from cookie", zap.Error(err))
+			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
+			return
+		}
+		kid, err := env.SigningKeys.Rotate(time.Now())
+		if err != nil {
+			env.SystemLogger.Error("failed to rotate signing key", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		env.AuditLogger.SigningKeyRotated(kid,
+			log.WithActor(claims.Email),
+			log.WithRequest(r),
+		)
+		writeResponse(w, http.StatusCreated, "", RotateSigningKeyResponse{KID: kid}, env.SystemLogger)
+	}
+}
+
+// rotateSigningKeysIfDue rotates the signing keys on schedule, and forgets the keys whose grace period is over.
+func rotateSigningKeysIfDue(env *HandlerDependencies, now time.Time) {
+	kid, err := env.SigningKeys.RotateIfDue(now)
+	if err != nil {
+		env.SystemLogger.Error("failed to rotate signing keys", zap.Error(err))
+		return
+	}
+	if kid != "" {
+		env.SystemLogger.Info("rotated signing key on schedule", zap.String("kid", kid))
+		env.AuditLogger.SigningKeyRotated(kid, log.WithReason("scheduled rotation"))
+	}
+}
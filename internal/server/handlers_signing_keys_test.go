@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mustVerifyWithJWKS verifies a session token the way another service would, with the published keys only.
+func mustVerifyWithJWKS(t *testing.T, url string, client *http.Client, token string) string {
+	t.Helper()
+	statusCode, jwks, err := tu.GetJWKS(url, client)
+	if err != nil {
+		t.Fatalf("couldn't get JWKS: %s", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		keys := jwks.Key(kid)
+		if len(keys) != 1 || !keys[0].IsPublic() {
+			t.Fatalf("expected the JWKS to publish the public key %q, got %+v", kid, jwks.Keys)
+		}
+		return keys[0].Key, nil
+	}, jwt.WithValidMethods([]string{"ES256", "EdDSA"}))
+	if err != nil {
+		t.Fatalf("couldn't verify token with JWKS: %s", err)
+	}
+	return parsed.Header["kid"].(string)
+}
+
+func TestSigningKeysEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t)
+	client := ts.Client()
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	userToken := tu.MustPrepareAccount(t, ts, "user@canonical.com", tu.RoleCertificateRequestor, adminToken)
+
+	var firstKID string
+	t.Run("1. Session tokens verify with the JWKS", func(t *testing.T) {
+		firstKID = mustVerifyWithJWKS(t, ts.URL, client, adminToken)
+		statusCode, resp, err := tu.ListSigningKeys(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't list signing keys: %s", err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 1 || resp.Data[0].KID != firstKID || !resp.Data[0].Active || resp.Data[0].Algorithm != "ES256" {
+			t.Fatalf("expected the key of the token to be active, got %+v", resp.Data)
+		}
+	})
+
+	t.Run("2. Only admins manage signing keys", func(t *testing.T) {
+		statusCode, _, err := tu.ListSigningKeys(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't list signing keys: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+		statusCode, _, err = tu.RotateSigningKey(ts.URL, client, userToken)
+		if err != nil {
+			t.Fatalf("couldn't rotate signing key: %s", err)
+		}
+		if statusCode != http.StatusForbidden {
+			t.Fatalf("expected status %d, got %d", http.StatusForbidden, statusCode)
+		}
+	})
+
+	t.Run("3. Rotating the key keeps the sessions", func(t *testing.T) {
+		statusCode, resp, err := tu.RotateSigningKey(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't rotate signing key: %s", err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+		if resp.Data.KID == "" || resp.Data.KID == firstKID {
+			t.Fatalf("expected a new key, got %q", resp.Data.KID)
+		}
+		if !hasEvent(logs, "sys_signing_key_rotated:"+resp.Data.KID) {
+			t.Fatalf("expected the rotation to be audited")
+		}
+
+		expectStatus(t, ts.URL, client, userToken, http.StatusOK)
+		mustVerifyWithJWKS(t, ts.URL, client, userToken)
+		token := mustLogin(t, ts.URL, client, "user@canonical.com", "Admin123")
+		if kid := mustVerifyWithJWKS(t, ts.URL, client, token); kid != resp.Data.KID {
+			t.Fatalf("expected new tokens to be signed with the new key %q, got %q", resp.Data.KID, kid)
+		}
+
+		statusCode, list, err := tu.ListSigningKeys(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatalf("couldn't list signing keys: %s", err)
+		}
+		if statusCode != http.StatusOK || len(list.Data) != 2 {
+			t.Fatalf("expected the new and the previous keys, got %d %+v", statusCode, list.Data)
+		}
+		if !list.Data[0].Active || list.Data[1].Active || list.Data[1].KID != firstKID || list.Data[1].ExpiresAt <= list.Data[1].RetiredAt {
+			t.Fatalf("expected the previous key to be in its grace period, got %+v", list.Data)
+		}
+	})
+
+	t.Run("4. HMAC tokens are rejected", func(t *testing.T) {
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"email": "admin@canonical.com", "role_id": 0})
+		forged.Header["kid"] = firstKID
+		raw, err := forged.SignedString([]byte{})
+		if err != nil {
+			t.Fatalf("couldn't sign token: %s", err)
+		}
+		expectStatus(t, ts.URL, client, raw, http.StatusUnauthorized)
+	})
+}
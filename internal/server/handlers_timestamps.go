@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/tsa"
+	"go.uber.org/zap"
+)
+
+const (
+	timestampQueryContentType = "application/timestamp-query"
+	timestampReplyContentType = "application/timestamp-reply"
+
+	// maxTimestampQueryBytes is the size of the largest timestamp query that is answered.
+	// Queries only carry a hash, a nonce and a policy, so they are far smaller.
+	maxTimestampQueryBytes = 8 << 10
+)
+
+type TimestampTokenResponse struct {
+	ID            int64  `json:"id"`
+	SerialNumber  string `json:"serial_number"`
+	GenTime       string `json:"gen_time"`
+	PolicyOID     string `json:"policy_oid"`
+	HashAlgorithm string `json:"hash_algorithm"`
+	HashedMessage string `json:"hashed_message"`
+	Nonce         string `json:"nonce"`
+}
+
+// Timestamp handler answers RFC 3161 timestamp queries.
+// Queries that can't be honoured are still answered with a 200 OK, the rejection is carried in the reply.
+// It doesn't require authentication, so the queries of each IP address are rate limited, as every token is recorded.
+func Timestamp(env *HandlerDependencies) http.HandlerFunc {
+	var limiter *ipRateLimiter
+	if env.AppConfig != nil {
+		limiter = newIPRateLimiter(env.TimestampRateLimit)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := r.RemoteAddr
+		if addr, err := remoteAddr(r); err == nil {
+			client = addr.String()
+		}
+		if !limiter.Allow(client) {
+			env.SystemLogger.Info("timestamp query rate limited", zap.String("remote_addr", r.RemoteAddr))
+			w.Header().Set("Retry-After", "60")
+			writeResponse(w, http.StatusTooManyRequests, "too many timestamp queries", nil, env.SystemLogger)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != timestampQueryContentType {
+			writeResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be "+timestampQueryContentType, nil, env.SystemLogger)
+			return
+		}
+		query, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTimestampQueryBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeResponse(w, http.StatusRequestEntityTooLarge, "timestamp query is too large", nil, env.SystemLogger)
+				return
+			}
+			writeResponse(w, http.StatusBadRequest, "couldn't read timestamp query", nil, env.SystemLogger)
+			return
+		}
+
+		reply, token, err := env.TSARepository.Timestamp(query)
+		if err != nil {
+			var rejectionErr *tsa.RejectionError
+			if errors.As(err, &rejectionErr) {
+				env.SystemLogger.Info("timestamp query rejected", zap.Error(err))
+			} else {
+				env.SystemLogger.Error("failed to issue timestamp", zap.Error(err))
+			}
+			if reply == nil {
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+		}
+		if token != nil {
+			env.AuditLogger.TimestampIssued(token.SerialNumber, int(env.TSARepository.CertificateAuthorityID()),
+				log.WithRequest(r),
+			)
+		}
+
+		w.Header().Set("Content-Type", timestampReplyContentType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(reply); err != nil {
+			env.SystemLogger.Error("error writing timestamp reply", zap.Error(err))
+		}
+	}
+}
+
+// ListTimestamps handler returns the log of the timestamp tokens issued by the timestamping authority.
+// It returns a 200 OK on success
+func ListTimestamps(env *HandlerDependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := env.Database.ListTimestampTokens()
+		if err != nil {
+			env.SystemLogger.Error("failed to list timestamp tokens", zap.Error(err))
+			writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+			return
+		}
+		resp := make([]TimestampTokenResponse, 0, len(tokens))
+		for _, token := range tokens {
+			resp = append(resp, TimestampTokenResponse{
+				ID:            token.ID,
+				SerialNumber:  token.SerialNumber,
+				GenTime:       token.GenTime,
+				PolicyOID:     token.PolicyOID,
+				HashAlgorithm: token.HashAlgorithm,
+				HashedMessage: token.HashedMessage,
+				Nonce:         token.Nonce,
+			})
+		}
+		writeResponse(w, http.StatusOK, "", resp, env.SystemLogger)
+	}
+}
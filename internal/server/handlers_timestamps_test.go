@@ -0,0 +1,316 @@
+package server_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/digitorus/timestamp"
+)
+
+var testTimestampPolicyOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+// This is an end-to-end test for the timestamping authority endpoints.
+// The order of the tests is important, as some tests depend on the
+// state of the server after previous tests.
+func TestTimestampingEndToEnd(t *testing.T) {
+	ts, logs := tu.MustPrepareServer(t, tu.WithTimestamping(1, testTimestampPolicyOID, 500*time.Millisecond))
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	readerToken := tu.MustPrepareAccount(t, ts, "reader@canonical.com", tu.RoleReadOnly, adminToken)
+	client := ts.Client()
+
+	mustCreateQuery := func(t *testing.T, data string, opts *timestamp.RequestOptions) []byte {
+		t.Helper()
+		query, err := timestamp.CreateRequest(bytes.NewReader([]byte(data)), opts)
+		if err != nil {
+			t.Fatalf("couldn't create timestamp query: %s", err)
+		}
+		return query
+	}
+
+	t.Run("1. Timestamp query fails when the certificate authority doesn't exist", func(t *testing.T) {
+		statusCode, reply, err := tu.RequestTimestamp(ts.URL, client, mustCreateQuery(t, "artifact", nil), "application/timestamp-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if _, err := timestamp.ParseResponse(reply); err == nil {
+			t.Fatal("expected a failure reply")
+		}
+	})
+
+	t.Run("2. Create self signed certificate authority", func(t *testing.T) {
+		statusCode, _, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{
+			SelfSigned:       true,
+			CommonName:       "Self Signed CA",
+			OrganizationName: "Canonical",
+			NotValidAfter:    "2030-01-01T00:00:00Z",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+	})
+
+	t.Run("3. Timestamp query with the wrong content type is refused", func(t *testing.T) {
+		statusCode, _, err := tu.RequestTimestamp(ts.URL, client, mustCreateQuery(t, "artifact", nil), "application/octet-stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, statusCode)
+		}
+	})
+
+	var firstSigner *x509.Certificate
+	t.Run("4. Timestamp query is granted", func(t *testing.T) {
+		_ = logs.TakeAll()
+		nonce := big.NewInt(424242)
+		query := mustCreateQuery(t, "artifact", &timestamp.RequestOptions{
+			Hash:         crypto.SHA256,
+			Certificates: true,
+			TSAPolicyOID: testTimestampPolicyOID,
+			Nonce:        nonce,
+		})
+		statusCode, reply, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		token, err := timestamp.ParseResponse(reply)
+		if err != nil {
+			t.Fatalf("couldn't parse timestamp reply: %s", err)
+		}
+		req, _ := timestamp.ParseRequest(query) // nolint: errcheck
+		if !bytes.Equal(token.HashedMessage, req.HashedMessage) {
+			t.Fatalf("expected the message imprint to be echoed back")
+		}
+		if token.Nonce == nil || token.Nonce.Cmp(nonce) != 0 {
+			t.Fatalf("expected nonce %s, got %v", nonce, token.Nonce)
+		}
+		if !token.Policy.Equal(testTimestampPolicyOID) {
+			t.Fatalf("expected policy %s, got %s", testTimestampPolicyOID, token.Policy)
+		}
+		if token.Accuracy != 500*time.Millisecond {
+			t.Fatalf("expected accuracy of 500ms, got %s", token.Accuracy)
+		}
+		if time.Since(token.Time) > time.Minute {
+			t.Fatalf("unexpected timestamp time %s", token.Time)
+		}
+		if len(token.Certificates) != 2 {
+			t.Fatalf("expected the TSA certificate and its issuer, got %d certificates", len(token.Certificates))
+		}
+		signer, issuer := token.Certificates[0], token.Certificates[1]
+		if err := signer.CheckSignatureFrom(issuer); err != nil {
+			t.Fatalf("TSA certificate is not issued by the certificate authority: %s", err)
+		}
+		if issuer.Subject.CommonName != "Self Signed CA" {
+			t.Fatalf("expected the TSA certificate to be issued by the certificate authority, got %s", issuer.Subject.CommonName)
+		}
+		if len(signer.ExtKeyUsage) != 1 || signer.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping {
+			t.Fatalf("expected only the timeStamping extended key usage, got %v", signer.ExtKeyUsage)
+		}
+		for _, ext := range signer.Extensions {
+			if ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 37}) && !ext.Critical {
+				t.Fatalf("expected the extended key usage extension to be critical")
+			}
+		}
+		if len(signer.CRLDistributionPoints) != 1 || signer.CRLDistributionPoints[0] != "https://example.com/api/v1/certificate_authorities/1/crl" {
+			t.Fatalf("unexpected CRL distribution points %v", signer.CRLDistributionPoints)
+		}
+		firstSigner = signer
+
+		entries := logs.TakeAll()
+		found := false
+		for _, e := range entries {
+			if findStringField(e, "event") == "timestamp_issued" && findStringField(e, "serial_number") == token.SerialNumber.Text(16) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected timestamp_issued audit event")
+		}
+	})
+
+	t.Run("5. Timestamp queries reuse the TSA certificate", func(t *testing.T) {
+		query := mustCreateQuery(t, "another artifact", &timestamp.RequestOptions{Hash: crypto.SHA512, Certificates: true})
+		statusCode, reply, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		token, err := timestamp.ParseResponse(reply)
+		if err != nil {
+			t.Fatalf("couldn't parse timestamp reply: %s", err)
+		}
+		if token.HashAlgorithm != crypto.SHA512 {
+			t.Fatalf("expected SHA-512 message imprint, got %s", token.HashAlgorithm)
+		}
+		if len(token.Certificates) == 0 || !bytes.Equal(token.Certificates[0].Raw, firstSigner.Raw) {
+			t.Fatalf("expected the same TSA certificate to be used")
+		}
+	})
+
+	t.Run("6. Timestamp queries that can't be honoured are rejected", func(t *testing.T) {
+		queries := map[string][]byte{
+			"unknown policy":        mustCreateQuery(t, "artifact", &timestamp.RequestOptions{TSAPolicyOID: asn1.ObjectIdentifier{1, 2, 3, 4}}),
+			"unsupported algorithm": mustCreateQuery(t, "artifact", &timestamp.RequestOptions{Hash: crypto.SHA1}),
+			"malformed query":       []byte("not a timestamp query"),
+			"empty query":           {},
+		}
+		for name, query := range queries {
+			statusCode, reply, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if statusCode != http.StatusOK {
+				t.Fatalf("%s: expected status %d, got %d", name, http.StatusOK, statusCode)
+			}
+			if _, err := timestamp.ParseResponse(reply); err == nil {
+				t.Fatalf("%s: expected a rejection reply", name)
+			}
+		}
+	})
+
+	t.Run("7. List issued timestamp tokens", func(t *testing.T) {
+		statusCode, resp, err := tu.ListTimestamps(ts.URL, client, readerToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		if len(resp.Data) != 2 {
+			t.Fatalf("expected 2 timestamp tokens, got %d", len(resp.Data))
+		}
+		first := resp.Data[0]
+		if first.PolicyOID != testTimestampPolicyOID.String() || first.HashAlgorithm != "SHA-256" || first.Nonce != big.NewInt(424242).Text(16) {
+			t.Fatalf("unexpected timestamp token %+v", first)
+		}
+		if _, err := hex.DecodeString(first.HashedMessage); err != nil {
+			t.Fatalf("expected a hex encoded message imprint, got %s", first.HashedMessage)
+		}
+		if resp.Data[1].HashAlgorithm != "SHA-512" {
+			t.Fatalf("unexpected timestamp token %+v", resp.Data[1])
+		}
+	})
+
+	t.Run("8. Listing timestamp tokens requires authentication", func(t *testing.T) {
+		statusCode, _, err := tu.ListTimestamps(ts.URL, client, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, statusCode)
+		}
+	})
+
+	t.Run("9. Revoking the TSA certificate replaces it", func(t *testing.T) {
+		statusCode, resp, err := tu.ListCertificateRequests(ts.URL, client, adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		signerPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: firstSigner.Raw}))
+		var signerCSRID int
+		for _, csr := range resp.Data {
+			if strings.HasPrefix(csr.CertificateChain, signerPEM) {
+				signerCSRID = int(csr.ID)
+			}
+		}
+		if signerCSRID == 0 {
+			t.Fatalf("expected the TSA certificate to be listed with the certificate requests")
+		}
+		statusCode, _, err = tu.RevokeCertificateRequest(ts.URL, client, adminToken, signerCSRID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+
+		query := mustCreateQuery(t, "artifact", &timestamp.RequestOptions{Hash: crypto.SHA256, Certificates: true})
+		_, reply, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := timestamp.ParseResponse(reply)
+		if err != nil {
+			t.Fatalf("couldn't parse timestamp reply: %s", err)
+		}
+		if bytes.Equal(token.Certificates[0].Raw, firstSigner.Raw) {
+			t.Fatalf("expected a new TSA certificate once the previous one was revoked")
+		}
+	})
+}
+
+func TestTimestampingLimits(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t, tu.WithTimestamping(1, testTimestampPolicyOID, time.Second), tu.WithTimestampRateLimit(3))
+	client := ts.Client()
+
+	statusCode, _, err := tu.RequestTimestamp(ts.URL, client, make([]byte, 9<<10), "application/timestamp-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d for a large query, got %d", http.StatusRequestEntityTooLarge, statusCode)
+	}
+
+	query, err := timestamp.CreateRequest(bytes.NewReader([]byte("artifact")), nil)
+	if err != nil {
+		t.Fatalf("couldn't create timestamp query: %s", err)
+	}
+	// The large query counted towards the limit.
+	for range 2 {
+		statusCode, _, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+	}
+	statusCode, _, err = tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d once the limit is reached, got %d", http.StatusTooManyRequests, statusCode)
+	}
+}
+
+func TestTimestampingDisabled(t *testing.T) {
+	ts, _ := tu.MustPrepareServer(t)
+	client := ts.Client()
+
+	query, err := timestamp.CreateRequest(bytes.NewReader([]byte("artifact")), nil)
+	if err != nil {
+		t.Fatalf("couldn't create timestamp query: %s", err)
+	}
+	statusCode, _, err := tu.RequestTimestamp(ts.URL, client, query, "application/timestamp-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusNotFound && statusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected the timestamp endpoint to be unavailable, got status %d", statusCode)
+	}
+}
@@ -3,17 +3,21 @@ package server
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/canonical/notary/internal/backends/authentication"
 	"github.com/canonical/notary/internal/backends/authorization"
 	"github.com/canonical/notary/internal/backends/observability/log"
 	"github.com/canonical/notary/internal/backends/observability/metrics"
 	"github.com/canonical/notary/internal/backends/observability/tracing"
+	"github.com/canonical/notary/internal/db"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -28,7 +32,7 @@ const (
 // The middlewareContext type helps middleware receive and pass along information through the middleware chain.
 type middlewareContext struct {
 	responseStatusCode int
-	jwtSecret          []byte
+	signingKeys        *authentication.SigningKeyring
 	systemLogger       *zap.Logger
 	auditLogger        *log.AuditLogger
 	tracer             *tracing.TracingRepository
@@ -143,15 +147,24 @@ func loggingMiddleware(ctx *middlewareContext) middleware {
 	}
 }
 
+// auditedPrincipalContextKey is the key of where requirePermission records the principal of a request,
+// so that auditLoggingMiddleware, which runs before the principal is known, can log it as the actor.
+type auditedPrincipalContextKey struct{}
+
 // auditLoggingMiddleware logs API requests to the audit log.
 // It logs all failed requests, and also successful read-only (GET/HEAD) requests.
+// The actor is the principal that requirePermission authenticated, whatever the way it authenticated,
+// or else the account of the session cookie.
 func auditLoggingMiddleware(ctx *middlewareContext) middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var caller *principal
+			r = r.WithContext(context.WithValue(r.Context(), auditedPrincipalContextKey{}, &caller))
 			next.ServeHTTP(w, r)
 			var actor string
-			claims, err := getClaimsFromCookie(r, ctx.jwtSecret, nil)
-			if err == nil {
+			if caller != nil {
+				actor = caller.claims.Email
+			} else if claims, err := getClaims(r, ctx.signingKeys, nil); err == nil {
 				actor = claims.Email
 			}
 
@@ -226,16 +239,17 @@ func extractResourceType(path string) string {
 	return ""
 }
 
-// requirePermission authorizes a request by verifying the caller's JWT then performing an
+// requirePermission authorizes a request by verifying the caller's JWT, or API token, then performing an
 // OpenFGA Check against "system:notary" for each of the allowedRoles. The first matching
 // role grants access; if none match, 403 Forbidden is returned.
+// API tokens must also have the scope of the route, and the certificate authority it acts on when they are limited to some.
 func requirePermission(
 	allowedRoles []string,
 	env *HandlerDependencies,
 	handler http.HandlerFunc,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		claims, err := getClaimsFromCookie(r, env.Database.JWTSecret, env.AuthnRepository)
+		caller, err := authenticate(r, env)
 		if err != nil {
 			env.AuditLogger.UnauthorizedAccess(
 				log.WithRequest(r),
@@ -245,6 +259,36 @@ func requirePermission(
 			writeResponse(w, http.StatusUnauthorized, "unauthorized", nil, env.SystemLogger)
 			return
 		}
+		if audited, ok := r.Context().Value(auditedPrincipalContextKey{}).(**principal); ok {
+			*audited = caller
+		}
+		claims := caller.claims
+		if caller.token != nil {
+			reason, err := apiTokenDenial(env, caller, r)
+			if err != nil {
+				env.SystemLogger.Error("failed to check the certificate authorities of the API token", zap.Error(err))
+				writeResponse(w, http.StatusInternalServerError, "", nil, env.SystemLogger)
+				return
+			}
+			if reason != "" {
+				env.AuditLogger.APITokenRejected(caller.token.TokenID,
+					log.WithActor(claims.Email),
+					log.WithRequest(r),
+					log.WithReason(reason),
+				)
+				writeResponse(w, http.StatusForbidden, "forbidden: "+reason, nil, env.SystemLogger)
+				return
+			}
+		}
+		if mfaEnrollmentRequired(env, caller.session, db.RoleID(claims.RoleID)) && !slices.Contains(mfaEnrollmentRoutes, r.Pattern) {
+			env.AuditLogger.AccessDenied(claims.Email, r.URL.Path, strings.Join(allowedRoles, ","),
+				log.WithRequest(r),
+				log.WithReason("multi-factor authentication enrollment required"),
+			)
+			writeResponse(w, http.StatusForbidden, "forbidden: multi-factor authentication is required, enroll a TOTP authenticator", nil, env.SystemLogger)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), principalContextKey{}, caller))
 
 		userID := authorization.UserID(claims.Email)
 		const systemObject = "system:notary"
@@ -285,6 +329,213 @@ func requirePermission(
 	}
 }
 
+// authenticateClientCertificate returns the claims of the account that the client certificate of the connection is mapped to.
+func authenticateClientCertificate(r *http.Request, env *HandlerDependencies) (*authentication.NotaryJWTClaims, error) {
+	leaf := r.TLS.PeerCertificates[0]
+	user, err := env.ClientCertRepository.Authenticate(leaf, time.Now())
+	if err != nil {
+		env.AuditLogger.ClientCertificateRejected(leaf.Subject.String(), leaf.SerialNumber.String(), log.WithRequest(r), log.WithReason(err.Error()))
+		return nil, err
+	}
+	return &authentication.NotaryJWTClaims{Email: user.Email, RoleID: int(user.RoleID)}, nil
+}
+
+// apiTokenLastUseResolution is how stale the last use of an API token can be before it is recorded again,
+// so that a busy pipeline doesn't write to the database on every request.
+const apiTokenLastUseResolution = time.Minute
+
+// sessionLastSeenResolution is how stale the last use of a session can be before it is recorded again.
+const sessionLastSeenResolution = time.Minute
+
+// apiTokenDenial returns why the API token of the caller can't call the route of the request, or an empty string if it can.
+func apiTokenDenial(env *HandlerDependencies, caller *principal, r *http.Request) (string, error) {
+	token := caller.token
+	_, pattern, _ := strings.Cut(r.Pattern, " ")
+	scope, ok := routeScopes[r.Pattern]
+	if !ok {
+		return "route not available to API tokens", nil
+	}
+	if !slices.Contains(token.ScopeList(), scope) {
+		return "missing scope " + scope, nil
+	}
+	caIDs := token.CertificateAuthorityIDList()
+	if len(caIDs) == 0 {
+		return "", nil
+	}
+	switch r.Pattern {
+	case "POST /certificate_authorities", "POST /certificate_requests/{id}/certificate":
+		// The certificate authority that issued an uploaded certificate isn't known.
+		return "API token limited to certificate authorities", nil
+	}
+	if strings.HasPrefix(pattern, "/certificate_authorities/{id}") {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || !slices.Contains(caIDs, id) {
+			return "certificate authority not allowed for API token", nil
+		}
+	}
+	if strings.HasPrefix(pattern, "/certificate_requests/{id}") {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			// The handler rejects the invalid ID.
+			return "", nil
+		}
+		allowed, err := certificateRequestAllowed(env, caller, id)
+		if err != nil {
+			return "", err
+		}
+		if !allowed {
+			return "certificate request not allowed for API token", nil
+		}
+	}
+	return "", nil
+}
+
+// certificateAuthorityAllowed returns whether the caller can act on the certificate authority,
+// which is always the case unless it uses an API token limited to other certificate authorities.
+func certificateAuthorityAllowed(r *http.Request, id int64) bool {
+	p, ok := r.Context().Value(principalContextKey{}).(*principal)
+	if !ok || p.token == nil {
+		return true
+	}
+	caIDs := p.token.CertificateAuthorityIDList()
+	return len(caIDs) == 0 || slices.Contains(caIDs, id)
+}
+
+// limitedToCertificateAuthorities returns whether the caller uses an API token limited to some certificate authorities.
+func limitedToCertificateAuthorities(r *http.Request) bool {
+	p, ok := r.Context().Value(principalContextKey{}).(*principal)
+	return ok && p.token != nil && len(p.token.CertificateAuthorityIDList()) != 0
+}
+
+// certificateRequestAllowed returns whether the caller can act on the certificate request. An API token limited to some
+// certificate authorities can only act on the certificate requests whose certificate one of them issued,
+// and on the certificate requests of its account that have no certificate yet.
+// Certificate requests that don't exist are allowed, so that the handler reports them as not found.
+func certificateRequestAllowed(env *HandlerDependencies, caller *principal, id int64) (bool, error) {
+	if caller.token == nil || len(caller.token.CertificateAuthorityIDList()) == 0 {
+		return true, nil
+	}
+	csr, err := env.Database.GetCertificateRequest(db.ByCSRID(id))
+	if errors.Is(err, db.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if csr.CertificateID == 0 {
+		return csr.UserEmail == caller.claims.Email, nil
+	}
+	caID, err := issuingCertificateAuthority(env, csr.CertificateID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(caller.token.CertificateAuthorityIDList(), caID), nil
+}
+
+// issuingCertificateAuthority returns the ID of the certificate authority that issued the certificate,
+// or 0 if no certificate authority of Notary did.
+func issuingCertificateAuthority(env *HandlerDependencies, certificateID int64) (int64, error) {
+	certificate, err := env.Database.GetCertificate(db.ByCertificateID(certificateID))
+	if err != nil {
+		return 0, err
+	}
+	if certificate.IssuerID == 0 {
+		return 0, nil
+	}
+	ca, err := env.Database.GetCertificateAuthority(db.ByCertificateAuthorityCertificateID(certificate.IssuerID))
+	if errors.Is(err, db.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ca.CertificateAuthorityID, nil
+}
+
+// principalContextKey is the key of the principal of the authenticated requests.
+type principalContextKey struct{}
+
+// principal is the account that authenticated a request. Its token is set when it authenticated with an API token,
+// and session when it authenticated with the session cookie of a Notary login.
+type principal struct {
+	claims  *authentication.NotaryJWTClaims
+	token   *db.APIToken
+	session *db.Session
+}
+
+// authenticate returns the caller, from the API token in the Authorization header if there is one,
+// from the session cookie if there is one, or else from the client certificate of the connection.
+func authenticate(r *http.Request, env *HandlerDependencies) (*principal, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && authentication.IsAPIToken(raw) {
+		claims, token, err := authenticateAPIToken(r, env, raw)
+		if err != nil {
+			return nil, err
+		}
+		return &principal{claims: claims, token: token}, nil
+	}
+	if !hasSessionCookie(r) && env.ClientCertRepository != nil && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		claims, err := authenticateClientCertificate(r, env)
+		if err != nil {
+			return nil, err
+		}
+		return &principal{claims: claims}, nil
+	}
+	claims, err := getClaims(r, env.SigningKeys, env.AuthnRepository)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Provider != authentication.ProviderLocal {
+		return &principal{claims: claims}, nil
+	}
+	session, err := authenticateSession(env, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &principal{claims: claims, session: session}, nil
+}
+
+// authenticateSession returns the session that the claims of the session cookie reference, and records its use.
+func authenticateSession(env *HandlerDependencies, claims *authentication.NotaryJWTClaims) (*db.Session, error) {
+	now := time.Now()
+	session, err := authentication.VerifySession(env.Database, claims, now)
+	if err != nil {
+		return nil, err
+	}
+	if now.Sub(time.Unix(session.LastSeenAt, 0)) >= sessionLastSeenResolution {
+		if err := env.Database.UpdateSessionLastSeen(session.ID, now); err != nil {
+			env.SystemLogger.Warn("failed to record the last use of a session", zap.Error(err))
+		}
+	}
+	return session, nil
+}
+
+// hasSessionCookie reports whether the request carries a session cookie.
+func hasSessionCookie(r *http.Request) bool {
+	c, err := r.Cookie(CookieSessionTokenKey)
+	return err == nil && c.Value != ""
+}
+
+// authenticateAPIToken returns the claims of the service account that the API token belongs to, along with the token.
+func authenticateAPIToken(r *http.Request, env *HandlerDependencies, raw string) (*authentication.NotaryJWTClaims, *db.APIToken, error) {
+	now := time.Now()
+	token, user, err := authentication.VerifyAPIToken(env.Database, raw, now)
+	if err != nil {
+		tokenID := ""
+		if token != nil {
+			tokenID = token.TokenID
+		}
+		env.AuditLogger.APITokenRejected(tokenID, log.WithRequest(r), log.WithReason(err.Error()))
+		return nil, nil, err
+	}
+	if now.Sub(time.Unix(token.LastUsedAt, 0)) >= apiTokenLastUseResolution {
+		if err := env.Database.UpdateAPITokenLastUse(token.ID, now); err != nil {
+			env.SystemLogger.Warn("failed to record the use of an API token", zap.Error(err), zap.Int64("id", token.ID))
+		}
+	}
+	return &authentication.NotaryJWTClaims{Email: user.Email, RoleID: int(user.RoleID)}, token, nil
+}
+
 // firstUserOrAdmin allows unauthenticated access when zero users exist (first-run setup).
 // This enables the initial admin account to be created without pre-existing credentials.
 // Once any user exists, it falls back to requirePermission with adminOnly access.
@@ -306,7 +557,12 @@ func firstUserOrAdmin(env *HandlerDependencies, handler http.HandlerFunc) http.H
 	}
 }
 
-func getClaimsFromCookie(r *http.Request, jwtSecret []byte, oidcConfig *authentication.OIDCRepository) (*authentication.NotaryJWTClaims, error) {
+// getClaims returns the claims of the caller: those of the API token or client certificate that requirePermission
+// authenticated the request with, or those of the session cookie.
+func getClaims(r *http.Request, signingKeys *authentication.SigningKeyring, oidcConfig *authentication.OIDCRepository) (*authentication.NotaryJWTClaims, error) {
+	if p, ok := r.Context().Value(principalContextKey{}).(*principal); ok {
+		return p.claims, nil
+	}
 	c, err := r.Cookie(CookieSessionTokenKey)
 	if err != nil {
 		return nil, fmt.Errorf("cookie not found")
@@ -315,22 +571,22 @@ func getClaimsFromCookie(r *http.Request, jwtSecret []byte, oidcConfig *authenti
 		return nil, fmt.Errorf("cookie value not found")
 	}
 
-	claims, err := getClaimsFromJWT(c.Value, jwtSecret, oidcConfig)
+	claims, err := getClaimsFromJWT(c.Value, signingKeys, oidcConfig)
 	if err != nil {
 		return nil, fmt.Errorf("token is not valid: %s", err)
 	}
 	return claims, nil
 }
 
-func getClaimsFromJWT(rawToken string, jwtSecret []byte, oidcConfig *authentication.OIDCRepository) (*authentication.NotaryJWTClaims, error) {
+func getClaimsFromJWT(rawToken string, signingKeys *authentication.SigningKeyring, oidcConfig *authentication.OIDCRepository) (*authentication.NotaryJWTClaims, error) {
 	v := authentication.NewVerifier([]authentication.ProviderConfig{
 		{
 			Provider: oidcConfig,
 			Type:     authentication.ProviderOIDC,
 		},
 		{
-			Secret: jwtSecret,
-			Type:   authentication.ProviderLocal,
+			Keyring: signingKeys,
+			Type:    authentication.ProviderLocal,
 		},
 	})
 	claims, err := v.VerifyToken(context.Background(), rawToken)
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ipRateLimiter limits how many requests each IP address can make per minute.
+// An IP address can make its whole allowance at once, which is then given back over a minute.
+type ipRateLimiter struct {
+	perMinute int
+
+	mu        sync.Mutex
+	limiters  map[string]*ipLimiter
+	lastSweep time.Time
+}
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newIPRateLimiter returns a limiter of perMinute requests per IP address, or nil when perMinute is 0.
+func newIPRateLimiter(perMinute int) *ipRateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &ipRateLimiter{
+		perMinute: perMinute,
+		limiters:  make(map[string]*ipLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow returns whether the IP address can make a request now. A nil limiter allows every request.
+func (l *ipRateLimiter) Allow(ip string) bool {
+	if l == nil {
+		return true
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// The allowance of an IP address that made no request for a minute is whole again, so it can be forgotten.
+	if now.Sub(l.lastSweep) >= time.Minute {
+		for ip, entry := range l.limiters {
+			if now.Sub(entry.lastSeen) >= time.Minute {
+				delete(l.limiters, ip)
+			}
+		}
+		l.lastSweep = now
+	}
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(l.perMinute)), l.perMinute)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	notaryacme "github.com/canonical/notary/internal/acme"
+	"github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
+	"go.uber.org/zap"
+)
+
+const (
+	certificateRenewalJobType        = "certificate_renewal"
+	certificateRenewalJobMaxAttempts = 3
+)
+
+type certificateRenewalJobPayload struct {
+	CertificateRequestID int64 `json:"certificate_request_id"`
+	// CertificateID is the certificate that the job renews, so that a renewal that failed
+	// isn't queued again until the certificate of the request changes.
+	CertificateID int64 `json:"certificate_id"`
+}
+
+// renewalCandidate is a certificate request whose certificate can be renewed automatically.
+type renewalCandidate struct {
+	csr              *db.CertificateRequest
+	certificateChain string
+	signingMethod    string
+	// issuer is the certificate authority that issued the certificate, when it was signed by a Notary CA.
+	issuer *db.CertificateAuthority
+}
+
+// getRenewalCandidate works out how the certificate of a certificate request is renewed.
+// It returns ErrNotFound if the certificate can't be renewed, for example because it was uploaded.
+func getRenewalCandidate(database *db.DatabaseRepository, csrID int64) (*renewalCandidate, error) {
+	csr, err := database.GetCertificateRequest(db.ByCSRID(csrID))
+	if err != nil {
+		return nil, err
+	}
+	csrWithChain, err := database.GetCertificateRequestAndChain(db.ByCSRID(csrID))
+	if err != nil {
+		return nil, err
+	}
+	if csrWithChain.CertificateChain == "" {
+		return nil, fmt.Errorf("%w: certificate request has no certificate", db.ErrNotFound)
+	}
+	candidate := &renewalCandidate{csr: csr, certificateChain: csrWithChain.CertificateChain, signingMethod: csr.SigningMethod}
+	if csr.SigningMethod == db.SigningMethodACME {
+		return candidate, nil
+	}
+	// Certificates signed before the signing method was recorded are renewed by the CA that issued them, if any.
+	candidate.issuer, err = database.GetIssuingCertificateAuthority(db.ByCSRID(csrID))
+	if err != nil {
+		return nil, err
+	}
+	candidate.signingMethod = db.SigningMethodCA
+	return candidate, nil
+}
+
+// optedIn reports whether the owner of the certificate request or of the issuing CA opted into automatic renewal.
+func (c *renewalCandidate) optedIn() bool {
+	return c.csr.AutoRenew || (c.issuer != nil && c.issuer.AutoRenew)
+}
+
+// due reports whether the certificate should be renewed at now. ACME servers that offer renewal
+// information decide for themselves, otherwise the certificate is renewed once it enters the renewal window.
+func (c *renewalCandidate) due(env *HandlerDependencies, now time.Time) (bool, error) {
+	if c.signingMethod == db.SigningMethodACME {
+		acmeRepo, err := c.issuingACMERepository(env)
+		if err == nil {
+			due, err := acmeRepo.RenewalDue(c.certificateChain, now)
+			if err == nil {
+				return due, nil
+			}
+			if !errors.Is(err, notaryacme.ErrRenewalInfoUnsupported) {
+				env.SystemLogger.Warn("failed to get ACME renewal information, falling back to the renewal window",
+					zap.Error(err), zap.Int64("csr_id", c.csr.CSR_ID))
+			}
+		}
+	}
+	block, _ := pem.Decode([]byte(c.certificateChain))
+	if block == nil {
+		return false, errors.New("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return !now.Before(cert.NotAfter.Add(-env.RenewalWindow)), nil
+}
+
+// issuingACMERepository creates an ACMERepository for the ACME server that issued the certificate.
+// Certificates obtained before the ACME server was recorded use the server that the ACME routes send the request to.
+func (c *renewalCandidate) issuingACMERepository(env *HandlerDependencies) (*notaryacme.ACMERepository, error) {
+	if c.csr.ACMEServerID != nil {
+		return acmeRepositoryForServer(env, *c.csr.ACMEServerID)
+	}
+	return routedACMERepository(env, c.csr.CSR)
+}
+
+// scheduleRenewals queues a renewal job for every certificate that opted into automatic renewal and is due.
+func scheduleRenewals(env *HandlerDependencies, now time.Time) error {
+	csrs, err := env.Database.ListActiveCertificateRequests()
+	if err != nil {
+		return fmt.Errorf("failed to list active certificate requests: %w", err)
+	}
+	for _, csr := range csrs {
+		candidate, err := getRenewalCandidate(env.Database, csr.CSR_ID)
+		if errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			env.SystemLogger.Warn("failed to check certificate for renewal", zap.Error(err), zap.Int64("csr_id", csr.CSR_ID))
+			continue
+		}
+		if !candidate.optedIn() {
+			continue
+		}
+		due, err := candidate.due(env, now)
+		if err != nil {
+			env.SystemLogger.Warn("failed to check certificate for renewal", zap.Error(err), zap.Int64("csr_id", csr.CSR_ID))
+			continue
+		}
+		if !due {
+			continue
+		}
+		payload, err := json.Marshal(certificateRenewalJobPayload{CertificateRequestID: csr.CSR_ID, CertificateID: candidate.csr.CertificateID})
+		if err != nil {
+			return fmt.Errorf("failed to encode certificate renewal job: %w", err)
+		}
+		// A renewal of this certificate that is pending, or that ran out of attempts, isn't queued again.
+		// The owner was told about the failure, and a new certificate is renewed as usual.
+		latest, err := env.Database.GetLatestJob(certificateRenewalJobType, string(payload))
+		if realError(err) {
+			return fmt.Errorf("failed to check for a previous certificate renewal job: %w", err)
+		}
+		if rowFound(err) && latest.Status != db.JobStatusSucceeded && latest.Status != db.JobStatusCancelled {
+			continue
+		}
+		if _, err := env.JobRunner.Enqueue(certificateRenewalJobType, string(payload), certificateRenewalJobMaxAttempts, ""); err != nil {
+			return fmt.Errorf("failed to queue certificate renewal job: %w", err)
+		}
+		env.SystemLogger.Info("queued certificate renewal", zap.Int64("csr_id", csr.CSR_ID), zap.String("signing_method", candidate.signingMethod))
+	}
+	return nil
+}
+
+// certificateRenewalJob submits the stored CSR of a certificate request to the signing method
+// that issued its current certificate, and stores the new certificate chain.
+func certificateRenewalJob(env *HandlerDependencies) jobs.Handler {
+	return func(ctx context.Context, job *db.Job) error {
+		var payload certificateRenewalJobPayload
+		if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+			return jobs.Permanent(fmt.Errorf("invalid job payload: %w", err))
+		}
+		candidate, err := getRenewalCandidate(env.Database, payload.CertificateRequestID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return jobs.Permanent(errors.New("certificate request has no renewable certificate"))
+			}
+			return fmt.Errorf("failed to get certificate request: %w", err)
+		}
+		if payload.CertificateID != 0 && candidate.csr.CertificateID != payload.CertificateID {
+			// The certificate was renewed or signed again since the job was queued.
+			return nil
+		}
+		id := strconv.FormatInt(payload.CertificateRequestID, 10)
+		if err := renewCertificate(ctx, env, candidate); err != nil {
+			if (job.Attempts >= job.MaxAttempts || jobs.IsPermanent(err)) && context.Cause(ctx) == nil {
+				env.AuditLogger.CertificateRenewalFailed(id, candidate.csr.UserEmail, log.WithReason(err.Error()))
+				notifyRenewalOwner(env, candidate, err)
+			}
+			return err
+		}
+		env.AuditLogger.CertificateRenewed(id, candidate.signingMethod, candidate.csr.UserEmail)
+		notifyRenewalOwner(env, candidate, nil)
+
+		if env.ShouldEnablePebbleNotifications {
+			err := SendPebbleNotification(CertificateUpdate, payload.CertificateRequestID)
+			if err != nil {
+				env.SystemLogger.Warn("pebble notify failed", zap.Error(err))
+			}
+		}
+		return nil
+	}
+}
+
+// notifyRenewalOwner emails the owner of the certificate request that its certificate was renewed,
+// or that it couldn't be renewed when renewalErr is set. Nothing is sent when no SMTP server is configured.
+func notifyRenewalOwner(env *HandlerDependencies, candidate *renewalCandidate, renewalErr error) {
+	if env.EmailSender == nil || candidate.csr.UserEmail == "" {
+		return
+	}
+	link := fmt.Sprintf("https://%s/certificate_requests", env.ExternalHostname)
+	subject := "Your certificate was renewed"
+	body := fmt.Sprintf("The certificate of your certificate request %d on Notary was renewed automatically.\n\n"+
+		"Download the new certificate at %s.\n", candidate.csr.CSR_ID, link)
+	if renewalErr != nil {
+		subject = "Your certificate couldn't be renewed"
+		body = fmt.Sprintf("The automatic renewal of the certificate of your certificate request %d on Notary failed:\n\n"+
+			"%s\n\n"+
+			"It won't be attempted again for this certificate. Renew it by signing the certificate request again at %s.\n",
+			candidate.csr.CSR_ID, renewalErr, link)
+	}
+	if err := env.EmailSender.Send(candidate.csr.UserEmail, subject, body); err != nil {
+		env.SystemLogger.Error("failed to email the owner of a renewed certificate", zap.Error(err),
+			zap.Int64("csr_id", candidate.csr.CSR_ID), zap.String("email", candidate.csr.UserEmail))
+	}
+}
+
+// renewCertificate obtains and stores a new certificate chain for the candidate with its signing method.
+func renewCertificate(ctx context.Context, env *HandlerDependencies, candidate *renewalCandidate) error {
+	filter := db.ByCSRID(candidate.csr.CSR_ID)
+	if candidate.signingMethod == db.SigningMethodCA {
+		err := env.Database.SignCertificateRequest(filter, db.ByCertificateAuthorityDenormalizedID(candidate.issuer.CertificateAuthorityID), env.ExternalHostname)
+		if err != nil {
+			return fmt.Errorf("failed to sign certificate request with certificate authority %d: %w", candidate.issuer.CertificateAuthorityID, err)
+		}
+		return nil
+	}
+	// Renewals follow the ACME routes, so that moving a domain to another ACME server applies from the next renewal.
+	acmeRepo, err := routedACMERepository(env, candidate.csr.CSR)
+	if err != nil {
+		return err
+	}
+	// The certificate is renewed with the options it was ordered with, unless it is now routed to another ACME server.
+	var order db.ACMEOrderOptions
+	if candidate.csr.ACMEServerID != nil && *candidate.csr.ACMEServerID == acmeRepo.ServerID() {
+		order = db.ACMEOrderOptions{PreferredChain: candidate.csr.ACMEPreferredChain, Profile: candidate.csr.ACMEProfile}
+	}
+	cert, err := acmeRepo.RenewCSR(ctx, candidate.csr.CSR, candidate.certificateChain, order)
+	if err != nil {
+		return fmt.Errorf("failed to renew certificate via ACME: %w", err)
+	}
+	// A cancellation that arrives once the certificate is issued still keeps it from being stored.
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	if _, err := env.Database.AddCertificateChainToCertificateRequest(filter, cert.Chain); err != nil {
+		return fmt.Errorf("failed to store ACME certificate chain: %w", err)
+	}
+	if err := env.Database.SetCertificateRequestACMEIssuer(filter, cert.ServerID, cert.AccountID, cert.Order, cert.ChainIssuer); err != nil {
+		return fmt.Errorf("failed to record ACME issuer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/backends/email"
+	"github.com/canonical/notary/internal/server"
+	tu "github.com/canonical/notary/internal/testutils"
+)
+
+// mustWaitForRenewal waits until the certificate chain of the certificate request differs from chain, and returns the new one.
+func mustWaitForRenewal(t *testing.T, url string, client *http.Client, token string, id int, chain string) string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		_, response, err := tu.GetCertificateRequest(url, client, token, id)
+		if err != nil {
+			t.Fatalf("GetCertificateRequest() error: %v", err)
+		}
+		if response.Data.CertificateChain != "" && response.Data.CertificateChain != chain {
+			return response.Data.CertificateChain
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("certificate request %d was not renewed", id)
+	return ""
+}
+
+func mustGetCertificateChain(t *testing.T, url string, client *http.Client, token string, id int) string {
+	t.Helper()
+	statusCode, response, err := tu.GetCertificateRequest(url, client, token, id)
+	if err != nil {
+		t.Fatalf("GetCertificateRequest() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	return response.Data.CertificateChain
+}
+
+func TestCertificateRenewal(t *testing.T) {
+	// Every certificate is within a window this large, so opted in certificates are renewed on every check.
+	ts, logs := tu.MustPrepareServer(t, tu.WithRenewal(100*365*24*time.Hour, 100*time.Millisecond))
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, _, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{
+		SelfSigned:    true,
+		CommonName:    "Self Signed CA",
+		NotValidAfter: "2030-01-01T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+	}
+	for _, csr := range []string{tu.AppleCSR, tu.BananaCSR} {
+		statusCode, _, err = tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: csr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d", http.StatusCreated, statusCode)
+		}
+	}
+	for _, id := range []int{2, 3} {
+		statusCode, _, err = tu.SignCertificateRequest(ts.URL, client, adminToken, id, server.SignCertificateRequestParams{CertificateAuthorityID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d", http.StatusAccepted, statusCode)
+		}
+	}
+
+	t.Run("certificates are not renewed without opting in", func(t *testing.T) {
+		chain := mustGetCertificateChain(t, ts.URL, client, adminToken, 2)
+		time.Sleep(300 * time.Millisecond)
+		if got := mustGetCertificateChain(t, ts.URL, client, adminToken, 2); got != chain {
+			t.Fatal("expected the certificate not to be renewed")
+		}
+	})
+
+	t.Run("certificate request opted into renewal", func(t *testing.T) {
+		chain := mustGetCertificateChain(t, ts.URL, client, adminToken, 2)
+		statusCode, _, err := tu.UpdateCertificateRequestRenewal(ts.URL, client, adminToken, 2, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		_, response, err := tu.GetCertificateRequest(ts.URL, client, adminToken, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !response.Data.AutoRenew {
+			t.Fatal("expected auto_renew to be true")
+		}
+		mustWaitForRenewal(t, ts.URL, client, adminToken, 2, chain)
+		var haveRenewed bool
+		for _, e := range logs.All() {
+			if findStringField(e, "event") == "cert_renewed" && findStringField(e, "owner") == "admin@canonical.com" {
+				haveRenewed = true
+				break
+			}
+		}
+		if !haveRenewed {
+			t.Fatal("expected a cert_renewed audit entry naming the owner")
+		}
+
+		statusCode, _, err = tu.UpdateCertificateRequestRenewal(ts.URL, client, adminToken, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+	})
+
+	t.Run("certificate authority opted into renewal", func(t *testing.T) {
+		chain := mustGetCertificateChain(t, ts.URL, client, adminToken, 3)
+		statusCode, _, err := tu.UpdateCertificateAuthorityRenewal(ts.URL, client, adminToken, 1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+		}
+		_, response, err := tu.GetCertificateAuthority(ts.URL, client, adminToken, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !response.Data.AutoRenew {
+			t.Fatal("expected auto_renew to be true")
+		}
+		mustWaitForRenewal(t, ts.URL, client, adminToken, 3, chain)
+	})
+
+	t.Run("renewal settings of missing resources", func(t *testing.T) {
+		statusCode, _, err := tu.UpdateCertificateRequestRenewal(ts.URL, client, adminToken, 100, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+		// The certificate request of a certificate authority is not renewed on its own.
+		statusCode, _, err = tu.UpdateCertificateRequestRenewal(ts.URL, client, adminToken, 1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+		statusCode, _, err = tu.UpdateCertificateAuthorityRenewal(ts.URL, client, adminToken, 100, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if statusCode != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, statusCode)
+		}
+	})
+}
+
+// mustListRenewalJobs returns the certificate renewal jobs.
+func mustListRenewalJobs(t *testing.T, url string, client *http.Client, token string) []server.JobResponse {
+	t.Helper()
+	statusCode, resp, err := tu.ListJobs(url, client, token)
+	if err != nil {
+		t.Fatalf("ListJobs() error: %v", err)
+	}
+	if statusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, statusCode)
+	}
+	var renewals []server.JobResponse
+	for _, job := range resp.Data {
+		if job.Type == "certificate_renewal" {
+			renewals = append(renewals, job)
+		}
+	}
+	return renewals
+}
+
+func TestCertificateRenewalNotifiesOwner(t *testing.T) {
+	smtpServer := tu.MustStartSMTPServer(t)
+	ts, _ := tu.MustPrepareServer(t,
+		tu.WithRenewal(100*365*24*time.Hour, 100*time.Millisecond),
+		tu.WithJobRetryBackoff(time.Millisecond),
+		tu.WithSMTP(&email.SMTPSender{
+			Host:     smtpServer.Host,
+			Port:     smtpServer.Port,
+			From:     "notary@example.com",
+			Security: email.SMTPSecurityNone,
+		}),
+	)
+	adminToken := tu.MustPrepareAccount(t, ts, "admin@canonical.com", tu.RoleAdmin, "")
+	client := ts.Client()
+
+	statusCode, _, err := tu.CreateCertificateAuthority(ts.URL, client, adminToken, tu.CreateCertificateAuthorityParams{
+		SelfSigned:    true,
+		CommonName:    "Self Signed CA",
+		NotValidAfter: "2030-01-01T00:00:00Z",
+	})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create certificate authority: %d, %v", statusCode, err)
+	}
+	statusCode, _, err = tu.CreateCertificateRequest(ts.URL, client, adminToken, tu.CreateCertificateRequestParams{CSR: tu.AppleCSR})
+	if err != nil || statusCode != http.StatusCreated {
+		t.Fatalf("couldn't create certificate request: %d, %v", statusCode, err)
+	}
+	sign := func() {
+		t.Helper()
+		statusCode, _, err := tu.SignCertificateRequest(ts.URL, client, adminToken, 2, server.SignCertificateRequestParams{CertificateAuthorityID: "1"})
+		if err != nil || statusCode != http.StatusAccepted {
+			t.Fatalf("couldn't sign certificate request: %d, %v", statusCode, err)
+		}
+	}
+	setCAEnabled := func(status string) {
+		t.Helper()
+		statusCode, _, err := tu.UpdateCertificateAuthority(ts.URL, client, adminToken, 1, tu.UpdateCertificateAuthorityParams{Status: status})
+		if err != nil || statusCode != http.StatusOK {
+			t.Fatalf("couldn't update certificate authority: %d, %v", statusCode, err)
+		}
+	}
+	sign()
+
+	t.Run("a renewal that runs out of attempts is not queued again", func(t *testing.T) {
+		// The certificate can't be renewed while its certificate authority is disabled.
+		setCAEnabled("legacy")
+		statusCode, _, err := tu.UpdateCertificateRequestRenewal(ts.URL, client, adminToken, 2, true)
+		if err != nil || statusCode != http.StatusOK {
+			t.Fatalf("couldn't opt into renewal: %d, %v", statusCode, err)
+		}
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			renewals := mustListRenewalJobs(t, ts.URL, client, adminToken)
+			if len(renewals) == 1 && renewals[0].Status == "failed" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the renewal to fail, got %+v", renewals)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if renewals := mustListRenewalJobs(t, ts.URL, client, adminToken); len(renewals) != 1 {
+			t.Fatalf("expected the failed renewal not to be queued again, got %+v", renewals)
+		}
+		messages := smtpServer.Messages()
+		if len(messages) != 1 || messages[0].To[0] != "admin@canonical.com" || !strings.Contains(messages[0].Data, "Subject: Your certificate couldn't be renewed") {
+			t.Fatalf("expected the owner to be told about the failure, got %+v", messages)
+		}
+	})
+
+	t.Run("a new certificate is renewed again", func(t *testing.T) {
+		setCAEnabled("active")
+		chain := mustGetCertificateChain(t, ts.URL, client, adminToken, 2)
+		sign()
+		chain = mustWaitForRenewal(t, ts.URL, client, adminToken, 2, chain)
+		mustWaitForRenewal(t, ts.URL, client, adminToken, 2, chain)
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			messages := smtpServer.Messages()
+			if len(messages) > 1 && strings.Contains(messages[1].Data, "Subject: Your certificate was renewed") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the owner to be told about the renewal, got %+v", messages)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+}
@@ -47,13 +47,17 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 	// Certificate request endpoints
 	apiV1Router.HandleFunc("GET /certificate_requests", requirePermission(allRoles, config, ListCertificateRequests(config)))
 	apiV1Router.HandleFunc("POST /certificate_requests", requirePermission(requestorRoles, config, CreateCertificateRequest(config)))
+	apiV1Router.HandleFunc("POST /certificate_requests/generate", requirePermission(requestorRoles, config, GenerateCertificateRequest(config)))
 	apiV1Router.HandleFunc("GET /certificate_requests/{id}", requirePermission(allRoles, config, GetCertificateRequest(config)))
 	apiV1Router.HandleFunc("DELETE /certificate_requests/{id}", requirePermission(managerRoles, config, DeleteCertificateRequest(config)))
 	apiV1Router.HandleFunc("POST /certificate_requests/{id}/reject", requirePermission(managerRoles, config, RejectCertificateRequest(config)))
 	apiV1Router.HandleFunc("POST /certificate_requests/{id}/sign", requirePermission(managerRoles, config, SignCertificateRequest(config)))
+	apiV1Router.HandleFunc("GET /certificate_requests/{id}/certificate", requirePermission(allRoles, config, GetCertificateRequestCertificate(config)))
+	apiV1Router.HandleFunc("POST /certificate_requests/{id}/pkcs12", requirePermission(requestorRoles, config, GetCertificateRequestPKCS12(config)))
 	apiV1Router.HandleFunc("POST /certificate_requests/{id}/certificate", requirePermission(managerRoles, config, PostCertificateRequestCertificate(config)))
 	apiV1Router.HandleFunc("DELETE /certificate_requests/{id}/certificate", requirePermission(managerRoles, config, DeleteCertificate(config)))
 	apiV1Router.HandleFunc("POST /certificate_requests/{id}/certificate/revoke", requirePermission(managerRoles, config, RevokeCertificate(config)))
+	apiV1Router.HandleFunc("PUT /certificate_requests/{id}/renewal", requirePermission(managerRoles, config, UpdateCertificateRequestRenewal(config)))
 
 	// Certificate authority endpoints
 	apiV1Router.HandleFunc("GET /certificate_authorities", requirePermission(readerRoles, config, ListCertificateAuthorities(config)))
@@ -63,8 +67,10 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 	apiV1Router.HandleFunc("DELETE /certificate_authorities/{id}", requirePermission(managerRoles, config, DeleteCertificateAuthority(config)))
 	apiV1Router.HandleFunc("POST /certificate_authorities/{id}/sign", requirePermission(managerRoles, config, SignCertificateAuthority(config)))
 	apiV1Router.HandleFunc("POST /certificate_authorities/{id}/certificate", requirePermission(managerRoles, config, PostCertificateAuthorityCertificate(config)))
+	apiV1Router.HandleFunc("GET /certificate_authorities/{id}/chain", requirePermission(allRoles, config, GetCertificateAuthorityChain(config)))
 	apiV1Router.HandleFunc("GET /certificate_authorities/{id}/crl", GetCertificateAuthorityCRL(config))
 	apiV1Router.HandleFunc("POST /certificate_authorities/{id}/revoke", requirePermission(managerRoles, config, RevokeCertificateAuthorityCertificate(config)))
+	apiV1Router.HandleFunc("PUT /certificate_authorities/{id}/renewal", requirePermission(managerRoles, config, UpdateCertificateAuthorityRenewal(config)))
 
 	// ACME server endpoints
 	apiV1Router.HandleFunc("GET /acme_servers", requirePermission(readerRoles, config, ListACMEServers(config)))
@@ -73,6 +79,16 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 	apiV1Router.HandleFunc("PUT /acme_servers/{id}", requirePermission(managerRoles, config, UpdateACMEServer(config)))
 	apiV1Router.HandleFunc("DELETE /acme_servers/{id}", requirePermission(managerRoles, config, DeleteACMEServer(config)))
 	apiV1Router.HandleFunc("PUT /acme_servers/{id}/active", requirePermission(managerRoles, config, SetActiveACMEServer(config)))
+	apiV1Router.HandleFunc("POST /acme_servers/{id}/test", requirePermission(managerRoles, config, TestACMEServer(config)))
+	apiV1Router.HandleFunc("PUT /acme_servers/{id}/account", requirePermission(managerRoles, config, LinkACMEServerAccount(config)))
+	apiV1Router.HandleFunc("GET /acme_accounts", requirePermission(readerRoles, config, ListACMEAccounts(config)))
+	apiV1Router.HandleFunc("GET /acme_accounts/{id}", requirePermission(readerRoles, config, GetACMEAccount(config)))
+	apiV1Router.HandleFunc("POST /acme_accounts/{id}/refresh", requirePermission(managerRoles, config, RefreshACMEAccount(config)))
+	apiV1Router.HandleFunc("PUT /acme_accounts/{id}/contacts", requirePermission(managerRoles, config, UpdateACMEAccountContacts(config)))
+	apiV1Router.HandleFunc("POST /acme_accounts/{id}/key_rollover", requirePermission(managerRoles, config, RollOverACMEAccountKey(config)))
+	apiV1Router.HandleFunc("POST /acme_accounts/{id}/deactivate", requirePermission(managerRoles, config, DeactivateACMEAccount(config)))
+	apiV1Router.HandleFunc("GET /acme_routes", requirePermission(readerRoles, config, ListACMERoutes(config)))
+	apiV1Router.HandleFunc("PUT /acme_routes", requirePermission(managerRoles, config, ReplaceACMERoutes(config)))
 
 	// Account endpoints
 	apiV1Router.HandleFunc("GET /accounts", requirePermission(adminOnly, config, ListAccounts(config)))
@@ -83,10 +99,56 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 	apiV1Router.HandleFunc("POST /accounts/{id}/change_password", requirePermission(adminOnly, config, ChangeAccountPassword(config)))
 	apiV1Router.HandleFunc("PUT /accounts/{id}/role", requirePermission(adminOnly, config, UpdateAccountRole(config)))
 	apiV1Router.HandleFunc("POST /accounts/me/change_password", requirePermission(allRoles, config, ChangeMyPassword(config)))
+	apiV1Router.HandleFunc("GET /signing_keys", requirePermission(adminOnly, config, ListSigningKeys(config)))
+	apiV1Router.HandleFunc("POST /signing_keys/rotate", requirePermission(adminOnly, config, RotateSigningKey(config)))
+	apiV1Router.HandleFunc("POST /invitations", requirePermission(adminOnly, config, CreateInvitation(config)))
+	apiV1Router.HandleFunc("POST /accounts/{id}/password_reset", requirePermission(adminOnly, config, CreatePasswordReset(config)))
+	apiV1Router.HandleFunc("POST /set_password", SetPassword(config))
+	apiV1Router.HandleFunc("POST /service_accounts", requirePermission(adminOnly, config, CreateServiceAccount(config)))
+	apiV1Router.HandleFunc("GET /accounts/{id}/tokens", requirePermission(adminOnly, config, ListAPITokens(config)))
+	apiV1Router.HandleFunc("POST /accounts/{id}/tokens", requirePermission(adminOnly, config, CreateAPIToken(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/tokens/{token_id}", requirePermission(adminOnly, config, DeleteAPIToken(config)))
+	apiV1Router.HandleFunc("GET /accounts/me/sessions", requirePermission(allRoles, config, ListMySessions(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/me/sessions", requirePermission(allRoles, config, DeleteMySessions(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/me/sessions/{session_id}", requirePermission(allRoles, config, DeleteMySession(config)))
+	apiV1Router.HandleFunc("GET /accounts/{id}/sessions", requirePermission(adminOnly, config, ListAccountSessions(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/sessions", requirePermission(adminOnly, config, DeleteAccountSessions(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/sessions/{session_id}", requirePermission(adminOnly, config, DeleteAccountSession(config)))
+	apiV1Router.HandleFunc("GET /accounts/me/mfa", requirePermission(allRoles, config, GetMyMFA(config)))
+	apiV1Router.HandleFunc("POST /accounts/me/mfa/totp", requirePermission(allRoles, config, EnrollTOTP(config)))
+	apiV1Router.HandleFunc("POST /accounts/me/mfa/totp/verify", requirePermission(allRoles, config, VerifyTOTP(config)))
+	apiV1Router.HandleFunc("POST /accounts/me/mfa/totp/disable", requirePermission(allRoles, config, DisableMyTOTP(config)))
+	apiV1Router.HandleFunc("POST /accounts/me/mfa/recovery_codes", requirePermission(allRoles, config, RegenerateRecoveryCodes(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/mfa", requirePermission(adminOnly, config, ResetAccountMFA(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/lockout", requirePermission(adminOnly, config, UnlockAccountLogin(config)))
+	apiV1Router.HandleFunc("GET /lockouts", requirePermission(adminOnly, config, ListLoginLockouts(config)))
+	apiV1Router.HandleFunc("DELETE /lockouts/ip/{address}", requirePermission(adminOnly, config, UnlockIPAddressLogin(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/me/oidc", requirePermission(allRoles, config, UnlinkMyOIDC(config)))
+	apiV1Router.HandleFunc("DELETE /accounts/{id}/oidc", requirePermission(adminOnly, config, UnlinkAccountOIDC(config)))
+	apiV1Router.HandleFunc("GET /oidc_identities", requirePermission(adminOnly, config, ListOIDCIdentities(config)))
+
+	// Background job endpoints
+	apiV1Router.HandleFunc("GET /jobs", requirePermission(readerRoles, config, ListJobs(config)))
+	apiV1Router.HandleFunc("GET /jobs/{id}", requirePermission(readerRoles, config, GetJob(config)))
+	apiV1Router.HandleFunc("POST /jobs/{id}/cancel", requirePermission(managerRoles, config, CancelJob(config)))
+
+	// Timestamping authority endpoints
+	if config.TSARepository != nil {
+		apiV1Router.HandleFunc("POST /timestamp", Timestamp(config))
+		apiV1Router.HandleFunc("GET /timestamps", requirePermission(readerRoles, config, ListTimestamps(config)))
+	}
+
+	// acme-dns compatible service endpoints
+	if config.ACMEDNSRepository != nil {
+		apiV1Router.HandleFunc("GET /acme_dns_registrations", requirePermission(readerRoles, config, ListACMEDNSRegistrations(config)))
+		apiV1Router.HandleFunc("GET /acme_dns_registrations/{id}", requirePermission(readerRoles, config, GetACMEDNSRegistration(config)))
+		apiV1Router.HandleFunc("DELETE /acme_dns_registrations/{id}", requirePermission(managerRoles, config, DeleteACMEDNSRegistration(config)))
+	}
 
 	if config.AuthnRepository != nil {
 		apiV1Router.HandleFunc("GET /oauth/login", LoginOIDC(config))
 		apiV1Router.HandleFunc("GET /oauth/callback", CallbackOIDC(config))
+		apiV1Router.HandleFunc("GET /accounts/me/oidc/link", requirePermission(allRoles, config, StartOIDCLink(config)))
 	}
 
 	apiV1Router.HandleFunc("GET /config", requirePermission(allRoles, config, GetConfigContent(config)))
@@ -97,7 +159,7 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 		config.SystemLogger.Fatal("Failed to create frontend file server", zap.Error(err))
 	}
 	ctx := middlewareContext{
-		jwtSecret:    config.Database.JWTSecret,
+		signingKeys:  config.SigningKeys,
 		systemLogger: config.SystemLogger,
 		auditLogger:  config.AuditLogger,
 		tracer:       config.TracingRepository,
@@ -116,10 +178,19 @@ func NewRouter(config *HandlerDependencies) http.Handler {
 
 	router := http.NewServeMux()
 	router.HandleFunc("POST /login", Login(config))
+	router.HandleFunc("POST /login/mfa", LoginMFA(config))
 	router.HandleFunc("POST /logout", Logout(config))
 	router.HandleFunc("GET /status", GetStatus(config))
+	router.HandleFunc("GET /.well-known/jwks.json", GetJWKS(config))
 	router.Handle("/metrics", m.Handler)
 	router.Handle("/api/v1/", http.StripPrefix("/api/v1", apiMiddlewareStack(apiV1Router)))
+	if config.ACMEDNSRepository != nil {
+		// The acme-dns API lives outside of /api/v1, under the paths that acme-dns clients expect.
+		acmeDNSRouter := http.NewServeMux()
+		acmeDNSRouter.HandleFunc("POST /register", requirePermission(requestorRoles, config, RegisterACMEDNS(config)))
+		acmeDNSRouter.HandleFunc("POST /update", UpdateACMEDNS(config))
+		router.Handle("/acme-dns/", http.StripPrefix("/acme-dns", apiMiddlewareStack(acmeDNSRouter)))
+	}
 	router.Handle("/", metricsMiddlewareStack(frontendHandler))
 
 	return router
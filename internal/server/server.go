@@ -2,6 +2,7 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net/http"
@@ -29,6 +30,7 @@ func New(appCfg *config.AppConfig, appEnv *config.AppEnvironment) (*Server, erro
 		AppEnvironment: appEnv,
 	}
 	router := NewRouter(cfg)
+	warnUnsupportedDNSProviders(cfg)
 
 	if appEnv.AuthnRepository != nil {
 		cfg.StateStore = NewStateStore()
@@ -45,6 +47,29 @@ func New(appCfg *config.AppConfig, appEnv *config.AppEnvironment) (*Server, erro
 
 		appEnv.SystemLogger.Info("OIDC authentication enabled with state store")
 	}
+	if appEnv.JobRunner != nil {
+		appEnv.JobRunner.Register(acmeSignJobType, acmeSignJob(cfg))
+		appEnv.JobRunner.Register(certificateRenewalJobType, certificateRenewalJob(cfg))
+		if appCfg.RenewalCheckInterval > 0 {
+			appEnv.JobRunner.Every(appCfg.RenewalCheckInterval, func(context.Context) {
+				if err := scheduleRenewals(cfg, time.Now()); err != nil {
+					appEnv.SystemLogger.Error("failed to schedule certificate renewals", zap.Error(err))
+				}
+			})
+		}
+		if appEnv.SigningKeys != nil {
+			checkInterval := signingKeyCheckInterval
+			if appCfg.SigningKeyRotationInterval > 0 && appCfg.SigningKeyRotationInterval < checkInterval {
+				checkInterval = appCfg.SigningKeyRotationInterval
+			}
+			appEnv.JobRunner.Every(checkInterval, func(context.Context) {
+				rotateSigningKeysIfDue(cfg, time.Now())
+			})
+		}
+		if err := appEnv.JobRunner.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start job runner: %w", err)
+		}
+	}
 	if appEnv.TracingRepository != nil {
 		router = otelhttp.NewHandler(
 			router,
@@ -67,6 +92,11 @@ func New(appCfg *config.AppConfig, appEnv *config.AppEnvironment) (*Server, erro
 			Certificates: []tls.Certificate{serverCerts},
 		},
 	}
+	if appEnv.ClientCertRepository != nil {
+		// Client certificates are verified against the trusted certificate authorities by requirePermission
+		// rather than during the handshake, as the certificate authorities and their CRLs change at runtime.
+		s.TLSConfig.ClientAuth = tls.RequestClientCert
+	}
 	return &Server{
 		Server: s,
 	}, err
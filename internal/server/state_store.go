@@ -5,6 +5,13 @@ import (
 	"time"
 )
 
+// The types of the OAuth flows that a state belongs to.
+const (
+	StateTypeLogin = "login"
+	// StateTypeLink is the type of the flows that link an OIDC identity to the account of UserID.
+	StateTypeLink = "link"
+)
+
 // StateEntry represents a stored OAuth state with metadata
 type StateEntry struct {
 	CreatedAt time.Time
@@ -35,33 +42,51 @@ func (s *StateStore) Store(state string, userAgent string) {
 	s.states[state] = StateEntry{
 		CreatedAt: time.Now(),
 		UserAgent: userAgent,
-		Type:      "login", // Default to login type
+		Type:      StateTypeLogin,
+	}
+}
+
+// StoreLink saves a state parameter for linking an OIDC identity to the account of userID
+func (s *StateStore) StoreLink(state string, userAgent string, userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state] = StateEntry{
+		CreatedAt: time.Now(),
+		UserAgent: userAgent,
+		UserID:    &userID,
+		Type:      StateTypeLink,
 	}
 }
 
 // Validate checks if a state is valid and removes it (one-time use)
 // Returns true if the state is valid, false otherwise
 func (s *StateStore) Validate(state string, userAgent string) bool {
+	_, ok := s.Consume(state, userAgent)
+	return ok
+}
+
+// Consume checks if a state is valid and removes it (one-time use)
+// Returns the entry of the state and whether it is valid
+func (s *StateStore) Consume(state string, userAgent string) (*StateEntry, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	entry, exists := s.states[state]
 	if !exists {
-		return false
+		return nil, false
 	}
+	delete(s.states, state)
 
 	if time.Since(entry.CreatedAt) > 5*time.Minute {
-		delete(s.states, state)
-		return false
+		return nil, false
 	}
 
 	if entry.UserAgent != userAgent {
-		delete(s.states, state)
-		return false
+		return nil, false
 	}
 
-	delete(s.states, state)
-	return true
+	return &entry, true
 }
 
 // Get retrieves a state entry without deleting it (for inspecting metadata)
@@ -264,3 +264,58 @@ func TestStateStore_GetAndDelete(t *testing.T) {
 		}
 	})
 }
+
+func TestStateStore_Consume(t *testing.T) {
+	t.Run("Consume returns the login entry", func(t *testing.T) {
+		store := NewStateStore()
+		state := "login-state"
+		userAgent := "Mozilla/5.0"
+
+		store.Store(state, userAgent)
+
+		entry, ok := store.Consume(state, userAgent)
+		if !ok {
+			t.Fatal("expected state to be consumed")
+		}
+		if entry.Type != StateTypeLogin {
+			t.Errorf("expected type %q, got %q", StateTypeLogin, entry.Type)
+		}
+		if entry.UserID != nil {
+			t.Error("expected no user ID for a login state")
+		}
+	})
+
+	t.Run("Consume returns the link entry with its user", func(t *testing.T) {
+		store := NewStateStore()
+		state := "link-state"
+		userAgent := "Mozilla/5.0"
+
+		store.StoreLink(state, userAgent, 42)
+
+		entry, ok := store.Consume(state, userAgent)
+		if !ok {
+			t.Fatal("expected state to be consumed")
+		}
+		if entry.Type != StateTypeLink {
+			t.Errorf("expected type %q, got %q", StateTypeLink, entry.Type)
+		}
+		if entry.UserID == nil || *entry.UserID != 42 {
+			t.Errorf("expected user ID 42, got %v", entry.UserID)
+		}
+
+		if _, ok := store.Consume(state, userAgent); ok {
+			t.Error("expected second consume to fail - state should be one-time use")
+		}
+	})
+
+	t.Run("Consume fails for wrong user agent", func(t *testing.T) {
+		store := NewStateStore()
+		state := "link-state"
+
+		store.StoreLink(state, "Mozilla/5.0 (Chrome)", 42)
+
+		if _, ok := store.Consume(state, "Mozilla/5.0 (Firefox)"); ok {
+			t.Error("expected consume to fail for wrong user agent")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package testutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/pebble/v2/ca"
+	pebbledb "github.com/letsencrypt/pebble/v2/db"
+	"github.com/letsencrypt/pebble/v2/va"
+	"github.com/letsencrypt/pebble/v2/wfe"
+)
+
+// MustStartPebble runs a Pebble ACME server in the test process and returns its directory URL.
+// Pebble validates HTTP-01 challenges on httpPort and TLS-ALPN-01 challenges on tlsPort of the identifier.
+// The ACME client is configured to trust the Pebble TLS certificate through LEGO_CA_CERTIFICATES.
+func MustStartPebble(t *testing.T, httpPort, tlsPort int) string {
+	t.Helper()
+	directoryURL, _ := mustStartPebble(t, httpPort, tlsPort, nil, 0)
+	return directoryURL
+}
+
+// MustStartPebbleWithEAB runs a Pebble ACME server that requires External Account Binding.
+// macKeys maps the accepted key IDs to their base64url encoded HMAC keys.
+func MustStartPebbleWithEAB(t *testing.T, httpPort, tlsPort int, macKeys map[string]string) string {
+	t.Helper()
+	directoryURL, _ := mustStartPebble(t, httpPort, tlsPort, macKeys, 0)
+	return directoryURL
+}
+
+// MustStartPebbleWithAlternateChains runs a Pebble ACME server that offers alternateRoots chains
+// besides the default one. It returns the directory URL and the common names of the roots, the default one first.
+func MustStartPebbleWithAlternateChains(t *testing.T, httpPort, tlsPort, alternateRoots int) (string, []string) {
+	t.Helper()
+	return mustStartPebble(t, httpPort, tlsPort, nil, alternateRoots)
+}
+
+// PebbleShortLivedProfile is a certificate profile offered by the Pebble ACME servers besides the default one.
+// Its certificates are valid for PebbleShortLivedValidity.
+const (
+	PebbleShortLivedProfile  = "shortlived"
+	PebbleShortLivedValidity = 6 * 24 * time.Hour
+)
+
+func mustStartPebble(t *testing.T, httpPort, tlsPort int, macKeys map[string]string, alternateRoots int) (string, []string) {
+	t.Helper()
+	t.Setenv("PEBBLE_VA_NOSLEEP", "1")
+	t.Setenv("PEBBLE_WFE_NONCEREJECT", "0")
+
+	logger := log.New(io.Discard, "", 0)
+	store := pebbledb.NewMemoryStore()
+	for keyID, key := range macKeys {
+		if err := store.AddExternalAccountKeyByID(keyID, key); err != nil {
+			t.Fatalf("couldn't add Pebble external account key: %s", err)
+		}
+	}
+	pebbleCA := ca.New(logger, store, "", "rsa", alternateRoots, 1, map[string]ca.Profile{
+		"default":               {Description: "The default profile"},
+		PebbleShortLivedProfile: {Description: "A short-lived profile", ValidityPeriod: uint64(PebbleShortLivedValidity.Seconds())},
+	})
+	pebbleVA := va.New(logger, httpPort, tlsPort, false, "", store)
+	pebbleWFE := wfe.New(logger, store, pebbleVA, pebbleCA, []string{"pebble.letsencrypt.org"}, false, len(macKeys) > 0, 0, 0)
+
+	ts := httptest.NewTLSServer(pebbleWFE.Handler())
+	t.Cleanup(ts.Close)
+
+	certPath := filepath.Join(t.TempDir(), "pebble.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(certPath, certPEM, 0o600); err != nil {
+		t.Fatalf("couldn't write Pebble certificate: %s", err)
+	}
+	t.Setenv("LEGO_CA_CERTIFICATES", certPath)
+
+	roots := make([]string, pebbleCA.GetNumberOfRootCerts())
+	for i := range roots {
+		roots[i] = pebbleCA.GetRootCert(i).Cert.Subject.CommonName
+	}
+	return ts.URL + wfe.DirectoryPath, roots
+}
+
+// MustGetFreePort returns a local TCP port that nothing is listening on.
+func MustGetFreePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't find a free port: %s", err)
+	}
+	defer l.Close()
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		t.Fatalf("couldn't parse listener address: %s", err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("couldn't parse port: %s", err)
+	}
+	return p
+}
+
+// MustGenerateCSR returns a PEM encoded certificate request for the given DNS names.
+func MustGenerateCSR(t *testing.T, dnsNames ...string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %s", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsNames[0]},
+		DNSNames: dnsNames,
+	}, key)
+	if err != nil {
+		t.Fatalf("couldn't create certificate request: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+// MustGenerateACMEAccountKey returns a PEM encoded P-256 key, as the keys of the ACME accounts are stored.
+func MustGenerateACMEAccountKey(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("couldn't generate key: %s", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("couldn't marshal key: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
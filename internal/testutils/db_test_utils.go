@@ -3,12 +3,14 @@ package testutils
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/canonical/notary/internal/backends/authentication"
 	"github.com/canonical/notary/internal/backends/authorization"
 	"github.com/canonical/notary/internal/backends/encryption"
 	"github.com/canonical/notary/internal/config"
 	"github.com/canonical/notary/internal/db"
+	"github.com/canonical/notary/internal/jobs"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -33,12 +35,6 @@ func MustPrepareEmptyDB(t *testing.T) *db.DatabaseRepository {
 		t.Fatalf("Couldn't set up encryption key: %s", err)
 	}
 
-	// Set up JWT secret for the database
-	err = authentication.SetUpJWTSecret(database)
-	if err != nil {
-		t.Fatalf("Couldn't set up JWT secret: %s", err)
-	}
-
 	t.Cleanup(func() {
 		err := database.Close()
 		if err != nil {
@@ -70,6 +66,12 @@ func MustCreateTestAppConfig(t *testing.T) *config.AppConfig {
 		TLSCertificate:                  []byte(TestServerCertificate),
 		TLSPrivateKey:                   []byte(TestServerKey),
 		LoggingConfig:                   loggingConfig,
+		RenewalWindow:                   720 * time.Hour,
+		RenewalCheckInterval:            time.Hour,
+		PasswordInvitationLifetime:      72 * time.Hour,
+		PasswordResetLifetime:           24 * time.Hour,
+		SigningKeyAlgorithm:             authentication.SigningAlgorithmES256,
+		SigningKeyGracePeriod:           time.Hour,
 	}
 }
 
@@ -92,11 +94,22 @@ func MustCreateTestAppEnvironment(t *testing.T, database *db.DatabaseRepository)
 		t.Fatalf("failed to initialize OpenFGA: %s", err)
 	}
 
+	signingKeys, err := authentication.NewSigningKeyring(database, authentication.SigningAlgorithmES256, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to set up signing keys: %s", err)
+	}
+
+	// The runner is started by server.New, it has to stop before the database is closed.
+	jobRunner := jobs.NewRunner(database, logger)
+	t.Cleanup(jobRunner.Stop)
+
 	return &config.AppEnvironment{
 		Database:             database,
 		SystemLogger:         logger,
 		AuditLogger:          nil, // Can be set up as needed
 		EncryptionRepository: encryptionRepo,
 		AuthzRepository:      authzRepo,
+		SigningKeys:          signingKeys,
+		JobRunner:            jobRunner,
 	}
 }
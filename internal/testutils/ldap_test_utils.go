@@ -0,0 +1,367 @@
+package testutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LDAPEntry is an entry of the directory of a test LDAP server. The entries with a password can be bound to.
+type LDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// LDAPBind is a successful bind to a test LDAP server.
+type LDAPBind struct {
+	DN string
+	// TLS is set when the bind was made after StartTLS.
+	TLS bool
+}
+
+// LDAPServer is an LDAP server that runs in the test process. It supports simple binds, searches with
+// equality, presence, and, or and not filters, and StartTLS.
+type LDAPServer struct {
+	// URL is the ldap URL of the server.
+	URL string
+	// RootCAs trust the certificate of the server after StartTLS.
+	RootCAs *x509.CertPool
+
+	entries   []LDAPEntry
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	binds []LDAPBind
+}
+
+// The LDAP protocol operations and result codes that the test server uses.
+const (
+	ldapBindRequest        = 0
+	ldapBindResponse       = 1
+	ldapUnbindRequest      = 2
+	ldapSearchRequest      = 3
+	ldapSearchResultEntry  = 4
+	ldapSearchResultDone   = 5
+	ldapExtendedRequest    = 23
+	ldapExtendedResponse   = 24
+	ldapStartTLSOID        = "1.3.6.1.4.1.1466.20037"
+	ldapSuccess            = 0
+	ldapProtocolError      = 2
+	ldapNoSuchObject       = 32
+	ldapInvalidCredentials = 49
+	ldapUnwillingToPerform = 53
+)
+
+// MustStartLDAPServer runs an LDAP server with the entries in the test process.
+func MustStartLDAPServer(t *testing.T, entries ...LDAPEntry) *LDAPServer {
+	t.Helper()
+	cert, roots := mustGenerateLocalServerCertificate(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen for LDAP connections: %s", err)
+	}
+	s := &LDAPServer{
+		URL:       "ldap://" + l.Addr().String(),
+		RootCAs:   roots,
+		entries:   entries,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+	}
+	var wg sync.WaitGroup
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close() // nolint: errcheck
+		wg.Wait()
+	})
+	return s
+}
+
+// Binds returns the successful binds to the server, in order.
+func (s *LDAPServer) Binds() []LDAPBind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]LDAPBind(nil), s.binds...)
+}
+
+func (s *LDAPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }() // nolint: errcheck
+	isTLS := false
+	for {
+		if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			return
+		}
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldapBindRequest:
+			code := s.bind(op, isTLS)
+			if _, err := conn.Write(ldapResult(messageID, ldapBindResponse, code).Bytes()); err != nil {
+				return
+			}
+		case ldapSearchRequest:
+			for _, response := range s.search(messageID, op) {
+				if _, err := conn.Write(response.Bytes()); err != nil {
+					return
+				}
+			}
+		case ldapExtendedRequest:
+			if isTLS || len(op.Children) == 0 || op.Children[0].Data.String() != ldapStartTLSOID {
+				if _, err := conn.Write(ldapResult(messageID, ldapExtendedResponse, ldapUnwillingToPerform).Bytes()); err != nil {
+					return
+				}
+				continue
+			}
+			if _, err := conn.Write(ldapResult(messageID, ldapExtendedResponse, ldapSuccess).Bytes()); err != nil {
+				return
+			}
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			isTLS = true
+		case ldapUnbindRequest:
+			return
+		default:
+			if _, err := conn.Write(ldapResult(messageID, ldapExtendedResponse, ldapProtocolError).Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind checks a simple bind request and returns its result code. An empty password is an unauthenticated bind,
+// which succeeds like on most servers.
+func (s *LDAPServer) bind(op *ber.Packet, isTLS bool) int64 {
+	if len(op.Children) < 3 {
+		return ldapProtocolError
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+	if password == "" {
+		return ldapSuccess
+	}
+	entry := s.entry(dn)
+	if entry == nil || entry.Password == "" || entry.Password != password {
+		return ldapInvalidCredentials
+	}
+	s.mu.Lock()
+	s.binds = append(s.binds, LDAPBind{DN: entry.DN, TLS: isTLS})
+	s.mu.Unlock()
+	return ldapSuccess
+}
+
+// search returns the responses to a search request: an entry for every match, and the result.
+func (s *LDAPServer) search(messageID int64, op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{ldapResult(messageID, ldapSearchResultDone, ldapProtocolError)}
+	}
+	base, _ := op.Children[0].Value.(string)
+	scope, _ := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, attribute := range op.Children[7].Children {
+		if name, ok := attribute.Value.(string); ok {
+			attributes = append(attributes, name)
+		}
+	}
+
+	var responses []*ber.Packet
+	found := false
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, base) {
+			found = true
+		}
+		if !ldapInScope(entry.DN, base, scope) || !ldapMatches(filter, &entry) {
+			continue
+		}
+		responses = append(responses, ldapSearchEntry(messageID, &entry, attributes))
+	}
+	if scope == 0 && !found {
+		return []*ber.Packet{ldapResult(messageID, ldapSearchResultDone, ldapNoSuchObject)}
+	}
+	return append(responses, ldapResult(messageID, ldapSearchResultDone, ldapSuccess))
+}
+
+func (s *LDAPServer) entry(dn string) *LDAPEntry {
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].DN, dn) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+// ldapInScope reports whether the entry with dn is in the scope of a search of base: the base object (0),
+// its children (1), or its whole subtree (2).
+func ldapInScope(dn, base string, scope int64) bool {
+	dn, base = strings.ToLower(dn), strings.ToLower(base)
+	switch scope {
+	case 0:
+		return dn == base
+	case 1:
+		i := strings.Index(dn, ",")
+		return i >= 0 && dn[i+1:] == base
+	default:
+		return dn == base || strings.HasSuffix(dn, ","+base)
+	}
+}
+
+// ldapMatches reports whether the entry matches the filter. The attribute names and values are case insensitive.
+func ldapMatches(filter *ber.Packet, entry *LDAPEntry) bool {
+	switch filter.Tag {
+	case 0: // and
+		for _, child := range filter.Children {
+			if !ldapMatches(child, entry) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, child := range filter.Children {
+			if ldapMatches(child, entry) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return len(filter.Children) == 1 && !ldapMatches(filter.Children[0], entry)
+	case 3: // equality
+		if len(filter.Children) != 2 {
+			return false
+		}
+		name, _ := filter.Children[0].Value.(string)
+		value, _ := filter.Children[1].Value.(string)
+		if strings.EqualFold(name, "dn") || strings.EqualFold(name, "distinguishedName") {
+			return strings.EqualFold(entry.DN, value)
+		}
+		for _, v := range ldapAttribute(entry, name) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		name := filter.Data.String()
+		return strings.EqualFold(name, "objectClass") || len(ldapAttribute(entry, name)) > 0
+	default:
+		return false
+	}
+}
+
+func ldapAttribute(entry *LDAPEntry, name string) []string {
+	for n, values := range entry.Attributes {
+		if strings.EqualFold(n, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func ldapSearchEntry(messageID int64, entry *LDAPEntry, attributes []string) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+	attributesPacket := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.Attributes {
+		if !ldapRequested(attributes, name) {
+			continue
+		}
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributesPacket.AppendChild(attribute)
+	}
+	op.AppendChild(attributesPacket)
+	packet.AppendChild(op)
+	return packet
+}
+
+// ldapRequested reports whether the attribute with name is returned by a search of attributes.
+// No attributes, or *, request them all.
+func ldapRequested(attributes []string, name string) bool {
+	if len(attributes) == 0 {
+		return true
+	}
+	for _, attribute := range attributes {
+		if attribute == "*" || strings.EqualFold(attribute, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func ldapResult(messageID int64, tag ber.Tag, code int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	packet.AppendChild(op)
+	return packet
+}
+
+// mustGenerateLocalServerCertificate returns a self-signed certificate for 127.0.0.1, and a pool that trusts it.
+func mustGenerateLocalServerCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("couldn't generate server key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("couldn't create server certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("couldn't parse server certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, roots
+}
@@ -2,10 +2,14 @@ package testutils
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/json"
 	"encoding/pem"
+	"io"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
@@ -14,15 +18,29 @@ import (
 	"testing"
 	"time"
 
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/canonical/notary/internal/acmedns"
+	"github.com/canonical/notary/internal/backends/authentication"
+	"github.com/canonical/notary/internal/backends/authorization"
+	"github.com/canonical/notary/internal/backends/email"
 	internalLog "github.com/canonical/notary/internal/backends/observability/log"
+	"github.com/canonical/notary/internal/config"
+	"github.com/canonical/notary/internal/db"
 	"github.com/canonical/notary/internal/server"
+	"github.com/canonical/notary/internal/tsa"
+	"github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v4"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/oauth2"
 )
 
-// MustPrepareServer starts a test server and returns it along with observed audit logs.
-func MustPrepareServer(t *testing.T) (*httptest.Server, *observer.ObservedLogs) {
+// ServerOption customizes the configuration and the environment of a test server prepared by MustPrepareServer.
+type ServerOption func(t *testing.T, appCfg *config.AppConfig, appEnv *config.AppEnvironment)
+
+// MustPrepareServer starts a test server customized with opts, and returns it along with observed audit logs.
+func MustPrepareServer(t *testing.T, opts ...ServerOption) (*httptest.Server, *observer.ObservedLogs) {
 	t.Helper()
 
 	db := MustPrepareEmptyDB(t)
@@ -33,18 +51,171 @@ func MustPrepareServer(t *testing.T) (*httptest.Server, *observer.ObservedLogs)
 	appCfg := MustCreateTestAppConfig(t)
 	appEnv := MustCreateTestAppEnvironment(t, db)
 	appEnv.AuditLogger = internalLog.NewAuditLogger(auditZap)
+	for _, opt := range opts {
+		opt(t, appCfg, appEnv)
+	}
 
 	srv, err := server.New(appCfg, appEnv)
 	if err != nil {
 		t.Fatalf("Couldn't get server: %s", err)
 	}
-	testServer := httptest.NewTLSServer(srv.Handler)
+	testServer := httptest.NewUnstartedServer(srv.Handler)
+	testServer.TLS = &tls.Config{ClientAuth: srv.TLSConfig.ClientAuth}
+	testServer.StartTLS()
 	t.Cleanup(func() {
 		testServer.Close()
 	})
 	return testServer, logs
 }
 
+// WithDatabase stores the database of the test server in database.
+func WithDatabase(database **db.DatabaseRepository) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		*database = appEnv.Database
+	}
+}
+
+// WithAuthzRepository stores the OpenFGA repository of the test server in authzRepo.
+func WithAuthzRepository(authzRepo **authorization.AuthzRepository) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		*authzRepo = appEnv.AuthzRepository
+	}
+}
+
+// WithTimestamping enables a timestamping authority backed by the given CA.
+func WithTimestamping(caID int64, policyOID asn1.ObjectIdentifier, accuracy time.Duration) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.TSARepository = tsa.NewTSARepository(caID, policyOID, accuracy, appCfg.ExternalHostname, appEnv.Database)
+	}
+}
+
+// WithTimestampRateLimit answers perMinute timestamp queries per IP address.
+func WithTimestampRateLimit(perMinute int) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, _ *config.AppEnvironment) {
+		appCfg.TimestampRateLimit = perMinute
+	}
+}
+
+// WithRenewal looks for certificates to renew every checkInterval, and renews them once they are within window of their expiry.
+func WithRenewal(window, checkInterval time.Duration) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, _ *config.AppEnvironment) {
+		appCfg.RenewalWindow = window
+		appCfg.RenewalCheckInterval = checkInterval
+	}
+}
+
+// WithJobRetryBackoff retries the failed background jobs after backoff, without growing it.
+func WithJobRetryBackoff(backoff time.Duration) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.JobRunner.RetryBackoff = backoff
+		appEnv.JobRunner.MaxRetryBackoff = backoff
+	}
+}
+
+// WithSMTP emails invitations, password reset tokens and renewal notices with sender.
+func WithSMTP(sender *email.SMTPSender) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.EmailSender = sender
+	}
+}
+
+// WithACMEWebroot lets the ACME servers use a webroot within directory.
+func WithACMEWebroot(directory string) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, _ *config.AppEnvironment) {
+		appCfg.ACMEWebrootDirectory = directory
+	}
+}
+
+// WithACMEDNS enables the acme-dns compatible service for domain. Its DNS server isn't started.
+func WithACMEDNS(domain string) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.ACMEDNSRepository = acmedns.NewACMEDNSRepository(domain, "", nil, "", appEnv.Database, appEnv.SystemLogger)
+	}
+}
+
+// WithClientCertificates authenticates the clients that present a certificate issued by one of the certificate
+// authorities, mapping them to accounts with identity.
+func WithClientCertificates(identity string, certificateAuthorityIDs ...int64) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.ClientCertRepository = authentication.NewClientCertificateRepository(certificateAuthorityIDs, identity, appEnv.Database)
+	}
+}
+
+// WithMFARequired requires the accounts of the roles to use multi-factor authentication.
+func WithMFARequired(roles ...db.RoleID) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, _ *config.AppEnvironment) {
+		appCfg.MFARequiredRoles = roles
+	}
+}
+
+// WithLockout locks out the accounts and IP addresses with too many failed login attempts.
+func WithLockout(maxAttempts, maxAttemptsPerIP int, duration, maxDuration time.Duration) ServerOption {
+	return func(_ *testing.T, appCfg *config.AppConfig, _ *config.AppEnvironment) {
+		appCfg.LoginMaxAttempts = maxAttempts
+		appCfg.LoginMaxAttemptsPerIP = maxAttemptsPerIP
+		appCfg.LoginLockoutDuration = duration
+		appCfg.LoginMaxLockoutDuration = maxDuration
+	}
+}
+
+// WithOIDC enables OIDC authentication with an identity provider that authorizes at authURL.
+// The identity provider can't be reached, so the callback can't complete.
+func WithOIDC(authURL string) ServerOption {
+	return func(t *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.AuthnRepository = &authentication.OIDCRepository{
+			OAuth2Config: &oauth2.Config{
+				ClientID:    "notary",
+				Endpoint:    oauth2.Endpoint{AuthURL: authURL, TokenURL: authURL + "/token"},
+				RedirectURL: "https://notary.example.com/api/v1/oauth/callback",
+				Scopes:      []string{"openid", "email"},
+			},
+			Audience:      "notary",
+			EmailClaimKey: "email",
+			KeyFunc:       mustCreateEmptyKeyFunc(t),
+		}
+	}
+}
+
+// WithOIDCProvider enables OIDC authentication against provider, whose claims are mapped to roles with mapping.
+func WithOIDCProvider(provider *OIDCProvider, mapping *authentication.RoleMapping) ServerOption {
+	return func(t *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		oidcProvider, err := oidc.NewProvider(context.Background(), provider.URL)
+		if err != nil {
+			t.Fatalf("Couldn't discover OIDC provider: %s", err)
+		}
+		appEnv.AuthnRepository = &authentication.OIDCRepository{
+			OIDCProvider: oidcProvider,
+			OAuth2Config: &oauth2.Config{
+				ClientID:    "notary",
+				Endpoint:    oidcProvider.Endpoint(),
+				RedirectURL: "https://notary.example.com/api/v1/oauth/callback",
+				Scopes:      []string{"openid", "email"},
+			},
+			Audience:      "notary",
+			Issuer:        provider.URL,
+			EmailClaimKey: "email",
+			RoleMapping:   mapping,
+			KeyFunc:       mustCreateEmptyKeyFunc(t),
+		}
+	}
+}
+
+// WithLDAP logs the users without a local password in against the LDAP directory of repo.
+func WithLDAP(repo *authentication.LDAPRepository) ServerOption {
+	return func(_ *testing.T, _ *config.AppConfig, appEnv *config.AppEnvironment) {
+		appEnv.LDAPRepository = repo
+	}
+}
+
+func mustCreateEmptyKeyFunc(t *testing.T) keyfunc.Keyfunc {
+	t.Helper()
+	keyFunc, err := keyfunc.NewJWKSetJSON(json.RawMessage(`{"keys":[]}`))
+	if err != nil {
+		t.Fatalf("Couldn't create key function: %s", err)
+	}
+	return keyFunc
+}
+
 // MustGetDefaultAdminToken creates the first admin account (no auth required when zero users exist)
 // then logs in and returns the token.
 func MustGetDefaultAdminToken(t *testing.T, ts *httptest.Server) string {
@@ -121,13 +292,14 @@ type SuccessResponse struct {
 }
 
 type GetAccountResponseResult struct {
-	ID          int      `json:"id"`
-	Email       string   `json:"email"`
-	RoleID      int      `json:"role_id"`
-	HasPassword bool     `json:"has_password"`
-	HasOIDC     bool     `json:"has_oidc"`
-	OIDCSubject *string  `json:"oidc_subject,omitempty"`
-	AuthMethods []string `json:"auth_methods"`
+	ID             int      `json:"id"`
+	Email          string   `json:"email"`
+	RoleID         int      `json:"role_id"`
+	HasPassword    bool     `json:"has_password"`
+	HasOIDC        bool     `json:"has_oidc"`
+	OIDCSubject    *string  `json:"oidc_subject,omitempty"`
+	AuthMethods    []string `json:"auth_methods"`
+	ServiceAccount bool     `json:"service_account"`
 }
 
 type GetAccountResponse = APIResponse[GetAccountResponseResult]
@@ -385,7 +557,9 @@ type LoginParams struct {
 }
 
 type LoginResponseResult struct {
-	Token string `json:"token"`
+	Token       string `json:"token"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
 }
 
 type LoginResponse = APIResponse[LoginResponseResult]
@@ -728,6 +902,48 @@ func UpdateCertificateAuthority(url string, client *http.Client, token string, i
 	return res.StatusCode, &updateCertificateAuthorityResponse, nil
 }
 
+type UpdateRenewalResponse = APIResponse[SuccessResponse]
+
+// UpdateCertificateAuthorityRenewal opts the certificates issued by a certificate authority in or out of automatic renewal.
+func UpdateCertificateAuthorityRenewal(url string, client *http.Client, token string, id int, enabled bool) (int, *UpdateRenewalResponse, error) {
+	return updateRenewal(url+"/api/v1/certificate_authorities/"+strconv.Itoa(id)+"/renewal", client, token, enabled)
+}
+
+// UpdateCertificateRequestRenewal opts a certificate request in or out of automatic renewal.
+func UpdateCertificateRequestRenewal(url string, client *http.Client, token string, id int, enabled bool) (int, *UpdateRenewalResponse, error) {
+	return updateRenewal(url+"/api/v1/certificate_requests/"+strconv.Itoa(id)+"/renewal", client, token, enabled)
+}
+
+func updateRenewal(endpoint string, client *http.Client, token string, enabled bool) (int, *UpdateRenewalResponse, error) {
+	reqData, err := json.Marshal(server.UpdateAutoRenewParams{Enabled: enabled})
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("PUT", endpoint, bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{
+		Name:     server.CookieSessionTokenKey,
+		Value:    token,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var updateRenewalResponse UpdateRenewalResponse
+	if err := json.NewDecoder(res.Body).Decode(&updateRenewalResponse); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &updateRenewalResponse, nil
+}
+
 func DeleteCertificateAuthority(url string, client *http.Client, token string, id int) (int, error) {
 	req, err := http.NewRequest("DELETE", url+"/api/v1/certificate_authorities/"+strconv.Itoa(id), nil)
 	if err != nil {
@@ -781,7 +997,7 @@ func UploadCertificateToCertificateAuthority(url string, client *http.Client, to
 	return res.StatusCode, &uploadCertificateToCertificateAuthorityResponse, nil
 }
 
-type SignCertificateRequestResponse = APIResponse[SuccessResponse]
+type SignCertificateRequestResponse = APIResponse[server.SignCertificateRequestResponse]
 
 func SignCertificateRequest(url string, client *http.Client, token string, id int, cert server.SignCertificateRequestParams) (int, *SignCertificateRequestResponse, error) {
 	reqData, err := json.Marshal(cert)
@@ -899,6 +1115,27 @@ func RevokeCertificateRequest(url string, client *http.Client, token string, id
 	return res.StatusCode, &RevokeCertificateRequestResponse, nil
 }
 
+func RevokeCertificateRequestWithReason(url string, client *http.Client, token string, id int, reason string) (int, *RevokeCertificateRequestResponse, error) {
+	reqData, err := json.Marshal(server.RevokeCertificateParams{Reason: reason})
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/certificate_requests/"+strconv.Itoa(id)+"/certificate/revoke", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var RevokeCertificateRequestResponse RevokeCertificateRequestResponse
+	if err := json.NewDecoder(res.Body).Decode(&RevokeCertificateRequestResponse); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &RevokeCertificateRequestResponse, nil
+}
+
 type GetCRLResponse = APIResponse[server.CRL]
 
 func GetCertificateAuthorityCRLRequest(url string, client *http.Client, token string, id int) (int, *GetCRLResponse, error) {
@@ -926,6 +1163,89 @@ func GetCertificateAuthorityCRLRequest(url string, client *http.Client, token st
 	return res.StatusCode, &GetCRLResponse, nil
 }
 
+type GenerateCertificateRequestParams struct {
+	CommonName          string `json:"common_name"`
+	SANsDNS             string `json:"sans_dns"`
+	CountryName         string `json:"country_name"`
+	StateOrProvinceName string `json:"state_or_province_name"`
+	LocalityName        string `json:"locality_name"`
+	OrganizationName    string `json:"organization_name"`
+	OrganizationalUnit  string `json:"organizational_unit_name"`
+}
+
+type GenerateCertificateRequestResponse = APIResponse[CreateAccountResponseResult]
+
+func GenerateCertificateRequest(url string, client *http.Client, token string, params GenerateCertificateRequestParams) (int, *GenerateCertificateRequestResponse, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/certificate_requests/generate", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var generateResponse GenerateCertificateRequestResponse
+	if err := json.NewDecoder(res.Body).Decode(&generateResponse); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &generateResponse, nil
+}
+
+// GetCertificateRequestPKCS12 collects the PKCS#12 file of a certificate request with a generated key,
+// and returns the status code and the raw body.
+func GetCertificateRequestPKCS12(url string, client *http.Client, token string, id int, password string) (int, []byte, error) {
+	reqData, err := json.Marshal(server.GetCertificateRequestPKCS12Params{Password: password})
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/certificate_requests/"+strconv.Itoa(id)+"/pkcs12", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, body, nil
+}
+
+// DownloadCertificate fetches a certificate file from the given API path, and returns the status code,
+// the response headers and the raw body. The format is selected with the query string or the Accept header.
+func DownloadCertificate(url string, client *http.Client, token string, path string, query string, accept string) (int, http.Header, []byte, error) {
+	if query != "" {
+		path += "?" + query
+	}
+	req, err := http.NewRequest("GET", url+"/api/v1"+path, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return res.StatusCode, res.Header, body, nil
+}
+
 // sign a csr with a self signed ca
 func SignCSR(csr string) string {
 	csrDER, _ := pem.Decode([]byte(csr))
@@ -990,19 +1310,47 @@ func SignCSR(csr string) string {
 // ACME Server helpers
 
 type CreateACMEServerParams struct {
-	Name         string            `json:"name"`
-	DirectoryURL string            `json:"directory_url"`
-	Email        string            `json:"email"`
-	DNSProvider  string            `json:"dns_provider"`
-	EnvVars      map[string]string `json:"env_vars"`
+	Name          string            `json:"name"`
+	DirectoryURL  string            `json:"directory_url"`
+	Email         string            `json:"email"`
+	DNSProvider   string            `json:"dns_provider"`
+	ChallengeType string            `json:"challenge_type,omitempty"`
+	ChallengePort int64             `json:"challenge_port,omitempty"`
+	Webroot       string            `json:"webroot,omitempty"`
+	EABKeyID      *string           `json:"eab_key_id,omitempty"`
+	EABHMACKey    string            `json:"eab_hmac_key,omitempty"`
+	EnvVars       map[string]string `json:"env_vars"`
+
+	DNSResolvers              []string          `json:"dns_resolvers,omitempty"`
+	DNSPropagationTimeout     int64             `json:"dns_propagation_timeout,omitempty"`
+	DNSPollingInterval        int64             `json:"dns_polling_interval,omitempty"`
+	DNSSkipAuthoritativeCheck bool              `json:"dns_skip_authoritative_check,omitempty"`
+	DNSChallengeAliases       map[string]string `json:"dns_challenge_aliases,omitempty"`
+
+	PreferredChain string `json:"preferred_chain,omitempty"`
+	Profile        string `json:"profile,omitempty"`
 }
 
 type UpdateACMEServerParams struct {
-	Name         string            `json:"name"`
-	DirectoryURL string            `json:"directory_url"`
-	Email        string            `json:"email"`
-	DNSProvider  string            `json:"dns_provider"`
-	EnvVars      map[string]string `json:"env_vars"`
+	Name          string            `json:"name"`
+	DirectoryURL  string            `json:"directory_url"`
+	Email         string            `json:"email"`
+	DNSProvider   string            `json:"dns_provider"`
+	ChallengeType string            `json:"challenge_type,omitempty"`
+	ChallengePort int64             `json:"challenge_port,omitempty"`
+	Webroot       string            `json:"webroot,omitempty"`
+	EABKeyID      *string           `json:"eab_key_id,omitempty"`
+	EABHMACKey    string            `json:"eab_hmac_key,omitempty"`
+	EnvVars       map[string]string `json:"env_vars"`
+
+	DNSResolvers              []string          `json:"dns_resolvers,omitempty"`
+	DNSPropagationTimeout     int64             `json:"dns_propagation_timeout,omitempty"`
+	DNSPollingInterval        int64             `json:"dns_polling_interval,omitempty"`
+	DNSSkipAuthoritativeCheck bool              `json:"dns_skip_authoritative_check,omitempty"`
+	DNSChallengeAliases       map[string]string `json:"dns_challenge_aliases,omitempty"`
+
+	PreferredChain string `json:"preferred_chain,omitempty"`
+	Profile        string `json:"profile,omitempty"`
 }
 
 type ListACMEServersResponse = APIResponse[[]server.ACMEServerResponse]
@@ -1010,6 +1358,10 @@ type GetACMEServerResponse = APIResponse[server.ACMEServerResponse]
 type CreateACMEServerResponse = APIResponse[server.ACMEServerResponse]
 type UpdateACMEServerResponse = APIResponse[server.ACMEServerResponse]
 type SetActiveACMEServerResponse = APIResponse[server.ACMEServerResponse]
+type ListACMERoutesResponse = APIResponse[[]server.ACMERouteResponse]
+type TestACMEServerResponse = APIResponse[server.ACMEServerTestResponse]
+type ListACMEAccountsResponse = APIResponse[[]server.ACMEAccountResponse]
+type GetACMEAccountResponse = APIResponse[server.ACMEAccountResponse]
 
 func addAuthHeaders(req *http.Request, token string) {
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -1023,6 +1375,101 @@ func addAuthHeaders(req *http.Request, token string) {
 	})
 }
 
+type ListTimestampsResponse = APIResponse[[]server.TimestampTokenResponse]
+
+// RequestTimestamp posts an RFC 3161 timestamp query and returns the raw reply.
+func RequestTimestamp(url string, client *http.Client, query []byte, contentType string) (int, []byte, error) {
+	req, err := http.NewRequest("POST", url+"/api/v1/timestamp", bytes.NewReader(query))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, body, nil
+}
+
+func ListTimestamps(url string, client *http.Client, token string) (int, *ListTimestampsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/timestamps", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListTimestampsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+type ListJobsResponse = APIResponse[[]server.JobResponse]
+
+type GetJobResponse = APIResponse[server.JobResponse]
+
+type CancelJobResponse = APIResponse[SuccessResponse]
+
+func ListJobs(url string, client *http.Client, token string) (int, *ListJobsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/jobs", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListJobsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func GetJob(url string, client *http.Client, token string, id int64) (int, *GetJobResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/jobs/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp GetJobResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func CancelJob(url string, client *http.Client, token string, id int64) (int, *CancelJobResponse, error) {
+	req, err := http.NewRequest("POST", url+"/api/v1/jobs/"+strconv.FormatInt(id, 10)+"/cancel", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp CancelJobResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
 func ListACMEServers(url string, client *http.Client, token string) (int, *ListACMEServersResponse, error) {
 	req, err := http.NewRequest("GET", url+"/api/v1/acme_servers", nil)
 	if err != nil {
@@ -1161,3 +1608,683 @@ func CreateRequestBombWithCustomHeader(url string, client *http.Client, token st
 	}
 	return res.StatusCode, nil
 }
+
+func ListACMERoutes(url string, client *http.Client, token string) (int, *ListACMERoutesResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/acme_routes", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListACMERoutesResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func ReplaceACMERoutes(url string, client *http.Client, token string, params server.ReplaceACMERoutesParams) (int, *ListACMERoutesResponse, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("PUT", url+"/api/v1/acme_routes", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListACMERoutesResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func ListACMEAccounts(url string, client *http.Client, token string) (int, *ListACMEAccountsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/acme_accounts", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListACMEAccountsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func GetACMEAccount(url string, client *http.Client, token string, id int64) (int, *GetACMEAccountResponse, error) {
+	return doACMEAccountRequest(client, token, "GET", url+"/api/v1/acme_accounts/"+strconv.FormatInt(id, 10), nil)
+}
+
+func RefreshACMEAccount(url string, client *http.Client, token string, id int64) (int, *GetACMEAccountResponse, error) {
+	return doACMEAccountRequest(client, token, "POST", url+"/api/v1/acme_accounts/"+strconv.FormatInt(id, 10)+"/refresh", nil)
+}
+
+func UpdateACMEAccountContacts(url string, client *http.Client, token string, id int64, emails []string) (int, *GetACMEAccountResponse, error) {
+	reqData, err := json.Marshal(server.UpdateACMEAccountContactsParams{Emails: emails})
+	if err != nil {
+		return 0, nil, err
+	}
+	return doACMEAccountRequest(client, token, "PUT", url+"/api/v1/acme_accounts/"+strconv.FormatInt(id, 10)+"/contacts", reqData)
+}
+
+func RollOverACMEAccountKey(url string, client *http.Client, token string, id int64) (int, *GetACMEAccountResponse, error) {
+	return doACMEAccountRequest(client, token, "POST", url+"/api/v1/acme_accounts/"+strconv.FormatInt(id, 10)+"/key_rollover", nil)
+}
+
+func DeactivateACMEAccount(url string, client *http.Client, token string, id int64) (int, *GetACMEAccountResponse, error) {
+	return doACMEAccountRequest(client, token, "POST", url+"/api/v1/acme_accounts/"+strconv.FormatInt(id, 10)+"/deactivate", nil)
+}
+
+func doACMEAccountRequest(client *http.Client, token, method, url string, body []byte) (int, *GetACMEAccountResponse, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp GetACMEAccountResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func LinkACMEServerAccount(url string, client *http.Client, token string, serverID, accountID int64) (int, *GetACMEServerResponse, error) {
+	reqData, err := json.Marshal(server.LinkACMEServerAccountParams{ACMEAccountID: accountID})
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("PUT", url+"/api/v1/acme_servers/"+strconv.FormatInt(serverID, 10)+"/account", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp GetACMEServerResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func TestACMEServer(url string, client *http.Client, token string, id int64, params server.TestACMEServerParams) (int, *TestACMEServerResponse, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/acme_servers/"+strconv.FormatInt(id, 10)+"/test", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp TestACMEServerResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+type ListACMEDNSRegistrationsResponse = APIResponse[[]server.ACMEDNSRegistrationResponse]
+type GetACMEDNSRegistrationResponse = APIResponse[server.ACMEDNSRegistrationResponse]
+
+// RegisterACMEDNS registers an account with the acme-dns API. The response body is returned raw,
+// since the acme-dns API doesn't wrap its responses.
+func RegisterACMEDNS(url string, client *http.Client, token string, params server.RegisterACMEDNSParams) (int, []byte, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/acme-dns/register", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	return doRawRequest(client, req)
+}
+
+// UpdateACMEDNS sets the TXT record of an account with the acme-dns API. The response body is returned raw.
+func UpdateACMEDNS(url string, client *http.Client, username, password string, params server.UpdateACMEDNSParams) (int, []byte, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/acme-dns/update", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("X-Api-User", username)
+	req.Header.Set("X-Api-Key", password)
+	return doRawRequest(client, req)
+}
+
+func doRawRequest(client *http.Client, req *http.Request) (int, []byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, body, nil
+}
+
+func ListACMEDNSRegistrations(url string, client *http.Client, token string) (int, *ListACMEDNSRegistrationsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/acme_dns_registrations", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListACMEDNSRegistrationsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func GetACMEDNSRegistration(url string, client *http.Client, token string, id int64) (int, *GetACMEDNSRegistrationResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/acme_dns_registrations/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp GetACMEDNSRegistrationResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func DeleteACMEDNSRegistration(url string, client *http.Client, token string, id int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/acme_dns_registrations/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	return res.StatusCode, nil
+}
+
+type CreateServiceAccountResponse = APIResponse[CreateAccountResponseResult]
+type CreateAPITokenResponse = APIResponse[server.CreateAPITokenResponse]
+type ListAPITokensResponse = APIResponse[[]server.APITokenResponse]
+
+func CreateServiceAccount(url string, client *http.Client, token string, data server.CreateServiceAccountParams) (int, *CreateServiceAccountResponse, error) {
+	reqData, err := json.Marshal(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/service_accounts", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp CreateServiceAccountResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func CreateAPIToken(url string, client *http.Client, token string, accountID int64, data server.CreateAPITokenParams) (int, *CreateAPITokenResponse, error) {
+	reqData, err := json.Marshal(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/tokens", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp CreateAPITokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func ListAPITokens(url string, client *http.Client, token string, accountID int64) (int, *ListAPITokensResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/tokens", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListAPITokensResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+func DeleteAPIToken(url string, client *http.Client, token string, accountID, tokenID int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/tokens/"+strconv.FormatInt(tokenID, 10), nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	return res.StatusCode, nil
+}
+
+// DoBearerRequest sends a request authenticated only by the Authorization header, as automation does with API tokens.
+func DoBearerRequest(client *http.Client, token, method, url string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return doRawRequest(client, req)
+}
+
+type ListSessionsResponse = APIResponse[[]server.SessionResponse]
+
+func Logout(url string, client *http.Client, token string) (int, error) {
+	req, err := http.NewRequest("POST", url+"/logout", nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	return res.StatusCode, nil
+}
+
+// ListSessions lists the sessions of the account, or of the account of the token when accountID is 0.
+func ListSessions(url string, client *http.Client, token string, accountID int64) (int, *ListSessionsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/accounts/"+sessionsAccount(accountID)+"/sessions", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	var resp ListSessionsResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, nil, err
+	}
+	return res.StatusCode, &resp, nil
+}
+
+// DeleteSession revokes a session of the account, or of the account of the token when accountID is 0.
+func DeleteSession(url string, client *http.Client, token string, accountID, sessionID int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/"+sessionsAccount(accountID)+"/sessions/"+strconv.FormatInt(sessionID, 10), nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	return res.StatusCode, nil
+}
+
+// DeleteSessions revokes all the sessions of the account, or of the account of the token when accountID is 0,
+// but the session of the token.
+func DeleteSessions(url string, client *http.Client, token string, accountID int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/"+sessionsAccount(accountID)+"/sessions", nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	return res.StatusCode, nil
+}
+
+func sessionsAccount(accountID int64) string {
+	if accountID == 0 {
+		return "me"
+	}
+	return strconv.FormatInt(accountID, 10)
+}
+
+type LoginMFAParams struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// LoginMFA completes a login that requires multi-factor authentication, and returns the session token from the cookie.
+func LoginMFA(url string, client *http.Client, data *LoginMFAParams) (int, string, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequest("POST", url+"/login/mfa", bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	token := ""
+	if len(res.Cookies()) >= 1 {
+		token = res.Cookies()[0].Value
+	}
+	return res.StatusCode, token, nil
+}
+
+type GetMFAResponse = APIResponse[server.MFAStatusResponse]
+
+type EnrollTOTPResponse = APIResponse[server.EnrollTOTPResponse]
+
+type RecoveryCodesResponse = APIResponse[server.RecoveryCodesResponse]
+
+func GetMyMFA(url string, client *http.Client, token string) (int, *GetMFAResponse, error) {
+	var resp GetMFAResponse
+	status, err := doMFARequest(client, token, "GET", url+"/api/v1/accounts/me/mfa", nil, &resp)
+	return status, &resp, err
+}
+
+func EnrollTOTP(url string, client *http.Client, token string) (int, *EnrollTOTPResponse, error) {
+	var resp EnrollTOTPResponse
+	status, err := doMFARequest(client, token, "POST", url+"/api/v1/accounts/me/mfa/totp", nil, &resp)
+	return status, &resp, err
+}
+
+func VerifyTOTP(url string, client *http.Client, token, code string) (int, *RecoveryCodesResponse, error) {
+	var resp RecoveryCodesResponse
+	status, err := doMFARequest(client, token, "POST", url+"/api/v1/accounts/me/mfa/totp/verify", &server.MFACodeParams{Code: code}, &resp)
+	return status, &resp, err
+}
+
+func DisableTOTP(url string, client *http.Client, token, code string) (int, error) {
+	return doMFARequest(client, token, "POST", url+"/api/v1/accounts/me/mfa/totp/disable", &server.MFACodeParams{Code: code}, nil)
+}
+
+func RegenerateRecoveryCodes(url string, client *http.Client, token, code string) (int, *RecoveryCodesResponse, error) {
+	var resp RecoveryCodesResponse
+	status, err := doMFARequest(client, token, "POST", url+"/api/v1/accounts/me/mfa/recovery_codes", &server.MFACodeParams{Code: code}, &resp)
+	return status, &resp, err
+}
+
+func ResetAccountMFA(url string, client *http.Client, token string, accountID int64) (int, error) {
+	return doMFARequest(client, token, "DELETE", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/mfa", nil, nil)
+}
+
+// doMFARequest sends params as the JSON body of the request when set, and decodes the response into resp when set.
+func doMFARequest(client *http.Client, token, method, url string, params, resp any) (int, error) {
+	var body []byte
+	if params != nil {
+		var err error
+		body, err = json.Marshal(params)
+		if err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	status, respBody, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, err
+	}
+	if resp != nil {
+		if err := json.Unmarshal(respBody, resp); err != nil {
+			return 0, err
+		}
+	}
+	return status, nil
+}
+
+type ListLoginLockoutsResponse = APIResponse[[]server.LoginLockoutResponse]
+
+func ListLoginLockouts(url string, client *http.Client, token string) (int, *ListLoginLockoutsResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/lockouts", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListLoginLockoutsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, nil, err
+	}
+	return status, &resp, nil
+}
+
+func UnlockAccountLogin(url string, client *http.Client, token string, accountID int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/lockout", nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	status, _, err := doRawRequest(client, req)
+	return status, err
+}
+
+func UnlockIPAddressLogin(url string, client *http.Client, token string, address string) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/lockouts/ip/"+address, nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	status, _, err := doRawRequest(client, req)
+	return status, err
+}
+
+type ListOIDCIdentitiesResponse = APIResponse[[]server.OIDCIdentityResponse]
+
+func ListOIDCIdentities(url string, client *http.Client, token string) (int, *ListOIDCIdentitiesResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/oidc_identities", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListOIDCIdentitiesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, nil, err
+	}
+	return status, &resp, nil
+}
+
+// StartOIDCLink starts linking an OIDC identity to the account of token, without following the redirect to the identity provider.
+func StartOIDCLink(url string, client *http.Client, token string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/accounts/me/oidc/link", nil)
+	if err != nil {
+		return nil, err
+	}
+	addAuthHeaders(req, token)
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	res, err := noRedirect.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close() // nolint: errcheck
+	return res, nil
+}
+
+func UnlinkMyOIDC(url string, client *http.Client, token string) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/me/oidc", nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	status, _, err := doRawRequest(client, req)
+	return status, err
+}
+
+func UnlinkAccountOIDC(url string, client *http.Client, token string, accountID int64) (int, error) {
+	req, err := http.NewRequest("DELETE", url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/oidc", nil)
+	if err != nil {
+		return 0, err
+	}
+	addAuthHeaders(req, token)
+	status, _, err := doRawRequest(client, req)
+	return status, err
+}
+
+type PasswordTokenResponse = APIResponse[server.PasswordTokenResponse]
+
+func CreateInvitation(url string, client *http.Client, token string, params server.CreateInvitationParams) (int, *PasswordTokenResponse, error) {
+	return doPasswordTokenRequest(client, token, url+"/api/v1/invitations", params)
+}
+
+func CreatePasswordReset(url string, client *http.Client, token string, accountID int64, params server.CreatePasswordResetParams) (int, *PasswordTokenResponse, error) {
+	return doPasswordTokenRequest(client, token, url+"/api/v1/accounts/"+strconv.FormatInt(accountID, 10)+"/password_reset", params)
+}
+
+func doPasswordTokenRequest(client *http.Client, token, url string, params any) (int, *PasswordTokenResponse, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(reqData))
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp PasswordTokenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, nil, err
+	}
+	return status, &resp, nil
+}
+
+// SetPassword sets a password with an invitation or reset token. It doesn't authenticate.
+func SetPassword(url string, client *http.Client, params server.SetPasswordParams) (int, error) {
+	reqData, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", url+"/api/v1/set_password", bytes.NewReader(reqData))
+	if err != nil {
+		return 0, err
+	}
+	status, _, err := doRawRequest(client, req)
+	return status, err
+}
+
+type ListSigningKeysResponse = APIResponse[[]server.SigningKeyResponse]
+
+type RotateSigningKeyResponse = APIResponse[server.RotateSigningKeyResponse]
+
+func ListSigningKeys(url string, client *http.Client, token string) (int, *ListSigningKeysResponse, error) {
+	req, err := http.NewRequest("GET", url+"/api/v1/signing_keys", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp ListSigningKeysResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, nil, err
+	}
+	return status, &resp, nil
+}
+
+func RotateSigningKey(url string, client *http.Client, token string) (int, *RotateSigningKeyResponse, error) {
+	req, err := http.NewRequest("POST", url+"/api/v1/signing_keys/rotate", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	addAuthHeaders(req, token)
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var resp RotateSigningKeyResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0, nil, err
+	}
+	return status, &resp, nil
+}
+
+// GetJWKS fetches the public keys that verify the session tokens. It doesn't authenticate.
+func GetJWKS(url string, client *http.Client) (int, *jose.JSONWebKeySet, error) {
+	req, err := http.NewRequest("GET", url+"/.well-known/jwks.json", nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	status, body, err := doRawRequest(client, req)
+	if err != nil {
+		return 0, nil, err
+	}
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return 0, nil, err
+	}
+	return status, &jwks, nil
+}
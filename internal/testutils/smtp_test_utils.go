@@ -0,0 +1,199 @@
+package testutils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// SMTPMessage is an email that a test SMTP server received.
+type SMTPMessage struct {
+	From string
+	To   []string
+	// Data is the message with its headers, with CRLF line endings.
+	Data string
+	// Username is the user that authenticated to send the message, if any.
+	Username string
+	// TLS is set when the message was sent after STARTTLS.
+	TLS bool
+}
+
+// SMTPServer is an SMTP server that runs in the test process. It supports STARTTLS and PLAIN authentication,
+// which accepts any password, and keeps the messages it receives.
+type SMTPServer struct {
+	Host string
+	Port int
+	// RootCAs trust the certificate of the server after STARTTLS.
+	RootCAs *x509.CertPool
+
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	messages []SMTPMessage
+}
+
+// MustStartSMTPServer runs an SMTP server in the test process.
+func MustStartSMTPServer(t *testing.T) *SMTPServer {
+	t.Helper()
+	cert, roots := mustGenerateLocalServerCertificate(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen for SMTP connections: %s", err)
+	}
+	s := &SMTPServer{
+		Host:      "127.0.0.1",
+		Port:      l.Addr().(*net.TCPAddr).Port,
+		RootCAs:   roots,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+	}
+	var wg sync.WaitGroup
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close() // nolint: errcheck
+		wg.Wait()
+	})
+	return s
+}
+
+// Messages returns the messages that the server received, in order.
+func (s *SMTPServer) Messages() []SMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMTPMessage(nil), s.messages...)
+}
+
+func (s *SMTPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }() // nolint: errcheck
+	reader := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		return err == nil
+	}
+	if !reply("220 notary-test ESMTP") {
+		return
+	}
+	var message SMTPMessage
+	for {
+		if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			return
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"250-notary-test", "250-AUTH PLAIN"}
+			if !message.TLS {
+				extensions = append(extensions, "250-STARTTLS")
+			}
+			extensions = append(extensions, "250 8BITMIME")
+			if !reply(strings.Join(extensions, "\r\n")) {
+				return
+			}
+		case "STARTTLS":
+			if message.TLS {
+				if !reply("503 TLS already started") {
+					return
+				}
+				continue
+			}
+			if !reply("220 ready to start TLS") {
+				return
+			}
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			reader = bufio.NewReader(conn)
+			message = SMTPMessage{TLS: true}
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			parts := strings.Split(string(decoded), "\x00")
+			if !strings.EqualFold(mechanism, "PLAIN") || err != nil || len(parts) != 3 {
+				if !reply("535 authentication failed") {
+					return
+				}
+				continue
+			}
+			message.Username = parts[1]
+			if !reply("235 authenticated") {
+				return
+			}
+		case "MAIL":
+			message.From = smtpAddress(arg)
+			if !reply("250 OK") {
+				return
+			}
+		case "RCPT":
+			message.To = append(message.To, smtpAddress(arg))
+			if !reply("250 OK") {
+				return
+			}
+		case "DATA":
+			if !reply("354 end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			message.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+			message = SMTPMessage{TLS: message.TLS, Username: message.Username}
+			if !reply("250 OK: queued as " + strconv.Itoa(len(s.Messages()))) {
+				return
+			}
+		case "RSET", "NOOP":
+			if !reply("250 OK") {
+				return
+			}
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			if !reply("502 command not implemented") {
+				return
+			}
+		}
+	}
+}
+
+// smtpAddress returns the address of a MAIL FROM or RCPT TO argument.
+func smtpAddress(arg string) string {
+	_, address, _ := strings.Cut(arg, ":")
+	address, _, _ = strings.Cut(strings.TrimSpace(address), " ")
+	return strings.Trim(address, "<>")
+}
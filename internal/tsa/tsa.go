@@ -0,0 +1,315 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	"github.com/digitorus/timestamp"
+)
+
+const signerKeySize = 2048
+
+// signerValidity is how long the certificates of the TSA signers are valid. A new signer is issued once it expired.
+const signerValidity = 30 * 24 * time.Hour
+
+// RejectionError is returned when a timestamp query is rejected.
+// The reply returned alongside it carries the rejection to the client.
+type RejectionError struct {
+	FailureInfo timestamp.FailureInfo
+	Reason      string
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("timestamp query rejected (%s): %s", e.FailureInfo, e.Reason)
+}
+
+// TSARepository issues RFC 3161 timestamp tokens. The tokens are signed with a
+// certificate carrying the timeStamping extended key usage, which is issued on demand
+// by the configured Notary certificate authority.
+type TSARepository struct {
+	certificateAuthorityID int64
+	policyOID              asn1.ObjectIdentifier
+	accuracy               time.Duration
+	externalHostname       string
+	db                     *db.DatabaseRepository
+
+	// signerMu makes sure only one signer is issued when it needs to be replaced.
+	signerMu sync.Mutex
+}
+
+func NewTSARepository(caID int64, policyOID asn1.ObjectIdentifier, accuracy time.Duration, externalHostname string, database *db.DatabaseRepository) *TSARepository {
+	return &TSARepository{
+		certificateAuthorityID: caID,
+		policyOID:              policyOID,
+		accuracy:               accuracy,
+		externalHostname:       externalHostname,
+		db:                     database,
+	}
+}
+
+// CertificateAuthorityID returns the ID of the certificate authority backing the timestamping authority.
+func (r *TSARepository) CertificateAuthorityID() int64 {
+	return r.certificateAuthorityID
+}
+
+// Timestamp answers a DER encoded timestamp query with a DER encoded timestamp reply,
+// and records the issued token.
+// Queries that can't be honoured are answered with a rejection reply and a *RejectionError.
+// Other errors are answered with a system failure reply.
+func (r *TSARepository) Timestamp(query []byte) ([]byte, *db.TimestampToken, error) {
+	req, err := timestamp.ParseRequest(query)
+	if err != nil {
+		return r.reject(timestamp.BadDataFormat, fmt.Sprintf("failed to parse timestamp query: %s", err))
+	}
+	switch req.HashAlgorithm {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		return r.reject(timestamp.BadAlgorithm, fmt.Sprintf("unsupported hash algorithm: %s", req.HashAlgorithm))
+	}
+	if len(req.HashedMessage) != req.HashAlgorithm.Size() {
+		return r.reject(timestamp.BadDataFormat, "message imprint does not match the hash algorithm")
+	}
+	if len(req.TSAPolicyOID) > 0 && !req.TSAPolicyOID.Equal(r.policyOID) {
+		return r.reject(timestamp.UnacceptedPolicy, fmt.Sprintf("unsupported policy: %s", req.TSAPolicyOID))
+	}
+
+	signer, signerCert, signerKey, err := r.loadOrIssueSigner()
+	if err != nil {
+		return r.fail(err)
+	}
+	chain, err := db.ParseCertificateChain(signer.CertificateChain)
+	if err != nil {
+		return r.fail(fmt.Errorf("failed to parse TSA certificate chain: %w", err))
+	}
+
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     req.HashAlgorithm,
+		HashedMessage:     req.HashedMessage,
+		Time:              time.Now().UTC().Truncate(time.Second),
+		Accuracy:          r.accuracy,
+		Policy:            r.policyOID,
+		Nonce:             req.Nonce,
+		AddTSACertificate: req.Certificates,
+	}
+	if req.Certificates {
+		ts.Certificates = issuerCertificates(chain)
+	}
+	reply, err := ts.CreateResponseWithOpts(signerCert, signerKey, crypto.SHA256)
+	if err != nil {
+		return r.fail(fmt.Errorf("failed to create timestamp reply: %w", err))
+	}
+	// The serial number of the token is generated while building the reply.
+	issued, err := timestamp.ParseResponse(reply)
+	if err != nil {
+		return r.fail(fmt.Errorf("failed to parse timestamp reply: %w", err))
+	}
+
+	token := db.TimestampToken{
+		SerialNumber:  issued.SerialNumber.Text(16),
+		GenTime:       ts.Time.Format(time.RFC3339),
+		PolicyOID:     r.policyOID.String(),
+		HashAlgorithm: req.HashAlgorithm.String(),
+		HashedMessage: hex.EncodeToString(req.HashedMessage),
+		TSASignerID:   signer.ID,
+	}
+	if req.Nonce != nil {
+		token.Nonce = req.Nonce.Text(16)
+	}
+	token.ID, err = r.db.CreateTimestampToken(token)
+	if err != nil {
+		return r.fail(fmt.Errorf("failed to record timestamp token: %w", err))
+	}
+	return reply, &token, nil
+}
+
+// reject builds a rejection reply for a query that can't be honoured.
+func (r *TSARepository) reject(failureInfo timestamp.FailureInfo, reason string) ([]byte, *db.TimestampToken, error) {
+	rejectionErr := &RejectionError{FailureInfo: failureInfo, Reason: reason}
+	reply, err := timestamp.CreateErrorResponse(timestamp.Rejection, failureInfo)
+	if err != nil {
+		return nil, nil, errors.Join(rejectionErr, err)
+	}
+	return reply, nil, rejectionErr
+}
+
+// fail builds a system failure reply for a query that couldn't be processed.
+func (r *TSARepository) fail(cause error) ([]byte, *db.TimestampToken, error) {
+	reply, err := timestamp.CreateErrorResponse(timestamp.Rejection, timestamp.SystemFailure)
+	if err != nil {
+		return nil, nil, errors.Join(cause, err)
+	}
+	return reply, nil, cause
+}
+
+// loadOrIssueSigner returns the current TSA signer. A new one is issued by the certificate authority
+// when there is none yet, when it expired or was revoked, or when it was not issued by the current CA certificate.
+func (r *TSARepository) loadOrIssueSigner() (*db.TSASigner, *x509.Certificate, crypto.Signer, error) {
+	r.signerMu.Lock()
+	defer r.signerMu.Unlock()
+
+	caRow, err := r.db.GetDenormalizedCertificateAuthority(db.ByCertificateAuthorityDenormalizedID(r.certificateAuthorityID))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get TSA certificate authority: %w", err)
+	}
+	if caRow.CertificateChain == "" {
+		return nil, nil, nil, errors.New("TSA certificate authority does not have a valid signed certificate")
+	}
+	if !caRow.Enabled {
+		return nil, nil, nil, errors.New("TSA certificate authority is not enabled")
+	}
+	caChain, err := db.ParseCertificateChain(caRow.CertificateChain)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse TSA certificate authority chain: %w", err)
+	}
+	caCert := caChain[0]
+	if time.Now().After(caCert.NotAfter) {
+		return nil, nil, nil, errors.New("TSA certificate authority certificate is expired")
+	}
+
+	signer, err := r.db.GetDecryptedTSASigner(r.certificateAuthorityID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return nil, nil, nil, fmt.Errorf("failed to get TSA signer: %w", err)
+	}
+	if err == nil {
+		cert, key, err := parseSigner(signer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		current, err := r.signerCertificateIsCurrent(signer)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if current && time.Now().Before(cert.NotAfter) && cert.CheckSignatureFrom(caCert) == nil {
+			return signer, cert, key, nil
+		}
+	}
+
+	csrID, privPEM, certChainPEM, err := r.issueSigner(caCert)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if _, err := r.db.CreateTSASigner(r.certificateAuthorityID, csrID, privPEM, certChainPEM); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to store TSA signer: %w", err)
+	}
+	signer, err = r.db.GetDecryptedTSASigner(r.certificateAuthorityID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get TSA signer: %w", err)
+	}
+	cert, key, err := parseSigner(signer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return signer, cert, key, nil
+}
+
+// signerCertificateIsCurrent returns whether the certificate request of a signer still holds its certificate.
+// It doesn't once the certificate was revoked or deleted, or when the signer predates its certificate request.
+func (r *TSARepository) signerCertificateIsCurrent(signer *db.TSASigner) (bool, error) {
+	if signer.CSRID == 0 {
+		return false, nil
+	}
+	csrRow, err := r.db.GetCertificateRequestAndChain(db.ByCSRID(signer.CSRID))
+	if errors.Is(err, db.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get TSA certificate request: %w", err)
+	}
+	return csrRow.CertificateChain == signer.CertificateChain, nil
+}
+
+// issueSigner generates a new TSA key and has the certificate authority sign a timestamping certificate for it,
+// like any other certificate request, so that it can be listed and revoked.
+// It returns the certificate request, and the private key and the certificate chain as PEM strings.
+func (r *TSARepository) issueSigner(caCert *x509.Certificate) (int64, string, string, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, signerKeySize)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to generate TSA private key: %w", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   fmt.Sprintf("%s Timestamping Authority", r.externalHostname),
+			Organization: caCert.Subject.Organization,
+		},
+	}, priv)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to create TSA certificate request: %w", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+	csrID, err := r.db.CreateCertificateRequest(string(csrPEM), "")
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to store TSA certificate request: %w", err)
+	}
+
+	notAfter := time.Now().Add(signerValidity)
+	if caCert.NotAfter.Before(notAfter) {
+		notAfter = caCert.NotAfter
+	}
+	err = r.db.SignCertificateRequest(db.ByCSRID(csrID), db.ByCertificateAuthorityDenormalizedID(r.certificateAuthorityID), r.externalHostname,
+		db.WithTimestampingUsage(),
+		db.WithNotAfter(notAfter),
+	)
+	if err != nil {
+		if deleteErr := r.db.DeleteCertificateRequest(db.ByCSRID(csrID)); deleteErr != nil {
+			err = errors.Join(err, deleteErr)
+		}
+		return 0, "", "", fmt.Errorf("failed to issue TSA certificate: %w", err)
+	}
+	csrRow, err := r.db.GetCertificateRequestAndChain(db.ByCSRID(csrID))
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to get TSA certificate: %w", err)
+	}
+
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	return csrID, string(privPEM), csrRow.CertificateChain, nil
+}
+
+// parseSigner parses the certificate and private key of a TSA signer.
+func parseSigner(signer *db.TSASigner) (*x509.Certificate, crypto.Signer, error) {
+	chain, err := db.ParseCertificateChain(signer.CertificateChain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse TSA certificate chain: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, nil, errors.New("TSA certificate chain is empty")
+	}
+	block, _ := pem.Decode([]byte(signer.PrivateKeyPEM))
+	if block == nil {
+		return nil, nil, errors.New("failed to decode TSA private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse TSA private key: %w", err)
+	}
+	return chain[0], key, nil
+}
+
+// issuerCertificates returns the certificates of the chain above the TSA certificate, without duplicates.
+func issuerCertificates(chain []*x509.Certificate) []*x509.Certificate {
+	var issuers []*x509.Certificate
+	for _, cert := range chain[1:] {
+		duplicate := false
+		for _, seen := range issuers {
+			if bytes.Equal(seen.Raw, cert.Raw) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			issuers = append(issuers, cert)
+		}
+	}
+	return issuers
+}
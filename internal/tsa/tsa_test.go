@@ -0,0 +1,141 @@
+package tsa_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/notary/internal/db"
+	tu "github.com/canonical/notary/internal/testutils"
+	"github.com/canonical/notary/internal/tsa"
+	"github.com/digitorus/timestamp"
+)
+
+var testPolicyOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+func mustPrepareTSA(t *testing.T) (*tsa.TSARepository, *db.DatabaseRepository) {
+	t.Helper()
+	database := tu.MustPrepareEmptyDB(t)
+	caID, err := database.CreateCertificateAuthority(tu.RootCACSR, tu.RootCAPrivateKey, tu.RootCACRL, tu.RootCACertificate+"\n"+tu.RootCACertificate, "testuser@example.com")
+	if err != nil {
+		t.Fatalf("couldn't create certificate authority: %s", err)
+	}
+	if err := database.UpdateCertificateAuthorityEnabledStatus(db.ByCertificateAuthorityID(caID), true); err != nil {
+		t.Fatalf("couldn't enable certificate authority: %s", err)
+	}
+	return tsa.NewTSARepository(caID, testPolicyOID, time.Second, "example.com", database), database
+}
+
+// mustTimestamp returns the certificate of the signer that granted a timestamp query.
+func mustTimestamp(t *testing.T, repo *tsa.TSARepository, data string) *x509.Certificate {
+	t.Helper()
+	query, err := timestamp.CreateRequest(bytes.NewReader([]byte(data)), &timestamp.RequestOptions{Hash: crypto.SHA256, Certificates: true})
+	if err != nil {
+		t.Fatalf("couldn't create timestamp query: %s", err)
+	}
+	reply, token, err := repo.Timestamp(query)
+	if err != nil {
+		t.Fatalf("couldn't timestamp: %s", err)
+	}
+	parsed, err := timestamp.ParseResponse(reply)
+	if err != nil {
+		t.Fatalf("couldn't parse timestamp reply: %s", err)
+	}
+	if token == nil || token.SerialNumber != parsed.SerialNumber.Text(16) {
+		t.Fatalf("expected the token to be recorded, got %+v", token)
+	}
+	return parsed.Certificates[0]
+}
+
+func TestTimestampSignerIsACertificateOfTheCA(t *testing.T) {
+	repo, database := mustPrepareTSA(t)
+
+	cert := mustTimestamp(t, repo, "artifact")
+	if cert.NotAfter.After(time.Now().Add(31 * 24 * time.Hour)) {
+		t.Fatalf("expected a short-lived TSA certificate, got one valid until %s", cert.NotAfter)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping || cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Fatalf("expected a timestamping certificate, got %v %v", cert.ExtKeyUsage, cert.KeyUsage)
+	}
+
+	signer, err := database.GetDecryptedTSASigner(repo.CertificateAuthorityID())
+	if err != nil {
+		t.Fatalf("couldn't get TSA signer: %s", err)
+	}
+	csr, err := database.GetCertificateRequestAndChain(db.ByCSRID(signer.CSRID))
+	if err != nil {
+		t.Fatalf("expected the TSA certificate to have a certificate request: %s", err)
+	}
+	if csr.CertificateChain != signer.CertificateChain {
+		t.Fatalf("expected the certificate request to hold the TSA certificate")
+	}
+
+	if again := mustTimestamp(t, repo, "another artifact"); !bytes.Equal(again.Raw, cert.Raw) {
+		t.Fatalf("expected the TSA certificate to be reused")
+	}
+}
+
+func TestTimestampSignerIsReplacedOnceRevoked(t *testing.T) {
+	repo, database := mustPrepareTSA(t)
+	cert := mustTimestamp(t, repo, "artifact")
+	signer, err := database.GetDecryptedTSASigner(repo.CertificateAuthorityID())
+	if err != nil {
+		t.Fatalf("couldn't get TSA signer: %s", err)
+	}
+
+	if err := database.RevokeCertificate(db.ByCSRID(signer.CSRID)); err != nil {
+		t.Fatalf("couldn't revoke TSA certificate: %s", err)
+	}
+	if replaced := mustTimestamp(t, repo, "artifact"); bytes.Equal(replaced.Raw, cert.Raw) {
+		t.Fatalf("expected a revoked TSA certificate to be replaced")
+	}
+}
+
+func TestTimestampSignerOfEarlierVersionsIsReplaced(t *testing.T) {
+	repo, database := mustPrepareTSA(t)
+	cert := mustTimestamp(t, repo, "artifact")
+	signer, err := database.GetDecryptedTSASigner(repo.CertificateAuthorityID())
+	if err != nil {
+		t.Fatalf("couldn't get TSA signer: %s", err)
+	}
+	// The signers of earlier versions have no certificate request.
+	if _, err := database.CreateTSASigner(repo.CertificateAuthorityID(), 0, signer.PrivateKeyPEM, signer.CertificateChain); err != nil {
+		t.Fatalf("couldn't create TSA signer: %s", err)
+	}
+
+	if replaced := mustTimestamp(t, repo, "artifact"); bytes.Equal(replaced.Raw, cert.Raw) {
+		t.Fatalf("expected a TSA signer without certificate request to be replaced")
+	}
+}
+
+func TestTimestampRejections(t *testing.T) {
+	repo, _ := mustPrepareTSA(t)
+	cases := []struct {
+		desc        string
+		opts        *timestamp.RequestOptions
+		failureInfo timestamp.FailureInfo
+	}{
+		{"unsupported algorithm", &timestamp.RequestOptions{Hash: crypto.SHA1}, timestamp.BadAlgorithm},
+		{"unknown policy", &timestamp.RequestOptions{Hash: crypto.SHA256, TSAPolicyOID: asn1.ObjectIdentifier{1, 2, 3, 4}}, timestamp.UnacceptedPolicy},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			query, err := timestamp.CreateRequest(bytes.NewReader([]byte("artifact")), tc.opts)
+			if err != nil {
+				t.Fatalf("couldn't create timestamp query: %s", err)
+			}
+			reply, token, err := repo.Timestamp(query)
+			var rejectionErr *tsa.RejectionError
+			if !errors.As(err, &rejectionErr) || rejectionErr.FailureInfo != tc.failureInfo {
+				t.Fatalf("expected a %s rejection, got %v", tc.failureInfo, err)
+			}
+			if reply == nil || token != nil {
+				t.Fatalf("expected a rejection reply and no token")
+			}
+		})
+	}
+}
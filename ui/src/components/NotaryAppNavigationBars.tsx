@@ -247,6 +247,12 @@ export function SideBar({
 															>
 																Change Password
 															</button>
+															<Link
+																className="p-contextual-menu__link"
+																to="/mfa"
+															>
+																Multi-Factor Authentication
+															</Link>
 															<button
 																type="button"
 																className="p-contextual-menu__link"
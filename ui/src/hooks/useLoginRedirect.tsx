@@ -1,10 +1,11 @@
 import { useQuery } from "@tanstack/react-query";
 import { useLocation, useNavigate } from "@tanstack/react-router";
 import { useEffect } from "react";
-import { getSelfAccount, getStatus } from "@/utils/queries";
+import { getMyMFA, getSelfAccount, getStatus } from "@/utils/queries";
 // This hook manages some redirects based on the user's login status, notary's initialization status and the current page.
 // If Notary isn't initialized, it will redirect the user to the initialization page.
 // If the user isn't logged in, it will redirect the user to the login page.
+// If the session of the user is limited to the enrollment of multi-factor authentication, it will redirect the user to the enrollment page.
 // If the user is logged in and tries to go to the login or initialize page, it will redirect the user to the home page.
 export function useLoginRedirect() {
 	const location = useLocation();
@@ -24,12 +25,20 @@ export function useLoginRedirect() {
 		retry: false,
 	});
 
+	const mfaQ = useQuery({
+		queryKey: ["mfa"],
+		queryFn: getMyMFA,
+		enabled: !!userQ.data,
+		retry: false,
+	});
+
 	useEffect(() => {
 		const notaryStatusIsLoading = statusQ.isLoading;
 		const notaryUserDataIsLoading = userQ.isLoading;
 		const notaryIsNotInitialized = statusQ.data && !statusQ.data.initialized;
 		const notaryUserNotLoggedIn = !statusQ.isLoading && userQ.isError;
 		const notaryUserLoggedIn = !statusQ.isLoading && userQ.data;
+		const notaryUserMustEnrollMFA = mfaQ.data?.enrollment_required;
 
 		if (notaryStatusIsLoading || notaryUserDataIsLoading) return;
 		if (notaryIsNotInitialized) {
@@ -46,6 +55,17 @@ export function useLoginRedirect() {
 			});
 			return;
 		}
+		if (
+			notaryUserLoggedIn &&
+			notaryUserMustEnrollMFA &&
+			location.pathname !== "/mfa"
+		) {
+			navigate({
+				to: "/mfa",
+				replace: true,
+			});
+			return;
+		}
 		if (notaryUserLoggedIn && location.pathname === "/login") {
 			navigate({
 				to: "/",
@@ -66,6 +86,7 @@ export function useLoginRedirect() {
 		userQ.isLoading,
 		userQ.data,
 		userQ.isError,
+		mfaQ.data,
 		navigate,
 		location.pathname,
 	]);
@@ -10,6 +10,7 @@
 
 import { Route as rootRouteImport } from './routes/__root'
 import { Route as UsersRouteRouteImport } from './routes/users/route'
+import { Route as MfaRouteRouteImport } from './routes/mfa/route'
 import { Route as LoginRouteRouteImport } from './routes/login/route'
 import { Route as InitializeRouteRouteImport } from './routes/initialize/route'
 import { Route as ConfigurationRouteRouteImport } from './routes/configuration/route'
@@ -23,6 +24,11 @@ const UsersRouteRoute = UsersRouteRouteImport.update({
   path: '/users',
   getParentRoute: () => rootRouteImport,
 } as any)
+const MfaRouteRoute = MfaRouteRouteImport.update({
+  id: '/mfa',
+  path: '/mfa',
+  getParentRoute: () => rootRouteImport,
+} as any)
 const LoginRouteRoute = LoginRouteRouteImport.update({
   id: '/login',
   path: '/login',
@@ -69,6 +75,7 @@ export interface FileRoutesByFullPath {
   '/configuration': typeof ConfigurationRouteRoute
   '/initialize': typeof InitializeRouteRoute
   '/login': typeof LoginRouteRoute
+  '/mfa': typeof MfaRouteRoute
   '/users': typeof UsersRouteRoute
 }
 export interface FileRoutesByTo {
@@ -79,6 +86,7 @@ export interface FileRoutesByTo {
   '/configuration': typeof ConfigurationRouteRoute
   '/initialize': typeof InitializeRouteRoute
   '/login': typeof LoginRouteRoute
+  '/mfa': typeof MfaRouteRoute
   '/users': typeof UsersRouteRoute
 }
 export interface FileRoutesById {
@@ -90,6 +98,7 @@ export interface FileRoutesById {
   '/configuration': typeof ConfigurationRouteRoute
   '/initialize': typeof InitializeRouteRoute
   '/login': typeof LoginRouteRoute
+  '/mfa': typeof MfaRouteRoute
   '/users': typeof UsersRouteRoute
 }
 export interface FileRouteTypes {
@@ -102,6 +111,7 @@ export interface FileRouteTypes {
     | '/configuration'
     | '/initialize'
     | '/login'
+    | '/mfa'
     | '/users'
   fileRoutesByTo: FileRoutesByTo
   to:
@@ -112,6 +122,7 @@ export interface FileRouteTypes {
     | '/configuration'
     | '/initialize'
     | '/login'
+    | '/mfa'
     | '/users'
   id:
     | '__root__'
@@ -122,6 +133,7 @@ export interface FileRouteTypes {
     | '/configuration'
     | '/initialize'
     | '/login'
+    | '/mfa'
     | '/users'
   fileRoutesById: FileRoutesById
 }
@@ -133,6 +145,7 @@ export interface RootRouteChildren {
   ConfigurationRouteRoute: typeof ConfigurationRouteRoute
   InitializeRouteRoute: typeof InitializeRouteRoute
   LoginRouteRoute: typeof LoginRouteRoute
+  MfaRouteRoute: typeof MfaRouteRoute
   UsersRouteRoute: typeof UsersRouteRoute
 }
 
@@ -145,6 +158,13 @@ declare module '@tanstack/react-router' {
       preLoaderRoute: typeof UsersRouteRouteImport
       parentRoute: typeof rootRouteImport
     }
+    '/mfa': {
+      id: '/mfa'
+      path: '/mfa'
+      fullPath: '/mfa'
+      preLoaderRoute: typeof MfaRouteRouteImport
+      parentRoute: typeof rootRouteImport
+    }
     '/login': {
       id: '/login'
       path: '/login'
@@ -205,6 +225,7 @@ const rootRouteChildren: RootRouteChildren = {
   ConfigurationRouteRoute: ConfigurationRouteRoute,
   InitializeRouteRoute: InitializeRouteRoute,
   LoginRouteRoute: LoginRouteRoute,
+  MfaRouteRoute: MfaRouteRoute,
   UsersRouteRoute: UsersRouteRoute,
 }
 export const routeTree = rootRouteImport
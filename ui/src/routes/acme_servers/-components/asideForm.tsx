@@ -221,7 +221,7 @@ export default function ACMEServersAsidePanel({
 						onChange={(e: ChangeEvent<HTMLInputElement>) =>
 							setDNSProvider(e.target.value)
 						}
-						help="The LEGO DNS provider name, e.g. cloudflare, hetzner, route53."
+						help="The DNS provider name: notary for the built-in provider, or the name of a lego DNS provider such as cloudflare or route53."
 						stacked
 						required
 					/>
@@ -42,7 +42,7 @@ export default function ACMEServersTable({
 			queryKey: "acme_servers",
 			closeFn: () => setConfirmationModalData(null),
 			buttonConfirmText: "Set Active",
-			warningText: `"${server.name}" will become the active ACME server. Any ongoing signing requests using a different server will continue until complete.`,
+			warningText: `"${server.name}" will sign the certificate requests that no other ACME route matches. Any ongoing signing requests using a different server will continue until complete.`,
 			successTitle: "Active ACME server updated",
 			successMessage: `"${server.name}" is now the active ACME server.`,
 			failureMessage: "Failed to set the active ACME server.",
@@ -56,10 +56,10 @@ export default function ACMEServersTable({
 			queryKey: "acme_servers",
 			closeFn: () => setConfirmationModalData(null),
 			buttonConfirmText: "Delete",
-			warningText: `Deleting "${server.name}" will remove its configuration permanently. This action cannot be undone.`,
+			warningText: `Deleting "${server.name}" will remove its configuration permanently. ACME servers that ACME routes send certificate requests to can't be deleted. This action cannot be undone.`,
 			successTitle: "ACME server deleted",
 			successMessage: `"${server.name}" was deleted successfully.`,
-			failureMessage: "Failed to delete the ACME server.",
+			failureMessage: "Failed to delete the ACME server. Remove the ACME routes to it first.",
 		});
 	};
 
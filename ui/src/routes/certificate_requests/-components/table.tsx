@@ -139,10 +139,11 @@ export function CertificateRequestsTable({
 			},
 			closeFn: () => setConfirmationModalData(null),
 			queryKey: "csrs",
-			warningText: `Signing with ACME will submit this CSR to "${acmeServerName}" via DNS-01 challenge. This may take a few moments to complete.`,
+			warningText: `Signing with ACME will submit this CSR to "${acmeServerName}" via DNS-01 challenge. The order runs in the background and may take a few minutes to complete.`,
 			buttonConfirmText: "Sign with ACME",
-			successTitle: "Certificate request signed via ACME",
-			successMessage: "The certificate was issued by the ACME server.",
+			successTitle: "ACME order queued",
+			successMessage:
+				"The certificate will appear once the ACME server has issued it.",
 			failureMessage: "Failed to sign via ACME.",
 		});
 	};
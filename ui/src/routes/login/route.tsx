@@ -9,8 +9,8 @@ import {
 import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
 import { createFileRoute } from "@tanstack/react-router";
 import { type ChangeEvent, useState } from "react";
-import { getStatus, login } from "@/utils/queries";
-import { getErrorMessage } from "@/utils/types";
+import { getStatus, login, loginMFA } from "@/utils/queries";
+import { getErrorMessage, type LoginResult } from "@/utils/types";
 
 export const Route = createFileRoute("/login")({
 	component: LoginPageComponent,
@@ -23,8 +23,24 @@ function LoginPageComponent() {
 		queryFn: getStatus,
 		retry: false,
 	});
+	// mfaToken is set when the password was accepted and the login must be completed with a second factor.
+	const [mfaToken, setMFAToken] = useState<string>("");
 	const loginMutation = useMutation({
 		mutationFn: login,
+		onSuccess: async (result: LoginResult | undefined) => {
+			setErrorText("");
+			if (result?.mfa_required && result.mfa_token) {
+				setMFAToken(result.mfa_token);
+				return;
+			}
+			await queryClient.invalidateQueries({ queryKey: ["user"] });
+		},
+		onError: (e: Error) => {
+			setErrorText(getErrorMessage(e));
+		},
+	});
+	const mfaMutation = useMutation({
+		mutationFn: loginMFA,
 		onSuccess: async () => {
 			await queryClient.invalidateQueries({ queryKey: ["user"] });
 		},
@@ -35,6 +51,7 @@ function LoginPageComponent() {
 
 	const [email, setEmail] = useState<string>("");
 	const [password, setPassword] = useState<string>("");
+	const [code, setCode] = useState<string>("");
 	const [errorText, setErrorText] = useState<string>("");
 	const handleEmailChange = (event: ChangeEvent<HTMLInputElement>) => {
 		setEmail(event.target.value);
@@ -42,6 +59,58 @@ function LoginPageComponent() {
 	const handlePasswordChange = (event: ChangeEvent<HTMLInputElement>) => {
 		setPassword(event.target.value);
 	};
+	const handleCodeChange = (event: ChangeEvent<HTMLInputElement>) => {
+		setCode(event.target.value);
+	};
+	if (mfaToken) {
+		return (
+			<LoginPageLayout
+				logo={{
+					src: "https://assets.ubuntu.com/v1/82818827-CoF_white.svg",
+					title: "Notary",
+					url: "#",
+				}}
+				title="Multi-factor authentication"
+			>
+				<Form>
+					<Input
+						id="InputMFACode"
+						label="Authentication code"
+						type="text"
+						autoComplete="one-time-code"
+						help="A code of your authenticator app, or one of your recovery codes."
+						required={true}
+						onChange={handleCodeChange}
+					/>
+					{errorText && (
+						<Notification severity="negative" title="Error">
+							{errorText}
+						</Notification>
+					)}
+					<Button
+						appearance="positive"
+						disabled={code.trim().length === 0 || mfaMutation.isPending}
+						onClick={(event) => {
+							event.preventDefault();
+							mfaMutation.mutate({ mfa_token: mfaToken, code: code });
+						}}
+					>
+						Verify
+					</Button>
+					<Button
+						onClick={(event) => {
+							event.preventDefault();
+							setMFAToken("");
+							setCode("");
+							setErrorText("");
+						}}
+					>
+						Back
+					</Button>
+				</Form>
+			</LoginPageLayout>
+		);
+	}
 	return (
 		<LoginPageLayout
 			logo={{
@@ -0,0 +1,301 @@
+import {
+	Application,
+	AppMain,
+	Button,
+	Form,
+	Input,
+	Notification,
+	Panel,
+	ToastNotificationProvider,
+} from "@canonical/react-components";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { createFileRoute, useNavigate } from "@tanstack/react-router";
+import { type ChangeEvent, useState } from "react";
+import ErrorComponent from "@/components/error";
+import Loading from "@/components/loading";
+import NotaryAppNavigationBars from "@/components/NotaryAppNavigationBars";
+import NotaryAppStatus from "@/components/NotaryAppStatus";
+import { retryUnlessUnauthorized } from "@/utils/helpers";
+import {
+	disableTOTP,
+	enrollTOTP,
+	getMyMFA,
+	regenerateRecoveryCodes,
+	verifyTOTP,
+} from "@/utils/queries";
+import {
+	getErrorMessage,
+	type MFAStatus,
+	type TOTPEnrollment,
+} from "@/utils/types";
+
+export const Route = createFileRoute("/mfa")({
+	component: MFAPageComponent,
+});
+
+function MFAPageComponent() {
+	const query = useQuery<MFAStatus, Error>({
+		queryKey: ["mfa"],
+		queryFn: getMyMFA,
+		retry: retryUnlessUnauthorized,
+	});
+	if (query.status === "pending") {
+		return <Loading />;
+	}
+	if (query.status === "error") {
+		return <ErrorComponent msg={getErrorMessage(query.error)} />;
+	}
+	return (
+		<Application>
+			<ToastNotificationProvider>
+				<NotaryAppNavigationBars />
+				<AppMain>
+					<Panel
+						stickyHeader
+						title="Multi-Factor Authentication"
+						className="u-fixed-width"
+					>
+						<MFASettings status={query.data} />
+					</Panel>
+				</AppMain>
+				<NotaryAppStatus />
+			</ToastNotificationProvider>
+		</Application>
+	);
+}
+
+function MFASettings({ status }: { status: MFAStatus }) {
+	// Recovery codes are only returned once, when they are generated, so they are kept until the user is done.
+	const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+	if (recoveryCodes.length > 0) {
+		return (
+			<RecoveryCodesList
+				codes={recoveryCodes}
+				onDone={() => setRecoveryCodes([])}
+			/>
+		);
+	}
+	if (!status.totp_enabled) {
+		return (
+			<EnrollTOTP required={status.required} onEnrolled={setRecoveryCodes} />
+		);
+	}
+	return (
+		<ManageTOTP
+			status={status}
+			onRecoveryCodesRegenerated={setRecoveryCodes}
+		/>
+	);
+}
+
+function EnrollTOTP({
+	required,
+	onEnrolled,
+}: {
+	required: boolean;
+	onEnrolled: (codes: string[]) => void;
+}) {
+	const queryClient = useQueryClient();
+	const [enrollment, setEnrollment] = useState<TOTPEnrollment | null>(null);
+	const [code, setCode] = useState<string>("");
+	const [errorText, setErrorText] = useState<string>("");
+	const enrollMutation = useMutation({
+		mutationFn: enrollTOTP,
+		onSuccess: (result: TOTPEnrollment) => {
+			setErrorText("");
+			setEnrollment(result);
+		},
+		onError: (e: Error) => {
+			setErrorText(getErrorMessage(e));
+		},
+	});
+	const verifyMutation = useMutation({
+		mutationFn: verifyTOTP,
+		onSuccess: async (result) => {
+			setErrorText("");
+			onEnrolled(result.recovery_codes);
+			await queryClient.invalidateQueries({ queryKey: ["mfa"] });
+		},
+		onError: (e: Error) => {
+			setErrorText(getErrorMessage(e));
+		},
+	});
+	return (
+		<>
+			{required && (
+				<Notification
+					severity="caution"
+					title="Multi-factor authentication required"
+				>
+					Your role requires multi-factor authentication. Set up an
+					authenticator app to use Notary.
+				</Notification>
+			)}
+			<p>
+				Add a TOTP authenticator app as a second factor to your password. Once
+				it is set up, logging in asks for a code of the app.
+			</p>
+			{enrollment ? (
+				<Form>
+					<p>
+						Add this secret to your authenticator app, or open the link on a
+						device that has it:
+					</p>
+					<pre>
+						<code>{enrollment.secret}</code>
+					</pre>
+					<p>
+						<a href={enrollment.uri}>{enrollment.uri}</a>
+					</p>
+					<Input
+						id="InputTOTPCode"
+						label="Code of the authenticator app"
+						type="text"
+						autoComplete="one-time-code"
+						required={true}
+						onChange={(event: ChangeEvent<HTMLInputElement>) =>
+							setCode(event.target.value)
+						}
+					/>
+					{errorText && (
+						<Notification severity="negative" title="Error">
+							{errorText}
+						</Notification>
+					)}
+					<Button
+						appearance="positive"
+						disabled={code.trim().length === 0 || verifyMutation.isPending}
+						onClick={(event) => {
+							event.preventDefault();
+							verifyMutation.mutate({ code: code });
+						}}
+					>
+						Verify
+					</Button>
+				</Form>
+			) : (
+				<>
+					{errorText && (
+						<Notification severity="negative" title="Error">
+							{errorText}
+						</Notification>
+					)}
+					<Button
+						appearance="positive"
+						disabled={enrollMutation.isPending}
+						onClick={() => enrollMutation.mutate()}
+					>
+						Set Up Authenticator
+					</Button>
+				</>
+			)}
+		</>
+	);
+}
+
+function ManageTOTP({
+	status,
+	onRecoveryCodesRegenerated,
+}: {
+	status: MFAStatus;
+	onRecoveryCodesRegenerated: (codes: string[]) => void;
+}) {
+	const queryClient = useQueryClient();
+	const [code, setCode] = useState<string>("");
+	const [errorText, setErrorText] = useState<string>("");
+	const regenerateMutation = useMutation({
+		mutationFn: regenerateRecoveryCodes,
+		onSuccess: async (result) => {
+			setErrorText("");
+			onRecoveryCodesRegenerated(result.recovery_codes);
+			await queryClient.invalidateQueries({ queryKey: ["mfa"] });
+		},
+		onError: (e: Error) => {
+			setErrorText(getErrorMessage(e));
+		},
+	});
+	const disableMutation = useMutation({
+		mutationFn: disableTOTP,
+		onSuccess: async () => {
+			setErrorText("");
+			await queryClient.invalidateQueries({ queryKey: ["mfa"] });
+		},
+		onError: (e: Error) => {
+			setErrorText(getErrorMessage(e));
+		},
+	});
+	return (
+		<Form>
+			<p>
+				Multi-factor authentication is enabled.{" "}
+				{status.recovery_codes_remaining} recovery codes remain.
+			</p>
+			<Input
+				id="InputTOTPCode"
+				label="Code of the authenticator app, or a recovery code"
+				type="text"
+				autoComplete="one-time-code"
+				required={true}
+				onChange={(event: ChangeEvent<HTMLInputElement>) =>
+					setCode(event.target.value)
+				}
+			/>
+			{errorText && (
+				<Notification severity="negative" title="Error">
+					{errorText}
+				</Notification>
+			)}
+			<Button
+				disabled={code.trim().length === 0 || regenerateMutation.isPending}
+				onClick={(event) => {
+					event.preventDefault();
+					regenerateMutation.mutate({ code: code });
+				}}
+			>
+				Regenerate Recovery Codes
+			</Button>
+			{!status.required && (
+				<Button
+					appearance="negative"
+					disabled={code.trim().length === 0 || disableMutation.isPending}
+					onClick={(event) => {
+						event.preventDefault();
+						disableMutation.mutate({ code: code });
+					}}
+				>
+					Disable
+				</Button>
+			)}
+		</Form>
+	);
+}
+
+function RecoveryCodesList({
+	codes,
+	onDone,
+}: {
+	codes: string[];
+	onDone: () => void;
+}) {
+	const navigate = useNavigate();
+	return (
+		<>
+			<Notification severity="information" title="Recovery codes">
+				Save these recovery codes somewhere safe. Each of them logs you in once
+				if you lose your authenticator app, and they are only shown now.
+			</Notification>
+			<pre>
+				<code>{codes.join("\n")}</code>
+			</pre>
+			<Button
+				appearance="positive"
+				onClick={() => {
+					onDone();
+					navigate({ to: "/" });
+				}}
+			>
+				Done
+			</Button>
+		</>
+	);
+}
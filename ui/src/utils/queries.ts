@@ -7,6 +7,10 @@ import {
 	type CertificateAuthorityEntry,
 	type ConfigEntry,
 	type CSREntry,
+	type LoginResult,
+	type MFAStatus,
+	type RecoveryCodes,
+	type TOTPEnrollment,
 	type UserEntry,
 } from "@/utils/types";
 
@@ -147,7 +151,7 @@ export async function revokeCertificate(params: RequiredCSRParams) {
 }
 
 export async function login(userForm: { email: string; password: string }) {
-	return fetchAPI("/login", {
+	return fetchAPI<LoginResult>("/login", {
 		method: "POST",
 
 		body: JSON.stringify({
@@ -157,6 +161,16 @@ export async function login(userForm: { email: string; password: string }) {
 	});
 }
 
+export async function loginMFA(mfaForm: { mfa_token: string; code: string }) {
+	return fetchAPI<LoginResult>("/login/mfa", {
+		method: "POST",
+		body: JSON.stringify({
+			mfa_token: mfaForm.mfa_token,
+			code: mfaForm.code.trim(),
+		}),
+	});
+}
+
 export async function logout() {
 	return fetchAPI("/logout", { method: "POST" });
 }
@@ -190,6 +204,47 @@ export async function getSelfAccount(): Promise<UserEntry> {
 	return (await fetchAPI<UserEntry>("/api/v1/accounts/me")) as UserEntry;
 }
 
+export async function getMyMFA(): Promise<MFAStatus> {
+	return (await fetchAPI<MFAStatus>("/api/v1/accounts/me/mfa")) as MFAStatus;
+}
+
+export async function enrollTOTP(): Promise<TOTPEnrollment> {
+	return (await fetchAPI<TOTPEnrollment>("/api/v1/accounts/me/mfa/totp", {
+		method: "POST",
+	})) as TOTPEnrollment;
+}
+
+export async function verifyTOTP(params: {
+	code: string;
+}): Promise<RecoveryCodes> {
+	return (await fetchAPI<RecoveryCodes>(
+		"/api/v1/accounts/me/mfa/totp/verify",
+		{
+			method: "POST",
+			body: JSON.stringify({ code: params.code.trim() }),
+		},
+	)) as RecoveryCodes;
+}
+
+export async function disableTOTP(params: { code: string }) {
+	return fetchAPI("/api/v1/accounts/me/mfa/totp/disable", {
+		method: "POST",
+		body: JSON.stringify({ code: params.code.trim() }),
+	});
+}
+
+export async function regenerateRecoveryCodes(params: {
+	code: string;
+}): Promise<RecoveryCodes> {
+	return (await fetchAPI<RecoveryCodes>(
+		"/api/v1/accounts/me/mfa/recovery_codes",
+		{
+			method: "POST",
+			body: JSON.stringify({ code: params.code.trim() }),
+		},
+	)) as RecoveryCodes;
+}
+
 export async function deleteUser(params: { id: string }) {
 	return fetchAPI(`/api/v1/accounts/${params.id}`, {
 		method: "delete",
@@ -83,6 +83,28 @@ export type UserEntry = {
 	role_id: RoleID;
 };
 
+export type LoginResult = {
+	token?: string;
+	mfa_required?: boolean;
+	mfa_token?: string;
+};
+
+export type MFAStatus = {
+	totp_enabled: boolean;
+	recovery_codes_remaining: number;
+	required: boolean;
+	enrollment_required: boolean;
+};
+
+export type TOTPEnrollment = {
+	secret: string;
+	uri: string;
+};
+
+export type RecoveryCodes = {
+	recovery_codes: string[];
+};
+
 export type ConfigEntry = {
 	port: number;
 	pebble_notifications: boolean;